@@ -57,19 +57,20 @@ func ExtractPkScriptAddr(pkScript []byte, chain byte) (string, error) {
 	if threshold != 1 || len(addrs) != 1 || cls == txscript.NonStandardTy {
 		return "", fmt.Errorf("unsupported pkscript %d %v %d", cls, addrs, threshold)
 	}
-	return addrs[0].EncodeAddress(), nil
+	return encodeAddress(addrs[0], chain)
 }
 
 func EncodeAddress(script []byte, chain byte) (string, error) {
 	typ := checkScriptType(script)
 	switch typ {
 	case InputTypeP2WSHMultisigHolderSigner:
-		msh := sha256.Sum256(script)
-		mwsh, err := btcutil.NewAddressWitnessScriptHash(msh[:], NetConfig(chain))
+		return scriptHashAddress(script, chain)
+	case InputTypeP2TRHolderSigner:
+		ts, err := parseTaprootScript(script)
 		if err != nil {
 			return "", err
 		}
-		return mwsh.EncodeAddress(), nil
+		return ts.address(chain)
 	default:
 		panic(typ)
 	}
@@ -121,10 +122,10 @@ func BuildWitnessScriptAccount(holder, signer, observer string, lock time.Durati
 		pubKeys = append(pubKeys, pub)
 	}
 
-	if lock < TimeLockMinimum || lock > TimeLockMaximum {
-		return nil, fmt.Errorf("time lock out of range %s", lock.String())
+	sequence, err := ParseSequence(lock, chain)
+	if err != nil {
+		return nil, err
 	}
-	sequence := ParseSequence(lock, chain)
 
 	builder := txscript.NewScriptBuilder()
 	builder.AddData(pubKeys[0].ScriptAddress())
@@ -151,19 +152,73 @@ func BuildWitnessScriptAccount(holder, signer, observer string, lock time.Durati
 	if err != nil {
 		return nil, fmt.Errorf("build.Script() => %v", err)
 	}
-	msh := sha256.Sum256(script)
-	mwsh, err := btcutil.NewAddressWitnessScriptHash(msh[:], NetConfig(chain))
+	addr, err := scriptHashAddress(script, chain)
 	if err != nil {
-		return nil, fmt.Errorf("btcutil.NewAddressWitnessScriptHash(%x) => %v", msh[:], err)
+		return nil, fmt.Errorf("scriptHashAddress(%x) => %v", script, err)
 	}
 
 	return &WitnessScriptAccount{
 		Sequence: uint32(sequence),
 		Script:   script,
-		Address:  mwsh.EncodeAddress(),
+		Address:  addr,
 	}, nil
 }
 
+// bitcoin cash has no segwit, so the same script is paid to the legacy
+// script hash, and signed with the BIP143 forkid sighash of the amount
+func IsSegwitChain(chain byte) bool {
+	switch chain {
+	case ChainBitcoin, ChainLitecoin:
+		return true
+	case ChainBitcoinCash:
+		return false
+	default:
+		panic(chain)
+	}
+}
+
+func scriptHashAddress(script []byte, chain byte) (string, error) {
+	if IsSegwitChain(chain) {
+		msh := sha256.Sum256(script)
+		mwsh, err := btcutil.NewAddressWitnessScriptHash(msh[:], NetConfig(chain))
+		if err != nil {
+			return "", err
+		}
+		return mwsh.EncodeAddress(), nil
+	}
+	if len(script) > txscript.MaxScriptElementSize {
+		return "", fmt.Errorf("large script %d", len(script))
+	}
+	sh, err := btcutil.NewAddressScriptHash(script, NetConfig(chain))
+	if err != nil {
+		return "", err
+	}
+	return encodeAddress(sh, chain)
+}
+
+func encodeAddress(addr btcutil.Address, chain byte) (string, error) {
+	switch chain {
+	case ChainBitcoinCash:
+		return encodeCashAddress(addr)
+	default:
+		return addr.EncodeAddress(), nil
+	}
+}
+
+func decodeAddress(address string, chain byte) (btcutil.Address, error) {
+	if chain == ChainBitcoinCash {
+		return decodeCashAddress(address)
+	}
+	bda, err := btcutil.DecodeAddress(address, NetConfig(chain))
+	if err != nil {
+		return nil, fmt.Errorf("btcutil.DecodeAddress(%s, %d) => %v", address, chain, err)
+	}
+	if !bda.IsForNet(NetConfig(chain)) {
+		return nil, fmt.Errorf("btcutil.IsForNet(%s, %d)", address, chain)
+	}
+	return bda, nil
+}
+
 func CheckMultisigHolderSignerScript(script []byte) bool {
 	return checkScriptType(script) == InputTypeP2WSHMultisigHolderSigner
 }
@@ -178,7 +233,7 @@ func parseBitcoinCompressedPublicKey(public string) (*btcutil.AddressPubKey, err
 
 func ValueDust(chain byte) int64 {
 	switch chain {
-	case ChainBitcoin:
+	case ChainBitcoin, ChainBitcoinCash:
 		return 1000
 	case ChainLitecoin:
 		return 10000
@@ -189,7 +244,7 @@ func ValueDust(chain byte) int64 {
 
 func protocolVersion(chain byte) uint32 {
 	switch chain {
-	case ChainBitcoin:
+	case ChainBitcoin, ChainBitcoinCash:
 		return wire.ProtocolVersion
 	case ChainLitecoin:
 		return 70015
@@ -223,12 +278,25 @@ func NetConfig(chain byte) *chaincfg.Params {
 			HDPublicKeyID:  [4]byte{0x01, 0x9d, 0xa4, 0x64},
 			HDPrivateKeyID: [4]byte{0x01, 0x9d, 0x9c, 0xfe},
 		}
+	case ChainBitcoinCash:
+		return &chaincfg.Params{
+			Net: 0xe8f3e1e3,
+
+			PubKeyHashAddrID: 0x00,
+			ScriptHashAddrID: 0x05,
+
+			HDPublicKeyID:  [4]byte{0x04, 0x88, 0xb2, 0x1e},
+			HDPrivateKeyID: [4]byte{0x04, 0x88, 0xad, 0xe4},
+		}
 	default:
 		panic(chain)
 	}
 }
 
 func checkScriptType(script []byte) int {
+	if len(script) > 2 && script[0] == byte(txscript.BaseLeafVersion) {
+		return InputTypeP2TRHolderSigner
+	}
 	if len(script) == 33 {
 		return InputTypeP2WPKHAccoutant
 	}
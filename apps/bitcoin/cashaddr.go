@@ -0,0 +1,135 @@
+package bitcoin
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcutil"
+)
+
+const (
+	cashAddrPrefix  = "bitcoincash"
+	cashAddrCharset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+	cashAddrTypePubKeyHash = 0
+	cashAddrTypeScriptHash = 8
+)
+
+var cashAddrGenerator = []uint64{0x98f2bc8e61, 0x79b76d99e2, 0xf33e5fb3c4, 0xae2eabe2a8, 0x1e4f43e470}
+
+// the bitcoin cash addresses are always encoded with the prefix, and only
+// the 160 bits hashes of pay to public key hash and script hash are valid
+func encodeCashAddress(addr btcutil.Address) (string, error) {
+	var version byte
+	switch addr.(type) {
+	case *btcutil.AddressPubKeyHash:
+		version = cashAddrTypePubKeyHash
+	case *btcutil.AddressScriptHash:
+		version = cashAddrTypeScriptHash
+	default:
+		return "", fmt.Errorf("invalid cash address type %T", addr)
+	}
+	hash := addr.ScriptAddress()
+	if len(hash) != 20 {
+		return "", fmt.Errorf("invalid cash address hash %x", hash)
+	}
+	data, err := convertBits(append([]byte{version}, hash...), 8, 5, true)
+	if err != nil {
+		return "", err
+	}
+	checksum := cashAddrPolymod(cashAddrPrefix, append(data, make([]byte, 8)...))
+
+	var sb strings.Builder
+	sb.WriteString(cashAddrPrefix + ":")
+	for _, b := range data {
+		sb.WriteByte(cashAddrCharset[b])
+	}
+	for i := 0; i < 8; i++ {
+		sb.WriteByte(cashAddrCharset[(checksum>>(5*(7-i)))&31])
+	}
+	return sb.String(), nil
+}
+
+func decodeCashAddress(s string) (btcutil.Address, error) {
+	if strings.ToLower(s) != s && strings.ToUpper(s) != s {
+		return nil, fmt.Errorf("invalid cash address case %s", s)
+	}
+	s = strings.ToLower(s)
+	prefix, payload := cashAddrPrefix, s
+	if i := strings.LastIndex(s, ":"); i >= 0 {
+		prefix, payload = s[:i], s[i+1:]
+	}
+	if prefix != cashAddrPrefix || len(payload) != 42 {
+		return nil, fmt.Errorf("invalid cash address %s", s)
+	}
+	data := make([]byte, len(payload))
+	for i, c := range payload {
+		v := strings.IndexRune(cashAddrCharset, c)
+		if v < 0 {
+			return nil, fmt.Errorf("invalid cash address character %q", c)
+		}
+		data[i] = byte(v)
+	}
+	if cashAddrPolymod(prefix, data) != 0 {
+		return nil, fmt.Errorf("invalid cash address checksum %s", s)
+	}
+	decoded, err := convertBits(data[:len(data)-8], 5, 8, false)
+	if err != nil || len(decoded) != 21 {
+		return nil, fmt.Errorf("invalid cash address payload %s", s)
+	}
+	params := NetConfig(ChainBitcoinCash)
+	switch decoded[0] {
+	case cashAddrTypePubKeyHash:
+		return btcutil.NewAddressPubKeyHash(decoded[1:], params)
+	case cashAddrTypeScriptHash:
+		return btcutil.NewAddressScriptHashFromHash(decoded[1:], params)
+	default:
+		return nil, fmt.Errorf("invalid cash address version %d", decoded[0])
+	}
+}
+
+func cashAddrPolymod(prefix string, data []byte) uint64 {
+	var values []byte
+	for _, c := range []byte(prefix) {
+		values = append(values, c&31)
+	}
+	values = append(values, 0)
+	values = append(values, data...)
+
+	c := uint64(1)
+	for _, d := range values {
+		c0 := byte(c >> 35)
+		c = ((c & 0x07ffffffff) << 5) ^ uint64(d)
+		for i, g := range cashAddrGenerator {
+			if (c0>>i)&1 == 1 {
+				c ^= g
+			}
+		}
+	}
+	return c ^ 1
+}
+
+func convertBits(data []byte, from, to uint, pad bool) ([]byte, error) {
+	var acc, bits uint
+	var ret []byte
+	maxv := uint(1<<to) - 1
+	for _, v := range data {
+		if uint(v)>>from != 0 {
+			return nil, fmt.Errorf("invalid data range %d", v)
+		}
+		acc = acc<<from | uint(v)
+		bits += from
+		for bits >= to {
+			bits -= to
+			ret = append(ret, byte(acc>>bits&maxv))
+		}
+	}
+	if pad {
+		if bits > 0 {
+			ret = append(ret, byte(acc<<(to-bits)&maxv))
+		}
+	} else if bits >= from || acc<<(to-bits)&maxv != 0 {
+		return nil, fmt.Errorf("invalid padding")
+	}
+	return ret, nil
+}
@@ -0,0 +1,122 @@
+package bitcoin
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/ecdsa"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCashAddress(t *testing.T) {
+	require := require.New(t)
+
+	hash := "76a04053bda0a88bda5177b86a15c3b29f559873"
+	addr, err := decodeCashAddress("bitcoincash:qpm2qsznhks23z7629mms6s4cwef74vcwvy22gdx6a")
+	require.Nil(err)
+	require.Equal(hash, hex.EncodeToString(addr.ScriptAddress()))
+	require.Equal("1BpEi6DfDAUFd7GtittLSdBeYJvcoaVggu", addr.EncodeAddress())
+	_, ok := addr.(*btcutil.AddressPubKeyHash)
+	require.True(ok)
+	encoded, err := encodeCashAddress(addr)
+	require.Nil(err)
+	require.Equal("bitcoincash:qpm2qsznhks23z7629mms6s4cwef74vcwvy22gdx6a", encoded)
+
+	addr, err = decodeCashAddress("PPM2QSZNHKS23Z7629MMS6S4CWEF74VCWVN0H829PQ")
+	require.Nil(err)
+	require.Equal(hash, hex.EncodeToString(addr.ScriptAddress()))
+	_, ok = addr.(*btcutil.AddressScriptHash)
+	require.True(ok)
+	encoded, err = encodeCashAddress(addr)
+	require.Nil(err)
+	require.Equal("bitcoincash:ppm2qsznhks23z7629mms6s4cwef74vcwvn0h829pq", encoded)
+
+	_, err = decodeCashAddress("bitcoincash:qpm2qsznhks23z7629mms6s4cwef74vcwvy22gdx6b")
+	require.NotNil(err)
+	_, err = decodeCashAddress("bchtest:qpm2qsznhks23z7629mms6s4cwef74vcwvy22gdx6a")
+	require.NotNil(err)
+	_, err = decodeCashAddress("bitcoincash:Qpm2qsznhks23z7629mms6s4cwef74vcwvy22gdx6a")
+	require.NotNil(err)
+
+	script, err := ParseAddress("bitcoincash:qpm2qsznhks23z7629mms6s4cwef74vcwvy22gdx6a", ChainBitcoinCash)
+	require.Nil(err)
+	require.Equal("76a91476a04053bda0a88bda5177b86a15c3b29f55987388ac", hex.EncodeToString(script))
+	extracted, err := ExtractPkScriptAddr(script, ChainBitcoinCash)
+	require.Nil(err)
+	require.Equal("bitcoincash:qpm2qsznhks23z7629mms6s4cwef74vcwvy22gdx6a", extracted)
+	_, err = ParseAddress("1BpEi6DfDAUFd7GtittLSdBeYJvcoaVggu", ChainBitcoinCash)
+	require.NotNil(err)
+	_, err = ParseAddress("bitcoincash:qpm2qsznhks23z7629mms6s4cwef74vcwvy22gdx6a", ChainBitcoin)
+	require.NotNil(err)
+}
+
+func TestBitcoinCashTransaction(t *testing.T) {
+	require := require.New(t)
+
+	holderPriv, holder := testBitcoinCashKey("holder")
+	signerPriv, signer := testBitcoinCashKey("signer")
+	_, observer := testBitcoinCashKey("observer")
+	wsa, err := BuildWitnessScriptAccount(holder, signer, observer, time.Hour*24*7, ChainBitcoinCash)
+	require.Nil(err)
+	require.True(strings.HasPrefix(wsa.Address, "bitcoincash:p"))
+	script, err := ParseAddress(wsa.Address, ChainBitcoinCash)
+	require.Nil(err)
+	require.True(txscript.IsPayToScriptHash(script))
+
+	inputs := []*Input{{
+		TransactionHash: "6daf0a2ca612879093698c5ab6dbcff372e893137d5dfda23615e1489f5e0721",
+		Index:           1,
+		Satoshi:         100000,
+		Script:          wsa.Script,
+		Sequence:        MaxTransactionSequence,
+	}}
+	outputs := []*Output{{Address: "bitcoincash:qpm2qsznhks23z7629mms6s4cwef74vcwvy22gdx6a", Satoshi: 60000}}
+	psbt, err := BuildPartiallySignedTransaction(inputs, outputs, nil, ChainBitcoinCash)
+	require.Nil(err)
+	require.Len(psbt.UnsignedTx.TxOut, 2)
+	pin := psbt.Inputs[0]
+	require.Equal(wsa.Script, pin.RedeemScript)
+	require.Nil(pin.WitnessScript)
+	require.Equal(SigHashType|SigHashForkId, pin.SighashType)
+
+	psbt = SignPartiallySignedTransaction(psbt.Marshal(), holderPriv)
+	require.True(CheckTransactionPartiallySignedBy(hex.EncodeToString(psbt.Marshal()), holder))
+	_, err = psbt.SignedTransaction(holder, signer, observer)
+	require.NotNil(err)
+	psbt = SignPartiallySignedTransaction(psbt.Marshal(), signerPriv)
+	require.True(CheckTransactionPartiallySignedBy(hex.EncodeToString(psbt.Marshal()), signer))
+
+	msgTx, err := psbt.SignedTransaction(holder, signer, observer)
+	require.Nil(err)
+	require.Len(msgTx.TxIn[0].Witness, 0)
+	pushes, err := txscript.PushedData(msgTx.TxIn[0].SignatureScript)
+	require.Nil(err)
+	require.Equal(wsa.Script, pushes[len(pushes)-1])
+	hash := psbt.SigHash(0)
+	for _, push := range pushes[:len(pushes)-1] {
+		if len(push) == 0 {
+			continue
+		}
+		require.Equal(byte(SigHashType|SigHashForkId), push[len(push)-1])
+		sig, err := ecdsa.ParseDERSignature(push[:len(push)-1])
+		require.Nil(err)
+		require.True(sig.Verify(hash, holderPriv.PubKey()) || sig.Verify(hash, signerPriv.PubKey()))
+	}
+
+	err = SignAccountantInput(msgTx, 0, 100000, holderPriv, ChainBitcoinCash)
+	require.Nil(err)
+	pushes, _ = txscript.PushedData(msgTx.TxIn[0].SignatureScript)
+	require.Len(pushes, 2)
+	require.Equal(byte(txscript.SigHashAll|SigHashForkId), pushes[0][len(pushes[0])-1])
+	require.Equal(holder, hex.EncodeToString(pushes[1]))
+}
+
+func testBitcoinCashKey(seed string) (*btcec.PrivateKey, string) {
+	priv, pub := btcec.PrivKeyFromBytes(btcutil.Hash160([]byte(seed)))
+	return priv, hex.EncodeToString(pub.SerializeCompressed())
+}
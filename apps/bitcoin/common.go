@@ -8,7 +8,6 @@ import (
 	"time"
 
 	"github.com/MixinNetwork/mixin/common"
-	"github.com/btcsuite/btcd/btcutil"
 	"github.com/btcsuite/btcd/btcutil/hdkeychain"
 	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
@@ -18,8 +17,9 @@ import (
 )
 
 const (
-	ChainBitcoin  = 1
-	ChainLitecoin = 5
+	ChainBitcoin     = 1
+	ChainLitecoin    = 5
+	ChainBitcoinCash = 7
 
 	ValuePrecision = 8
 	ValueSatoshi   = 100000000
@@ -31,11 +31,17 @@ const (
 
 	ScriptPubKeyTypeWitnessKeyHash    = "witness_v0_keyhash"
 	ScriptPubKeyTypeWitnessScriptHash = "witness_v0_scripthash"
+	ScriptPubKeyTypeWitnessTaproot    = "witness_v1_taproot"
+	ScriptPubKeyTypeScriptHash        = "scripthash"
+	ScriptPubKeyTypePubKeyHash        = "pubkeyhash"
 	SigHashType                       = txscript.SigHashAll | txscript.SigHashAnyOneCanPay
+	SigHashForkId                     = 0x40
 
 	InputTypeP2WPKHAccoutant             = 1
 	InputTypeP2WSHMultisigHolderSigner   = 2
 	InputTypeP2WSHMultisigObserverSigner = 3
+	InputTypeP2TRHolderSigner            = 4
+	InputTypeP2TRObserverSigner          = 5
 
 	MaxTransactionSequence = 0xffffffff
 	MaxStandardTxWeight    = 300000
@@ -58,16 +64,13 @@ func ParseSatoshi(amount string) int64 {
 
 func ParseAddress(addr string, chain byte) ([]byte, error) {
 	switch chain {
-	case ChainBitcoin, ChainLitecoin:
+	case ChainBitcoin, ChainLitecoin, ChainBitcoinCash:
 	default:
 		return nil, fmt.Errorf("ParseAddress(%s, %d)", addr, chain)
 	}
-	bda, err := btcutil.DecodeAddress(addr, NetConfig(chain))
+	bda, err := decodeAddress(addr, chain)
 	if err != nil {
-		return nil, fmt.Errorf("btcutil.DecodeAddress(%s, %d) => %v", addr, chain, err)
-	}
-	if !bda.IsForNet(NetConfig(chain)) {
-		return nil, fmt.Errorf("btcutil.IsForNet(%s, %d)", addr, chain)
+		return nil, err
 	}
 	script, err := txscript.PayToAddrScript(bda)
 	if err != nil {
@@ -76,10 +79,32 @@ func ParseAddress(addr string, chain byte) ([]byte, error) {
 	return script, nil
 }
 
-func ParseSequence(lock time.Duration, chain byte) int64 {
+// the relative lock is at most 0xffff blocks, so the sequence of the safes
+// with a longer time lock on the chain is clamped to the maximum blocks
+func ParseSequence(lock time.Duration, chain byte) (int64, error) {
 	if lock < TimeLockMinimum || lock > TimeLockMaximum {
-		panic(lock.String())
+		return 0, fmt.Errorf("time lock out of range %s", lock.String())
+	}
+	blocks := timeLockBlocks(lock, chain)
+	if blocks >= 0xffff {
+		blocks = 0xffff
+	}
+	return blocks, nil
+}
+
+// the new safe is refused if its time lock could not be encoded in the
+// sequence without being shortened
+func VerifyTimeLock(lock time.Duration, chain byte) error {
+	if lock < TimeLockMinimum || lock > TimeLockMaximum {
+		return fmt.Errorf("time lock out of range %s", lock.String())
+	}
+	if timeLockBlocks(lock, chain) > 0xffff {
+		return fmt.Errorf("time lock %s exceeds %d blocks of chain %d", lock.String(), 0xffff, chain)
 	}
+	return nil
+}
+
+func timeLockBlocks(lock time.Duration, chain byte) int64 {
 	blockDuration := 10 * time.Minute
 	switch chain {
 	case ChainBitcoin:
@@ -87,12 +112,7 @@ func ParseSequence(lock time.Duration, chain byte) int64 {
 		blockDuration = 150 * time.Second
 	default:
 	}
-	// FIXME check litecoin timelock consensus as this may exceed 0xffff
-	lock = lock / blockDuration
-	if lock >= 0xffff {
-		lock = 0xffff
-	}
-	return int64(lock)
+	return int64(lock / blockDuration)
 }
 
 func CheckFeeRange(fvb int64, chain byte) bool {
@@ -101,6 +121,8 @@ func CheckFeeRange(fvb int64, chain byte) bool {
 		return fvb >= 2 && fvb <= 1000
 	case ChainLitecoin:
 		return fvb >= 1 && fvb <= 20
+	case ChainBitcoinCash:
+		return fvb >= 1 && fvb <= 20
 	default:
 		panic(chain)
 	}
@@ -152,7 +174,7 @@ func HashMessageForSignature(msg string, chain byte) []byte {
 	var buf bytes.Buffer
 	prefix := "Bitcoin Signed Message:\n"
 	switch chain {
-	case ChainBitcoin:
+	case ChainBitcoin, ChainBitcoinCash:
 	case ChainLitecoin:
 		prefix = "Litecoin Signed Message:\n"
 	default:
@@ -0,0 +1,236 @@
+package bitcoin
+
+import (
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+)
+
+const (
+	descriptorInputCharset    = "0123456789()[],'/*abcdefgh@:$%{}IJKLMNOPQRSTUVWXYZ&+-.;<=>?!^_|~ijklmnopqrstuvwxyzABCDEFGH`#\"\\ "
+	descriptorChecksumCharset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+)
+
+var descriptorGenerator = []uint64{0xf5dee51989, 0xa9fdca3312, 0x1bab10e32d, 0x3706b1677a, 0x644d626ffd}
+
+// the BIP-380 key expression, the origin is the master key fingerprint with
+// the optional hardened path to the xpub, e.g. 9c03cfaf/44'/0'/0'
+type DescriptorKey struct {
+	Origin string
+	XPub   string
+}
+
+// the BIP-388 wallet policy to register the safe on a hardware wallet, the
+// safe address is the receive address at the index
+type WalletPolicy struct {
+	Template string   `json:"template"`
+	Keys     []string `json:"keys"`
+	Index    uint32   `json:"index"`
+}
+
+type SafeDescriptor struct {
+	Descriptor string        `json:"descriptor"`
+	Policy     *WalletPolicy `json:"policy,omitempty"`
+}
+
+func ParseDescriptorKey(s string) (*DescriptorKey, error) {
+	dk := &DescriptorKey{XPub: s}
+	if strings.HasPrefix(s, "[") {
+		end := strings.Index(s, "]")
+		if end < 0 {
+			return nil, fmt.Errorf("invalid descriptor key %s", s)
+		}
+		dk.Origin, dk.XPub = s[1:end], s[end+1:]
+	}
+	key, err := hdkeychain.NewKeyFromString(dk.XPub)
+	if err != nil {
+		return nil, fmt.Errorf("hdkeychain.NewKeyFromString(%s) => %v", dk.XPub, err)
+	}
+	if key.IsPrivate() {
+		return nil, fmt.Errorf("private descriptor key %s", s)
+	}
+	_, _, err = dk.origin()
+	if err != nil {
+		return nil, err
+	}
+	return dk, nil
+}
+
+// the signer and observer keys are master keys without any origin path
+func NewMasterDescriptorKey(public string, chainCode []byte) (*DescriptorKey, error) {
+	xpub, pub, err := DeriveBIP32(public, chainCode)
+	if err != nil {
+		return nil, err
+	}
+	if pub != public {
+		return nil, fmt.Errorf("invalid master key %s", public)
+	}
+	finger := btcutil.Hash160(decodeHex(public))[:4]
+	return &DescriptorKey{Origin: hex.EncodeToString(finger), XPub: xpub}, nil
+}
+
+func (dk *DescriptorKey) String() string {
+	if dk.Origin == "" {
+		return dk.XPub
+	}
+	return fmt.Sprintf("[%s]%s", dk.Origin, dk.XPub)
+}
+
+// the master key fingerprint and the path to the xpub, the xpub itself is
+// the master key if without origin
+func (dk *DescriptorKey) origin() ([]byte, []uint32, error) {
+	if dk.Origin == "" {
+		key, err := hdkeychain.NewKeyFromString(dk.XPub)
+		if err != nil {
+			return nil, nil, err
+		}
+		pub, err := key.ECPubKey()
+		if err != nil {
+			return nil, nil, err
+		}
+		return btcutil.Hash160(pub.SerializeCompressed())[:4], nil, nil
+	}
+	parts := strings.Split(dk.Origin, "/")
+	finger, err := hex.DecodeString(parts[0])
+	if err != nil || len(finger) != 4 {
+		return nil, nil, fmt.Errorf("invalid descriptor key fingerprint %s", dk.String())
+	}
+	path := make([]uint32, len(parts)-1)
+	for i, p := range parts[1:] {
+		hardened := strings.HasSuffix(p, "'") || strings.HasSuffix(p, "h")
+		if hardened {
+			p = p[:len(p)-1]
+		}
+		c, err := strconv.ParseUint(p, 10, 31)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid descriptor key origin %s", dk.String())
+		}
+		path[i] = uint32(c)
+		if hardened {
+			path[i] += hdkeychain.HardenedKeyStart
+		}
+	}
+	return finger, path, nil
+}
+
+func (dk *DescriptorKey) Derive(path []uint32) (string, error) {
+	key, err := hdkeychain.NewKeyFromString(dk.XPub)
+	if err != nil {
+		return "", err
+	}
+	for _, i := range path {
+		key, err = key.Derive(i)
+		if err != nil {
+			return "", err
+		}
+	}
+	pub, err := key.ECPubKey()
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(pub.SerializeCompressed()), nil
+}
+
+// all keys must derive with the safe path to some key in the script, and the
+// script keys without a descriptor key are kept as raw public keys, thus the
+// wallet policy is only available when all the keys are known
+func BuildSafeDescriptor(script []byte, keys []*DescriptorKey, path []uint32) (*SafeDescriptor, error) {
+	ms, err := parseSafeMiniscript(script)
+	if err != nil {
+		return nil, fmt.Errorf("parseSafeMiniscript(%x) => %v", script, err)
+	}
+	order := miniscriptKeys(ms)
+
+	var suffix string
+	for _, i := range path {
+		if i >= hdkeychain.HardenedKeyStart {
+			return nil, fmt.Errorf("hardened descriptor path %v", path)
+		}
+		suffix = suffix + fmt.Sprintf("/%d", i)
+	}
+	derived := make(map[string]*DescriptorKey)
+	for _, dk := range keys {
+		pub, err := dk.Derive(path)
+		if err != nil {
+			return nil, fmt.Errorf("DescriptorKey.Derive(%s, %v) => %v", dk.String(), path, err)
+		}
+		if !slices.Contains(order, pub) || derived[pub] != nil {
+			return nil, fmt.Errorf("invalid descriptor key %s for script %x", dk.String(), script)
+		}
+		derived[pub] = dk
+	}
+
+	desc := "wsh(" + ms.format(func(k []byte) string {
+		dk := derived[hex.EncodeToString(k)]
+		if dk == nil {
+			return hex.EncodeToString(k)
+		}
+		return dk.String() + suffix
+	}) + ")"
+	checksum, err := DescriptorChecksum(desc)
+	if err != nil {
+		return nil, err
+	}
+	sd := &SafeDescriptor{Descriptor: desc + "#" + checksum}
+	if len(derived) != len(order) || len(path) != 2 || path[0] != 0 {
+		return sd, nil
+	}
+
+	policy := &WalletPolicy{Index: path[1]}
+	placeholders := make(map[string]int)
+	for i, k := range order {
+		placeholders[k] = i
+		policy.Keys = append(policy.Keys, derived[k].String())
+	}
+	policy.Template = "wsh(" + ms.format(func(k []byte) string {
+		return fmt.Sprintf("@%d/**", placeholders[hex.EncodeToString(k)])
+	}) + ")"
+	sd.Policy = policy
+	return sd, nil
+}
+
+func DescriptorChecksum(desc string) (string, error) {
+	var symbols, groups []uint64
+	for _, c := range desc {
+		v := strings.IndexRune(descriptorInputCharset, c)
+		if v < 0 {
+			return "", fmt.Errorf("invalid descriptor character %q", c)
+		}
+		symbols = append(symbols, uint64(v&31))
+		groups = append(groups, uint64(v>>5))
+		if len(groups) == 3 {
+			symbols = append(symbols, groups[0]*9+groups[1]*3+groups[2])
+			groups = nil
+		}
+	}
+	switch len(groups) {
+	case 1:
+		symbols = append(symbols, groups[0])
+	case 2:
+		symbols = append(symbols, groups[0]*3+groups[1])
+	}
+	symbols = append(symbols, make([]uint64, 8)...)
+
+	chk := uint64(1)
+	for _, v := range symbols {
+		top := chk >> 35
+		chk = (chk&0x7ffffffff)<<5 ^ v
+		for i, g := range descriptorGenerator {
+			if (top>>i)&1 == 1 {
+				chk ^= g
+			}
+		}
+	}
+	chk ^= 1
+
+	checksum := make([]byte, 8)
+	for i := range checksum {
+		checksum[i] = descriptorChecksumCharset[(chk>>(5*(7-i)))&31]
+	}
+	return string(checksum), nil
+}
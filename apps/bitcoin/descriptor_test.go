@@ -0,0 +1,90 @@
+package bitcoin
+
+import (
+	"encoding/hex"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDescriptorChecksum(t *testing.T) {
+	require := require.New(t)
+
+	checksum, err := DescriptorChecksum("raw(deadbeef)")
+	require.Nil(err)
+	require.Equal("89f8spxm", checksum)
+	checksum, err = DescriptorChecksum("pkh([d34db33f/44'/0'/0']xpub6ERApfZwUNrhLCkDtcHTcxd75RbzS1ed54G1LkBUHQVHQKqhMkhgbmJbZRkrgZw4koxb5JaHWkY4ALHY2grBGRjaDMzQLcgJvLJuZZvRcEL/1/*)")
+	require.Nil(err)
+	require.Equal("ml40v0wf", checksum)
+	_, err = DescriptorChecksum("raw(deadbeef)\n")
+	require.NotNil(err)
+}
+
+func TestSafeDescriptor(t *testing.T) {
+	require := require.New(t)
+
+	// the keys registered on the ledger in TestLedgerBitcoin
+	holderKey := "[9c03cfaf/44'/0'/0']xpub6Ci9Nfuo4VkA6gzSdKQK6XYztQ65B52gyTQ4ShB7unGB8tfAqVLS6Nw3v62onLJXEzp8H2UarXRt7nBnAFX7FpwjaSBgo2M7AWp81y4eg1w"
+	signerChainCode, _ := hex.DecodeString("f555b08a9871213c0d52fee12e1bd365990b956880491b2b1a106f84584aa3a2")
+	observerChainCode, _ := hex.DecodeString("f20e9476f2395c49ed80cc62fe6022a8d83121cb48ee01fdfaa77b31b01c031d")
+
+	hk, err := ParseDescriptorKey(holderKey)
+	require.Nil(err)
+	require.Equal(holderKey, hk.String())
+	sk, err := NewMasterDescriptorKey("02bf0a7fa4b7905a0de5ab60a5322529e1a591ddd1ee53df82e751e8adb4bed08c", signerChainCode)
+	require.Nil(err)
+	require.Equal("[61315bdf]xpub661MyMwAqRbcGz6ujRJnzrBvWrkz2NdNzYc3ZGBMVPmPBTHomqTiX5RrcTZVYZR2jM75oBU1UFssyMFqHV6GDsreibF2tPMbCcSPnTfqwhM", sk.String())
+	ok, err := NewMasterDescriptorKey("02442850035ee4d6f322e58e6e0ddc25b81d5fa3ca693000f992bb198c967d4a5b", observerChainCode)
+	require.Nil(err)
+	require.Equal("[03d4ea2b]xpub661MyMwAqRbcGxD8XPvZ3fQy7WqAJBvdrEH1kwg1SGDAQprmpGvHsz5rVytTrfFmLTzRiSAMo43R7DhzjR3ucQH5UxBvFB9YDUZVQFiyDKG", ok.String())
+
+	for i, address := range []string{
+		"bc1qrgks3frgprw92rkey7yqs5ge57jddep52es6yn54mudl3kfwvxpsa4r46k",
+		"bc1qdj9sa2fa49rw77s468zfzsrmpdh805m98ctyypydnnt0ppxy802qaxd028",
+	} {
+		path := []uint32{0, uint32(i)}
+		holder, err := hk.Derive(path)
+		require.Nil(err)
+		signer, err := sk.Derive(path)
+		require.Nil(err)
+		observer, err := ok.Derive(path)
+		require.Nil(err)
+		wsa, err := BuildWitnessScriptAccount(holder, signer, observer, time.Hour*24*3, ChainBitcoin)
+		require.Nil(err)
+		require.Equal(address, wsa.Address)
+
+		sd, err := BuildSafeDescriptor(wsa.Script, []*DescriptorKey{sk, ok, hk}, path)
+		require.Nil(err)
+		desc := fmt.Sprintf("wsh(thresh(2,pk(%s/0/%d),s:pk(%s/0/%d),sj:and_v(v:pk(%s/0/%d),n:older(432))))", holderKey, i, sk, i, ok, i)
+		checksum, _ := DescriptorChecksum(desc)
+		require.Equal(desc+"#"+checksum, sd.Descriptor)
+		require.NotNil(sd.Policy)
+		require.Equal("wsh(thresh(2,pk(@0/**),s:pk(@1/**),sj:and_v(v:pk(@2/**),n:older(432))))", sd.Policy.Template)
+		require.Equal([]string{holderKey, sk.String(), ok.String()}, sd.Policy.Keys)
+		require.Equal(uint32(i), sd.Policy.Index)
+
+		sd, err = BuildSafeDescriptor(wsa.Script, []*DescriptorKey{sk, ok}, path)
+		require.Nil(err)
+		desc = fmt.Sprintf("wsh(thresh(2,pk(%s),s:pk(%s/0/%d),sj:and_v(v:pk(%s/0/%d),n:older(432))))", holder, sk, i, ok, i)
+		checksum, _ = DescriptorChecksum(desc)
+		require.Equal(desc+"#"+checksum, sd.Descriptor)
+		require.Nil(sd.Policy)
+
+		_, err = BuildSafeDescriptor(wsa.Script, []*DescriptorKey{sk, ok, hk}, []uint32{0, 7})
+		require.NotNil(err)
+		_, err = BuildSafeDescriptor(wsa.Script, []*DescriptorKey{sk, sk}, path)
+		require.NotNil(err)
+	}
+
+	for _, k := range []string{
+		"[9c03cfa/44'/0'/0']xpub6Ci9Nfuo4VkA6gzSdKQK6XYztQ65B52gyTQ4ShB7unGB8tfAqVLS6Nw3v62onLJXEzp8H2UarXRt7nBnAFX7FpwjaSBgo2M7AWp81y4eg1w",
+		"[9c03cfaf/44'/x/0']xpub6Ci9Nfuo4VkA6gzSdKQK6XYztQ65B52gyTQ4ShB7unGB8tfAqVLS6Nw3v62onLJXEzp8H2UarXRt7nBnAFX7FpwjaSBgo2M7AWp81y4eg1w",
+		"[9c03cfaf/44'/0'/0'xpub6Ci9Nfuo4VkA6gzSdKQK6XYztQ65B52gyTQ4ShB7unGB8tfAqVLS6Nw3v62onLJXEzp8H2UarXRt7nBnAFX7FpwjaSBgo2M7AWp81y4eg1w",
+		"02bf0a7fa4b7905a0de5ab60a5322529e1a591ddd1ee53df82e751e8adb4bed08c",
+	} {
+		_, err := ParseDescriptorKey(k)
+		require.NotNil(err)
+	}
+}
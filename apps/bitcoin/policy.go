@@ -0,0 +1,719 @@
+package bitcoin
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+const (
+	HolderPolicyExtraFlag = 0x00
+	HolderPolicyMaximum   = 15
+
+	multisigKeysMaximum = 20
+)
+
+// the holder policy replaces the single holder key with a m-of-n holders
+// group, the group is still paired with the signer, and the observer with
+// the time lock is kept as the recovery path
+type HolderPolicy struct {
+	Threshold byte
+	Holders   []string
+}
+
+func (hp *HolderPolicy) Marshal() []byte {
+	extra := []byte{HolderPolicyExtraFlag, hp.Threshold, byte(len(hp.Holders))}
+	for _, h := range hp.Holders {
+		pub, err := hex.DecodeString(h)
+		if err != nil {
+			panic(h)
+		}
+		extra = append(extra, pub...)
+	}
+	return extra
+}
+
+func UnmarshalHolderPolicy(extra []byte) (*HolderPolicy, error) {
+	if len(extra) < 3 || extra[0] != HolderPolicyExtraFlag {
+		return nil, fmt.Errorf("invalid holder policy %x", extra)
+	}
+	hp := &HolderPolicy{Threshold: extra[1]}
+	total := int(extra[2])
+	if len(extra) != 3+total*33 {
+		return nil, fmt.Errorf("invalid holder policy %x", extra)
+	}
+	for i := 0; i < total; i++ {
+		pub := extra[3+i*33 : 3+(i+1)*33]
+		hp.Holders = append(hp.Holders, hex.EncodeToString(pub))
+	}
+	return hp, hp.verify()
+}
+
+func (hp *HolderPolicy) Contains(holder string) bool {
+	return slices.Contains(hp.Holders, holder)
+}
+
+func (hp *HolderPolicy) verify() error {
+	total := len(hp.Holders)
+	if total < 2 || total > HolderPolicyMaximum {
+		return fmt.Errorf("invalid holder policy size %d", total)
+	}
+	if hp.Threshold < 1 || int(hp.Threshold) > total {
+		return fmt.Errorf("invalid holder policy threshold %d/%d", hp.Threshold, total)
+	}
+	for i, h := range hp.Holders {
+		err := VerifyHolderKey(h)
+		if err != nil {
+			return fmt.Errorf("invalid holder policy key %s %v", h, err)
+		}
+		if slices.Contains(hp.Holders[i+1:], h) {
+			return fmt.Errorf("duplicate holder policy key %s", h)
+		}
+	}
+	return nil
+}
+
+func (hp *HolderPolicy) policy() string {
+	keys := make([]string, len(hp.Holders))
+	for i, h := range hp.Holders {
+		keys[i] = fmt.Sprintf("pk(%s)", h)
+	}
+	return fmt.Sprintf("thresh(%d,%s)", hp.Threshold, strings.Join(keys, ","))
+}
+
+// thresh(2,thresh(M,pk(H1),...,pk(HN)),pk(SIGNER),and(pk(OBSERVER),older(12960)))
+// thresh(2,multi(M,H1,...,HN),s:pk(SIGNER),sj:and_v(v:pk(OBSERVER),n:older(12960)))
+func BuildPolicyScriptAccount(hp *HolderPolicy, signer, observer string, lock time.Duration, chain byte) (*WitnessScriptAccount, error) {
+	err := hp.verify()
+	if err != nil {
+		return nil, err
+	}
+	for _, public := range []string{signer, observer} {
+		_, err := parseBitcoinCompressedPublicKey(public)
+		if err != nil {
+			return nil, fmt.Errorf("parseBitcoinCompressedPublicKey(%s) => %v", public, err)
+		}
+	}
+	sequence, err := ParseSequence(lock, chain)
+	if err != nil {
+		return nil, err
+	}
+
+	policy := fmt.Sprintf("thresh(2,%s,pk(%s),and(pk(%s),older(%d)))", hp.policy(), signer, observer, sequence)
+	script, _, err := CompilePolicy(policy)
+	if err != nil {
+		return nil, fmt.Errorf("CompilePolicy(%s) => %v", policy, err)
+	}
+	addr, err := scriptHashAddress(script, chain)
+	if err != nil {
+		return nil, fmt.Errorf("scriptHashAddress(%x) => %v", script, err)
+	}
+
+	return &WitnessScriptAccount{
+		Sequence: uint32(sequence),
+		Script:   script,
+		Address:  addr,
+	}, nil
+}
+
+// the safe script with a single holder key has no holder policy, and a nil
+// policy is returned for it
+func ParseHolderPolicy(script []byte) (*HolderPolicy, error) {
+	if checkScriptType(script) != InputTypeP2WSHMultisigHolderSigner {
+		return nil, nil
+	}
+	ms, err := parseSafeMiniscript(script)
+	if err != nil {
+		return nil, err
+	}
+	holders := ms.subs[0]
+	switch holders.fragment {
+	case "pk":
+		return nil, nil
+	case "multi":
+		hp := &HolderPolicy{Threshold: byte(holders.k)}
+		for _, k := range holders.keys {
+			hp.Holders = append(hp.Holders, hex.EncodeToString(k))
+		}
+		return hp, hp.verify()
+	default:
+		return nil, fmt.Errorf("invalid holder policy %s", ms.String())
+	}
+}
+
+// compiles the policy of pk, older, and, thresh to the miniscript, and
+// returns the witness script and the miniscript
+func CompilePolicy(policy string) ([]byte, string, error) {
+	p, err := parsePolicy(policy)
+	if err != nil {
+		return nil, "", err
+	}
+	ms, err := p.compile()
+	if err != nil {
+		return nil, "", err
+	}
+	script, err := ms.script()
+	if err != nil {
+		return nil, "", err
+	}
+	return script, ms.String(), nil
+}
+
+type policyNode struct {
+	name string
+	key  []byte
+	k    int64
+	subs []*policyNode
+}
+
+func parsePolicy(policy string) (*policyNode, error) {
+	policy = strings.TrimSpace(policy)
+	open := strings.IndexByte(policy, '(')
+	if open <= 0 || !strings.HasSuffix(policy, ")") {
+		return nil, fmt.Errorf("invalid policy %s", policy)
+	}
+	name, args := policy[:open], splitPolicyArgs(policy[open+1:len(policy)-1])
+	if args == nil {
+		return nil, fmt.Errorf("invalid policy %s", policy)
+	}
+
+	p := &policyNode{name: name}
+	switch name {
+	case "pk":
+		if len(args) != 1 {
+			return nil, fmt.Errorf("invalid policy %s", policy)
+		}
+		pub, err := parseBitcoinCompressedPublicKey(args[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid policy key %s %v", args[0], err)
+		}
+		p.key = pub.ScriptAddress()
+		return p, nil
+	case "older":
+		if len(args) != 1 {
+			return nil, fmt.Errorf("invalid policy %s", policy)
+		}
+		n, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil || n < 1 || n >= wire.SequenceLockTimeDisabled {
+			return nil, fmt.Errorf("invalid policy older %s", args[0])
+		}
+		p.k = n
+		return p, nil
+	case "and":
+		if len(args) != 2 {
+			return nil, fmt.Errorf("invalid policy %s", policy)
+		}
+	case "thresh":
+		if len(args) < 2 {
+			return nil, fmt.Errorf("invalid policy %s", policy)
+		}
+		k, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil || k < 1 || int(k) > len(args)-1 {
+			return nil, fmt.Errorf("invalid policy threshold %s", args[0])
+		}
+		p.k, args = k, args[1:]
+	default:
+		return nil, fmt.Errorf("unsupported policy %s", name)
+	}
+	for _, arg := range args {
+		sub, err := parsePolicy(arg)
+		if err != nil {
+			return nil, err
+		}
+		p.subs = append(p.subs, sub)
+	}
+	return p, nil
+}
+
+func splitPolicyArgs(s string) []string {
+	var args []string
+	var depth, start int
+	for i, c := range s {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth < 0 {
+				return nil
+			}
+		case ',':
+			if depth == 0 {
+				args = append(args, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	if depth != 0 {
+		return nil
+	}
+	return append(args, s[start:])
+}
+
+func (p *policyNode) compile() (*miniscript, error) {
+	switch p.name {
+	case "pk":
+		return &miniscript{fragment: "pk", keys: [][]byte{p.key}}, nil
+	case "older":
+		return &miniscript{fragment: "older", k: p.k}, nil
+	case "and":
+		x, err := p.subs[0].compile()
+		if err != nil {
+			return nil, err
+		}
+		if x.fragment != "pk" {
+			return nil, fmt.Errorf("unsupported policy and(%s)", x.String())
+		}
+		y, err := p.subs[1].compile()
+		if err != nil {
+			return nil, err
+		}
+		return &miniscript{fragment: "and_v", subs: []*miniscript{wrapMiniscript("v", x), y}}, nil
+	}
+
+	var keys [][]byte
+	for _, sub := range p.subs {
+		if sub.name == "pk" {
+			keys = append(keys, sub.key)
+		}
+	}
+	if len(keys) == len(p.subs) && len(keys) <= multisigKeysMaximum {
+		if len(keys) == 1 {
+			return &miniscript{fragment: "pk", keys: keys}, nil
+		}
+		return &miniscript{fragment: "multi", k: p.k, keys: keys}, nil
+	}
+
+	ms := &miniscript{fragment: "thresh", k: p.k}
+	for i, sub := range p.subs {
+		x, err := sub.compile()
+		if err != nil {
+			return nil, err
+		}
+		x = x.unit()
+		switch {
+		case i == 0 && x.fragment == "and_v":
+			x = wrapMiniscript("j", x)
+		case i == 0:
+		case x.fragment == "pk":
+			x = wrapMiniscript("s", x)
+		case x.fragment == "and_v":
+			x = wrapMiniscript("s", wrapMiniscript("j", x))
+		default:
+			x = wrapMiniscript("a", x)
+		}
+		ms.subs = append(ms.subs, x)
+	}
+	return ms, nil
+}
+
+type miniscript struct {
+	fragment string
+	k        int64
+	keys     [][]byte
+	subs     []*miniscript
+}
+
+func wrapMiniscript(wrapper string, sub *miniscript) *miniscript {
+	return &miniscript{fragment: wrapper, subs: []*miniscript{sub}}
+}
+
+func (ms *miniscript) isWrapper() bool {
+	return len(ms.fragment) == 1
+}
+
+// the thresh adds up the results of all subs, so each of them must leave
+// exactly 0 or 1 on the stack
+func (ms *miniscript) unit() *miniscript {
+	switch ms.fragment {
+	case "older":
+		return wrapMiniscript("n", ms)
+	case "and_v":
+		return &miniscript{fragment: "and_v", subs: []*miniscript{ms.subs[0], ms.subs[1].unit()}}
+	default:
+		return ms
+	}
+}
+
+func (ms *miniscript) String() string {
+	return ms.format(hex.EncodeToString)
+}
+
+func (ms *miniscript) format(key func([]byte) string) string {
+	switch ms.fragment {
+	case "pk":
+		return fmt.Sprintf("pk(%s)", key(ms.keys[0]))
+	case "older":
+		return fmt.Sprintf("older(%d)", ms.k)
+	case "multi":
+		keys := make([]string, len(ms.keys))
+		for i, k := range ms.keys {
+			keys[i] = key(k)
+		}
+		return fmt.Sprintf("multi(%d,%s)", ms.k, strings.Join(keys, ","))
+	case "and_v":
+		return fmt.Sprintf("and_v(%s,%s)", ms.subs[0].format(key), ms.subs[1].format(key))
+	case "thresh":
+		subs := make([]string, len(ms.subs))
+		for i, sub := range ms.subs {
+			subs[i] = sub.format(key)
+		}
+		return fmt.Sprintf("thresh(%d,%s)", ms.k, strings.Join(subs, ","))
+	}
+	wrappers, sub := ms.fragment, ms.subs[0]
+	for sub.isWrapper() {
+		wrappers, sub = wrappers+sub.fragment, sub.subs[0]
+	}
+	return wrappers + ":" + sub.format(key)
+}
+
+func (ms *miniscript) script() ([]byte, error) {
+	builder := txscript.NewScriptBuilder()
+	ms.encode(builder)
+	return builder.Script()
+}
+
+func (ms *miniscript) encode(builder *txscript.ScriptBuilder) {
+	switch ms.fragment {
+	case "pk":
+		builder.AddData(ms.keys[0])
+		builder.AddOp(txscript.OP_CHECKSIG)
+	case "older":
+		builder.AddInt64(ms.k)
+		builder.AddOp(txscript.OP_CHECKSEQUENCEVERIFY)
+	case "multi":
+		builder.AddInt64(ms.k)
+		for _, k := range ms.keys {
+			builder.AddData(k)
+		}
+		builder.AddInt64(int64(len(ms.keys)))
+		builder.AddOp(txscript.OP_CHECKMULTISIG)
+	case "and_v":
+		ms.subs[0].encode(builder)
+		ms.subs[1].encode(builder)
+	case "thresh":
+		ms.subs[0].encode(builder)
+		for _, sub := range ms.subs[1:] {
+			sub.encode(builder)
+			builder.AddOp(txscript.OP_ADD)
+		}
+		builder.AddInt64(ms.k)
+		builder.AddOp(txscript.OP_EQUAL)
+	case "v":
+		if ms.subs[0].fragment != "pk" {
+			panic(ms.String())
+		}
+		builder.AddData(ms.subs[0].keys[0])
+		builder.AddOp(txscript.OP_CHECKSIGVERIFY)
+	case "s":
+		builder.AddOp(txscript.OP_SWAP)
+		ms.subs[0].encode(builder)
+	case "a":
+		builder.AddOp(txscript.OP_TOALTSTACK)
+		ms.subs[0].encode(builder)
+		builder.AddOp(txscript.OP_FROMALTSTACK)
+	case "j":
+		builder.AddOp(txscript.OP_SIZE)
+		builder.AddOp(txscript.OP_0NOTEQUAL)
+		builder.AddOp(txscript.OP_IF)
+		ms.subs[0].encode(builder)
+		builder.AddOp(txscript.OP_ENDIF)
+	case "n":
+		ms.subs[0].encode(builder)
+		builder.AddOp(txscript.OP_0NOTEQUAL)
+	default:
+		panic(ms.fragment)
+	}
+}
+
+// the witness stack of the satisfaction is ordered from bottom to top, and
+// the thresh satisfies its first k satisfiable subs
+func (ms *miniscript) satisfy(sigs map[string][]byte, sequence uint32) ([][]byte, bool) {
+	switch ms.fragment {
+	case "pk":
+		sig := sigs[hex.EncodeToString(ms.keys[0])]
+		return [][]byte{sig}, sig != nil
+	case "older":
+		return [][]byte{}, checkSequenceLock(sequence, ms.k)
+	case "multi":
+		stack := [][]byte{{}}
+		for _, k := range ms.keys {
+			sig := sigs[hex.EncodeToString(k)]
+			if sig != nil && len(stack) <= int(ms.k) {
+				stack = append(stack, sig)
+			}
+		}
+		return stack, len(stack) == int(ms.k)+1
+	case "and_v":
+		y, ok := ms.subs[1].satisfy(sigs, sequence)
+		if !ok {
+			return nil, false
+		}
+		x, ok := ms.subs[0].satisfy(sigs, sequence)
+		return append(y, x...), ok
+	case "thresh":
+		var count int64
+		parts := make([][][]byte, len(ms.subs))
+		for i, sub := range ms.subs {
+			if count < ms.k {
+				w, ok := sub.satisfy(sigs, sequence)
+				if ok {
+					parts[i], count = w, count+1
+					continue
+				}
+			}
+			w, ok := sub.dissatisfy()
+			if !ok {
+				return nil, false
+			}
+			parts[i] = w
+		}
+		var stack [][]byte
+		for i := len(parts) - 1; i >= 0; i-- {
+			stack = append(stack, parts[i]...)
+		}
+		return stack, count == ms.k
+	default:
+		return ms.subs[0].satisfy(sigs, sequence)
+	}
+}
+
+func (ms *miniscript) dissatisfy() ([][]byte, bool) {
+	switch ms.fragment {
+	case "pk", "j":
+		return [][]byte{{}}, true
+	case "multi":
+		return make([][]byte, ms.k+1), true
+	case "thresh":
+		var stack [][]byte
+		for i := len(ms.subs) - 1; i >= 0; i-- {
+			w, ok := ms.subs[i].dissatisfy()
+			if !ok {
+				return nil, false
+			}
+			stack = append(stack, w...)
+		}
+		return stack, true
+	case "s", "a", "n":
+		return ms.subs[0].dissatisfy()
+	default:
+		return nil, false
+	}
+}
+
+func checkSequenceLock(sequence uint32, lock int64) bool {
+	if sequence&wire.SequenceLockTimeDisabled != 0 {
+		return false
+	}
+	mask := uint32(wire.SequenceLockTimeIsSeconds | wire.SequenceLockTimeMask)
+	seq, req := sequence&mask, uint32(lock)&mask
+	if seq&wire.SequenceLockTimeIsSeconds != req&wire.SequenceLockTimeIsSeconds {
+		return false
+	}
+	return seq >= req
+}
+
+// the safe miniscript is always the thresh of the holders, the signer and
+// the observer with the time lock
+func parseSafeMiniscript(script []byte) (*miniscript, error) {
+	ms, err := parseMiniscript(script)
+	if err != nil {
+		return nil, err
+	}
+	if ms.fragment != "thresh" || ms.k != 2 || len(ms.subs) != 3 {
+		return nil, fmt.Errorf("invalid safe miniscript %s", ms.String())
+	}
+	return ms, nil
+}
+
+type scriptToken struct {
+	op   byte
+	data []byte
+}
+
+type miniscriptParser struct {
+	tokens []scriptToken
+	pos    int
+}
+
+// parses the script compiled from the supported policies, the parsed
+// miniscript must encode to the exact same script
+func parseMiniscript(script []byte) (*miniscript, error) {
+	p := &miniscriptParser{}
+	tokenizer := txscript.MakeScriptTokenizer(0, script)
+	for tokenizer.Next() {
+		p.tokens = append(p.tokens, scriptToken{tokenizer.Opcode(), tokenizer.Data()})
+	}
+	if err := tokenizer.Err(); err != nil {
+		return nil, err
+	}
+	ms, err := p.parseB()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos != len(p.tokens) {
+		return nil, fmt.Errorf("invalid miniscript %x", script)
+	}
+	encoded, err := ms.script()
+	if err != nil || !bytes.Equal(encoded, script) {
+		return nil, fmt.Errorf("invalid miniscript %x %s", script, ms.String())
+	}
+	return ms, nil
+}
+
+func (p *miniscriptParser) parseB() (*miniscript, error) {
+	x, err := p.parseAtom()
+	if err != nil || !p.peek(txscript.OP_SWAP, txscript.OP_TOALTSTACK) {
+		return x, err
+	}
+	ms := &miniscript{fragment: "thresh", subs: []*miniscript{x}}
+	for p.peek(txscript.OP_SWAP, txscript.OP_TOALTSTACK) {
+		w, err := p.parseW()
+		if err != nil {
+			return nil, err
+		}
+		if !p.expect(txscript.OP_ADD) {
+			return nil, fmt.Errorf("invalid miniscript thresh at %d", p.pos)
+		}
+		ms.subs = append(ms.subs, w)
+	}
+	k, ok := p.number()
+	if !ok || !p.expect(txscript.OP_EQUAL) {
+		return nil, fmt.Errorf("invalid miniscript thresh at %d", p.pos)
+	}
+	ms.k = k
+	return ms, nil
+}
+
+func (p *miniscriptParser) parseW() (*miniscript, error) {
+	if p.expect(txscript.OP_SWAP) {
+		x, err := p.parseAtom()
+		if err != nil {
+			return nil, err
+		}
+		return wrapMiniscript("s", x), nil
+	}
+	if p.expect(txscript.OP_TOALTSTACK) {
+		x, err := p.parseB()
+		if err != nil {
+			return nil, err
+		}
+		if !p.expect(txscript.OP_FROMALTSTACK) {
+			return nil, fmt.Errorf("invalid miniscript a: at %d", p.pos)
+		}
+		return wrapMiniscript("a", x), nil
+	}
+	return nil, fmt.Errorf("invalid miniscript w at %d", p.pos)
+}
+
+func (p *miniscriptParser) parseAtom() (*miniscript, error) {
+	if key, ok := p.key(); ok {
+		pk := &miniscript{fragment: "pk", keys: [][]byte{key}}
+		if p.expect(txscript.OP_CHECKSIG) {
+			return pk, nil
+		}
+		if !p.expect(txscript.OP_CHECKSIGVERIFY) {
+			return nil, fmt.Errorf("invalid miniscript pk at %d", p.pos)
+		}
+		y, err := p.parseB()
+		if err != nil {
+			return nil, err
+		}
+		return &miniscript{fragment: "and_v", subs: []*miniscript{wrapMiniscript("v", pk), y}}, nil
+	}
+
+	if p.expect(txscript.OP_SIZE) {
+		if !p.expect(txscript.OP_0NOTEQUAL) || !p.expect(txscript.OP_IF) {
+			return nil, fmt.Errorf("invalid miniscript j: at %d", p.pos)
+		}
+		x, err := p.parseB()
+		if err != nil {
+			return nil, err
+		}
+		if !p.expect(txscript.OP_ENDIF) {
+			return nil, fmt.Errorf("invalid miniscript j: at %d", p.pos)
+		}
+		return wrapMiniscript("j", x), nil
+	}
+
+	k, ok := p.number()
+	if !ok {
+		return nil, fmt.Errorf("invalid miniscript at %d", p.pos)
+	}
+	if p.expect(txscript.OP_CHECKSEQUENCEVERIFY) {
+		older := &miniscript{fragment: "older", k: k}
+		if p.expect(txscript.OP_0NOTEQUAL) {
+			return wrapMiniscript("n", older), nil
+		}
+		return older, nil
+	}
+	ms := &miniscript{fragment: "multi", k: k}
+	for {
+		key, ok := p.key()
+		if !ok {
+			break
+		}
+		ms.keys = append(ms.keys, key)
+	}
+	n, ok := p.number()
+	if !ok || int(n) != len(ms.keys) || !p.expect(txscript.OP_CHECKMULTISIG) {
+		return nil, fmt.Errorf("invalid miniscript multi at %d", p.pos)
+	}
+	return ms, nil
+}
+
+func (p *miniscriptParser) peek(ops ...byte) bool {
+	return p.pos < len(p.tokens) && slices.Contains(ops, p.tokens[p.pos].op)
+}
+
+func (p *miniscriptParser) expect(op byte) bool {
+	if !p.peek(op) {
+		return false
+	}
+	p.pos++
+	return true
+}
+
+func (p *miniscriptParser) key() ([]byte, bool) {
+	if !p.peek(txscript.OP_DATA_33) {
+		return nil, false
+	}
+	p.pos++
+	return p.tokens[p.pos-1].data, true
+}
+
+func (p *miniscriptParser) number() (int64, bool) {
+	if p.pos >= len(p.tokens) {
+		return 0, false
+	}
+	t := p.tokens[p.pos]
+	switch {
+	case t.op == txscript.OP_0:
+		p.pos++
+		return 0, true
+	case t.op >= txscript.OP_1 && t.op <= txscript.OP_16:
+		p.pos++
+		return int64(t.op-txscript.OP_1) + 1, true
+	case t.op >= txscript.OP_DATA_1 && t.op <= txscript.OP_DATA_5:
+		var n int64
+		for i, b := range t.data {
+			n |= int64(b) << (8 * i)
+		}
+		last := t.data[len(t.data)-1]
+		if last&0x80 != 0 {
+			n &= ^(int64(0x80) << (8 * (len(t.data) - 1)))
+			n = -n
+		}
+		p.pos++
+		return n, true
+	default:
+		return 0, false
+	}
+}
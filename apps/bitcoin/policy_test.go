@@ -0,0 +1,166 @@
+package bitcoin
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicyCompiler(t *testing.T) {
+	require := require.New(t)
+
+	holder, _ := testPolicyKey("holder")
+	signer, _ := testPolicyKey("signer")
+	observer, _ := testPolicyKey("observer")
+	wsa, err := BuildWitnessScriptAccount(holder, signer, observer, time.Hour*24*90, ChainBitcoin)
+	require.Nil(err)
+
+	policy := fmt.Sprintf("thresh(2,pk(%s),pk(%s),and(pk(%s),older(%d)))", holder, signer, observer, wsa.Sequence)
+	script, ms, err := CompilePolicy(policy)
+	require.Nil(err)
+	require.Equal(wsa.Script, script)
+	require.Equal(fmt.Sprintf("thresh(2,pk(%s),s:pk(%s),sj:and_v(v:pk(%s),n:older(%d)))", holder, signer, observer, wsa.Sequence), ms)
+	parsed, err := parseSafeMiniscript(script)
+	require.Nil(err)
+	require.Equal(ms, parsed.String())
+	hp, err := ParseHolderPolicy(script)
+	require.Nil(err)
+	require.Nil(hp)
+
+	policy = fmt.Sprintf("thresh(2,pk(%s),thresh(1,pk(%s),and(pk(%s),older(144))))", signer, holder, observer)
+	script, ms, err = CompilePolicy(policy)
+	require.Nil(err)
+	require.Equal(fmt.Sprintf("thresh(2,pk(%s),a:thresh(1,pk(%s),sj:and_v(v:pk(%s),n:older(144))))", signer, holder, observer), ms)
+	parsed, err = parseMiniscript(script)
+	require.Nil(err)
+	require.Equal(ms, parsed.String())
+
+	for _, p := range []string{
+		"pk(00)",
+		"older(0)",
+		"thresh(3,pk(%s),pk(%s))",
+		"and(older(1),pk(%s))",
+		"or(pk(%s),pk(%s))",
+		"thresh(1,pk(%s)",
+	} {
+		_, _, err = CompilePolicy(fmt.Sprintf(p, holder, signer))
+		require.NotNil(err)
+	}
+}
+
+func TestPolicyAccount(t *testing.T) {
+	require := require.New(t)
+
+	var holders []string
+	var hps []*btcec.PrivateKey
+	for i := 0; i < 3; i++ {
+		pub, priv := testPolicyKey(fmt.Sprintf("officer-%d", i))
+		holders, hps = append(holders, pub), append(hps, priv)
+	}
+	signer, sp := testPolicyKey("signer")
+	observer, op := testPolicyKey("observer")
+
+	hp := &HolderPolicy{Threshold: 2, Holders: holders}
+	decoded, err := UnmarshalHolderPolicy(hp.Marshal())
+	require.Nil(err)
+	require.Equal(hp, decoded)
+	_, err = UnmarshalHolderPolicy((&HolderPolicy{Threshold: 3, Holders: holders[:2]}).Marshal())
+	require.NotNil(err)
+	_, err = UnmarshalHolderPolicy((&HolderPolicy{Threshold: 1, Holders: []string{signer, signer}}).Marshal())
+	require.NotNil(err)
+
+	wsa, err := BuildPolicyScriptAccount(hp, signer, observer, time.Hour*24*7, ChainBitcoin)
+	require.Nil(err)
+	require.True(CheckMultisigHolderSignerScript(wsa.Script))
+	addr, err := EncodeAddress(wsa.Script, ChainBitcoin)
+	require.Nil(err)
+	require.Equal(wsa.Address, addr)
+	parsed, err := ParseHolderPolicy(wsa.Script)
+	require.Nil(err)
+	require.Equal(hp, parsed)
+
+	receiver := "bc1q2nhm0clwt7qcmnpntetjlzf0tflp2h0zvkczql4v9nmydnt7xm6swx2nnv"
+	for _, c := range []struct {
+		recovery  bool
+		keys      []*btcec.PrivateKey
+		satisfied bool
+		holders   bool
+	}{
+		{false, []*btcec.PrivateKey{hps[0], hps[1], sp}, true, true},
+		{false, []*btcec.PrivateKey{hps[2], sp, hps[0]}, true, true},
+		{false, []*btcec.PrivateKey{hps[1], hps[2], sp, hps[0]}, true, true},
+		{false, []*btcec.PrivateKey{hps[1], sp}, false, false},
+		{false, []*btcec.PrivateKey{hps[1], hps[2], op}, false, true},
+		{true, []*btcec.PrivateKey{op, sp}, true, false},
+		{true, []*btcec.PrivateKey{hps[0], op, hps[2]}, true, true},
+		{true, []*btcec.PrivateKey{hps[0], op}, false, false},
+	} {
+		inputs := []*Input{{
+			TransactionHash: "6daf0a2ca612879093698c5ab6dbcff372e893137d5dfda23615e1489f5e0721",
+			Index:           1,
+			Satoshi:         100000,
+			Script:          wsa.Script,
+			Sequence:        wsa.Sequence,
+			RouteBackup:     c.recovery,
+		}}
+		outputs := []*Output{{Address: receiver, Satoshi: 100000}}
+		psbt, err := BuildPartiallySignedTransaction(inputs, outputs, nil, ChainBitcoin)
+		require.Nil(err)
+		for _, k := range c.keys {
+			psbt = SignPartiallySignedTransaction(psbt.Marshal(), k)
+		}
+		require.Equal(c.holders, CheckTransactionSignedByHolders(hex.EncodeToString(psbt.Marshal())))
+		require.Equal(c.satisfied, psbt.IsInputSatisfied(0))
+
+		msgTx, err := psbt.SignedTransaction(holders[0], signer, observer)
+		if !c.satisfied {
+			require.NotNil(err)
+			continue
+		}
+		require.Nil(err)
+		pin := psbt.Inputs[0]
+		pof := txscript.NewCannedPrevOutputFetcher(pin.WitnessUtxo.PkScript, pin.WitnessUtxo.Value)
+		engine, err := txscript.NewEngine(pin.WitnessUtxo.PkScript, msgTx, 0, txscript.StandardVerifyFlags, nil, txscript.NewTxSigHashes(msgTx, pof), pin.WitnessUtxo.Value, pof)
+		require.Nil(err)
+		require.Nil(engine.Execute())
+	}
+}
+
+func TestPolicySequenceLock(t *testing.T) {
+	require := require.New(t)
+
+	blocks, err := ParseSequence(time.Hour*24, ChainBitcoin)
+	require.Nil(err)
+	require.Equal(int64(144), blocks)
+	require.True(checkSequenceLock(uint32(blocks), blocks))
+	require.True(checkSequenceLock(uint32(blocks)+1, blocks))
+	require.False(checkSequenceLock(uint32(blocks)-1, blocks))
+	require.False(checkSequenceLock(MaxTransactionSequence, blocks))
+	require.False(checkSequenceLock(uint32(blocks)|wire.SequenceLockTimeIsSeconds, blocks))
+
+	blocks, err = ParseSequence(TimeLockMaximum, ChainBitcoin)
+	require.Nil(err)
+	require.Equal(int64(52560), blocks)
+	require.Nil(VerifyTimeLock(TimeLockMaximum, ChainBitcoin))
+	blocks, err = ParseSequence(TimeLockMaximum, ChainLitecoin)
+	require.Nil(err)
+	require.Equal(int64(0xffff), blocks)
+	require.NotNil(VerifyTimeLock(TimeLockMaximum, ChainLitecoin))
+	require.Nil(VerifyTimeLock(time.Hour*24*113, ChainLitecoin))
+	require.NotNil(VerifyTimeLock(time.Hour*24*114, ChainLitecoin))
+	_, err = ParseSequence(TimeLockMaximum+time.Hour, ChainBitcoin)
+	require.NotNil(err)
+}
+
+func testPolicyKey(seed string) (string, *btcec.PrivateKey) {
+	b := sha256.Sum256([]byte(seed))
+	priv, pub := btcec.PrivKeyFromBytes(b[:])
+	return hex.EncodeToString(pub.SerializeCompressed()), priv
+}
@@ -0,0 +1,175 @@
+package bitcoin
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/txscript"
+)
+
+// the safe transactions are passed around as the hex of the psbt, but the
+// external wallets only accept the base64 of BIP-174, so both are accepted
+func ParsePartiallySignedTransaction(s string) (*PartiallySignedTransaction, error) {
+	s = strings.TrimSpace(s)
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		b, err = base64.StdEncoding.DecodeString(s)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid psbt encoding %s", s)
+	}
+	return UnmarshalPartiallySignedTransaction(b)
+}
+
+// the partial signatures are stored without the sighash type, and they are
+// appended with the sighash type as BIP-174 requires, the keys with origins
+// are filled to the bip32 derivations of all the safe inputs and outputs
+func (raw *PartiallySignedTransaction) MarshalBIP174(keys []*DescriptorKey, path []uint32) (string, error) {
+	pkt, err := UnmarshalPartiallySignedTransaction(raw.Marshal())
+	if err != nil {
+		return "", err
+	}
+	derivations := make(map[string]*psbt.Bip32Derivation)
+	for _, dk := range keys {
+		pub, err := dk.Derive(path)
+		if err != nil {
+			return "", fmt.Errorf("DescriptorKey.Derive(%s, %v) => %v", dk.String(), path, err)
+		}
+		finger, origin, err := dk.origin()
+		if err != nil {
+			return "", err
+		}
+		derivations[pub] = &psbt.Bip32Derivation{
+			PubKey:               decodeHex(pub),
+			MasterKeyFingerprint: binary.LittleEndian.Uint32(finger),
+			Bip32Path:            append(origin, path...),
+		}
+	}
+
+	scripts := make(map[string][]byte)
+	for idx := range pkt.Inputs {
+		if pkt.IsTaprootInput(idx) {
+			continue
+		}
+		pin := &pkt.Inputs[idx]
+		for _, ps := range pin.PartialSigs {
+			sig, err := CanonicalSignatureDER(ps.Signature)
+			if err != nil {
+				return "", err
+			}
+			ps.Signature = append(sig, byte(pin.SighashType))
+		}
+		script := raw.inputScript(idx)
+		pin.Bip32Derivation = scriptDerivations(script, derivations)
+		scripts[hex.EncodeToString(pin.WitnessUtxo.PkScript)] = script
+	}
+	for idx, out := range pkt.UnsignedTx.TxOut {
+		script := scripts[hex.EncodeToString(out.PkScript)]
+		if script == nil {
+			continue
+		}
+		if len(raw.Inputs[0].RedeemScript) > 0 {
+			pkt.Outputs[idx].RedeemScript = script
+		} else {
+			pkt.Outputs[idx].WitnessScript = script
+		}
+		pkt.Outputs[idx].Bip32Derivation = scriptDerivations(script, derivations)
+	}
+
+	err = pkt.SanityCheck()
+	if err != nil {
+		return "", fmt.Errorf("psbt.SanityCheck() => %v", err)
+	}
+	return pkt.B64Encode()
+}
+
+// only the valid partial signatures of the keys in the input scripts are
+// merged, and they are stored in the same format as the safe signatures
+func (raw *PartiallySignedTransaction) MergeSignatures(other *PartiallySignedTransaction) (int, error) {
+	if other.Hash() != raw.Hash() || len(other.Inputs) != len(raw.Inputs) {
+		return 0, fmt.Errorf("psbt.MergeSignatures(%s, %s) mismatch", raw.Hash(), other.Hash())
+	}
+	var merged int
+	for idx := range raw.Inputs {
+		hash := raw.SigHash(idx)
+		if raw.IsTaprootInput(idx) {
+			leaf := raw.taprootSpendLeaf(idx)
+			leafHash := txscript.NewTapLeaf(leaf.LeafVersion, leaf.Script).TapHash()
+			for _, ps := range other.Inputs[idx].TaprootScriptSpendSig {
+				public := hex.EncodeToString(ps.XOnlyPubKey)
+				if !bytes.Equal(ps.LeafHash, leafHash[:]) || ps.SigHash != raw.Inputs[idx].SighashType {
+					continue
+				}
+				if VerifySignatureSchnorr(public, hash, ps.Signature) != nil {
+					return merged, fmt.Errorf("psbt.MergeSignatures(%s, %d) invalid signature %s", raw.Hash(), idx, public)
+				}
+				if bytes.Equal(raw.TaprootSignature(idx, public), ps.Signature) {
+					continue
+				}
+				raw.WriteTaprootSignature(idx, public, ps.Signature)
+				merged = merged + 1
+			}
+			continue
+		}
+
+		ms, err := parseSafeMiniscript(raw.inputScript(idx))
+		if err != nil {
+			return merged, fmt.Errorf("parseSafeMiniscript(%x) => %v", raw.inputScript(idx), err)
+		}
+		keys := miniscriptKeys(ms)
+		pin := &raw.Inputs[idx]
+		for _, ps := range other.Inputs[idx].PartialSigs {
+			public := hex.EncodeToString(ps.PubKey)
+			if !slices.Contains(keys, public) {
+				continue
+			}
+			sig, err := CanonicalSignatureDER(ps.Signature)
+			if err != nil {
+				return merged, err
+			}
+			if VerifySignatureDER(public, hash, sig) != nil {
+				return merged, fmt.Errorf("psbt.MergeSignatures(%s, %d) invalid signature %s", raw.Hash(), idx, public)
+			}
+			sigs := slices.DeleteFunc(pin.PartialSigs, func(s *psbt.PartialSig) bool {
+				return bytes.Equal(s.PubKey, ps.PubKey)
+			})
+			if len(sigs) == len(pin.PartialSigs) {
+				merged = merged + 1
+			}
+			pin.PartialSigs = append(sigs, &psbt.PartialSig{
+				PubKey:    ps.PubKey,
+				Signature: sig,
+			})
+		}
+	}
+	return merged, nil
+}
+
+func scriptDerivations(script []byte, derivations map[string]*psbt.Bip32Derivation) []*psbt.Bip32Derivation {
+	ms, err := parseSafeMiniscript(script)
+	if err != nil {
+		return nil
+	}
+	var bds []*psbt.Bip32Derivation
+	for _, k := range miniscriptKeys(ms) {
+		if bd := derivations[k]; bd != nil {
+			bds = append(bds, bd)
+		}
+	}
+	return bds
+}
+
+func miniscriptKeys(ms *miniscript) []string {
+	var keys []string
+	ms.format(func(k []byte) string {
+		keys = append(keys, hex.EncodeToString(k))
+		return ""
+	})
+	return keys
+}
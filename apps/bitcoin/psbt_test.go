@@ -0,0 +1,147 @@
+package bitcoin
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec/v2/ecdsa"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPartiallySignedTransactionBIP174(t *testing.T) {
+	require := require.New(t)
+
+	path := []uint32{0, 0}
+	holderKey, hp := testExtendedKey("holder", 44+hdkeychain.HardenedKeyStart, hdkeychain.HardenedKeyStart, hdkeychain.HardenedKeyStart)
+	signerKey, sp := testExtendedKey("signer")
+	observerKey, _ := testExtendedKey("observer")
+	require.Contains(holderKey.String(), "/44'/0'/0']xpub")
+	holder, _ := holderKey.Derive(path)
+	signer, _ := signerKey.Derive(path)
+	observer, _ := observerKey.Derive(path)
+	hpk, _ := hp.Derive(0)
+	hpk, _ = hpk.Derive(0)
+	holderPriv, _ := hpk.ECPrivKey()
+	spk, _ := sp.Derive(0)
+	spk, _ = spk.Derive(0)
+	signerPriv, _ := spk.ECPrivKey()
+	require.Equal(holder, hex.EncodeToString(holderPriv.PubKey().SerializeCompressed()))
+
+	wsa, err := BuildWitnessScriptAccount(holder, signer, observer, time.Hour*24*7, ChainBitcoin)
+	require.Nil(err)
+	inputs := []*Input{{
+		TransactionHash: "6daf0a2ca612879093698c5ab6dbcff372e893137d5dfda23615e1489f5e0721",
+		Index:           1,
+		Satoshi:         100000,
+		Script:          wsa.Script,
+		Sequence:        MaxTransactionSequence,
+	}}
+	outputs := []*Output{{Address: "bc1q2nhm0clwt7qcmnpntetjlzf0tflp2h0zvkczql4v9nmydnt7xm6swx2nnv", Satoshi: 60000}}
+	raw, err := BuildPartiallySignedTransaction(inputs, outputs, nil, ChainBitcoin)
+	require.Nil(err)
+	require.Len(raw.UnsignedTx.TxOut, 2)
+	raw = SignPartiallySignedTransaction(raw.Marshal(), signerPriv)
+
+	keys := []*DescriptorKey{holderKey, signerKey, observerKey}
+	b64, err := raw.MarshalBIP174(keys, path)
+	require.Nil(err)
+	pkt, err := psbt.NewFromRawBytes(bytes.NewReader([]byte(b64)), true)
+	require.Nil(err)
+	pin := pkt.Inputs[0]
+	require.Equal(wsa.Script, pin.WitnessScript)
+	require.Equal(SigHashType, pin.SighashType)
+	require.Len(pin.Bip32Derivation, 3)
+	for _, bd := range pin.Bip32Derivation {
+		dk := map[string]*DescriptorKey{holder: holderKey, signer: signerKey, observer: observerKey}[hex.EncodeToString(bd.PubKey)]
+		require.NotNil(dk)
+		finger, origin, _ := dk.origin()
+		require.Equal(binary.LittleEndian.Uint32(finger), bd.MasterKeyFingerprint)
+		require.Equal(append(origin, path...), bd.Bip32Path)
+	}
+	require.Len(pin.PartialSigs, 1)
+	require.Equal(byte(SigHashType), pin.PartialSigs[0].Signature[len(pin.PartialSigs[0].Signature)-1])
+	require.Nil(pkt.Outputs[0].WitnessScript)
+	require.Equal(wsa.Script, pkt.Outputs[1].WitnessScript)
+	require.Len(pkt.Outputs[1].Bip32Derivation, 3)
+
+	// an external wallet signs the input with the sighash type appended
+	hash := raw.SigHash(0)
+	sig := append(ecdsa.Sign(holderPriv, hash).Serialize(), byte(SigHashType))
+	updater, err := psbt.NewUpdater(pkt)
+	require.Nil(err)
+	outcome, err := updater.Sign(0, sig, holderPriv.PubKey().SerializeCompressed(), nil, wsa.Script)
+	require.Nil(err)
+	require.Equal(psbt.SignSuccesful, int(outcome))
+	b64, err = pkt.B64Encode()
+	require.Nil(err)
+	signed, err := ParsePartiallySignedTransaction(b64)
+	require.Nil(err)
+	_, err = ParsePartiallySignedTransaction("psbt" + b64)
+	require.NotNil(err)
+
+	require.False(CheckTransactionPartiallySignedBy(hex.EncodeToString(raw.Marshal()), holder))
+	merged, err := raw.MergeSignatures(signed)
+	require.Nil(err)
+	require.Equal(1, merged)
+	merged, err = raw.MergeSignatures(signed)
+	require.Nil(err)
+	require.Equal(0, merged)
+	require.True(CheckTransactionPartiallySignedBy(hex.EncodeToString(raw.Marshal()), holder))
+	require.True(CheckTransactionPartiallySignedBy(hex.EncodeToString(raw.Marshal()), signer))
+	parsed, err := ParsePartiallySignedTransaction(hex.EncodeToString(raw.Marshal()))
+	require.Nil(err)
+	require.Equal(raw.Hash(), parsed.Hash())
+
+	msgTx, err := raw.SignedTransaction(holder, signer, observer)
+	require.Nil(err)
+	pof := txscript.NewCannedPrevOutputFetcher(pin.WitnessUtxo.PkScript, pin.WitnessUtxo.Value)
+	engine, err := txscript.NewEngine(pin.WitnessUtxo.PkScript, msgTx, 0, txscript.StandardVerifyFlags, nil, txscript.NewTxSigHashes(msgTx, pof), pin.WitnessUtxo.Value, pof)
+	require.Nil(err)
+	require.Nil(engine.Execute())
+
+	// the signature with another sighash type is invalid
+	pkt, _ = psbt.NewFromRawBytes(bytes.NewReader([]byte(b64)), true)
+	other := txscript.SigHashAll
+	tsh := txscript.NewTxSigHashes(pkt.UnsignedTx, pof)
+	hash, _ = txscript.CalcWitnessSigHash(wsa.Script, tsh, other, pkt.UnsignedTx, 0, pin.WitnessUtxo.Value)
+	for _, ps := range pkt.Inputs[0].PartialSigs {
+		if bytes.Equal(ps.PubKey, holderPriv.PubKey().SerializeCompressed()) {
+			ps.Signature = append(ecdsa.Sign(holderPriv, hash).Serialize(), byte(other))
+		}
+	}
+	_, err = raw.MergeSignatures(&PartiallySignedTransaction{Packet: pkt})
+	require.NotNil(err)
+
+	outputs[0].Satoshi = 50000
+	another, _ := BuildPartiallySignedTransaction(inputs, outputs, nil, ChainBitcoin)
+	_, err = raw.MergeSignatures(another)
+	require.NotNil(err)
+}
+
+func testExtendedKey(seed string, children ...uint32) (*DescriptorKey, *hdkeychain.ExtendedKey) {
+	s := btcutil.Hash160([]byte(seed))
+	master, err := hdkeychain.NewMaster(append(s, s...)[:32], &chaincfg.MainNetParams)
+	if err != nil {
+		panic(err)
+	}
+	pub, _ := master.ECPubKey()
+	finger := btcutil.Hash160(pub.SerializeCompressed())[:4]
+	origin := hex.EncodeToString(finger)
+	key := master
+	for _, i := range children {
+		key, _ = key.Derive(i)
+		origin = origin + fmt.Sprintf("/%d'", i-hdkeychain.HardenedKeyStart)
+	}
+	xpub, _ := key.Neuter()
+	return &DescriptorKey{Origin: origin, XPub: xpub.String()}, key
+}
+
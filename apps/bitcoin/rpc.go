@@ -14,7 +14,6 @@ import (
 
 	"github.com/MixinNetwork/mixin/logger"
 	"github.com/btcsuite/btcd/btcutil"
-	"github.com/btcsuite/btcd/txscript"
 	"github.com/shopspring/decimal"
 )
 
@@ -45,13 +44,18 @@ type RPCTransaction struct {
 	Hex       string    `json:"hex"`
 	Fee       float64   `json:"fee"`
 	VSize     int64     `json:"vsize"`
+	Size      int64     `json:"size"`
 }
 
 type MemPoolTransaction struct {
 	Fees struct {
-		Base float64 `json:"base"`
+		Base     float64 `json:"base"`
+		Ancestor float64 `json:"ancestor"`
 	} `json:"fees"`
-	VSize int64 `json:"vsize"`
+	VSize         int64 `json:"vsize"`
+	Size          int64 `json:"size"`
+	AncestorCount int64 `json:"ancestorcount"`
+	AncestorSize  int64 `json:"ancestorsize"`
 }
 
 type RPCBlock struct {
@@ -76,8 +80,7 @@ func RPCGetTransactionOutput(chain byte, rpc, hash string, index int64) (*RPCTra
 		return nil, nil, nil
 	}
 	out := tx.Vout[index]
-	skt := out.ScriptPubKey.Type
-	if skt != ScriptPubKeyTypeWitnessScriptHash && skt != ScriptPubKeyTypeWitnessKeyHash {
+	if !CheckScriptPubKeyType(out.ScriptPubKey.Type, chain) {
 		return nil, nil, nil
 	}
 	if out.ScriptPubKey.Address == "" {
@@ -122,21 +125,33 @@ func RPCGetTransactionOutput(chain byte, rpc, hash string, index int64) (*RPCTra
 	if rtmx.TxOut[index].Value != output.Satoshi {
 		return nil, nil, nil
 	}
-	script, err := txscript.ParsePkScript(rtmx.TxOut[index].PkScript)
+	addr, err := ExtractPkScriptAddr(rtmx.TxOut[index].PkScript, chain)
 	if err != nil {
 		return nil, nil, err
 	}
-	addr, err := script.Address(NetConfig(chain))
-	if err != nil {
-		return nil, nil, err
-	}
-	if addr.EncodeAddress() != output.Address {
+	if addr != output.Address {
 		return nil, nil, nil
 	}
 
 	return tx, output, nil
 }
 
+// the legacy chains without segwit only have the script hash safe outputs,
+// and the public key hash accountant outputs
+func CheckScriptPubKeyType(skt string, chain byte) bool {
+	if !IsSegwitChain(chain) {
+		return skt == ScriptPubKeyTypeScriptHash || skt == ScriptPubKeyTypePubKeyHash
+	}
+	switch skt {
+	case ScriptPubKeyTypeWitnessScriptHash, ScriptPubKeyTypeWitnessKeyHash:
+		return true
+	case ScriptPubKeyTypeWitnessTaproot:
+		return true
+	default:
+		return false
+	}
+}
+
 func RPCGetTransactionSender(chain byte, rpc string, tx *RPCTransaction) (string, error) {
 	if tx.Vin[0].Coinbase != "" {
 		return tx.Vin[0].Coinbase, nil
@@ -158,7 +173,7 @@ func RPCGetTransaction(chain byte, rpc, hash string) (*RPCTransaction, error) {
 	if err != nil {
 		return nil, err
 	}
-	fixLitecoinLegacyScriptPubKeyRPC(chain, &tx)
+	fixLegacyScriptPubKeyRPC(chain, &tx)
 	return &tx, err
 }
 
@@ -201,12 +216,30 @@ func RPCGetRawMempoolWithTransactions(rpc string) ([]*RPCTransaction, error) {
 		transactions = append(transactions, &RPCTransaction{
 			TxId:  id,
 			Fee:   tx.Fees.Base,
-			VSize: tx.VSize,
+			VSize: transactionSize(tx.VSize, tx.Size),
 		})
 	}
 	return transactions, nil
 }
 
+// the entry is nil if the transaction is confirmed or dropped from the mempool
+func RPCGetMempoolEntry(rpc, hash string) (*MemPoolTransaction, error) {
+	res, err := callBitcoinRPCUntilSufficient(rpc, "getmempoolentry", []any{hash})
+	if err != nil && strings.Contains(err.Error(), "Transaction not in mempool") {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var tx MemPoolTransaction
+	err = json.Unmarshal(res, &tx)
+	if err != nil {
+		return nil, err
+	}
+	tx.VSize = transactionSize(tx.VSize, tx.Size)
+	return &tx, nil
+}
+
 func RPCGetBlockWithTransactions(chain byte, rpc, hash string) (*RPCBlockWithTransactions, error) {
 	res, err := callBitcoinRPCUntilSufficient(rpc, "getblock", []any{hash, 2})
 	if err != nil {
@@ -218,7 +251,7 @@ func RPCGetBlockWithTransactions(chain byte, rpc, hash string) (*RPCBlockWithTra
 		return nil, err
 	}
 	for _, tx := range b.Tx {
-		fixLitecoinLegacyScriptPubKeyRPC(chain, tx)
+		fixLegacyScriptPubKeyRPC(chain, tx)
 		tx.BlockHash = hash
 	}
 	return &b, err
@@ -241,10 +274,16 @@ func RPCGetBlockAverageFeePerBytes(chain byte, rpc string) (*big.Int, error) {
 	count := decimal.NewFromInt(int64(len(block.Tx)))
 	for _, tx := range block.Tx {
 		fee := decimal.NewFromFloat(tx.Fee).Mul(decimal.New(1, 8))
-		size := decimal.NewFromInt(tx.VSize)
+		size := decimal.NewFromInt(transactionSize(tx.VSize, tx.Size))
+		if size.IsZero() {
+			continue
+		}
 		feePerBytes := fee.Div(size)
 		sum = sum.Add(feePerBytes)
 	}
+	if count.IsZero() {
+		return sum.BigInt(), nil
+	}
 	sum = sum.Div(count)
 	return sum.Ceil().BigInt(), nil
 }
@@ -256,11 +295,12 @@ func RPCGetMempoolAverageFeePerBytes(rpc string) (*big.Int, error) {
 	}
 	fees := []decimal.Decimal{}
 	for _, tx := range txs {
-		if tx.VSize == 0 {
+		vsize := transactionSize(tx.VSize, tx.Size)
+		if vsize == 0 {
 			continue
 		}
 		fee := decimal.NewFromFloat(tx.Fee).Mul(decimal.New(1, 8))
-		size := decimal.NewFromInt(tx.VSize)
+		size := decimal.NewFromInt(vsize)
 		feePerBytes := fee.Div(size)
 		fees = append(fees, feePerBytes)
 	}
@@ -274,6 +314,9 @@ func RPCGetMempoolAverageFeePerBytes(rpc string) (*big.Int, error) {
 		sum = sum.Add(f)
 		count += 1
 	}
+	if count == 0 {
+		return sum.BigInt(), nil
+	}
 	avg := sum.Div(decimal.NewFromInt(count))
 	return avg.Ceil().BigInt(), nil
 }
@@ -323,6 +366,10 @@ func EstimateAvgFee(chain byte, rpc string) (int64, error) {
 	if mempoolTopAvgFee.Cmp(blockAvgFee) > 0 {
 		maxFee = mempoolTopAvgFee
 	}
+	// the bitcoin cash blocks and mempool are mostly empty
+	if maxFee.Cmp(big.NewInt(0)) <= 0 && chain == ChainBitcoinCash {
+		maxFee = big.NewInt(1)
+	}
 	if maxFee.Cmp(big.NewInt(0)) <= 0 {
 		return 0, fmt.Errorf("EstimateAvgFee(%d) => %s", chain, maxFee.String())
 	}
@@ -337,6 +384,14 @@ func EstimateAvgFee(chain byte, rpc string) (int64, error) {
 	return fvb, nil
 }
 
+// the legacy chains have no virtual size
+func transactionSize(vsize, size int64) int64 {
+	if vsize > 0 {
+		return vsize
+	}
+	return size
+}
+
 func RPCSendRawTransaction(rpc, raw string) (string, error) {
 	res, err := callBitcoinRPCUntilSufficient(rpc, "sendrawtransaction", []any{raw})
 	if err != nil {
@@ -348,7 +403,7 @@ func RPCSendRawTransaction(rpc, raw string) (string, error) {
 }
 
 // FIXME wait for litecoin core update to the latest rpc
-func fixLitecoinLegacyScriptPubKeyRPC(chain byte, tx *RPCTransaction) {
+func fixLegacyScriptPubKeyRPC(chain byte, tx *RPCTransaction) {
 	switch chain {
 	case ChainLitecoin:
 		for _, o := range tx.Vout {
@@ -357,6 +412,19 @@ func fixLitecoinLegacyScriptPubKeyRPC(chain byte, tx *RPCTransaction) {
 			}
 			o.ScriptPubKey.Address = o.ScriptPubKey.LegacyAddresses[0]
 		}
+	case ChainBitcoinCash:
+		// the cash addresses are returned with or without the prefix
+		for _, o := range tx.Vout {
+			addr := o.ScriptPubKey.Address
+			if addr == "" && len(o.ScriptPubKey.LegacyAddresses) == 1 {
+				addr = o.ScriptPubKey.LegacyAddresses[0]
+			}
+			ca, err := decodeCashAddress(addr)
+			if err != nil {
+				continue
+			}
+			o.ScriptPubKey.Address, _ = encodeCashAddress(ca)
+		}
 	}
 }
 
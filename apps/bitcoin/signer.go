@@ -0,0 +1,50 @@
+package bitcoin
+
+import (
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/ecdsa"
+)
+
+// AccountantSigner signs the fee inputs with the accountant key, which
+// could be a raw private key or a handle to the key in a key vault
+type AccountantSigner interface {
+	PublicKey() []byte
+	Sign(hash []byte) ([]byte, error)
+}
+
+type privateKeySigner struct {
+	priv *btcec.PrivateKey
+}
+
+func NewPrivateKeySigner(priv *btcec.PrivateKey) AccountantSigner {
+	return &privateKeySigner{priv: priv}
+}
+
+func (s *privateKeySigner) PublicKey() []byte {
+	return s.priv.PubKey().SerializeCompressed()
+}
+
+func (s *privateKeySigner) Sign(hash []byte) ([]byte, error) {
+	return ecdsa.Sign(s.priv, hash).Serialize(), nil
+}
+
+// EncodeSignatureDER normalizes the r||s signature from a key vault to the
+// low s DER format required by the bitcoin script
+func EncodeSignatureDER(sig []byte) ([]byte, error) {
+	if len(sig) != 64 {
+		return nil, fmt.Errorf("invalid signature size %d", len(sig))
+	}
+	var r, s btcec.ModNScalar
+	if r.SetByteSlice(sig[:32]) || s.SetByteSlice(sig[32:]) {
+		return nil, fmt.Errorf("invalid signature %x", sig)
+	}
+	if r.IsZero() || s.IsZero() {
+		return nil, fmt.Errorf("invalid signature %x", sig)
+	}
+	if s.IsOverHalfOrder() {
+		s.Negate()
+	}
+	return ecdsa.NewSignature(&r, &s).Serialize(), nil
+}
@@ -0,0 +1,278 @@
+package bitcoin
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr/musig2"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/txscript"
+)
+
+// the internal key is the musig2 aggregation of the holder and signer keys,
+// so a cooperative spend is a single key path signature which looks like any
+// other taproot spend, and the holder and signer leaf is kept for the spends
+// signed separately, e.g. when the musig2 nonces could not be exchanged
+//
+// normal:
+// <HOLDER> OP_CHECKSIGVERIFY <SIGNER> OP_CHECKSIG
+//
+// recovery:
+// <OBSERVER> OP_CHECKSIGVERIFY
+// <HOLDER> OP_CHECKSIG <SIGNER> OP_CHECKSIGADD OP_VERIFY
+// <a032> OP_CHECKSEQUENCEVERIFY
+//
+// the account script is the leaf version, the normal leaf length, the
+// normal leaf and the recovery leaf, all keys are x-only public keys
+func BuildTaprootScriptAccount(holder, signer, observer string, lock time.Duration, chain byte) (*WitnessScriptAccount, error) {
+	if chain != ChainBitcoin {
+		return nil, fmt.Errorf("taproot not supported on chain %d", chain)
+	}
+	var pubKeys [][]byte
+	for _, public := range []string{holder, signer, observer} {
+		pub, err := parseTaprootPublicKey(public)
+		if err != nil {
+			return nil, fmt.Errorf("parseTaprootPublicKey(%s) => %v", public, err)
+		}
+		pubKeys = append(pubKeys, schnorr.SerializePubKey(pub))
+	}
+
+	sequence, err := ParseSequence(lock, chain)
+	if err != nil {
+		return nil, err
+	}
+
+	builder := txscript.NewScriptBuilder()
+	builder.AddData(pubKeys[0])
+	builder.AddOp(txscript.OP_CHECKSIGVERIFY)
+	builder.AddData(pubKeys[1])
+	builder.AddOp(txscript.OP_CHECKSIG)
+	normal, err := builder.Script()
+	if err != nil {
+		return nil, fmt.Errorf("build.Script() => %v", err)
+	}
+
+	builder = txscript.NewScriptBuilder()
+	builder.AddData(pubKeys[2])
+	builder.AddOp(txscript.OP_CHECKSIGVERIFY)
+	builder.AddData(pubKeys[0])
+	builder.AddOp(txscript.OP_CHECKSIG)
+	builder.AddData(pubKeys[1])
+	builder.AddOp(txscript.OP_CHECKSIGADD)
+	builder.AddOp(txscript.OP_VERIFY)
+	builder.AddInt64(sequence)
+	builder.AddOp(txscript.OP_CHECKSEQUENCEVERIFY)
+	recovery, err := builder.Script()
+	if err != nil {
+		return nil, fmt.Errorf("build.Script() => %v", err)
+	}
+
+	script := []byte{byte(txscript.BaseLeafVersion), byte(len(normal))}
+	script = append(script, normal...)
+	script = append(script, recovery...)
+	ts, err := parseTaprootScript(script)
+	if err != nil {
+		return nil, fmt.Errorf("parseTaprootScript(%x) => %v", script, err)
+	}
+	addr, err := ts.address(chain)
+	if err != nil {
+		return nil, err
+	}
+
+	return &WitnessScriptAccount{
+		Sequence: uint32(sequence),
+		Script:   script,
+		Address:  addr,
+	}, nil
+}
+
+func CheckTaprootHolderSignerScript(script []byte) bool {
+	return checkScriptType(script) == InputTypeP2TRHolderSigner
+}
+
+// holder and observer keys are compressed ecdsa keys, and the signer keys
+// are x-only keys, both of them are used as x-only keys in the leaves
+func VerifyTaprootKey(public string) error {
+	_, err := parseTaprootPublicKey(public)
+	return err
+}
+
+func VerifySignatureSchnorr(public string, msg, sig []byte) error {
+	pub, err := parseTaprootPublicKey(public)
+	if err != nil {
+		return err
+	}
+	signature, err := schnorr.ParseSignature(sig)
+	if err != nil {
+		return err
+	}
+	if signature.Verify(msg, pub) {
+		return nil
+	}
+	return fmt.Errorf("bitcoin.VerifySignatureSchnorr(%s, %x, %x)", public, msg, sig)
+}
+
+func CheckTaprootDerivation(public string, chainCode []byte, maxRange uint32) error {
+	pub, err := hex.DecodeString(public)
+	if err != nil {
+		return err
+	}
+	if len(pub) != schnorr.PubKeyBytesLen {
+		return fmt.Errorf("invalid x-only public key %s", public)
+	}
+	for i := uint32(0); i <= maxRange; i++ {
+		children := []uint32{i, i, i}
+		_, err := DeriveTaprootBIP32(public, chainCode, children...)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// this matches the taproot config derivation of the signer, which lifts the
+// x-only key to the even point before each non-hardened derivation
+func DeriveTaprootBIP32(public string, chainCode []byte, children ...uint32) (string, error) {
+	pub, err := parseTaprootPublicKey(public)
+	if err != nil {
+		return "", err
+	}
+	key := schnorr.SerializePubKey(pub)
+	parentFP := []byte{0x00, 0x00, 0x00, 0x00}
+	version := []byte{0x04, 0x88, 0xb2, 0x1e}
+	for _, i := range children {
+		even := append([]byte{0x02}, key...)
+		extPub := hdkeychain.NewExtendedKey(version, even, chainCode, parentFP, 0, 0, false)
+		extPub, err = extPub.Derive(i)
+		if err != nil {
+			return "", err
+		}
+		if bytes.Equal(extPub.ChainCode(), chainCode) {
+			return "", fmt.Errorf("invalid taproot derivation %s %d", public, i)
+		}
+		child, err := extPub.ECPubKey()
+		if err != nil {
+			return "", err
+		}
+		key = schnorr.SerializePubKey(child)
+		chainCode = extPub.ChainCode()
+	}
+	return hex.EncodeToString(key), nil
+}
+
+type taprootScript struct {
+	normal   txscript.TapLeaf
+	recovery txscript.TapLeaf
+	tree     *txscript.IndexedTapScriptTree
+	internal *btcec.PublicKey
+}
+
+func parseTaprootScript(script []byte) (*taprootScript, error) {
+	if len(script) < 3 || script[0] != byte(txscript.BaseLeafVersion) {
+		return nil, fmt.Errorf("invalid taproot script %x", script)
+	}
+	size := int(script[1])
+	if size == 0 || len(script) <= 2+size {
+		return nil, fmt.Errorf("invalid taproot script %x", script)
+	}
+	// <HOLDER> OP_CHECKSIGVERIFY <SIGNER> OP_CHECKSIG
+	normal := script[2 : 2+size]
+	if size != 68 || normal[0] != txscript.OP_DATA_32 || normal[33] != txscript.OP_CHECKSIGVERIFY ||
+		normal[34] != txscript.OP_DATA_32 || normal[67] != txscript.OP_CHECKSIG {
+		return nil, fmt.Errorf("invalid taproot script %x", script)
+	}
+	internal, err := aggregateTaprootKeys(normal[1:33], normal[35:67])
+	if err != nil {
+		return nil, fmt.Errorf("invalid taproot script %x", script)
+	}
+	ts := &taprootScript{
+		normal:   txscript.NewBaseTapLeaf(script[2 : 2+size]),
+		recovery: txscript.NewBaseTapLeaf(script[2+size:]),
+		internal: internal,
+	}
+	ts.tree = txscript.AssembleTaprootScriptTree(ts.normal, ts.recovery)
+	return ts, nil
+}
+
+func (ts *taprootScript) outputKey() *btcec.PublicKey {
+	root := ts.tree.RootNode.TapHash()
+	return txscript.ComputeTaprootOutputKey(ts.internal, root[:])
+}
+
+func (ts *taprootScript) address(chain byte) (string, error) {
+	key := schnorr.SerializePubKey(ts.outputKey())
+	addr, err := btcutil.NewAddressTaproot(key, NetConfig(chain))
+	if err != nil {
+		return "", fmt.Errorf("btcutil.NewAddressTaproot(%x) => %v", key, err)
+	}
+	return addr.EncodeAddress(), nil
+}
+
+func (ts *taprootScript) controlBlock(leaf txscript.TapLeaf) []byte {
+	idx := ts.tree.LeafProofIndex[leaf.TapHash()]
+	proof := ts.tree.LeafMerkleProofs[idx]
+	cb := proof.ToControlBlock(ts.internal)
+	b, err := cb.ToBytes()
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// both keys are lifted to the even points, so the holder with an odd compressed
+// key negates its private key for the musig2 session, as for the leaf spends
+func aggregateTaprootKeys(holder, signer []byte) (*btcec.PublicKey, error) {
+	var keys []*btcec.PublicKey
+	for _, k := range [][]byte{holder, signer} {
+		pub, err := schnorr.ParsePubKey(k)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, pub)
+	}
+	agg, _, _, err := musig2.AggregateKeys(keys, true)
+	if err != nil {
+		return nil, err
+	}
+	return agg.PreTweakedKey, nil
+}
+
+func checkTaprootRecoveryLeaf(script []byte) bool {
+	return len(script) > 0 && script[len(script)-1] == txscript.OP_CHECKSEQUENCEVERIFY
+}
+
+func parseTaprootPublicKey(public string) (*btcec.PublicKey, error) {
+	pub, err := hex.DecodeString(public)
+	if err != nil {
+		return nil, err
+	}
+	switch len(pub) {
+	case schnorr.PubKeyBytesLen:
+		return schnorr.ParsePubKey(pub)
+	case btcec.PubKeyBytesLenCompressed:
+		return btcec.ParsePubKey(pub)
+	default:
+		return nil, fmt.Errorf("invalid taproot public key %s", public)
+	}
+}
+
+func taprootXOnlyPublicKey(public string) []byte {
+	pub, err := parseTaprootPublicKey(public)
+	if err != nil {
+		return nil
+	}
+	return schnorr.SerializePubKey(pub)
+}
+
+func decodeHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(s)
+	}
+	return b
+}
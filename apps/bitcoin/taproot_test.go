@@ -0,0 +1,268 @@
+package bitcoin
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/multi-party-sig/pkg/math/curve"
+	"github.com/MixinNetwork/multi-party-sig/pkg/party"
+	"github.com/MixinNetwork/multi-party-sig/protocols/frost"
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr/musig2"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testTaprootHolderPrivate   = "52250bb9b9edc5d54466182778a6470a5ee34033c215c92dd250b9c2ce543556"
+	testTaprootSignerPrivate   = "e0eb7ecd6a0c0a1a26b27cfc5e01e8b4a4b1d2c5d1ec19e3a71c2d4fbdff2a4e"
+	testTaprootObserverPrivate = "97f6a6c8b8c5d4d0b1f3df4a1f9dc0f8c7e62e6e80c8bd4dcfd9b3b31fd31a4e"
+)
+
+func TestTaprootAccount(t *testing.T) {
+	require := require.New(t)
+
+	holder, hp := testTaprootKey(testTaprootHolderPrivate, true)
+	signer, sp := testTaprootKey(testTaprootSignerPrivate, false)
+	observer, op := testTaprootKey(testTaprootObserverPrivate, true)
+	require.Len(holder, 66)
+	require.Len(signer, 64)
+	require.Nil(VerifyTaprootKey(holder))
+	require.Nil(VerifyTaprootKey(signer))
+	require.NotNil(VerifyTaprootKey(signer[2:]))
+
+	_, err := BuildTaprootScriptAccount(holder, signer, observer, time.Hour*24*7, ChainLitecoin)
+	require.NotNil(err)
+	tsa, err := BuildTaprootScriptAccount(holder, signer, observer, time.Hour*24*7, ChainBitcoin)
+	require.Nil(err)
+	require.True(strings.HasPrefix(tsa.Address, "bc1p"))
+	require.Equal(uint32(1008), tsa.Sequence)
+	require.True(CheckTaprootHolderSignerScript(tsa.Script))
+	require.False(CheckMultisigHolderSignerScript(tsa.Script))
+	addr, err := EncodeAddress(tsa.Script, ChainBitcoin)
+	require.Nil(err)
+	require.Equal(tsa.Address, addr)
+	wsa, err := UnmarshalWitnessScriptAccount(tsa.Marshal())
+	require.Nil(err)
+	require.Equal(tsa, wsa)
+
+	receiver := "bc1q2nhm0clwt7qcmnpntetjlzf0tflp2h0zvkczql4v9nmydnt7xm6swx2nnv"
+	for _, recovery := range []bool{false, true} {
+		inputs := []*Input{{
+			TransactionHash: "6daf0a2ca612879093698c5ab6dbcff372e893137d5dfda23615e1489f5e0721",
+			Index:           1,
+			Satoshi:         100000,
+			Script:          tsa.Script,
+			Sequence:        tsa.Sequence,
+			RouteBackup:     recovery,
+		}}
+		outputs := []*Output{{Address: receiver, Satoshi: 100000}}
+		psbt, err := BuildPartiallySignedTransaction(inputs, outputs, nil, ChainBitcoin)
+		require.Nil(err)
+		require.Equal(recovery, psbt.IsRecoveryTransaction())
+		require.Len(psbt.Inputs[0].TaprootLeafScript, 2)
+
+		raw := psbt.Marshal()
+		require.False(CheckTransactionSignedByHolders(hex.EncodeToString(raw)))
+		psbt = SignPartiallySignedTransaction(raw, hp)
+		require.True(CheckTransactionPartiallySignedBy(hex.EncodeToString(psbt.Marshal()), holder))
+		require.False(CheckTransactionPartiallySignedBy(hex.EncodeToString(psbt.Marshal()), signer))
+		require.True(CheckTransactionSignedByHolders(hex.EncodeToString(psbt.Marshal())))
+		second := sp
+		if recovery {
+			second = op
+		}
+		psbt = SignPartiallySignedTransaction(psbt.Marshal(), second)
+		require.True(CheckTransactionPartiallySignedBy(hex.EncodeToString(psbt.Marshal()), holder))
+		require.True(CheckTransactionSignedByHolders(hex.EncodeToString(psbt.Marshal())))
+
+		msgTx, err := psbt.SignedTransaction(holder, signer, observer)
+		require.Nil(err)
+		pin := psbt.Inputs[0]
+		pof := txscript.NewCannedPrevOutputFetcher(pin.WitnessUtxo.PkScript, pin.WitnessUtxo.Value)
+		engine, err := txscript.NewEngine(pin.WitnessUtxo.PkScript, msgTx, 0, txscript.StandardVerifyFlags, nil, txscript.NewTxSigHashes(msgTx, pof), pin.WitnessUtxo.Value, pof)
+		require.Nil(err)
+		require.Nil(engine.Execute())
+
+		if !recovery {
+			psbt = SignPartiallySignedTransaction(raw, hp)
+			_, err = psbt.SignedTransaction(holder, signer, observer)
+			require.NotNil(err)
+			continue
+		}
+
+		// the observer with the signer only, and the observer alone
+		psbt = SignPartiallySignedTransaction(raw, sp)
+		psbt = SignPartiallySignedTransaction(psbt.Marshal(), op)
+		require.False(CheckTransactionSignedByHolders(hex.EncodeToString(psbt.Marshal())))
+		msgTx, err = psbt.SignedTransaction(holder, signer, observer)
+		require.Nil(err)
+		engine, err = txscript.NewEngine(pin.WitnessUtxo.PkScript, msgTx, 0, txscript.StandardVerifyFlags, nil, txscript.NewTxSigHashes(msgTx, pof), pin.WitnessUtxo.Value, pof)
+		require.Nil(err)
+		require.Nil(engine.Execute())
+		psbt = SignPartiallySignedTransaction(raw, op)
+		_, err = psbt.SignedTransaction(holder, signer, observer)
+		require.NotNil(err)
+	}
+}
+
+func TestTaprootKeySpend(t *testing.T) {
+	require := require.New(t)
+
+	holder, hp := testTaprootKey(testTaprootHolderPrivate, true)
+	signer, sp := testTaprootKey(testTaprootSignerPrivate, false)
+	observer, _ := testTaprootKey(testTaprootObserverPrivate, true)
+	tsa, err := BuildTaprootScriptAccount(holder, signer, observer, time.Hour*24*7, ChainBitcoin)
+	require.Nil(err)
+
+	inputs := []*Input{{
+		TransactionHash: "6daf0a2ca612879093698c5ab6dbcff372e893137d5dfda23615e1489f5e0721",
+		Index:           1,
+		Satoshi:         100000,
+		Script:          tsa.Script,
+		Sequence:        tsa.Sequence,
+	}, {
+		TransactionHash: "9b5e0b1c5d4f0c2b3a1e8f7d6c5b4a3928170615f4e3d2c1b0a9f8e7d6c5b4a3",
+		Index:           0,
+		Satoshi:         50000,
+		Script:          tsa.Script,
+		Sequence:        tsa.Sequence,
+	}}
+	receiver := "bc1q2nhm0clwt7qcmnpntetjlzf0tflp2h0zvkczql4v9nmydnt7xm6swx2nnv"
+	outputs := []*Output{{Address: receiver, Satoshi: 150000}}
+	psbt, err := BuildPartiallySignedTransaction(inputs, outputs, nil, ChainBitcoin)
+	require.Nil(err)
+
+	// the holder and signer keys are lifted to the even points
+	var keys []*btcec.PrivateKey
+	for _, p := range []*btcec.PrivateKey{hp, sp} {
+		if p.PubKey().SerializeCompressed()[0] == 0x03 {
+			s := new(btcec.ModNScalar).Set(&p.Key)
+			p = btcec.PrivKeyFromScalar(s.Negate())
+		}
+		keys = append(keys, p)
+	}
+	require.False(CheckTransactionSignedByHolders(hex.EncodeToString(psbt.Marshal())))
+	for idx := range psbt.Inputs {
+		pin := psbt.Inputs[idx]
+		require.Equal(pin.WitnessUtxo.PkScript[2:], schnorr.SerializePubKey(txscript.ComputeTaprootOutputKey(
+			testTaprootParseKey(pin.TaprootInternalKey), pin.TaprootMerkleRoot)))
+		sig := testTaprootMuSig2(require, keys, pin.TaprootMerkleRoot, psbt.KeySpendSigHash(idx))
+		err = psbt.WriteKeySpendSignature(idx, sig[:32])
+		require.NotNil(err)
+		invalid := append([]byte{}, sig...)
+		invalid[63] ^= 1
+		err = psbt.WriteKeySpendSignature(idx, invalid)
+		require.NotNil(err)
+		err = psbt.WriteKeySpendSignature(idx, sig)
+		require.Nil(err)
+	}
+
+	psbt, err = UnmarshalPartiallySignedTransaction(psbt.Marshal())
+	require.Nil(err)
+	require.True(CheckTransactionSignedByHolders(hex.EncodeToString(psbt.Marshal())))
+	psbt.Inputs[1].TaprootKeySpendSig[0] ^= 1
+	require.False(CheckTransactionSignedByHolders(hex.EncodeToString(psbt.Marshal())))
+	psbt.Inputs[1].TaprootKeySpendSig[0] ^= 1
+	msgTx, err := psbt.SignedTransaction(holder, signer, observer)
+	require.Nil(err)
+	pof := psbt.prevOutputFetcher()
+	for idx, pin := range psbt.Inputs {
+		require.Len(msgTx.TxIn[idx].Witness, 1)
+		engine, err := txscript.NewEngine(pin.WitnessUtxo.PkScript, msgTx, idx, txscript.StandardVerifyFlags, nil, txscript.NewTxSigHashes(msgTx, pof), pin.WitnessUtxo.Value, pof)
+		require.Nil(err)
+		require.Nil(engine.Execute())
+	}
+}
+
+func TestTaprootDerivation(t *testing.T) {
+	require := require.New(t)
+
+	group := curve.Secp256k1{}
+	chainCode, _ := hex.DecodeString("2ec96d547bfee9ace803ac050929b74c1a04954b78b4b6035e97a5e078a5a0f2")
+	priv, _ := hex.DecodeString(testTaprootSignerPrivate)
+	share := group.NewScalar()
+	err := share.UnmarshalBinary(priv)
+	require.Nil(err)
+	public := share.ActOnBase().(*curve.Secp256k1Point)
+	if !public.HasEvenY() {
+		share.Negate()
+	}
+	conf := &frost.TaprootConfig{
+		ID:                 party.ID("signer"),
+		PrivateShare:       share.(*curve.Secp256k1Scalar),
+		PublicKey:          public.XScalar().Bytes(),
+		ChainKey:           chainCode,
+		VerificationShares: map[party.ID]curve.Point{},
+	}
+
+	err = CheckTaprootDerivation(hex.EncodeToString(conf.PublicKey), chainCode, 10)
+	require.Nil(err)
+	for i := uint32(0); i < 8; i++ {
+		derived := conf
+		for j := 0; j < 3; j++ {
+			derived, err = derived.DeriveChild(i)
+			require.Nil(err)
+		}
+		sdk, err := DeriveTaprootBIP32(hex.EncodeToString(conf.PublicKey), chainCode, i, i, i)
+		require.Nil(err)
+		require.Equal(hex.EncodeToString(derived.PublicKey), sdk)
+	}
+}
+
+func testTaprootMuSig2(require *require.Assertions, keys []*btcec.PrivateKey, root, msg []byte) []byte {
+	var publics []*btcec.PublicKey
+	for _, k := range keys {
+		publics = append(publics, k.PubKey())
+	}
+	var sessions []*musig2.Session
+	for _, k := range keys {
+		mc, err := musig2.NewContext(k, true, musig2.WithKnownSigners(publics), musig2.WithTaprootTweakCtx(root))
+		require.Nil(err)
+		session, err := mc.NewSession()
+		require.Nil(err)
+		sessions = append(sessions, session)
+	}
+	for i, s := range sessions {
+		for j, o := range sessions {
+			if i == j {
+				continue
+			}
+			_, err := s.RegisterPubNonce(o.PublicNonce())
+			require.Nil(err)
+		}
+	}
+	var digest [32]byte
+	copy(digest[:], msg)
+	var partials []*musig2.PartialSignature
+	for _, s := range sessions {
+		ps, err := s.Sign(digest)
+		require.Nil(err)
+		partials = append(partials, ps)
+	}
+	for _, ps := range partials[1:] {
+		_, err := sessions[0].CombineSig(ps)
+		require.Nil(err)
+	}
+	return sessions[0].FinalSig().Serialize()
+}
+
+func testTaprootParseKey(key []byte) *btcec.PublicKey {
+	pub, err := schnorr.ParsePubKey(key)
+	if err != nil {
+		panic(err)
+	}
+	return pub
+}
+
+func testTaprootKey(priv string, compressed bool) (string, *btcec.PrivateKey) {
+	seed, _ := hex.DecodeString(priv)
+	key, _ := btcec.PrivKeyFromBytes(seed)
+	if compressed {
+		return hex.EncodeToString(key.PubKey().SerializeCompressed()), key
+	}
+	return hex.EncodeToString(schnorr.SerializePubKey(key.PubKey())), key
+}
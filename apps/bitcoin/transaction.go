@@ -2,7 +2,6 @@ package bitcoin
 
 import (
 	"bytes"
-	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
 	"time"
@@ -10,6 +9,7 @@ import (
 	"github.com/btcsuite/btcd/blockchain"
 	"github.com/btcsuite/btcd/btcec/v2"
 	"github.com/btcsuite/btcd/btcec/v2/ecdsa"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
 	"github.com/btcsuite/btcd/btcutil"
 	"github.com/btcsuite/btcd/btcutil/psbt"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
@@ -64,8 +64,15 @@ func (psbt *PartiallySignedTransaction) EstimateVirtualSize() int {
 		panic(err)
 	}
 	const witnessSize = 264
-	weight := len(tx.MsgTx().TxIn) * witnessSize
-	weight = weight + 4*len(raw)
+	weight := 4 * len(raw)
+	for _, pin := range psbt.Inputs {
+		if len(pin.RedeemScript) == 0 {
+			weight = weight + witnessSize
+			continue
+		}
+		// the legacy signature script with the redeem script and signatures
+		weight = weight + 4*(len(pin.RedeemScript)+160)
+	}
 	return weight / 4
 }
 
@@ -88,67 +95,316 @@ func (psbt *PartiallySignedTransaction) SigHash(idx int) []byte {
 	tx := psbt.UnsignedTx
 	pin := psbt.Inputs[idx]
 	satoshi := pin.WitnessUtxo.Value
-	pof := txscript.NewCannedPrevOutputFetcher(pin.WitnessScript, satoshi)
+	if len(pin.TaprootLeafScript) > 0 {
+		leaf := psbt.taprootSpendLeaf(idx)
+		pof := psbt.prevOutputFetcher()
+		tsh := txscript.NewTxSigHashes(tx, pof)
+		tl := txscript.NewTapLeaf(leaf.LeafVersion, leaf.Script)
+		hash, err := txscript.CalcTapscriptSignaturehash(tsh, SigHashType, tx, idx, pof, tl)
+		if err != nil {
+			panic(err)
+		}
+		return hash
+	}
+	// the bitcoin cash forkid sighash is the BIP143 digest with the forkid
+	// flag in the sighash type, so it is calculated the same as witness
+	script, hashType := pin.WitnessScript, SigHashType
+	if len(pin.RedeemScript) > 0 {
+		script, hashType = pin.RedeemScript, pin.SighashType
+		if hashType != SigHashType|SigHashForkId {
+			panic(hashType)
+		}
+	}
+	pof := txscript.NewCannedPrevOutputFetcher(script, satoshi)
 	tsh := txscript.NewTxSigHashes(tx, pof)
-	hash, err := txscript.CalcWitnessSigHash(pin.WitnessScript, tsh, SigHashType, tx, idx, satoshi)
+	hash, err := txscript.CalcWitnessSigHash(script, tsh, hashType, tx, idx, satoshi)
 	if err != nil {
 		panic(err)
 	}
 	return hash
 }
 
+// the musig2 session of the holder and signer signs this key path digest with
+// the internal key tweaked by the merkle root of the input
+func (psbt *PartiallySignedTransaction) KeySpendSigHash(idx int) []byte {
+	if !psbt.IsTaprootInput(idx) || psbt.IsRecoveryTransaction() {
+		panic(idx)
+	}
+	pof := psbt.prevOutputFetcher()
+	tsh := txscript.NewTxSigHashes(psbt.UnsignedTx, pof)
+	hash, err := txscript.CalcTaprootSignatureHash(tsh, psbt.Inputs[idx].SighashType, psbt.UnsignedTx, idx, pof)
+	if err != nil {
+		panic(err)
+	}
+	return hash
+}
+
+func (psbt *PartiallySignedTransaction) WriteKeySpendSignature(idx int, sig []byte) error {
+	pin := &psbt.Inputs[idx]
+	signature, err := schnorr.ParseSignature(sig)
+	if err != nil {
+		return err
+	}
+	key, err := schnorr.ParsePubKey(pin.WitnessUtxo.PkScript[2:])
+	if err != nil {
+		return err
+	}
+	if !signature.Verify(psbt.KeySpendSigHash(idx), key) {
+		return fmt.Errorf("psbt.WriteKeySpendSignature(%d) invalid signature %x", idx, sig)
+	}
+	pin.TaprootKeySpendSig = append([]byte{}, sig...)
+	if pin.SighashType != txscript.SigHashDefault {
+		pin.TaprootKeySpendSig = append(pin.TaprootKeySpendSig, byte(pin.SighashType))
+	}
+	return nil
+}
+
+// the taproot sighash commits to the amounts and scripts of all the inputs
+func (psbt *PartiallySignedTransaction) prevOutputFetcher() txscript.PrevOutputFetcher {
+	pof := txscript.NewMultiPrevOutFetcher(nil)
+	for i, in := range psbt.UnsignedTx.TxIn {
+		if out := psbt.Inputs[i].WitnessUtxo; out != nil {
+			pof.AddPrevOut(in.PreviousOutPoint, out)
+		}
+	}
+	return pof
+}
+
+// the safe script of the input is the redeem script for the legacy chains
+func (raw *PartiallySignedTransaction) inputScript(idx int) []byte {
+	pin := raw.Inputs[idx]
+	if len(pin.RedeemScript) > 0 {
+		return pin.RedeemScript
+	}
+	return pin.WitnessScript
+}
+
 func (psbt *PartiallySignedTransaction) SignedTransaction(holder, signer, observer string) (*wire.MsgTx, error) {
 	msgTx := psbt.UnsignedTx.Copy()
 	isRecoveryTransaction := psbt.IsRecoveryTransaction()
 	for idx := range msgTx.TxIn {
 		pin := psbt.Inputs[idx]
-		sigs := make(map[string][]byte, 2)
-		for _, ps := range pin.PartialSigs {
-			pub := hex.EncodeToString(ps.PubKey)
-			sig, err := CanonicalSignatureDER(ps.Signature)
+		if len(pin.TaprootLeafScript) > 0 {
+			witness, err := psbt.taprootWitness(idx, holder, signer, observer)
 			if err != nil {
 				return nil, err
 			}
-			sigs[pub] = sig
+			msgTx.TxIn[idx].Witness = witness
+			continue
+		}
+		sigs, err := psbt.partialSignatures(idx)
+		if err != nil {
+			return nil, err
+		}
+		script := psbt.inputScript(idx)
+		ms, err := parseSafeMiniscript(script)
+		if err != nil {
+			return nil, err
 		}
 
-		holderSig := sigs[holder]
-		signerSig := sigs[signer]
-		observerSig := sigs[observer]
+		sequence := msgTx.TxIn[idx].Sequence
+		_, holderSigned := ms.subs[0].satisfy(sigs, sequence)
+		signerSigned := sigs[signer] != nil
+		observerSigned := sigs[observer] != nil
 		switch {
 		case isRecoveryTransaction:
-			if observerSig == nil {
+			if !observerSigned {
 				return nil, fmt.Errorf("psbt.SignedTransaction(%s, %s, %s) observer", holder, signer, observer)
 			}
-			if holderSig == nil && signerSig == nil {
+			if !holderSigned && !signerSigned {
 				return nil, fmt.Errorf("psbt.SignedTransaction(%s, %s, %s) holder&signer", holder, signer, observer)
 			}
 		case !isRecoveryTransaction:
-			if holderSig == nil {
+			if !holderSigned {
 				return nil, fmt.Errorf("psbt.SignedTransaction(%s, %s, %s) holder", holder, signer, observer)
 			}
-			if signerSig == nil {
+			if !signerSigned {
 				return nil, fmt.Errorf("psbt.SignedTransaction(%s, %s, %s) signer", holder, signer, observer)
 			}
 		}
 
-		if observerSig != nil {
-			observerSig = append(observerSig, byte(pin.SighashType))
+		witness, satisfied := ms.satisfy(sigs, sequence)
+		if !satisfied {
+			return nil, fmt.Errorf("psbt.SignedTransaction(%s, %s, %s) %s", holder, signer, observer, ms.String())
 		}
-		msgTx.TxIn[idx].Witness = append(msgTx.TxIn[idx].Witness, observerSig)
-		if signerSig != nil {
-			signerSig = append(signerSig, byte(pin.SighashType))
+		if len(pin.RedeemScript) == 0 {
+			msgTx.TxIn[idx].Witness = append(witness, script)
+			continue
 		}
-		msgTx.TxIn[idx].Witness = append(msgTx.TxIn[idx].Witness, signerSig)
-		if holderSig != nil {
-			holderSig = append(holderSig, byte(pin.SighashType))
+		builder := txscript.NewScriptBuilder()
+		for _, w := range witness {
+			builder.AddData(w)
 		}
-		msgTx.TxIn[idx].Witness = append(msgTx.TxIn[idx].Witness, holderSig)
-		msgTx.TxIn[idx].Witness = append(msgTx.TxIn[idx].Witness, pin.WitnessScript)
+		builder.AddData(script)
+		sigScript, err := builder.Script()
+		if err != nil {
+			return nil, fmt.Errorf("psbt.SignedTransaction(%s, %s, %s) => %v", holder, signer, observer, err)
+		}
+		msgTx.TxIn[idx].SignatureScript = sigScript
 	}
 	return msgTx, nil
 }
 
+// the signatures with the sighash type appended for the witness
+func (raw *PartiallySignedTransaction) partialSignatures(idx int) (map[string][]byte, error) {
+	pin := raw.Inputs[idx]
+	sigs := make(map[string][]byte, len(pin.PartialSigs))
+	for _, ps := range pin.PartialSigs {
+		pub := hex.EncodeToString(ps.PubKey)
+		sig, err := CanonicalSignatureDER(ps.Signature)
+		if err != nil {
+			return nil, err
+		}
+		sigs[pub] = append(sig, byte(pin.SighashType))
+	}
+	return sigs, nil
+}
+
+// whether the signatures of the input are enough to build the witness
+func (raw *PartiallySignedTransaction) IsInputSatisfied(idx int) bool {
+	if raw.IsTaprootInput(idx) {
+		return false
+	}
+	sigs, err := raw.partialSignatures(idx)
+	if err != nil {
+		return false
+	}
+	ms, err := parseSafeMiniscript(raw.inputScript(idx))
+	if err != nil {
+		return false
+	}
+	_, satisfied := ms.satisfy(sigs, raw.UnsignedTx.TxIn[idx].Sequence)
+	return satisfied
+}
+
+// the witness stack is consumed by the leaf from the top, so the signatures
+// are pushed in the reversed order of the keys in the leaf
+func (raw *PartiallySignedTransaction) taprootWitness(idx int, holder, signer, observer string) (wire.TxWitness, error) {
+	sigs := raw.taprootSignatures(idx)
+	holderSig := sigs[hex.EncodeToString(taprootXOnlyPublicKey(holder))]
+	signerSig := sigs[hex.EncodeToString(taprootXOnlyPublicKey(signer))]
+	observerSig := sigs[hex.EncodeToString(taprootXOnlyPublicKey(observer))]
+
+	leaf := raw.taprootSpendLeaf(idx)
+	if !raw.IsRecoveryTransaction() {
+		if sig := raw.Inputs[idx].TaprootKeySpendSig; len(sig) > 0 {
+			return wire.TxWitness{sig}, nil
+		}
+		if holderSig == nil {
+			return nil, fmt.Errorf("psbt.SignedTransaction(%s, %s, %s) holder", holder, signer, observer)
+		}
+		if signerSig == nil {
+			return nil, fmt.Errorf("psbt.SignedTransaction(%s, %s, %s) signer", holder, signer, observer)
+		}
+		return wire.TxWitness{signerSig, holderSig, leaf.Script, leaf.ControlBlock}, nil
+	}
+	if observerSig == nil {
+		return nil, fmt.Errorf("psbt.SignedTransaction(%s, %s, %s) observer", holder, signer, observer)
+	}
+	if holderSig == nil && signerSig == nil {
+		return nil, fmt.Errorf("psbt.SignedTransaction(%s, %s, %s) holder&signer", holder, signer, observer)
+	}
+	return wire.TxWitness{signerSig, holderSig, observerSig, leaf.Script, leaf.ControlBlock}, nil
+}
+
+func (raw *PartiallySignedTransaction) taprootSignatures(idx int) map[string][]byte {
+	leaf := raw.taprootSpendLeaf(idx)
+	hash := txscript.NewTapLeaf(leaf.LeafVersion, leaf.Script).TapHash()
+	sigs := make(map[string][]byte, 3)
+	for _, ps := range raw.Inputs[idx].TaprootScriptSpendSig {
+		if !bytes.Equal(ps.LeafHash, hash[:]) {
+			continue
+		}
+		sig := append([]byte{}, ps.Signature...)
+		if ps.SigHash != txscript.SigHashDefault {
+			sig = append(sig, byte(ps.SigHash))
+		}
+		sigs[hex.EncodeToString(ps.XOnlyPubKey)] = sig
+	}
+	return sigs
+}
+
+func (raw *PartiallySignedTransaction) taprootSpendLeaf(idx int) *psbt.TaprootTapLeafScript {
+	recovery := raw.IsRecoveryTransaction()
+	for _, leaf := range raw.Inputs[idx].TaprootLeafScript {
+		if checkTaprootRecoveryLeaf(leaf.Script) == recovery {
+			return leaf
+		}
+	}
+	panic(idx)
+}
+
+// the key path signature is the musig2 session of the holder and signer,
+// otherwise the holder signature of the spend leaf is required
+func (raw *PartiallySignedTransaction) checkTaprootSignedByHolder(idx int) bool {
+	pin := raw.Inputs[idx]
+	if sig := pin.TaprootKeySpendSig; len(sig) > 0 && !raw.IsRecoveryTransaction() {
+		if pin.WitnessUtxo == nil || len(pin.WitnessUtxo.PkScript) != 34 || len(sig) < schnorr.SignatureSize {
+			return false
+		}
+		key, err := schnorr.ParsePubKey(pin.WitnessUtxo.PkScript[2:])
+		if err != nil {
+			return false
+		}
+		signature, err := schnorr.ParseSignature(sig[:schnorr.SignatureSize])
+		if err != nil {
+			return false
+		}
+		return signature.Verify(raw.KeySpendSigHash(idx), key)
+	}
+
+	// <HOLDER> OP_CHECKSIGVERIFY <SIGNER> OP_CHECKSIG
+	// <OBSERVER> OP_CHECKSIGVERIFY <HOLDER> OP_CHECKSIG ...
+	script, offset := raw.taprootSpendLeaf(idx).Script, 1
+	if raw.IsRecoveryTransaction() {
+		offset = 35
+	}
+	if len(script) < offset+32 || script[offset-1] != txscript.OP_DATA_32 {
+		return false
+	}
+	holder := hex.EncodeToString(script[offset : offset+32])
+	sig := raw.TaprootSignature(idx, holder)
+	if sig == nil {
+		return false
+	}
+	return VerifySignatureSchnorr(holder, raw.SigHash(idx), sig) == nil
+}
+
+func (raw *PartiallySignedTransaction) IsTaprootInput(idx int) bool {
+	return len(raw.Inputs[idx].TaprootLeafScript) > 0
+}
+
+func (raw *PartiallySignedTransaction) TaprootSignature(idx int, public string) []byte {
+	sig := raw.taprootSignatures(idx)[hex.EncodeToString(taprootXOnlyPublicKey(public))]
+	if len(sig) < schnorr.SignatureSize {
+		return nil
+	}
+	return sig[:schnorr.SignatureSize]
+}
+
+func (raw *PartiallySignedTransaction) WriteTaprootSignature(idx int, public string, sig []byte) {
+	key := taprootXOnlyPublicKey(public)
+	if key == nil || len(sig) != schnorr.SignatureSize {
+		panic(public)
+	}
+	leaf := raw.taprootSpendLeaf(idx)
+	hash := txscript.NewTapLeaf(leaf.LeafVersion, leaf.Script).TapHash()
+	pin := &raw.Inputs[idx]
+	var sigs []*psbt.TaprootScriptSpendSig
+	for _, ps := range pin.TaprootScriptSpendSig {
+		if bytes.Equal(ps.XOnlyPubKey, key) && bytes.Equal(ps.LeafHash, hash[:]) {
+			continue
+		}
+		sigs = append(sigs, ps)
+	}
+	pin.TaprootScriptSpendSig = append(sigs, &psbt.TaprootScriptSpendSig{
+		XOnlyPubKey: key,
+		LeafHash:    hash[:],
+		Signature:   sig,
+		SigHash:     pin.SighashType,
+	})
+}
+
 func MarshalWiredTransaction(msgTx *wire.MsgTx, encoding wire.MessageEncoding, chain byte) ([]byte, error) {
 	var rawBuffer bytes.Buffer
 	err := msgTx.BtcEncode(&rawBuffer, protocolVersion(chain), encoding)
@@ -164,6 +420,17 @@ func CheckTransactionPartiallySignedBy(raw, public string) bool {
 
 	for i := range psbt.Inputs {
 		pin := psbt.Inputs[i]
+		if psbt.IsTaprootInput(i) {
+			sig := psbt.TaprootSignature(i, public)
+			if sig == nil {
+				return false
+			}
+			err := VerifySignatureSchnorr(public, psbt.SigHash(i), sig)
+			if err != nil {
+				return false
+			}
+			continue
+		}
 		sigs := make(map[string][]byte, 2)
 		for _, ps := range pin.PartialSigs {
 			pub := hex.EncodeToString(ps.PubKey)
@@ -187,56 +454,146 @@ func CheckTransactionPartiallySignedBy(raw, public string) bool {
 	return len(psbt.Inputs) > 0
 }
 
+// the holders of a safe with holder policy sign the transaction together,
+// and their valid signatures must satisfy the holder policy
+func CheckTransactionSignedByHolders(raw string) bool {
+	b, _ := hex.DecodeString(raw)
+	psbt, _ := UnmarshalPartiallySignedTransaction(b)
+
+	for i := range psbt.Inputs {
+		if psbt.IsTaprootInput(i) {
+			if !psbt.checkTaprootSignedByHolder(i) {
+				return false
+			}
+			continue
+		}
+		sigs, err := psbt.partialSignatures(i)
+		if err != nil {
+			return false
+		}
+		hash := psbt.SigHash(i)
+		for pub, sig := range sigs {
+			if VerifySignatureDER(pub, hash, sig[:len(sig)-1]) != nil {
+				delete(sigs, pub)
+			}
+		}
+		ms, err := parseSafeMiniscript(psbt.inputScript(i))
+		if err != nil {
+			return false
+		}
+		_, satisfied := ms.subs[0].satisfy(sigs, psbt.UnsignedTx.TxIn[i].Sequence)
+		if !satisfied {
+			return false
+		}
+	}
+
+	return len(psbt.Inputs) > 0
+}
+
 func SpendSignedTransaction(raw string, feeInputs []*Input, accountant string, chain byte) (*wire.MsgTx, error) {
-	b, err := hex.DecodeString(raw)
+	b, err := hex.DecodeString(accountant)
 	if err != nil {
 		return nil, err
 	}
-	rtx, err := btcutil.NewTxFromBytes(b)
-	if err != nil {
-		return nil, err
+	privateKey, _ := btcec.PrivKeyFromBytes(b)
+	return SpendSignedTransactionWithSigner(raw, feeInputs, NewPrivateKeySigner(privateKey), chain)
+}
+
+func SpendSignedTransactionWithSigner(raw string, feeInputs []*Input, accountant AccountantSigner, chain byte) (*wire.MsgTx, error) {
+	signers := make([]AccountantSigner, len(feeInputs))
+	for i := range feeInputs {
+		signers[i] = accountant
 	}
-	msgTx := rtx.MsgTx()
-	mainCount := len(msgTx.TxIn)
+	return SpendSignedTransactionWithSigners(raw, feeInputs, signers, chain)
+}
 
-	b, err = hex.DecodeString(accountant)
+// the fee inputs could belong to different accountants, and each of them
+// is signed by the accountant signer at the same index
+func SpendSignedTransactionWithSigners(raw string, feeInputs []*Input, signers []AccountantSigner, chain byte) (*wire.MsgTx, error) {
+	if len(feeInputs) != len(signers) {
+		return nil, fmt.Errorf("invalid accountant signers %d %d", len(feeInputs), len(signers))
+	}
+	b, err := hex.DecodeString(raw)
 	if err != nil {
 		return nil, err
 	}
-	privateKey, publicKey := btcec.PrivKeyFromBytes(b)
-	apk, err := btcutil.NewAddressPubKey(publicKey.SerializeCompressed(), NetConfig(chain))
+	rtx, err := btcutil.NewTxFromBytes(b)
 	if err != nil {
 		return nil, err
 	}
+	msgTx := rtx.MsgTx()
+	mainCount := len(msgTx.TxIn)
 
-	scripts := make([][]byte, len(feeInputs))
-	for i := range feeInputs {
-		scripts[i] = apk.ScriptAddress()
-		feeInputs[i].Script = apk.ScriptAddress()
-	}
-	_, _, err = addInputs(msgTx, feeInputs, chain)
-	if err != nil {
-		return nil, fmt.Errorf("addInputs(fee) => %v", err)
+	for i, in := range feeInputs {
+		apk, err := btcutil.NewAddressPubKey(signers[i].PublicKey(), NetConfig(chain))
+		if err != nil {
+			return nil, err
+		}
+		in.Script = apk.ScriptAddress()
+		_, err = addInput(msgTx, in, chain)
+		if err != nil {
+			return nil, fmt.Errorf("addInput(fee) => %v", err)
+		}
 	}
 
 	for idx, in := range feeInputs {
-		script := scripts[idx]
-		idx = idx + mainCount
-		pof := txscript.NewCannedPrevOutputFetcher(in.Script, in.Satoshi)
-		tsh := txscript.NewTxSigHashes(msgTx, pof)
-		hash, err := txscript.CalcWitnessSigHash(in.Script, tsh, txscript.SigHashAll, msgTx, idx, in.Satoshi)
+		err := SignAccountantInputWithSigner(msgTx, idx+mainCount, in.Satoshi, signers[idx], chain)
 		if err != nil {
 			return nil, err
 		}
-		signature := ecdsa.Sign(privateKey, hash)
-		sig := append(signature.Serialize(), byte(txscript.SigHashAll))
-		msgTx.TxIn[idx].Witness = append(msgTx.TxIn[idx].Witness, sig)
-		msgTx.TxIn[idx].Witness = append(msgTx.TxIn[idx].Witness, script)
 	}
 
 	return msgTx, nil
 }
 
+// the accountant inputs are pay to witness public key hash, except the legacy
+// public key hash with the forkid sighash for bitcoin cash
+func SignAccountantInput(msgTx *wire.MsgTx, idx int, satoshi int64, priv *btcec.PrivateKey, chain byte) error {
+	return SignAccountantInputWithSigner(msgTx, idx, satoshi, NewPrivateKeySigner(priv), chain)
+}
+
+func SignAccountantInputWithSigner(msgTx *wire.MsgTx, idx int, satoshi int64, accountant AccountantSigner, chain byte) error {
+	pub := accountant.PublicKey()
+	hashType := txscript.SigHashAll
+	builder := txscript.NewScriptBuilder()
+	if IsSegwitChain(chain) {
+		builder.AddOp(txscript.OP_0)
+		builder.AddData(btcutil.Hash160(pub))
+	} else {
+		hashType = hashType | SigHashForkId
+		builder.AddOp(txscript.OP_DUP)
+		builder.AddOp(txscript.OP_HASH160)
+		builder.AddData(btcutil.Hash160(pub))
+		builder.AddOp(txscript.OP_EQUALVERIFY)
+		builder.AddOp(txscript.OP_CHECKSIG)
+	}
+	script, err := builder.Script()
+	if err != nil {
+		return err
+	}
+
+	pof := txscript.NewCannedPrevOutputFetcher(script, satoshi)
+	tsh := txscript.NewTxSigHashes(msgTx, pof)
+	hash, err := txscript.CalcWitnessSigHash(script, tsh, hashType, msgTx, idx, satoshi)
+	if err != nil {
+		return err
+	}
+	der, err := accountant.Sign(hash)
+	if err != nil {
+		return err
+	}
+	sig := append(der, byte(hashType))
+	if IsSegwitChain(chain) {
+		msgTx.TxIn[idx].Witness = wire.TxWitness{sig, pub}
+		return nil
+	}
+	builder = txscript.NewScriptBuilder()
+	builder.AddData(sig)
+	builder.AddData(pub)
+	msgTx.TxIn[idx].SignatureScript, err = builder.Script()
+	return err
+}
+
 func BuildPartiallySignedTransaction(mainInputs []*Input, outputs []*Output, rid []byte, chain byte) (*PartiallySignedTransaction, error) {
 	msgTx := wire.NewMsgTx(2)
 
@@ -309,7 +666,7 @@ func BuildPartiallySignedTransaction(mainInputs []*Input, outputs []*Output, rid
 	}
 	for i, in := range mainInputs {
 		address := mainAddress
-		addr, err := btcutil.DecodeAddress(address, NetConfig(chain))
+		addr, err := decodeAddress(address, chain)
 		if err != nil {
 			panic(address)
 		}
@@ -321,8 +678,28 @@ func BuildPartiallySignedTransaction(mainInputs []*Input, outputs []*Output, rid
 			Value:    in.Satoshi,
 			PkScript: pkScript,
 		})
-		pin.WitnessScript = in.Script
 		pin.SighashType = SigHashType
+		if CheckTaprootHolderSignerScript(in.Script) {
+			ts, err := parseTaprootScript(in.Script)
+			if err != nil {
+				panic(address)
+			}
+			root := ts.tree.RootNode.TapHash()
+			pin.TaprootInternalKey = schnorr.SerializePubKey(ts.internal)
+			pin.TaprootMerkleRoot = root[:]
+			for _, leaf := range []txscript.TapLeaf{ts.normal, ts.recovery} {
+				pin.TaprootLeafScript = append(pin.TaprootLeafScript, &psbt.TaprootTapLeafScript{
+					ControlBlock: ts.controlBlock(leaf),
+					Script:       leaf.Script,
+					LeafVersion:  leaf.LeafVersion,
+				})
+			}
+		} else if IsSegwitChain(chain) {
+			pin.WitnessScript = in.Script
+		} else {
+			pin.RedeemScript = in.Script
+			pin.SighashType = SigHashType | SigHashForkId
+		}
 		if !pin.IsSane() {
 			panic(address)
 		}
@@ -371,11 +748,32 @@ func addInput(tx *wire.MsgTx, in *Input, chain byte) (string, error) {
 	}
 	typ := checkScriptType(in.Script)
 	if in.RouteBackup {
-		typ = InputTypeP2WSHMultisigObserverSigner
+		switch typ {
+		case InputTypeP2TRHolderSigner:
+			typ = InputTypeP2TRObserverSigner
+		default:
+			typ = InputTypeP2WSHMultisigObserverSigner
+		}
 	}
 	switch typ {
 	case InputTypeP2WPKHAccoutant:
 		in.Script = btcutil.Hash160(in.Script)
+		if !IsSegwitChain(chain) {
+			pkh, err := btcutil.NewAddressPubKeyHash(in.Script, NetConfig(chain))
+			if err != nil {
+				return "", err
+			}
+			addr, err = encodeAddress(pkh, chain)
+			if err != nil {
+				return "", err
+			}
+			in.Script, err = txscript.PayToAddrScript(pkh)
+			if err != nil {
+				return "", err
+			}
+			txIn.Sequence = MaxTransactionSequence
+			break
+		}
 		wpkh, err := btcutil.NewAddressWitnessPubKeyHash(in.Script, NetConfig(chain))
 		if err != nil {
 			return "", err
@@ -391,21 +789,30 @@ func addInput(tx *wire.MsgTx, in *Input, chain byte) (string, error) {
 		addr = wpkh.EncodeAddress()
 		txIn.Sequence = MaxTransactionSequence
 	case InputTypeP2WSHMultisigHolderSigner:
-		msh := sha256.Sum256(in.Script)
-		mwsh, err := btcutil.NewAddressWitnessScriptHash(msh[:], NetConfig(chain))
+		addr, err = scriptHashAddress(in.Script, chain)
 		if err != nil {
 			return "", err
 		}
-		addr = mwsh.EncodeAddress()
 		txIn.Sequence = MaxTransactionSequence
 	case InputTypeP2WSHMultisigObserverSigner:
-		msh := sha256.Sum256(in.Script)
-		mwsh, err := btcutil.NewAddressWitnessScriptHash(msh[:], NetConfig(chain))
+		addr, err = scriptHashAddress(in.Script, chain)
 		if err != nil {
 			return "", err
 		}
-		addr = mwsh.EncodeAddress()
 		txIn.Sequence = in.Sequence
+	case InputTypeP2TRHolderSigner, InputTypeP2TRObserverSigner:
+		ts, err := parseTaprootScript(in.Script)
+		if err != nil {
+			return "", err
+		}
+		addr, err = ts.address(chain)
+		if err != nil {
+			return "", err
+		}
+		txIn.Sequence = MaxTransactionSequence
+		if typ == InputTypeP2TRObserverSigner {
+			txIn.Sequence = in.Sequence
+		}
 	default:
 		return "", fmt.Errorf("invalid input type %d", typ)
 	}
@@ -417,7 +824,7 @@ func addInput(tx *wire.MsgTx, in *Input, chain byte) (string, error) {
 }
 
 func addOutput(tx *wire.MsgTx, address string, satoshi int64, chain byte) (bool, error) {
-	addr, err := btcutil.DecodeAddress(address, NetConfig(chain))
+	addr, err := decodeAddress(address, chain)
 	if err != nil {
 		return false, err
 	}
@@ -437,6 +844,15 @@ func SignPartiallySignedTransaction(raw []byte, signer *secp256k1.PrivateKey) *P
 	psTx, _ := UnmarshalPartiallySignedTransaction(raw)
 	for idx := range psTx.UnsignedTx.TxIn {
 		hash := psTx.SigHash(idx)
+		if len(psTx.Inputs[idx].TaprootLeafScript) > 0 {
+			sig, err := schnorr.Sign(signer, hash)
+			if err != nil {
+				panic(err)
+			}
+			public := hex.EncodeToString(signer.PubKey().SerializeCompressed())
+			psTx.WriteTaprootSignature(idx, public, sig.Serialize())
+			continue
+		}
 		sig := ecdsa.Sign(signer, hash).Serialize()
 
 		osig := &psbt.PartialSig{
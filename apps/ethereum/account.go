@@ -60,8 +60,10 @@ func UnmarshalGnosisSafe(extra []byte) (*GnosisSafe, error) {
 }
 
 func BuildGnosisSafe(ctx context.Context, rpc, holder, signer, observer, rid string, lock time.Duration, chain byte) (*GnosisSafe, *SafeTransaction, error) {
+	chainID := GetEvmChainID(int64(chain))
 	owners, _ := GetSortedSafeOwners(holder, signer, observer)
-	safeAddress := GetSafeAccountAddress(owners, 2).Hex()
+	factory, singleton, _ := getSafeContracts(chainID)
+	safeAddress := getSafeAccountAddress(factory, singleton, owners, 2).Hex()
 	ob, err := ParseEthereumCompressedPublicKey(observer)
 	if err != nil {
 		return nil, nil, fmt.Errorf("ethereum.ParseEthereumCompressedPublicKey(%s) => %v %v", observer, ob, err)
@@ -72,7 +74,6 @@ func BuildGnosisSafe(ctx context.Context, rpc, holder, signer, observer, rid str
 	}
 	sequence := lock / time.Hour
 
-	chainID := GetEvmChainID(int64(chain))
 	t, err := CreateEnableGuardTransaction(ctx, chainID, rid, safeAddress, ob.Hex(), new(big.Int).SetUint64(uint64(sequence)))
 	logger.Printf("CreateEnableGuardTransaction(%d, %s, %s, %s, %d) => %v", chainID, rid, safeAddress, ob.Hex(), sequence, err)
 	if err != nil {
@@ -111,20 +112,29 @@ func GetSortedSafeOwners(holder, signer, observer string) ([]string, []string) {
 }
 
 func GetOrDeploySafeAccount(ctx context.Context, rpc, key string, chainId int64, owners []string, threshold int64, timelock, observerIndex int64, tx *SafeTransaction) (*common.Address, error) {
-	addr := GetSafeAccountAddress(owners, threshold)
+	signer, err := NewPrivateKeySigner(key)
+	if err != nil {
+		return nil, err
+	}
+	return GetOrDeploySafeAccountWithSigner(ctx, rpc, signer, chainId, owners, threshold, tx)
+}
+
+func GetOrDeploySafeAccountWithSigner(ctx context.Context, rpc string, signer EVMSigner, chainId int64, owners []string, threshold int64, tx *SafeTransaction) (*common.Address, error) {
+	factory, singleton, _ := getSafeContracts(chainId)
+	addr := getSafeAccountAddress(factory, singleton, owners, threshold)
 
 	isGuarded, isDeployed, err := CheckSafeAccountDeployed(rpc, addr.String())
 	if err != nil {
 		return nil, err
 	}
 	if !isDeployed {
-		err = DeploySafeAccount(ctx, rpc, key, chainId, owners, threshold)
+		err = DeploySafeAccountWithSigner(ctx, rpc, signer, chainId, owners, threshold)
 		if err != nil {
 			return nil, err
 		}
 	}
 	if !isGuarded {
-		_, err := tx.ExecTransaction(ctx, rpc, key)
+		_, err := tx.ExecTransactionWithSigner(ctx, rpc, signer)
 		if err != nil {
 			return nil, err
 		}
@@ -170,9 +180,13 @@ func CheckSafeAccountDeployed(rpc, address string) (bool, bool, error) {
 }
 
 func GetSafeAccountAddress(owners []string, threshold int64) common.Address {
+	return getSafeAccountAddress(EthereumSafeProxyFactoryAddress, EthereumSafeL2Address, owners, threshold)
+}
+
+func getSafeAccountAddress(factory, singleton string, owners []string, threshold int64) common.Address {
 	sort.Slice(owners, func(i, j int) bool { return common.HexToAddress(owners[i]).Cmp(common.HexToAddress(owners[j])) == -1 })
 
-	this, err := hex.DecodeString(EthereumSafeProxyFactoryAddress[2:])
+	this, err := hex.DecodeString(factory[2:])
 	if err != nil {
 		panic(err)
 	}
@@ -189,7 +203,7 @@ func GetSafeAccountAddress(owners []string, threshold int64) common.Address {
 	if err != nil {
 		panic(err)
 	}
-	code = append(code, packSafeArguments(EthereumSafeL2Address)...)
+	code = append(code, packSafeArguments(singleton)...)
 
 	input := []byte{0xff}
 	input = append(input, this...)
@@ -199,19 +213,28 @@ func GetSafeAccountAddress(owners []string, threshold int64) common.Address {
 }
 
 func DeploySafeAccount(ctx context.Context, rpc, key string, chainId int64, owners []string, threshold int64) error {
+	signer, err := NewPrivateKeySigner(key)
+	if err != nil {
+		return err
+	}
+	return DeploySafeAccountWithSigner(ctx, rpc, signer, chainId, owners, threshold)
+}
+
+func DeploySafeAccountWithSigner(ctx context.Context, rpc string, evm EVMSigner, chainId int64, owners []string, threshold int64) error {
 	initializer := getInitializer(owners, threshold)
 	nonce := new(big.Int)
 	nonce.SetString(predeterminedSaltNonce[2:], 16)
 
-	conn, factoryAbi, err := factoryInit(rpc)
+	factory, singleton, _ := getSafeContracts(chainId)
+	conn, factoryAbi, err := factoryInit(rpc, factory)
 	if err != nil {
 		return err
 	}
 	defer conn.Close()
 
-	signer := SignerInit(ctx, conn, key, chainId)
+	signer := TransactorInit(ctx, conn, evm, chainId)
 
-	t, err := factoryAbi.CreateProxyWithNonce(signer, common.HexToAddress(EthereumSafeL2Address), initializer, nonce)
+	t, err := factoryAbi.CreateProxyWithNonce(signer, common.HexToAddress(singleton), initializer, nonce)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,153 @@
+package ethereum
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+
+	ga "github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// LocalBundler is a stand-in for the relay bundler, it executes every relayed
+// call with its own key so tests don't need a real bundler service.
+type LocalBundler struct {
+	sync.Mutex
+	calls    []*RelayCall
+	receipts map[string]*RelayReceipt
+	prices   map[string]string
+	execute  func(ctx context.Context, call *RelayCall) (string, error)
+}
+
+func NewLocalBundler(rpc, key string, prices map[string]string) *LocalBundler {
+	b := &LocalBundler{receipts: make(map[string]*RelayReceipt), prices: prices}
+	b.execute = func(ctx context.Context, call *RelayCall) (string, error) {
+		return localBundlerExecute(ctx, rpc, key, call)
+	}
+	return b
+}
+
+func (b *LocalBundler) Calls() []*RelayCall {
+	b.Lock()
+	defer b.Unlock()
+	return append([]*RelayCall{}, b.calls...)
+}
+
+func (b *LocalBundler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Id     any               `json:"id"`
+		Method string            `json:"method"`
+		Params []json.RawMessage `json:"params"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil || len(req.Params) != 1 {
+		b.render(w, req.Id, nil, fmt.Errorf("invalid request %v", err))
+		return
+	}
+
+	switch req.Method {
+	case RelayMethodSendTransaction:
+		var call RelayCall
+		err = json.Unmarshal(req.Params[0], &call)
+		if err != nil {
+			b.render(w, req.Id, nil, err)
+			return
+		}
+		id, err := b.send(r.Context(), &call)
+		b.render(w, req.Id, id, err)
+	case RelayMethodGetGasPrices:
+		b.render(w, req.Id, b.prices, nil)
+	case RelayMethodGetReceipt:
+		var id string
+		err = json.Unmarshal(req.Params[0], &id)
+		if err != nil {
+			b.render(w, req.Id, nil, err)
+			return
+		}
+		b.Lock()
+		receipt := b.receipts[id]
+		b.Unlock()
+		b.render(w, req.Id, receipt, nil)
+	default:
+		b.render(w, req.Id, nil, fmt.Errorf("invalid method %s", req.Method))
+	}
+}
+
+func (b *LocalBundler) send(ctx context.Context, call *RelayCall) (string, error) {
+	if !common.IsHexAddress(call.To) || call.Id == "" {
+		return "", fmt.Errorf("invalid call %v", call)
+	}
+	data, err := hex.DecodeString(call.Data)
+	if err != nil {
+		return "", err
+	}
+	if NewRelayCall(call.ChainId, call.Sender, call.To, data).Id != call.Id {
+		return "", fmt.Errorf("invalid call id %v", call)
+	}
+
+	b.Lock()
+	r := b.receipts[call.Id]
+	b.Unlock()
+	if r != nil && r.Reason == "" {
+		return call.Id, nil
+	}
+
+	id := call.Id
+	receipt := &RelayReceipt{Id: id}
+	hash, err := b.execute(ctx, call)
+	if err != nil {
+		receipt.Reason = err.Error()
+	} else {
+		receipt.TransactionHash = hash
+		receipt.Success = true
+	}
+
+	b.Lock()
+	defer b.Unlock()
+	b.calls = append(b.calls, call)
+	b.receipts[id] = receipt
+	return id, nil
+}
+
+func (b *LocalBundler) render(w http.ResponseWriter, id, result any, err error) {
+	body := map[string]any{"jsonrpc": "2.0", "id": id}
+	if err != nil {
+		body["error"] = map[string]any{"code": -32000, "message": err.Error()}
+	} else {
+		body["result"] = result
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(body)
+}
+
+func localBundlerExecute(ctx context.Context, rpc, key string, call *RelayCall) (string, error) {
+	conn, err := ethclient.Dial(rpc)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	data, err := hex.DecodeString(call.Data)
+	if err != nil {
+		return "", err
+	}
+	signer := SignerInit(ctx, conn, key, call.ChainId)
+	contract := bind.NewBoundContract(common.HexToAddress(call.To), ga.ABI{}, conn, conn, conn)
+	t, err := contract.RawTransact(signer, data)
+	if err != nil {
+		return "", err
+	}
+	r, err := bind.WaitMined(ctx, conn, t)
+	if err != nil {
+		return "", err
+	}
+	if r.Status != 1 {
+		return "", fmt.Errorf("transaction %s reverted", t.Hash().Hex())
+	}
+	return t.Hash().Hex(), nil
+}
@@ -0,0 +1,200 @@
+package ethereum
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/gofrs/uuid/v5"
+)
+
+// the evm chains share the same safe implementation, so a new evm chain only
+// needs its parameters registered, and all keeper nodes must register the same
+// chains because these parameters are used in the mtg consensus
+type EvmChain struct {
+	Chain            byte   `toml:"chain"`
+	Curve            byte   `toml:"curve"`
+	Name             string `toml:"name"`
+	ChainId          int64  `toml:"evm-chain-id"`
+	MixinChainId     string `toml:"mixin-chain-id"`
+	RPC              string `toml:"rpc"`
+	FactoryAddress   string `toml:"safe-factory-address"`
+	SingletonAddress string `toml:"safe-singleton-address"`
+	GuardAddress     string `toml:"safe-guard-address"`
+	Confirmations    int64  `toml:"confirmations"`
+	Finalization     int64  `toml:"finalization"`
+	Checkpoint       int64  `toml:"checkpoint"`
+
+	// the chain does not support the eip-1559 dynamic fee transactions
+	LegacyTransaction bool `toml:"legacy-transaction"`
+}
+
+var evmChains = struct {
+	sync.RWMutex
+	m map[byte]*EvmChain
+}{m: make(map[byte]*EvmChain)}
+
+func init() {
+	for _, c := range []*EvmChain{{
+		Chain:         ChainEthereum,
+		Curve:         2,
+		Name:          "Ethereum",
+		ChainId:       1,
+		MixinChainId:  "43d61dcd-e413-450d-80b8-101d5e903357",
+		Confirmations: 1,
+		Finalization:  32,
+	}, {
+		Chain:         ChainPolygon,
+		Curve:         112,
+		Name:          "Polygon",
+		ChainId:       137,
+		MixinChainId:  "b7938396-3f94-4e0a-9179-d3440718156f",
+		Confirmations: 256,
+		Finalization:  512,
+	}, {
+		Chain:             ChainMVM,
+		Curve:             102,
+		Name:              "MVM",
+		ChainId:           73927,
+		MixinChainId:      "a0ffd769-5850-4b48-9651-d2ae44a3e64d",
+		Confirmations:     1,
+		Finalization:      3,
+		LegacyTransaction: true,
+	}} {
+		err := RegisterEvmChain(c)
+		if err != nil {
+			panic(err)
+		}
+	}
+}
+
+// the rpc and checkpoint are node configurations instead of the consensus
+// parameters, so they are not kept in the registry
+func RegisterEvmChain(c *EvmChain) error {
+	c = c.withDefaults()
+	c.RPC, c.Checkpoint = "", 0
+	err := c.validate()
+	if err != nil {
+		return err
+	}
+
+	evmChains.Lock()
+	defer evmChains.Unlock()
+	for _, o := range evmChains.m {
+		if o.Chain == c.Chain {
+			continue
+		}
+		if o.Curve == c.Curve || o.ChainId == c.ChainId || o.MixinChainId == c.MixinChainId {
+			return fmt.Errorf("RegisterEvmChain(%d) => conflict with %d", c.Chain, o.Chain)
+		}
+	}
+	old := evmChains.m[c.Chain]
+	if old != nil && *old != *c {
+		return fmt.Errorf("RegisterEvmChain(%d) => %v %v", c.Chain, old, c)
+	}
+	evmChains.m[c.Chain] = c
+	return nil
+}
+
+func GetEvmChain(chain byte) *EvmChain {
+	evmChains.RLock()
+	defer evmChains.RUnlock()
+	return evmChains.m[chain]
+}
+
+func GetEvmChainByChainId(id int64) *EvmChain {
+	evmChains.RLock()
+	defer evmChains.RUnlock()
+	for _, c := range evmChains.m {
+		if c.ChainId == id {
+			return c
+		}
+	}
+	return nil
+}
+
+func GetEvmChainByCurve(crv byte) *EvmChain {
+	evmChains.RLock()
+	defer evmChains.RUnlock()
+	for _, c := range evmChains.m {
+		if c.Curve == crv {
+			return c
+		}
+	}
+	return nil
+}
+
+func GetEvmChainByMixinChainId(id string) *EvmChain {
+	evmChains.RLock()
+	defer evmChains.RUnlock()
+	for _, c := range evmChains.m {
+		if c.MixinChainId == id {
+			return c
+		}
+	}
+	return nil
+}
+
+func ListEvmChains() []*EvmChain {
+	evmChains.RLock()
+	defer evmChains.RUnlock()
+	var chains []*EvmChain
+	for _, c := range evmChains.m {
+		chains = append(chains, c)
+	}
+	slices.SortFunc(chains, func(a, b *EvmChain) int { return int(a.Chain) - int(b.Chain) })
+	return chains
+}
+
+func IsEvmChain(chain byte) bool {
+	return GetEvmChain(chain) != nil
+}
+
+// all the official safe contracts are deployed with the same addresses in
+// the evm chains, so they are the defaults if not configured
+func (c *EvmChain) withDefaults() *EvmChain {
+	n := *c
+	if n.FactoryAddress == "" {
+		n.FactoryAddress = EthereumSafeProxyFactoryAddress
+	}
+	if n.SingletonAddress == "" {
+		n.SingletonAddress = EthereumSafeL2Address
+	}
+	if n.GuardAddress == "" {
+		n.GuardAddress = EthereumSafeGuardAddress
+	}
+	return &n
+}
+
+func (c *EvmChain) validate() error {
+	switch c.Chain {
+	case 0:
+		return fmt.Errorf("invalid evm chain %d", c.Chain)
+	}
+	// the evm curves must be normalized to the ethereum curve
+	if c.Curve != 2 && (c.Curve < 100 || c.Curve%10 != 2) {
+		return fmt.Errorf("invalid evm chain %d curve %d", c.Chain, c.Curve)
+	}
+	if c.ChainId <= 0 || c.Confirmations < 1 || c.Finalization < c.Confirmations {
+		return fmt.Errorf("invalid evm chain %d params %d %d %d", c.Chain, c.ChainId, c.Confirmations, c.Finalization)
+	}
+	id, err := uuid.FromString(c.MixinChainId)
+	if err != nil || id.String() != c.MixinChainId {
+		return fmt.Errorf("invalid evm chain %d mixin chain id %s", c.Chain, c.MixinChainId)
+	}
+	for _, a := range []string{c.FactoryAddress, c.SingletonAddress, c.GuardAddress} {
+		if !common.IsHexAddress(a) || common.HexToAddress(a).Hex() != a {
+			return fmt.Errorf("invalid evm chain %d address %s", c.Chain, a)
+		}
+	}
+	return nil
+}
+
+func getSafeContracts(chainId int64) (string, string, string) {
+	c := GetEvmChainByChainId(chainId)
+	if c == nil {
+		return EthereumSafeProxyFactoryAddress, EthereumSafeL2Address, EthereumSafeGuardAddress
+	}
+	return c.FactoryAddress, c.SingletonAddress, c.GuardAddress
+}
@@ -24,7 +24,7 @@ import (
 
 const (
 	ChainEthereum = 2
-	chainMVM      = 4
+	ChainMVM      = 4
 	ChainPolygon  = 6
 
 	ValuePrecision = 18
@@ -41,6 +41,8 @@ const (
 	TypeETHTx       = 1
 	TypeERC20Tx     = 2
 	TypeMultiSendTx = 3
+	TypeERC721Tx    = 4
+	TypeERC1155Tx   = 5
 
 	EthereumEmptyAddress                        = "0x0000000000000000000000000000000000000000"
 	EthereumSafeProxyFactoryAddress             = "0x4e1DCf7AD4e460CfD30791CCC4F9c8a4f820ec67"
@@ -73,6 +75,8 @@ type Transfer struct {
 	Sender       string
 	Receiver     string
 	Value        *big.Int
+	TokenId      *big.Int
+	TokenType    int
 }
 
 func GenerateAssetId(chain byte, assetKey string) string {
@@ -214,16 +218,11 @@ func VerifyDeposit(ctx context.Context, chain byte, rpc, hash, chainId, assetAdd
 }
 
 func CheckFinalization(num uint64, chain byte) bool {
-	switch chain {
-	case ChainEthereum:
-		return num >= 1
-	case ChainPolygon:
-		return num >= 256
-	case chainMVM:
-		return num >= 1
-	default:
+	c := GetEvmChain(chain)
+	if c == nil {
 		panic(chain)
 	}
+	return num >= uint64(c.Confirmations)
 }
 
 func ParseAmount(amount string, decimals int32) *big.Int {
@@ -244,29 +243,19 @@ func UnitAmount(amount *big.Int, decimals int32) string {
 }
 
 func GetEvmChainID(chain int64) int64 {
-	switch chain {
-	case ChainEthereum:
-		return 1
-	case ChainPolygon:
-		return 137
-	case chainMVM:
-		return 73927
-	default:
+	c := GetEvmChain(byte(chain))
+	if c == nil || int64(c.Chain) != chain {
 		panic(chain)
 	}
+	return c.ChainId
 }
 
 func GetMixinChainID(chain int64) string {
-	switch chain {
-	case ChainEthereum:
-		return "43d61dcd-e413-450d-80b8-101d5e903357"
-	case ChainPolygon:
-		return "b7938396-3f94-4e0a-9179-d3440718156f"
-	case chainMVM:
-		return "a0ffd769-5850-4b48-9651-d2ae44a3e64d"
-	default:
+	c := GetEvmChain(byte(chain))
+	if c == nil || int64(c.Chain) != chain {
 		panic(chain)
 	}
+	return c.MixinChainId
 }
 
 func FetchAsset(chain byte, rpc, address string) (*Asset, error) {
@@ -522,6 +511,10 @@ func SignerInit(ctx context.Context, conn *ethclient.Client, key string, evmChai
 		panic(err)
 	}
 
+	if c := GetEvmChainByChainId(evmChainId); c != nil && c.LegacyTransaction {
+		signer.GasPrice = suggestMaxFeePerGas(ctx, conn)
+		return signer
+	}
 	signer.GasFeeCap = suggestMaxFeePerGas(ctx, conn)
 	signer.GasTipCap = suggestMaxPriorityFeePerGas(ctx, conn)
 	return signer
@@ -561,13 +554,13 @@ func safeInit(rpc, address string) (*ethclient.Client, *abi.GnosisSafe, error) {
 	return conn, abi, nil
 }
 
-func factoryInit(rpc string) (*ethclient.Client, *abi.ProxyFactory, error) {
+func factoryInit(rpc, factory string) (*ethclient.Client, *abi.ProxyFactory, error) {
 	conn, err := ethclient.Dial(rpc)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	abi, err := abi.NewProxyFactory(common.HexToAddress(EthereumSafeProxyFactoryAddress), conn)
+	abi, err := abi.NewProxyFactory(common.HexToAddress(factory), conn)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -0,0 +1,245 @@
+package ethereum
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/MixinNetwork/safe/apps/ethereum/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// the relay bundler is a json-rpc service, which submits the safe calls with
+// its own keys, and gets the gas back from the safe refund of execTransaction,
+// i.e. the safe pays (gasUsed + baseGas) * gasPrice in the gas token to the
+// tx.origin when the refund receiver is empty
+const (
+	RelayMethodSendTransaction = "relay_sendTransaction"
+	RelayMethodGetReceipt      = "relay_getTransactionReceipt"
+	RelayMethodGetGasPrices    = "relay_getGasPrices"
+
+	RelayBaseGas         = 60000
+	RelaySafeTxGas       = 100000
+	RelayOutputSafeTxGas = 60000
+)
+
+var ErrRelayPending = errors.New("relay call pending")
+
+// RelayCall id is derived from the call, so the same call could be polled
+// again by the id without any local state
+type RelayCall struct {
+	Id      string `json:"id"`
+	ChainId int64  `json:"chainId"`
+	Sender  string `json:"sender"`
+	To      string `json:"to"`
+	Data    string `json:"data"`
+}
+
+type RelayReceipt struct {
+	Id              string `json:"id"`
+	TransactionHash string `json:"transactionHash"`
+	Success         bool   `json:"success"`
+	Reason          string `json:"reason"`
+}
+
+func NewRelayCall(chainId int64, sender, to string, data []byte) *RelayCall {
+	var b []byte
+	b = append(b, big.NewInt(chainId).Bytes()...)
+	b = append(b, common.HexToAddress(to).Bytes()...)
+	b = append(b, data...)
+	return &RelayCall{
+		Id:      hex.EncodeToString(crypto.Keccak256(b)),
+		ChainId: chainId,
+		Sender:  sender,
+		To:      to,
+		Data:    hex.EncodeToString(data),
+	}
+}
+
+// SetGasRefund makes the safe refund the relay bundler in the gas token, at
+// most RefundLimit, the gas price is the token amount per gas unit
+func (tx *SafeTransaction) SetGasRefund(id, gasToken string, gasPrice *big.Int, outputs int) {
+	safeTxGas := RelaySafeTxGas + RelayOutputSafeTxGas*int64(outputs)
+	tx.SafeTxGas = big.NewInt(safeTxGas)
+	tx.BaseGas = big.NewInt(RelayBaseGas)
+	tx.GasPrice = new(big.Int).Set(gasPrice)
+	tx.GasToken = common.HexToAddress(gasToken)
+	tx.RefundReceiver = common.HexToAddress(EthereumEmptyAddress)
+	tx.Message = tx.GetTransactionHash()
+	tx.TxHash = tx.Hash(id)
+}
+
+func (tx *SafeTransaction) RefundLimit() *big.Int {
+	gas := new(big.Int).Add(tx.SafeTxGas, tx.BaseGas)
+	return gas.Mul(gas, tx.GasPrice)
+}
+
+func (tx *SafeTransaction) ExecTransactionData() ([]byte, error) {
+	safeAbi, err := abi.GnosisSafeMetaData.GetAbi()
+	if err != nil {
+		return nil, err
+	}
+	var signature []byte
+	count := 0
+	for _, sig := range tx.Signatures {
+		if sig == nil {
+			continue
+		}
+		signature = append(signature, sig...)
+		count += 1
+	}
+	if count < 2 {
+		return nil, fmt.Errorf("SafeTransaction has insufficient signatures")
+	}
+	return safeAbi.Pack(
+		"execTransaction",
+		tx.Destination,
+		tx.Value,
+		tx.Data,
+		tx.Operation,
+		tx.SafeTxGas,
+		tx.BaseGas,
+		tx.GasPrice,
+		tx.GasToken,
+		tx.RefundReceiver,
+		signature,
+	)
+}
+
+func (tx *SafeTransaction) RelayTransaction(bundler string) (string, error) {
+	data, err := tx.ExecTransactionData()
+	if err != nil {
+		return "", err
+	}
+	call := NewRelayCall(tx.ChainID, tx.SafeAddress, tx.SafeAddress, data)
+	return RelayCallOrPoll(bundler, call)
+}
+
+func (tx *SafeTransaction) RelayReceipt(bundler string) (*RelayReceipt, error) {
+	data, err := tx.ExecTransactionData()
+	if err != nil {
+		return nil, err
+	}
+	call := NewRelayCall(tx.ChainID, tx.SafeAddress, tx.SafeAddress, data)
+	return RelayGetReceipt(bundler, call.Id)
+}
+
+// the deployment has no safe to refund, and is sponsored by the bundler
+func RelayDeploySafeAccount(bundler string, chainId int64, owners []string, threshold int64) error {
+	factory, singleton, _ := getSafeContracts(chainId)
+	factoryAbi, err := abi.ProxyFactoryMetaData.GetAbi()
+	if err != nil {
+		return err
+	}
+	nonce := new(big.Int)
+	nonce.SetString(predeterminedSaltNonce[2:], 16)
+	initializer := getInitializer(owners, threshold)
+	data, err := factoryAbi.Pack("createProxyWithNonce", common.HexToAddress(singleton), initializer, nonce)
+	if err != nil {
+		return err
+	}
+	addr := getSafeAccountAddress(factory, singleton, owners, threshold)
+	call := NewRelayCall(chainId, addr.Hex(), factory, data)
+	_, err = RelayCallOrPoll(bundler, call)
+	return err
+}
+
+func RelaySendCall(bundler string, call *RelayCall) error {
+	res, err := callEthereumRPC(bundler, RelayMethodSendTransaction, []any{call})
+	if err != nil {
+		return err
+	}
+	var id string
+	err = json.Unmarshal(res, &id)
+	if err != nil || id != call.Id {
+		return fmt.Errorf("RelaySendCall(%v) => %s %v", call, string(res), err)
+	}
+	return nil
+}
+
+func RelayGetReceipt(bundler, id string) (*RelayReceipt, error) {
+	res, err := callEthereumRPC(bundler, RelayMethodGetReceipt, []any{id})
+	if err != nil {
+		return nil, err
+	}
+	var r *RelayReceipt
+	err = json.Unmarshal(res, &r)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// RelayGetGasPrices returns the gas prices in the token units accepted by
+// the bundler, keyed by the token address
+func RelayGetGasPrices(bundler string, chainId int64) (map[string]*big.Int, error) {
+	res, err := callEthereumRPC(bundler, RelayMethodGetGasPrices, []any{chainId})
+	if err != nil {
+		return nil, err
+	}
+	var prices map[string]string
+	err = json.Unmarshal(res, &prices)
+	if err != nil {
+		return nil, err
+	}
+	gpm := make(map[string]*big.Int)
+	for token, p := range prices {
+		price, ok := new(big.Int).SetString(p, 10)
+		if !ok || price.Sign() <= 0 || !common.IsHexAddress(token) {
+			return nil, fmt.Errorf("RelayGetGasPrices(%d) => %s %s", chainId, token, p)
+		}
+		gpm[common.HexToAddress(token).Hex()] = price
+	}
+	return gpm, nil
+}
+
+// RelayCallOrPoll never waits for the receipt, it submits the call when the
+// bundler doesn't know it or the call failed, and returns ErrRelayPending
+// until the bundler has the receipt, so the caller should poll it later
+func RelayCallOrPoll(bundler string, call *RelayCall) (string, error) {
+	r, err := RelayGetReceipt(bundler, call.Id)
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case r == nil:
+	case r.Success:
+		return r.TransactionHash, nil
+	case r.TransactionHash == "" && r.Reason == "":
+		return "", ErrRelayPending
+	}
+	err = RelaySendCall(bundler, call)
+	if err != nil {
+		return "", err
+	}
+	if r != nil {
+		return "", fmt.Errorf("RelayCallOrPoll(%s) => %s", call.Id, r.Reason)
+	}
+	return "", ErrRelayPending
+}
+
+func GetOrRelaySafeAccount(rpc, bundler string, chainId int64, owners []string, threshold int64, tx *SafeTransaction) (*common.Address, error) {
+	factory, singleton, _ := getSafeContracts(chainId)
+	addr := getSafeAccountAddress(factory, singleton, owners, threshold)
+
+	isGuarded, isDeployed, err := CheckSafeAccountDeployed(rpc, addr.String())
+	if err != nil {
+		return nil, err
+	}
+	if !isDeployed {
+		err = RelayDeploySafeAccount(bundler, chainId, owners, threshold)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if !isGuarded {
+		_, err := tx.RelayTransaction(bundler)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &addr, nil
+}
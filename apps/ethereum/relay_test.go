@@ -0,0 +1,99 @@
+package ethereum
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/MixinNetwork/safe/apps/ethereum/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testRelaySafeAddress = "0x0385B11Cfe2C529DE68E045C9E7708BA1a446432"
+	testRelayReceiver    = "0x9d04735aaEB73535672200950fA77C2dFC86eB21"
+	testRelayToken       = "0xdAC17F958D2ee523a2206206994597C13D831ec7"
+)
+
+func TestRelayTransaction(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+
+	bundler := NewLocalBundler("", "", map[string]string{testRelayToken: "25"})
+	bundler.execute = func(ctx context.Context, call *RelayCall) (string, error) {
+		if call.To == EthereumSafeProxyFactoryAddress {
+			return "", fmt.Errorf("reverted")
+		}
+		return "0x" + hex.EncodeToString(common.LeftPadBytes(big.NewInt(int64(len(call.Data))).Bytes(), 32)), nil
+	}
+	server := httptest.NewServer(bundler)
+	defer server.Close()
+
+	prices, err := RelayGetGasPrices(server.URL, 1)
+	require.Nil(err)
+	require.Len(prices, 1)
+	require.Equal(big.NewInt(25), prices[testRelayToken])
+
+	id := "b0a7b8ed-e4f6-4a5d-9c3e-c2e0d3ab8f21"
+	tx, err := CreateTransaction(ctx, TypeERC20Tx, 1, id, testRelaySafeAddress, testRelayReceiver, testRelayToken, "1000000", big.NewInt(1))
+	require.Nil(err)
+	require.Equal(int64(0), tx.RefundLimit().Int64())
+	hash, message := tx.TxHash, tx.Message
+	tx.SetGasRefund(id, testRelayToken, prices[testRelayToken], 1)
+	require.NotEqual(hash, tx.TxHash)
+	require.NotEqual(message, tx.Message)
+	require.Equal(int64((RelaySafeTxGas+RelayOutputSafeTxGas+RelayBaseGas)*25), tx.RefundLimit().Int64())
+	st, err := UnmarshalSafeTransaction(tx.Marshal())
+	require.Nil(err)
+	require.Equal(tx.SafeTxGas, st.SafeTxGas)
+	require.Equal(tx.BaseGas, st.BaseGas)
+	require.Equal(tx.GasPrice, st.GasPrice)
+	require.Equal(testRelayToken, st.GasToken.Hex())
+	require.Equal(EthereumEmptyAddress, st.RefundReceiver.Hex())
+	require.Equal(tx.Message, st.GetTransactionHash())
+
+	_, err = tx.RelayTransaction(server.URL)
+	require.NotNil(err)
+	require.Len(bundler.Calls(), 0)
+
+	tx.Signatures[0] = make([]byte, 65)
+	tx.Signatures[2] = make([]byte, 65)
+	_, err = tx.RelayTransaction(server.URL)
+	require.Equal(ErrRelayPending, err)
+	hash, err = tx.RelayTransaction(server.URL)
+	require.Nil(err)
+	require.Len(hash, 66)
+	calls := bundler.Calls()
+	require.Len(calls, 1)
+	require.Equal(testRelaySafeAddress, calls[0].To)
+	require.Equal(int64(1), calls[0].ChainId)
+	data, err := tx.ExecTransactionData()
+	require.Nil(err)
+	require.Equal(hex.EncodeToString(data), calls[0].Data)
+	require.Equal("6a761202", calls[0].Data[:8])
+
+	// the safe refunds the bundler in the moved token
+	safeAbi, err := abi.GnosisSafeMetaData.GetAbi()
+	require.Nil(err)
+	args, err := safeAbi.Methods["execTransaction"].Inputs.Unpack(data[4:])
+	require.Nil(err)
+	require.Equal(tx.GasPrice, args[6])
+	require.Equal(common.HexToAddress(testRelayToken), args[7])
+	require.Equal(common.HexToAddress(EthereumEmptyAddress), args[8])
+
+	owners := []string{testRelaySafeAddress, testRelayReceiver, testRelayToken}
+	err = RelayDeploySafeAccount(server.URL, 1, owners, 2)
+	require.Equal(ErrRelayPending, err)
+	err = RelayDeploySafeAccount(server.URL, 1, owners, 2)
+	require.NotNil(err)
+	require.Contains(err.Error(), "reverted")
+	calls = bundler.Calls()
+	require.Len(calls, 3)
+	require.Equal(EthereumSafeProxyFactoryAddress, calls[1].To)
+	require.Equal(GetSafeAccountAddress(owners, 2).Hex(), calls[1].Sender)
+	require.Equal(calls[1], calls[2])
+}
@@ -18,6 +18,7 @@ import (
 	"github.com/ethereum/go-ethereum"
 	ga "github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethclient"
 )
@@ -292,10 +293,37 @@ func GetERC20TransferLogFromBlock(ctx context.Context, rpc string, chain, height
 	}
 	logTransferSig := []byte("Transfer(address,address,uint256)")
 	logTransferSigHash := crypto.Keccak256Hash(logTransferSig)
+	logTransferSingleSigHash := crypto.Keccak256Hash([]byte("TransferSingle(address,address,address,uint256,uint256)"))
+	logTransferBatchSigHash := crypto.Keccak256Hash([]byte("TransferBatch(address,address,address,uint256[],uint256[])"))
 
 	ts := []*Transfer{}
 	for _, vLog := range logs {
 		switch {
+		case len(vLog.Topics) == 4 && vLog.Topics[0] == logTransferSigHash && len(vLog.Data) == 0:
+			// erc721 has the token id as the third indexed topic
+			tokenId := new(big.Int).SetBytes(vLog.Topics[3].Bytes())
+			t := buildNFTTransfer(chain, vLog, TypeERC721Tx, 1, 2, 0, tokenId, big.NewInt(1))
+			ts = append(ts, t)
+		case len(vLog.Topics) == 4 && vLog.Topics[0] == logTransferSingleSigHash && len(vLog.Data) == 64:
+			tokenId := new(big.Int).SetBytes(vLog.Data[:32])
+			value := new(big.Int).SetBytes(vLog.Data[32:])
+			if value.Sign() <= 0 {
+				continue
+			}
+			t := buildNFTTransfer(chain, vLog, TypeERC1155Tx, 2, 3, 0, tokenId, value)
+			ts = append(ts, t)
+		case len(vLog.Topics) == 4 && vLog.Topics[0] == logTransferBatchSigHash:
+			ids, values, err := unpackERC1155TransferBatch(vLog.Data)
+			if err != nil {
+				return nil, err
+			}
+			for i, id := range ids {
+				if values[i].Sign() <= 0 {
+					continue
+				}
+				t := buildNFTTransfer(chain, vLog, TypeERC1155Tx, 2, 3, i, id, values[i])
+				ts = append(ts, t)
+			}
 		case len(vLog.Topics) == 3 && vLog.Topics[0].Hex() == logTransferSigHash.Hex() && len(vLog.Data) == 32:
 			var event abi.AssetTransfer
 			err = contractAbi.UnpackIntoInterface(&event, "Transfer", vLog.Data)
@@ -320,6 +348,43 @@ func GetERC20TransferLogFromBlock(ctx context.Context, rpc string, chain, height
 	return ts, nil
 }
 
+func buildNFTTransfer(chain int64, vLog types.Log, typ, from, to, i int, tokenId, value *big.Int) *Transfer {
+	tokenAddress := vLog.Address.Hex()
+	return &Transfer{
+		Hash: vLog.TxHash.Hex(),
+		// the batch items after the first one are shifted out of the log index range
+		Index:        int64(vLog.Index) + int64(math.MaxInt32) + int64(i)<<32,
+		TokenAddress: tokenAddress,
+		AssetId:      GenerateAssetId(byte(chain), tokenAddress),
+		Sender:       common.HexToAddress(vLog.Topics[from].Hex()).Hex(),
+		Receiver:     common.HexToAddress(vLog.Topics[to].Hex()).Hex(),
+		Value:        value,
+		TokenId:      tokenId,
+		TokenType:    typ,
+	}
+}
+
+func unpackERC1155TransferBatch(data []byte) ([]*big.Int, []*big.Int, error) {
+	uint256Array, err := ga.NewType("uint256[]", "", nil)
+	if err != nil {
+		panic(err)
+	}
+	args := ga.Arguments{{Type: uint256Array}, {Type: uint256Array}}
+	vals, err := args.Unpack(data)
+	if err != nil || len(vals) != 2 {
+		return nil, nil, fmt.Errorf("invalid erc1155 batch data %x %v", data, err)
+	}
+	ids, ok := vals[0].([]*big.Int)
+	if !ok {
+		return nil, nil, fmt.Errorf("invalid erc1155 batch ids %x", data)
+	}
+	values, ok := vals[1].([]*big.Int)
+	if !ok || len(values) != len(ids) {
+		return nil, nil, fmt.Errorf("invalid erc1155 batch values %x", data)
+	}
+	return ids, values, nil
+}
+
 func callEthereumRPCUntilSufficient(rpc, method string, params []any) ([]byte, error) {
 	for {
 		res, err := callEthereumRPC(rpc, method, params)
@@ -0,0 +1,101 @@
+package ethereum
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// EVMSigner signs the transaction digests of the account paying the gas,
+// so the private key could be kept in a key vault instead of the config
+type EVMSigner interface {
+	Address() common.Address
+	SignDigest(ctx context.Context, digest []byte) ([]byte, error)
+}
+
+type privateKeySigner struct {
+	priv *ecdsa.PrivateKey
+}
+
+func NewPrivateKeySigner(key string) (EVMSigner, error) {
+	priv, err := crypto.HexToECDSA(key)
+	if err != nil {
+		return nil, err
+	}
+	return &privateKeySigner{priv: priv}, nil
+}
+
+func (s *privateKeySigner) Address() common.Address {
+	return crypto.PubkeyToAddress(s.priv.PublicKey)
+}
+
+func (s *privateKeySigner) SignDigest(ctx context.Context, digest []byte) ([]byte, error) {
+	return crypto.Sign(digest, s.priv)
+}
+
+// RecoverableSignature converts a low s r||s signature to the r||s||v format,
+// by finding the recovery id that matches the public key
+func RecoverableSignature(digest, sig []byte, pub []byte) ([]byte, error) {
+	if len(sig) != 64 {
+		return nil, fmt.Errorf("invalid signature size %d", len(sig))
+	}
+	for v := byte(0); v < 2; v++ {
+		rsv := append(append([]byte{}, sig...), v)
+		recovered, err := crypto.Ecrecover(digest, rsv)
+		if err != nil {
+			continue
+		}
+		key, err := crypto.UnmarshalPubkey(recovered)
+		if err != nil {
+			continue
+		}
+		if common.Bytes2Hex(crypto.CompressPubkey(key)) == common.Bytes2Hex(pub) {
+			return rsv, nil
+		}
+	}
+	return nil, fmt.Errorf("unrecoverable signature %x", sig)
+}
+
+func TransactorInit(ctx context.Context, conn *ethclient.Client, signer EVMSigner, evmChainId int64) *bind.TransactOpts {
+	chainId := new(big.Int).SetInt64(evmChainId)
+	latest := types.LatestSignerForChainID(chainId)
+	opts := &bind.TransactOpts{
+		From: signer.Address(),
+		Signer: func(address common.Address, tx *types.Transaction) (*types.Transaction, error) {
+			if address != signer.Address() {
+				return nil, bind.ErrNotAuthorized
+			}
+			sig, err := signer.SignDigest(ctx, latest.Hash(tx).Bytes())
+			if err != nil {
+				return nil, err
+			}
+			return tx.WithSignature(latest, sig)
+		},
+		Context: ctx,
+	}
+
+	if c := GetEvmChainByChainId(evmChainId); c != nil && c.LegacyTransaction {
+		opts.GasPrice = suggestMaxFeePerGas(ctx, conn)
+		return opts
+	}
+	opts.GasFeeCap = suggestMaxFeePerGas(ctx, conn)
+	opts.GasTipCap = suggestMaxPriorityFeePerGas(ctx, conn)
+	return opts
+}
+
+func FetchBalance(ctx context.Context, rpc string, addr common.Address) (*big.Int, error) {
+	client, err := ethclient.Dial(rpc)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	return client.BalanceAt(ctx, addr, nil)
+}
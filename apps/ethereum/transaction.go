@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/hex"
 	"fmt"
+	"io"
 	"math/big"
 	"strings"
 
@@ -39,16 +40,52 @@ type SafeTransaction struct {
 	Signatures     [][]byte
 }
 
+type ContractCall struct {
+	Target   string
+	Value    *big.Int
+	Selector string
+	Data     []byte
+}
+
+// the abi of some well known methods to decode the calldata for the approvers
+const knownCallABIJSON = `[
+	{"type":"function","name":"approve","inputs":[{"name":"spender","type":"address"},{"name":"amount","type":"uint256"}]},
+	{"type":"function","name":"transferFrom","inputs":[{"name":"from","type":"address"},{"name":"to","type":"address"},{"name":"amount","type":"uint256"}]},
+	{"type":"function","name":"deposit","inputs":[]},
+	{"type":"function","name":"withdraw","inputs":[{"name":"amount","type":"uint256"}]},
+	{"type":"function","name":"stake","inputs":[{"name":"amount","type":"uint256"}]},
+	{"type":"function","name":"unstake","inputs":[{"name":"amount","type":"uint256"}]},
+	{"type":"function","name":"getReward","inputs":[]},
+	{"type":"function","name":"castVote","inputs":[{"name":"proposalId","type":"uint256"},{"name":"support","type":"bool"}]},
+	{"type":"function","name":"castVote","inputs":[{"name":"proposalId","type":"uint256"},{"name":"support","type":"uint8"}]},
+	{"type":"function","name":"delegate","inputs":[{"name":"delegatee","type":"address"}]},
+	{"type":"function","name":"setApprovalForAll","inputs":[{"name":"operator","type":"address"},{"name":"approved","type":"bool"}]}
+]`
+
+var knownCallABI ga.ABI
+
+func init() {
+	parsed, err := ga.JSON(strings.NewReader(knownCallABIJSON))
+	if err != nil {
+		panic(err)
+	}
+	knownCallABI = parsed
+}
+
 type Output struct {
 	TokenAddress string
 	Destination  string
 	Amount       *big.Int
+	TokenId      *big.Int
+	TokenType    int
 }
 
 func CreateTransactionFromOutputs(ctx context.Context, typ int, chainId int64, id, safeAddress string, outputs []*Output, nonce *big.Int) (*SafeTransaction, error) {
 	switch {
 	case len(outputs) > 1 && typ == TypeMultiSendTx:
 		return CreateMultiSendTransaction(ctx, chainId, id, safeAddress, outputs, nonce)
+	case len(outputs) == 1 && (typ == TypeERC721Tx || typ == TypeERC1155Tx):
+		return CreateNFTTransaction(ctx, typ, chainId, id, safeAddress, outputs[0], nonce)
 	case len(outputs) == 1:
 		o := outputs[0]
 		return CreateTransaction(ctx, typ, chainId, id, safeAddress, o.Destination, o.TokenAddress, o.Amount.String(), nonce)
@@ -97,6 +134,68 @@ func CreateTransaction(ctx context.Context, typ int, chainID int64, id, safeAddr
 	return tx, nil
 }
 
+func CreateNFTTransaction(ctx context.Context, typ int, chainID int64, id, safeAddress string, o *Output, nonce *big.Int) (*SafeTransaction, error) {
+	if nonce == nil || o.TokenId == nil || o.TokenType != typ {
+		return nil, fmt.Errorf("Invalid ethereum nft transaction nonce or token %s %v", nonce, o)
+	}
+	norm := NormalizeAddress(o.TokenAddress)
+	if norm == EthereumEmptyAddress {
+		return nil, fmt.Errorf("invalid NFT address %s for type %d", o.TokenAddress, typ)
+	}
+	if typ == TypeERC721Tx && o.Amount.Cmp(big.NewInt(1)) != 0 {
+		return nil, fmt.Errorf("invalid ERC721 amount %s", o.Amount)
+	}
+	tx := &SafeTransaction{
+		ChainID:        chainID,
+		SafeAddress:    safeAddress,
+		Destination:    common.HexToAddress(norm),
+		Value:          big.NewInt(0),
+		Data:           buildNFTTxData(safeAddress, o),
+		Operation:      operationTypeCall,
+		SafeTxGas:      big.NewInt(0),
+		BaseGas:        big.NewInt(0),
+		GasPrice:       big.NewInt(0),
+		GasToken:       common.HexToAddress(EthereumEmptyAddress),
+		RefundReceiver: common.HexToAddress(EthereumEmptyAddress),
+		Nonce:          nonce,
+		Signatures:     make([][]byte, 3),
+	}
+	tx.Message = tx.GetTransactionHash()
+	tx.TxHash = tx.Hash(id)
+	return tx, nil
+}
+
+func CreateContractCallTransaction(ctx context.Context, chainID int64, id, safeAddress, target string, value *big.Int, data []byte, nonce *big.Int) (*SafeTransaction, error) {
+	if nonce == nil || value == nil {
+		return nil, fmt.Errorf("Invalid ethereum transaction nonce or value %s %s", nonce, value)
+	}
+	norm := NormalizeAddress(target)
+	if norm == EthereumEmptyAddress || norm == safeAddress {
+		return nil, fmt.Errorf("invalid contract call target %s", target)
+	}
+	tx := &SafeTransaction{
+		ChainID:        chainID,
+		SafeAddress:    safeAddress,
+		Destination:    common.HexToAddress(norm),
+		Value:          value,
+		Data:           data,
+		Operation:      operationTypeCall,
+		SafeTxGas:      big.NewInt(0),
+		BaseGas:        big.NewInt(0),
+		GasPrice:       big.NewInt(0),
+		GasToken:       common.HexToAddress(EthereumEmptyAddress),
+		RefundReceiver: common.HexToAddress(EthereumEmptyAddress),
+		Nonce:          nonce,
+		Signatures:     make([][]byte, 3),
+	}
+	if tx.ExtractContractCall() == nil {
+		return nil, fmt.Errorf("invalid contract call data %x", data)
+	}
+	tx.Message = tx.GetTransactionHash()
+	tx.TxHash = tx.Hash(id)
+	return tx, nil
+}
+
 func CreateMultiSendTransaction(ctx context.Context, chainID int64, id, safeAddress string, outputs []*Output, nonce *big.Int) (*SafeTransaction, error) {
 	if nonce == nil {
 		return nil, fmt.Errorf("Invalid ethereum transaction nonce")
@@ -106,7 +205,7 @@ func CreateMultiSendTransaction(ctx context.Context, chainID int64, id, safeAddr
 		SafeAddress:    safeAddress,
 		Destination:    common.HexToAddress(EthereumMultiSendAddress),
 		Value:          big.NewInt(0),
-		Data:           buildMultiSendData(outputs),
+		Data:           buildMultiSendData(safeAddress, outputs),
 		Operation:      operationTypeDelegateCall,
 		SafeTxGas:      big.NewInt(0),
 		BaseGas:        big.NewInt(0),
@@ -172,6 +271,15 @@ func (tx *SafeTransaction) Marshal() []byte {
 	}
 	sigs := strings.Join(signatures, ",")
 	bitcoin.WriteBytes(enc, []byte(sigs))
+
+	// the gas refund is appended only when set, to keep the old encoding
+	if tx.GasPrice.Sign() > 0 {
+		bitcoin.WriteBytes(enc, tx.SafeTxGas.Bytes())
+		bitcoin.WriteBytes(enc, tx.BaseGas.Bytes())
+		bitcoin.WriteBytes(enc, tx.GasPrice.Bytes())
+		bitcoin.WriteBytes(enc, tx.GasToken.Bytes())
+		bitcoin.WriteBytes(enc, tx.RefundReceiver.Bytes())
+	}
 	return enc.Bytes()
 }
 
@@ -231,6 +339,17 @@ func UnmarshalSafeTransaction(b []byte) (*SafeTransaction, error) {
 		signatures[i] = sig
 	}
 
+	refund := make([][]byte, 5)
+	for i := range refund {
+		b, err := dec.ReadBytes()
+		if i == 0 && err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		refund[i] = b
+	}
+
 	return &SafeTransaction{
 		TxHash:         string(hash),
 		ChainID:        int64(chainID),
@@ -239,11 +358,11 @@ func UnmarshalSafeTransaction(b []byte) (*SafeTransaction, error) {
 		Value:          new(big.Int).SetBytes(valueByte),
 		Data:           data,
 		Operation:      uint8(operation),
-		SafeTxGas:      new(big.Int).SetInt64(0),
-		BaseGas:        new(big.Int).SetInt64(0),
-		GasPrice:       new(big.Int).SetInt64(0),
-		GasToken:       common.HexToAddress(EthereumEmptyAddress),
-		RefundReceiver: common.HexToAddress(EthereumEmptyAddress),
+		SafeTxGas:      new(big.Int).SetBytes(refund[0]),
+		BaseGas:        new(big.Int).SetBytes(refund[1]),
+		GasPrice:       new(big.Int).SetBytes(refund[2]),
+		GasToken:       common.BytesToAddress(refund[3]),
+		RefundReceiver: common.BytesToAddress(refund[4]),
 		Nonce:          new(big.Int).SetBytes(nonce),
 		Message:        msg,
 		Signatures:     signatures,
@@ -285,12 +404,20 @@ func (tx *SafeTransaction) ValidTransaction(rpc string) (bool, error) {
 }
 
 func (tx *SafeTransaction) ExecTransaction(ctx context.Context, rpc, key string) (string, error) {
+	signer, err := NewPrivateKeySigner(key)
+	if err != nil {
+		return "", err
+	}
+	return tx.ExecTransactionWithSigner(ctx, rpc, signer)
+}
+
+func (tx *SafeTransaction) ExecTransactionWithSigner(ctx context.Context, rpc string, evm EVMSigner) (string, error) {
 	conn, safeAbi, err := safeInit(rpc, tx.SafeAddress)
 	if err != nil {
 		return "", err
 	}
 	defer conn.Close()
-	signer := SignerInit(ctx, conn, key, tx.ChainID)
+	signer := TransactorInit(ctx, conn, evm, tx.ChainID)
 
 	var signature []byte
 	count := 0
@@ -328,22 +455,28 @@ func (tx *SafeTransaction) ExecTransaction(ctx context.Context, rpc, key string)
 	return t.Hash().Hex(), nil
 }
 
-func (tx *SafeTransaction) ExtractOutputs() []*Output {
+func (tx *SafeTransaction) ExtractOutputs() ([]*Output, error) {
 	outputs, err := tx.parseMultiSendData()
 	if err == nil {
-		return outputs
+		return outputs, nil
 	}
 	switch {
-	case len(tx.Data) == 0:
+	case len(tx.Data) == 0 || tx.ExtractContractCall() != nil:
 		return []*Output{{
 			TokenAddress: EthereumEmptyAddress,
 			Destination:  tx.Destination.Hex(),
 			Amount:       tx.Value,
-		}}
+		}}, nil
+	case len(tx.Data) >= 4 && hex.EncodeToString(tx.Data[0:4]) != "a9059cbb":
+		o := parseNFTTxData(tx.Destination.Hex(), tx.Data)
+		if o == nil {
+			return nil, fmt.Errorf("invalid safe transaction data %x", tx.Data)
+		}
+		return []*Output{o}, nil
 	default:
 		method := hex.EncodeToString(tx.Data[0:4])
 		if method != "a9059cbb" || len(tx.Data) != 68 {
-			panic("invalid safe transaction data")
+			return nil, fmt.Errorf("invalid safe transaction data %x", tx.Data)
 		}
 		destination := tx.Data[4:36]
 		value := tx.Data[36:68]
@@ -351,8 +484,61 @@ func (tx *SafeTransaction) ExtractOutputs() []*Output {
 			TokenAddress: tx.Destination.Hex(),
 			Destination:  common.BytesToAddress(destination).Hex(),
 			Amount:       new(big.Int).SetBytes(value),
-		}}
+		}}, nil
+	}
+}
+
+// the transfers and multisend are not contract calls, though
+// they are also calls to the token contracts
+func (tx *SafeTransaction) ExtractContractCall() *ContractCall {
+	if tx.Operation != operationTypeCall || len(tx.Data) < 4 {
+		return nil
+	}
+	method := hex.EncodeToString(tx.Data[0:4])
+	if method == "a9059cbb" && len(tx.Data) == 68 {
+		return nil
+	}
+	if parseNFTTxData(tx.Destination.Hex(), tx.Data) != nil {
+		return nil
+	}
+	return &ContractCall{
+		Target:   tx.Destination.Hex(),
+		Value:    tx.Value,
+		Selector: method,
+		Data:     tx.Data,
+	}
+}
+
+func (c *ContractCall) Method() string {
+	method, err := knownCallABI.MethodById(c.Data[:4])
+	if err != nil {
+		return ""
+	}
+	return method.Sig
+}
+
+// the arguments of the known method are decoded by its abi, and the calldata
+// of the other methods is split into 32 bytes words
+func (c *ContractCall) Arguments() ([]string, error) {
+	method, err := knownCallABI.MethodById(c.Data[:4])
+	if err != nil {
+		var args []string
+		for data := c.Data[4:]; len(data) > 0; {
+			n := min(32, len(data))
+			args = append(args, hex.EncodeToString(data[:n]))
+			data = data[n:]
+		}
+		return args, nil
+	}
+	values, err := method.Inputs.Unpack(c.Data[4:])
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s calldata %x: %v", method.Sig, c.Data, err)
+	}
+	args := make([]string, len(values))
+	for i, v := range values {
+		args[i] = fmt.Sprint(v)
 	}
+	return args, nil
 }
 
 func (tx *SafeTransaction) GetTransactionHash() []byte {
@@ -425,6 +611,14 @@ func (tx *SafeTransaction) parseMultiSendData() ([]*Output, error) {
 			default:
 				return nil, fmt.Errorf("invalid meta tx data: %x", metaData)
 			}
+		case int(dataLen) == 100 || int(dataLen) == 196:
+			metaData := multiSendData[offset : offset+int(dataLen)]
+			nft := parseNFTTxData(o.Destination, metaData)
+			if nft == nil {
+				return nil, fmt.Errorf("invalid meta tx data: %x", metaData)
+			}
+			o = nft
+			offset += int(dataLen)
 		default:
 			offset += int(dataLen)
 		}
@@ -434,13 +628,14 @@ func (tx *SafeTransaction) parseMultiSendData() ([]*Output, error) {
 }
 
 func (tx *SafeTransaction) buildEnableGuradData(observer string, timelock *big.Int) []byte {
+	_, _, guard := getSafeContracts(tx.ChainID)
 	safeAbi, err := ga.JSON(strings.NewReader(abi.GnosisSafeMetaData.ABI))
 	if err != nil {
 		panic(err)
 	}
 	args, err := safeAbi.Pack(
 		"setGuard",
-		common.HexToAddress(EthereumSafeGuardAddress),
+		common.HexToAddress(guard),
 	)
 	if err != nil {
 		panic(err)
@@ -459,7 +654,7 @@ func (tx *SafeTransaction) buildEnableGuradData(observer string, timelock *big.I
 	if err != nil {
 		panic(err)
 	}
-	guardSafeData := buildMetaTxData(common.HexToAddress(EthereumSafeGuardAddress), big.NewInt(0), args)
+	guardSafeData := buildMetaTxData(common.HexToAddress(guard), big.NewInt(0), args)
 
 	data := []byte{}
 	data = append(data, setGuardData...)
@@ -494,12 +689,65 @@ func buildERC20TxData(receiver string, amount *big.Int) []byte {
 	return data
 }
 
-func buildMultiSendData(outputs []*Output) []byte {
+func buildNFTTxData(from string, o *Output) []byte {
+	var fnSignature []byte
+	switch o.TokenType {
+	case TypeERC721Tx:
+		fnSignature = []byte("safeTransferFrom(address,address,uint256)")
+	case TypeERC1155Tx:
+		fnSignature = []byte("safeTransferFrom(address,address,uint256,uint256,bytes)")
+	default:
+		panic(o.TokenType)
+	}
+	hash := sha3.NewLegacyKeccak256()
+	hash.Write(fnSignature)
+	methodID := hash.Sum(nil)[:4]
+
+	var data []byte
+	data = append(data, methodID...)
+	data = append(data, common.LeftPadBytes(common.HexToAddress(from).Bytes(), 32)...)
+	data = append(data, common.LeftPadBytes(common.HexToAddress(o.Destination).Bytes(), 32)...)
+	data = append(data, common.LeftPadBytes(o.TokenId.Bytes(), 32)...)
+	if o.TokenType == TypeERC1155Tx {
+		data = append(data, common.LeftPadBytes(o.Amount.Bytes(), 32)...)
+		// offset and length of the empty bytes data
+		data = append(data, common.LeftPadBytes([]byte{0xa0}, 32)...)
+		data = append(data, make([]byte, 32)...)
+	}
+	return data
+}
+
+func parseNFTTxData(tokenAddress string, data []byte) *Output {
+	if len(data) < 4 {
+		return nil
+	}
+	o := &Output{TokenAddress: tokenAddress}
+	switch method := hex.EncodeToString(data[0:4]); {
+	case method == "42842e0e" && len(data) == 100: // erc721 safeTransferFrom
+		o.TokenType = TypeERC721Tx
+		o.Amount = big.NewInt(1)
+	case method == "f242432a" && len(data) == 196: // erc1155 safeTransferFrom
+		o.TokenType = TypeERC1155Tx
+		o.Amount = new(big.Int).SetBytes(data[100:132])
+	default:
+		return nil
+	}
+	o.Destination = common.BytesToAddress(data[36:68]).Hex()
+	o.TokenId = new(big.Int).SetBytes(data[68:100])
+	return o
+}
+
+func buildMultiSendData(safeAddress string, outputs []*Output) []byte {
 	metaTxsData := []byte{}
 	for _, o := range outputs {
 		destination, amount, data := o.Destination, o.Amount, []byte{}
 		tokenAddress := NormalizeAddress(o.TokenAddress)
-		if tokenAddress != EthereumEmptyAddress {
+		switch {
+		case o.TokenId != nil:
+			destination = tokenAddress
+			amount = big.NewInt(0)
+			data = buildNFTTxData(safeAddress, o)
+		case tokenAddress != EthereumEmptyAddress:
 			destination = tokenAddress
 			amount = big.NewInt(0)
 			data = buildERC20TxData(o.Destination, o.Amount)
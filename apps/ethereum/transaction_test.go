@@ -0,0 +1,100 @@
+package ethereum
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNFTTransaction(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+
+	safeAddress := "0x4f5974a056029EFA7e4B7b51a7Bbcb8FEc6E8970"
+	destination := "0xA03A8590BB3A2cA5c747c8b99C63DA399424a055"
+	collection := "0x251BE3A17Af4892035C37ebf5890F4a4D889dcAD"
+	id := "b231eebd-78ec-44f7-aeeb-7cf0b73ed070"
+	o := &Output{
+		TokenAddress: collection,
+		Destination:  destination,
+		Amount:       big.NewInt(1),
+		TokenId:      big.NewInt(1024),
+		TokenType:    TypeERC721Tx,
+	}
+	tx, err := CreateTransactionFromOutputs(ctx, TypeERC721Tx, 137, id, safeAddress, []*Output{o}, big.NewInt(1))
+	require.Nil(err)
+	outputs, err := tx.ExtractOutputs()
+	require.Nil(err)
+	require.Len(outputs, 1)
+	require.Equal(collection, outputs[0].TokenAddress)
+	require.Equal(destination, outputs[0].Destination)
+	require.Equal("1", outputs[0].Amount.String())
+	require.Equal("1024", outputs[0].TokenId.String())
+	require.Equal(TypeERC721Tx, outputs[0].TokenType)
+
+	var mos []*Output
+	mos = append(mos, &Output{
+		TokenAddress: EthereumEmptyAddress,
+		Destination:  destination,
+		Amount:       big.NewInt(100000000000000),
+	})
+	mos = append(mos, o)
+	mos = append(mos, &Output{
+		TokenAddress: collection,
+		Destination:  destination,
+		Amount:       big.NewInt(5),
+		TokenId:      big.NewInt(7),
+		TokenType:    TypeERC1155Tx,
+	})
+	tx, err = CreateTransactionFromOutputs(ctx, TypeMultiSendTx, 137, id, safeAddress, mos, big.NewInt(1))
+	require.Nil(err)
+	parsedOutputs, err := tx.ExtractOutputs()
+	require.Nil(err)
+	require.Len(parsedOutputs, 3)
+	for i, po := range parsedOutputs {
+		o := mos[i]
+		require.True(po.Amount.Cmp(o.Amount) == 0)
+		require.Equal(o.Destination, po.Destination)
+		require.Equal(o.TokenAddress, po.TokenAddress)
+		require.Equal(o.TokenType, po.TokenType)
+		if o.TokenId != nil {
+			require.True(po.TokenId.Cmp(o.TokenId) == 0)
+		}
+	}
+
+	tx.Data = common.FromHex("0x42842e0e0000")
+	tx.Operation = operationTypeDelegateCall
+	_, err = tx.ExtractOutputs()
+	require.NotNil(err)
+}
+
+func TestContractCallArguments(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+
+	safeAddress := "0x4f5974a056029EFA7e4B7b51a7Bbcb8FEc6E8970"
+	target := "0xc2132D05D31c914a87C6611C10748AEb04B58e8F"
+	id := "b231eebd-78ec-44f7-aeeb-7cf0b73ed070"
+	data := common.FromHex("0x15373e3d00000000000000000000000000000000000000000000000000000000000000070000000000000000000000000000000000000000000000000000000000000001")
+	tx, err := CreateContractCallTransaction(ctx, 137, id, safeAddress, target, big.NewInt(0), data, big.NewInt(1))
+	require.Nil(err)
+	call := tx.ExtractContractCall()
+	require.NotNil(call)
+	require.Equal("castVote(uint256,bool)", call.Method())
+	args, err := call.Arguments()
+	require.Nil(err)
+	require.Equal([]string{"7", "true"}, args)
+
+	call.Data = data[:36]
+	_, err = call.Arguments()
+	require.NotNil(err)
+
+	call.Data = common.FromHex("0x12345678000000000000000000000000000000000000000000000000000000000000000701")
+	require.Equal("", call.Method())
+	args, err = call.Arguments()
+	require.Nil(err)
+	require.Equal([]string{"0000000000000000000000000000000000000000000000000000000000000007", "01"}, args)
+}
@@ -1,3 +1,15 @@
 package mixin
 
-const OutputTypeWithdrawalClaim = 0xa9
+const (
+	ChainMixinKernel = 3
+
+	ValuePrecision = 8
+	MaxUnspentUtxo = 256
+
+	// the key indexes of the safe members in the kernel multisig outputs
+	SafeThreshold  = 2
+	HolderKeyIndex = 0
+	SignerKeyIndex = 1
+
+	OutputTypeWithdrawalClaim = 0xa9
+)
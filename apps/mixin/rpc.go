@@ -19,15 +19,24 @@ type WithdrawalData struct {
 type Output struct {
 	Type       uint8           `json:"type"`
 	Amount     string          `json:"amount"`
+	Keys       []string        `json:"keys"`
+	Mask       string          `json:"mask"`
+	Script     string          `json:"script"`
 	Withdrawal *WithdrawalData `json:"withdrawal"`
 }
 
+type RPCInput struct {
+	Hash  string `json:"hash"`
+	Index uint   `json:"index"`
+}
+
 type RPCTransaction struct {
-	Asset      string   `json:"asset"`
-	Extra      string   `json:"extra"`
-	Hash       string   `json:"hash"`
-	Output     []Output `json:"outputs"`
-	References []string `json:"references"`
+	Asset      string     `json:"asset"`
+	Extra      string     `json:"extra"`
+	Hash       string     `json:"hash"`
+	Input      []RPCInput `json:"inputs"`
+	Output     []Output   `json:"outputs"`
+	References []string   `json:"references"`
 }
 
 type RPCSnapshot struct {
@@ -60,6 +69,26 @@ func RPCListSnapshots(ctx context.Context, rpc string, offset uint64, limit int)
 	return r, err
 }
 
+type RPCMintDistribution struct {
+	Amount      string `json:"amount"`
+	Batch       uint64 `json:"batch"`
+	Group       string `json:"group"`
+	Transaction string `json:"transaction"`
+}
+
+func RPCListMintDistributions(ctx context.Context, rpc string, offset uint64, limit int) ([]RPCMintDistribution, error) {
+	res, err := callMixinRPCUntilSufficient(rpc, "listmintdistributions", []any{offset, limit, false})
+	if err != nil {
+		return nil, err
+	}
+	var r []RPCMintDistribution
+	err = json.Unmarshal(res, &r)
+	if err != nil {
+		return nil, err
+	}
+	return r, err
+}
+
 func callMixinRPCUntilSufficient(rpc, method string, params []any) ([]byte, error) {
 	for {
 		res, err := callMixinRPC(rpc, method, params)
@@ -128,3 +157,15 @@ func callMixinRPC(rpc, method string, params []any) ([]byte, error) {
 func buildRPCError(rpc, method string, params []any, err error) error {
 	return fmt.Errorf("callMixinRPC(%s, %s, %v) => %v", rpc, method, params, err)
 }
+
+func RPCSendRawTransaction(ctx context.Context, rpc, raw string) (string, error) {
+	res, err := callMixinRPCUntilSufficient(rpc, "sendrawtransaction", []any{raw})
+	if err != nil {
+		return "", err
+	}
+	var r struct {
+		Hash string `json:"hash"`
+	}
+	err = json.Unmarshal(res, &r)
+	return r.Hash, err
+}
@@ -0,0 +1,355 @@
+package mixin
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"filippo.io/edwards25519"
+	"github.com/MixinNetwork/bot-api-go-client/v3"
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+)
+
+type Input struct {
+	TransactionHash string
+	Index           uint32
+	Amount          string
+	Mask            string
+}
+
+type Recipient struct {
+	Address string
+	Amount  string
+}
+
+// the kernel transaction with the ghost masks of all inputs, the kernel
+// signatures of each input are made by two of the holder, signer and observer
+// ghost keys, which share the same mask because of the same view key
+type SafeTransaction struct {
+	Transaction *common.VersionedTransaction
+	Masks       []string
+}
+
+func VerifyHolderKey(public string) error {
+	key, err := crypto.KeyFromString(public)
+	if err != nil {
+		return err
+	}
+	if !key.CheckKey() {
+		return fmt.Errorf("invalid mixin key %s", public)
+	}
+	return nil
+}
+
+// the safe is the kernel multisig of the holder, signer and observer with
+// the threshold 2, the same roles as the bitcoin script. Each member address
+// has its own key as the spend key, and the observer key as the view key, so
+// the observer could scan the deposits and derive the ghost masks for all
+func BuildAddress(holder, signer, observer string) (string, error) {
+	var members []string
+	for _, public := range []string{holder, signer, observer} {
+		err := VerifyHolderKey(public)
+		if err != nil {
+			return "", err
+		}
+		spend, _ := crypto.KeyFromString(public)
+		view, _ := crypto.KeyFromString(observer)
+		addr := common.Address{PublicSpendKey: spend, PublicViewKey: view}
+		members = append(members, addr.String())
+	}
+	return bot.NewMainnetMixAddress(members, SafeThreshold).String(), nil
+}
+
+func parseAddress(s string) ([]*common.Address, int, error) {
+	if !strings.HasPrefix(s, bot.MixAddressPrefix) {
+		addr, err := common.NewAddressFromString(s)
+		if err != nil {
+			return nil, 0, fmt.Errorf("common.NewAddressFromString(%s) => %v", s, err)
+		}
+		return []*common.Address{&addr}, 1, nil
+	}
+	ma, err := bot.NewMixAddressFromString(s)
+	if err != nil {
+		return nil, 0, fmt.Errorf("bot.NewMixAddressFromString(%s) => %v", s, err)
+	}
+	var accounts []*common.Address
+	for _, m := range ma.Members() {
+		addr, err := common.NewAddressFromString(m)
+		if err != nil {
+			return nil, 0, fmt.Errorf("common.NewAddressFromString(%s) => %v", m, err)
+		}
+		accounts = append(accounts, &addr)
+	}
+	if int(ma.Threshold) > len(accounts) {
+		return nil, 0, fmt.Errorf("invalid mixin address threshold %s", s)
+	}
+	return accounts, int(ma.Threshold), nil
+}
+
+func VerifyMessageSignature(public string, msg, sig []byte) error {
+	return VerifyHashSignature(public, crypto.Sha256Hash(msg), sig)
+}
+
+func VerifyHashSignature(public string, hash crypto.Hash, sig []byte) error {
+	key, err := crypto.KeyFromString(public)
+	if err != nil || !key.CheckKey() {
+		return fmt.Errorf("invalid mixin key %s", public)
+	}
+	if len(sig) != len(crypto.Signature{}) {
+		return fmt.Errorf("invalid mixin signature %x", sig)
+	}
+	var s crypto.Signature
+	copy(s[:], sig)
+	if !key.Verify(hash, s) {
+		return fmt.Errorf("crypto.Verify(%s, %s, %x)", public, hash, sig)
+	}
+	return nil
+}
+
+func DeriveGhostMask(R, a *crypto.Key, index uint64) []byte {
+	return crypto.HashScalar(crypto.KeyMultPubPriv(R, a), index).Bytes()
+}
+
+// the one time public key of the output owned by the spend key, and the
+// signer signs with the spend key share plus the mask
+func GhostPublicKey(spend string, mask []byte) (string, error) {
+	B, err := crypto.KeyFromString(spend)
+	if err != nil {
+		return "", err
+	}
+	p1, err := edwards25519.NewIdentityPoint().SetBytes(B[:])
+	if err != nil {
+		return "", fmt.Errorf("invalid mixin key %s", spend)
+	}
+	x, err := edwards25519.NewScalar().SetCanonicalBytes(mask)
+	if err != nil {
+		return "", fmt.Errorf("invalid mixin mask %x", mask)
+	}
+	p2 := edwards25519.NewIdentityPoint().ScalarBaseMult(x)
+	p := edwards25519.NewIdentityPoint().Add(p1, p2)
+	return hex.EncodeToString(p.Bytes()), nil
+}
+
+// the one time public keys of the safe output in the member order
+func GhostPublicKeys(holder, signer, observer string, mask []byte) ([]string, error) {
+	var keys []string
+	for _, public := range []string{holder, signer, observer} {
+		key, err := GhostPublicKey(public, mask)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func BuildTransaction(assetId string, inputs []*Input, outputs []*Recipient, change string, extra []byte) (*SafeTransaction, error) {
+	if len(inputs) == 0 || len(inputs) > MaxUnspentUtxo {
+		return nil, fmt.Errorf("invalid inputs count %d", len(inputs))
+	}
+	if len(outputs) == 0 || len(outputs)+1 > MaxUnspentUtxo {
+		return nil, fmt.Errorf("invalid outputs count %d", len(outputs))
+	}
+	tx := common.NewTransactionV5(crypto.Sha256Hash([]byte(assetId)))
+	tx.Extra = extra
+
+	st := &SafeTransaction{}
+	var inputAmount, outputAmount common.Integer
+	for _, in := range inputs {
+		hash, err := crypto.HashFromString(in.TransactionHash)
+		if err != nil {
+			return nil, fmt.Errorf("invalid input hash %s", in.TransactionHash)
+		}
+		amt := common.NewIntegerFromString(in.Amount)
+		if amt.Sign() <= 0 {
+			return nil, fmt.Errorf("invalid input amount %s", in.Amount)
+		}
+		mask, err := hex.DecodeString(in.Mask)
+		if err != nil || len(mask) != 32 {
+			return nil, fmt.Errorf("invalid input mask %s", in.Mask)
+		}
+		tx.AddInput(hash, uint(in.Index))
+		st.Masks = append(st.Masks, in.Mask)
+		inputAmount = inputAmount.Add(amt)
+	}
+
+	for i, out := range outputs {
+		accounts, threshold, err := parseAddress(out.Address)
+		if err != nil {
+			return nil, err
+		}
+		amt := common.NewIntegerFromString(out.Amount)
+		if amt.Sign() <= 0 {
+			return nil, fmt.Errorf("invalid output amount %s", out.Amount)
+		}
+		script := common.NewThresholdScript(uint8(threshold))
+		tx.AddScriptOutput(accounts, script, amt, outputSeed(extra, i))
+		outputAmount = outputAmount.Add(amt)
+	}
+	if inputAmount.Cmp(outputAmount) < 0 {
+		return nil, BuildInsufficientInputError("total", inputAmount.String(), outputAmount.String())
+	}
+
+	if amt := inputAmount.Sub(outputAmount); amt.Sign() > 0 {
+		accounts, threshold, err := parseAddress(change)
+		if err != nil {
+			return nil, err
+		}
+		script := common.NewThresholdScript(uint8(threshold))
+		tx.AddScriptOutput(accounts, script, amt, outputSeed(extra, len(outputs)))
+	}
+
+	st.Transaction = tx.AsVersioned()
+	return st, nil
+}
+
+// the output seeds are deterministic so that all keeper nodes build the
+// same transaction for the same request
+func outputSeed(extra []byte, index int) []byte {
+	buf := binary.BigEndian.AppendUint32(append([]byte{}, extra...), uint32(index))
+	h1 := crypto.Sha256Hash(buf)
+	h2 := crypto.Sha256Hash(h1[:])
+	return append(h1[:], h2[:]...)
+}
+
+func (st *SafeTransaction) Hash() string {
+	return st.Transaction.PayloadHash().String()
+}
+
+// the kernel signatures of each input are made by two members, which are
+// the holder and signer for the transactions signed by the keeper
+func (st *SafeTransaction) IsFullySigned() bool {
+	if len(st.Transaction.SignaturesMap) != len(st.Transaction.Inputs) {
+		return false
+	}
+	for _, sm := range st.Transaction.SignaturesMap {
+		if len(sm) != SafeThreshold {
+			return false
+		}
+		for _, sig := range sm {
+			if sig == nil {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// the kernel signature of the input by the member at the key index
+func (st *SafeTransaction) InputSignature(input int, index uint16) []byte {
+	if input >= len(st.Transaction.SignaturesMap) {
+		return nil
+	}
+	sig := st.Transaction.SignaturesMap[input][index]
+	if sig == nil {
+		return nil
+	}
+	return sig[:]
+}
+
+// the public signs all inputs with its ghost keys derived from the masks
+func (st *SafeTransaction) SignedBy(public string) bool {
+	if len(st.Masks) != len(st.Transaction.Inputs) {
+		return false
+	}
+	if len(st.Transaction.SignaturesMap) != len(st.Transaction.Inputs) {
+		return false
+	}
+	hash := st.Transaction.PayloadHash()
+	for i, sm := range st.Transaction.SignaturesMap {
+		mask, err := hex.DecodeString(st.Masks[i])
+		if err != nil {
+			return false
+		}
+		key, err := GhostPublicKey(public, mask)
+		if err != nil {
+			return false
+		}
+		signed := false
+		for _, sig := range sm {
+			if sig != nil && VerifyHashSignature(key, hash, sig[:]) == nil {
+				signed = true
+			}
+		}
+		if !signed {
+			return false
+		}
+	}
+	return true
+}
+
+func (st *SafeTransaction) Marshal() []byte {
+	enc := common.NewEncoder()
+	writeBytes(enc, st.Transaction.Marshal())
+	enc.WriteInt(len(st.Masks))
+	for _, m := range st.Masks {
+		mask, _ := hex.DecodeString(m)
+		writeBytes(enc, mask)
+	}
+	return enc.Bytes()
+}
+
+func UnmarshalSafeTransaction(b []byte) (*SafeTransaction, error) {
+	dec := common.NewDecoder(b)
+	raw, err := dec.ReadBytes()
+	if err != nil {
+		return nil, err
+	}
+	ver, err := common.UnmarshalVersionedTransaction(raw)
+	if err != nil {
+		return nil, err
+	}
+	st := &SafeTransaction{Transaction: ver}
+	n, err := dec.ReadInt()
+	if err != nil {
+		return nil, err
+	}
+	for ; n > 0; n-- {
+		mask, err := dec.ReadBytes()
+		if err != nil {
+			return nil, err
+		}
+		st.Masks = append(st.Masks, hex.EncodeToString(mask))
+	}
+	return st, nil
+}
+
+// the kernel signatures of all inputs are made by the ghost keys of the public
+func CheckTransactionPartiallySignedBy(raw, public string) bool {
+	b, err := hex.DecodeString(raw)
+	if err != nil {
+		return false
+	}
+	st, err := UnmarshalSafeTransaction(b)
+	if err != nil {
+		return false
+	}
+	return st.SignedBy(public)
+}
+
+func CheckTransactionFullySigned(raw string) bool {
+	b, err := hex.DecodeString(raw)
+	if err != nil {
+		return false
+	}
+	st, err := UnmarshalSafeTransaction(b)
+	if err != nil {
+		return false
+	}
+	return st.IsFullySigned()
+}
+
+func IsInsufficientInputError(err error) bool {
+	return err != nil && strings.HasPrefix(err.Error(), "insufficient ")
+}
+
+func BuildInsufficientInputError(cat, inAmount, outAmount string) error {
+	return fmt.Errorf("insufficient %s %s %s", cat, inAmount, outAmount)
+}
+
+func writeBytes(enc *common.Encoder, b []byte) {
+	enc.WriteInt(len(b))
+	enc.Write(b)
+}
@@ -0,0 +1,125 @@
+package mixin
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMixinSafeTransaction(t *testing.T) {
+	require := require.New(t)
+
+	holder := testMixinKey("holder")
+	signer := testMixinKey("signer")
+	observer := testMixinKey("observer")
+	require.Nil(VerifyHolderKey(holder.Public().String()))
+	require.NotNil(VerifyHolderKey("invalid"))
+
+	addr, err := BuildAddress(holder.Public().String(), signer.Public().String(), observer.Public().String())
+	require.Nil(err)
+	accounts, threshold, err := parseAddress(addr)
+	require.Nil(err)
+	require.Equal(SafeThreshold, threshold)
+	require.Len(accounts, 3)
+	for i, k := range []crypto.Key{holder, signer, observer} {
+		require.Equal(k.Public(), accounts[i].PublicSpendKey)
+		require.Equal(observer.Public(), accounts[i].PublicViewKey)
+	}
+	_, err = BuildAddress(holder.Public().String(), signer.Public().String(), "invalid")
+	require.NotNil(err)
+
+	// the observer view key derives the same ghost keys as the kernel output
+	r := testMixinKey("deposit")
+	R := r.Public()
+	mask := DeriveGhostMask(&R, &observer, 3)
+	keys, err := GhostPublicKeys(holder.Public().String(), signer.Public().String(), observer.Public().String(), mask)
+	require.Nil(err)
+	for i, a := range accounts {
+		ghost := crypto.DeriveGhostPublicKey(&r, &a.PublicViewKey, &a.PublicSpendKey, 3)
+		require.Equal(ghost.String(), keys[i])
+	}
+	holderPriv := crypto.DeriveGhostPrivateKey(&R, &observer, &holder, 3)
+	require.Equal(keys[HolderKeyIndex], holderPriv.Public().String())
+	signerPriv := crypto.DeriveGhostPrivateKey(&R, &observer, &signer, 3)
+	require.Equal(keys[SignerKeyIndex], signerPriv.Public().String())
+	other, _ := GhostPublicKey(signer.Public().String(), DeriveGhostMask(&R, &observer, 4))
+	require.NotEqual(keys[SignerKeyIndex], other)
+
+	receiver := common.Address{PublicSpendKey: testMixinKey("spend").Public(), PublicViewKey: testMixinKey("view").Public()}
+	inputs := []*Input{{
+		TransactionHash: crypto.Sha256Hash([]byte("input")).String(),
+		Index:           3,
+		Amount:          "1.5",
+		Mask:            hex.EncodeToString(mask),
+	}}
+	outputs := []*Recipient{{Address: receiver.String(), Amount: "1.2"}}
+	extra := []byte("b7a8bc7e-9c0d-4f8c-9e1b-1b9c0d4f8c9e")
+	st, err := BuildTransaction(testMixinAssetId, inputs, outputs, addr, extra)
+	require.Nil(err)
+	require.Len(st.Transaction.Inputs, 1)
+	require.Len(st.Transaction.Outputs, 2)
+	require.Len(st.Transaction.Outputs[0].Keys, 1)
+	require.Equal(common.NewThresholdScript(1).String(), st.Transaction.Outputs[0].Script.String())
+	require.Len(st.Transaction.Outputs[1].Keys, 3)
+	require.Equal(common.NewThresholdScript(2).String(), st.Transaction.Outputs[1].Script.String())
+	require.Equal("0.30000000", st.Transaction.Outputs[1].Amount.String())
+	require.Equal([]string{hex.EncodeToString(mask)}, st.Masks)
+	another, _ := BuildTransaction(testMixinAssetId, inputs, outputs, addr, extra)
+	require.Equal(st.Hash(), another.Hash())
+
+	outputs[0].Amount = "1.6"
+	_, err = BuildTransaction(testMixinAssetId, inputs, outputs, addr, extra)
+	require.True(IsInsufficientInputError(err))
+	outputs[0].Amount = "1.5"
+	another, err = BuildTransaction(testMixinAssetId, inputs, outputs, addr, extra)
+	require.Nil(err)
+	require.Len(another.Transaction.Outputs, 1)
+	inputs[0].Mask = "invalid"
+	_, err = BuildTransaction(testMixinAssetId, inputs, outputs, addr, extra)
+	require.NotNil(err)
+
+	require.False(st.IsFullySigned())
+	raw := hex.EncodeToString(st.Marshal())
+	require.False(CheckTransactionPartiallySignedBy(raw, holder.Public().String()))
+	hash := st.Transaction.PayloadHash()
+	sig := holder.Sign(hash)
+	st.Transaction.SignaturesMap = []map[uint16]*crypto.Signature{{HolderKeyIndex: &sig}}
+	raw = hex.EncodeToString(st.Marshal())
+	require.False(CheckTransactionPartiallySignedBy(raw, holder.Public().String()))
+	hs := holderPriv.Sign(hash)
+	st.Transaction.SignaturesMap = []map[uint16]*crypto.Signature{{HolderKeyIndex: &hs}}
+	raw = hex.EncodeToString(st.Marshal())
+	require.True(CheckTransactionPartiallySignedBy(raw, holder.Public().String()))
+	require.False(CheckTransactionPartiallySignedBy(raw, signer.Public().String()))
+	require.False(CheckTransactionFullySigned(raw))
+	require.Equal(hs[:], st.InputSignature(0, HolderKeyIndex))
+	require.Nil(st.InputSignature(0, SignerKeyIndex))
+
+	ss := signerPriv.Sign(hash)
+	st.Transaction.SignaturesMap[0][SignerKeyIndex] = &ss
+	require.True(st.IsFullySigned())
+	raw = hex.EncodeToString(st.Marshal())
+	require.True(CheckTransactionFullySigned(raw))
+	require.True(CheckTransactionPartiallySignedBy(raw, signer.Public().String()))
+	require.False(CheckTransactionPartiallySignedBy(raw, observer.Public().String()))
+	decoded, err := UnmarshalSafeTransaction(st.Marshal())
+	require.Nil(err)
+	require.Equal(st.Hash(), decoded.Hash())
+	require.Equal(st.Masks, decoded.Masks)
+
+	msg := []byte("APPROVE:" + addr)
+	sig = holder.Sign(crypto.Sha256Hash(msg))
+	require.Nil(VerifyMessageSignature(holder.Public().String(), msg, sig[:]))
+	require.NotNil(VerifyMessageSignature(signer.Public().String(), msg, sig[:]))
+}
+
+const testMixinAssetId = "c94ac88f-4671-3976-b60a-09064f1811e8"
+
+func testMixinKey(seed string) crypto.Key {
+	h1 := crypto.Sha256Hash([]byte(seed))
+	h2 := crypto.Sha256Hash(h1[:])
+	return crypto.NewKeyFromSeed(append(h1[:], h2[:]...))
+}
@@ -0,0 +1,28 @@
+package solana
+
+const (
+	ChainSolana = 9
+
+	ValuePrecision = 9
+
+	// the safe transaction is limited by the 1232 bytes packet size, with
+	// the squads multisig creation in the first transaction
+	MaxTransferOutputs = 4
+
+	SystemProgramAddress           = "11111111111111111111111111111111"
+	SysvarRecentBlockhashesAddress = "SysvarRecentB1ockHashes11111111111111111111"
+
+	// the squads v4 multisig program, the safe multisig members are the holder,
+	// signer and observer with the threshold 2, and only the signer has the
+	// initiate and execute permissions, so the holder and observer could never
+	// spend without the signer, which only signs the observer recovery after
+	// the timelock enforced by the keeper
+	SquadsProgramAddress = "SQDS4ep65T869zMMBKyuUq6aD6EgTu8psMjkvj52pCf"
+
+	systemInstructionAdvanceNonceAccount = 4
+	systemInstructionTransfer            = 2
+
+	squadsPermissionInitiate = 1
+	squadsPermissionVote     = 2
+	squadsPermissionExecute  = 4
+)
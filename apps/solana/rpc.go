@@ -0,0 +1,242 @@
+package solana
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/btcsuite/btcd/btcutil/base58"
+)
+
+type RPCBlock struct {
+	Blockhash         string            `json:"blockhash"`
+	ParentSlot        int64             `json:"parentSlot"`
+	BlockTime         int64             `json:"blockTime"`
+	BlockHeight       int64             `json:"blockHeight"`
+	Transactions      []*RPCTransaction `json:"transactions"`
+	PreviousBlockhash string            `json:"previousBlockhash"`
+}
+
+type RPCTransaction struct {
+	Slot        int64 `json:"slot"`
+	Transaction struct {
+		Signatures []string `json:"signatures"`
+		Message    struct {
+			AccountKeys  []string          `json:"accountKeys"`
+			Instructions []*RPCInstruction `json:"instructions"`
+		} `json:"message"`
+	} `json:"transaction"`
+	Meta *struct {
+		Err             any `json:"err"`
+		LoadedAddresses *struct {
+			Writable []string `json:"writable"`
+			Readonly []string `json:"readonly"`
+		} `json:"loadedAddresses"`
+	} `json:"meta"`
+}
+
+type RPCInstruction struct {
+	ProgramIdIndex int    `json:"programIdIndex"`
+	Accounts       []int  `json:"accounts"`
+	Data           string `json:"data"`
+}
+
+type Transfer struct {
+	Signature string
+	Index     int64
+	Sender    string
+	Receiver  string
+	Lamports  uint64
+}
+
+func (tx *RPCTransaction) Signature() string {
+	if len(tx.Transaction.Signatures) == 0 {
+		return ""
+	}
+	return tx.Transaction.Signatures[0]
+}
+
+// only the system transfers in the top level instructions are recognized
+// as deposits, and the instruction index is used as the output index
+func (tx *RPCTransaction) ExtractTransfers() []*Transfer {
+	if tx.Meta == nil || tx.Meta.Err != nil {
+		return nil
+	}
+	keys := tx.Transaction.Message.AccountKeys
+	if la := tx.Meta.LoadedAddresses; la != nil {
+		keys = append(append(keys, la.Writable...), la.Readonly...)
+	}
+
+	var transfers []*Transfer
+	for i, ix := range tx.Transaction.Message.Instructions {
+		if ix.ProgramIdIndex >= len(keys) || keys[ix.ProgramIdIndex] != SystemProgramAddress {
+			continue
+		}
+		data := base58.Decode(ix.Data)
+		if len(data) != 12 || len(ix.Accounts) != 2 {
+			continue
+		}
+		if binary.LittleEndian.Uint32(data[:4]) != systemInstructionTransfer {
+			continue
+		}
+		if ix.Accounts[0] >= len(keys) || ix.Accounts[1] >= len(keys) {
+			continue
+		}
+		transfers = append(transfers, &Transfer{
+			Signature: tx.Signature(),
+			Index:     int64(i),
+			Sender:    keys[ix.Accounts[0]],
+			Receiver:  keys[ix.Accounts[1]],
+			Lamports:  binary.LittleEndian.Uint64(data[4:]),
+		})
+	}
+	return transfers
+}
+
+func RPCGetSlot(rpc string) (int64, error) {
+	res, err := callSolanaRPCUntilSufficient(rpc, "getSlot", []any{rpcCommitment()})
+	if err != nil {
+		return 0, err
+	}
+	var slot int64
+	err = json.Unmarshal(res, &slot)
+	return slot, err
+}
+
+// the skipped slots have no blocks, and nil is returned without error
+func RPCGetBlock(rpc string, slot int64) (*RPCBlock, error) {
+	params := rpcCommitment()
+	params["encoding"] = "json"
+	params["transactionDetails"] = "full"
+	params["rewards"] = false
+	params["maxSupportedTransactionVersion"] = 0
+	res, err := callSolanaRPCUntilSufficient(rpc, "getBlock", []any{slot, params})
+	if err != nil && strings.Contains(err.Error(), "skipped") {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var b RPCBlock
+	err = json.Unmarshal(res, &b)
+	if err != nil {
+		return nil, err
+	}
+	for _, tx := range b.Transactions {
+		tx.Slot = slot
+	}
+	return &b, nil
+}
+
+func RPCGetTransaction(rpc, signature string) (*RPCTransaction, error) {
+	params := rpcCommitment()
+	params["encoding"] = "json"
+	params["maxSupportedTransactionVersion"] = 0
+	res, err := callSolanaRPCUntilSufficient(rpc, "getTransaction", []any{signature, params})
+	if err != nil {
+		return nil, err
+	}
+	if string(res) == "null" {
+		return nil, nil
+	}
+	var tx RPCTransaction
+	err = json.Unmarshal(res, &tx)
+	return &tx, err
+}
+
+func RPCSendTransaction(rpc string, raw []byte) (string, error) {
+	params := map[string]any{
+		"encoding":            "base64",
+		"preflightCommitment": "confirmed",
+	}
+	res, err := callSolanaRPCUntilSufficient(rpc, "sendTransaction", []any{base64.StdEncoding.EncodeToString(raw), params})
+	if err != nil {
+		return "", err
+	}
+	var signature string
+	err = json.Unmarshal(res, &signature)
+	return signature, err
+}
+
+func rpcCommitment() map[string]any {
+	return map[string]any{"commitment": "finalized"}
+}
+
+func callSolanaRPCUntilSufficient(rpc, method string, params []any) ([]byte, error) {
+	for {
+		res, err := callSolanaRPC(rpc, method, params)
+		if err != nil {
+			reason := strings.ToLower(err.Error())
+			switch {
+			case strings.Contains(reason, "timeout"):
+			case strings.Contains(reason, "eof"):
+			case strings.Contains(reason, "handshake"):
+			case strings.Contains(reason, "too many requests"):
+			case strings.Contains(reason, "invalid character '<'"):
+			default:
+				return res, err
+			}
+			time.Sleep(7 * time.Second)
+			continue
+		}
+		return res, err
+	}
+}
+
+func callSolanaRPC(rpc, method string, params []any) ([]byte, error) {
+	client := &http.Client{Timeout: 20 * time.Second}
+
+	body, err := json.Marshal(map[string]any{
+		"method":  method,
+		"params":  params,
+		"id":      time.Now().UnixNano(),
+		"jsonrpc": "2.0",
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	req, err := http.NewRequest("POST", rpc, bytes.NewReader(body))
+	if err != nil {
+		return nil, buildRPCError(rpc, method, params, err)
+	}
+
+	req.Close = true
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, buildRPCError(rpc, method, params, err)
+	}
+	defer resp.Body.Close()
+
+	body, err = io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, buildRPCError(rpc, method, params, err)
+	}
+	var result struct {
+		Result json.RawMessage `json:"result"`
+		Error  *struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	err = json.Unmarshal(body, &result)
+	if err != nil {
+		return nil, fmt.Errorf("%v (%s)", buildRPCError(rpc, method, params, err), string(body))
+	}
+	if result.Error != nil {
+		err = fmt.Errorf("%d %s", result.Error.Code, strings.ToLower(result.Error.Message))
+		return nil, buildRPCError(rpc, method, params, err)
+	}
+	return result.Result, nil
+}
+
+func buildRPCError(rpc, method string, params []any, err error) error {
+	return fmt.Errorf("callSolanaRPC(%s, %s, %v) => %v", rpc, method, params, err)
+}
@@ -0,0 +1,638 @@
+package solana
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"slices"
+
+	"filippo.io/edwards25519"
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/btcsuite/btcd/btcutil/base58"
+	"github.com/shopspring/decimal"
+)
+
+type Recipient struct {
+	Address string
+	Amount  string
+}
+
+// the legacy solana messages with the durable nonce, the signer is the fee
+// payer and the nonce authority, the message is cosigned by the holder, and
+// the recovery message is the same transfers cosigned by the observer, which
+// is only requested to be signed by the keeper after the timelock
+type SafeTransaction struct {
+	Message         []byte
+	RecoveryMessage []byte
+	HolderSignature []byte
+	Signature       []byte
+}
+
+func VerifyHolderKey(public string) error {
+	key, err := hex.DecodeString(public)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return fmt.Errorf("invalid solana key %s", public)
+	}
+	_, err = edwards25519.NewIdentityPoint().SetBytes(key)
+	if err != nil {
+		return fmt.Errorf("invalid solana key %s", public)
+	}
+	return nil
+}
+
+// the safe is the squads multisig created with the signer key, and the safe
+// address is the vault 0 of the multisig, the signer key is unique for each
+// holder, so the address is determined before the multisig created
+func BuildAddress(holder, signer, observer string) (string, error) {
+	for _, public := range []string{holder, signer, observer} {
+		err := VerifyHolderKey(public)
+		if err != nil {
+			return "", err
+		}
+	}
+	key, _ := hex.DecodeString(signer)
+	vault := buildVaultAddress(buildMultisigAddress(key))
+	return base58.Encode(vault), nil
+}
+
+func buildMultisigAddress(signer []byte) []byte {
+	return findSquadsAddress([]byte("multisig"), signer)
+}
+
+func buildVaultAddress(multisig []byte) []byte {
+	return findSquadsAddress(multisig, []byte("vault"), []byte{0})
+}
+
+func buildTransactionAddress(multisig []byte, index uint64) []byte {
+	return findSquadsAddress(multisig, []byte("transaction"), binary.LittleEndian.AppendUint64(nil, index))
+}
+
+func buildProposalAddress(multisig []byte, index uint64) []byte {
+	return findSquadsAddress(multisig, []byte("transaction"), binary.LittleEndian.AppendUint64(nil, index), []byte("proposal"))
+}
+
+func buildProgramConfigAddress() []byte {
+	return findSquadsAddress([]byte("program_config"))
+}
+
+func findSquadsAddress(seeds ...[]byte) []byte {
+	seeds = append([][]byte{[]byte("multisig")}, seeds...)
+	addr, err := findProgramAddress(seeds, SquadsProgramAddress)
+	if err != nil {
+		panic(err)
+	}
+	return addr
+}
+
+func findProgramAddress(seeds [][]byte, program string) ([]byte, error) {
+	pid, err := DecodeAddress(program)
+	if err != nil {
+		return nil, err
+	}
+	for bump := 255; bump >= 0; bump-- {
+		h := sha256.New()
+		for _, s := range seeds {
+			h.Write(s)
+		}
+		h.Write([]byte{byte(bump)})
+		h.Write(pid)
+		h.Write([]byte("ProgramDerivedAddress"))
+		addr := h.Sum(nil)
+		_, err := edwards25519.NewIdentityPoint().SetBytes(addr)
+		if err != nil {
+			return addr, nil
+		}
+	}
+	return nil, fmt.Errorf("solana.findProgramAddress(%s) not found", program)
+}
+
+// the anchor instruction discriminator of the squads program
+func squadsInstruction(name string) []byte {
+	sum := sha256.Sum256([]byte("global:" + name))
+	return sum[:8]
+}
+
+func DecodeAddress(addr string) ([]byte, error) {
+	key := base58.Decode(addr)
+	if len(key) != ed25519.PublicKeySize || base58.Encode(key) != addr {
+		return nil, fmt.Errorf("invalid solana address %s", addr)
+	}
+	return key, nil
+}
+
+func VerifyMessageSignature(public string, msg, sig []byte) error {
+	err := VerifyHolderKey(public)
+	if err != nil {
+		return err
+	}
+	if len(sig) != ed25519.SignatureSize {
+		return fmt.Errorf("invalid solana signature %x", sig)
+	}
+	key, _ := hex.DecodeString(public)
+	if !ed25519.Verify(key, msg, sig) {
+		return fmt.Errorf("ed25519.Verify(%s, %x, %x)", public, msg, sig)
+	}
+	return nil
+}
+
+func ParseLamports(amount string) (uint64, error) {
+	amt, err := decimal.NewFromString(amount)
+	if err != nil || !amt.IsPositive() {
+		return 0, fmt.Errorf("invalid solana amount %s", amount)
+	}
+	if !amt.Equal(amt.Truncate(ValuePrecision)) {
+		return 0, fmt.Errorf("invalid solana amount %s", amount)
+	}
+	lamports := amt.Shift(ValuePrecision).BigInt()
+	if !lamports.IsUint64() {
+		return 0, fmt.Errorf("invalid solana amount %s", amount)
+	}
+	return lamports.Uint64(), nil
+}
+
+// the index is the next transaction index of the squads multisig, and the
+// multisig is created in the first transaction, which requires the treasury
+// of the squads program config to pay the creation fee
+func BuildTransaction(holder, signer, observer string, index uint64, treasury, nonceAccount, nonceHash string, outputs []*Recipient) (*SafeTransaction, error) {
+	if len(outputs) == 0 || len(outputs) > MaxTransferOutputs {
+		return nil, fmt.Errorf("invalid outputs count %d", len(outputs))
+	}
+	safe, err := BuildAddress(holder, signer, observer)
+	if err != nil {
+		return nil, err
+	}
+	if index == 0 {
+		return nil, fmt.Errorf("invalid squads transaction index %d", index)
+	}
+	nonce, err := DecodeAddress(nonceAccount)
+	if err != nil {
+		return nil, err
+	}
+	blockhash, err := DecodeAddress(nonceHash)
+	if err != nil {
+		return nil, fmt.Errorf("invalid solana nonce hash %s", nonceHash)
+	}
+
+	sm := &safeMessage{index: index, nonce: nonce, blockhash: blockhash}
+	sm.holder, _ = hex.DecodeString(holder)
+	sm.signer, _ = hex.DecodeString(signer)
+	sm.observer, _ = hex.DecodeString(observer)
+	sm.multisig = buildMultisigAddress(sm.signer)
+	sm.vault, _ = DecodeAddress(safe)
+	reserved := map[string]bool{
+		safe: true, nonceAccount: true,
+		SysvarRecentBlockhashesAddress: true, SystemProgramAddress: true, SquadsProgramAddress: true,
+	}
+	for _, k := range [][]byte{
+		sm.holder, sm.signer, sm.observer, sm.multisig, buildProgramConfigAddress(),
+		buildTransactionAddress(sm.multisig, index), buildProposalAddress(sm.multisig, index),
+	} {
+		reserved[base58.Encode(k)] = true
+	}
+	if index == 1 {
+		sm.treasury, err = DecodeAddress(treasury)
+		if err != nil {
+			return nil, fmt.Errorf("invalid squads treasury %s", treasury)
+		}
+		reserved[treasury] = true
+	}
+	for _, out := range outputs {
+		if reserved[out.Address] {
+			return nil, fmt.Errorf("invalid solana receiver %s", out.Address)
+		}
+		receiver, err := DecodeAddress(out.Address)
+		if err != nil {
+			return nil, err
+		}
+		lamports, err := ParseLamports(out.Amount)
+		if err != nil {
+			return nil, err
+		}
+		sm.receivers = append(sm.receivers, receiver)
+		sm.amounts = append(sm.amounts, lamports)
+	}
+
+	st := &SafeTransaction{}
+	st.Message = sm.build(sm.holder)
+	st.RecoveryMessage = sm.build(sm.observer)
+	return st, nil
+}
+
+type safeMessage struct {
+	holder    []byte
+	signer    []byte
+	observer  []byte
+	multisig  []byte
+	vault     []byte
+	treasury  []byte
+	nonce     []byte
+	blockhash []byte
+	index     uint64
+	receivers [][]byte
+	amounts   []uint64
+}
+
+// the signer is the fee payer, nonce authority and the squads rent payer, and
+// the cosigner is either the holder or the observer. The message advances the
+// nonce, creates the multisig for the first transaction, then creates the
+// vault transaction and its proposal, approves the proposal by the signer and
+// cosigner, and executes the vault transaction in the same solana transaction.
+func (sm *safeMessage) build(cosigner []byte) []byte {
+	sysvar, _ := DecodeAddress(SysvarRecentBlockhashesAddress)
+	system, _ := DecodeAddress(SystemProgramAddress)
+	program, _ := DecodeAddress(SquadsProgramAddress)
+	transaction := buildTransactionAddress(sm.multisig, sm.index)
+	proposal := buildProposalAddress(sm.multisig, sm.index)
+	vaultKeys, vaultMessage := buildVaultMessage(sm.vault, sm.receivers, sm.amounts)
+
+	keys := [][]byte{sm.signer, cosigner, sm.nonce, sm.multisig, transaction, proposal}
+	keys = append(keys, vaultKeys[:len(vaultKeys)-1]...)
+	if sm.index == 1 {
+		keys = append(keys, sm.treasury)
+	}
+	writable := len(keys)
+	keys = append(keys, sysvar, system, program)
+	if sm.index == 1 {
+		keys = append(keys, buildProgramConfigAddress())
+	}
+	index := func(k []byte) byte {
+		return byte(slices.IndexFunc(keys, func(b []byte) bool { return bytes.Equal(b, k) }))
+	}
+
+	msg := []byte{2, 0, byte(len(keys) - writable)}
+	msg = appendCompactU16(msg, len(keys))
+	for _, k := range keys {
+		msg = append(msg, k...)
+	}
+	msg = append(msg, sm.blockhash...)
+	if sm.index == 1 {
+		msg = appendCompactU16(msg, 7)
+	} else {
+		msg = appendCompactU16(msg, 6)
+	}
+
+	data := binary.LittleEndian.AppendUint32(nil, systemInstructionAdvanceNonceAccount)
+	msg = appendInstruction(msg, index(system), []byte{index(sm.nonce), index(sysvar), 0}, data)
+
+	if sm.index == 1 {
+		data = squadsInstruction("multisig_create_v2")
+		data = append(data, 0)
+		data = binary.LittleEndian.AppendUint16(data, 2)
+		data = binary.LittleEndian.AppendUint32(data, 3)
+		data = append(data, sm.holder...)
+		data = append(data, squadsPermissionVote)
+		data = append(data, sm.signer...)
+		data = append(data, squadsPermissionInitiate|squadsPermissionVote|squadsPermissionExecute)
+		data = append(data, sm.observer...)
+		data = append(data, squadsPermissionVote)
+		data = binary.LittleEndian.AppendUint32(data, 0)
+		data = append(data, 0, 0)
+		accounts := []byte{index(buildProgramConfigAddress()), index(sm.treasury), index(sm.multisig), 0, 0, index(system)}
+		msg = appendInstruction(msg, index(program), accounts, data)
+	}
+
+	data = squadsInstruction("vault_transaction_create")
+	data = append(data, 0, 0)
+	data = binary.LittleEndian.AppendUint32(data, uint32(len(vaultMessage)))
+	data = append(data, vaultMessage...)
+	data = append(data, 0)
+	accounts := []byte{index(sm.multisig), index(transaction), 0, 0, index(system)}
+	msg = appendInstruction(msg, index(program), accounts, data)
+
+	data = squadsInstruction("proposal_create")
+	data = binary.LittleEndian.AppendUint64(data, sm.index)
+	data = append(data, 0)
+	accounts = []byte{index(sm.multisig), index(proposal), 0, 0, index(system)}
+	msg = appendInstruction(msg, index(program), accounts, data)
+
+	for _, member := range [][]byte{sm.signer, cosigner} {
+		data = append(squadsInstruction("proposal_approve"), 0)
+		accounts = []byte{index(sm.multisig), index(member), index(proposal)}
+		msg = appendInstruction(msg, index(program), accounts, data)
+	}
+
+	accounts = []byte{index(sm.multisig), index(proposal), index(transaction), 0}
+	for _, k := range vaultKeys {
+		accounts = append(accounts, index(k))
+	}
+	return appendInstruction(msg, index(program), accounts, squadsInstruction("vault_transaction_execute"))
+}
+
+// the squads transaction message of the system transfers from the vault, the
+// vault is the only signer, and the keys are also the remaining accounts of
+// the vault transaction execution in the same order
+func buildVaultMessage(vault []byte, receivers [][]byte, amounts []uint64) ([][]byte, []byte) {
+	system, _ := DecodeAddress(SystemProgramAddress)
+	keys := [][]byte{vault}
+	indexes := make([]byte, len(receivers))
+	for i, r := range receivers {
+		index := slices.IndexFunc(keys, func(k []byte) bool { return bytes.Equal(k, r) })
+		if index < 0 {
+			index = len(keys)
+			keys = append(keys, r)
+		}
+		indexes[i] = byte(index)
+	}
+	keys = append(keys, system)
+
+	msg := []byte{1, 1, byte(len(keys) - 2), byte(len(keys))}
+	for _, k := range keys {
+		msg = append(msg, k...)
+	}
+	msg = append(msg, byte(len(receivers)))
+	for i, a := range amounts {
+		data := binary.LittleEndian.AppendUint32(nil, systemInstructionTransfer)
+		data = binary.LittleEndian.AppendUint64(data, a)
+		msg = append(msg, byte(len(keys)-1), 2, 0, indexes[i])
+		msg = binary.LittleEndian.AppendUint16(msg, uint16(len(data)))
+		msg = append(msg, data...)
+	}
+	return keys, append(msg, 0)
+}
+
+func appendInstruction(msg []byte, program byte, accounts, data []byte) []byte {
+	msg = append(msg, program)
+	msg = appendCompactU16(msg, len(accounts))
+	msg = append(msg, accounts...)
+	msg = appendCompactU16(msg, len(data))
+	return append(msg, data...)
+}
+
+func appendCompactU16(b []byte, n int) []byte {
+	for {
+		c := byte(n & 0x7f)
+		n >>= 7
+		if n == 0 {
+			return append(b, c)
+		}
+		b = append(b, c|0x80)
+	}
+}
+
+func readCompactU16(b []byte) (int, []byte, error) {
+	var n int
+	for i := 0; i < 3 && i < len(b); i++ {
+		n |= int(b[i]&0x7f) << (7 * i)
+		if b[i]&0x80 == 0 {
+			return n, b[i+1:], nil
+		}
+	}
+	return 0, nil, fmt.Errorf("invalid compact u16 %x", b)
+}
+
+type instruction struct {
+	program  int
+	accounts []byte
+	data     []byte
+}
+
+func decodeMessage(msg []byte) ([]string, []*instruction, error) {
+	if len(msg) < 3 {
+		return nil, nil, fmt.Errorf("invalid solana message %x", msg)
+	}
+	n, msg, err := readCompactU16(msg[3:])
+	if err != nil || len(msg) < (n+1)*ed25519.PublicKeySize {
+		return nil, nil, fmt.Errorf("invalid solana message keys %d %v", n, err)
+	}
+	keys := make([]string, n)
+	for i := range keys {
+		keys[i] = base58.Encode(msg[i*32 : i*32+32])
+	}
+	msg = msg[(n+1)*ed25519.PublicKeySize:]
+
+	ixs, msg, err := readCompactU16(msg)
+	if err != nil {
+		return nil, nil, err
+	}
+	instructions := make([]*instruction, ixs)
+	for i := range instructions {
+		if len(msg) < 1 {
+			return nil, nil, fmt.Errorf("invalid solana instruction %d", i)
+		}
+		program := int(msg[0])
+		al, rest, err := readCompactU16(msg[1:])
+		if err != nil || len(rest) < al {
+			return nil, nil, fmt.Errorf("invalid solana instruction %d accounts", i)
+		}
+		accounts := rest[:al]
+		dl, rest, err := readCompactU16(rest[al:])
+		if err != nil || len(rest) < dl {
+			return nil, nil, fmt.Errorf("invalid solana instruction %d data", i)
+		}
+		if program >= n {
+			return nil, nil, fmt.Errorf("invalid solana instruction %d program", i)
+		}
+		for _, a := range accounts {
+			if int(a) >= n {
+				return nil, nil, fmt.Errorf("invalid solana instruction %d account %d", i, a)
+			}
+		}
+		instructions[i] = &instruction{program, accounts, rest[:dl]}
+		msg = rest[dl:]
+	}
+	if len(msg) != 0 {
+		return nil, nil, fmt.Errorf("invalid solana message size %d", len(msg))
+	}
+	return keys, instructions, nil
+}
+
+// the message hash is used as the keeper transaction hash, and it's the same
+// for both the holder and observer paths, the solana transaction id is only
+// known after signed
+func (st *SafeTransaction) Hash() string {
+	sum := sha256.Sum256(st.Message)
+	return hex.EncodeToString(sum[:])
+}
+
+func (st *SafeTransaction) PayerKey() []byte {
+	if len(st.Message) < 4+ed25519.PublicKeySize {
+		return nil
+	}
+	return st.Message[4 : 4+ed25519.PublicKeySize]
+}
+
+func cosignerKey(msg []byte) []byte {
+	if len(msg) < 4+2*ed25519.PublicKeySize {
+		return nil
+	}
+	return msg[4+ed25519.PublicKeySize : 4+2*ed25519.PublicKeySize]
+}
+
+// the message to be signed by the public, either the holder message or the
+// observer recovery message, and nil if the public is not a cosigner
+func (st *SafeTransaction) CosignerMessage(public string) []byte {
+	key, err := hex.DecodeString(public)
+	if err != nil {
+		return nil
+	}
+	for _, msg := range [][]byte{st.Message, st.RecoveryMessage} {
+		if bytes.Equal(cosignerKey(msg), key) {
+			return msg
+		}
+	}
+	return nil
+}
+
+// the message cosigned by the holder signature, which is the one to send
+func (st *SafeTransaction) SpendMessage() []byte {
+	for _, msg := range [][]byte{st.Message, st.RecoveryMessage} {
+		cosigner := cosignerKey(msg)
+		if cosigner == nil || len(st.HolderSignature) != ed25519.SignatureSize {
+			continue
+		}
+		if ed25519.Verify(cosigner, msg, st.HolderSignature) {
+			return msg
+		}
+	}
+	return nil
+}
+
+// only the transfers from the vault in the squads vault transaction are
+// extracted, and the message must be built by BuildTransaction, thus all the
+// other instructions are the nonce advance and the squads proposal ones
+func (st *SafeTransaction) ExtractOutputs() ([]*Recipient, error) {
+	keys, ixs, err := decodeMessage(st.Message)
+	if err != nil {
+		return nil, err
+	}
+	if len(ixs) < 6 || keys[ixs[0].program] != SystemProgramAddress {
+		return nil, fmt.Errorf("invalid solana instructions %d", len(ixs))
+	}
+	ix := ixs[len(ixs)-5]
+	create := squadsInstruction("vault_transaction_create")
+	if keys[ix.program] != SquadsProgramAddress || len(ix.data) < 15 || !bytes.Equal(ix.data[:8], create) {
+		return nil, fmt.Errorf("invalid solana squads instruction %v", ix)
+	}
+	l := int(binary.LittleEndian.Uint32(ix.data[10:14]))
+	if len(ix.data) != 15+l {
+		return nil, fmt.Errorf("invalid solana squads transaction %x", ix.data)
+	}
+	return decodeVaultMessage(ix.data[14 : 14+l])
+}
+
+func decodeVaultMessage(msg []byte) ([]*Recipient, error) {
+	if len(msg) < 4 || len(msg) < 4+int(msg[3])*ed25519.PublicKeySize+1 {
+		return nil, fmt.Errorf("invalid squads message %x", msg)
+	}
+	keys := make([]string, msg[3])
+	for i := range keys {
+		keys[i] = base58.Encode(msg[4+i*32 : 4+i*32+32])
+	}
+	msg = msg[4+len(keys)*ed25519.PublicKeySize:]
+	outputs := make([]*Recipient, msg[0])
+	msg = msg[1:]
+	for i := range outputs {
+		if len(msg) != 18*(len(outputs)-i)+1 {
+			return nil, fmt.Errorf("invalid squads instruction %d", i)
+		}
+		program, accounts, data := int(msg[0]), msg[2:4], msg[6:18]
+		if msg[1] != 2 || binary.LittleEndian.Uint16(msg[4:6]) != 12 || program >= len(keys) || int(accounts[1]) >= len(keys) {
+			return nil, fmt.Errorf("invalid squads instruction %d", i)
+		}
+		if keys[program] != SystemProgramAddress || accounts[0] != 0 || binary.LittleEndian.Uint32(data) != systemInstructionTransfer {
+			return nil, fmt.Errorf("invalid squads transfer %d", i)
+		}
+		lamports := binary.LittleEndian.Uint64(data[4:])
+		amt := decimal.NewFromBigInt(new(big.Int).SetUint64(lamports), -ValuePrecision)
+		outputs[i] = &Recipient{
+			Address: keys[accounts[1]],
+			Amount:  amt.String(),
+		}
+		msg = msg[18:]
+	}
+	if len(msg) != 1 || msg[0] != 0 {
+		return nil, fmt.Errorf("invalid squads message lookups %x", msg)
+	}
+	return outputs, nil
+}
+
+func (st *SafeTransaction) IsFullySigned() bool {
+	payer := st.PayerKey()
+	msg := st.SpendMessage()
+	if payer == nil || msg == nil || len(st.Signature) != ed25519.SignatureSize {
+		return false
+	}
+	return ed25519.Verify(payer, msg, st.Signature)
+}
+
+func (st *SafeTransaction) TransactionId() string {
+	return base58.Encode(st.Signature)
+}
+
+// the wire format with the signer and cosigner signatures of the message
+// cosigned by the holder signature
+func (st *SafeTransaction) Serialize() []byte {
+	raw := appendCompactU16(nil, 2)
+	raw = append(raw, st.Signature...)
+	raw = append(raw, st.HolderSignature...)
+	return append(raw, st.SpendMessage()...)
+}
+
+func (st *SafeTransaction) Marshal() []byte {
+	enc := common.NewEncoder()
+	writeBytes(enc, st.Message)
+	writeBytes(enc, st.RecoveryMessage)
+	writeBytes(enc, st.HolderSignature)
+	writeBytes(enc, st.Signature)
+	return enc.Bytes()
+}
+
+func UnmarshalSafeTransaction(b []byte) (*SafeTransaction, error) {
+	dec := common.NewDecoder(b)
+	msg, err := dec.ReadBytes()
+	if err != nil {
+		return nil, err
+	}
+	rm, err := dec.ReadBytes()
+	if err != nil {
+		return nil, err
+	}
+	hs, err := dec.ReadBytes()
+	if err != nil {
+		return nil, err
+	}
+	sig, err := dec.ReadBytes()
+	if err != nil {
+		return nil, err
+	}
+	return &SafeTransaction{Message: msg, RecoveryMessage: rm, HolderSignature: hs, Signature: sig}, nil
+}
+
+func CheckTransactionPartiallySignedBy(raw, public string) bool {
+	b, err := hex.DecodeString(raw)
+	if err != nil {
+		return false
+	}
+	st, err := UnmarshalSafeTransaction(b)
+	if err != nil {
+		return false
+	}
+	msg := st.CosignerMessage(public)
+	if msg == nil {
+		return false
+	}
+	err = VerifyMessageSignature(public, msg, st.HolderSignature)
+	return err == nil
+}
+
+// the solana transaction is fully signed by the signer and the cosigner
+func CheckTransactionFullySigned(raw string) bool {
+	b, err := hex.DecodeString(raw)
+	if err != nil {
+		return false
+	}
+	st, err := UnmarshalSafeTransaction(b)
+	if err != nil {
+		return false
+	}
+	return st.IsFullySigned()
+}
+
+func writeBytes(enc *common.Encoder, b []byte) {
+	enc.WriteInt(len(b))
+	enc.Write(b)
+}
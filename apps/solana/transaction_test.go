@@ -0,0 +1,189 @@
+package solana
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"filippo.io/edwards25519"
+	"github.com/btcsuite/btcd/btcutil/base58"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSolanaSafeTransaction(t *testing.T) {
+	require := require.New(t)
+
+	holder := testSolanaKey("holder")
+	signer := testSolanaKey("signer")
+	observer := testSolanaKey("observer")
+	holderPublic := hex.EncodeToString(holder.Public().(ed25519.PublicKey))
+	signerPublic := hex.EncodeToString(signer.Public().(ed25519.PublicKey))
+	observerPublic := hex.EncodeToString(observer.Public().(ed25519.PublicKey))
+	require.Nil(VerifyHolderKey(holderPublic))
+	require.NotNil(VerifyHolderKey("invalid"))
+
+	addr, err := BuildAddress(holderPublic, signerPublic, observerPublic)
+	require.Nil(err)
+	key, err := DecodeAddress(addr)
+	require.Nil(err)
+	_, err = edwards25519.NewIdentityPoint().SetBytes(key)
+	require.NotNil(err)
+	require.NotEqual([]byte(signer.Public().(ed25519.PublicKey)), key)
+	_, err = DecodeAddress(addr + "1")
+	require.NotNil(err)
+	multisig := buildMultisigAddress(signer.Public().(ed25519.PublicKey))
+	require.Equal(addr, base58.Encode(buildVaultAddress(multisig)))
+	other, err := BuildAddress(observerPublic, holderPublic, signerPublic)
+	require.Nil(err)
+	require.NotEqual(addr, other)
+	_, err = BuildAddress(holderPublic, signerPublic, "invalid")
+	require.NotNil(err)
+
+	nonceAccount := base58.Encode(testSolanaKey("nonce").Public().(ed25519.PublicKey))
+	nonceHash := base58.Encode(testSolanaKey("hash").Public().(ed25519.PublicKey))
+	treasury := base58.Encode(testSolanaKey("treasury").Public().(ed25519.PublicKey))
+	receiver := base58.Encode(testSolanaKey("receiver").Public().(ed25519.PublicKey))
+	outputs := []*Recipient{
+		{Address: receiver, Amount: "0.123456789"},
+		{Address: nonceHash, Amount: "1"},
+	}
+	st, err := BuildTransaction(holderPublic, signerPublic, observerPublic, 2, "", nonceAccount, nonceHash, outputs)
+	require.Nil(err)
+	require.Equal([]byte(signer.Public().(ed25519.PublicKey)), st.PayerKey())
+	require.False(st.IsFullySigned())
+	require.NotEqual(st.Message, st.RecoveryMessage)
+	require.Equal(st.Message, st.CosignerMessage(holderPublic))
+	require.Equal(st.RecoveryMessage, st.CosignerMessage(observerPublic))
+	require.Nil(st.CosignerMessage(signerPublic))
+	for _, r := range []string{addr, nonceAccount, base58.Encode(signer.Public().(ed25519.PublicKey)), base58.Encode(multisig), base58.Encode(buildProposalAddress(multisig, 2)), SquadsProgramAddress} {
+		_, err = BuildTransaction(holderPublic, signerPublic, observerPublic, 2, "", nonceAccount, nonceHash, []*Recipient{{Address: r, Amount: "1"}})
+		require.NotNil(err)
+	}
+	_, err = BuildTransaction(holderPublic, signerPublic, observerPublic, 2, "", nonceAccount, nonceHash, []*Recipient{{Address: receiver, Amount: "0.0000000001"}})
+	require.NotNil(err)
+	_, err = BuildTransaction(holderPublic, signerPublic, observerPublic, 0, "", nonceAccount, nonceHash, outputs)
+	require.NotNil(err)
+	_, err = BuildTransaction(holderPublic, signerPublic, observerPublic, 1, "", nonceAccount, nonceHash, outputs)
+	require.NotNil(err)
+
+	extracted, err := st.ExtractOutputs()
+	require.Nil(err)
+	require.Len(extracted, 2)
+	require.Equal(receiver, extracted[0].Address)
+	require.Equal("0.123456789", extracted[0].Amount)
+	require.Equal(nonceHash, extracted[1].Address)
+	require.Equal("1", extracted[1].Amount)
+	keys, ixs, err := decodeMessage(st.Message)
+	require.Nil(err)
+	require.Equal(base58.Encode(multisig), keys[3])
+	require.Equal(addr, keys[6])
+	require.Len(ixs, 6)
+	for _, ix := range ixs[1:] {
+		require.Equal(SquadsProgramAddress, keys[ix.program])
+	}
+	require.Equal(squadsInstruction("proposal_approve"), ixs[4].data[:8])
+	require.Equal(holderPublic, hex.EncodeToString(base58.Decode(keys[ixs[4].accounts[1]])))
+	require.Equal(squadsInstruction("vault_transaction_execute"), ixs[5].data)
+	require.Equal([]string{addr, receiver, nonceHash, SystemProgramAddress}, []string{keys[ixs[5].accounts[4]], keys[ixs[5].accounts[5]], keys[ixs[5].accounts[6]], keys[ixs[5].accounts[7]]})
+
+	many := make([]*Recipient, MaxTransferOutputs)
+	for i := range many {
+		r := base58.Encode(testSolanaKey(fmt.Sprintf("receiver-%d", i)).Public().(ed25519.PublicKey))
+		many[i] = &Recipient{Address: r, Amount: "1"}
+	}
+	ft, err := BuildTransaction(holderPublic, signerPublic, observerPublic, 1, treasury, nonceAccount, nonceHash, many)
+	require.Nil(err)
+	keys, ixs, err = decodeMessage(ft.Message)
+	require.Nil(err)
+	require.Len(ixs, 7)
+	require.Equal(squadsInstruction("multisig_create_v2"), ixs[1].data[:8])
+	require.Equal(treasury, keys[ixs[1].accounts[1]])
+	require.Equal(base58.Encode(multisig), keys[ixs[1].accounts[2]])
+	extracted, err = ft.ExtractOutputs()
+	require.Nil(err)
+	require.Equal(many, extracted)
+	ft.Signature = ed25519.Sign(signer, ft.Message)
+	ft.HolderSignature = ed25519.Sign(holder, ft.Message)
+	require.LessOrEqual(len(ft.Serialize()), 1232)
+	_, err = BuildTransaction(holderPublic, signerPublic, observerPublic, 1, treasury, nonceAccount, nonceHash, append(many, outputs[0]))
+	require.NotNil(err)
+
+	hash := sha256.Sum256(st.Message)
+	require.Equal(hex.EncodeToString(hash[:]), st.Hash())
+
+	st.HolderSignature = ed25519.Sign(observer, st.Message)
+	raw := hex.EncodeToString(st.Marshal())
+	require.False(CheckTransactionPartiallySignedBy(raw, observerPublic))
+	require.Nil(st.SpendMessage())
+
+	st.HolderSignature = ed25519.Sign(holder, st.Message)
+	raw = hex.EncodeToString(st.Marshal())
+	require.True(CheckTransactionPartiallySignedBy(raw, holderPublic))
+	require.False(CheckTransactionPartiallySignedBy(raw, observerPublic))
+	require.False(CheckTransactionFullySigned(raw))
+
+	st.Signature = ed25519.Sign(signer, st.RecoveryMessage)
+	require.False(st.IsFullySigned())
+	st.Signature = ed25519.Sign(signer, st.Message)
+	require.True(st.IsFullySigned())
+	raw = hex.EncodeToString(st.Marshal())
+	require.True(CheckTransactionFullySigned(raw))
+	require.Equal(base58.Encode(st.Signature), st.TransactionId())
+
+	parsed, err := UnmarshalSafeTransaction(st.Marshal())
+	require.Nil(err)
+	require.Equal(st.Hash(), parsed.Hash())
+	require.Equal(st.RecoveryMessage, parsed.RecoveryMessage)
+	require.Equal(st.HolderSignature, parsed.HolderSignature)
+	require.Equal(st.Signature, parsed.Signature)
+
+	wire := st.Serialize()
+	require.Equal(byte(2), wire[0])
+	require.Equal(st.Signature, wire[1:65])
+	require.Equal(st.HolderSignature, wire[65:129])
+	require.Equal(st.Message, wire[129:])
+
+	st.HolderSignature = ed25519.Sign(observer, st.RecoveryMessage)
+	st.Signature = ed25519.Sign(signer, st.RecoveryMessage)
+	raw = hex.EncodeToString(st.Marshal())
+	require.True(CheckTransactionPartiallySignedBy(raw, observerPublic))
+	require.False(CheckTransactionPartiallySignedBy(raw, holderPublic))
+	require.True(CheckTransactionFullySigned(raw))
+	require.Equal(st.RecoveryMessage, st.Serialize()[129:])
+
+	ms := []byte("APPROVE:9f3b2d35-6d1c-4bd8-8e2b-6d4a0e8b6f5e:" + addr)
+	require.Nil(VerifyMessageSignature(holderPublic, ms, ed25519.Sign(holder, ms)))
+	require.NotNil(VerifyMessageSignature(observerPublic, ms, ed25519.Sign(holder, ms)))
+}
+
+func TestSolanaExtractTransfers(t *testing.T) {
+	require := require.New(t)
+
+	sender := base58.Encode(testSolanaKey("sender").Public().(ed25519.PublicKey))
+	receiver := base58.Encode(testSolanaKey("receiver").Public().(ed25519.PublicKey))
+	transfer := base58.Encode([]byte{2, 0, 0, 0, 0, 202, 154, 59, 0, 0, 0, 0})
+	advance := base58.Encode([]byte{4, 0, 0, 0})
+	data := `{"transaction":{"signatures":["5VERv8NMvzbJMEkV8xnrLkEaWRtSz9CosKDYjCJjBRnbJLgp8uirBgmQpjKhoR4tjF3ZpRzrFmBV6UjKdiSZkQUW"],"message":{"accountKeys":["` + sender + `","` + receiver + `","11111111111111111111111111111111"],"instructions":[{"programIdIndex":2,"accounts":[0,1],"data":"` + advance + `"},{"programIdIndex":2,"accounts":[0,1],"data":"` + transfer + `"}]}},"meta":{"err":null}}`
+
+	var tx RPCTransaction
+	err := json.Unmarshal([]byte(data), &tx)
+	require.Nil(err)
+	transfers := tx.ExtractTransfers()
+	require.Len(transfers, 1)
+	require.Equal(tx.Signature(), transfers[0].Signature)
+	require.Equal(int64(1), transfers[0].Index)
+	require.Equal(sender, transfers[0].Sender)
+	require.Equal(receiver, transfers[0].Receiver)
+	require.Equal(uint64(1000000000), transfers[0].Lamports)
+
+	tx.Meta.Err = map[string]any{"InstructionError": []any{1, "Custom"}}
+	require.Len(tx.ExtractTransfers(), 0)
+}
+
+func testSolanaKey(seed string) ed25519.PrivateKey {
+	h := sha256.Sum256([]byte(seed))
+	return ed25519.NewKeyFromSeed(h[:])
+}
@@ -0,0 +1,160 @@
+package tron
+
+import (
+	"crypto/ecdsa"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strconv"
+	"time"
+
+	"github.com/MixinNetwork/safe/apps/ethereum"
+	"github.com/btcsuite/btcd/btcutil/base58"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	ChainTron = 10
+
+	ValuePrecision = 6
+
+	TronChainId = "25dabac5-056a-48ff-b9f9-f67395dc407c"
+
+	// the base58 address of 0x41 with 20 zero bytes, used as the trx token
+	TronEmptyAddress = "T9yD14Nj9j7xAB4dbGeiX9h8unkKHxuWwb"
+
+	// the solidified block is about 19 blocks behind the latest block
+	TransactionConfirmations = 20
+
+	// the transaction expiration is limited to 24 hours by the network
+	TransactionExpiration = 23 * time.Hour
+
+	// the energy of trc20 transfers is paid by burning trx of the safe
+	TransactionFeeLimit = 100000000
+
+	// the owner permission update fee of the network
+	PermissionUpdateFee = 100000000
+
+	addressPrefix = 0x41
+
+	contractTypeTransfer         = 1
+	contractTypeTriggerSmart     = 31
+	contractTypePermissionUpdate = 46
+
+	trc20TransferMethodId = "a9059cbb"
+)
+
+type Transfer struct {
+	Hash         string
+	Index        int64
+	TokenAddress string
+	AssetId      string
+	Sender       string
+	Receiver     string
+	Value        *big.Int
+}
+
+func GenerateAssetId(assetKey string) string {
+	if assetKey == TronEmptyAddress {
+		return TronChainId
+	}
+	_, err := DecodeAddress(assetKey)
+	if err != nil {
+		panic(assetKey)
+	}
+	return ethereum.BuildChainAssetId(TronChainId, assetKey)
+}
+
+func VerifyHolderKey(public string) error {
+	_, err := parsePublicKey(public)
+	return err
+}
+
+func PublicKeyToAddress(public string) (string, error) {
+	pub, err := parsePublicKey(public)
+	if err != nil {
+		return "", err
+	}
+	b := crypto.PubkeyToAddress(*pub).Bytes()
+	return EncodeAddress(b), nil
+}
+
+func EncodeAddress(b []byte) string {
+	if len(b) != 20 {
+		panic(hex.EncodeToString(b))
+	}
+	return base58.CheckEncode(b, addressPrefix)
+}
+
+// the decoded address is 21 bytes with the 0x41 prefix, the same as the
+// address bytes in the raw transaction
+func DecodeAddress(addr string) ([]byte, error) {
+	b, version, err := base58.CheckDecode(addr)
+	if err != nil || version != addressPrefix || len(b) != 20 {
+		return nil, fmt.Errorf("invalid tron address %s", addr)
+	}
+	return append([]byte{addressPrefix}, b...), nil
+}
+
+func encodeAddressBytes(b []byte) (string, error) {
+	if len(b) != 21 || b[0] != addressPrefix {
+		return "", fmt.Errorf("invalid tron address %x", b)
+	}
+	return EncodeAddress(b[1:]), nil
+}
+
+func HashMessageForSignature(msg []byte) []byte {
+	prefix := "\x19TRON Signed Message:\n" + strconv.Itoa(len(msg))
+	return crypto.Keccak256([]byte(prefix), msg)
+}
+
+func VerifyMessageSignature(public string, msg, sig []byte) error {
+	return VerifyHashSignature(public, HashMessageForSignature(msg), sig)
+}
+
+func VerifyHashSignature(public string, hash, sig []byte) error {
+	_, err := parsePublicKey(public)
+	if err != nil {
+		return err
+	}
+	if len(sig) != 65 {
+		return fmt.Errorf("invalid tron signature %x", sig)
+	}
+	pub, _ := hex.DecodeString(public)
+	if crypto.VerifySignature(pub, hash, sig[:64]) {
+		return nil
+	}
+	return fmt.Errorf("crypto.VerifySignature(%s, %x, %x)", public, hash, sig)
+}
+
+// the signer returns the recovery id in the last byte instead of v
+func ProcessSignature(signature []byte) []byte {
+	if signature[64] < 27 {
+		signature[64] += 27
+	}
+	return signature
+}
+
+func ParseAmount(amount string, decimals int32) (*big.Int, error) {
+	amt, err := decimal.NewFromString(amount)
+	if err != nil || !amt.IsPositive() {
+		return nil, fmt.Errorf("invalid tron amount %s", amount)
+	}
+	if !amt.Equal(amt.Truncate(decimals)) {
+		return nil, fmt.Errorf("invalid tron amount %s", amount)
+	}
+	return amt.Shift(decimals).BigInt(), nil
+}
+
+func parsePublicKey(public string) (*ecdsa.PublicKey, error) {
+	b, err := hex.DecodeString(public)
+	if err != nil || len(b) != 33 {
+		return nil, fmt.Errorf("invalid tron key %s", public)
+	}
+	pub, err := crypto.DecompressPubkey(b)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tron key %s", public)
+	}
+	return pub, nil
+}
@@ -0,0 +1,297 @@
+package tron
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+type RPCBlock struct {
+	BlockID     string `json:"blockID"`
+	BlockHeader struct {
+		RawData struct {
+			Number    int64 `json:"number"`
+			Timestamp int64 `json:"timestamp"`
+		} `json:"raw_data"`
+	} `json:"block_header"`
+	Transactions []*RPCTransaction `json:"transactions"`
+}
+
+type RPCTransaction struct {
+	TxID    string `json:"txID"`
+	RawData struct {
+		Contract []struct {
+			Type      string `json:"type"`
+			Parameter struct {
+				Value struct {
+					OwnerAddress    string `json:"owner_address"`
+					ToAddress       string `json:"to_address"`
+					Amount          int64  `json:"amount"`
+					ContractAddress string `json:"contract_address"`
+					CallValue       int64  `json:"call_value"`
+					Data            string `json:"data"`
+				} `json:"value"`
+			} `json:"parameter"`
+		} `json:"contract"`
+	} `json:"raw_data"`
+	Ret []struct {
+		ContractRet string `json:"contractRet"`
+	} `json:"ret"`
+}
+
+type RPCTransactionInfo struct {
+	Id          string `json:"id"`
+	BlockNumber int64  `json:"blockNumber"`
+	Receipt     struct {
+		Result string `json:"result"`
+	} `json:"receipt"`
+}
+
+func (b *RPCBlock) Height() int64 {
+	return b.BlockHeader.RawData.Number
+}
+
+// only the trx transfers and the direct trc20 transfer calls are recognized
+// as deposits, and the transaction has only one contract with index 0
+func (tx *RPCTransaction) ExtractTransfers() []*Transfer {
+	if len(tx.RawData.Contract) != 1 || len(tx.Ret) != 1 {
+		return nil
+	}
+	if tx.Ret[0].ContractRet != "SUCCESS" {
+		return nil
+	}
+	c := tx.RawData.Contract[0]
+	sender, err := encodeHexAddress(c.Parameter.Value.OwnerAddress)
+	if err != nil {
+		return nil
+	}
+	switch c.Type {
+	case "TransferContract":
+		receiver, err := encodeHexAddress(c.Parameter.Value.ToAddress)
+		if err != nil || c.Parameter.Value.Amount <= 0 {
+			return nil
+		}
+		return []*Transfer{{
+			Hash:         tx.TxID,
+			Index:        0,
+			TokenAddress: TronEmptyAddress,
+			AssetId:      TronChainId,
+			Sender:       sender,
+			Receiver:     receiver,
+			Value:        big.NewInt(c.Parameter.Value.Amount),
+		}}
+	case "TriggerSmartContract":
+		token, err := encodeHexAddress(c.Parameter.Value.ContractAddress)
+		if err != nil || c.Parameter.Value.CallValue != 0 {
+			return nil
+		}
+		data, err := hex.DecodeString(c.Parameter.Value.Data)
+		if err != nil {
+			return nil
+		}
+		receiver, amount, err := parseTRC20TransferData(data)
+		if err != nil || amount.Sign() <= 0 {
+			return nil
+		}
+		return []*Transfer{{
+			Hash:         tx.TxID,
+			Index:        0,
+			TokenAddress: token,
+			AssetId:      GenerateAssetId(token),
+			Sender:       sender,
+			Receiver:     receiver,
+			Value:        amount,
+		}}
+	}
+	return nil
+}
+
+func encodeHexAddress(s string) (string, error) {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return "", err
+	}
+	return encodeAddressBytes(b)
+}
+
+func RPCGetNowBlock(rpc string) (*RPCBlock, error) {
+	res, err := callTronRPCUntilSufficient(rpc, "/wallet/getnowblock", map[string]any{})
+	if err != nil {
+		return nil, err
+	}
+	var b RPCBlock
+	err = json.Unmarshal(res, &b)
+	return &b, err
+}
+
+func RPCGetBlockByNumber(rpc string, num int64) (*RPCBlock, error) {
+	res, err := callTronRPCUntilSufficient(rpc, "/wallet/getblockbynum", map[string]any{"num": num})
+	if err != nil {
+		return nil, err
+	}
+	var b RPCBlock
+	err = json.Unmarshal(res, &b)
+	if err != nil {
+		return nil, err
+	}
+	if b.BlockID == "" {
+		return nil, nil
+	}
+	return &b, nil
+}
+
+func RPCGetTransaction(rpc, id string) (*RPCTransaction, *RPCTransactionInfo, error) {
+	params := map[string]any{"value": id}
+	res, err := callTronRPCUntilSufficient(rpc, "/wallet/gettransactionbyid", params)
+	if err != nil {
+		return nil, nil, err
+	}
+	var tx RPCTransaction
+	err = json.Unmarshal(res, &tx)
+	if err != nil || tx.TxID == "" {
+		return nil, nil, err
+	}
+	res, err = callTronRPCUntilSufficient(rpc, "/wallet/gettransactioninfobyid", params)
+	if err != nil {
+		return nil, nil, err
+	}
+	var info RPCTransactionInfo
+	err = json.Unmarshal(res, &info)
+	if err != nil || info.Id == "" {
+		return &tx, nil, err
+	}
+	return &tx, &info, nil
+}
+
+func RPCGetBalance(rpc, addr string) (int64, error) {
+	res, err := callTronRPCUntilSufficient(rpc, "/wallet/getaccount", map[string]any{"address": addr, "visible": true})
+	if err != nil {
+		return 0, err
+	}
+	var account struct {
+		Balance int64 `json:"balance"`
+	}
+	err = json.Unmarshal(res, &account)
+	return account.Balance, err
+}
+
+func RPCBroadcastTransaction(rpc string, raw []byte) (string, error) {
+	res, err := callTronRPCUntilSufficient(rpc, "/wallet/broadcasthex", map[string]any{"transaction": hex.EncodeToString(raw)})
+	if err != nil {
+		return "", err
+	}
+	var result struct {
+		Result  bool   `json:"result"`
+		TxId    string `json:"txid"`
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	}
+	err = json.Unmarshal(res, &result)
+	if err != nil {
+		return "", err
+	}
+	if !result.Result {
+		msg, _ := hex.DecodeString(result.Message)
+		return "", fmt.Errorf("tron.RPCBroadcastTransaction() => %s %s", result.Code, string(msg))
+	}
+	return result.TxId, nil
+}
+
+// the trx transfer from a normal account, used by the observer to pay the
+// account activation and the transaction fees of safe accounts
+func TransferFromPrivateKey(rpc, priv, receiver string, amount int64) (string, error) {
+	key, err := crypto.HexToECDSA(priv)
+	if err != nil {
+		return "", err
+	}
+	sender := EncodeAddress(crypto.PubkeyToAddress(key.PublicKey).Bytes())
+	block, err := RPCGetNowBlock(rpc)
+	if err != nil {
+		return "", err
+	}
+	ref := &ReferenceBlock{Height: uint64(block.Height()), Hash: block.BlockID}
+	out := &Output{TokenAddress: TronEmptyAddress, Destination: receiver, Amount: big.NewInt(amount)}
+	st, err := BuildTransaction(sender, out, ref, time.Now())
+	if err != nil {
+		return "", err
+	}
+	st.Signature, err = crypto.Sign(st.TransactionId(), key)
+	if err != nil {
+		return "", err
+	}
+	return RPCBroadcastTransaction(rpc, st.Serialize())
+}
+
+func callTronRPCUntilSufficient(rpc, path string, params map[string]any) ([]byte, error) {
+	for {
+		res, err := callTronRPC(rpc, path, params)
+		if err != nil {
+			reason := strings.ToLower(err.Error())
+			switch {
+			case strings.Contains(reason, "timeout"):
+			case strings.Contains(reason, "eof"):
+			case strings.Contains(reason, "handshake"):
+			case strings.Contains(reason, "too many requests"):
+			case strings.Contains(reason, "invalid character '<'"):
+			default:
+				return res, err
+			}
+			time.Sleep(7 * time.Second)
+			continue
+		}
+		return res, err
+	}
+}
+
+func callTronRPC(rpc, path string, params map[string]any) ([]byte, error) {
+	client := &http.Client{Timeout: 20 * time.Second}
+
+	body, err := json.Marshal(params)
+	if err != nil {
+		panic(err)
+	}
+	req, err := http.NewRequest("POST", strings.TrimSuffix(rpc, "/")+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, buildRPCError(rpc, path, params, err)
+	}
+
+	req.Close = true
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, buildRPCError(rpc, path, params, err)
+	}
+	defer resp.Body.Close()
+
+	body, err = io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, buildRPCError(rpc, path, params, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		err = fmt.Errorf("%d %s", resp.StatusCode, strings.ToLower(string(body)))
+		return nil, buildRPCError(rpc, path, params, err)
+	}
+	var result struct {
+		Error string `json:"Error"`
+	}
+	err = json.Unmarshal(body, &result)
+	if err != nil {
+		return nil, fmt.Errorf("%v (%s)", buildRPCError(rpc, path, params, err), string(body))
+	}
+	if result.Error != "" {
+		return nil, buildRPCError(rpc, path, params, fmt.Errorf("%s", result.Error))
+	}
+	return body, nil
+}
+
+func buildRPCError(rpc, path string, params map[string]any, err error) error {
+	return fmt.Errorf("callTronRPC(%s, %s, %v) => %v", rpc, path, params, err)
+}
@@ -0,0 +1,449 @@
+package tron
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+type Output struct {
+	TokenAddress string
+	Destination  string
+	Amount       *big.Int
+}
+
+// the reference block is the latest network info written by the observer,
+// and the transaction is rejected by the network if the block is too old
+type ReferenceBlock struct {
+	Height uint64
+	Hash   string
+}
+
+// the raw data has exactly one contract owned by the safe address, the
+// holder signature is the approval of the holder or the observer, and the
+// signature is made by the signer whose key is also the safe address
+type SafeTransaction struct {
+	RawData         []byte
+	HolderSignature []byte
+	Signature       []byte
+}
+
+type protoField struct {
+	num    int
+	varint uint64
+	bytes  []byte
+}
+
+func BuildTransaction(safe string, out *Output, block *ReferenceBlock, timestamp time.Time) (*SafeTransaction, error) {
+	owner, err := DecodeAddress(safe)
+	if err != nil {
+		return nil, err
+	}
+	if out.Destination == safe || out.Amount == nil || out.Amount.Sign() <= 0 {
+		return nil, fmt.Errorf("invalid tron output %v", out)
+	}
+	receiver, err := DecodeAddress(out.Destination)
+	if err != nil {
+		return nil, err
+	}
+
+	var contract []byte
+	switch out.TokenAddress {
+	case TronEmptyAddress:
+		if !out.Amount.IsInt64() {
+			return nil, fmt.Errorf("invalid tron amount %s", out.Amount)
+		}
+		var value []byte
+		value = appendBytesField(value, 1, owner)
+		value = appendBytesField(value, 2, receiver)
+		value = appendVarintField(value, 3, out.Amount.Uint64())
+		contract = buildContract(contractTypeTransfer, "TransferContract", value)
+	default:
+		token, err := DecodeAddress(out.TokenAddress)
+		if err != nil {
+			return nil, err
+		}
+		if out.Amount.BitLen() > 256 {
+			return nil, fmt.Errorf("invalid tron amount %s", out.Amount)
+		}
+		data, _ := hex.DecodeString(trc20TransferMethodId)
+		data = append(data, make([]byte, 12)...)
+		data = append(data, receiver[1:]...)
+		data = append(data, out.Amount.FillBytes(make([]byte, 32))...)
+		var value []byte
+		value = appendBytesField(value, 1, owner)
+		value = appendBytesField(value, 2, token)
+		value = appendBytesField(value, 4, data)
+		contract = buildContract(contractTypeTriggerSmart, "TriggerSmartContract", value)
+	}
+
+	raw, err := buildRawData(block, timestamp, contract, TransactionFeeLimit)
+	if err != nil {
+		return nil, err
+	}
+	return &SafeTransaction{RawData: raw}, nil
+}
+
+// the owner and active permissions both need the signer key with either the
+// holder or observer key, so the holder and observer could not spend without
+// the signer, which is only requested by the keeper after the timelock for the
+// observer recovery
+func BuildPermissionUpdateTransaction(safe, holder, signer, observer string, block *ReferenceBlock, timestamp time.Time) (*SafeTransaction, error) {
+	addr, err := PublicKeyToAddress(signer)
+	if err != nil || addr != safe {
+		return nil, fmt.Errorf("invalid tron safe %s %s", safe, signer)
+	}
+	owner, _ := DecodeAddress(safe)
+
+	// the holder, signer and observer weights with the threshold 3
+	weights, threshold := []uint64{1, 2, 1}, uint64(3)
+	var keys []byte
+	for i, pub := range []string{holder, signer, observer} {
+		addr, err := PublicKeyToAddress(pub)
+		if err != nil {
+			return nil, err
+		}
+		b, _ := DecodeAddress(addr)
+		var key []byte
+		key = appendBytesField(key, 1, b)
+		key = appendVarintField(key, 2, weights[i])
+		keys = appendBytesField(keys, 7, key)
+	}
+	operations := make([]byte, 32)
+	for _, typ := range []int{contractTypeTransfer, contractTypeTriggerSmart} {
+		operations[typ/8] |= 1 << (typ % 8)
+	}
+
+	var op []byte
+	op = appendBytesField(op, 3, []byte("owner"))
+	op = appendVarintField(op, 4, threshold)
+	op = append(op, keys...)
+	var ap []byte
+	ap = appendVarintField(ap, 1, 2)
+	ap = appendVarintField(ap, 2, 2)
+	ap = appendBytesField(ap, 3, []byte("active"))
+	ap = appendVarintField(ap, 4, threshold)
+	ap = appendBytesField(ap, 6, operations)
+	ap = append(ap, keys...)
+
+	var value []byte
+	value = appendBytesField(value, 1, owner)
+	value = appendBytesField(value, 2, op)
+	value = appendBytesField(value, 4, ap)
+	contract := buildContract(contractTypePermissionUpdate, "AccountPermissionUpdateContract", value)
+
+	raw, err := buildRawData(block, timestamp, contract, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &SafeTransaction{RawData: raw}, nil
+}
+
+func buildContract(typ int, name string, value []byte) []byte {
+	var param []byte
+	param = appendBytesField(param, 1, []byte("type.googleapis.com/protocol."+name))
+	param = appendBytesField(param, 2, value)
+	var contract []byte
+	contract = appendVarintField(contract, 1, uint64(typ))
+	return appendBytesField(contract, 2, param)
+}
+
+func buildRawData(block *ReferenceBlock, timestamp time.Time, contract []byte, feeLimit int64) ([]byte, error) {
+	hash, err := hex.DecodeString(block.Hash)
+	if err != nil || len(hash) != 32 {
+		return nil, fmt.Errorf("invalid tron block %v", block)
+	}
+	height := binary.BigEndian.AppendUint64(nil, block.Height)
+	ts := timestamp.UnixMilli()
+
+	var raw []byte
+	raw = appendBytesField(raw, 1, height[6:8])
+	raw = appendBytesField(raw, 4, hash[8:16])
+	raw = appendVarintField(raw, 8, uint64(ts+TransactionExpiration.Milliseconds()))
+	raw = appendBytesField(raw, 11, contract)
+	raw = appendVarintField(raw, 14, uint64(ts))
+	if feeLimit > 0 {
+		raw = appendVarintField(raw, 18, uint64(feeLimit))
+	}
+	return raw, nil
+}
+
+func appendVarintField(b []byte, num int, v uint64) []byte {
+	b = binary.AppendUvarint(b, uint64(num)<<3)
+	return binary.AppendUvarint(b, v)
+}
+
+func appendBytesField(b []byte, num int, v []byte) []byte {
+	b = binary.AppendUvarint(b, uint64(num)<<3|2)
+	b = binary.AppendUvarint(b, uint64(len(v)))
+	return append(b, v...)
+}
+
+// only the varint and length delimited fields are used by the transactions
+func decodeProtoFields(b []byte) ([]*protoField, error) {
+	var fields []*protoField
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		if n <= 0 {
+			return nil, fmt.Errorf("invalid protobuf key %x", b)
+		}
+		b = b[n:]
+		f := &protoField{num: int(key >> 3)}
+		switch key & 7 {
+		case 0:
+			f.varint, n = binary.Uvarint(b)
+			if n <= 0 {
+				return nil, fmt.Errorf("invalid protobuf varint %x", b)
+			}
+			b = b[n:]
+		case 2:
+			l, n := binary.Uvarint(b)
+			if n <= 0 || uint64(len(b)-n) < l {
+				return nil, fmt.Errorf("invalid protobuf bytes %x", b)
+			}
+			f.bytes, b = b[n:n+int(l)], b[n+int(l):]
+		default:
+			return nil, fmt.Errorf("invalid protobuf wire type %d", key&7)
+		}
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+func findProtoField(fields []*protoField, num int) *protoField {
+	for _, f := range fields {
+		if f.num == num {
+			return f
+		}
+	}
+	return nil
+}
+
+func (st *SafeTransaction) parseContract() (int, []*protoField, error) {
+	raw, err := decodeProtoFields(st.RawData)
+	if err != nil {
+		return 0, nil, err
+	}
+	var contracts []*protoField
+	for _, f := range raw {
+		if f.num == 11 {
+			contracts = append(contracts, f)
+		}
+	}
+	if len(contracts) != 1 {
+		return 0, nil, fmt.Errorf("invalid tron contracts count %d", len(contracts))
+	}
+	contract, err := decodeProtoFields(contracts[0].bytes)
+	if err != nil {
+		return 0, nil, err
+	}
+	typ, param := findProtoField(contract, 1), findProtoField(contract, 2)
+	if typ == nil || param == nil {
+		return 0, nil, fmt.Errorf("invalid tron contract %x", contracts[0].bytes)
+	}
+	fields, err := decodeProtoFields(param.bytes)
+	if err != nil {
+		return 0, nil, err
+	}
+	value := findProtoField(fields, 2)
+	if value == nil {
+		return 0, nil, fmt.Errorf("invalid tron contract parameter %x", param.bytes)
+	}
+	fields, err = decodeProtoFields(value.bytes)
+	return int(typ.varint), fields, err
+}
+
+// the transaction id is the sha256 of the raw data, and it is also used
+// as the keeper transaction hash and the signer message
+func (st *SafeTransaction) Hash() string {
+	return hex.EncodeToString(st.TransactionId())
+}
+
+func (st *SafeTransaction) TransactionId() []byte {
+	sum := sha256.Sum256(st.RawData)
+	return sum[:]
+}
+
+func (st *SafeTransaction) Owner() (string, error) {
+	_, fields, err := st.parseContract()
+	if err != nil {
+		return "", err
+	}
+	owner := findProtoField(fields, 1)
+	if owner == nil {
+		return "", fmt.Errorf("invalid tron contract owner %x", st.RawData)
+	}
+	return encodeAddressBytes(owner.bytes)
+}
+
+func (st *SafeTransaction) Expiration() time.Time {
+	raw, err := decodeProtoFields(st.RawData)
+	if err != nil {
+		return time.Time{}
+	}
+	f := findProtoField(raw, 8)
+	if f == nil {
+		return time.Time{}
+	}
+	return time.UnixMilli(int64(f.varint)).UTC()
+}
+
+func (st *SafeTransaction) ExtractOutputs() ([]*Output, error) {
+	typ, fields, err := st.parseContract()
+	if err != nil {
+		return nil, err
+	}
+	switch typ {
+	case contractTypeTransfer:
+		to, amount := findProtoField(fields, 2), findProtoField(fields, 3)
+		if to == nil || amount == nil {
+			return nil, fmt.Errorf("invalid tron transfer %x", st.RawData)
+		}
+		receiver, err := encodeAddressBytes(to.bytes)
+		if err != nil {
+			return nil, err
+		}
+		return []*Output{{
+			TokenAddress: TronEmptyAddress,
+			Destination:  receiver,
+			Amount:       new(big.Int).SetUint64(amount.varint),
+		}}, nil
+	case contractTypeTriggerSmart:
+		contract, data := findProtoField(fields, 2), findProtoField(fields, 4)
+		if contract == nil || data == nil || findProtoField(fields, 3) != nil {
+			return nil, fmt.Errorf("invalid tron trigger %x", st.RawData)
+		}
+		token, err := encodeAddressBytes(contract.bytes)
+		if err != nil {
+			return nil, err
+		}
+		receiver, amount, err := parseTRC20TransferData(data.bytes)
+		if err != nil {
+			return nil, err
+		}
+		return []*Output{{
+			TokenAddress: token,
+			Destination:  receiver,
+			Amount:       amount,
+		}}, nil
+	default:
+		return nil, fmt.Errorf("invalid tron contract type %d", typ)
+	}
+}
+
+func parseTRC20TransferData(data []byte) (string, *big.Int, error) {
+	if len(data) != 68 || hex.EncodeToString(data[:4]) != trc20TransferMethodId {
+		return "", nil, fmt.Errorf("invalid trc20 transfer %x", data)
+	}
+	if !bytes.Equal(data[4:16], make([]byte, 12)) {
+		return "", nil, fmt.Errorf("invalid trc20 transfer %x", data)
+	}
+	return EncodeAddress(data[16:36]), new(big.Int).SetBytes(data[36:]), nil
+}
+
+func (st *SafeTransaction) IsPermissionUpdate() bool {
+	typ, _, err := st.parseContract()
+	return err == nil && typ == contractTypePermissionUpdate
+}
+
+func (st *SafeTransaction) signedBy(sig []byte) string {
+	if len(sig) != 65 {
+		return ""
+	}
+	rsv := bytes.Clone(sig)
+	if rsv[64] >= 27 {
+		rsv[64] -= 27
+	}
+	pub, err := crypto.SigToPub(st.TransactionId(), rsv)
+	if err != nil {
+		return ""
+	}
+	return EncodeAddress(crypto.PubkeyToAddress(*pub).Bytes())
+}
+
+// the signer key is the safe address, and the holder signature is required
+// for all transactions except the permission update
+func (st *SafeTransaction) IsFullySigned() bool {
+	owner, err := st.Owner()
+	if err != nil || st.signedBy(st.Signature) != owner {
+		return false
+	}
+	if st.IsPermissionUpdate() {
+		return true
+	}
+	holder := st.signedBy(st.HolderSignature)
+	return holder != "" && holder != owner
+}
+
+// the wire format of the protobuf transaction
+func (st *SafeTransaction) Serialize() []byte {
+	raw := appendBytesField(nil, 1, st.RawData)
+	for _, sig := range [][]byte{st.HolderSignature, st.Signature} {
+		if len(sig) > 0 {
+			raw = appendBytesField(raw, 2, sig)
+		}
+	}
+	return raw
+}
+
+func (st *SafeTransaction) Marshal() []byte {
+	enc := common.NewEncoder()
+	writeBytes(enc, st.RawData)
+	writeBytes(enc, st.HolderSignature)
+	writeBytes(enc, st.Signature)
+	return enc.Bytes()
+}
+
+func UnmarshalSafeTransaction(b []byte) (*SafeTransaction, error) {
+	dec := common.NewDecoder(b)
+	raw, err := dec.ReadBytes()
+	if err != nil {
+		return nil, err
+	}
+	hs, err := dec.ReadBytes()
+	if err != nil {
+		return nil, err
+	}
+	sig, err := dec.ReadBytes()
+	if err != nil {
+		return nil, err
+	}
+	return &SafeTransaction{RawData: raw, HolderSignature: hs, Signature: sig}, nil
+}
+
+func CheckTransactionPartiallySignedBy(raw, public string) bool {
+	b, err := hex.DecodeString(raw)
+	if err != nil {
+		return false
+	}
+	st, err := UnmarshalSafeTransaction(b)
+	if err != nil {
+		return false
+	}
+	err = VerifyHashSignature(public, st.TransactionId(), st.HolderSignature)
+	return err == nil
+}
+
+func CheckTransactionFullySigned(raw string) bool {
+	b, err := hex.DecodeString(raw)
+	if err != nil {
+		return false
+	}
+	st, err := UnmarshalSafeTransaction(b)
+	if err != nil {
+		return false
+	}
+	return st.IsFullySigned()
+}
+
+func writeBytes(enc *common.Encoder, b []byte) {
+	enc.WriteInt(len(b))
+	enc.Write(b)
+}
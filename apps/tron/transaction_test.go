@@ -0,0 +1,161 @@
+package tron
+
+import (
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTronSafeTransaction(t *testing.T) {
+	require := require.New(t)
+
+	holder := testTronKey("holder")
+	signer := testTronKey("signer")
+	observer := testTronKey("observer")
+	holderPublic := hex.EncodeToString(crypto.CompressPubkey(&holder.PublicKey))
+	signerPublic := hex.EncodeToString(crypto.CompressPubkey(&signer.PublicKey))
+	observerPublic := hex.EncodeToString(crypto.CompressPubkey(&observer.PublicKey))
+	require.Nil(VerifyHolderKey(holderPublic))
+	require.NotNil(VerifyHolderKey("invalid"))
+
+	require.Equal(TronEmptyAddress, EncodeAddress(make([]byte, 20)))
+	require.Equal(TronChainId, GenerateAssetId(TronEmptyAddress))
+	require.Equal("b91e18ff-a9ae-3dc7-8679-e935d9a4b34b", GenerateAssetId("TR7NHqjeKQxGTCi8q8ZY4pL8otSzgjLj6t"))
+
+	addr, err := PublicKeyToAddress(signerPublic)
+	require.Nil(err)
+	b, err := DecodeAddress(addr)
+	require.Nil(err)
+	require.Equal(byte(0x41), b[0])
+	require.Equal(crypto.PubkeyToAddress(signer.PublicKey).Bytes(), b[1:])
+	_, err = DecodeAddress(addr + "1")
+	require.NotNil(err)
+
+	block := &ReferenceBlock{Height: 65000000, Hash: hex.EncodeToString(make([]byte, 32))}
+	now := time.Unix(1700000000, 0)
+	pt, err := BuildPermissionUpdateTransaction(addr, holderPublic, signerPublic, observerPublic, block, now)
+	require.Nil(err)
+	require.True(pt.IsPermissionUpdate())
+	require.Equal(now.Add(23*time.Hour).UTC(), pt.Expiration())
+	_, err = pt.ExtractOutputs()
+	require.NotNil(err)
+	_, fields, err := pt.parseContract()
+	require.Nil(err)
+	for _, num := range []int{2, 4} {
+		permission, err := decodeProtoFields(findProtoField(fields, num).bytes)
+		require.Nil(err)
+		require.Equal(uint64(3), findProtoField(permission, 4).varint)
+		var weights []uint64
+		for _, f := range permission {
+			if f.num != 7 {
+				continue
+			}
+			key, err := decodeProtoFields(f.bytes)
+			require.Nil(err)
+			weights = append(weights, findProtoField(key, 2).varint)
+		}
+		require.Equal([]uint64{1, 2, 1}, weights)
+	}
+	_, err = BuildPermissionUpdateTransaction(addr, holderPublic, observerPublic, signerPublic, block, now)
+	require.NotNil(err)
+	pt.Signature, err = crypto.Sign(pt.TransactionId(), signer)
+	require.Nil(err)
+	require.True(pt.IsFullySigned())
+
+	receiver, _ := PublicKeyToAddress(observerPublic)
+	out := &Output{TokenAddress: TronEmptyAddress, Destination: receiver, Amount: big.NewInt(1234567)}
+	st, err := BuildTransaction(addr, out, block, now)
+	require.Nil(err)
+	require.False(st.IsPermissionUpdate())
+	owner, err := st.Owner()
+	require.Nil(err)
+	require.Equal(addr, owner)
+	outputs, err := st.ExtractOutputs()
+	require.Nil(err)
+	require.Len(outputs, 1)
+	require.Equal(out, outputs[0])
+	_, err = BuildTransaction(addr, &Output{TokenAddress: TronEmptyAddress, Destination: addr, Amount: big.NewInt(1)}, block, now)
+	require.NotNil(err)
+
+	usdt := &Output{TokenAddress: "TR7NHqjeKQxGTCi8q8ZY4pL8otSzgjLj6t", Destination: receiver, Amount: big.NewInt(100000000)}
+	ut, err := BuildTransaction(addr, usdt, block, now)
+	require.Nil(err)
+	outputs, err = ut.ExtractOutputs()
+	require.Nil(err)
+	require.Len(outputs, 1)
+	require.Equal(usdt, outputs[0])
+
+	hash := sha256.Sum256(st.RawData)
+	require.Equal(hex.EncodeToString(hash[:]), st.Hash())
+
+	st.HolderSignature, err = crypto.Sign(st.TransactionId(), holder)
+	require.Nil(err)
+	st.HolderSignature = ProcessSignature(st.HolderSignature)
+	raw := hex.EncodeToString(st.Marshal())
+	require.True(CheckTransactionPartiallySignedBy(raw, holderPublic))
+	require.False(CheckTransactionPartiallySignedBy(raw, observerPublic))
+	require.False(CheckTransactionFullySigned(raw))
+
+	st.Signature, err = crypto.Sign(st.TransactionId(), signer)
+	require.Nil(err)
+	raw = hex.EncodeToString(st.Marshal())
+	require.True(CheckTransactionFullySigned(raw))
+
+	parsed, err := UnmarshalSafeTransaction(st.Marshal())
+	require.Nil(err)
+	require.Equal(st.Hash(), parsed.Hash())
+	require.Equal(st.HolderSignature, parsed.HolderSignature)
+	require.Equal(st.Signature, parsed.Signature)
+
+	wire, err := decodeProtoFields(st.Serialize())
+	require.Nil(err)
+	require.Len(wire, 3)
+	require.Equal(st.RawData, wire[0].bytes)
+	require.Equal(st.HolderSignature, wire[1].bytes)
+	require.Equal(st.Signature, wire[2].bytes)
+
+	ms := []byte("APPROVE:9f3b2d35-6d1c-4bd8-8e2b-6d4a0e8b6f5e:" + addr)
+	sig, err := crypto.Sign(HashMessageForSignature(ms), holder)
+	require.Nil(err)
+	require.Nil(VerifyMessageSignature(holderPublic, ms, sig))
+	require.NotNil(VerifyMessageSignature(observerPublic, ms, sig))
+}
+
+func TestTronExtractTransfers(t *testing.T) {
+	require := require.New(t)
+
+	sender, _ := DecodeAddress("TKHuVq1oKVruCGLvqVexFs6dawKv6fQgFs")
+	receiver, _ := DecodeAddress("TR7NHqjeKQxGTCi8q8ZY4pL8otSzgjLj6t")
+	data := `{"txID":"0b6f0e4d0ff6a4d4b0b8b8a9f4b8c2b5b7f5f7b2f1c8e8f0e4d9b1a6e2c3d4e5","raw_data":{"contract":[{"type":"TransferContract","parameter":{"value":{"owner_address":"` + hex.EncodeToString(sender) + `","to_address":"` + hex.EncodeToString(receiver) + `","amount":1000000}}}]},"ret":[{"contractRet":"SUCCESS"}]}`
+
+	var tx RPCTransaction
+	err := json.Unmarshal([]byte(data), &tx)
+	require.Nil(err)
+	transfers := tx.ExtractTransfers()
+	require.Len(transfers, 1)
+	require.Equal(tx.TxID, transfers[0].Hash)
+	require.Equal(TronEmptyAddress, transfers[0].TokenAddress)
+	require.Equal(TronChainId, transfers[0].AssetId)
+	require.Equal("TKHuVq1oKVruCGLvqVexFs6dawKv6fQgFs", transfers[0].Sender)
+	require.Equal("TR7NHqjeKQxGTCi8q8ZY4pL8otSzgjLj6t", transfers[0].Receiver)
+	require.Equal(int64(1000000), transfers[0].Value.Int64())
+
+	tx.Ret[0].ContractRet = "REVERT"
+	require.Len(tx.ExtractTransfers(), 0)
+}
+
+func testTronKey(seed string) *ecdsa.PrivateKey {
+	h := sha256.Sum256([]byte(seed))
+	key, err := crypto.ToECDSA(h[:])
+	if err != nil {
+		panic(err)
+	}
+	return key
+}
@@ -5,12 +5,14 @@ import (
 	"crypto/rand"
 	"encoding/base64"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"os"
 
 	"github.com/MixinNetwork/safe/apps/bitcoin"
 	"github.com/MixinNetwork/safe/common"
 	"github.com/MixinNetwork/safe/config"
+	"github.com/MixinNetwork/safe/keeper"
 	"github.com/btcsuite/btcd/btcec/v2"
 	"github.com/btcsuite/btcd/btcec/v2/ecdsa"
 	"github.com/fox-one/mixin-sdk-go/v2"
@@ -71,6 +73,38 @@ func GenerateTestSafeProposal(c *cli.Context) error {
 	return makeKeeperPaymentRequest(c.String("config"), assetId, amount, sid, memo)
 }
 
+func ExportSafeDescriptor(c *cli.Context) error {
+	ctx := context.Background()
+
+	mc, err := config.ReadConfiguration(c.String("config"), "observer")
+	if err != nil {
+		return err
+	}
+	kd, err := keeper.OpenSQLite3ReadOnlyStore(mc.Observer.KeeperStoreDir + "/safe.sqlite3")
+	if err != nil {
+		return err
+	}
+	defer kd.Close()
+
+	safe, err := kd.ReadSafe(ctx, c.String("holder"))
+	if err != nil {
+		return err
+	}
+	if safe == nil {
+		return fmt.Errorf("safe not found %s", c.String("holder"))
+	}
+	sd, err := keeper.BuildBitcoinSafeDescriptor(ctx, kd, safe, c.StringSlice("xpub"))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("address: %s\ndescriptor: %s\n", safe.Address, sd.Descriptor)
+	if sd.Policy != nil {
+		keys, _ := json.Marshal(sd.Policy.Keys)
+		fmt.Printf("policy: %s\nkeys: %s\nindex: %d\n", sd.Policy.Template, keys, sd.Policy.Index)
+	}
+	return nil
+}
+
 func testPublicKey(priv string) string {
 	seed, _ := hex.DecodeString(priv)
 	_, dk := btcec.PrivKeyFromBytes(seed)
@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/MixinNetwork/safe/config"
+	"github.com/MixinNetwork/safe/custodian"
+	"github.com/MixinNetwork/trusted-group/mtg"
+	"github.com/fox-one/mixin-sdk-go/v2"
+	"github.com/fox-one/mixin-sdk-go/v2/mixinnet"
+	"github.com/urfave/cli/v2"
+)
+
+func CustodianBootCmd(c *cli.Context) error {
+	ctx := context.Background()
+
+	version := c.App.Metadata["VERSION"].(string)
+	ua := fmt.Sprintf("Mixin Safe Custodian (%s)", version)
+	resty := mixin.GetRestyClient()
+	resty.SetTimeout(time.Second * 30)
+	resty.SetHeader("User-Agent", ua)
+
+	mc, err := config.ReadConfiguration(c.String("config"), "custodian")
+	if err != nil {
+		return err
+	}
+	mc.Custodian.MTG.GroupSize = 1
+
+	db, err := mtg.OpenSQLite3Store(mc.Custodian.StoreDir + "/mtg.sqlite3")
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	group, err := mtg.BuildGroup(ctx, db, mc.Custodian.MTG)
+	if err != nil {
+		return err
+	}
+	group.EnableDebug()
+	group.SetKernelRPC(mc.Custodian.MixinRPC)
+
+	s := &mixin.Keystore{
+		ClientID:          mc.Custodian.MTG.App.AppId,
+		SessionID:         mc.Custodian.MTG.App.SessionId,
+		SessionPrivateKey: mc.Custodian.MTG.App.SessionPrivateKey,
+		ServerPublicKey:   mc.Custodian.MTG.App.ServerPublicKey,
+	}
+	client, err := mixin.NewFromKeystore(s)
+	if err != nil {
+		return err
+	}
+	me, err := client.UserMe(ctx)
+	if err != nil {
+		return err
+	}
+	key, err := mixinnet.ParseKeyWithPub(mc.Custodian.MTG.App.SpendPrivateKey, me.SpendPublicKey)
+	if err != nil {
+		return err
+	}
+	mc.Custodian.MTG.App.SpendPrivateKey = key.String()
+
+	cd, err := custodian.OpenSQLite3Store(mc.Custodian.StoreDir + "/custodian.sqlite3")
+	if err != nil {
+		return err
+	}
+	defer cd.Close()
+	worker := custodian.NewWorker(cd, group, mc.Custodian, mc.Signer.MTG, mc.Keeper.MTG, client)
+	worker.Boot(ctx)
+
+	group.AttachWorker(mc.Custodian.AppId, worker)
+	group.Run(ctx)
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"context"
 	ce "crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/hmac"
 	"crypto/sha512"
 	"encoding/binary"
@@ -17,16 +18,18 @@ import (
 	"github.com/MixinNetwork/mixin/crypto"
 	"github.com/MixinNetwork/safe/apps/bitcoin"
 	"github.com/MixinNetwork/safe/apps/ethereum"
+	m "github.com/MixinNetwork/safe/apps/mixin"
+	"github.com/MixinNetwork/safe/apps/solana"
 	"github.com/MixinNetwork/safe/common"
 	"github.com/MixinNetwork/safe/config"
 	"github.com/MixinNetwork/safe/keeper"
 	"github.com/MixinNetwork/safe/observer"
 	"github.com/btcsuite/btcd/btcec/v2"
-	"github.com/btcsuite/btcd/btcec/v2/ecdsa"
 	"github.com/btcsuite/btcd/btcutil"
 	"github.com/btcsuite/btcd/btcutil/hdkeychain"
 	"github.com/btcsuite/btcd/txscript"
 	"github.com/btcsuite/btcd/wire"
+	ec "github.com/ethereum/go-ethereum/common"
 	gc "github.com/ethereum/go-ethereum/crypto"
 	"github.com/fox-one/mixin-sdk-go/v2"
 	"github.com/fox-one/mixin-sdk-go/v2/mixinnet"
@@ -91,6 +94,7 @@ func ObserverFillAccountants(c *cli.Context) error {
 	switch chain {
 	case bitcoin.ChainBitcoin:
 	case bitcoin.ChainLitecoin:
+	case bitcoin.ChainBitcoinCash:
 	default:
 		return fmt.Errorf("invalid chain %d", chain)
 	}
@@ -157,24 +161,24 @@ func ObserverFillAccountants(c *cli.Context) error {
 	msgTx.TxOut = msgTx.TxOut[:count]
 
 	for idx := range msgTx.TxIn {
-		in := inputs[idx]
-		pof := txscript.NewCannedPrevOutputFetcher(in.Script, in.Satoshi)
-		tsh := txscript.NewTxSigHashes(msgTx, pof)
-		hash, err := txscript.CalcWitnessSigHash(in.Script, tsh, txscript.SigHashAll, msgTx, idx, in.Satoshi)
+		err := bitcoin.SignAccountantInput(msgTx, idx, inputs[idx].Satoshi, priv, chain)
 		if err != nil {
 			return err
 		}
-		signature := ecdsa.Sign(priv, hash)
-		sig := append(signature.Serialize(), byte(txscript.SigHashAll))
-		msgTx.TxIn[idx].Witness = append(msgTx.TxIn[idx].Witness, sig)
-		msgTx.TxIn[idx].Witness = append(msgTx.TxIn[idx].Witness, pub.SerializeCompressed())
 	}
 
 	signed, err := bitcoin.MarshalWiredTransaction(msgTx, wire.WitnessEncoding, chain)
 	if err != nil {
 		return err
 	}
-	err = db.WriteAccountantKeys(ctx, common.SafeChainCurve(chain), keys)
+	vault, err := observer.OpenKeyVault(&mc.Observer.KeyVault)
+	if err != nil {
+		return err
+	}
+	if vault != nil {
+		defer vault.Close()
+	}
+	err = db.WriteAccountantKeys(ctx, common.SafeChainCurve(chain), keys, vault)
 	if err != nil {
 		return err
 	}
@@ -182,6 +186,53 @@ func ObserverFillAccountants(c *cli.Context) error {
 	return nil
 }
 
+func ObserverVaultKeys(c *cli.Context) error {
+	ctx := context.Background()
+
+	mc, err := config.ReadConfiguration(c.String("config"), "observer")
+	if err != nil {
+		return err
+	}
+	vault, err := observer.OpenKeyVault(&mc.Observer.KeyVault)
+	if err != nil {
+		return err
+	}
+	if vault == nil {
+		return fmt.Errorf("no key vault configured")
+	}
+	defer vault.Close()
+
+	db, err := observer.OpenSQLite3Store(mc.Observer.StoreDir + "/safe.sqlite3")
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	count, err := db.MoveAccountantKeysToVault(ctx, vault)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("accountants: %d\n", count)
+
+	if mc.Observer.EVMKey == "" {
+		return nil
+	}
+	priv, err := hex.DecodeString(mc.Observer.EVMKey)
+	if err != nil {
+		return err
+	}
+	err = vault.ImportKey(ctx, observer.VaultEVMKeyLabel, priv)
+	if err != nil {
+		return err
+	}
+	addr, err := ethereum.PrivToAddress(mc.Observer.EVMKey)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("evm: %s, remove the evm-key from the configuration now\n", addr.Hex())
+	return nil
+}
+
 func ObserverImportKeys(c *cli.Context) error {
 	ctx := context.Background()
 
@@ -197,11 +248,80 @@ func ObserverImportKeys(c *cli.Context) error {
 	defer db.Close()
 
 	chain := c.Int("chain")
+	if chain == common.SafeChainMixinKernel {
+		keys, err := scanMixinKeyList(c.String("list"))
+		if err != nil {
+			return err
+		}
+		return db.WriteMixinObserverKeys(ctx, keys)
+	}
 	publics, err := scanKeyList(c.String("list"), chain)
 	if err != nil {
 		return err
 	}
-	return db.WriteObserverKeys(ctx, byte(chain), publics)
+	crv := common.NormalizeCurve(common.SafeChainCurve(byte(chain)))
+	return db.WriteObserverKeys(ctx, crv, publics)
+}
+
+func ObserverAllowContractCalls(c *cli.Context) error {
+	ctx := context.Background()
+
+	address, target := c.String("address"), c.String("target")
+	if !ec.IsHexAddress(address) || !ec.IsHexAddress(target) {
+		return fmt.Errorf("invalid address %s or target %s", address, target)
+	}
+	address, target = ec.HexToAddress(address).Hex(), ec.HexToAddress(target).Hex()
+	selector := strings.ToLower(strings.TrimPrefix(c.String("selector"), "0x"))
+	sb, err := hex.DecodeString(selector)
+	if err != nil || len(sb) != 4 {
+		return fmt.Errorf("invalid selector %s", c.String("selector"))
+	}
+
+	mc, err := config.ReadConfiguration(c.String("config"), "observer")
+	if err != nil {
+		return err
+	}
+	db, err := observer.OpenSQLite3Store(mc.Observer.StoreDir + "/safe.sqlite3")
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if c.Bool("deny") {
+		return db.DeleteCallAllowlist(ctx, address, target, selector)
+	}
+	return db.WriteCallAllowlist(ctx, address, target, selector)
+}
+
+// the observer key of a mixin kernel safe is the public view key, and the
+// private view key is required to scan the deposits of the safe
+func scanMixinKeyList(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Split(bufio.ScanLines)
+
+	keys := make(map[string]string)
+	for scanner.Scan() {
+		hd := scanner.Text()
+		hdp := strings.Split(hd, ":")
+		if len(hdp) != 4 {
+			return nil, fmt.Errorf("invalid pair %s", hd)
+		}
+		pub, err := crypto.KeyFromString(hdp[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid pub %s", hd)
+		}
+		priv, err := crypto.KeyFromString(hdp[3])
+		if err != nil || priv.Public() != pub {
+			return nil, fmt.Errorf("invalid priv %s", hd)
+		}
+		keys[pub.String()] = priv.String()
+	}
+	return keys, nil
 }
 
 func scanKeyList(path string, chain int) (map[string]string, error) {
@@ -213,9 +333,12 @@ func scanKeyList(path string, chain int) (map[string]string, error) {
 	scanner := bufio.NewScanner(f)
 	scanner.Split(bufio.ScanLines)
 
+	verify := bitcoin.VerifyHolderKey
 	switch chain {
 	case common.SafeChainBitcoin:
 	case common.SafeChainEthereum:
+	case common.SafeChainSolana:
+		verify = solana.VerifyHolderKey
 	default:
 		return nil, fmt.Errorf("invalid chain %d", chain)
 	}
@@ -228,7 +351,7 @@ func scanKeyList(path string, chain int) (map[string]string, error) {
 			return nil, fmt.Errorf("invalid pair %s", hd)
 		}
 		pub, code := hdp[0], hdp[1]
-		err := bitcoin.VerifyHolderKey(pub)
+		err := verify(pub)
 		if err != nil {
 			return nil, fmt.Errorf("invalid pub %s", hd)
 		}
@@ -248,6 +371,18 @@ func generateAccountantKey(chain byte) (*btcec.PrivateKey, string, error) {
 		return nil, "", err
 	}
 	pub := priv.PubKey().SerializeCompressed()
+	if !bitcoin.IsSegwitChain(chain) {
+		apkh, err := btcutil.NewAddressPubKeyHash(btcutil.Hash160(pub), bitcoin.NetConfig(chain))
+		if err != nil {
+			return nil, "", err
+		}
+		script, err := txscript.PayToAddrScript(apkh)
+		if err != nil {
+			return nil, "", err
+		}
+		addr, err := bitcoin.ExtractPkScriptAddr(script, chain)
+		return priv, addr, err
+	}
 	awpkh, err := btcutil.NewAddressWitnessPubKeyHash(btcutil.Hash160(pub), bitcoin.NetConfig(chain))
 	if err != nil {
 		return nil, "", err
@@ -260,6 +395,8 @@ func GenerateObserverKeys(c *cli.Context) error {
 	switch chain {
 	case common.SafeChainBitcoin:
 	case common.SafeChainEthereum:
+	case common.SafeChainMixinKernel:
+	case common.SafeChainSolana:
 	default:
 		return fmt.Errorf("invalid chain %d", chain)
 	}
@@ -290,7 +427,7 @@ func GenerateObserverKeys(c *cli.Context) error {
 			panic(err)
 		}
 		line := fmt.Sprintf("%x:%x:%s", account.Public, account.ChainCode, account.Fingerprint)
-		if c.Bool("private") {
+		if c.Bool("private") || chain == common.SafeChainMixinKernel {
 			line = fmt.Sprintf("%s:%x", line, account.Private)
 		}
 		_, err = pubF.WriteString(line + "\n")
@@ -380,6 +517,14 @@ func generateObserverAccount(chain byte, account uint32, masterSeed string) (*Ac
 			panic("cannot assert type: publicKey is not of type *ecdsa.PublicKey")
 		}
 		res.Public = gc.CompressPubkey(publicKeyECDSA)
+	case m.ChainMixinKernel:
+		key := crypto.NewKeyFromSeed(append(res.Private, res.ChainCode...))
+		res.Private = key[:]
+		pub := key.Public()
+		res.Public = pub[:]
+	case solana.ChainSolana:
+		key := ed25519.NewKeyFromSeed(res.Private)
+		res.Public = key.Public().(ed25519.PublicKey)
 	default:
 		panic(chain)
 	}
@@ -1,8 +1,16 @@
 package cmd
 
 import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/MixinNetwork/mixin/crypto"
 	"github.com/MixinNetwork/mixin/logger"
+	"github.com/MixinNetwork/safe/config"
 	"github.com/MixinNetwork/safe/saver"
+	"github.com/MixinNetwork/safe/signer"
 	"github.com/urfave/cli/v2"
 )
 
@@ -14,3 +22,49 @@ func SaverBootCmd(c *cli.Context) error {
 	}
 	return saver.StartHTTP(store, c.Int("port"))
 }
+
+func SaverRestoreCmd(c *cli.Context) error {
+	ctx := context.Background()
+
+	mc, err := config.ReadConfiguration(c.String("config"), "signer")
+	if err != nil {
+		return err
+	}
+	apis, _ := mc.Signer.Savers()
+	if len(apis) == 0 {
+		return fmt.Errorf("saver-apis")
+	}
+	key, err := crypto.KeyFromString(mc.Signer.SaverKey)
+	if err != nil {
+		return err
+	}
+
+	kd, err := signer.OpenSQLite3Store(mc.Signer.StoreDir + "/mpc.sqlite3")
+	if err != nil {
+		return err
+	}
+	defer kd.Close()
+
+	nodeId := mc.Signer.MTG.App.AppId
+	client := &http.Client{Timeout: 30 * time.Second}
+	var backups []*signer.KeygenBackup
+	if id := c.String("item"); id != "" {
+		b, err := signer.ReadKeygenBackup(ctx, client, apis, nodeId, id, &key)
+		if err != nil {
+			return err
+		}
+		backups = append(backups, b)
+	} else {
+		backups, err = signer.FetchKeygenBackups(ctx, client, apis, nodeId, &key)
+		if err != nil {
+			return err
+		}
+	}
+
+	count, err := signer.RestoreKeygenBackups(ctx, kd, backups, c.Bool("force"))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("restored %d keys from %d backups\n", count, len(backups))
+	return nil
+}
@@ -60,6 +60,13 @@ func SignerBootCmd(c *cli.Context) error {
 		return err
 	}
 	defer kd.Close()
+	kms, err := mc.Signer.ShareKMS()
+	if err != nil {
+		return err
+	}
+	if kms != nil {
+		kd.EnableShareEncryption(kms)
+	}
 
 	s := &mixin.Keystore{
 		ClientID:          mc.Signer.MTG.App.AppId,
@@ -81,7 +88,11 @@ func SignerBootCmd(c *cli.Context) error {
 	}
 	mc.Signer.MTG.App.SpendPrivateKey = key.String()
 
-	node := signer.NewNode(kd, group, messenger, mc.Signer, mc.Keeper.MTG, client)
+	var custodian *mtg.Configuration
+	if mc.Custodian != nil {
+		custodian = mc.Custodian.MTG
+	}
+	node := signer.NewNode(kd, group, messenger, mc.Signer, mc.Keeper.MTG, custodian, client)
 	node.Boot(ctx)
 
 	if mmc := mc.Signer.MonitorConversaionId; mmc != "" {
@@ -93,6 +104,36 @@ func SignerBootCmd(c *cli.Context) error {
 	return nil
 }
 
+func SignerEncryptShares(c *cli.Context) error {
+	ctx := context.Background()
+
+	mc, err := config.ReadConfiguration(c.String("config"), "signer")
+	if err != nil {
+		return err
+	}
+	kms, err := mc.Signer.ShareKMS()
+	if err != nil {
+		return err
+	}
+	if kms == nil {
+		return fmt.Errorf("no share passphrase or key file configured")
+	}
+
+	kd, err := signer.OpenSQLite3Store(mc.Signer.StoreDir + "/mpc.sqlite3")
+	if err != nil {
+		return err
+	}
+	defer kd.Close()
+	kd.EnableShareEncryption(kms)
+
+	count, err := kd.EncryptKeyShares(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("encrypted %d key shares\n", count)
+	return nil
+}
+
 func SignerFundRequest(c *cli.Context) error {
 	mc, err := config.ReadConfiguration(c.String("config"), "signer")
 	if err != nil {
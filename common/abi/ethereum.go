@@ -3,6 +3,7 @@ package abi
 import (
 	"context"
 	"encoding/hex"
+	"fmt"
 	"math/big"
 	"strings"
 
@@ -17,7 +18,10 @@ import (
 	"github.com/gofrs/uuid/v5"
 )
 
-var factoryContractAddress string
+var (
+	factoryContractAddress    string
+	mvmFactoryContractAddress string
+)
 
 func InitFactoryContractAddress(addr string) {
 	addr = strings.ToLower(addr)
@@ -32,8 +36,30 @@ func InitFactoryContractAddress(addr string) {
 	}
 }
 
+// the legacy bond assets were deployed by the factory on mvm
+func InitMVMFactoryContractAddress(addr string) {
+	addr = strings.ToLower(addr)
+	if ethereum.VerifyAssetKey(addr) != nil {
+		panic(addr)
+	}
+	if mvmFactoryContractAddress == "" {
+		mvmFactoryContractAddress = addr
+	}
+	if mvmFactoryContractAddress != addr {
+		panic(mvmFactoryContractAddress)
+	}
+}
+
 func GetOrDeployFactoryAsset(ctx context.Context, rpc, key string, assetId, symbol, name, receiver, holder string) error {
-	conn, abi, err := factoryInit(rpc)
+	signer, err := ethereum.NewPrivateKeySigner(key)
+	if err != nil {
+		return err
+	}
+	return GetOrDeployFactoryAssetWithSigner(ctx, rpc, signer, assetId, symbol, name, receiver, holder)
+}
+
+func GetOrDeployFactoryAssetWithSigner(ctx context.Context, rpc string, evm ethereum.EVMSigner, assetId, symbol, name, receiver, holder string) error {
+	conn, abi, err := factoryInit(rpc, factoryContractAddress)
 	if err != nil {
 		return err
 	}
@@ -46,7 +72,7 @@ func GetOrDeployFactoryAsset(ctx context.Context, rpc, key string, assetId, symb
 		return err
 	}
 
-	signer := ethereum.SignerInit(ctx, conn, key, ethereumChainId)
+	signer := ethereum.TransactorInit(ctx, conn, evm, ethereumChainId)
 	id := new(big.Int).SetBytes(uuid.Must(uuid.FromString(assetId)).Bytes())
 	symbol, name = "safe"+symbol, name+" @ Mixin Safe"
 	t, err := abi.Deploy(signer, common.HexToAddress(receiver), id, holder, symbol, name)
@@ -65,7 +91,18 @@ func GetOrDeployFactoryAsset(ctx context.Context, rpc, key string, assetId, symb
 }
 
 func CheckFactoryAssetDeployed(rpc, assetKey string) (*big.Int, error) {
-	conn, abi, err := factoryInit(rpc)
+	return checkFactoryAssetDeployed(rpc, factoryContractAddress, assetKey)
+}
+
+func CheckMVMFactoryAssetDeployed(rpc, assetKey string) (*big.Int, error) {
+	if mvmFactoryContractAddress == "" {
+		return nil, fmt.Errorf("mvm factory contract address not initialized")
+	}
+	return checkFactoryAssetDeployed(rpc, mvmFactoryContractAddress, assetKey)
+}
+
+func checkFactoryAssetDeployed(rpc, factory, assetKey string) (*big.Int, error) {
+	conn, abi, err := factoryInit(rpc, factory)
 	if err != nil {
 		return nil, err
 	}
@@ -102,13 +139,13 @@ func GetFactoryAssetAddress(receiver, assetId, symbol, name string, holder strin
 	return common.BytesToAddress(crypto.Keccak256(input))
 }
 
-func factoryInit(rpc string) (*ethclient.Client, *FactoryContract, error) {
+func factoryInit(rpc, factory string) (*ethclient.Client, *FactoryContract, error) {
 	conn, err := ethclient.Dial(rpc)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	abi, err := NewFactoryContract(common.HexToAddress(factoryContractAddress), conn)
+	abi, err := NewFactoryContract(common.HexToAddress(factory), conn)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -3,6 +3,7 @@ package common
 import (
 	"crypto/aes"
 	"crypto/cipher"
+	"fmt"
 
 	"github.com/MixinNetwork/mixin/crypto"
 	"github.com/gofrs/uuid/v5"
@@ -22,21 +23,32 @@ func ECDHEd25519(priv, pub string) [32]byte {
 }
 
 func AESDecrypt(secret, b []byte) []byte {
-	aes, err := aes.NewCipher(secret)
+	d, err := AESDecryptWithError(secret, b)
 	if err != nil {
 		panic(err)
 	}
+	return d
+}
+
+func AESDecryptWithError(secret, b []byte) ([]byte, error) {
+	aes, err := aes.NewCipher(secret)
+	if err != nil {
+		return nil, err
+	}
 	aead, err := cipher.NewGCM(aes)
 	if err != nil {
-		panic(err)
+		return nil, err
+	}
+	if len(b) < aead.NonceSize() {
+		return nil, fmt.Errorf("invalid cipher size %d", len(b))
 	}
 	nonce := b[:aead.NonceSize()]
 	cipher := b[aead.NonceSize():]
 	d, err := aead.Open(nil, nonce, cipher, nil)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
-	return append(nonce, d...)
+	return append(nonce, d...), nil
 }
 
 func AESEncrypt(secret, b []byte, sid string) []byte {
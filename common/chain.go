@@ -1,35 +1,56 @@
 package common
 
 import (
+	"fmt"
+
 	"github.com/MixinNetwork/safe/apps/bitcoin"
 	"github.com/MixinNetwork/safe/apps/ethereum"
+	m "github.com/MixinNetwork/safe/apps/mixin"
+	"github.com/MixinNetwork/safe/apps/solana"
+	"github.com/MixinNetwork/safe/apps/tron"
 )
 
 const (
-	SafeChainBitcoin  = bitcoin.ChainBitcoin
-	SafeChainLitecoin = bitcoin.ChainLitecoin
-	SafeChainEthereum = ethereum.ChainEthereum
-	SafeChainPolygon  = ethereum.ChainPolygon
+	SafeChainBitcoin     = bitcoin.ChainBitcoin
+	SafeChainLitecoin    = bitcoin.ChainLitecoin
+	SafeChainEthereum    = ethereum.ChainEthereum
+	SafeChainPolygon     = ethereum.ChainPolygon
+	SafeChainMixinKernel = m.ChainMixinKernel
+	SafeChainBitcoinCash = bitcoin.ChainBitcoinCash
+	SafeChainMVM         = ethereum.ChainMVM
+	SafeChainSolana      = solana.ChainSolana
+	SafeChainTron        = tron.ChainTron
 
-	SafeBitcoinChainId  = "c6d0c728-2624-429b-8e0d-d9d19b6592fa"
-	SafeEthereumChainId = "43d61dcd-e413-450d-80b8-101d5e903357"
-	SafeLitecoinChainId = "76c802a2-7c88-447f-a93e-c29c9e5dd9c8"
-	SafePolygonChainId  = "b7938396-3f94-4e0a-9179-d3440718156f"
+	SafeBitcoinChainId     = "c6d0c728-2624-429b-8e0d-d9d19b6592fa"
+	SafeEthereumChainId    = "43d61dcd-e413-450d-80b8-101d5e903357"
+	SafeLitecoinChainId    = "76c802a2-7c88-447f-a93e-c29c9e5dd9c8"
+	SafePolygonChainId     = "b7938396-3f94-4e0a-9179-d3440718156f"
+	SafeMixinKernelAssetId = "c94ac88f-4671-3976-b60a-09064f1811e8"
+	SafeBitcoinCashChainId = "fd11b6e3-0b87-41f1-a41f-f0e9b49e5bf0"
+	SafeMVMChainId         = "a0ffd769-5850-4b48-9651-d2ae44a3e64d"
+	SafeSolanaChainId      = "64692c23-8971-4cf4-84a7-4dd1271dd887"
+	SafeTronChainId        = tron.TronChainId
 )
 
 func SafeCurveChain(crv byte) byte {
 	switch crv {
-	case CurveSecp256k1ECDSABitcoin:
+	case CurveSecp256k1ECDSABitcoin, CurveSecp256k1SchnorrBitcoin:
 		return SafeChainBitcoin
 	case CurveSecp256k1ECDSALitecoin:
 		return SafeChainLitecoin
-	case CurveSecp256k1ECDSAEthereum:
-		return SafeChainEthereum
-	case CurveSecp256k1ECDSAPolygon:
-		return SafeChainPolygon
-	default:
-		panic(crv)
+	case CurveEdwards25519Mixin:
+		return SafeChainMixinKernel
+	case CurveSecp256k1ECDSABitcoinCash:
+		return SafeChainBitcoinCash
+	case CurveEdwards25519Solana:
+		return SafeChainSolana
+	case CurveSecp256k1ECDSATron:
+		return SafeChainTron
 	}
+	if c := ethereum.GetEvmChainByCurve(crv); c != nil {
+		return c.Chain
+	}
+	panic(crv)
 }
 
 func SafeChainCurve(chain byte) byte {
@@ -38,13 +59,19 @@ func SafeChainCurve(chain byte) byte {
 		return CurveSecp256k1ECDSABitcoin
 	case SafeChainLitecoin:
 		return CurveSecp256k1ECDSALitecoin
-	case SafeChainEthereum:
-		return CurveSecp256k1ECDSAEthereum
-	case SafeChainPolygon:
-		return CurveSecp256k1ECDSAPolygon
-	default:
-		panic(chain)
+	case SafeChainMixinKernel:
+		return CurveEdwards25519Mixin
+	case SafeChainBitcoinCash:
+		return CurveSecp256k1ECDSABitcoinCash
+	case SafeChainSolana:
+		return CurveEdwards25519Solana
+	case SafeChainTron:
+		return CurveSecp256k1ECDSATron
+	}
+	if c := ethereum.GetEvmChain(chain); c != nil {
+		return c.Curve
 	}
+	panic(chain)
 }
 
 func SafeChainAssetId(chain byte) string {
@@ -53,13 +80,19 @@ func SafeChainAssetId(chain byte) string {
 		return SafeBitcoinChainId
 	case SafeChainLitecoin:
 		return SafeLitecoinChainId
-	case SafeChainEthereum:
-		return SafeEthereumChainId
-	case SafeChainPolygon:
-		return SafePolygonChainId
-	default:
-		panic(chain)
+	case SafeChainMixinKernel:
+		return SafeMixinKernelAssetId
+	case SafeChainBitcoinCash:
+		return SafeBitcoinCashChainId
+	case SafeChainSolana:
+		return SafeSolanaChainId
+	case SafeChainTron:
+		return SafeTronChainId
 	}
+	if c := ethereum.GetEvmChain(chain); c != nil {
+		return c.MixinChainId
+	}
+	panic(chain)
 }
 
 func SafeAssetIdChain(chainId string) byte {
@@ -76,10 +109,43 @@ func SafeAssetIdChainNoPanic(chainId string) byte {
 		return SafeChainBitcoin
 	case SafeLitecoinChainId:
 		return SafeChainLitecoin
-	case SafeEthereumChainId:
-		return SafeChainEthereum
-	case SafePolygonChainId:
-		return SafeChainPolygon
+	case SafeMixinKernelAssetId:
+		return SafeChainMixinKernel
+	case SafeBitcoinCashChainId:
+		return SafeChainBitcoinCash
+	case SafeSolanaChainId:
+		return SafeChainSolana
+	case SafeTronChainId:
+		return SafeChainTron
+	}
+	if c := ethereum.GetEvmChainByMixinChainId(chainId); c != nil {
+		return c.Chain
 	}
 	return 0
 }
+
+// all the evm chains share the ethereum safe implementation, so they are
+// normalized to ethereum in the chain switches, the same as the curves
+func NormalizeSafeChain(chain byte) byte {
+	if ethereum.IsEvmChain(chain) {
+		return SafeChainEthereum
+	}
+	return chain
+}
+
+func RegisterEvmChains(chains []*ethereum.EvmChain) error {
+	for _, c := range chains {
+		switch c.Chain {
+		case SafeChainBitcoin, SafeChainLitecoin, SafeChainMixinKernel, SafeChainBitcoinCash, SafeChainSolana, SafeChainTron:
+			return fmt.Errorf("invalid evm chain %d", c.Chain)
+		}
+		if c.Curve == CurveSecp256k1ECDSATron || c.MixinChainId == SafeTronChainId {
+			return fmt.Errorf("invalid evm chain %d curve %d", c.Chain, c.Curve)
+		}
+		err := ethereum.RegisterEvmChain(c)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/MixinNetwork/safe/apps/ethereum"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvmChains(t *testing.T) {
+	require := require.New(t)
+
+	require.Equal(byte(CurveSecp256k1ECDSAEthereum), SafeChainCurve(SafeChainEthereum))
+	require.Equal(byte(CurveSecp256k1ECDSAPolygon), SafeChainCurve(SafeChainPolygon))
+	require.Equal(byte(SafeChainPolygon), SafeCurveChain(CurveSecp256k1ECDSAPolygon))
+	require.Equal(byte(SafeChainPolygon), SafeAssetIdChain(SafePolygonChainId))
+	require.Equal(SafeEthereumChainId, SafeChainAssetId(SafeChainEthereum))
+	require.Equal(byte(SafeChainEthereum), NormalizeSafeChain(SafeChainPolygon))
+	require.Equal(byte(SafeChainBitcoin), NormalizeSafeChain(SafeChainBitcoin))
+	require.Equal(byte(CurveSecp256k1ECDSAMVM), SafeChainCurve(SafeChainMVM))
+	require.Equal(byte(SafeChainMVM), SafeCurveChain(CurveSecp256k1ECDSAMVM))
+	require.Equal(byte(SafeChainMVM), SafeAssetIdChain(SafeMVMChainId))
+	require.Equal(byte(SafeChainEthereum), NormalizeSafeChain(SafeChainMVM))
+	require.Equal(int64(73927), ethereum.GetEvmChainID(SafeChainMVM))
+	require.Equal(SafeMVMChainId, ethereum.GetMixinChainID(SafeChainMVM))
+	require.True(ethereum.GetEvmChain(SafeChainMVM).LegacyTransaction)
+	require.True(ethereum.CheckFinalization(1, SafeChainMVM))
+	require.False(ethereum.CheckFinalization(0, SafeChainMVM))
+
+	arb := &ethereum.EvmChain{
+		Chain:         11,
+		Curve:         122,
+		Name:          "Arbitrum",
+		ChainId:       42161,
+		MixinChainId:  "8c590110-1abc-3697-84f2-05214e6516aa",
+		RPC:           "https://arb1.arbitrum.io/rpc",
+		Confirmations: 20,
+		Finalization:  1200,
+		Checkpoint:    264000000,
+	}
+	require.Nil(RegisterEvmChains([]*ethereum.EvmChain{arb}))
+	require.Nil(RegisterEvmChains([]*ethereum.EvmChain{arb}))
+	require.Equal(byte(122), SafeChainCurve(11))
+	require.Equal(byte(11), SafeCurveChain(122))
+	require.Equal(byte(11), SafeAssetIdChain(arb.MixinChainId))
+	require.Equal(byte(SafeChainEthereum), NormalizeSafeChain(11))
+	require.Equal(int64(42161), ethereum.GetEvmChainID(11))
+	registered := ethereum.GetEvmChain(11)
+	require.Equal("", registered.RPC)
+	require.Equal(ethereum.EthereumSafeGuardAddress, registered.GuardAddress)
+
+	invalid := *arb
+	invalid.Finalization = 1
+	require.NotNil(RegisterEvmChains([]*ethereum.EvmChain{&invalid}))
+	invalid = *arb
+	invalid.Chain = SafeChainSolana
+	require.NotNil(RegisterEvmChains([]*ethereum.EvmChain{&invalid}))
+	invalid = *arb
+	invalid.Chain = SafeChainTron
+	require.NotNil(RegisterEvmChains([]*ethereum.EvmChain{&invalid}))
+	invalid = *arb
+	invalid.Chain, invalid.Curve = 12, CurveSecp256k1ECDSATron
+	require.NotNil(RegisterEvmChains([]*ethereum.EvmChain{&invalid}))
+	invalid = *arb
+	invalid.Chain, invalid.Curve = 12, CurveSecp256k1ECDSAMVM
+	require.NotNil(RegisterEvmChains([]*ethereum.EvmChain{&invalid}))
+	invalid = *arb
+	invalid.Chain, invalid.Curve = 12, 132
+	require.NotNil(RegisterEvmChains([]*ethereum.EvmChain{&invalid}))
+	invalid = *arb
+	invalid.Chain, invalid.Curve, invalid.ChainId = 12, 132, 10
+	invalid.GuardAddress = "0x5a3a6e35038f33458c13f3b5349ee5ae1e94a8d9"
+	require.NotNil(RegisterEvmChains([]*ethereum.EvmChain{&invalid}))
+	require.False(ethereum.IsEvmChain(12))
+}
+
+func TestSolanaChain(t *testing.T) {
+	require := require.New(t)
+
+	require.Equal(byte(CurveEdwards25519Solana), SafeChainCurve(SafeChainSolana))
+	require.Equal(byte(SafeChainSolana), SafeCurveChain(CurveEdwards25519Solana))
+	require.Equal(byte(SafeChainSolana), SafeAssetIdChain(SafeSolanaChainId))
+	require.Equal(SafeSolanaChainId, SafeChainAssetId(SafeChainSolana))
+	require.Equal(byte(SafeChainSolana), NormalizeSafeChain(SafeChainSolana))
+	require.Equal(byte(CurveEdwards25519Default), NormalizeCurve(CurveEdwards25519Solana))
+}
+
+func TestTronChain(t *testing.T) {
+	require := require.New(t)
+
+	require.Equal(byte(CurveSecp256k1ECDSATron), SafeChainCurve(SafeChainTron))
+	require.Equal(byte(SafeChainTron), SafeCurveChain(CurveSecp256k1ECDSATron))
+	require.Equal(byte(SafeChainTron), SafeAssetIdChain(SafeTronChainId))
+	require.Equal(SafeTronChainId, SafeChainAssetId(SafeChainTron))
+	require.Equal(byte(SafeChainTron), NormalizeSafeChain(SafeChainTron))
+	require.Equal(byte(CurveSecp256k1ECDSAEthereum), NormalizeCurve(CurveSecp256k1ECDSATron))
+	require.False(ethereum.IsEvmChain(SafeChainTron))
+}
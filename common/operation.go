@@ -11,9 +11,14 @@ const (
 	OperationTypeWrapper     = 0
 	OperationTypeKeygenInput = 1
 	OperationTypeSignInput   = 2
+	// 3 and 4 are used by the custodian works actions in the signer mtg
+	OperationTypeRefreshInput = 5
+	OperationTypeReshareInput = 6
 
-	OperationTypeKeygenOutput = 11
-	OperationTypeSignOutput   = 12
+	OperationTypeKeygenOutput  = 11
+	OperationTypeSignOutput    = 12
+	OperationTypeRefreshOutput = 15
+	OperationTypeReshareOutput = 16
 
 	CurveSecp256k1ECDSABitcoin   = 1
 	CurveSecp256k1ECDSAEthereum  = 2
@@ -25,6 +30,8 @@ const (
 	CurveSecp256k1ECDSABitcoinCash = 110 + CurveSecp256k1ECDSABitcoin
 	CurveSecp256k1ECDSAMVM         = 100 + CurveSecp256k1ECDSAEthereum
 	CurveSecp256k1ECDSAPolygon     = 110 + CurveSecp256k1ECDSAEthereum
+	CurveEdwards25519Solana        = 100 + CurveEdwards25519Default
+	CurveSecp256k1ECDSATron        = 140 + CurveSecp256k1ECDSAEthereum
 )
 
 type Operation struct {
@@ -11,6 +11,9 @@ import (
 	"github.com/MixinNetwork/mixin/crypto"
 	"github.com/MixinNetwork/safe/apps/bitcoin"
 	"github.com/MixinNetwork/safe/apps/ethereum"
+	m "github.com/MixinNetwork/safe/apps/mixin"
+	"github.com/MixinNetwork/safe/apps/solana"
+	"github.com/MixinNetwork/safe/apps/tron"
 	"github.com/MixinNetwork/trusted-group/mtg"
 	"github.com/fox-one/mixin-sdk-go/v2"
 	"github.com/gofrs/uuid/v5"
@@ -18,9 +21,10 @@ import (
 )
 
 const (
-	RequestRoleHolder   = 1
-	RequestRoleSigner   = 2
-	RequestRoleObserver = 3
+	RequestRoleHolder    = 1
+	RequestRoleSigner    = 2
+	RequestRoleObserver  = 3
+	RequestRoleCustodian = 4
 
 	RequestFlagNone              = 0
 	RequestFlagCustomObserverKey = 1
@@ -40,6 +44,7 @@ const (
 	ActionObserverUpdateNetworkStatus = 103
 	ActionObserverHolderDeposit       = 104
 	ActionObserverSetOperationParams  = 106
+	ActionObserverSetRelayGasPrice    = 107
 
 	// For all Bitcoin like chains
 	ActionBitcoinSafeProposeAccount     = 110
@@ -65,8 +70,37 @@ const (
 	ActionEthereumSafeCloseAccount       = 135
 	ActionEthereumSafeRefundTransaction  = 136
 
+	// For Solana mainnet
+	ActionSolanaSafeProposeAccount     = 140
+	ActionSolanaSafeApproveAccount     = 141
+	ActionSolanaSafeProposeTransaction = 142
+	ActionSolanaSafeApproveTransaction = 143
+	ActionSolanaSafeRevokeTransaction  = 144
+
+	// For TRON mainnet
+	ActionTronSafeProposeAccount     = 150
+	ActionTronSafeApproveAccount     = 151
+	ActionTronSafeProposeTransaction = 152
+	ActionTronSafeApproveTransaction = 153
+	ActionTronSafeRevokeTransaction  = 154
+
 	FlagProposeNormalTransaction   = 0
 	FlagProposeRecoveryTransaction = 1
+	FlagProposeNFTTransaction      = 2
+	FlagProposeContractCall        = 3
+
+	// domain do the first key, next should be from the first key
+	CustodianActionRefreshKey = 1
+	CustodianActionDistribute = 2
+
+	// every signer node will send this to the mtg
+	// everyday at a specific time
+	// then at some point, a random output will cause
+	// all signer nodes to finalize the works
+	CustodianActionVoteWorks = 3
+	// then the signer mtg send this action to keeper mtg
+	// the custodian will process this then
+	CustodianActionFinalizeWorks = 4
 )
 
 type Request struct {
@@ -92,6 +126,7 @@ type AccountProposal struct {
 	Threshold byte
 	Timelock  time.Duration
 	Observer  string
+	Policy    *bitcoin.HolderPolicy
 }
 
 func (req *Request) Operation() *Operation {
@@ -141,6 +176,9 @@ func (req *Request) ParseMixinRecipient(ctx context.Context, client *mixin.Clien
 	switch req.Action {
 	case ActionBitcoinSafeProposeAccount:
 	case ActionEthereumSafeProposeAccount:
+	case ActionMixinSafeProposeAccount:
+	case ActionSolanaSafeProposeAccount:
+	case ActionTronSafeProposeAccount:
 	default:
 		panic(req.Action)
 	}
@@ -184,15 +222,43 @@ func (req *Request) ParseMixinRecipient(ctx context.Context, client *mixin.Clien
 		return arp, nil
 	}
 
-	if len(extra) != offset+33 {
-		return nil, fmt.Errorf("extra size %x %v", extra, arp)
+	// the optional observer key is followed by the optional holder policy,
+	// and the policy flag is never a valid public key prefix
+	// the mixin kernel and solana keys are 32 bytes without any prefix
+	rest, size := extra[offset:], 33
+	if req.Action == ActionMixinSafeProposeAccount || req.Action == ActionSolanaSafeProposeAccount {
+		size = 32
 	}
-	arp.Observer = hex.EncodeToString(extra[offset:])
+	if rest[0] != bitcoin.HolderPolicyExtraFlag || size == 32 {
+		if len(rest) < size {
+			return nil, fmt.Errorf("extra size %x %v", extra, arp)
+		}
+		arp.Observer, rest = hex.EncodeToString(rest[:size]), rest[size:]
+	}
+	if len(rest) > 0 {
+		if req.Action != ActionBitcoinSafeProposeAccount {
+			return nil, fmt.Errorf("extra size %x %v", extra, arp)
+		}
+		arp.Policy, err = bitcoin.UnmarshalHolderPolicy(rest)
+		if err != nil {
+			return nil, fmt.Errorf("request policy %x %v", rest, err)
+		}
+	}
+	if arp.Observer == "" {
+		return arp, nil
+	}
+
 	switch req.Action {
 	case ActionBitcoinSafeProposeAccount:
 		err = bitcoin.VerifyHolderKey(arp.Observer)
 	case ActionEthereumSafeProposeAccount:
 		err = ethereum.VerifyHolderKey(arp.Observer)
+	case ActionMixinSafeProposeAccount:
+		err = m.VerifyHolderKey(arp.Observer)
+	case ActionSolanaSafeProposeAccount:
+		err = solana.VerifyHolderKey(arp.Observer)
+	case ActionTronSafeProposeAccount:
+		err = tron.VerifyHolderKey(arp.Observer)
 	}
 	if err != nil {
 		return nil, fmt.Errorf("request observer %s %v", arp.Observer, err)
@@ -231,10 +297,21 @@ func (r *Request) VerifyFormat() error {
 		return fmt.Errorf("invalid request mixin %v", r)
 	}
 	switch r.Curve {
-	case CurveSecp256k1ECDSABitcoin, CurveSecp256k1ECDSALitecoin:
+	case CurveSecp256k1ECDSABitcoin, CurveSecp256k1ECDSALitecoin, CurveSecp256k1ECDSABitcoinCash:
+		return bitcoin.VerifyHolderKey(r.Holder)
+	case CurveSecp256k1SchnorrBitcoin:
+		if r.Role == RequestRoleSigner {
+			return bitcoin.VerifyTaprootKey(r.Holder)
+		}
 		return bitcoin.VerifyHolderKey(r.Holder)
 	case CurveSecp256k1ECDSAEthereum, CurveSecp256k1ECDSAMVM, CurveSecp256k1ECDSAPolygon:
 		return ethereum.VerifyHolderKey(r.Holder)
+	case CurveEdwards25519Mixin:
+		return m.VerifyHolderKey(r.Holder)
+	case CurveEdwards25519Solana:
+		return solana.VerifyHolderKey(r.Holder)
+	case CurveSecp256k1ECDSATron:
+		return tron.VerifyHolderKey(r.Holder)
 	default:
 		return fmt.Errorf("invalid request curve %v", r)
 	}
@@ -33,4 +33,21 @@ func TestRequest(t *testing.T) {
 	require.Equal(byte(1), arp.Threshold)
 	require.Equal(time.Hour, arp.Timelock)
 	require.Equal("039c2f5ebdd4eae6d69e7a98b737beeb78e0a8d42c7b957a0fbe0c41658d16ab40", arp.Observer)
+
+	extra = "00010101e459de8b4edd44ffa119b1d707f8521a000202" +
+		"03d277643292197684bde44376b94a9c91783ce3e424bf390ac99bc90f3df9be60" +
+		"02bd2f73ffb81fda1a7b774be4e8ddcca9f776f7e5777f9c7fd246725ba034aa24"
+	arp, err = req.ParseMixinRecipient(ctx, client, DecodeHexOrPanic(extra))
+	require.Nil(err)
+	require.NotNil(arp)
+	require.Equal("", arp.Observer)
+	require.Equal(byte(2), arp.Policy.Threshold)
+	require.Len(arp.Policy.Holders, 2)
+	require.True(arp.Policy.Contains("02bd2f73ffb81fda1a7b774be4e8ddcca9f776f7e5777f9c7fd246725ba034aa24"))
+
+	_, err = req.ParseMixinRecipient(ctx, client, DecodeHexOrPanic(extra[:len(extra)-2]))
+	require.NotNil(err)
+	req.Action = ActionEthereumSafeProposeAccount
+	_, err = req.ParseMixinRecipient(ctx, client, DecodeHexOrPanic(extra))
+	require.NotNil(err)
 }
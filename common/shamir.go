@@ -0,0 +1,121 @@
+package common
+
+import (
+	"crypto/rand"
+	"fmt"
+)
+
+// byte wise shamir secret sharing over GF(2^8) with the AES polynomial,
+// every part is the x coordinate byte followed by the y bytes
+
+var gfExp, gfLog [256]byte
+
+func init() {
+	var x byte = 1
+	for i := 0; i < 255; i++ {
+		gfExp[i] = x
+		gfLog[x] = byte(i)
+		x = x ^ gfMulSlow(x, 2)
+	}
+	gfExp[255] = gfExp[0]
+}
+
+func gfMulSlow(a, b byte) byte {
+	var p byte
+	for b > 0 {
+		if b&1 == 1 {
+			p ^= a
+		}
+		carry := a & 0x80
+		a <<= 1
+		if carry != 0 {
+			a ^= 0x1b
+		}
+		b >>= 1
+	}
+	return p
+}
+
+func gfMul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return gfExp[(int(gfLog[a])+int(gfLog[b]))%255]
+}
+
+func gfDiv(a, b byte) byte {
+	if b == 0 {
+		panic("gf division by zero")
+	}
+	if a == 0 {
+		return 0
+	}
+	return gfExp[(int(gfLog[a])-int(gfLog[b])+255)%255]
+}
+
+func ShamirSplit(secret []byte, parts, threshold int) ([][]byte, error) {
+	if len(secret) == 0 {
+		return nil, fmt.Errorf("empty secret")
+	}
+	if threshold < 1 || parts < threshold || parts > 255 {
+		return nil, fmt.Errorf("invalid shamir %d/%d", threshold, parts)
+	}
+
+	coefficients := make([]byte, threshold-1)
+	shares := make([][]byte, parts)
+	for i := range shares {
+		shares[i] = make([]byte, len(secret)+1)
+		shares[i][0] = byte(i + 1)
+	}
+	for j, s := range secret {
+		_, err := rand.Read(coefficients)
+		if err != nil {
+			panic(err)
+		}
+		for i := range shares {
+			x, y := shares[i][0], byte(0)
+			for k := len(coefficients) - 1; k >= 0; k-- {
+				y = gfMul(y^coefficients[k], x)
+			}
+			shares[i][j+1] = y ^ s
+		}
+	}
+	return shares, nil
+}
+
+func ShamirCombine(parts [][]byte) ([]byte, error) {
+	if len(parts) == 0 {
+		return nil, fmt.Errorf("empty parts")
+	}
+	size := len(parts[0])
+	if size < 2 {
+		return nil, fmt.Errorf("invalid part size %d", size)
+	}
+	xs := make(map[byte]bool)
+	for _, p := range parts {
+		if len(p) != size {
+			return nil, fmt.Errorf("inconsistent part size %d %d", size, len(p))
+		}
+		if p[0] == 0 || xs[p[0]] {
+			return nil, fmt.Errorf("invalid part x %d", p[0])
+		}
+		xs[p[0]] = true
+	}
+
+	secret := make([]byte, size-1)
+	for i, pi := range parts {
+		var num, den byte = 1, 1
+		for j, pj := range parts {
+			if i == j {
+				continue
+			}
+			num = gfMul(num, pj[0])
+			den = gfMul(den, pj[0]^pi[0])
+		}
+		basis := gfDiv(num, den)
+		for k := range secret {
+			secret[k] ^= gfMul(pi[k+1], basis)
+		}
+	}
+	return secret, nil
+}
@@ -0,0 +1,48 @@
+package common
+
+import (
+	"bytes"
+	"crypto/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestShamir(t *testing.T) {
+	require := require.New(t)
+
+	secret := make([]byte, 1024)
+	_, err := rand.Read(secret)
+	require.Nil(err)
+
+	parts, err := ShamirSplit(secret, 5, 3)
+	require.Nil(err)
+	require.Len(parts, 5)
+	for _, p := range parts {
+		require.Len(p, len(secret)+1)
+	}
+
+	combined, err := ShamirCombine(parts[:3])
+	require.Nil(err)
+	require.True(bytes.Equal(secret, combined))
+	combined, err = ShamirCombine([][]byte{parts[4], parts[1], parts[2]})
+	require.Nil(err)
+	require.True(bytes.Equal(secret, combined))
+	combined, err = ShamirCombine(parts)
+	require.Nil(err)
+	require.True(bytes.Equal(secret, combined))
+	combined, err = ShamirCombine(parts[:2])
+	require.Nil(err)
+	require.False(bytes.Equal(secret, combined))
+
+	_, err = ShamirCombine([][]byte{parts[0], parts[0]})
+	require.NotNil(err)
+	_, err = ShamirSplit(secret, 2, 3)
+	require.NotNil(err)
+
+	parts, err = ShamirSplit(secret, 3, 1)
+	require.Nil(err)
+	for _, p := range parts {
+		require.True(bytes.Equal(secret, p[1:]))
+	}
+}
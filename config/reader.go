@@ -8,6 +8,7 @@ import (
 	"slices"
 	"strings"
 
+	"github.com/MixinNetwork/safe/custodian"
 	"github.com/MixinNetwork/safe/keeper"
 	"github.com/MixinNetwork/safe/observer"
 	"github.com/MixinNetwork/safe/signer"
@@ -15,10 +16,11 @@ import (
 )
 
 type Configuration struct {
-	Signer   *signer.Configuration   `toml:"signer"`
-	Keeper   *keeper.Configuration   `toml:"keeper"`
-	Observer *observer.Configuration `toml:"observer"`
-	Dev      *DevConfig              `toml:"dev"`
+	Signer    *signer.Configuration    `toml:"signer"`
+	Keeper    *keeper.Configuration    `toml:"keeper"`
+	Custodian *custodian.Configuration `toml:"custodian"`
+	Observer  *observer.Configuration  `toml:"observer"`
+	Dev       *DevConfig               `toml:"dev"`
 }
 
 func ReadConfiguration(path, role string) (*Configuration, error) {
@@ -45,6 +47,7 @@ func (c *Configuration) checkMainnet(role string) {
 	switch role {
 	case "signer":
 	case "keeper":
+	case "custodian":
 	case "observer":
 	default:
 		panic(role)
@@ -140,6 +143,7 @@ func (c *Configuration) checkTestnet(role string) {
 	switch role {
 	case "signer":
 	case "keeper":
+	case "custodian":
 	case "observer":
 	default:
 		panic(role)
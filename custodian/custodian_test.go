@@ -0,0 +1,363 @@
+package custodian
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/safe/apps/mixin"
+	"github.com/MixinNetwork/safe/common"
+	"github.com/MixinNetwork/safe/signer"
+	"github.com/MixinNetwork/trusted-group/mtg"
+	"github.com/gofrs/uuid/v5"
+	"github.com/pelletier/go-toml"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+const testDomainPrivateKey = "5d8ad8bbfb2bc6b2f29ed4f1b1e5e4c7e2b95c3ad0f2f11e0e6a06a3de2b560f"
+
+func TestCustodian(t *testing.T) {
+	require := require.New(t)
+	ctx, signers, _ := signer.TestPrepare(require)
+	workers := testPrepareWorkers(ctx, require)
+
+	domain := testDomainKey()
+	rid := uuid.Must(uuid.NewV4()).String()
+	out := testBuildRefreshRequest(workers[0], rid, domain, domain.Public().String())
+	var tx *mtg.Transaction
+	for _, worker := range workers {
+		txs := testStep(ctx, require, worker, out)
+		require.Len(txs, 1)
+		tx = txs[0]
+	}
+	require.Equal(workers[0].conf.SignerAppId, tx.OpponentAppId)
+	require.Equal(workers[0].conf.AssetId, tx.AssetId)
+	require.Equal(workers[0].signer.Genesis.Members, tx.Receivers)
+
+	req, err := workers[0].store.ReadRefreshRequest(ctx, rid)
+	require.Nil(err)
+	require.Equal(common.RequestStateInitial, int(req.State))
+	require.Equal(domain.Public().String(), req.Signer)
+
+	op := testProcessSignerKeygen(ctx, require, signers, tx, req.SessionId)
+	require.Equal(common.OperationTypeKeygenOutput, int(op.Type))
+	require.Equal(req.SessionId, op.Id)
+	require.Len(op.Extra, 34)
+	require.Equal(byte(common.RequestRoleCustodian), op.Extra[0])
+
+	out = testBuildSignerOutput(workers[0], op)
+	for _, worker := range workers {
+		txs := testStep(ctx, require, worker, out)
+		require.Len(txs, 0)
+		key, err := worker.store.ReadLatestKey(ctx)
+		require.Nil(err)
+		require.Equal(op.Public, key.Public)
+		require.Equal(hex.EncodeToString(op.Extra[1:33]), key.ChainCode)
+		require.Equal(rid, key.RequestId)
+		req, err := worker.store.ReadRefreshRequest(ctx, rid)
+		require.Nil(err)
+		require.Equal(common.RequestStateDone, int(req.State))
+	}
+
+	rid = uuid.Must(uuid.NewV4()).String()
+	out = testBuildRefreshRequest(workers[0], rid, domain, domain.Public().String())
+	for _, worker := range workers {
+		txs := testStep(ctx, require, worker, out)
+		require.Len(txs, 0)
+		req, err := worker.store.ReadRefreshRequest(ctx, rid)
+		require.Nil(err)
+		require.Nil(req)
+	}
+
+	key, err := workers[0].store.ReadLatestKey(ctx)
+	require.Nil(err)
+	md := testBuildMintDistribution(key.Public, "72.04307223")
+	for _, worker := range workers {
+		err = worker.sendDistributionToGroup(ctx, key, md)
+		require.Nil(err)
+	}
+	for _, worker := range workers {
+		d := testWaitDistribution(ctx, worker, md.Transaction)
+		require.NotNil(d)
+		require.Equal(key.Public, d.Public)
+		require.Equal(md.Batch, d.Batch)
+		require.Equal("72.04307223", d.Amount)
+	}
+
+	md = testBuildMintDistribution(key.Public, "3.5")
+	op = encodeDistribution(&Distribution{
+		TransactionHash: md.Transaction,
+		Public:          key.Public,
+		Batch:           md.Batch,
+		Amount:          "3.5",
+	})
+	threshold := workers[0].conf.MTG.Genesis.Threshold
+	for i, id := range workers[0].conf.MTG.Genesis.Members[:threshold] {
+		out := testBuildNodeOutput(workers[0], id, op)
+		txs := testStep(ctx, require, workers[0], out)
+		if i+1 < threshold {
+			require.Len(txs, 0)
+			continue
+		}
+		require.Len(txs, 1)
+		tx = txs[0]
+		require.Equal(workers[0].conf.KeeperAppId, tx.OpponentAppId)
+		require.Equal(workers[0].conf.AssetId, tx.AssetId)
+		require.Equal(workers[0].keeper.Genesis.Members, tx.Receivers)
+		require.Equal(workers[0].keeper.Genesis.Threshold, tx.Threshold)
+		require.Equal(string(op.Encode()), tx.Memo)
+	}
+	out = testBuildNodeOutput(workers[0], workers[0].conf.MTG.Genesis.Members[threshold], op)
+	txs := testStep(ctx, require, workers[0], out)
+	require.Len(txs, 0)
+
+	md = testBuildMintDistribution(domain.Public().String(), "1")
+	err = workers[0].sendDistributionToGroup(ctx, key, md)
+	require.Nil(err)
+	d, err := workers[0].store.ReadDistribution(ctx, md.Transaction)
+	require.Nil(err)
+	require.Nil(d)
+}
+
+func TestCustodianFinalizeWorks(t *testing.T) {
+	require := require.New(t)
+	ctx := common.EnableTestEnvironment(context.Background())
+	workers := testPrepareWorkers(ctx, require)
+
+	members := slices.Clone(workers[0].signer.Genesis.Members)
+	slices.Sort(members)
+	start := time.Now().UTC().Truncate(time.Hour * 24)
+	var op *common.Operation
+	for i := 0; i < worksRewardPayoutDays; i++ {
+		day := start.Add(time.Hour * 24 * time.Duration(i))
+		extra := binary.BigEndian.AppendUint64(nil, uint64(day.Unix()))
+		extra = append(extra, 0, 85, 170, 255)
+		op = &common.Operation{
+			Id:    uuid.Must(uuid.NewV4()).String(),
+			Type:  common.CustodianActionFinalizeWorks,
+			Curve: common.CurveEdwards25519Mixin,
+			Extra: extra,
+		}
+		out := testBuildSignerOutput(workers[0], op)
+		for _, worker := range workers {
+			txs := testStep(ctx, require, worker, out)
+			w, err := worker.store.ReadDailyWorks(ctx, day)
+			require.Nil(err)
+			require.Equal("0055aaff", w.Works)
+			if i+1 < worksRewardPayoutDays {
+				require.Len(txs, 0)
+				continue
+			}
+			require.Len(txs, 3)
+			for i, tx := range txs {
+				require.Equal(worker.conf.RewardAssetId, tx.AssetId)
+				require.Equal([]string{members[i+1]}, tx.Receivers)
+				require.Equal(1, tx.Threshold)
+			}
+			require.Equal("1.16666662", txs[0].Amount)
+			require.Equal("2.33333331", txs[1].Amount)
+			require.Equal("3.5", txs[2].Amount)
+			rewards, err := worker.store.ListUnpaidWorksRewards(ctx)
+			require.Nil(err)
+			require.Len(rewards, 0)
+		}
+	}
+
+	op.Id = uuid.Must(uuid.NewV4()).String()
+	out := testBuildSignerOutput(workers[0], op)
+	txs := testStep(ctx, require, workers[0], out)
+	require.Len(txs, 0)
+}
+
+func testPrepareWorkers(ctx context.Context, require *require.Assertions) []*Worker {
+	f, _ := os.ReadFile("../config/example.toml")
+	var conf struct {
+		Custodian *Configuration `toml:"custodian"`
+	}
+	err := toml.Unmarshal(f, &conf)
+	require.Nil(err)
+	members := conf.Custodian.MTG.Genesis.Members
+
+	network := newTestNetwork(members)
+	workers := make([]*Worker, len(members))
+	for i := range members {
+		root, err := os.MkdirTemp("", fmt.Sprintf("safe-custodian-test-%d", i))
+		require.Nil(err)
+		workers[i] = testBuildWorker(ctx, require, root, i)
+		workers[i].network = network
+		go network.mtgLoop(ctx, workers[i])
+	}
+	return workers
+}
+
+func testBuildWorker(ctx context.Context, require *require.Assertions, root string, i int) *Worker {
+	f, _ := os.ReadFile("../config/example.toml")
+	var conf struct {
+		Custodian *Configuration `toml:"custodian"`
+		Signer    struct {
+			MTG *mtg.Configuration `toml:"mtg"`
+		} `toml:"signer"`
+		Keeper struct {
+			MTG *mtg.Configuration `toml:"mtg"`
+		} `toml:"keeper"`
+	}
+	err := toml.Unmarshal(f, &conf)
+	require.Nil(err)
+
+	conf.Custodian.StoreDir = root
+	conf.Custodian.MTG.App.AppId = conf.Custodian.MTG.Genesis.Members[i]
+	conf.Custodian.DomainPublicKey = testDomainKey().Public().String()
+	if !(strings.HasPrefix(conf.Custodian.StoreDir, "/tmp/") || strings.HasPrefix(conf.Custodian.StoreDir, "/var/folders")) {
+		panic(root)
+	}
+	cd, err := OpenSQLite3Store(conf.Custodian.StoreDir + "/custodian.sqlite3")
+	require.Nil(err)
+
+	db, err := mtg.OpenSQLite3Store(conf.Custodian.StoreDir + "/mtg.sqlite3")
+	require.Nil(err)
+	group, err := mtg.BuildGroup(ctx, db, conf.Custodian.MTG)
+	require.Nil(err)
+	group.EnableDebug()
+
+	worker := NewWorker(cd, group, conf.Custodian, conf.Signer.MTG, conf.Keeper.MTG, nil)
+	group.AttachWorker(worker.conf.AppId, worker)
+	return worker
+}
+
+func testStep(ctx context.Context, require *require.Assertions, worker *Worker, out *mtg.Action) []*mtg.Transaction {
+	out.TestAttachActionToGroup(worker.group)
+	txs1, asset := worker.ProcessOutput(ctx, out)
+	require.Equal("", asset)
+	txs2, asset := worker.ProcessOutput(ctx, out)
+	require.Equal("", asset)
+	require.Len(txs2, len(txs1))
+	for i := range txs1 {
+		require.True(txs1[i].Equal(txs2[i]))
+	}
+	return txs1
+}
+
+func testProcessSignerKeygen(ctx context.Context, require *require.Assertions, signers []*signer.Node, tx *mtg.Transaction, sessionId string) *common.Operation {
+	memo := mtg.EncodeMixinExtraBase64(tx.OpponentAppId, []byte(tx.Memo))
+	out := &mtg.Action{
+		UnifiedOutput: mtg.UnifiedOutput{
+			OutputId:        uuid.Must(uuid.NewV4()).String(),
+			TransactionHash: crypto.Sha256Hash([]byte(sessionId)).String(),
+			AppId:           tx.OpponentAppId,
+			AssetId:         tx.AssetId,
+			Extra:           hex.EncodeToString([]byte(memo)),
+			Amount:          decimal.NewFromInt(1),
+			CreatedAt:       time.Now(),
+		},
+	}
+	op := signer.TestProcessOutput(ctx, require, signers, out, sessionId)
+	require.NotNil(op)
+	return op
+}
+
+func testBuildRefreshRequest(worker *Worker, id string, priv crypto.Key, public string) *mtg.Action {
+	op := &common.Operation{
+		Id:     id,
+		Type:   common.CustodianActionRefreshKey,
+		Curve:  common.CurveEdwards25519Mixin,
+		Public: public,
+	}
+	sig := priv.Sign(crypto.Sha256Hash(op.IdBytes()))
+	op.Extra = sig[:]
+	memo := mtg.EncodeMixinExtraBase64(worker.conf.AppId, op.Encode())
+	return &mtg.Action{
+		UnifiedOutput: mtg.UnifiedOutput{
+			OutputId:        common.UniqueId(op.Id, "output"),
+			TransactionHash: crypto.Sha256Hash([]byte(op.Id)).String(),
+			AppId:           worker.conf.AppId,
+			AssetId:         XINAssetId,
+			Extra:           hex.EncodeToString([]byte(memo)),
+			Amount:          decimal.NewFromInt(1),
+			CreatedAt:       time.Now(),
+			UpdatedAt:       time.Now(),
+		},
+	}
+}
+
+func testBuildSignerOutput(worker *Worker, op *common.Operation) *mtg.Action {
+	extra := common.AESEncrypt(worker.signerAESKey[:], op.Encode(), op.Id)
+	memo := mtg.EncodeMixinExtraBase64(worker.conf.AppId, extra)
+	return &mtg.Action{
+		UnifiedOutput: mtg.UnifiedOutput{
+			OutputId:        common.UniqueId(op.Id, "output"),
+			TransactionHash: crypto.Sha256Hash([]byte(op.Id)).String(),
+			AppId:           worker.conf.AppId,
+			AssetId:         worker.conf.AssetId,
+			Extra:           hex.EncodeToString([]byte(memo)),
+			Amount:          decimal.NewFromInt(1),
+			CreatedAt:       time.Now(),
+			UpdatedAt:       time.Now(),
+		},
+	}
+}
+
+func testBuildNodeOutput(worker *Worker, sender string, op *common.Operation) *mtg.Action {
+	memo := mtg.EncodeMixinExtraBase64(worker.conf.AppId, op.Encode())
+	return &mtg.Action{
+		UnifiedOutput: mtg.UnifiedOutput{
+			OutputId:        common.UniqueId(op.Id, sender),
+			TransactionHash: crypto.Sha256Hash([]byte(op.Id + sender)).String(),
+			AppId:           worker.conf.AppId,
+			Senders:         []string{sender},
+			AssetId:         worker.conf.NodeAssetId,
+			Extra:           hex.EncodeToString([]byte(memo)),
+			Amount:          decimal.NewFromInt(1),
+			CreatedAt:       time.Now(),
+			UpdatedAt:       time.Now(),
+		},
+	}
+}
+
+func testBuildMintDistribution(public, amount string) *MintDistribution {
+	spend, _ := crypto.KeyFromString(public)
+	view := spend.DeterministicHashDerive().Public()
+	seed := crypto.Sha256Hash([]byte(public + amount))
+	r := crypto.NewKeyFromSeed(append(seed[:], seed[:]...))
+	ghost := crypto.DeriveGhostPublicKey(&r, &view, &spend, 1)
+	return &MintDistribution{
+		Transaction: crypto.Sha256Hash(seed[:]).String(),
+		Batch:       1709,
+		Amount:      "1000",
+		Outputs: []mixin.Output{{
+			Amount: "927.95692777",
+			Keys:   []string{r.Public().String()},
+			Mask:   r.Public().String(),
+		}, {
+			Amount: amount,
+			Keys:   []string{ghost.String()},
+			Mask:   r.Public().String(),
+		}},
+	}
+}
+
+func testWaitDistribution(ctx context.Context, worker *Worker, hash string) *Distribution {
+	timeout := time.Now().Add(time.Minute)
+	for ; time.Now().Before(timeout); time.Sleep(time.Second) {
+		d, err := worker.store.ReadDistribution(ctx, hash)
+		if err != nil {
+			panic(err)
+		}
+		if d != nil {
+			return d
+		}
+	}
+	return nil
+}
+
+func testDomainKey() crypto.Key {
+	seed, _ := hex.DecodeString(testDomainPrivateKey)
+	return crypto.NewKeyFromSeed(append(seed, seed...))
+}
@@ -0,0 +1,78 @@
+package custodian
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+type Distribution struct {
+	TransactionHash string
+	Public          string
+	Batch           uint64
+	Amount          string
+	CreatedAt       time.Time
+}
+
+var distributionCols = []string{"transaction_hash", "public", "batch", "amount", "created_at"}
+
+func (s *SQLite3Store) ReadDistribution(ctx context.Context, hash string) (*Distribution, error) {
+	query := fmt.Sprintf("SELECT %s FROM distributions WHERE transaction_hash=?", strings.Join(distributionCols, ","))
+	row := s.db.QueryRowContext(ctx, query, hash)
+
+	var d Distribution
+	err := row.Scan(&d.TransactionHash, &d.Public, &d.Batch, &d.Amount, &d.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return &d, err
+}
+
+// WriteDistributionVote records the vote of the node, and writes the
+// distribution once threshold nodes have voted for exactly the same one.
+func (s *SQLite3Store) WriteDistributionVote(ctx context.Context, d *Distribution, nodeId string, threshold int) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	existed, err := s.checkExistence(ctx, tx, "SELECT transaction_hash FROM distributions WHERE transaction_hash=?", d.TransactionHash)
+	if err != nil || existed {
+		return false, err
+	}
+
+	existed, err = s.checkExistence(ctx, tx, "SELECT node_id FROM distribution_votes WHERE transaction_hash=? AND node_id=?", d.TransactionHash, nodeId)
+	if err != nil || existed {
+		return false, err
+	}
+	cols := []string{"transaction_hash", "node_id", "public", "batch", "amount", "created_at"}
+	vals := []any{d.TransactionHash, nodeId, d.Public, d.Batch, d.Amount, d.CreatedAt}
+	err = s.execOne(ctx, tx, buildInsertionSQL("distribution_votes", cols), vals...)
+	if err != nil {
+		return false, fmt.Errorf("INSERT distribution_votes %v", err)
+	}
+
+	var count int
+	query := "SELECT COUNT(*) FROM distribution_votes WHERE transaction_hash=? AND public=? AND batch=? AND amount=?"
+	row := tx.QueryRowContext(ctx, query, d.TransactionHash, d.Public, d.Batch, d.Amount)
+	err = row.Scan(&count)
+	if err != nil {
+		return false, err
+	}
+	if count < threshold {
+		return false, tx.Commit()
+	}
+
+	vals = []any{d.TransactionHash, d.Public, d.Batch, d.Amount, d.CreatedAt}
+	err = s.execOne(ctx, tx, buildInsertionSQL("distributions", distributionCols), vals...)
+	if err != nil {
+		return false, fmt.Errorf("INSERT distributions %v", err)
+	}
+	return true, tx.Commit()
+}
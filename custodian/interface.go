@@ -0,0 +1,27 @@
+package custodian
+
+import (
+	"context"
+
+	"github.com/MixinNetwork/trusted-group/mtg"
+)
+
+type Configuration struct {
+	AppId           string             `toml:"app-id"`
+	SignerAppId     string             `toml:"signer-app-id"`
+	KeeperAppId     string             `toml:"keeper-app-id"`
+	StoreDir        string             `toml:"store-dir"`
+	DomainPublicKey string             `toml:"domain-public-key"`
+	SharedKey       string             `toml:"shared-key"`
+	SignerPublicKey string             `toml:"signer-public-key"`
+	AssetId         string             `toml:"asset-id"`
+	NodeAssetId     string             `toml:"node-asset-id"`
+	RewardAssetId   string             `toml:"reward-asset-id"`
+	RewardAmount    string             `toml:"reward-amount"`
+	MixinRPC        string             `toml:"mixin-rpc"`
+	MTG             *mtg.Configuration `toml:"mtg"`
+}
+
+type Network interface {
+	QueueMTGOutput(ctx context.Context, b []byte) error
+}
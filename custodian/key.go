@@ -0,0 +1,136 @@
+package custodian
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/MixinNetwork/safe/common"
+)
+
+type RefreshRequest struct {
+	RequestId string
+	SessionId string
+	Signer    string
+	State     byte
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+type Key struct {
+	Public    string
+	ChainCode string
+	SessionId string
+	RequestId string
+	CreatedAt time.Time
+}
+
+var refreshRequestCols = []string{"request_id", "session_id", "signer", "state", "created_at", "updated_at"}
+
+var keyCols = []string{"public", "chain_code", "session_id", "request_id", "created_at"}
+
+func refreshRequestFromRow(row *sql.Row) (*RefreshRequest, error) {
+	var r RefreshRequest
+	err := row.Scan(&r.RequestId, &r.SessionId, &r.Signer, &r.State, &r.CreatedAt, &r.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return &r, err
+}
+
+func keyFromRow(row *sql.Row) (*Key, error) {
+	var k Key
+	err := row.Scan(&k.Public, &k.ChainCode, &k.SessionId, &k.RequestId, &k.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return &k, err
+}
+
+func (s *SQLite3Store) ReadRefreshRequest(ctx context.Context, id string) (*RefreshRequest, error) {
+	query := fmt.Sprintf("SELECT %s FROM refresh_requests WHERE request_id=?", strings.Join(refreshRequestCols, ","))
+	row := s.db.QueryRowContext(ctx, query, id)
+	return refreshRequestFromRow(row)
+}
+
+func (s *SQLite3Store) ReadRefreshRequestBySession(ctx context.Context, sessionId string) (*RefreshRequest, error) {
+	query := fmt.Sprintf("SELECT %s FROM refresh_requests WHERE session_id=?", strings.Join(refreshRequestCols, ","))
+	row := s.db.QueryRowContext(ctx, query, sessionId)
+	return refreshRequestFromRow(row)
+}
+
+func (s *SQLite3Store) WriteRefreshRequest(ctx context.Context, r *RefreshRequest) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	vals := []any{r.RequestId, r.SessionId, r.Signer, r.State, r.CreatedAt, r.UpdatedAt}
+	err = s.execOne(ctx, tx, buildInsertionSQL("refresh_requests", refreshRequestCols), vals...)
+	if err != nil {
+		return fmt.Errorf("INSERT refresh_requests %v", err)
+	}
+	return tx.Commit()
+}
+
+func (s *SQLite3Store) WriteKeyFromRefreshRequest(ctx context.Context, r *RefreshRequest, public, chainCode string, createdAt time.Time) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = s.execOne(ctx, tx, "UPDATE refresh_requests SET state=?, updated_at=? WHERE request_id=? AND state=?",
+		common.RequestStateDone, createdAt, r.RequestId, common.RequestStateInitial)
+	if err != nil {
+		return fmt.Errorf("UPDATE refresh_requests %v", err)
+	}
+
+	vals := []any{public, chainCode, r.SessionId, r.RequestId, createdAt}
+	err = s.execOne(ctx, tx, buildInsertionSQL("keys", keyCols), vals...)
+	if err != nil {
+		return fmt.Errorf("INSERT keys %v", err)
+	}
+	return tx.Commit()
+}
+
+func (s *SQLite3Store) ReadKey(ctx context.Context, public string) (*Key, error) {
+	query := fmt.Sprintf("SELECT %s FROM keys WHERE public=?", strings.Join(keyCols, ","))
+	row := s.db.QueryRowContext(ctx, query, public)
+	return keyFromRow(row)
+}
+
+func (s *SQLite3Store) ReadLatestKey(ctx context.Context) (*Key, error) {
+	query := fmt.Sprintf("SELECT %s FROM keys ORDER BY created_at DESC, public DESC LIMIT 1", strings.Join(keyCols, ","))
+	row := s.db.QueryRowContext(ctx, query)
+	return keyFromRow(row)
+}
+
+func (s *SQLite3Store) ListKeys(ctx context.Context) ([]*Key, error) {
+	query := fmt.Sprintf("SELECT %s FROM keys ORDER BY created_at ASC, public ASC", strings.Join(keyCols, ","))
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []*Key
+	for rows.Next() {
+		var k Key
+		err := rows.Scan(&k.Public, &k.ChainCode, &k.SessionId, &k.RequestId, &k.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, &k)
+	}
+	return keys, nil
+}
@@ -0,0 +1,78 @@
+CREATE TABLE IF NOT EXISTS properties (
+	key           VARCHAR NOT NULL,
+	value         VARCHAR NOT NULL,
+	created_at    TIMESTAMP NOT NULL,
+	updated_at    TIMESTAMP NOT NULL,
+	PRIMARY KEY ('key')
+);
+
+CREATE TABLE IF NOT EXISTS refresh_requests (
+	request_id    VARCHAR NOT NULL,
+	session_id    VARCHAR NOT NULL,
+	signer        VARCHAR NOT NULL,
+	state         INTEGER NOT NULL,
+	created_at    TIMESTAMP NOT NULL,
+	updated_at    TIMESTAMP NOT NULL,
+	PRIMARY KEY ('request_id')
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS refresh_requests_by_session ON refresh_requests(session_id);
+
+CREATE TABLE IF NOT EXISTS keys (
+	public        VARCHAR NOT NULL,
+	chain_code    VARCHAR NOT NULL,
+	session_id    VARCHAR NOT NULL,
+	request_id    VARCHAR NOT NULL,
+	created_at    TIMESTAMP NOT NULL,
+	PRIMARY KEY ('public')
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS keys_by_session ON keys(session_id);
+CREATE INDEX IF NOT EXISTS keys_by_created ON keys(created_at);
+
+CREATE TABLE IF NOT EXISTS distribution_votes (
+	transaction_hash   VARCHAR NOT NULL,
+	node_id            VARCHAR NOT NULL,
+	public             VARCHAR NOT NULL,
+	batch              INTEGER NOT NULL,
+	amount             VARCHAR NOT NULL,
+	created_at         TIMESTAMP NOT NULL,
+	PRIMARY KEY ('transaction_hash', 'node_id')
+);
+
+CREATE TABLE IF NOT EXISTS distributions (
+	transaction_hash   VARCHAR NOT NULL,
+	public             VARCHAR NOT NULL,
+	batch              INTEGER NOT NULL,
+	amount             VARCHAR NOT NULL,
+	created_at         TIMESTAMP NOT NULL,
+	PRIMARY KEY ('transaction_hash')
+);
+
+CREATE INDEX IF NOT EXISTS distributions_by_batch ON distributions(batch);
+
+CREATE TABLE IF NOT EXISTS daily_works (
+	day                TIMESTAMP NOT NULL,
+	works              VARCHAR NOT NULL,
+	created_at         TIMESTAMP NOT NULL,
+	PRIMARY KEY ('day')
+);
+
+CREATE TABLE IF NOT EXISTS works_rewards (
+	day                TIMESTAMP NOT NULL,
+	member_id          VARCHAR NOT NULL,
+	amount             VARCHAR NOT NULL,
+	paid_by            VARCHAR,
+	created_at         TIMESTAMP NOT NULL,
+	PRIMARY KEY ('day', 'member_id')
+);
+
+CREATE INDEX IF NOT EXISTS works_rewards_by_paid ON works_rewards(paid_by);
+
+CREATE TABLE IF NOT EXISTS action_results (
+	output_id       VARCHAR NOT NULL,
+	compaction      VARCHAR NOT NULL,
+	transactions    TEXT NOT NULL,
+	created_at      TIMESTAMP NOT NULL,
+	PRIMARY KEY ('output_id')
+);
@@ -10,6 +10,8 @@ import (
 	"time"
 
 	"github.com/MixinNetwork/safe/common"
+	"github.com/MixinNetwork/trusted-group/mtg"
+	"github.com/gofrs/uuid/v5"
 )
 
 //go:embed schema.sql
@@ -77,16 +79,81 @@ func (s *SQLite3Store) ReadProperty(ctx context.Context, k string) (string, erro
 }
 
 func (s *SQLite3Store) WriteProperty(ctx context.Context, k, v string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	existed, err := s.checkExistence(ctx, tx, "SELECT value FROM properties WHERE key=?", k)
+	if err != nil {
+		return err
+	}
+
+	createdAt := time.Now().UTC()
+	if existed {
+		err = s.execOne(ctx, tx, "UPDATE properties SET value=?, updated_at=? WHERE key=?", v, createdAt, k)
+		if err != nil {
+			return fmt.Errorf("UPDATE properties %v", err)
+		}
+	} else {
+		cols := []string{"key", "value", "created_at", "updated_at"}
+		err = s.execOne(ctx, tx, buildInsertionSQL("properties", cols), k, v, createdAt, createdAt)
+		if err != nil {
+			return fmt.Errorf("INSERT properties %v", err)
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *SQLite3Store) WriteActionResults(ctx context.Context, outputId string, txs []*mtg.Transaction, compaction string) error {
+	if uuid.Must(uuid.FromString(outputId)).String() != outputId {
+		panic(outputId)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
 	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
 
-	cols := []string{"key", "value", "created_at"}
-	err = s.execOne(ctx, tx, buildInsertionSQL("properties", cols), k, v, time.Now().UTC())
+	ts := common.Base91Encode(mtg.SerializeTransactions(txs))
+	cols := []string{"output_id", "compaction", "transactions", "created_at"}
+	vals := []any{outputId, compaction, ts, time.Now().UTC()}
+	err = s.execOne(ctx, tx, buildInsertionSQL("action_results", cols), vals...)
 	if err != nil {
-		return fmt.Errorf("INSERT properties %v", err)
+		return fmt.Errorf("INSERT action_results %v", err)
 	}
 	return tx.Commit()
 }
+
+func (s *SQLite3Store) ReadActionResults(ctx context.Context, outputId string) ([]*mtg.Transaction, string, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	query := "SELECT transactions,compaction FROM action_results WHERE output_id=?"
+	row := s.db.QueryRowContext(ctx, query, outputId)
+	var ts, compaction string
+	err := row.Scan(&ts, &compaction)
+	if err == sql.ErrNoRows {
+		return nil, "", false
+	} else if err != nil {
+		panic(err)
+	}
+
+	tb, err := common.Base91Decode(ts)
+	if err != nil {
+		panic(ts)
+	}
+	txs, err := mtg.DeserializeTransactions(tb)
+	if err != nil {
+		panic(ts)
+	}
+	return txs, compaction, true
+}
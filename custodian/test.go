@@ -0,0 +1,83 @@
+package custodian
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/MixinNetwork/mixin/logger"
+	"github.com/MixinNetwork/safe/common"
+	"github.com/MixinNetwork/trusted-group/mtg"
+	"github.com/gofrs/uuid/v5"
+)
+
+type testNetwork struct {
+	mtgChannels map[string]chan []byte
+	mtx         sync.Mutex
+}
+
+func newTestNetwork(members []string) *testNetwork {
+	n := &testNetwork{
+		mtgChannels: make(map[string]chan []byte, len(members)),
+	}
+	N := len(members)
+	for _, id := range members {
+		n.mtgChannels[id] = make(chan []byte, N*N)
+	}
+	return n
+}
+
+func (n *testNetwork) mtgLoop(ctx context.Context, worker *Worker) {
+	filter := make(map[string]bool)
+	loop := n.mtgChannel(worker.conf.MTG.App.AppId)
+	for mob := range loop {
+		k := hex.EncodeToString(mob)
+		if filter[k] {
+			continue
+		}
+		var out mtg.Action
+		json.Unmarshal(mob, &out)
+		out.TestAttachActionToGroup(worker.group)
+		_, asset := worker.ProcessOutput(ctx, &out)
+		if asset != "" {
+			panic(asset)
+		}
+		logger.Verbosef("test.mtgLoop(%s) => %s", worker.conf.MTG.App.AppId, out.OutputId)
+		filter[k] = true
+	}
+}
+
+func (worker *Worker) mtgQueueTestOutput(ctx context.Context, memo []byte) error {
+	out := &mtg.Action{
+		UnifiedOutput: mtg.UnifiedOutput{
+			OutputId:  uuid.Must(uuid.NewV4()).String(),
+			AppId:     worker.conf.AppId,
+			Senders:   []string{worker.conf.MTG.App.AppId},
+			AssetId:   worker.conf.NodeAssetId,
+			CreatedAt: time.Now(),
+		},
+	}
+	out.Extra = mtg.EncodeMixinExtraBase64(worker.conf.AppId, memo)
+	out.Extra = hex.EncodeToString([]byte(out.Extra))
+	data := common.MarshalJSONOrPanic(out)
+	return worker.network.QueueMTGOutput(ctx, data)
+}
+
+func (n *testNetwork) QueueMTGOutput(ctx context.Context, b []byte) error {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+
+	for _, c := range n.mtgChannels {
+		c <- b
+	}
+	return nil
+}
+
+func (n *testNetwork) mtgChannel(id string) chan []byte {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+
+	return n.mtgChannels[id]
+}
@@ -2,58 +2,509 @@ package custodian
 
 import (
 	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
 
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/logger"
+	m "github.com/MixinNetwork/safe/apps/mixin"
+	"github.com/MixinNetwork/safe/common"
 	"github.com/MixinNetwork/trusted-group/mtg"
+	"github.com/fox-one/mixin-sdk-go/v2"
+	"github.com/shopspring/decimal"
 )
 
 const (
 	XINAssetId = "c94ac88f-4671-3976-b60a-09064f1811e8"
 
-	// domain do the first key, next should be from the first key
-	CustodianActionRefreshKey = 1
-	CustodianActionDistribute = 2
-
-	// every signer node will send this to the mtg
-	// everyday at a specific time
-	// then at some point, a random output will cause
-	// all signer nodes to finalize the works
-	CustodianActionVoteWorks = 3
-	// then the signer mtg send this action to keeper mtg
-	// the custodian will process this then
-	CustodianActionFinalizeWorks = 4
+	mintDistributionsCheckpointKey = "mint-distributions-checkpoint"
+	mintDistributionsLimit         = 100
 )
 
+type MintDistribution struct {
+	Transaction string
+	Batch       uint64
+	Amount      string
+	Outputs     []m.Output
+}
+
 type Worker struct {
-	store           *SQLite3Store
-	signerAssetId   string
-	keeperAssetId   string
-	observerAssetId string
+	conf         *Configuration
+	group        *mtg.Group
+	store        *SQLite3Store
+	signer       *mtg.Configuration
+	keeper       *mtg.Configuration
+	signerAESKey [32]byte
+	mixin        *mixin.Client
+	network      Network
 }
 
-func NewWorker(s *SQLite3Store) *Worker {
-	return &Worker{
-		store: s,
+func NewWorker(s *SQLite3Store, group *mtg.Group, conf *Configuration, signer, keeper *mtg.Configuration, client *mixin.Client) *Worker {
+	worker := &Worker{
+		conf:   conf,
+		group:  group,
+		store:  s,
+		signer: signer,
+		keeper: keeper,
+		mixin:  client,
 	}
+	worker.signerAESKey = common.ECDHEd25519(conf.SharedKey, conf.SignerPublicKey)
+	return worker
 }
 
 func (worker *Worker) ProcessOutput(ctx context.Context, out *mtg.Action) ([]*mtg.Transaction, string) {
-	return nil, ""
+	logger.Verbosef("worker.ProcessOutput(%v)", out)
+	txs1, asset1 := worker.processActionWithPersistence(ctx, out)
+	txs2, asset2 := worker.processActionWithPersistence(ctx, out)
+	mtg.ReplayCheck(out, txs1, txs2, asset1, asset2)
+	return txs1, asset1
 }
 
 func (worker *Worker) Boot(ctx context.Context) {
 	go worker.loopKernelMintDistributions(ctx)
 }
 
-func (worker *Worker) handleRefreshKey() {
-	// domain signature verification
-	// send a request to signer keygen, ed25519 mixin
-	// receive keygen from signer and store the key
-	// custodian use public derivation of spend key
+func (worker *Worker) processActionWithPersistence(ctx context.Context, out *mtg.Action) ([]*mtg.Transaction, string) {
+	txs, compaction, found := worker.store.ReadActionResults(ctx, out.OutputId)
+	if found {
+		return txs, compaction
+	}
+	txs, compaction = worker.processAction(ctx, out)
+	err := worker.store.WriteActionResults(ctx, out.OutputId, txs, compaction)
+	if err != nil {
+		panic(err)
+	}
+	return txs, compaction
+}
+
+func (worker *Worker) processAction(ctx context.Context, out *mtg.Action) ([]*mtg.Transaction, string) {
+	switch out.AssetId {
+	case worker.conf.AssetId:
+		if out.Amount.Cmp(decimal.NewFromInt(1)) < 0 {
+			panic(out.TransactionHash)
+		}
+		op, err := worker.parseSignerResponse(out)
+		logger.Printf("worker.parseSignerResponse(%v) => %v %v", out, op, err)
+		if err != nil {
+			return nil, ""
+		}
+		switch op.Type {
+		case common.OperationTypeKeygenOutput:
+			return worker.processSignerKeygenResult(ctx, op, out)
+		case common.CustodianActionFinalizeWorks:
+			return worker.handleFinalizeWorks(ctx, op, out)
+		}
+	case worker.conf.NodeAssetId:
+		op, err := worker.parseNodeRequest(out)
+		logger.Printf("worker.parseNodeRequest(%v) => %v %v", out, op, err)
+		if err != nil {
+			return nil, ""
+		}
+		switch op.Type {
+		case common.CustodianActionDistribute:
+			return worker.handleDistribute(ctx, op, out)
+		}
+	default:
+		op, err := worker.parseDomainRequest(out)
+		logger.Printf("worker.parseDomainRequest(%v) => %v %v", out, op, err)
+		if err != nil {
+			return nil, ""
+		}
+		switch op.Type {
+		case common.CustodianActionRefreshKey:
+			return worker.handleRefreshKey(ctx, op, out)
+		}
+	}
+	return nil, ""
+}
+
+func (worker *Worker) parseSignerResponse(out *mtg.Action) (*common.Operation, error) {
+	a, m := mtg.DecodeMixinExtraHEX(out.Extra)
+	if a != worker.conf.AppId {
+		panic(out.Extra)
+	}
+	if len(m) < 12 {
+		return nil, fmt.Errorf("worker.parseSignerResponse(%v)", out)
+	}
+	b := common.AESDecrypt(worker.signerAESKey[:], m)
+	return common.DecodeOperation(b)
+}
+
+func (worker *Worker) parseNodeRequest(out *mtg.Action) (*common.Operation, error) {
+	if len(out.Senders) != 1 || !slices.Contains(worker.conf.MTG.Genesis.Members, out.Senders[0]) {
+		return nil, fmt.Errorf("worker.parseNodeRequest(%v) invalid senders %v", out, out.Senders)
+	}
+	a, m := mtg.DecodeMixinExtraHEX(out.Extra)
+	if a != worker.conf.AppId {
+		panic(out.Extra)
+	}
+	if m == nil {
+		return nil, fmt.Errorf("worker.parseNodeRequest(%v)", out)
+	}
+	return common.DecodeOperation(m)
+}
+
+func (worker *Worker) parseDomainRequest(out *mtg.Action) (*common.Operation, error) {
+	a, m := mtg.DecodeMixinExtraHEX(out.Extra)
+	if a != worker.conf.AppId {
+		panic(out.Extra)
+	}
+	if m == nil {
+		return nil, fmt.Errorf("worker.parseDomainRequest(%v)", out)
+	}
+	return common.DecodeOperation(m)
+}
+
+// the first refresh request must be signed by the domain key, and all
+// later ones must be signed by the latest custodian key, then a mixin
+// key is requested from the signer mtg to replace the custodian key
+func (worker *Worker) handleRefreshKey(ctx context.Context, op *common.Operation, out *mtg.Action) ([]*mtg.Transaction, string) {
+	if op.Curve != common.CurveEdwards25519Mixin || len(op.Extra) != 64 {
+		return nil, ""
+	}
+	old, err := worker.store.ReadRefreshRequest(ctx, op.Id)
+	if err != nil {
+		panic(fmt.Errorf("store.ReadRefreshRequest(%s) => %v", op.Id, err))
+	} else if old != nil {
+		return nil, ""
+	}
+
+	signer := worker.conf.DomainPublicKey
+	latest, err := worker.store.ReadLatestKey(ctx)
+	if err != nil {
+		panic(fmt.Errorf("store.ReadLatestKey() => %v", err))
+	} else if latest != nil {
+		signer = latest.Public
+	}
+	if op.Public != signer {
+		logger.Printf("worker.handleRefreshKey(%v) invalid signer %s", op, signer)
+		return nil, ""
+	}
+	if !verifyRefreshKeySignature(op) {
+		logger.Printf("worker.handleRefreshKey(%v) invalid signature", op)
+		return nil, ""
+	}
+
+	keygen := &common.Operation{
+		Type:  common.OperationTypeKeygenInput,
+		Curve: common.CurveEdwards25519Mixin,
+		Extra: []byte{common.RequestRoleCustodian},
+	}
+	keygen.Id = common.UniqueId(op.Id, "CUSTODIAN:KEYGEN")
+	keygen.Id = common.UniqueId(keygen.Id, fmt.Sprintf("MTG:%v:%d", worker.signer.Genesis.Members, worker.signer.Genesis.Threshold))
+	tx, asset := worker.buildSignerTransaction(ctx, out, keygen)
+	if asset != "" {
+		return nil, asset
+	}
+
+	err = worker.store.WriteRefreshRequest(ctx, &RefreshRequest{
+		RequestId: op.Id,
+		SessionId: keygen.Id,
+		Signer:    op.Public,
+		State:     common.RequestStateInitial,
+		CreatedAt: out.CreatedAt,
+		UpdatedAt: out.CreatedAt,
+	})
+	if err != nil {
+		panic(fmt.Errorf("store.WriteRefreshRequest(%v) => %v", op, err))
+	}
+	return []*mtg.Transaction{tx}, ""
+}
+
+func (worker *Worker) processSignerKeygenResult(ctx context.Context, op *common.Operation, out *mtg.Action) ([]*mtg.Transaction, string) {
+	if op.Curve != common.CurveEdwards25519Mixin || len(op.Extra) != 34 {
+		return nil, ""
+	}
+	if op.Extra[0] != common.RequestRoleCustodian || op.Extra[33] != common.RequestFlagNone {
+		return nil, ""
+	}
+	req, err := worker.store.ReadRefreshRequestBySession(ctx, op.Id)
+	if err != nil {
+		panic(fmt.Errorf("store.ReadRefreshRequestBySession(%s) => %v", op.Id, err))
+	}
+	if req == nil || req.State != common.RequestStateInitial {
+		return nil, ""
+	}
+	public, err := crypto.KeyFromString(op.Public)
+	if err != nil || !public.CheckKey() {
+		return nil, ""
+	}
+	old, err := worker.store.ReadKey(ctx, op.Public)
+	if err != nil {
+		panic(fmt.Errorf("store.ReadKey(%s) => %v", op.Public, err))
+	} else if old != nil {
+		return nil, ""
+	}
+
+	chainCode := hex.EncodeToString(op.Extra[1:33])
+	err = worker.store.WriteKeyFromRefreshRequest(ctx, req, op.Public, chainCode, out.CreatedAt)
+	if err != nil {
+		panic(fmt.Errorf("store.WriteKeyFromRefreshRequest(%v) => %v", req, err))
+	}
+	return nil, ""
 }
 
+func (worker *Worker) handleDistribute(ctx context.Context, op *common.Operation, out *mtg.Action) ([]*mtg.Transaction, string) {
+	d, err := decodeDistribution(op)
+	if err != nil {
+		logger.Printf("decodeDistribution(%v) => %v", op, err)
+		return nil, ""
+	}
+	key, err := worker.store.ReadKey(ctx, d.Public)
+	if err != nil {
+		panic(fmt.Errorf("store.ReadKey(%s) => %v", d.Public, err))
+	} else if key == nil {
+		return nil, ""
+	}
+
+	d.CreatedAt = out.CreatedAt
+	threshold := worker.conf.MTG.Genesis.Threshold
+	confirmed, err := worker.store.WriteDistributionVote(ctx, d, out.Senders[0], threshold)
+	logger.Printf("store.WriteDistributionVote(%v, %s) => %t %v", d, out.Senders[0], confirmed, err)
+	if err != nil {
+		panic(err)
+	}
+	if !confirmed {
+		return nil, ""
+	}
+
+	op = encodeDistribution(d)
+	tx, asset := worker.buildKeeperTransaction(ctx, out, op)
+	if asset != "" {
+		return nil, asset
+	}
+	return []*mtg.Transaction{tx}, ""
+}
+
+func (worker *Worker) buildSignerTransaction(ctx context.Context, act *mtg.Action, op *common.Operation) (*mtg.Transaction, string) {
+	extra := common.AESEncrypt(worker.signerAESKey[:], op.Encode(), op.Id)
+	if len(extra) > 160 {
+		panic(fmt.Errorf("worker.buildSignerTransaction(%v) omitted %x", op, extra))
+	}
+
+	amount := decimal.NewFromInt(1)
+	if !common.CheckTestEnvironment(ctx) {
+		balance := act.CheckAssetBalanceAt(ctx, worker.conf.AssetId)
+		if balance.Cmp(amount) < 0 {
+			return nil, worker.conf.AssetId
+		}
+	}
+
+	members := worker.signer.Genesis.Members
+	threshold := worker.signer.Genesis.Threshold
+	traceId := common.UniqueId(worker.group.GenesisId(), op.Id)
+	tx := act.BuildTransaction(ctx, traceId, worker.conf.SignerAppId, worker.conf.AssetId, amount.String(), string(extra), members, threshold)
+	logger.Printf("worker.buildSignerTransaction(%v) => %s %x %x", op, traceId, extra, tx.Serialize())
+	return tx, ""
+}
+
+// the distribution confirmed by the custodian nodes is forwarded to the keeper
+// mtg, with the same asset used to send operations to the signer mtg
+func (worker *Worker) buildKeeperTransaction(ctx context.Context, act *mtg.Action, op *common.Operation) (*mtg.Transaction, string) {
+	extra := op.Encode()
+	if len(extra) > 160 {
+		panic(fmt.Errorf("worker.buildKeeperTransaction(%v) omitted %x", op, extra))
+	}
+
+	amount := decimal.NewFromInt(1)
+	if !common.CheckTestEnvironment(ctx) {
+		balance := act.CheckAssetBalanceAt(ctx, worker.conf.AssetId)
+		if balance.Cmp(amount) < 0 {
+			return nil, worker.conf.AssetId
+		}
+	}
+
+	members := worker.keeper.Genesis.Members
+	threshold := worker.keeper.Genesis.Threshold
+	traceId := common.UniqueId(worker.group.GenesisId(), op.Id)
+	tx := act.BuildTransaction(ctx, traceId, worker.conf.KeeperAppId, worker.conf.AssetId, amount.String(), string(extra), members, threshold)
+	logger.Printf("worker.buildKeeperTransaction(%v) => %s %x %x", op, traceId, extra, tx.Serialize())
+	return tx, ""
+}
+
+// the checkpoint is written after each distribution is sent successfully,
+// otherwise the same distribution is retried from the checkpoint
 func (worker *Worker) loopKernelMintDistributions(ctx context.Context) {
-	//for {
-	// loop read mint distributions to the custodian key
-	// for new distribution, send the mint to keeper MTG with custodian action distribute
-	//}
+	for {
+		time.Sleep(time.Second)
+		checkpoint, err := worker.readMintDistributionsCheckpoint(ctx)
+		if err != nil {
+			panic(err)
+		}
+		mds, err := worker.listMintDistributions(ctx, checkpoint)
+		if err != nil {
+			logger.Printf("worker.listMintDistributions(%d) => %v", checkpoint, err)
+			continue
+		}
+
+		keys, err := worker.store.ListKeys(ctx)
+		if err != nil {
+			panic(err)
+		}
+		sent := 0
+		for _, md := range mds {
+			err := worker.sendMintDistribution(ctx, keys, md)
+			if err != nil {
+				break
+			}
+			err = worker.writeMintDistributionsCheckpoint(ctx, md.Batch+1)
+			if err != nil {
+				panic(err)
+			}
+			sent++
+		}
+		if sent < len(mds) {
+			time.Sleep(time.Second * 5)
+		} else if len(mds) < mintDistributionsLimit {
+			time.Sleep(time.Minute)
+		}
+	}
+}
+
+func (worker *Worker) sendMintDistribution(ctx context.Context, keys []*Key, md *MintDistribution) error {
+	for _, k := range keys {
+		err := worker.sendDistributionToGroup(ctx, k, md)
+		logger.Printf("worker.sendDistributionToGroup(%v, %v) => %v", k, md, err)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (worker *Worker) listMintDistributions(ctx context.Context, offset uint64) ([]*MintDistribution, error) {
+	mds, err := m.RPCListMintDistributions(ctx, worker.conf.MixinRPC, offset, mintDistributionsLimit)
+	if err != nil {
+		return nil, err
+	}
+	distributions := make([]*MintDistribution, len(mds))
+	for i, md := range mds {
+		tx, err := m.RPCGetTransaction(ctx, worker.conf.MixinRPC, md.Transaction)
+		if err != nil {
+			return nil, err
+		}
+		distributions[i] = &MintDistribution{
+			Transaction: md.Transaction,
+			Batch:       md.Batch,
+			Amount:      md.Amount,
+			Outputs:     tx.Output,
+		}
+	}
+	return distributions, nil
+}
+
+// the mint distribution to the custodian is sent to the custodian address, and
+// the address view key is the deterministic public derivation of the spend key
+func (worker *Worker) sendDistributionToGroup(ctx context.Context, k *Key, md *MintDistribution) error {
+	spend, err := crypto.KeyFromString(k.Public)
+	if err != nil {
+		panic(k.Public)
+	}
+	view := spend.DeterministicHashDerive()
+	var amount decimal.Decimal
+	for i, out := range md.Outputs {
+		if len(out.Keys) != 1 {
+			continue
+		}
+		mask, err := crypto.KeyFromString(out.Mask)
+		if err != nil {
+			continue
+		}
+		ghost, err := crypto.KeyFromString(out.Keys[0])
+		if err != nil {
+			continue
+		}
+		if *crypto.ViewGhostOutputKey(&ghost, &view, &mask, uint64(i)) != spend {
+			continue
+		}
+		amount = amount.Add(decimal.RequireFromString(out.Amount))
+	}
+	if !amount.IsPositive() {
+		return nil
+	}
+
+	op := encodeDistribution(&Distribution{
+		TransactionHash: md.Transaction,
+		Public:          k.Public,
+		Batch:           md.Batch,
+		Amount:          amount.String(),
+	})
+	return worker.sendTransactionToGroupUntilSufficient(ctx, op.Encode(), op.Id)
+}
+
+func (worker *Worker) sendTransactionToGroupUntilSufficient(ctx context.Context, memo []byte, traceId string) error {
+	receivers := worker.conf.MTG.Genesis.Members
+	threshold := worker.conf.MTG.Genesis.Threshold
+	amount := decimal.NewFromInt(1)
+	traceId = common.UniqueId(traceId, worker.conf.MTG.App.AppId)
+	traceId = common.UniqueId(traceId, fmt.Sprintf("MTG:%v:%d", receivers, threshold))
+
+	if common.CheckTestEnvironment(ctx) {
+		return worker.mtgQueueTestOutput(ctx, memo)
+	}
+	m := mtg.EncodeMixinExtraBase64(worker.conf.AppId, memo)
+	_, err := common.SendTransactionUntilSufficient(ctx, worker.mixin, []string{worker.mixin.ClientID}, 1, receivers, threshold, amount, traceId, worker.conf.NodeAssetId, m, worker.conf.MTG.App.SpendPrivateKey)
+	return err
+}
+
+func encodeDistribution(d *Distribution) *common.Operation {
+	hash, err := crypto.HashFromString(d.TransactionHash)
+	if err != nil {
+		panic(d.TransactionHash)
+	}
+	extra := binary.BigEndian.AppendUint64(hash[:], d.Batch)
+	extra = append(extra, []byte(d.Amount)...)
+	return &common.Operation{
+		Id:     common.UniqueId(d.TransactionHash, d.Public),
+		Type:   common.CustodianActionDistribute,
+		Curve:  common.CurveEdwards25519Mixin,
+		Public: d.Public,
+		Extra:  extra,
+	}
+}
+
+func decodeDistribution(op *common.Operation) (*Distribution, error) {
+	if op.Curve != common.CurveEdwards25519Mixin || len(op.Extra) <= 40 {
+		return nil, fmt.Errorf("invalid distribution %v", op)
+	}
+	hash := crypto.Hash(op.Extra[:32])
+	amount, err := decimal.NewFromString(string(op.Extra[40:]))
+	if err != nil || !amount.IsPositive() || amount.String() != string(op.Extra[40:]) {
+		return nil, fmt.Errorf("invalid distribution amount %x", op.Extra[40:])
+	}
+	if op.Id != common.UniqueId(hash.String(), op.Public) {
+		return nil, fmt.Errorf("invalid distribution id %v", op)
+	}
+	return &Distribution{
+		TransactionHash: hash.String(),
+		Public:          op.Public,
+		Batch:           binary.BigEndian.Uint64(op.Extra[32:40]),
+		Amount:          amount.String(),
+	}, nil
+}
+
+func verifyRefreshKeySignature(op *common.Operation) bool {
+	public, err := crypto.KeyFromString(op.Public)
+	if err != nil {
+		return false
+	}
+	var sig crypto.Signature
+	copy(sig[:], op.Extra)
+	return public.Verify(crypto.Sha256Hash(op.IdBytes()), sig)
+}
+
+func (worker *Worker) readMintDistributionsCheckpoint(ctx context.Context) (uint64, error) {
+	val, err := worker.store.ReadProperty(ctx, mintDistributionsCheckpointKey)
+	if err != nil || val == "" {
+		return 0, err
+	}
+	return strconv.ParseUint(val, 10, 64)
+}
+
+func (worker *Worker) writeMintDistributionsCheckpoint(ctx context.Context, checkpoint uint64) error {
+	return worker.store.WriteProperty(ctx, mintDistributionsCheckpointKey, fmt.Sprint(checkpoint))
 }
@@ -0,0 +1,206 @@
+package custodian
+
+import (
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/MixinNetwork/mixin/logger"
+	"github.com/MixinNetwork/safe/common"
+	"github.com/MixinNetwork/trusted-group/mtg"
+	"github.com/shopspring/decimal"
+)
+
+// the rewards are accrued daily and paid in one transaction to each member
+// after the rewards of this many days are finalized
+const worksRewardPayoutDays = 7
+
+type DailyWorks struct {
+	Day       time.Time
+	Works     string
+	CreatedAt time.Time
+}
+
+type WorksReward struct {
+	Day       time.Time
+	MemberId  string
+	Amount    string
+	PaidBy    sql.NullString
+	CreatedAt time.Time
+}
+
+// the signer mtg finalizes the works of all signer members sorted by id,
+// then the reward pool is split to them pro rata of the works
+func (worker *Worker) handleFinalizeWorks(ctx context.Context, op *common.Operation, out *mtg.Action) ([]*mtg.Transaction, string) {
+	members := slices.Clone(worker.signer.Genesis.Members)
+	slices.Sort(members)
+	if len(op.Extra) != 8+len(members) {
+		return nil, ""
+	}
+	day := time.Unix(int64(binary.BigEndian.Uint64(op.Extra[:8])), 0).UTC()
+	works := op.Extra[8:]
+	old, err := worker.store.ReadDailyWorks(ctx, day)
+	if err != nil {
+		panic(fmt.Errorf("store.ReadDailyWorks(%v) => %v", day, err))
+	} else if old != nil {
+		return nil, ""
+	}
+
+	var rewards []*WorksReward
+	for i, a := range worker.splitRewardPool(works) {
+		if !a.IsPositive() {
+			continue
+		}
+		rewards = append(rewards, &WorksReward{
+			Day:       day,
+			MemberId:  members[i],
+			Amount:    a.String(),
+			CreatedAt: out.CreatedAt,
+		})
+	}
+	unpaid, err := worker.store.ListUnpaidWorksRewards(ctx)
+	if err != nil {
+		panic(fmt.Errorf("store.ListUnpaidWorksRewards() => %v", err))
+	}
+
+	var txs []*mtg.Transaction
+	payouts, days := sumWorksRewards(members, append(unpaid, rewards...))
+	if days >= worksRewardPayoutDays {
+		total := decimal.Zero
+		for _, a := range payouts {
+			total = total.Add(a)
+		}
+		if total.IsPositive() && !common.CheckTestEnvironment(ctx) {
+			balance := out.CheckAssetBalanceAt(ctx, worker.conf.RewardAssetId)
+			if balance.Cmp(total) < 0 {
+				return nil, worker.conf.RewardAssetId
+			}
+		}
+		for i, id := range members {
+			if !payouts[i].IsPositive() {
+				continue
+			}
+			traceId := common.UniqueId(op.Id, id)
+			tx := out.BuildTransaction(ctx, traceId, worker.conf.AppId, worker.conf.RewardAssetId, payouts[i].String(), "", []string{id}, 1)
+			logger.Printf("worker.handleFinalizeWorks(%v, %s) => %s %s", day, id, traceId, payouts[i])
+			txs = append(txs, tx)
+		}
+	}
+
+	var paidBy string
+	if len(txs) > 0 {
+		paidBy = op.Id
+	}
+	err = worker.store.WriteDailyWorks(ctx, &DailyWorks{
+		Day:       day,
+		Works:     hex.EncodeToString(works),
+		CreatedAt: out.CreatedAt,
+	}, rewards, paidBy)
+	if err != nil {
+		panic(fmt.Errorf("store.WriteDailyWorks(%v) => %v", day, err))
+	}
+	return txs, ""
+}
+
+func (worker *Worker) splitRewardPool(works []byte) []decimal.Decimal {
+	amounts := make([]decimal.Decimal, len(works))
+	if worker.conf.RewardAssetId == "" || worker.conf.RewardAmount == "" {
+		return amounts
+	}
+	pool := decimal.RequireFromString(worker.conf.RewardAmount)
+	var sum int64
+	for _, w := range works {
+		sum += int64(w)
+	}
+	for i, w := range works {
+		if sum == 0 {
+			continue
+		}
+		share := pool.Mul(decimal.NewFromInt(int64(w))).Div(decimal.NewFromInt(sum))
+		amounts[i] = share.RoundDown(8)
+	}
+	return amounts
+}
+
+// the accrued rewards of each member in the sorted members order, and the
+// count of days with rewards accrued
+func sumWorksRewards(members []string, rewards []*WorksReward) ([]decimal.Decimal, int) {
+	amounts := make([]decimal.Decimal, len(members))
+	days := make(map[int64]bool)
+	for _, r := range rewards {
+		days[r.Day.Unix()] = true
+		i := slices.Index(members, r.MemberId)
+		if i < 0 {
+			continue
+		}
+		amounts[i] = amounts[i].Add(decimal.RequireFromString(r.Amount))
+	}
+	return amounts, len(days)
+}
+
+func (s *SQLite3Store) ReadDailyWorks(ctx context.Context, day time.Time) (*DailyWorks, error) {
+	row := s.db.QueryRowContext(ctx, "SELECT day,works,created_at FROM daily_works WHERE day=?", day)
+	var w DailyWorks
+	err := row.Scan(&w.Day, &w.Works, &w.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return &w, err
+}
+
+func (s *SQLite3Store) ListUnpaidWorksRewards(ctx context.Context) ([]*WorksReward, error) {
+	query := "SELECT day,member_id,amount,paid_by,created_at FROM works_rewards WHERE paid_by IS NULL ORDER BY day ASC, member_id ASC"
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rewards []*WorksReward
+	for rows.Next() {
+		var r WorksReward
+		err := rows.Scan(&r.Day, &r.MemberId, &r.Amount, &r.PaidBy, &r.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		rewards = append(rewards, &r)
+	}
+	return rewards, nil
+}
+
+// the rewards of the day are written with the works, and all the unpaid
+// rewards are marked paid by the payout if present
+func (s *SQLite3Store) WriteDailyWorks(ctx context.Context, w *DailyWorks, rewards []*WorksReward, payout string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	cols := []string{"day", "works", "created_at"}
+	err = s.execOne(ctx, tx, buildInsertionSQL("daily_works", cols), w.Day, w.Works, w.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("INSERT daily_works %v", err)
+	}
+	cols = []string{"day", "member_id", "amount", "created_at"}
+	for _, r := range rewards {
+		err = s.execOne(ctx, tx, buildInsertionSQL("works_rewards", cols), r.Day, r.MemberId, r.Amount, r.CreatedAt)
+		if err != nil {
+			return fmt.Errorf("INSERT works_rewards %v", err)
+		}
+	}
+	if payout != "" {
+		_, err = tx.ExecContext(ctx, "UPDATE works_rewards SET paid_by=? WHERE paid_by IS NULL", payout)
+		if err != nil {
+			return fmt.Errorf("UPDATE works_rewards %v", err)
+		}
+	}
+	return tx.Commit()
+}
@@ -145,7 +145,11 @@ func (node *Node) processBitcoinSafeCloseAccount(ctx context.Context, req *commo
 	if info == nil {
 		return node.failRequest(ctx, req, "")
 	}
-	sequence := uint64(bitcoin.ParseSequence(safe.Timelock, safe.Chain))
+	blocks, err := bitcoin.ParseSequence(safe.Timelock, safe.Chain)
+	if err != nil {
+		panic(err)
+	}
+	sequence := uint64(blocks)
 
 	var total int64
 	var requests []*store.SignatureRequest
@@ -179,7 +183,7 @@ func (node *Node) processBitcoinSafeCloseAccount(ctx context.Context, req *commo
 			TransactionHash: txHash,
 			InputIndex:      idx,
 			Signer:          safe.Signer,
-			Curve:           req.Curve,
+			Curve:           node.bitcoinSignatureCurve(ctx, safe, req.Curve),
 			Message:         hex.EncodeToString(opsbt.SigHash(idx)),
 			State:           common.RequestStateInitial,
 			CreatedAt:       req.CreatedAt,
@@ -218,7 +222,7 @@ func (node *Node) closeBitcoinAccountWithHolder(ctx context.Context, req *common
 	txHash := msgTx.TxHash().String()
 	signedByHolder := bitcoin.CheckTransactionPartiallySignedBy(hex.EncodeToString(raw), safe.Holder)
 	logger.Printf("bitcoin.CheckTransactionPartiallySignedBy(%x, %s) => %t", raw, safe.Holder, signedByHolder)
-	if !signedByHolder {
+	if !signedByHolder || !bitcoin.CheckTransactionSignedByHolders(hex.EncodeToString(raw)) {
 		return node.failRequest(ctx, req, "")
 	}
 
@@ -292,6 +296,11 @@ func (node *Node) processBitcoinSafeProposeAccount(ctx context.Context, req *com
 	if req.Amount.Cmp(plan.OperationPriceAmount) < 0 {
 		return node.failRequest(ctx, req, "")
 	}
+	err = bitcoin.VerifyTimeLock(arp.Timelock, chain)
+	logger.Printf("bitcoin.VerifyTimeLock(%s, %d) => %v", arp.Timelock, chain, err)
+	if err != nil {
+		return node.refundAndFailRequest(ctx, req, arp.Receivers, int(arp.Threshold))
+	}
 	safe, err := node.store.ReadSafe(ctx, req.Holder)
 	if err != nil {
 		panic(fmt.Errorf("store.ReadSafe(%s) => %v", req.Holder, err))
@@ -305,6 +314,10 @@ func (node *Node) processBitcoinSafeProposeAccount(ctx context.Context, req *com
 		return node.failRequest(ctx, req, "")
 	}
 
+	if arp.Policy != nil && !arp.Policy.Contains(req.Holder) {
+		return node.refundAndFailRequest(ctx, req, arp.Receivers, int(arp.Threshold))
+	}
+
 	signer, observer, err := node.store.AssignSignerAndObserverToHolder(ctx, req, SafeKeyBackupMaturity, arp.Observer)
 	logger.Printf("store.AssignSignerAndObserverToHolder(%s) => %s %s %v", req.Holder, signer, observer, err)
 	if err != nil {
@@ -319,9 +332,21 @@ func (node *Node) processBitcoinSafeProposeAccount(ctx context.Context, req *com
 	if !common.CheckUnique(req.Holder, signer, observer) {
 		return node.refundAndFailRequest(ctx, req, arp.Receivers, int(arp.Threshold))
 	}
+	if arp.Policy != nil {
+		if node.checkBitcoinTaprootSigner(ctx, signer) {
+			return node.refundAndFailRequest(ctx, req, arp.Receivers, int(arp.Threshold))
+		}
+		keys := []any{signer, observer}
+		for _, h := range arp.Policy.Holders {
+			keys = append(keys, h)
+		}
+		if !common.CheckUnique(keys...) {
+			return node.refundAndFailRequest(ctx, req, arp.Receivers, int(arp.Threshold))
+		}
+	}
 	path := bitcoinDefaultDerivationPath()
 
-	wsa, err := node.buildBitcoinWitnessAccountWithDerivation(ctx, req.Holder, signer, observer, path, arp.Timelock, chain)
+	wsa, err := node.buildBitcoinWitnessAccountWithDerivation(ctx, req.Holder, arp.Policy, signer, observer, path, arp.Timelock, chain)
 	logger.Verbosef("node.buildBitcoinWitnessAccountWithDerivation(%v) => %v %v", req, wsa, err)
 	if err != nil {
 		panic(err)
@@ -478,6 +503,8 @@ func (node *Node) processBitcoinSafeProposeTransaction(ctx context.Context, req
 	case common.SafeChainBitcoin:
 	case common.SafeChainLitecoin:
 		assetId = common.SafeLitecoinChainId
+	case common.SafeChainBitcoinCash:
+		assetId = common.SafeBitcoinCashChainId
 	default:
 		panic(safe.Chain)
 	}
@@ -686,7 +713,7 @@ func (node *Node) processBitcoinSafeApproveTransaction(ctx context.Context, req
 	raw := node.readStorageExtraFromObserver(ctx, ref)
 	signed := bitcoin.CheckTransactionPartiallySignedBy(hex.EncodeToString(raw), tx.Holder)
 	logger.Printf("bitcoin.CheckTransactionPartiallySignedBy(%x, %s) => %t", raw, tx.Holder, signed)
-	if !signed {
+	if !signed || !bitcoin.CheckTransactionSignedByHolders(hex.EncodeToString(raw)) {
 		return node.failRequest(ctx, req, "")
 	}
 	hpsbt, _ := bitcoin.UnmarshalPartiallySignedTransaction(raw)
@@ -724,7 +751,7 @@ func (node *Node) processBitcoinSafeApproveTransaction(ctx context.Context, req
 			TransactionHash: tx.TransactionHash,
 			InputIndex:      idx,
 			Signer:          safe.Signer,
-			Curve:           req.Curve,
+			Curve:           node.bitcoinSignatureCurve(ctx, safe, req.Curve),
 			Message:         hex.EncodeToString(hash),
 			State:           common.RequestStateInitial,
 			CreatedAt:       req.CreatedAt,
@@ -755,9 +782,13 @@ func (node *Node) processBitcoinSafeSignatureResponse(ctx context.Context, req *
 	if err != nil {
 		panic(fmt.Errorf("node.deriveBIP32WithPath(%s, %s) => %v", safe.Signer, safe.Path, err))
 	}
+	verify := bitcoin.VerifySignatureDER
+	if old.Curve == common.CurveSecp256k1SchnorrBitcoin {
+		verify = bitcoin.VerifySignatureSchnorr
+	}
 	sig := req.ExtraBytes()
 	msg := common.DecodeHexOrPanic(old.Message)
-	err = bitcoin.VerifySignatureDER(spk, msg, sig)
+	err = verify(spk, msg, sig)
 	logger.Printf("node.verifyBitcoinSignatureWithPath(%v) => %v", req, err)
 	if err != nil {
 		return node.failRequest(ctx, req, "")
@@ -796,10 +827,14 @@ func (node *Node) processBitcoinSafeSignatureResponse(ctx context.Context, req *
 			panic(sr.Message)
 		}
 		sig := common.DecodeHexOrPanic(sr.Signature.String)
-		err = bitcoin.VerifySignatureDER(spk, msg, sig)
+		err = verify(spk, msg, sig)
 		if err != nil {
 			panic(sr.Signature.String)
 		}
+		if sr.Curve == common.CurveSecp256k1SchnorrBitcoin {
+			spsbt.WriteTaprootSignature(idx, spk, sig)
+			continue
+		}
 		spsbt.Inputs[idx].PartialSigs = []*psbt.PartialSig{{
 			PubKey:    common.DecodeHexOrPanic(spk),
 			Signature: sig,
@@ -822,7 +857,7 @@ func (node *Node) processBitcoinSafeSignatureResponse(ctx context.Context, req *
 	txs = append(txs, t)
 
 	raw := hex.EncodeToString(spsbt.Marshal())
-	err = node.store.FinishTransactionSignaturesWithRequest(ctx, old.TransactionHash, raw, req, int64(len(msgTx.TxIn)), safe, nil, txs)
+	err = node.store.FinishTransactionSignaturesWithRequest(ctx, old.TransactionHash, raw, req, int64(len(msgTx.TxIn)), safe, nil, nil, txs)
 	logger.Printf("store.FinishTransactionSignaturesWithRequest(%s, %s, %v) => %v", old.TransactionHash, raw, req, err)
 	if err != nil {
 		panic(err)
@@ -830,7 +865,7 @@ func (node *Node) processBitcoinSafeSignatureResponse(ctx context.Context, req *
 	return txs, ""
 }
 
-func (node *Node) buildBitcoinWitnessAccountWithDerivation(ctx context.Context, holder, signer, observer string, path []byte, timelock time.Duration, chain byte) (*bitcoin.WitnessScriptAccount, error) {
+func (node *Node) buildBitcoinWitnessAccountWithDerivation(ctx context.Context, holder string, policy *bitcoin.HolderPolicy, signer, observer string, path []byte, timelock time.Duration, chain byte) (*bitcoin.WitnessScriptAccount, error) {
 	sdk, err := node.deriveBIP32WithPath(ctx, signer, path)
 	logger.Verbosef("bitcoin.DeriveBIP32(%s) => %s %v", signer, sdk, err)
 	if err != nil {
@@ -841,9 +876,44 @@ func (node *Node) buildBitcoinWitnessAccountWithDerivation(ctx context.Context,
 	if err != nil {
 		return nil, fmt.Errorf("bitcoin.DeriveBIP32(%s) => %v", observer, err)
 	}
+	if node.checkBitcoinTaprootSigner(ctx, signer) {
+		return bitcoin.BuildTaprootScriptAccount(holder, sdk, odk, timelock, chain)
+	}
+	if policy != nil {
+		return bitcoin.BuildPolicyScriptAccount(policy, sdk, odk, timelock, chain)
+	}
 	return bitcoin.BuildWitnessScriptAccount(holder, sdk, odk, timelock, chain)
 }
 
+func (node *Node) readBitcoinHolderPolicy(safe *store.Safe) *bitcoin.HolderPolicy {
+	wsa, err := bitcoin.UnmarshalWitnessScriptAccount(safe.Extra)
+	if err != nil {
+		panic(fmt.Errorf("bitcoin.UnmarshalWitnessScriptAccount(%x) => %v", safe.Extra, err))
+	}
+	policy, err := bitcoin.ParseHolderPolicy(wsa.Script)
+	if err != nil {
+		panic(fmt.Errorf("bitcoin.ParseHolderPolicy(%x) => %v", wsa.Script, err))
+	}
+	return policy
+}
+
+// taproot safes are assigned with the schnorr signer keys, and their
+// observer keys are still the ecdsa keys of the bitcoin safes
+func (node *Node) checkBitcoinTaprootSigner(ctx context.Context, signer string) bool {
+	key, err := node.store.ReadKey(ctx, signer)
+	if err != nil || key == nil {
+		panic(fmt.Errorf("store.ReadKey(%s) => %v %v", signer, key, err))
+	}
+	return key.Curve == common.CurveSecp256k1SchnorrBitcoin
+}
+
+func (node *Node) bitcoinSignatureCurve(ctx context.Context, safe *store.Safe, crv byte) byte {
+	if node.checkBitcoinTaprootSigner(ctx, safe.Signer) {
+		return common.CurveSecp256k1SchnorrBitcoin
+	}
+	return crv
+}
+
 func (node *Node) verifyBitcoinSignatureWithPath(ctx context.Context, public, path string, msg, sig []byte) error {
 	spk, err := node.deriveBIP32WithPath(ctx, public, common.DecodeHexOrPanic(path))
 	if err != nil {
@@ -864,6 +934,9 @@ func (node *Node) deriveBIP32WithPath(ctx context.Context, public string, path8
 	if err != nil {
 		return "", fmt.Errorf("store.ReadKey(%s) => %v", public, err)
 	}
+	if sk.Curve == common.CurveSecp256k1SchnorrBitcoin {
+		return bitcoin.DeriveTaprootBIP32(public, common.DecodeHexOrPanic(sk.Extra), path32...)
+	}
 	_, sdk, err := bitcoin.DeriveBIP32(public, common.DecodeHexOrPanic(sk.Extra), path32...)
 	return sdk, err
 }
@@ -884,5 +957,6 @@ func (node *Node) checkTransactionIndexSignaturePending(ctx context.Context, has
 
 func (node *Node) checkBitcoinUTXOSignatureRequired(ctx context.Context, pop wire.OutPoint) bool {
 	utxo, _, _ := node.store.ReadBitcoinUTXO(ctx, pop.Hash.String(), int(pop.Index))
-	return bitcoin.CheckMultisigHolderSignerScript(utxo.Script)
+	return bitcoin.CheckMultisigHolderSignerScript(utxo.Script) ||
+		bitcoin.CheckTaprootHolderSignerScript(utxo.Script)
 }
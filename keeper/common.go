@@ -8,6 +8,8 @@ import (
 	"github.com/MixinNetwork/mixin/logger"
 	"github.com/MixinNetwork/safe/apps/bitcoin"
 	"github.com/MixinNetwork/safe/apps/ethereum"
+	"github.com/MixinNetwork/safe/apps/mixin"
+	"github.com/MixinNetwork/safe/apps/solana"
 	"github.com/MixinNetwork/safe/common"
 	"github.com/MixinNetwork/safe/common/abi"
 	"github.com/MixinNetwork/safe/keeper/store"
@@ -56,7 +58,7 @@ func (node *Node) refundAndFailRequest(ctx context.Context, req *common.Request,
 
 func (node *Node) bondMaxSupply(ctx context.Context, chain byte, assetId string) decimal.Decimal {
 	switch assetId {
-	case common.SafeBitcoinChainId, common.SafeLitecoinChainId, common.SafeEthereumChainId, common.SafePolygonChainId:
+	case common.SafeBitcoinChainId, common.SafeLitecoinChainId, common.SafeBitcoinCashChainId, common.SafeEthereumChainId, common.SafePolygonChainId, common.SafeMVMChainId, common.SafeMixinKernelAssetId, common.SafeSolanaChainId, common.SafeTronChainId:
 		return decimal.RequireFromString("115792089237316195423570985008687907853269984665640564039457.58400791")
 	default:
 		return decimal.RequireFromString("115792089237316195423570985008687907853269984665640564039457.58400791")
@@ -108,6 +110,9 @@ func (node *Node) verifySafeMessageSignatureWithHolderOrObserver(ctx context.Con
 			}
 		}
 	case common.CurveSecp256k1ECDSAEthereum:
+		if safe.Chain == common.SafeChainTron {
+			return node.verifyTronMessageSignatureWithHolderOrObserver(ctx, safe, ms, sig)
+		}
 		msg := []byte(ms)
 		err := ethereum.VerifyMessageSignature(safe.Holder, msg, sig)
 		logger.Printf("holder: ethereum.VerifyMessageSignature(%s, %x) => %v", ms, sig, err)
@@ -118,6 +123,28 @@ func (node *Node) verifySafeMessageSignatureWithHolderOrObserver(ctx context.Con
 				return err
 			}
 		}
+	case common.CurveEdwards25519Mixin:
+		msg := []byte(ms)
+		err := mixin.VerifyMessageSignature(safe.Holder, msg, sig)
+		logger.Printf("holder: mixin.VerifyMessageSignature(%s, %x) => %v", ms, sig, err)
+		if err != nil {
+			err = mixin.VerifyMessageSignature(safe.Observer, msg, sig)
+			logger.Printf("observer: mixin.VerifyMessageSignature(%s, %x) => %v", ms, sig, err)
+			if err != nil {
+				return err
+			}
+		}
+	case common.CurveEdwards25519Default:
+		msg := []byte(ms)
+		err := solana.VerifyMessageSignature(safe.Holder, msg, sig)
+		logger.Printf("holder: solana.VerifyMessageSignature(%s, %x) => %v", ms, sig, err)
+		if err != nil {
+			err = solana.VerifyMessageSignature(safe.Observer, msg, sig)
+			logger.Printf("observer: solana.VerifyMessageSignature(%s, %x) => %v", ms, sig, err)
+			if err != nil {
+				return err
+			}
+		}
 	default:
 		panic(safe.Chain)
 	}
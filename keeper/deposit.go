@@ -9,13 +9,18 @@ import (
 	"math/big"
 	"slices"
 
+	mc "github.com/MixinNetwork/mixin/common"
 	"github.com/MixinNetwork/mixin/crypto"
 	"github.com/MixinNetwork/mixin/logger"
 	"github.com/MixinNetwork/safe/apps/bitcoin"
 	"github.com/MixinNetwork/safe/apps/ethereum"
+	"github.com/MixinNetwork/safe/apps/mixin"
+	"github.com/MixinNetwork/safe/apps/solana"
+	"github.com/MixinNetwork/safe/apps/tron"
 	"github.com/MixinNetwork/safe/common"
 	"github.com/MixinNetwork/safe/keeper/store"
 	"github.com/MixinNetwork/trusted-group/mtg"
+	"github.com/btcsuite/btcd/btcutil/base58"
 	gc "github.com/ethereum/go-ethereum/common"
 	"github.com/gofrs/uuid/v5"
 	"github.com/shopspring/decimal"
@@ -27,7 +32,9 @@ type Deposit struct {
 	AssetAddress string
 	Hash         string
 	Index        uint64
+	Mask         string
 	Amount       *big.Int
+	TokenType    int
 }
 
 func parseDepositExtra(req *common.Request) (*Deposit, error) {
@@ -43,19 +50,64 @@ func parseDepositExtra(req *common.Request) (*Deposit, error) {
 		panic(req.Id)
 	}
 	extra = extra[17:]
-	switch deposit.Chain {
-	case common.SafeChainBitcoin, common.SafeChainLitecoin:
+	switch common.NormalizeSafeChain(deposit.Chain) {
+	case common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainBitcoinCash:
 		deposit.Hash = hex.EncodeToString(extra[0:32])
 		deposit.Index = binary.BigEndian.Uint64(extra[32:40])
 		deposit.Amount = new(big.Int).SetBytes(extra[40:])
 		if !deposit.Amount.IsInt64() {
 			return nil, fmt.Errorf("invalid deposit amount %s", deposit.Amount.String())
 		}
-	case common.SafeChainEthereum, common.SafeChainPolygon:
+	case common.SafeChainEthereum:
 		deposit.Hash = "0x" + hex.EncodeToString(extra[0:32])
 		deposit.AssetAddress = gc.BytesToAddress(extra[32:52]).Hex()
 		deposit.Index = binary.BigEndian.Uint64(extra[52:60])
 		deposit.Amount = new(big.Int).SetBytes(extra[60:])
+		// the amount bytes never start with zero, so the zero byte marks
+		// a nft deposit with the token type before the amount, and the
+		// token id is read from the verified transfer log
+		if rest := extra[60:]; len(rest) > 0 && rest[0] == 0 {
+			if len(rest) < 3 {
+				return nil, fmt.Errorf("invalid deposit extra %s", req.ExtraHEX)
+			}
+			deposit.TokenType = int(rest[1])
+			deposit.Amount = new(big.Int).SetBytes(rest[2:])
+			switch deposit.TokenType {
+			case ethereum.TypeERC721Tx, ethereum.TypeERC1155Tx:
+			default:
+				return nil, fmt.Errorf("invalid deposit token type %d", deposit.TokenType)
+			}
+			if deposit.Asset != ethereum.GenerateAssetId(deposit.Chain, deposit.AssetAddress) {
+				return nil, fmt.Errorf("invalid deposit nft asset %s", deposit.Asset)
+			}
+		}
+	case common.SafeChainMixinKernel:
+		deposit.Hash = hex.EncodeToString(extra[0:32])
+		deposit.Index = uint64(binary.BigEndian.Uint16(extra[32:34]))
+		deposit.Mask = hex.EncodeToString(extra[34:66])
+		deposit.Amount = new(big.Int).SetBytes(extra[66:])
+		if !deposit.Amount.IsInt64() {
+			return nil, fmt.Errorf("invalid deposit amount %s", deposit.Amount.String())
+		}
+	case common.SafeChainSolana:
+		if len(extra) < 64+8+1 {
+			return nil, fmt.Errorf("invalid deposit extra %s", req.ExtraHEX)
+		}
+		deposit.Hash = base58.Encode(extra[0:64])
+		deposit.AssetAddress = solana.SystemProgramAddress
+		deposit.Index = binary.BigEndian.Uint64(extra[64:72])
+		deposit.Amount = new(big.Int).SetBytes(extra[72:])
+		if !deposit.Amount.IsUint64() {
+			return nil, fmt.Errorf("invalid deposit amount %s", deposit.Amount.String())
+		}
+	case common.SafeChainTron:
+		if len(extra) < 32+20+8+1 {
+			return nil, fmt.Errorf("invalid deposit extra %s", req.ExtraHEX)
+		}
+		deposit.Hash = hex.EncodeToString(extra[0:32])
+		deposit.AssetAddress = tron.EncodeAddress(extra[32:52])
+		deposit.Index = binary.BigEndian.Uint64(extra[52:60])
+		deposit.Amount = new(big.Int).SetBytes(extra[60:])
 	default:
 		return nil, fmt.Errorf("invalid deposit chain %d", deposit.Chain)
 	}
@@ -85,6 +137,9 @@ func (node *Node) CreateHolderDeposit(ctx context.Context, req *common.Request)
 		logger.Printf("Invalid safe state %d", safe.State)
 		return node.failRequest(ctx, req, "")
 	}
+	if deposit.TokenType != 0 {
+		return node.doEthereumNFTHolderDeposit(ctx, req, deposit, safe)
+	}
 
 	entry := node.fetchBondAssetReceiver(ctx, safe.Address, deposit.Asset)
 	safeAssetId := node.getBondAssetId(ctx, entry, deposit.Asset, req.Holder)
@@ -114,11 +169,17 @@ func (node *Node) CreateHolderDeposit(ctx context.Context, req *common.Request)
 		return node.failRequest(ctx, req, "")
 	}
 
-	switch deposit.Chain {
-	case common.SafeChainBitcoin, common.SafeChainLitecoin:
+	switch common.NormalizeSafeChain(deposit.Chain) {
+	case common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainBitcoinCash:
 		return node.doBitcoinHolderDeposit(ctx, req, deposit, safe, bond.AssetId, asset, plan.TransactionMinimum)
-	case common.SafeChainEthereum, common.SafeChainPolygon:
+	case common.SafeChainEthereum:
 		return node.doEthereumHolderDeposit(ctx, req, deposit, safe, bond.AssetId, asset, plan.TransactionMinimum)
+	case common.SafeChainMixinKernel:
+		return node.doMixinHolderDeposit(ctx, req, deposit, safe, bond.AssetId, asset, plan.TransactionMinimum)
+	case common.SafeChainSolana:
+		return node.doSolanaHolderDeposit(ctx, req, deposit, safe, bond.AssetId, asset, plan.TransactionMinimum)
+	case common.SafeChainTron:
+		return node.doTronHolderDeposit(ctx, req, deposit, safe, bond.AssetId, asset, plan.TransactionMinimum)
 	default:
 		return node.failRequest(ctx, req, "")
 	}
@@ -130,7 +191,7 @@ func (node *Node) doBitcoinHolderDeposit(ctx context.Context, req *common.Reques
 		panic(asset.Decimals)
 	}
 	switch asset.AssetId {
-	case common.SafeBitcoinChainId, common.SafeLitecoinChainId:
+	case common.SafeBitcoinChainId, common.SafeLitecoinChainId, common.SafeBitcoinCashChainId:
 	default:
 		panic(asset.AssetId)
 	}
@@ -176,7 +237,11 @@ func (node *Node) doBitcoinHolderDeposit(ctx context.Context, req *common.Reques
 		panic(deposit.Hash)
 	}
 
-	output, err := node.verifyBitcoinTransaction(ctx, req, deposit, safe, bitcoin.InputTypeP2WSHMultisigHolderSigner)
+	typ := bitcoin.InputTypeP2WSHMultisigHolderSigner
+	if node.checkBitcoinTaprootSigner(ctx, safe.Signer) {
+		typ = bitcoin.InputTypeP2TRHolderSigner
+	}
+	output, err := node.verifyBitcoinTransaction(ctx, req, deposit, safe, typ)
 	logger.Printf("node.verifyBitcoinTransaction(%v) => %v %v", req, output, err)
 	if err != nil {
 		panic(fmt.Errorf("node.verifyBitcoinTransaction(%s) => %v", deposit.Hash, err))
@@ -251,6 +316,162 @@ func (node *Node) doEthereumHolderDeposit(ctx context.Context, req *common.Reque
 	return []*mtg.Transaction{t}, ""
 }
 
+// nft deposits have no bond minted, they are only kept in the
+// safe balances and could be moved by the nft transaction proposal
+func (node *Node) doEthereumNFTHolderDeposit(ctx context.Context, req *common.Request, deposit *Deposit, safe *store.Safe) ([]*mtg.Transaction, string) {
+	deposited, err := node.store.ReadDeposit(ctx, deposit.Hash, int64(deposit.Index))
+	logger.Printf("store.ReadDeposit(%s, %d, %s, %s) => %v %v", deposit.Hash, int64(deposit.Index), deposit.Asset, safe.Address, deposited, err)
+	if err != nil {
+		panic(fmt.Errorf("store.ReadDeposit(%s, %d, %s, %s) => %v", deposit.Hash, int64(deposit.Index), deposit.Asset, safe.Address, err))
+	} else if deposited != nil {
+		return node.failRequest(ctx, req, "")
+	}
+	if deposit.TokenType == ethereum.TypeERC721Tx && deposit.Amount.Cmp(big.NewInt(1)) != 0 {
+		return node.failRequest(ctx, req, "")
+	}
+
+	output, err := node.verifyEthereumTransaction(ctx, req, deposit, safe)
+	logger.Printf("node.verifyEthereumTransaction(%v) => %v %v", req, output, err)
+	if err != nil {
+		panic(fmt.Errorf("node.verifyEthereumTransaction(%s) => %v", deposit.Hash, err))
+	}
+	if output == nil {
+		return node.failRequest(ctx, req, "")
+	}
+
+	nb, err := node.store.ReadNFTBalance(ctx, safe.Address, deposit.AssetAddress, output.TokenId.String(), deposit.TokenType)
+	logger.Printf("store.ReadNFTBalance(%s, %s, %s) => %v %v", safe.Address, deposit.AssetAddress, output.TokenId, nb, err)
+	if err != nil {
+		panic(err)
+	}
+	nb.UpdateBalance(deposit.Amount)
+
+	err = node.store.CreateNFTBalanceDepositFromRequest(ctx, safe, nb, deposit.Asset, deposit.Hash, int64(deposit.Index), deposit.Amount, output.Sender, req)
+	logger.Printf("store.CreateNFTBalanceDepositFromRequest(%v) => %v", req, err)
+	if err != nil {
+		panic(err)
+	}
+	return nil, ""
+}
+
+func (node *Node) doMixinHolderDeposit(ctx context.Context, req *common.Request, deposit *Deposit, safe *store.Safe, safeAssetId string, asset *store.Asset, minimum decimal.Decimal) ([]*mtg.Transaction, string) {
+	if asset.Decimals != mixin.ValuePrecision {
+		panic(asset.Decimals)
+	}
+	old, _, _, err := node.store.ReadMixinUTXO(ctx, deposit.Hash, int(deposit.Index))
+	logger.Printf("store.ReadMixinUTXO(%s, %d) => %v %v", deposit.Hash, deposit.Index, old, err)
+	if err != nil {
+		panic(fmt.Errorf("store.ReadMixinUTXO(%s, %d) => %v", deposit.Hash, deposit.Index, err))
+	} else if old != nil {
+		return node.failRequest(ctx, req, "")
+	}
+	deposited, err := node.store.ReadDeposit(ctx, deposit.Hash, int64(deposit.Index))
+	logger.Printf("store.ReadDeposit(%s, %d, %s, %s) => %v %v", deposit.Hash, int64(deposit.Index), asset.AssetId, safe.Address, deposited, err)
+	if err != nil {
+		panic(fmt.Errorf("store.ReadDeposit(%s, %d, %s, %s) => %v", deposit.Hash, int64(deposit.Index), asset.AssetId, safe.Address, err))
+	} else if deposited != nil {
+		return node.failRequest(ctx, req, "")
+	}
+
+	ver, err := node.group.ReadKernelTransactionUntilSufficient(ctx, deposit.Hash)
+	logger.Printf("group.ReadKernelTransactionUntilSufficient(%s) => %v %v", deposit.Hash, ver, err)
+	if err != nil {
+		panic(fmt.Errorf("group.ReadKernelTransactionUntilSufficient(%s) => %v", deposit.Hash, err))
+	}
+	amount := decimal.NewFromBigInt(deposit.Amount, -int32(asset.Decimals))
+	output, err := node.verifyMixinTransaction(ctx, deposit, safe, ver, amount)
+	logger.Printf("node.verifyMixinTransaction(%v) => %v %v", req, output, err)
+	if err != nil {
+		panic(fmt.Errorf("node.verifyMixinTransaction(%s) => %v", deposit.Hash, err))
+	}
+	if output == nil {
+		return node.failRequest(ctx, req, "")
+	}
+
+	change, sender, err := node.checkMixinChange(ctx, deposit, ver)
+	logger.Printf("node.checkMixinChange(%v) => %t %s %v", deposit, change, sender, err)
+	if err != nil {
+		panic(fmt.Errorf("node.checkMixinChange(%v) => %v", deposit, err))
+	}
+	if amount.Cmp(minimum) < 0 && !change {
+		return node.failRequest(ctx, req, "")
+	}
+
+	var txs []*mtg.Transaction
+	if !change {
+		tx := node.buildTransaction(ctx, req.Output, safe.RequestId, safeAssetId, safe.Receivers, int(safe.Threshold), amount.String(), nil, req.Id)
+		if tx == nil {
+			// no compaction needed, just retry from observer
+			return node.failRequest(ctx, req, "")
+		}
+		txs = append(txs, tx)
+	}
+
+	err = node.store.WriteMixinOutputFromRequest(ctx, safe, output, req, asset.AssetId, sender, txs)
+	if err != nil {
+		panic(err)
+	}
+	return txs, ""
+}
+
+// the kernel transaction is already finalized when it could be read, and
+// the output must be the 2 of 3 output owned by the safe members
+func (node *Node) verifyMixinTransaction(ctx context.Context, deposit *Deposit, safe *store.Safe, ver *mc.VersionedTransaction, amount decimal.Decimal) (*mixin.Input, error) {
+	if ver.Asset != crypto.Sha256Hash([]byte(deposit.Asset)) {
+		return nil, nil
+	}
+	if deposit.Index >= uint64(len(ver.Outputs)) {
+		return nil, nil
+	}
+	out := ver.Outputs[deposit.Index]
+	if out.Type != mc.OutputTypeScript || len(out.Keys) != 3 {
+		return nil, nil
+	}
+	if out.Script.String() != mc.NewThresholdScript(mixin.SafeThreshold).String() {
+		return nil, nil
+	}
+	if out.Amount.String() != mc.NewIntegerFromString(amount.String()).String() {
+		return nil, fmt.Errorf("malicious mixin deposit %s", deposit.Hash)
+	}
+	keys, err := mixin.GhostPublicKeys(safe.Holder, safe.Signer, safe.Observer, common.DecodeHexOrPanic(deposit.Mask))
+	if err != nil {
+		return nil, nil
+	}
+	for i, key := range keys {
+		if key != out.Keys[i].String() {
+			return nil, fmt.Errorf("malicious mixin deposit %s", deposit.Hash)
+		}
+	}
+	return &mixin.Input{
+		TransactionHash: deposit.Hash,
+		Index:           uint32(deposit.Index),
+		Amount:          out.Amount.String(),
+		Mask:            deposit.Mask,
+	}, nil
+}
+
+func (node *Node) checkMixinChange(ctx context.Context, deposit *Deposit, ver *mc.VersionedTransaction) (bool, string, error) {
+	if len(ver.Inputs) == 0 || ver.Inputs[0].Hash == (crypto.Hash{}) {
+		return false, "", nil
+	}
+	in := ver.Inputs[0]
+	sender := fmt.Sprintf("%s:%d", in.Hash.String(), in.Index)
+	vin, _, spentBy, err := node.store.ReadMixinUTXO(ctx, in.Hash.String(), int(in.Index))
+	if err != nil || vin == nil {
+		return false, sender, err
+	}
+	tx, err := node.store.ReadTransaction(ctx, spentBy)
+	if err != nil || tx == nil {
+		return false, sender, fmt.Errorf("store.ReadTransaction(%s) => %v %v", spentBy, tx, err)
+	}
+	var recipients []map[string]string
+	err = json.Unmarshal([]byte(tx.Data), &recipients)
+	if err != nil || len(recipients) == 0 {
+		return false, sender, fmt.Errorf("store.ReadTransaction(%s) => %s", spentBy, tx.Data)
+	}
+	return deposit.Index >= uint64(len(recipients)), sender, nil
+}
+
 func (node *Node) checkBitcoinChange(ctx context.Context, deposit *Deposit, btx *bitcoin.RPCTransaction) (bool, error) {
 	vin, spentBy, err := node.store.ReadBitcoinUTXO(ctx, btx.Vin[0].TxId, int(btx.Vin[0].VOUT))
 	if err != nil || vin == nil {
@@ -282,9 +503,9 @@ func (node *Node) verifyBitcoinTransaction(ctx context.Context, req *common.Requ
 
 	var receiver string
 	switch typ {
-	case bitcoin.InputTypeP2WSHMultisigHolderSigner:
+	case bitcoin.InputTypeP2WSHMultisigHolderSigner, bitcoin.InputTypeP2TRHolderSigner:
 		path := common.DecodeHexOrPanic(safe.Path)
-		wsa, err := node.buildBitcoinWitnessAccountWithDerivation(ctx, safe.Holder, safe.Signer, safe.Observer, path, safe.Timelock, safe.Chain)
+		wsa, err := node.buildBitcoinWitnessAccountWithDerivation(ctx, safe.Holder, node.readBitcoinHolderPolicy(safe), safe.Signer, safe.Observer, path, safe.Timelock, safe.Chain)
 		if err != nil {
 			panic(err)
 		}
@@ -356,6 +577,9 @@ func (node *Node) verifyEthereumTransaction(ctx context.Context, req *common.Req
 	if t.Receiver != safe.Address {
 		return nil, fmt.Errorf("malicious ethereum deposit %s", deposit.Hash)
 	}
+	if t.TokenType != deposit.TokenType || (t.TokenId == nil) != (t.TokenType == 0) {
+		return nil, fmt.Errorf("malicious ethereum nft deposit %s", deposit.Hash)
+	}
 
 	confirmations := info.Height - etx.BlockHeight + 1
 	if info.Height < etx.BlockHeight {
@@ -389,3 +613,166 @@ func (node *Node) checkTrustedSender(ctx context.Context, address string) (bool,
 	}
 	return safe != nil, nil
 }
+
+func (node *Node) doSolanaHolderDeposit(ctx context.Context, req *common.Request, deposit *Deposit, safe *store.Safe, safeAssetId string, asset *store.Asset, minimum decimal.Decimal) ([]*mtg.Transaction, string) {
+	if asset.AssetId != common.SafeSolanaChainId || asset.Decimals != solana.ValuePrecision {
+		panic(asset.AssetId)
+	}
+	amount := decimal.NewFromBigInt(deposit.Amount, -solana.ValuePrecision)
+	if amount.Cmp(minimum) < 0 {
+		return node.failRequest(ctx, req, "")
+	}
+	deposited, err := node.store.ReadDeposit(ctx, deposit.Hash, int64(deposit.Index))
+	logger.Printf("store.ReadDeposit(%s, %d, %s, %s) => %v %v", deposit.Hash, int64(deposit.Index), asset.AssetId, safe.Address, deposited, err)
+	if err != nil {
+		panic(fmt.Errorf("store.ReadDeposit(%s, %d, %s, %s) => %v", deposit.Hash, int64(deposit.Index), asset.AssetId, safe.Address, err))
+	} else if deposited != nil {
+		return node.failRequest(ctx, req, "")
+	}
+
+	safeBalance, err := node.store.ReadEthereumBalance(ctx, safe.Address, asset.AssetId, safeAssetId)
+	logger.Printf("store.ReadEthereumBalance(%s, %s) => %v %v", safe.Address, asset.AssetId, safeBalance, err)
+	if err != nil {
+		panic(err)
+	}
+	safeBalance.UpdateBalance(deposit.Amount)
+	if safeBalance.AssetAddress == "" {
+		safeBalance.AssetAddress = deposit.AssetAddress
+	}
+
+	transfer, err := node.verifySolanaTransaction(ctx, deposit, safe)
+	logger.Printf("node.verifySolanaTransaction(%v) => %v %v", req, transfer, err)
+	if err != nil {
+		panic(fmt.Errorf("node.verifySolanaTransaction(%s) => %v", deposit.Hash, err))
+	}
+	if transfer == nil {
+		return node.failRequest(ctx, req, "")
+	}
+
+	t := node.buildTransaction(ctx, req.Output, safe.RequestId, safeAssetId, safe.Receivers, int(safe.Threshold), amount.String(), nil, req.Id)
+	if t == nil {
+		// no compaction needed, just retry from observer
+		return node.failRequest(ctx, req, "")
+	}
+	err = node.store.CreateEthereumBalanceDepositFromRequest(ctx, safe, safeBalance, deposit.Hash, int64(deposit.Index), deposit.Amount, transfer.Sender, req, []*mtg.Transaction{t})
+	logger.Printf("store.CreateEthereumBalanceDepositFromRequest(%v) => %v", req, err)
+	if err != nil {
+		panic(err)
+	}
+	return []*mtg.Transaction{t}, ""
+}
+
+func (node *Node) verifySolanaTransaction(ctx context.Context, deposit *Deposit, safe *store.Safe) (*solana.Transfer, error) {
+	tx, err := solana.RPCGetTransaction(node.conf.SolanaRPC, deposit.Hash)
+	logger.Printf("solana.RPCGetTransaction(%s) => %v %v", deposit.Hash, tx, err)
+	if err != nil || tx == nil {
+		return nil, fmt.Errorf("malicious solana deposit or node not in sync? %s %v", deposit.Hash, err)
+	}
+	for _, t := range tx.ExtractTransfers() {
+		if t.Index != int64(deposit.Index) {
+			continue
+		}
+		if t.Receiver != safe.Address || t.Lamports != deposit.Amount.Uint64() {
+			return nil, nil
+		}
+		return t, nil
+	}
+	return nil, nil
+}
+
+func (node *Node) doTronHolderDeposit(ctx context.Context, req *common.Request, deposit *Deposit, safe *store.Safe, safeAssetId string, asset *store.Asset, minimum decimal.Decimal) ([]*mtg.Transaction, string) {
+	if asset.AssetId == common.SafeTronChainId && asset.Decimals != tron.ValuePrecision {
+		panic(asset.Decimals)
+	}
+	if tron.GenerateAssetId(deposit.AssetAddress) != asset.AssetId {
+		return node.failRequest(ctx, req, "")
+	}
+	amount := decimal.NewFromBigInt(deposit.Amount, -int32(asset.Decimals))
+	if amount.Cmp(minimum) < 0 {
+		return node.failRequest(ctx, req, "")
+	}
+	deposited, err := node.store.ReadDeposit(ctx, deposit.Hash, int64(deposit.Index))
+	logger.Printf("store.ReadDeposit(%s, %d, %s, %s) => %v %v", deposit.Hash, int64(deposit.Index), asset.AssetId, safe.Address, deposited, err)
+	if err != nil {
+		panic(fmt.Errorf("store.ReadDeposit(%s, %d, %s, %s) => %v", deposit.Hash, int64(deposit.Index), asset.AssetId, safe.Address, err))
+	} else if deposited != nil {
+		return node.failRequest(ctx, req, "")
+	}
+
+	safeBalance, err := node.store.ReadEthereumBalance(ctx, safe.Address, asset.AssetId, safeAssetId)
+	logger.Printf("store.ReadEthereumBalance(%s, %s) => %v %v", safe.Address, asset.AssetId, safeBalance, err)
+	if err != nil {
+		panic(err)
+	}
+	safeBalance.UpdateBalance(deposit.Amount)
+	if safeBalance.AssetAddress == "" {
+		safeBalance.AssetAddress = deposit.AssetAddress
+	}
+
+	transfer, err := node.verifyTronTransaction(ctx, req, deposit, safe)
+	logger.Printf("node.verifyTronTransaction(%v) => %v %v", req, transfer, err)
+	if err != nil {
+		panic(fmt.Errorf("node.verifyTronTransaction(%s) => %v", deposit.Hash, err))
+	}
+	if transfer == nil {
+		return node.failRequest(ctx, req, "")
+	}
+
+	t := node.buildTransaction(ctx, req.Output, safe.RequestId, safeAssetId, safe.Receivers, int(safe.Threshold), amount.String(), nil, req.Id)
+	if t == nil {
+		// no compaction needed, just retry from observer
+		return node.failRequest(ctx, req, "")
+	}
+	err = node.store.CreateEthereumBalanceDepositFromRequest(ctx, safe, safeBalance, deposit.Hash, int64(deposit.Index), deposit.Amount, transfer.Sender, req, []*mtg.Transaction{t})
+	logger.Printf("store.CreateEthereumBalanceDepositFromRequest(%v) => %v", req, err)
+	if err != nil {
+		panic(err)
+	}
+	return []*mtg.Transaction{t}, ""
+}
+
+func (node *Node) verifyTronTransaction(ctx context.Context, req *common.Request, deposit *Deposit, safe *store.Safe) (*tron.Transfer, error) {
+	info, err := node.store.ReadLatestNetworkInfo(ctx, safe.Chain, req.CreatedAt)
+	logger.Printf("store.ReadLatestNetworkInfo(%d) => %v %v", safe.Chain, info, err)
+	if err != nil || info == nil {
+		return nil, err
+	}
+	if info.CreatedAt.After(req.CreatedAt) {
+		return nil, fmt.Errorf("malicious tron network info %v", info)
+	}
+
+	tx, ti, err := tron.RPCGetTransaction(node.conf.TronRPC, deposit.Hash)
+	logger.Printf("tron.RPCGetTransaction(%s) => %v %v %v", deposit.Hash, tx, ti, err)
+	if err != nil || tx == nil || ti == nil {
+		return nil, fmt.Errorf("malicious tron deposit or node not in sync? %s %v", deposit.Hash, err)
+	}
+	var transfer *tron.Transfer
+	for _, t := range tx.ExtractTransfers() {
+		if t.Index != int64(deposit.Index) {
+			continue
+		}
+		if t.Receiver != safe.Address || t.TokenAddress != deposit.AssetAddress || t.Value.Cmp(deposit.Amount) != 0 {
+			return nil, nil
+		}
+		transfer = t
+	}
+	if transfer == nil {
+		return nil, nil
+	}
+
+	confirmations := int64(info.Height) - ti.BlockNumber + 1
+	if int64(info.Height) < ti.BlockNumber {
+		confirmations = 0
+	}
+	isSafe, err := node.checkTrustedSender(ctx, transfer.Sender)
+	if err != nil {
+		return nil, fmt.Errorf("node.checkTrustedSender(%s) => %v", transfer.Sender, err)
+	}
+	if isSafe && confirmations > 0 {
+		confirmations = 1000000
+	}
+	if confirmations < tron.TransactionConfirmations {
+		return nil, fmt.Errorf("tron.CheckFinalization(%s)", deposit.Hash)
+	}
+	return transfer, nil
+}
@@ -0,0 +1,62 @@
+package keeper
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/MixinNetwork/safe/apps/bitcoin"
+	"github.com/MixinNetwork/safe/common"
+	"github.com/MixinNetwork/safe/keeper/store"
+)
+
+// the holder keys are unknown to the safe network, so the holder xpubs with
+// their origins are required to build the wallet policy for hardware wallets
+func BuildBitcoinSafeDescriptor(ctx context.Context, s *store.SQLite3Store, safe *store.Safe, xpubs []string) (*bitcoin.SafeDescriptor, error) {
+	switch safe.Chain {
+	case common.SafeChainBitcoin, common.SafeChainLitecoin:
+	default:
+		return nil, fmt.Errorf("invalid safe chain %d", safe.Chain)
+	}
+	wsa, err := bitcoin.UnmarshalWitnessScriptAccount(safe.Extra)
+	if err != nil {
+		return nil, fmt.Errorf("bitcoin.UnmarshalWitnessScriptAccount(%x) => %v", safe.Extra, err)
+	}
+	if !bitcoin.CheckMultisigHolderSignerScript(wsa.Script) {
+		return nil, fmt.Errorf("invalid safe script %x", wsa.Script)
+	}
+
+	keys, path, err := ReadBitcoinSafeDescriptorKeys(ctx, s, safe, xpubs)
+	if err != nil {
+		return nil, err
+	}
+	return bitcoin.BuildSafeDescriptor(wsa.Script, keys, path)
+}
+
+func ReadBitcoinSafeDescriptorKeys(ctx context.Context, s *store.SQLite3Store, safe *store.Safe, xpubs []string) ([]*bitcoin.DescriptorKey, []uint32, error) {
+	var keys []*bitcoin.DescriptorKey
+	for _, public := range []string{safe.Signer, safe.Observer} {
+		key, err := s.ReadKey(ctx, public)
+		if err != nil || key == nil {
+			return nil, nil, fmt.Errorf("store.ReadKey(%s) => %v %v", public, key, err)
+		}
+		dk, err := bitcoin.NewMasterDescriptorKey(key.Public, common.DecodeHexOrPanic(key.Extra))
+		if err != nil {
+			return nil, nil, fmt.Errorf("bitcoin.NewMasterDescriptorKey(%s) => %v", key.Public, err)
+		}
+		keys = append(keys, dk)
+	}
+	for _, xpub := range xpubs {
+		dk, err := bitcoin.ParseDescriptorKey(xpub)
+		if err != nil {
+			return nil, nil, err
+		}
+		keys = append(keys, dk)
+	}
+
+	path8 := common.DecodeHexOrPanic(safe.Path)
+	path32 := make([]uint32, path8[0])
+	for i := range path32 {
+		path32[i] = uint32(path8[1+i])
+	}
+	return keys, path32, nil
+}
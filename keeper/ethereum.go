@@ -16,6 +16,7 @@ import (
 	"github.com/MixinNetwork/safe/common/abi"
 	"github.com/MixinNetwork/safe/keeper/store"
 	"github.com/MixinNetwork/trusted-group/mtg"
+	gc "github.com/ethereum/go-ethereum/common"
 	"github.com/gofrs/uuid/v5"
 	"github.com/shopspring/decimal"
 )
@@ -90,8 +91,17 @@ func (node *Node) processEthereumSafeCloseAccount(ctx context.Context, req *comm
 	if err != nil {
 		panic(err)
 	}
-	outputs := t.ExtractOutputs()
-	if len(outputs) != len(sbm) {
+	nbm, err := node.store.ReadAllNFTBalancesMap(ctx, safe.Address)
+	logger.Printf("store.ReadAllNFTBalancesMap(%s) => %v %v", safe.Address, nbm, err)
+	if err != nil {
+		panic(err)
+	}
+	outputs, err := t.ExtractOutputs()
+	logger.Printf("ethereum.ExtractOutputs(%v) => %v %v", t, outputs, err)
+	if err != nil {
+		return node.failRequest(ctx, req, "")
+	}
+	if len(outputs) != len(sbm)+len(nbm) {
 		return node.failRequest(ctx, req, "")
 	}
 
@@ -102,6 +112,14 @@ func (node *Node) processEthereumSafeCloseAccount(ctx context.Context, req *comm
 			return node.failRequest(ctx, req, "")
 		}
 
+		if o.TokenId != nil {
+			nb := nbm[store.NFTBalanceKey(o.TokenAddress, o.TokenId.String())]
+			if nb == nil || nb.BigBalance().Cmp(o.Amount) != 0 {
+				logger.Printf("inconsistent amount between %s nft balance and output: %v", o.TokenAddress, o)
+				return node.failRequest(ctx, req, "")
+			}
+			continue
+		}
 		sbb := sbm[o.TokenAddress].BigBalance()
 		if sbb.Cmp(o.Amount) != 0 {
 			logger.Printf("inconsistent amount between %s balance and output: %d, %d", o.TokenAddress, sbb, o.Amount)
@@ -195,7 +213,11 @@ func (node *Node) closeEthereumAccountWithHolder(ctx context.Context, req *commo
 		return node.failRequest(ctx, req, "")
 	}
 
-	outputs := t.ExtractOutputs()
+	outputs, err := t.ExtractOutputs()
+	logger.Printf("ethereum.ExtractOutputs(%v) => %v %v", t, outputs, err)
+	if err != nil {
+		return node.failRequest(ctx, req, "")
+	}
 	recipients := make([]map[string]string, len(outputs))
 	for i, out := range outputs {
 		norm := ethereum.NormalizeAddress(out.Destination)
@@ -203,6 +225,13 @@ func (node *Node) closeEthereumAccountWithHolder(ctx context.Context, req *commo
 			logger.Printf("invalid output destination: %s, %s", norm, safe.Address)
 			return node.failRequest(ctx, req, "")
 		}
+		if out.TokenId != nil {
+			recipients[i] = map[string]string{
+				"receiver": out.Destination, "amount": out.Amount.String(),
+				"token": out.TokenAddress, "token_id": out.TokenId.String(),
+			}
+			continue
+		}
 		decimals := int32(ethereum.ValuePrecision)
 		if out.TokenAddress != ethereum.EthereumEmptyAddress {
 			assetId := ethereum.GenerateAssetId(safe.Chain, out.TokenAddress)
@@ -595,9 +624,53 @@ func (node *Node) processEthereumSafeProposeTransaction(ctx context.Context, req
 		decimals = int32(asset.Decimals)
 	}
 
+	var callData []byte
 	var outputs []*ethereum.Output
 	ver, _ := node.group.ReadKernelTransactionUntilSufficient(ctx, req.MixinHash.String())
-	if len(extra[16:]) == 32 && len(ver.References) == 1 && ver.References[0].String() == hex.EncodeToString(extra[16:]) {
+	if flag == common.FlagProposeContractCall {
+		// the calldata is too large for the extra, so it is kept in the referenced
+		// storage with the target address, and the paid chain asset is the call value
+		if len(extra[16:]) != 32 || len(ver.References) != 1 || ver.References[0].String() != hex.EncodeToString(extra[16:]) {
+			return node.failRequest(ctx, req, "")
+		}
+		if balance.AssetAddress != ethereum.EthereumEmptyAddress {
+			return node.failRequest(ctx, req, "")
+		}
+		stx, _ := node.group.ReadKernelTransactionUntilSufficient(ctx, ver.References[0].String())
+		if len(stx.Extra) < 20+4 {
+			return node.failRequest(ctx, req, "")
+		}
+		callData = stx.Extra[20:]
+		outputs = []*ethereum.Output{{
+			Destination:  gc.BytesToAddress(stx.Extra[:20]).Hex(),
+			Amount:       ethereum.ParseAmount(req.Amount.String(), decimals),
+			TokenAddress: balance.AssetAddress,
+		}}
+	} else if flag == common.FlagProposeNFTTransaction {
+		// the paid chain asset only pays for the request, and is refunded to
+		// the safe receivers because the nft transaction moves no chain asset
+		if len(extra[16:]) != 20+32+42 || balance.AssetAddress != ethereum.EthereumEmptyAddress {
+			return node.failRequest(ctx, req, "")
+		}
+		nbm, err := node.store.ReadAllNFTBalancesMap(ctx, safe.Address)
+		logger.Printf("store.ReadAllNFTBalancesMap(%s) => %v %v", safe.Address, nbm, err)
+		if err != nil {
+			panic(err)
+		}
+		collection := gc.BytesToAddress(extra[16:36]).Hex()
+		tokenId := new(big.Int).SetBytes(extra[36:68])
+		nb := nbm[store.NFTBalanceKey(collection, tokenId.String())]
+		if nb == nil {
+			return node.failRequest(ctx, req, "")
+		}
+		outputs = []*ethereum.Output{{
+			Destination:  string(extra[68:]),
+			Amount:       nb.BigBalance(),
+			TokenAddress: nb.AssetAddress,
+			TokenId:      nb.BigTokenId(),
+			TokenType:    nb.TokenType,
+		}}
+	} else if len(extra[16:]) == 32 && len(ver.References) == 1 && ver.References[0].String() == hex.EncodeToString(extra[16:]) {
 		stx, _ := node.group.ReadKernelTransactionUntilSufficient(ctx, ver.References[0].String())
 		extra := stx.Extra
 		var recipients [][2]string // TODO better encoding
@@ -636,6 +709,13 @@ func (node *Node) processEthereumSafeProposeTransaction(ctx context.Context, req
 			logger.Printf("invalid output destination: %s, %s", norm, safe.Address)
 			return node.failRequest(ctx, req, "")
 		}
+		if out.TokenId != nil {
+			recipients[i] = map[string]string{
+				"receiver": out.Destination, "amount": out.Amount.String(),
+				"token": out.TokenAddress, "token_id": out.TokenId.String(),
+			}
+			continue
+		}
 		amt := decimal.NewFromBigInt(out.Amount, -decimals)
 		r := map[string]string{
 			"receiver": out.Destination, "amount": amt.String(),
@@ -646,9 +726,15 @@ func (node *Node) processEthereumSafeProposeTransaction(ctx context.Context, req
 		recipients[i] = r
 		total = total.Add(amt)
 	}
+	if flag == common.FlagProposeNFTTransaction {
+		total = req.Amount
+	}
 	if len(outputs) > 256 || !total.Equal(req.Amount) {
 		return node.failRequest(ctx, req, "")
 	}
+	if callData != nil {
+		recipients[0]["data"] = hex.EncodeToString(callData)
+	}
 
 	var t *ethereum.SafeTransaction
 	chainId := ethereum.GetEvmChainID(int64(safe.Chain))
@@ -667,6 +753,22 @@ func (node *Node) processEthereumSafeProposeTransaction(ctx context.Context, req
 		if err != nil {
 			panic(err)
 		}
+	case common.FlagProposeContractCall:
+		o := outputs[0]
+		t, err = ethereum.CreateContractCallTransaction(ctx, chainId, req.Id, safe.Address, o.Destination, o.Amount, callData, big.NewInt(safe.Nonce))
+		logger.Printf("ethereum.CreateContractCallTransaction(%d, %s, %s, %v, %x, %d) => %v %v",
+			chainId, req.Id, safe.Address, o, callData, safe.Nonce, t, err)
+		if err != nil {
+			return node.failRequest(ctx, req, "")
+		}
+	case common.FlagProposeNFTTransaction:
+		txType = outputs[0].TokenType
+		t, err = ethereum.CreateTransactionFromOutputs(ctx, txType, chainId, req.Id, safe.Address, outputs, big.NewInt(safe.Nonce))
+		logger.Printf("ethereum.CreateTransactionFromOutputs(%d, %d, %s, %s, %v, %d) => %v %v",
+			txType, chainId, req.Id, safe.Address, outputs, safe.Nonce, t, err)
+		if err != nil {
+			panic(err)
+		}
 	case common.FlagProposeRecoveryTransaction:
 		if len(outputs) != 1 {
 			logger.Printf("invalid recovery transaction outputs: %d", len(outputs))
@@ -702,6 +804,25 @@ func (node *Node) processEthereumSafeProposeTransaction(ctx context.Context, req
 			}
 			recipients = append(recipients, r)
 		}
+		nbs, err := node.store.ReadAllNFTBalances(ctx, safe.Address)
+		logger.Printf("store.ReadAllNFTBalances(%s) => %v %v", safe.Address, nbs, err)
+		if err != nil {
+			panic(err)
+		}
+		for _, nb := range nbs {
+			output := &ethereum.Output{
+				Destination:  string(extra[16:]),
+				Amount:       nb.BigBalance(),
+				TokenAddress: nb.AssetAddress,
+				TokenId:      nb.BigTokenId(),
+				TokenType:    nb.TokenType,
+			}
+			outputs = append(outputs, output)
+			recipients = append(recipients, map[string]string{
+				"receiver": output.Destination, "amount": output.Amount.String(),
+				"token": output.TokenAddress, "token_id": nb.TokenId,
+			})
+		}
 		txType = ethereum.TypeMultiSendTx
 		if len(outputs) == 1 {
 			txType = ethereum.TypeETHTx
@@ -720,6 +841,17 @@ func (node *Node) processEthereumSafeProposeTransaction(ctx context.Context, req
 		return node.failRequest(ctx, req, "")
 	}
 
+	// the safe refunds the relay bundler in the moved asset, when the observer
+	// has set its relay gas price, except the recovery which drains the safe
+	rgp, err := node.store.ReadLatestRelayGasPrice(ctx, safe.Chain, balance.AssetAddress, req.CreatedAt)
+	logger.Printf("store.ReadLatestRelayGasPrice(%d, %s) => %v %v", safe.Chain, balance.AssetAddress, rgp, err)
+	if err != nil {
+		panic(err)
+	}
+	if rgp != nil && flag != common.FlagProposeRecoveryTransaction {
+		t.SetGasRefund(req.Id, balance.AssetAddress, rgp.GasPrice, len(outputs))
+	}
+
 	extra = t.Marshal()
 	stx := node.buildStorageTransaction(ctx, req, []byte(common.Base91Encode(extra)))
 	if stx == nil {
@@ -735,6 +867,14 @@ func (node *Node) processEthereumSafeProposeTransaction(ctx context.Context, req
 	}
 	txs = append(txs, tt)
 
+	if flag == common.FlagProposeNFTTransaction {
+		rt := node.buildTransaction(ctx, req.Output, node.conf.AppId, req.AssetId, safe.Receivers, int(safe.Threshold), req.Amount.String(), []byte("refund"), common.UniqueId(req.Id, "refund"))
+		if rt == nil {
+			return node.failRequest(ctx, req, req.AssetId)
+		}
+		txs = append(txs, rt)
+	}
+
 	data := common.MarshalJSONOrPanic(recipients)
 	tx := &store.Transaction{
 		TransactionHash: t.TxHash,
@@ -873,10 +1013,25 @@ func (node *Node) processEthereumSafeRefundTransaction(ctx context.Context, req
 	if err != nil {
 		panic(err)
 	}
-	outputs := st.ExtractOutputs()
+	outputs, err := st.ExtractOutputs()
+	if err != nil {
+		panic(err)
+	}
+	nbm := node.readEthereumNFTBalances(ctx, safe, outputs)
 	for _, o := range outputs {
+		if o.TokenId != nil {
+			nb := nbm[store.NFTBalanceKey(o.TokenAddress, o.TokenId.String())]
+			if nb == nil {
+				panic(o.TokenId)
+			}
+			nb.UpdateBalance(o.Amount)
+			continue
+		}
 		sbm[o.TokenAddress].UpdateBalance(o.Amount)
 	}
+	if fee := st.RefundLimit(); fee.Sign() > 0 {
+		sbm[st.GasToken.Hex()].UpdateBalance(fee)
+	}
 
 	txRequest, err := node.store.ReadRequest(ctx, tx.RequestId)
 	logger.Printf("store.ReadRequest(%s) => %v %v", tx.RequestId, txRequest, err)
@@ -901,7 +1056,7 @@ func (node *Node) processEthereumSafeRefundTransaction(ctx context.Context, req
 		return node.failRequest(ctx, req, txRequest.AssetId)
 	}
 
-	err = node.store.FailTransactionWithRequest(ctx, tx, safe, req, sbm, []*mtg.Transaction{tt})
+	err = node.store.FailTransactionWithRequest(ctx, tx, safe, req, sbm, nbm, []*mtg.Transaction{tt})
 	logger.Printf("store.FailTransactionWithRequest(%v %v %v) => %v", tx, safe, req, err)
 	if err != nil {
 		panic(err)
@@ -1009,8 +1164,22 @@ func (node *Node) processEthereumSafeSignatureResponse(ctx context.Context, req
 	if err != nil {
 		panic(err)
 	}
-	outputs := t.ExtractOutputs()
+	outputs, err := t.ExtractOutputs()
+	logger.Printf("ethereum.ExtractOutputs(%v) => %v %v", t, outputs, err)
+	if err != nil {
+		return node.failRequest(ctx, req, "")
+	}
+	nbm := node.readEthereumNFTBalances(ctx, safe, outputs)
 	for _, o := range outputs {
+		if o.TokenId != nil {
+			nb := nbm[store.NFTBalanceKey(o.TokenAddress, o.TokenId.String())]
+			if nb == nil || nb.BigBalance().Cmp(o.Amount) < 0 {
+				logger.Printf("safe %s nft %s balance lower than %d", safe.Address, o.TokenId, o.Amount)
+				return node.failRequest(ctx, req, "")
+			}
+			nb.UpdateBalance(new(big.Int).Neg(o.Amount))
+			continue
+		}
 		closeBalance := big.NewInt(0).Sub(sbm[o.TokenAddress].BigBalance(), o.Amount)
 		if closeBalance.Cmp(big.NewInt(0)) < 0 {
 			logger.Printf("safe %s close balance %d lower than 0", safe.Address, closeBalance)
@@ -1018,6 +1187,14 @@ func (node *Node) processEthereumSafeSignatureResponse(ctx context.Context, req
 		}
 		sbm[o.TokenAddress].UpdateBalance(new(big.Int).Neg(o.Amount))
 	}
+	if fee := t.RefundLimit(); fee.Sign() > 0 {
+		sb := sbm[t.GasToken.Hex()]
+		if sb == nil || sb.BigBalance().Cmp(fee) < 0 {
+			logger.Printf("safe %s balance lower than the relay refund %d", safe.Address, fee)
+			return node.failRequest(ctx, req, "")
+		}
+		sb.UpdateBalance(new(big.Int).Neg(fee))
+	}
 
 	stx := node.buildStorageTransaction(ctx, req, []byte(common.Base91Encode(t.Marshal())))
 	if stx == nil {
@@ -1034,7 +1211,7 @@ func (node *Node) processEthereumSafeSignatureResponse(ctx context.Context, req
 	}
 	txs = append(txs, tt)
 
-	err = node.store.FinishTransactionSignaturesWithRequest(ctx, old.TransactionHash, raw, req, 0, safe, sbm, txs)
+	err = node.store.FinishTransactionSignaturesWithRequest(ctx, old.TransactionHash, raw, req, 0, safe, sbm, nbm, txs)
 	logger.Printf("store.FinishTransactionSignaturesWithRequest(%s, %s, %v) => %v", old.TransactionHash, raw, req, err)
 	if err != nil {
 		panic(err)
@@ -1042,6 +1219,26 @@ func (node *Node) processEthereumSafeSignatureResponse(ctx context.Context, req
 	return txs, ""
 }
 
+func (node *Node) readEthereumNFTBalances(ctx context.Context, safe *store.Safe, outputs []*ethereum.Output) map[string]*store.NFTBalance {
+	nbm := make(map[string]*store.NFTBalance)
+	for _, o := range outputs {
+		if o.TokenId == nil {
+			continue
+		}
+		key := store.NFTBalanceKey(o.TokenAddress, o.TokenId.String())
+		if nbm[key] != nil {
+			continue
+		}
+		nb, err := node.store.ReadNFTBalance(ctx, safe.Address, o.TokenAddress, o.TokenId.String(), o.TokenType)
+		logger.Printf("store.ReadNFTBalance(%s, %s, %s) => %v %v", safe.Address, o.TokenAddress, o.TokenId, nb, err)
+		if err != nil {
+			panic(err)
+		}
+		nbm[key] = nb
+	}
+	return nbm
+}
+
 func (node *Node) checkEthereumTransactionSignedBy(safe *store.Safe, t *ethereum.SafeTransaction, public string) (bool, error) {
 	_, pubs := ethereum.GetSortedSafeOwners(safe.Holder, safe.Signer, safe.Observer)
 	logger.Printf("ethereum.GetSortedSafeOwners(%s, %s, %s) => %v", safe.Holder, safe.Signer, safe.Observer, pubs)
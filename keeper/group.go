@@ -4,11 +4,14 @@ import (
 	"context"
 	"fmt"
 	"math"
+	"math/big"
 
 	"github.com/MixinNetwork/mixin/crypto"
 	"github.com/MixinNetwork/mixin/logger"
 	"github.com/MixinNetwork/safe/apps/bitcoin"
 	"github.com/MixinNetwork/safe/apps/ethereum"
+	"github.com/MixinNetwork/safe/apps/mixin"
+	"github.com/MixinNetwork/safe/apps/solana"
 	"github.com/MixinNetwork/safe/common"
 	"github.com/MixinNetwork/safe/common/abi"
 	"github.com/MixinNetwork/trusted-group/mtg"
@@ -95,17 +98,19 @@ func (node *Node) getActionRole(act byte) byte {
 		return common.RequestRoleObserver
 	case common.ActionObserverSetOperationParams:
 		return common.RequestRoleObserver
+	case common.ActionObserverSetRelayGasPrice:
+		return common.RequestRoleObserver
 	case common.ActionMigrateSafeToken:
 		return common.RequestRoleHolder
-	case common.ActionBitcoinSafeProposeAccount, common.ActionEthereumSafeProposeAccount:
+	case common.ActionBitcoinSafeProposeAccount, common.ActionEthereumSafeProposeAccount, common.ActionMixinSafeProposeAccount, common.ActionSolanaSafeProposeAccount, common.ActionTronSafeProposeAccount:
 		return common.RequestRoleHolder
-	case common.ActionBitcoinSafeApproveAccount, common.ActionEthereumSafeApproveAccount:
+	case common.ActionBitcoinSafeApproveAccount, common.ActionEthereumSafeApproveAccount, common.ActionMixinSafeApproveAccount, common.ActionSolanaSafeApproveAccount, common.ActionTronSafeApproveAccount:
 		return common.RequestRoleObserver
-	case common.ActionBitcoinSafeProposeTransaction, common.ActionEthereumSafeProposeTransaction:
+	case common.ActionBitcoinSafeProposeTransaction, common.ActionEthereumSafeProposeTransaction, common.ActionMixinSafeProposeTransaction, common.ActionSolanaSafeProposeTransaction, common.ActionTronSafeProposeTransaction:
 		return common.RequestRoleHolder
-	case common.ActionBitcoinSafeApproveTransaction, common.ActionEthereumSafeApproveTransaction:
+	case common.ActionBitcoinSafeApproveTransaction, common.ActionEthereumSafeApproveTransaction, common.ActionMixinSafeApproveTransaction, common.ActionSolanaSafeApproveTransaction, common.ActionTronSafeApproveTransaction:
 		return common.RequestRoleObserver
-	case common.ActionBitcoinSafeRevokeTransaction, common.ActionEthereumSafeRevokeTransaction:
+	case common.ActionBitcoinSafeRevokeTransaction, common.ActionEthereumSafeRevokeTransaction, common.ActionMixinSafeRevokeTransaction, common.ActionSolanaSafeRevokeTransaction, common.ActionTronSafeRevokeTransaction:
 		return common.RequestRoleObserver
 	case common.ActionBitcoinSafeCloseAccount, common.ActionEthereumSafeCloseAccount:
 		return common.RequestRoleObserver
@@ -125,14 +130,23 @@ func (node *Node) handleBondAsset(ctx context.Context, out *mtg.Action) (bool, e
 	if err != nil {
 		return false, fmt.Errorf("node.fetchAssetMeta(%s) => %v", out.AssetId, err)
 	}
-	if meta.Chain != common.SafeChainPolygon {
+	var deployed *big.Int
+	switch meta.Chain {
+	case common.SafeChainPolygon:
+		deployed, err = abi.CheckFactoryAssetDeployed(node.conf.PolygonRPC, meta.AssetKey)
+		logger.Verbosef("abi.CheckFactoryAssetDeployed(%s) => %v %v", meta.AssetKey, deployed, err)
+		if err != nil {
+			return false, fmt.Errorf("abi.CheckFactoryAssetDeployed(%s) => %v", meta.AssetKey, err)
+		}
+	case common.SafeChainMVM:
+		deployed, err = abi.CheckMVMFactoryAssetDeployed(node.conf.MVMRPC, meta.AssetKey)
+		logger.Verbosef("abi.CheckMVMFactoryAssetDeployed(%s) => %v %v", meta.AssetKey, deployed, err)
+		if err != nil {
+			return false, fmt.Errorf("abi.CheckMVMFactoryAssetDeployed(%s) => %v", meta.AssetKey, err)
+		}
+	default:
 		return false, nil
 	}
-	deployed, err := abi.CheckFactoryAssetDeployed(node.conf.PolygonRPC, meta.AssetKey)
-	logger.Verbosef("abi.CheckFactoryAssetDeployed(%s) => %v %v", meta.AssetKey, deployed, err)
-	if err != nil {
-		return false, fmt.Errorf("abi.CheckFactoryAssetDeployed(%s) => %v", meta.AssetKey, err)
-	}
 	if deployed.Sign() <= 0 {
 		return false, nil
 	}
@@ -179,6 +193,8 @@ func (node *Node) processRequest(ctx context.Context, req *common.Request) ([]*m
 		return node.CreateHolderDeposit(ctx, req)
 	case common.ActionObserverSetOperationParams:
 		return node.writeOperationParams(ctx, req)
+	case common.ActionObserverSetRelayGasPrice:
+		return node.writeRelayGasPrice(ctx, req)
 	case common.ActionMigrateSafeToken:
 		return node.checkSafeTokenMigration(ctx, req)
 	case common.ActionBitcoinSafeProposeAccount:
@@ -193,6 +209,36 @@ func (node *Node) processRequest(ctx context.Context, req *common.Request) ([]*m
 		return node.processSafeRevokeTransaction(ctx, req)
 	case common.ActionBitcoinSafeCloseAccount:
 		return node.processBitcoinSafeCloseAccount(ctx, req)
+	case common.ActionMixinSafeProposeAccount:
+		return node.processMixinSafeProposeAccount(ctx, req)
+	case common.ActionMixinSafeApproveAccount:
+		return node.processMixinSafeApproveAccount(ctx, req)
+	case common.ActionMixinSafeProposeTransaction:
+		return node.processMixinSafeProposeTransaction(ctx, req)
+	case common.ActionMixinSafeApproveTransaction:
+		return node.processMixinSafeApproveTransaction(ctx, req)
+	case common.ActionMixinSafeRevokeTransaction:
+		return node.processSafeRevokeTransaction(ctx, req)
+	case common.ActionSolanaSafeProposeAccount:
+		return node.processSolanaSafeProposeAccount(ctx, req)
+	case common.ActionSolanaSafeApproveAccount:
+		return node.processSolanaSafeApproveAccount(ctx, req)
+	case common.ActionSolanaSafeProposeTransaction:
+		return node.processSolanaSafeProposeTransaction(ctx, req)
+	case common.ActionSolanaSafeApproveTransaction:
+		return node.processSolanaSafeApproveTransaction(ctx, req)
+	case common.ActionSolanaSafeRevokeTransaction:
+		return node.processSafeRevokeTransaction(ctx, req)
+	case common.ActionTronSafeProposeAccount:
+		return node.processTronSafeProposeAccount(ctx, req)
+	case common.ActionTronSafeApproveAccount:
+		return node.processTronSafeApproveAccount(ctx, req)
+	case common.ActionTronSafeProposeTransaction:
+		return node.processTronSafeProposeTransaction(ctx, req)
+	case common.ActionTronSafeApproveTransaction:
+		return node.processTronSafeApproveTransaction(ctx, req)
+	case common.ActionTronSafeRevokeTransaction:
+		return node.processSafeRevokeTransaction(ctx, req)
 	case common.ActionEthereumSafeProposeAccount:
 		return node.processEthereumSafeProposeAccount(ctx, req)
 	case common.ActionEthereumSafeApproveAccount:
@@ -251,12 +297,33 @@ func (node *Node) processKeyAdd(ctx context.Context, req *common.Request) ([]*mt
 		if err != nil {
 			return node.failRequest(ctx, req, "")
 		}
+	case common.CurveSecp256k1SchnorrBitcoin:
+		if extra[0] != common.RequestRoleSigner {
+			return node.failRequest(ctx, req, "")
+		}
+		err = bitcoin.CheckTaprootDerivation(req.Holder, chainCode, 1000)
+		logger.Printf("bitcoin.CheckTaprootDerivation(%s, %x) => %v", req.Holder, chainCode, err)
+		if err != nil {
+			return node.failRequest(ctx, req, "")
+		}
 	case common.CurveSecp256k1ECDSAEthereum, common.CurveSecp256k1ECDSAMVM, common.CurveSecp256k1ECDSAPolygon:
 		err = ethereum.VerifyHolderKey(req.Holder)
 		logger.Printf("ethereum.VerifyHolderKey(%s, %x) => %v", req.Holder, chainCode, err)
 		if err != nil {
 			return node.failRequest(ctx, req, "")
 		}
+	case common.CurveEdwards25519Mixin:
+		err = mixin.VerifyHolderKey(req.Holder)
+		logger.Printf("mixin.VerifyHolderKey(%s, %x) => %v", req.Holder, chainCode, err)
+		if err != nil {
+			return node.failRequest(ctx, req, "")
+		}
+	case common.CurveEdwards25519Default:
+		err = solana.VerifyHolderKey(req.Holder)
+		logger.Printf("solana.VerifyHolderKey(%s, %x) => %v", req.Holder, chainCode, err)
+		if err != nil {
+			return node.failRequest(ctx, req, "")
+		}
 	default:
 		panic(req.Curve)
 	}
@@ -290,11 +357,17 @@ func (node *Node) processSignerSignatureResponse(ctx context.Context, req *commo
 	if safe.Signer != req.Holder {
 		return node.failRequest(ctx, req, "")
 	}
-	switch safe.Chain {
-	case common.SafeChainBitcoin, common.SafeChainLitecoin:
+	switch common.NormalizeSafeChain(safe.Chain) {
+	case common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainBitcoinCash:
 		return node.processBitcoinSafeSignatureResponse(ctx, req, safe, tx, old)
-	case common.SafeChainEthereum, common.SafeChainPolygon:
+	case common.SafeChainEthereum:
 		return node.processEthereumSafeSignatureResponse(ctx, req, safe, tx, old)
+	case common.SafeChainMixinKernel:
+		return node.processMixinSafeSignatureResponse(ctx, req, safe, tx, old)
+	case common.SafeChainSolana:
+		return node.processSolanaSafeSignatureResponse(ctx, req, safe, tx, old)
+	case common.SafeChainTron:
+		return node.processTronSafeSignatureResponse(ctx, req, safe, tx, old)
 	default:
 		panic(safe.Chain)
 	}
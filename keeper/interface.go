@@ -1,31 +1,38 @@
 package keeper
 
 import (
+	"github.com/MixinNetwork/safe/apps/ethereum"
 	"github.com/MixinNetwork/safe/keeper/store"
 	"github.com/MixinNetwork/trusted-group/mtg"
 )
 
 type Configuration struct {
-	AppId                       string             `toml:"app-id"`
-	SignerAppId                 string             `toml:"signer-app-id"`
-	StoreDir                    string             `toml:"store-dir"`
-	MonitorConversaionId        string             `toml:"monitor-conversation-id"`
-	SharedKey                   string             `toml:"shared-key"`
-	SignerPublicKey             string             `toml:"signer-public-key"`
-	AssetId                     string             `toml:"asset-id"`
-	ObserverAssetId             string             `toml:"observer-asset-id"`
-	ObserverPublicKey           string             `toml:"observer-public-key"`
-	ObserverUserId              string             `toml:"observer-user-id"`
-	MixinMessengerAPI           string             `toml:"mixin-messenger-api"`
-	MixinRPC                    string             `toml:"mixin-rpc"`
-	BitcoinRPC                  string             `toml:"bitcoin-rpc"`
-	LitecoinRPC                 string             `toml:"litecoin-rpc"`
-	EthereumRPC                 string             `toml:"ethereum-rpc"`
-	PolygonRPC                  string             `toml:"polygon-rpc"`
-	PolygonFactoryAddress       string             `toml:"polygon-factory-address"`
-	PolygonObserverDepositEntry string             `toml:"polygon-observer-deposit-entry"`
-	PolygonKeeperDepositEntry   string             `toml:"polygon-keeper-deposit-entry"`
-	MTG                         *mtg.Configuration `toml:"mtg"`
+	AppId                       string               `toml:"app-id"`
+	SignerAppId                 string               `toml:"signer-app-id"`
+	StoreDir                    string               `toml:"store-dir"`
+	MonitorConversaionId        string               `toml:"monitor-conversation-id"`
+	SharedKey                   string               `toml:"shared-key"`
+	SignerPublicKey             string               `toml:"signer-public-key"`
+	AssetId                     string               `toml:"asset-id"`
+	ObserverAssetId             string               `toml:"observer-asset-id"`
+	ObserverPublicKey           string               `toml:"observer-public-key"`
+	ObserverUserId              string               `toml:"observer-user-id"`
+	MixinMessengerAPI           string               `toml:"mixin-messenger-api"`
+	MixinRPC                    string               `toml:"mixin-rpc"`
+	BitcoinRPC                  string               `toml:"bitcoin-rpc"`
+	LitecoinRPC                 string               `toml:"litecoin-rpc"`
+	BitcoinCashRPC              string               `toml:"bitcoin-cash-rpc"`
+	EthereumRPC                 string               `toml:"ethereum-rpc"`
+	PolygonRPC                  string               `toml:"polygon-rpc"`
+	PolygonFactoryAddress       string               `toml:"polygon-factory-address"`
+	PolygonObserverDepositEntry string               `toml:"polygon-observer-deposit-entry"`
+	PolygonKeeperDepositEntry   string               `toml:"polygon-keeper-deposit-entry"`
+	MVMRPC                      string               `toml:"mvm-rpc"`
+	MVMFactoryAddress           string               `toml:"mvm-factory-address"`
+	SolanaRPC                   string               `toml:"solana-rpc"`
+	TronRPC                     string               `toml:"tron-rpc"`
+	EvmChains                   []*ethereum.EvmChain `toml:"evm-chains"`
+	MTG                         *mtg.Configuration   `toml:"mtg"`
 }
 
 func OpenSQLite3Store(path string) (*store.SQLite3Store, error) {
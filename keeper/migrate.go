@@ -59,8 +59,8 @@ func (node *Node) Migrate(ctx context.Context) error {
 			return err
 		}
 		ss = append(ss, ma)
-		switch safe.Chain {
-		case common.SafeChainEthereum, common.SafeChainPolygon:
+		switch common.NormalizeSafeChain(safe.Chain) {
+		case common.SafeChainEthereum:
 			bs, err := node.store.ReadUnmigratedEthereumAllBalance(ctx, safe.Address)
 			if err != nil {
 				return err
@@ -136,12 +136,12 @@ func (node *Node) checkSafeTokenMigration(ctx context.Context, req *common.Reque
 	if safe == nil || safe.State != common.RequestStateDone {
 		return node.failRequest(ctx, req, "")
 	}
-	switch safe.Chain {
-	case common.SafeChainBitcoin, common.SafeChainLitecoin:
+	switch common.NormalizeSafeChain(safe.Chain) {
+	case common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainBitcoinCash:
 		if safe.SafeAssetId != req.AssetId {
 			panic(req.AssetId)
 		}
-	case common.SafeChainEthereum, common.SafeChainPolygon:
+	case common.SafeChainEthereum:
 		bs, err := node.store.ReadAllEthereumTokenBalances(ctx, safe.Address)
 		if err != nil {
 			panic(err)
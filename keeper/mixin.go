@@ -1,6 +1,575 @@
 package keeper
 
-// holder key just for safe verification, not for kernel
-// observer holds the view key, i.e. the accountant key
-// no recovery key needed, and signer holds spend key
-// the risk here is the accountant key loss
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	mc "github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/logger"
+	"github.com/MixinNetwork/safe/apps/mixin"
+	"github.com/MixinNetwork/safe/common"
+	"github.com/MixinNetwork/safe/common/abi"
+	"github.com/MixinNetwork/safe/keeper/store"
+	"github.com/MixinNetwork/trusted-group/mtg"
+	"github.com/gofrs/uuid/v5"
+	"github.com/shopspring/decimal"
+)
+
+// the safe is the kernel multisig of the holder, signer and observer with
+// the threshold 2, and the observer key is also the view key of all members,
+// i.e. the accountant key. The kernel has no timelock, so the holder and
+// observer could recover the safe without the keeper.
+
+func mixinDefaultDerivationPath() []byte {
+	return []byte{0, 0, 0, 0}
+}
+
+func (node *Node) processMixinSafeProposeAccount(ctx context.Context, req *common.Request) ([]*mtg.Transaction, string) {
+	if req.Role != common.RequestRoleHolder {
+		panic(req.Role)
+	}
+	rce := req.ExtraBytes()
+	ver, _ := node.group.ReadKernelTransactionUntilSufficient(ctx, req.MixinHash.String())
+	if len(rce) == 32 && len(ver.References) == 1 && ver.References[0].String() == req.ExtraHEX {
+		stx, _ := node.group.ReadKernelTransactionUntilSufficient(ctx, ver.References[0].String())
+		rce = stx.Extra
+	}
+	arp, err := req.ParseMixinRecipient(ctx, node.mixin, rce)
+	logger.Printf("req.ParseMixinRecipient(%v) => %v %v", req, arp, err)
+	if err != nil {
+		return node.failRequest(ctx, req, "")
+	}
+	chain := common.SafeCurveChain(req.Curve)
+
+	plan, err := node.store.ReadLatestOperationParams(ctx, chain, req.CreatedAt)
+	logger.Printf("store.ReadLatestOperationParams(%d) => %v %v", chain, plan, err)
+	if err != nil {
+		panic(fmt.Errorf("node.ReadLatestOperationParams(%d) => %v", chain, err))
+	} else if plan == nil || !plan.OperationPriceAmount.IsPositive() {
+		return node.refundAndFailRequest(ctx, req, arp.Receivers, int(arp.Threshold))
+	}
+	if req.AssetId != plan.OperationPriceAsset {
+		return node.failRequest(ctx, req, "")
+	}
+	if req.Amount.Cmp(plan.OperationPriceAmount) < 0 {
+		return node.failRequest(ctx, req, "")
+	}
+	safe, err := node.store.ReadSafe(ctx, req.Holder)
+	if err != nil {
+		panic(fmt.Errorf("store.ReadSafe(%s) => %v", req.Holder, err))
+	} else if safe != nil {
+		return node.failRequest(ctx, req, "")
+	}
+	old, err := node.store.ReadSafeProposal(ctx, req.Id)
+	if err != nil {
+		panic(fmt.Errorf("store.ReadSafeProposal(%s) => %v", req.Id, err))
+	} else if old != nil {
+		return node.failRequest(ctx, req, "")
+	}
+
+	signer, observer, err := node.store.AssignSignerAndObserverToHolder(ctx, req, SafeKeyBackupMaturity, arp.Observer)
+	logger.Printf("store.AssignSignerAndObserverToHolder(%s) => %s %s %v", req.Holder, signer, observer, err)
+	if err != nil {
+		panic(fmt.Errorf("store.AssignSignerAndObserverToHolder(%v) => %v", req, err))
+	}
+	if signer == "" || observer == "" {
+		return node.refundAndFailRequest(ctx, req, arp.Receivers, int(arp.Threshold))
+	}
+	if arp.Observer != "" && arp.Observer != observer {
+		panic(fmt.Errorf("store.AssignSignerAndObserverToHolder(%v) => %v %s", req, arp, observer))
+	}
+	if !common.CheckUnique(req.Holder, signer, observer) {
+		return node.refundAndFailRequest(ctx, req, arp.Receivers, int(arp.Threshold))
+	}
+	path := mixinDefaultDerivationPath()
+
+	addr, err := mixin.BuildAddress(req.Holder, signer, observer)
+	logger.Verbosef("mixin.BuildAddress(%s, %s, %s) => %s %v", req.Holder, signer, observer, addr, err)
+	if err != nil {
+		panic(err)
+	}
+	old, err = node.store.ReadSafeProposalByAddress(ctx, addr)
+	if err != nil {
+		panic(fmt.Errorf("store.ReadSafeProposalByAddress(%s) => %v", addr, err))
+	} else if old != nil {
+		return node.failRequest(ctx, req, "")
+	}
+
+	extra := []byte(addr)
+	stx := node.buildStorageTransaction(ctx, req, []byte(common.Base91Encode(extra)))
+	if stx == nil {
+		return node.refundAndFailRequest(ctx, req, arp.Receivers, int(arp.Threshold))
+	}
+	txs := []*mtg.Transaction{stx}
+
+	typ := byte(common.ActionMixinSafeProposeAccount)
+	crv := common.SafeChainCurve(chain)
+	t := node.buildObserverResponseWithStorageTraceId(ctx, req.Id, req.Output, typ, crv, stx.TraceId)
+	if t == nil {
+		return node.refundAndFailRequest(ctx, req, arp.Receivers, int(arp.Threshold))
+	}
+	txs = append(txs, t)
+
+	sp := &store.SafeProposal{
+		RequestId: req.Id,
+		Chain:     chain,
+		Holder:    req.Holder,
+		Signer:    signer,
+		Observer:  observer,
+		Timelock:  arp.Timelock,
+		Path:      hex.EncodeToString(path),
+		Address:   addr,
+		Extra:     extra,
+		Receivers: arp.Receivers,
+		Threshold: arp.Threshold,
+		CreatedAt: req.CreatedAt,
+		UpdatedAt: req.CreatedAt,
+	}
+	err = node.store.WriteSafeProposalWithRequest(ctx, sp, txs, req)
+	if err != nil {
+		panic(err)
+	}
+	return txs, ""
+}
+
+func (node *Node) processMixinSafeApproveAccount(ctx context.Context, req *common.Request) ([]*mtg.Transaction, string) {
+	if req.Role != common.RequestRoleObserver {
+		panic(req.Role)
+	}
+	old, err := node.store.ReadSafe(ctx, req.Holder)
+	if err != nil {
+		panic(fmt.Errorf("store.ReadSafe(%s) => %v", req.Holder, err))
+	} else if old != nil {
+		return node.failRequest(ctx, req, "")
+	}
+	chain := common.SafeCurveChain(req.Curve)
+	safeAssetId := node.getBondAssetId(ctx, node.conf.PolygonKeeperDepositEntry, common.SafeMixinKernelAssetId, req.Holder)
+
+	extra := req.ExtraBytes()
+	if len(extra) != 80 {
+		return node.failRequest(ctx, req, "")
+	}
+	rid, err := uuid.FromBytes(extra[:16])
+	if err != nil {
+		return node.failRequest(ctx, req, "")
+	}
+	sp, err := node.store.ReadSafeProposal(ctx, rid.String())
+	if err != nil {
+		panic(fmt.Errorf("store.ReadSafeProposal(%v) => %s %v", req, rid.String(), err))
+	} else if sp == nil {
+		return node.failRequest(ctx, req, "")
+	} else if sp.Holder != req.Holder {
+		return node.failRequest(ctx, req, "")
+	} else if sp.Chain != chain {
+		return node.failRequest(ctx, req, "")
+	}
+
+	ms := fmt.Sprintf("APPROVE:%s:%s", rid.String(), sp.Address)
+	err = mixin.VerifyMessageSignature(req.Holder, []byte(ms), extra[16:])
+	logger.Printf("mixin.VerifyMessageSignature(%v) => %v", req, err)
+	if err != nil {
+		return node.failRequest(ctx, req, "")
+	}
+
+	spr, err := node.store.ReadRequest(ctx, sp.RequestId)
+	if err != nil {
+		panic(fmt.Errorf("store.ReadRequest(%s) => %v", sp.RequestId, err))
+	}
+
+	stx := node.buildStorageTransaction(ctx, req, []byte(common.Base91Encode(sp.Extra)))
+	if stx == nil {
+		return node.failRequest(ctx, req, "")
+	}
+	txs := []*mtg.Transaction{stx}
+
+	typ := byte(common.ActionMixinSafeApproveAccount)
+	crv := common.SafeChainCurve(sp.Chain)
+	t := node.buildObserverResponseWithAssetAndStorageTraceId(ctx, req.Id, req.Output, typ, crv, spr.AssetId, spr.Amount.String(), stx.TraceId)
+	if t == nil {
+		return node.failRequest(ctx, req, spr.AssetId)
+	}
+	txs = append(txs, t)
+
+	safe := &store.Safe{
+		Holder:      sp.Holder,
+		Chain:       sp.Chain,
+		Signer:      sp.Signer,
+		Observer:    sp.Observer,
+		Timelock:    sp.Timelock,
+		Path:        sp.Path,
+		Address:     sp.Address,
+		Extra:       sp.Extra,
+		Receivers:   sp.Receivers,
+		Threshold:   sp.Threshold,
+		RequestId:   req.Id,
+		State:       SafeStateApproved,
+		SafeAssetId: safeAssetId,
+		CreatedAt:   req.CreatedAt,
+		UpdatedAt:   req.CreatedAt,
+	}
+	err = node.store.WriteSafeWithRequest(ctx, safe, txs, req)
+	if err != nil {
+		panic(err)
+	}
+	return txs, ""
+}
+
+func (node *Node) processMixinSafeProposeTransaction(ctx context.Context, req *common.Request) ([]*mtg.Transaction, string) {
+	if req.Role != common.RequestRoleHolder {
+		panic(req.Role)
+	}
+	chain := common.SafeCurveChain(req.Curve)
+	safe, err := node.store.ReadSafe(ctx, req.Holder)
+	if err != nil {
+		panic(fmt.Errorf("store.ReadSafe(%s) => %v", req.Holder, err))
+	}
+	if safe == nil || safe.Chain != chain {
+		return node.failRequest(ctx, req, "")
+	}
+	if safe.State != SafeStateApproved {
+		return node.failRequest(ctx, req, "")
+	}
+
+	meta, err := node.fetchAssetMeta(ctx, req.AssetId)
+	logger.Printf("node.fetchAssetMeta(%s) => %v %v", req.AssetId, meta, err)
+	if err != nil {
+		panic(fmt.Errorf("node.fetchAssetMeta(%s) => %v", req.AssetId, err))
+	}
+	if meta.Chain != common.SafeChainPolygon {
+		return node.failRequest(ctx, req, "")
+	}
+	deployed, err := abi.CheckFactoryAssetDeployed(node.conf.PolygonRPC, meta.AssetKey)
+	logger.Printf("abi.CheckFactoryAssetDeployed(%s) => %v %v", meta.AssetKey, deployed, err)
+	if err != nil || deployed.Sign() <= 0 {
+		panic(fmt.Errorf("api.CheckFatoryAssetDeployed(%s) => %v", meta.AssetKey, err))
+	}
+	id := uuid.Must(uuid.FromBytes(deployed.Bytes()))
+	asset, err := node.fetchAssetMeta(ctx, id.String())
+	logger.Printf("node.fetchAssetMeta(%s) => %v %v", id.String(), asset, err)
+	if err != nil {
+		panic(fmt.Errorf("node.fetchAssetMeta(%s) => %v", id.String(), err))
+	}
+	if asset.Chain != common.SafeChainMixinKernel {
+		return node.failRequest(ctx, req, "")
+	}
+
+	plan, err := node.store.ReadLatestOperationParams(ctx, safe.Chain, req.CreatedAt)
+	logger.Printf("store.ReadLatestOperationParams(%d) => %v %v", safe.Chain, plan, err)
+	if err != nil {
+		panic(fmt.Errorf("store.ReadLatestOperationParams(%d) => %v", safe.Chain, err))
+	} else if plan == nil || !plan.TransactionMinimum.IsPositive() {
+		return node.refundAndFailRequest(ctx, req, safe.Receivers, int(safe.Threshold))
+	}
+	if req.Amount.Cmp(plan.TransactionMinimum) < 0 {
+		return node.failRequest(ctx, req, "")
+	}
+
+	entry := node.fetchBondAssetReceiver(ctx, safe.Address, id.String())
+	safeAssetId := node.getBondAssetId(ctx, entry, id.String(), req.Holder)
+	logger.Printf("node.getBondAssetId(%s, %s, %s) => %s", entry, id.String(), req.Holder, safeAssetId)
+	if req.AssetId != safeAssetId {
+		panic(req.AssetId)
+	}
+
+	extra := req.ExtraBytes()
+	if len(extra) < 33 {
+		return node.failRequest(ctx, req, "")
+	}
+	// the holder and observer recover the kernel safe without the keeper
+	if extra[0] != common.FlagProposeNormalTransaction {
+		return node.failRequest(ctx, req, "")
+	}
+	extra = extra[1:]
+
+	var outputs []*mixin.Recipient
+	ver, _ := node.group.ReadKernelTransactionUntilSufficient(ctx, req.MixinHash.String())
+	if len(extra) == 32 && len(ver.References) == 1 && ver.References[0].String() == hex.EncodeToString(extra) {
+		stx, _ := node.group.ReadKernelTransactionUntilSufficient(ctx, ver.References[0].String())
+		var recipients [][2]string
+		err = json.Unmarshal(stx.Extra, &recipients)
+		if err != nil {
+			return node.failRequest(ctx, req, "")
+		}
+		for _, rp := range recipients {
+			_, err := mc.NewAddressFromString(rp[0])
+			logger.Printf("common.NewAddressFromString(%s) => %v", rp[0], err)
+			if err != nil {
+				return node.failRequest(ctx, req, "")
+			}
+			amt, err := decimal.NewFromString(rp[1])
+			if err != nil || !amt.Equal(amt.Truncate(mixin.ValuePrecision)) {
+				return node.failRequest(ctx, req, "")
+			}
+			if amt.Cmp(plan.TransactionMinimum) < 0 {
+				return node.failRequest(ctx, req, "")
+			}
+			outputs = append(outputs, &mixin.Recipient{
+				Address: rp[0],
+				Amount:  amt.String(),
+			})
+		}
+	} else {
+		_, err := mc.NewAddressFromString(string(extra))
+		logger.Printf("common.NewAddressFromString(%s) => %v", string(extra), err)
+		if err != nil {
+			return node.failRequest(ctx, req, "")
+		}
+		if !req.Amount.Equal(req.Amount.Truncate(mixin.ValuePrecision)) {
+			return node.failRequest(ctx, req, "")
+		}
+		outputs = []*mixin.Recipient{{
+			Address: string(extra),
+			Amount:  req.Amount.String(),
+		}}
+	}
+
+	total := decimal.Zero
+	recipients := make([]map[string]string, len(outputs))
+	for i, out := range outputs {
+		amt := decimal.RequireFromString(out.Amount)
+		recipients[i] = map[string]string{
+			"receiver": out.Address, "amount": amt.String(),
+		}
+		total = total.Add(amt)
+	}
+	if len(outputs) >= mixin.MaxUnspentUtxo || !total.Equal(req.Amount) {
+		return node.failRequest(ctx, req, "")
+	}
+
+	mainInputs, err := node.store.ListAllMixinUTXOsForHolderAndAsset(ctx, req.Holder, id.String())
+	if err != nil {
+		panic(fmt.Errorf("store.ListAllMixinUTXOsForHolderAndAsset(%s, %s) => %v", req.Holder, id.String(), err))
+	}
+	if len(mainInputs) > mixin.MaxUnspentUtxo {
+		mainInputs = mainInputs[:mixin.MaxUnspentUtxo]
+	}
+	st, err := mixin.BuildTransaction(id.String(), mainInputs, outputs, safe.Address, req.Operation().IdBytes())
+	logger.Printf("mixin.BuildTransaction(%v) => %v %v", req, st, err)
+	if mixin.IsInsufficientInputError(err) {
+		return node.refundAndFailRequest(ctx, req, safe.Receivers, int(safe.Threshold))
+	}
+	if err != nil {
+		panic(fmt.Errorf("mixin.BuildTransaction(%v) => %v", req, err))
+	}
+
+	extra = st.Marshal()
+	stx := node.buildStorageTransaction(ctx, req, []byte(common.Base91Encode(extra)))
+	if stx == nil {
+		return node.refundAndFailRequest(ctx, req, safe.Receivers, int(safe.Threshold))
+	}
+	txs := []*mtg.Transaction{stx}
+
+	typ := byte(common.ActionMixinSafeProposeTransaction)
+	crv := common.SafeChainCurve(safe.Chain)
+	t := node.buildObserverResponseWithStorageTraceId(ctx, req.Id, req.Output, typ, crv, stx.TraceId)
+	if t == nil {
+		return node.refundAndFailRequest(ctx, req, safe.Receivers, int(safe.Threshold))
+	}
+	txs = append(txs, t)
+
+	data := common.MarshalJSONOrPanic(recipients)
+	tx := &store.Transaction{
+		TransactionHash: st.Hash(),
+		RawTransaction:  hex.EncodeToString(extra),
+		Holder:          req.Holder,
+		Chain:           safe.Chain,
+		AssetId:         id.String(),
+		State:           common.RequestStateInitial,
+		Data:            string(data),
+		RequestId:       req.Id,
+		CreatedAt:       req.CreatedAt,
+		UpdatedAt:       req.CreatedAt,
+	}
+	transacionInputs := make([]*store.TransactionInput, len(mainInputs))
+	for i, in := range mainInputs {
+		transacionInputs[i] = &store.TransactionInput{Hash: in.TransactionHash, Index: in.Index}
+	}
+	err = node.store.WriteTransactionWithRequest(ctx, tx, transacionInputs, txs, req)
+	if err != nil {
+		panic(err)
+	}
+	return txs, ""
+}
+
+// the holder signs all inputs with the ghost keys, and only the signature of
+// the first input is sent to the keeper, so the approval is small enough
+// without the storage transaction, and the observer merges all signatures
+func (node *Node) processMixinSafeApproveTransaction(ctx context.Context, req *common.Request) ([]*mtg.Transaction, string) {
+	if req.Role != common.RequestRoleObserver {
+		panic(req.Role)
+	}
+	chain := common.SafeCurveChain(req.Curve)
+	safe, err := node.store.ReadSafe(ctx, req.Holder)
+	if err != nil {
+		panic(fmt.Errorf("store.ReadSafe(%s) => %v", req.Holder, err))
+	}
+	if safe == nil || safe.Chain != chain {
+		return node.failRequest(ctx, req, "")
+	}
+
+	extra := req.ExtraBytes()
+	if len(extra) != 80 {
+		return node.failRequest(ctx, req, "")
+	}
+	rid, err := uuid.FromBytes(extra[:16])
+	if err != nil {
+		return node.failRequest(ctx, req, "")
+	}
+	tx, err := node.store.ReadTransactionByRequestId(ctx, rid.String())
+	if err != nil {
+		panic(fmt.Errorf("store.ReadTransactionByRequestId(%v) => %s %v", req, rid.String(), err))
+	} else if tx == nil {
+		return node.failRequest(ctx, req, "")
+	} else if tx.State == common.RequestStateDone {
+		return node.failRequest(ctx, req, "")
+	} else if tx.Holder != req.Holder {
+		return node.failRequest(ctx, req, "")
+	}
+
+	b := common.DecodeHexOrPanic(tx.RawTransaction)
+	st, err := mixin.UnmarshalSafeTransaction(b)
+	if err != nil {
+		panic(fmt.Errorf("mixin.UnmarshalSafeTransaction(%s) => %v", tx.RawTransaction, err))
+	}
+	hash := st.Transaction.PayloadHash()
+	msg := append(common.DecodeHexOrPanic(st.Masks[0]), hash[:]...)
+	err = verifyMixinSignatureWithMask(tx.Holder, msg, extra[16:])
+	logger.Printf("node.verifyMixinSignatureWithMask(%s, %s) => %v", tx.Holder, hash, err)
+	if err != nil {
+		return node.failRequest(ctx, req, "")
+	}
+
+	var requests []*store.SignatureRequest
+	for idx, in := range st.Transaction.Inputs {
+		utxo, _, _, err := node.store.ReadMixinUTXO(ctx, in.Hash.String(), int(in.Index))
+		if err != nil || utxo == nil {
+			panic(fmt.Errorf("store.ReadMixinUTXO(%s, %d) => %v %v", in.Hash, in.Index, utxo, err))
+		}
+
+		pending, err := node.checkTransactionIndexSignaturePending(ctx, tx.TransactionHash, idx, req)
+		logger.Printf("node.checkTransactionIndexSignaturePending(%s, %d) => %t %v", tx.TransactionHash, idx, pending, err)
+		if err != nil {
+			panic(err)
+		} else if pending {
+			continue
+		}
+
+		sr := &store.SignatureRequest{
+			TransactionHash: tx.TransactionHash,
+			InputIndex:      idx,
+			Signer:          safe.Signer,
+			Curve:           req.Curve,
+			Message:         utxo.Mask + hash.String(),
+			State:           common.RequestStateInitial,
+			CreatedAt:       req.CreatedAt,
+			UpdatedAt:       req.CreatedAt,
+		}
+		sr.RequestId = common.UniqueId(req.Id, sr.Message)
+		requests = append(requests, sr)
+	}
+
+	txs := node.buildSignerSignRequests(ctx, req, requests, safe.Path)
+	if len(txs) == 0 {
+		return node.failRequest(ctx, req, "")
+	}
+	err = node.store.WriteSignatureRequestsWithRequest(ctx, requests, tx.TransactionHash, "", req, txs)
+	logger.Printf("store.WriteSignatureRequestsWithRequest(%s, %d, %v) => %v", tx.TransactionHash, len(requests), req, err)
+	if err != nil {
+		panic(err)
+	}
+	return txs, ""
+}
+
+func (node *Node) processMixinSafeSignatureResponse(ctx context.Context, req *common.Request, safe *store.Safe, tx *store.Transaction, old *store.SignatureRequest) ([]*mtg.Transaction, string) {
+	if req.Role != common.RequestRoleSigner {
+		panic(req.Role)
+	}
+
+	sig := req.ExtraBytes()
+	msg := common.DecodeHexOrPanic(old.Message)
+	err := verifyMixinSignatureWithMask(safe.Signer, msg, sig)
+	logger.Printf("node.verifyMixinSignatureWithMask(%v) => %v", req, err)
+	if err != nil {
+		return node.failRequest(ctx, req, "")
+	}
+
+	err = node.store.FinishSignatureRequest(ctx, req)
+	logger.Printf("store.FinishSignatureRequest(%s) => %v", req.Id, err)
+	if err != nil {
+		panic(fmt.Errorf("store.FinishSignatureRequest(%s) => %v", req.Id, err))
+	}
+
+	b := common.DecodeHexOrPanic(tx.RawTransaction)
+	st, err := mixin.UnmarshalSafeTransaction(b)
+	if err != nil {
+		panic(fmt.Errorf("mixin.UnmarshalSafeTransaction(%s) => %v", tx.RawTransaction, err))
+	}
+	hash := st.Transaction.PayloadHash()
+
+	requests, err := node.store.ListAllSignaturesForTransaction(ctx, old.TransactionHash, common.RequestStatePending)
+	logger.Printf("store.ListAllSignaturesForTransaction(%s) => %d %v", old.TransactionHash, len(requests), err)
+	if err != nil {
+		panic(fmt.Errorf("store.ListAllSignaturesForTransaction(%s) => %v", old.TransactionHash, err))
+	}
+
+	st.Transaction.SignaturesMap = make([]map[uint16]*crypto.Signature, len(st.Transaction.Inputs))
+	for idx := range st.Transaction.Inputs {
+		sr := requests[idx]
+		if sr == nil {
+			return node.failRequest(ctx, req, "")
+		}
+		msg := common.DecodeHexOrPanic(sr.Message)
+		if hex.EncodeToString(msg[32:]) != hash.String() {
+			panic(sr.Message)
+		}
+		sig := common.DecodeHexOrPanic(sr.Signature.String)
+		err = verifyMixinSignatureWithMask(safe.Signer, msg, sig)
+		if err != nil {
+			panic(sr.Signature.String)
+		}
+		var s crypto.Signature
+		copy(s[:], sig)
+		st.Transaction.SignaturesMap[idx] = map[uint16]*crypto.Signature{mixin.SignerKeyIndex: &s}
+	}
+
+	stx := node.buildStorageTransaction(ctx, req, []byte(common.Base91Encode(st.Marshal())))
+	if stx == nil {
+		return node.failRequest(ctx, req, "")
+	}
+	txs := []*mtg.Transaction{stx}
+
+	id := common.UniqueId(old.TransactionHash, stx.TraceId)
+	typ := byte(common.ActionMixinSafeApproveTransaction)
+	crv := common.SafeChainCurve(safe.Chain)
+	t := node.buildObserverResponseWithStorageTraceId(ctx, id, req.Output, typ, crv, stx.TraceId)
+	if t == nil {
+		return node.failRequest(ctx, req, "")
+	}
+	txs = append(txs, t)
+
+	raw := hex.EncodeToString(st.Marshal())
+	err = node.store.FinishTransactionSignaturesWithRequest(ctx, old.TransactionHash, raw, req, int64(len(st.Transaction.Inputs)), safe, nil, nil, txs)
+	logger.Printf("store.FinishTransactionSignaturesWithRequest(%s, %s, %v) => %v", old.TransactionHash, raw, req, err)
+	if err != nil {
+		panic(err)
+	}
+	return txs, ""
+}
+
+// the signature request message is the ghost mask and the payload hash, and
+// the signature is valid for the one time key derived from the member key
+func verifyMixinSignatureWithMask(spend string, msg, sig []byte) error {
+	if len(msg) != 64 {
+		return fmt.Errorf("invalid mixin message %x", msg)
+	}
+	pub, err := mixin.GhostPublicKey(spend, msg[:32])
+	if err != nil {
+		return err
+	}
+	var hash crypto.Hash
+	copy(hash[:], msg[32:])
+	return mixin.VerifyHashSignature(pub, hash, sig)
+}
@@ -15,9 +15,11 @@ import (
 	"github.com/MixinNetwork/mixin/logger"
 	"github.com/MixinNetwork/safe/apps/bitcoin"
 	"github.com/MixinNetwork/safe/apps/ethereum"
+	"github.com/MixinNetwork/safe/apps/tron"
 	"github.com/MixinNetwork/safe/common"
 	"github.com/MixinNetwork/safe/keeper/store"
 	"github.com/MixinNetwork/trusted-group/mtg"
+	gc "github.com/ethereum/go-ethereum/common"
 	"github.com/gofrs/uuid/v5"
 	"github.com/shopspring/decimal"
 )
@@ -57,8 +59,8 @@ func (node *Node) writeNetworkInfo(ctx context.Context, req *common.Request) ([]
 	if info.Chain != common.SafeCurveChain(req.Curve) {
 		panic(req.Id)
 	}
-	switch info.Chain {
-	case common.SafeChainBitcoin, common.SafeChainLitecoin:
+	switch common.NormalizeSafeChain(info.Chain) {
+	case common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainBitcoinCash:
 		info.Hash = hex.EncodeToString(extra[17:])
 		valid, err := node.verifyBitcoinNetworkInfo(ctx, info, old)
 		if err != nil {
@@ -66,7 +68,7 @@ func (node *Node) writeNetworkInfo(ctx context.Context, req *common.Request) ([]
 		} else if !valid {
 			return node.failRequest(ctx, req, "")
 		}
-	case common.SafeChainEthereum, common.SafeChainPolygon:
+	case common.SafeChainEthereum:
 		info.Hash = "0x" + hex.EncodeToString(extra[17:])
 		valid, err := node.verifyEthereumNetworkInfo(ctx, info, old)
 		if err != nil {
@@ -74,6 +76,14 @@ func (node *Node) writeNetworkInfo(ctx context.Context, req *common.Request) ([]
 		} else if !valid {
 			return node.failRequest(ctx, req, "")
 		}
+	case common.SafeChainTron:
+		info.Hash = hex.EncodeToString(extra[17:])
+		valid, err := node.verifyTronNetworkInfo(ctx, info, old)
+		if err != nil {
+			panic(fmt.Errorf("node.verifyTronNetworkInfo(%v) => %v", info, err))
+		} else if !valid {
+			return node.failRequest(ctx, req, "")
+		}
 	default:
 		return node.failRequest(ctx, req, "")
 	}
@@ -98,11 +108,14 @@ func (node *Node) writeOperationParams(ctx context.Context, req *common.Request)
 	if chain != common.SafeCurveChain(req.Curve) {
 		panic(req.Id)
 	}
-	switch chain {
+	switch common.NormalizeSafeChain(chain) {
 	case common.SafeChainBitcoin:
 	case common.SafeChainLitecoin:
+	case common.SafeChainBitcoinCash:
 	case common.SafeChainEthereum:
-	case common.SafeChainPolygon:
+	case common.SafeChainMixinKernel:
+	case common.SafeChainSolana:
+	case common.SafeChainTron:
 	default:
 		return node.failRequest(ctx, req, "")
 	}
@@ -127,6 +140,42 @@ func (node *Node) writeOperationParams(ctx context.Context, req *common.Request)
 	return nil, ""
 }
 
+// the relay gas price is the token amount per gas unit, which the safe
+// refunds to the relay bundler for the transactions moving the token
+func (node *Node) writeRelayGasPrice(ctx context.Context, req *common.Request) ([]*mtg.Transaction, string) {
+	logger.Printf("node.writeRelayGasPrice(%v)", req)
+	if req.Role != common.RequestRoleObserver {
+		panic(req.Role)
+	}
+	extra := req.ExtraBytes()
+	if len(extra) <= 21 || len(extra) > 21+32 {
+		return node.failRequest(ctx, req, "")
+	}
+
+	chain := extra[0]
+	if chain != common.SafeCurveChain(req.Curve) {
+		panic(req.Id)
+	}
+	if common.NormalizeSafeChain(chain) != common.SafeChainEthereum {
+		return node.failRequest(ctx, req, "")
+	}
+	price := &store.RelayGasPrice{
+		RequestId:    req.Id,
+		Chain:        chain,
+		AssetAddress: gc.BytesToAddress(extra[1:21]).Hex(),
+		GasPrice:     new(big.Int).SetBytes(extra[21:]),
+		CreatedAt:    req.CreatedAt,
+	}
+	if price.GasPrice.Sign() <= 0 {
+		return node.failRequest(ctx, req, "")
+	}
+	err := node.store.WriteRelayGasPriceFromRequest(ctx, price, req)
+	if err != nil {
+		panic(err)
+	}
+	return nil, ""
+}
+
 func (node *Node) verifyBitcoinNetworkInfo(ctx context.Context, info, old *store.NetworkInfo) (bool, error) {
 	if len(info.Hash) != 64 {
 		return false, nil
@@ -175,12 +224,34 @@ func (node *Node) verifyEthereumNetworkInfo(ctx context.Context, info, old *stor
 	return true, nil
 }
 
+func (node *Node) verifyTronNetworkInfo(ctx context.Context, info, old *store.NetworkInfo) (bool, error) {
+	if len(info.Hash) != 64 {
+		return false, nil
+	}
+	if old != nil && old.Hash == info.Hash {
+		if old.Height != info.Height {
+			return false, fmt.Errorf("malicious tron block %s", info.Hash)
+		}
+	} else {
+		block, err := tron.RPCGetBlockByNumber(node.conf.TronRPC, int64(info.Height))
+		if err != nil || block == nil {
+			return false, fmt.Errorf("malicious tron block or node not in sync? %s %v", info.Hash, err)
+		}
+		if block.BlockID != info.Hash {
+			return false, fmt.Errorf("malicious tron block %s", info.Hash)
+		}
+	}
+	return true, nil
+}
+
 func (node *Node) bitcoinParams(chain byte) (string, string) {
 	switch chain {
 	case common.SafeChainBitcoin:
 		return node.conf.BitcoinRPC, common.SafeBitcoinChainId
 	case common.SafeChainLitecoin:
 		return node.conf.LitecoinRPC, common.SafeLitecoinChainId
+	case common.SafeChainBitcoinCash:
+		return node.conf.BitcoinCashRPC, common.SafeBitcoinCashChainId
 	default:
 		panic(chain)
 	}
@@ -192,9 +263,15 @@ func (node *Node) ethereumParams(chain byte) (string, string) {
 		return node.conf.EthereumRPC, common.SafeEthereumChainId
 	case common.SafeChainPolygon:
 		return node.conf.PolygonRPC, common.SafePolygonChainId
-	default:
-		panic(chain)
+	case common.SafeChainMVM:
+		return node.conf.MVMRPC, common.SafeMVMChainId
+	}
+	for _, c := range node.conf.EvmChains {
+		if c.Chain == chain {
+			return c.RPC, c.MixinChainId
+		}
 	}
+	panic(chain)
 }
 
 func (node *Node) fetchAssetMetaFromMessengerOrEthereum(ctx context.Context, id, assetContract string, chain byte) (*store.Asset, error) {
@@ -202,9 +279,8 @@ func (node *Node) fetchAssetMetaFromMessengerOrEthereum(ctx context.Context, id,
 	if err != nil || meta != nil {
 		return meta, err
 	}
-	switch chain {
+	switch common.NormalizeSafeChain(chain) {
 	case common.SafeChainEthereum:
-	case common.SafeChainPolygon:
 	default:
 		panic(chain)
 	}
@@ -39,6 +39,11 @@ func NewNode(store *store.SQLite3Store, group *mtg.Group, conf *Configuration, s
 	node.observerAESKey = common.ECDHEd25519(conf.SharedKey, conf.ObserverPublicKey)
 	node.mixin = mixin
 	abi.InitFactoryContractAddress(conf.PolygonFactoryAddress)
+	abi.InitMVMFactoryContractAddress(conf.MVMFactoryAddress)
+	err := common.RegisterEvmChains(conf.EvmChains)
+	if err != nil {
+		panic(err)
+	}
 	return node
 }
 
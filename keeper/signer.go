@@ -2,6 +2,7 @@ package keeper
 
 import (
 	"context"
+	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
 	"math/big"
@@ -26,6 +27,9 @@ func (node *Node) processSignerKeygenRequests(ctx context.Context, req *common.R
 	switch crv {
 	case common.CurveSecp256k1ECDSABitcoin:
 	case common.CurveSecp256k1ECDSAEthereum:
+	case common.CurveSecp256k1SchnorrBitcoin:
+	case common.CurveEdwards25519Default:
+	case common.CurveEdwards25519Mixin:
 	default:
 		return node.failRequest(ctx, req, "")
 	}
@@ -63,6 +67,9 @@ func (node *Node) buildSignerSignRequests(ctx context.Context, request *common.R
 		switch crv {
 		case common.CurveSecp256k1ECDSABitcoin:
 		case common.CurveSecp256k1ECDSAEthereum:
+		case common.CurveSecp256k1SchnorrBitcoin:
+		case common.CurveEdwards25519Default:
+		case common.CurveEdwards25519Mixin:
 		default:
 			panic(sr.Curve)
 		}
@@ -79,6 +86,14 @@ func (node *Node) buildSignerSignRequests(ctx context.Context, request *common.R
 			Public: hex.EncodeToString(fingerPath),
 			Extra:  common.DecodeHexOrPanic(sr.Message),
 		}
+		if crv == common.CurveEdwards25519Default {
+			stx, tx := node.buildSignerTransactionWithStorage(ctx, request, op)
+			if stx == nil || tx == nil {
+				return nil
+			}
+			txs = append(txs, stx, tx)
+			continue
+		}
 		tx := node.buildSignerTransaction(ctx, request.Output, op)
 		if tx == nil {
 			return nil
@@ -88,6 +103,26 @@ func (node *Node) buildSignerSignRequests(ctx context.Context, request *common.R
 	return txs
 }
 
+// the ed25519 signature is made over the whole message, which may be too
+// large for the operation, so the message is put in the storage transaction
+// and the operation extra is replaced with the message hash
+func (node *Node) buildSignerTransactionWithStorage(ctx context.Context, request *common.Request, op *common.Operation) (*mtg.Transaction, *mtg.Transaction) {
+	stx := node.buildStorageTransaction(ctx, request, op.Extra)
+	if stx == nil {
+		return nil, nil
+	}
+	hash := sha256.Sum256(op.Extra)
+	op.Extra = hash[:]
+	extra := node.encryptSignerOperation(op)
+	if len(extra) > 160 {
+		panic(fmt.Errorf("node.buildSignerTransactionWithStorage(%v) omitted %x", op, extra))
+	}
+	members := node.signer.Genesis.Members
+	threshold := node.signer.Genesis.Threshold
+	tx := node.buildTransactionWithStorageTraceId(ctx, request.Output, node.conf.SignerAppId, node.conf.AssetId, members, threshold, "1", extra, op.Id, stx.TraceId)
+	return stx, tx
+}
+
 func (node *Node) encryptSignerOperation(op *common.Operation) []byte {
 	extra := op.Encode()
 	return common.AESEncrypt(node.signerAESKey[:], extra, op.Id)
@@ -0,0 +1,548 @@
+package keeper
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	"github.com/MixinNetwork/mixin/logger"
+	"github.com/MixinNetwork/safe/apps/solana"
+	"github.com/MixinNetwork/safe/common"
+	"github.com/MixinNetwork/safe/common/abi"
+	"github.com/MixinNetwork/safe/keeper/store"
+	"github.com/MixinNetwork/trusted-group/mtg"
+	"github.com/gofrs/uuid/v5"
+	"github.com/shopspring/decimal"
+)
+
+// the safe address is the vault of the squads multisig created with the signer
+// key, the multisig members are the holder, signer and observer with the
+// threshold 2, and only the signer could initiate and execute transactions.
+// the signer is the fee payer and the durable nonce authority, and the keeper
+// only requests the signer signature after the holder approval, or the
+// observer approval after the timelock.
+
+func solanaDefaultDerivationPath() []byte {
+	return []byte{0, 0, 0, 0}
+}
+
+func (node *Node) processSolanaSafeProposeAccount(ctx context.Context, req *common.Request) ([]*mtg.Transaction, string) {
+	if req.Role != common.RequestRoleHolder {
+		panic(req.Role)
+	}
+	rce := req.ExtraBytes()
+	ver, _ := node.group.ReadKernelTransactionUntilSufficient(ctx, req.MixinHash.String())
+	if len(rce) == 32 && len(ver.References) == 1 && ver.References[0].String() == req.ExtraHEX {
+		stx, _ := node.group.ReadKernelTransactionUntilSufficient(ctx, ver.References[0].String())
+		rce = stx.Extra
+	}
+	arp, err := req.ParseMixinRecipient(ctx, node.mixin, rce)
+	logger.Printf("req.ParseMixinRecipient(%v) => %v %v", req, arp, err)
+	if err != nil {
+		return node.failRequest(ctx, req, "")
+	}
+	chain := common.SafeCurveChain(req.Curve)
+
+	plan, err := node.store.ReadLatestOperationParams(ctx, chain, req.CreatedAt)
+	logger.Printf("store.ReadLatestOperationParams(%d) => %v %v", chain, plan, err)
+	if err != nil {
+		panic(fmt.Errorf("node.ReadLatestOperationParams(%d) => %v", chain, err))
+	} else if plan == nil || !plan.OperationPriceAmount.IsPositive() {
+		return node.refundAndFailRequest(ctx, req, arp.Receivers, int(arp.Threshold))
+	}
+	if req.AssetId != plan.OperationPriceAsset {
+		return node.failRequest(ctx, req, "")
+	}
+	if req.Amount.Cmp(plan.OperationPriceAmount) < 0 {
+		return node.failRequest(ctx, req, "")
+	}
+	safe, err := node.store.ReadSafe(ctx, req.Holder)
+	if err != nil {
+		panic(fmt.Errorf("store.ReadSafe(%s) => %v", req.Holder, err))
+	} else if safe != nil {
+		return node.failRequest(ctx, req, "")
+	}
+	old, err := node.store.ReadSafeProposal(ctx, req.Id)
+	if err != nil {
+		panic(fmt.Errorf("store.ReadSafeProposal(%s) => %v", req.Id, err))
+	} else if old != nil {
+		return node.failRequest(ctx, req, "")
+	}
+
+	signer, observer, err := node.store.AssignSignerAndObserverToHolder(ctx, req, SafeKeyBackupMaturity, arp.Observer)
+	logger.Printf("store.AssignSignerAndObserverToHolder(%s) => %s %s %v", req.Holder, signer, observer, err)
+	if err != nil {
+		panic(fmt.Errorf("store.AssignSignerAndObserverToHolder(%v) => %v", req, err))
+	}
+	if signer == "" || observer == "" {
+		return node.refundAndFailRequest(ctx, req, arp.Receivers, int(arp.Threshold))
+	}
+	if arp.Observer != "" && arp.Observer != observer {
+		panic(fmt.Errorf("store.AssignSignerAndObserverToHolder(%v) => %v %s", req, arp, observer))
+	}
+	if !common.CheckUnique(req.Holder, signer, observer) {
+		return node.refundAndFailRequest(ctx, req, arp.Receivers, int(arp.Threshold))
+	}
+	path := solanaDefaultDerivationPath()
+
+	addr, err := solana.BuildAddress(req.Holder, signer, observer)
+	logger.Verbosef("solana.BuildAddress(%s, %s, %s) => %s %v", req.Holder, signer, observer, addr, err)
+	if err != nil {
+		return node.refundAndFailRequest(ctx, req, arp.Receivers, int(arp.Threshold))
+	}
+	old, err = node.store.ReadSafeProposalByAddress(ctx, addr)
+	if err != nil {
+		panic(fmt.Errorf("store.ReadSafeProposalByAddress(%s) => %v", addr, err))
+	} else if old != nil {
+		return node.failRequest(ctx, req, "")
+	}
+
+	extra := []byte(addr)
+	stx := node.buildStorageTransaction(ctx, req, []byte(common.Base91Encode(extra)))
+	if stx == nil {
+		return node.refundAndFailRequest(ctx, req, arp.Receivers, int(arp.Threshold))
+	}
+	txs := []*mtg.Transaction{stx}
+
+	typ := byte(common.ActionSolanaSafeProposeAccount)
+	crv := common.SafeChainCurve(chain)
+	t := node.buildObserverResponseWithStorageTraceId(ctx, req.Id, req.Output, typ, crv, stx.TraceId)
+	if t == nil {
+		return node.refundAndFailRequest(ctx, req, arp.Receivers, int(arp.Threshold))
+	}
+	txs = append(txs, t)
+
+	sp := &store.SafeProposal{
+		RequestId: req.Id,
+		Chain:     chain,
+		Holder:    req.Holder,
+		Signer:    signer,
+		Observer:  observer,
+		Timelock:  arp.Timelock,
+		Path:      hex.EncodeToString(path),
+		Address:   addr,
+		Extra:     extra,
+		Receivers: arp.Receivers,
+		Threshold: arp.Threshold,
+		CreatedAt: req.CreatedAt,
+		UpdatedAt: req.CreatedAt,
+	}
+	err = node.store.WriteSafeProposalWithRequest(ctx, sp, txs, req)
+	if err != nil {
+		panic(err)
+	}
+	return txs, ""
+}
+
+func (node *Node) processSolanaSafeApproveAccount(ctx context.Context, req *common.Request) ([]*mtg.Transaction, string) {
+	if req.Role != common.RequestRoleObserver {
+		panic(req.Role)
+	}
+	old, err := node.store.ReadSafe(ctx, req.Holder)
+	if err != nil {
+		panic(fmt.Errorf("store.ReadSafe(%s) => %v", req.Holder, err))
+	} else if old != nil {
+		return node.failRequest(ctx, req, "")
+	}
+	chain := common.SafeCurveChain(req.Curve)
+	safeAssetId := node.getBondAssetId(ctx, node.conf.PolygonKeeperDepositEntry, common.SafeSolanaChainId, req.Holder)
+
+	extra := req.ExtraBytes()
+	if len(extra) != 80 {
+		return node.failRequest(ctx, req, "")
+	}
+	rid, err := uuid.FromBytes(extra[:16])
+	if err != nil {
+		return node.failRequest(ctx, req, "")
+	}
+	sp, err := node.store.ReadSafeProposal(ctx, rid.String())
+	if err != nil {
+		panic(fmt.Errorf("store.ReadSafeProposal(%v) => %s %v", req, rid.String(), err))
+	} else if sp == nil {
+		return node.failRequest(ctx, req, "")
+	} else if sp.Holder != req.Holder {
+		return node.failRequest(ctx, req, "")
+	} else if sp.Chain != chain {
+		return node.failRequest(ctx, req, "")
+	}
+
+	ms := fmt.Sprintf("APPROVE:%s:%s", rid.String(), sp.Address)
+	err = solana.VerifyMessageSignature(req.Holder, []byte(ms), extra[16:])
+	logger.Printf("solana.VerifyMessageSignature(%v) => %v", req, err)
+	if err != nil {
+		return node.failRequest(ctx, req, "")
+	}
+
+	spr, err := node.store.ReadRequest(ctx, sp.RequestId)
+	if err != nil {
+		panic(fmt.Errorf("store.ReadRequest(%s) => %v", sp.RequestId, err))
+	}
+
+	stx := node.buildStorageTransaction(ctx, req, []byte(common.Base91Encode(sp.Extra)))
+	if stx == nil {
+		return node.failRequest(ctx, req, "")
+	}
+	txs := []*mtg.Transaction{stx}
+
+	typ := byte(common.ActionSolanaSafeApproveAccount)
+	crv := common.SafeChainCurve(sp.Chain)
+	t := node.buildObserverResponseWithAssetAndStorageTraceId(ctx, req.Id, req.Output, typ, crv, spr.AssetId, spr.Amount.String(), stx.TraceId)
+	if t == nil {
+		return node.failRequest(ctx, req, spr.AssetId)
+	}
+	txs = append(txs, t)
+
+	safe := &store.Safe{
+		Holder:      sp.Holder,
+		Chain:       sp.Chain,
+		Signer:      sp.Signer,
+		Observer:    sp.Observer,
+		Timelock:    sp.Timelock,
+		Path:        sp.Path,
+		Address:     sp.Address,
+		Extra:       sp.Extra,
+		Receivers:   sp.Receivers,
+		Threshold:   sp.Threshold,
+		RequestId:   req.Id,
+		State:       SafeStateApproved,
+		SafeAssetId: safeAssetId,
+		CreatedAt:   req.CreatedAt,
+		UpdatedAt:   req.CreatedAt,
+	}
+	err = node.store.WriteSafeWithRequest(ctx, safe, txs, req)
+	if err != nil {
+		panic(err)
+	}
+	return txs, ""
+}
+
+func (node *Node) processSolanaSafeProposeTransaction(ctx context.Context, req *common.Request) ([]*mtg.Transaction, string) {
+	if req.Role != common.RequestRoleHolder {
+		panic(req.Role)
+	}
+	chain := common.SafeCurveChain(req.Curve)
+	safe, err := node.store.ReadSafe(ctx, req.Holder)
+	if err != nil {
+		panic(fmt.Errorf("store.ReadSafe(%s) => %v", req.Holder, err))
+	}
+	if safe == nil || safe.Chain != chain {
+		return node.failRequest(ctx, req, "")
+	}
+	if safe.State != SafeStateApproved {
+		return node.failRequest(ctx, req, "")
+	}
+
+	pendings, err := node.store.ReadUnfinishedTransactionsByHolder(ctx, safe.Holder)
+	logger.Printf("store.ReadUnfinishedTransactionsByHolder(%s) => %v %v", safe.Holder, len(pendings), err)
+	if len(pendings) > 0 {
+		return node.failRequest(ctx, req, "")
+	}
+
+	meta, err := node.fetchAssetMeta(ctx, req.AssetId)
+	logger.Printf("node.fetchAssetMeta(%s) => %v %v", req.AssetId, meta, err)
+	if err != nil {
+		panic(fmt.Errorf("node.fetchAssetMeta(%s) => %v", req.AssetId, err))
+	}
+	if meta.Chain != common.SafeChainPolygon {
+		return node.failRequest(ctx, req, "")
+	}
+	deployed, err := abi.CheckFactoryAssetDeployed(node.conf.PolygonRPC, meta.AssetKey)
+	logger.Printf("abi.CheckFactoryAssetDeployed(%s) => %v %v", meta.AssetKey, deployed, err)
+	if err != nil || deployed.Sign() <= 0 {
+		panic(fmt.Errorf("api.CheckFatoryAssetDeployed(%s) => %v", meta.AssetKey, err))
+	}
+	id := uuid.Must(uuid.FromBytes(deployed.Bytes()))
+	// only the native sol transfers are supported now
+	if id.String() != common.SafeSolanaChainId {
+		return node.failRequest(ctx, req, "")
+	}
+
+	plan, err := node.store.ReadLatestOperationParams(ctx, safe.Chain, req.CreatedAt)
+	logger.Printf("store.ReadLatestOperationParams(%d) => %v %v", safe.Chain, plan, err)
+	if err != nil {
+		panic(fmt.Errorf("store.ReadLatestOperationParams(%d) => %v", safe.Chain, err))
+	} else if plan == nil || !plan.TransactionMinimum.IsPositive() {
+		return node.refundAndFailRequest(ctx, req, safe.Receivers, int(safe.Threshold))
+	}
+	if req.Amount.Cmp(plan.TransactionMinimum) < 0 {
+		return node.failRequest(ctx, req, "")
+	}
+
+	entry := node.fetchBondAssetReceiver(ctx, safe.Address, id.String())
+	safeAssetId := node.getBondAssetId(ctx, entry, id.String(), req.Holder)
+	logger.Printf("node.getBondAssetId(%s, %s, %s) => %s", entry, id.String(), req.Holder, safeAssetId)
+	if req.AssetId != safeAssetId {
+		panic(req.AssetId)
+	}
+
+	extra := req.ExtraBytes()
+	if len(extra) != 33 {
+		return node.failRequest(ctx, req, "")
+	}
+	// the observer recovery is the approval after timelock, not a new flag
+	if extra[0] != common.FlagProposeNormalTransaction {
+		return node.failRequest(ctx, req, "")
+	}
+	extra = extra[1:]
+
+	// the durable nonce account is created and funded by the holder, and
+	// the nonce authority must be the signer key. The transaction index is
+	// the next index of the squads multisig, and the first transaction also
+	// creates the multisig, which requires the squads program treasury
+	var proposal struct {
+		NonceAccount     string      `json:"nonce_account"`
+		NonceHash        string      `json:"nonce_hash"`
+		TransactionIndex uint64      `json:"transaction_index"`
+		Treasury         string      `json:"treasury"`
+		Outputs          [][2]string `json:"outputs"`
+	}
+	ver, _ := node.group.ReadKernelTransactionUntilSufficient(ctx, req.MixinHash.String())
+	if len(ver.References) != 1 || ver.References[0].String() != hex.EncodeToString(extra) {
+		return node.failRequest(ctx, req, "")
+	}
+	ptx, _ := node.group.ReadKernelTransactionUntilSufficient(ctx, ver.References[0].String())
+	err = json.Unmarshal(ptx.Extra, &proposal)
+	if err != nil {
+		return node.failRequest(ctx, req, "")
+	}
+
+	var outputs []*solana.Recipient
+	for _, rp := range proposal.Outputs {
+		_, err := solana.DecodeAddress(rp[0])
+		logger.Printf("solana.DecodeAddress(%s) => %v", rp[0], err)
+		if err != nil {
+			return node.failRequest(ctx, req, "")
+		}
+		amt, err := decimal.NewFromString(rp[1])
+		if err != nil || !amt.Equal(amt.Truncate(solana.ValuePrecision)) {
+			return node.failRequest(ctx, req, "")
+		}
+		if amt.Cmp(plan.TransactionMinimum) < 0 {
+			return node.failRequest(ctx, req, "")
+		}
+		outputs = append(outputs, &solana.Recipient{
+			Address: rp[0],
+			Amount:  amt.String(),
+		})
+	}
+
+	total := decimal.Zero
+	recipients := make([]map[string]string, len(outputs))
+	for i, out := range outputs {
+		amt := decimal.RequireFromString(out.Amount)
+		recipients[i] = map[string]string{
+			"receiver": out.Address, "amount": amt.String(),
+		}
+		total = total.Add(amt)
+	}
+	if len(outputs) == 0 || len(outputs) > solana.MaxTransferOutputs || !total.Equal(req.Amount) {
+		return node.failRequest(ctx, req, "")
+	}
+
+	// the solana balances share the same table with ethereum
+	balance, err := node.store.ReadEthereumBalance(ctx, safe.Address, id.String(), safeAssetId)
+	logger.Printf("store.ReadEthereumBalance(%s, %s) => %v %v", safe.Address, id.String(), balance, err)
+	if err != nil {
+		panic(err)
+	}
+	if balance.BigBalance().Cmp(total.Shift(solana.ValuePrecision).BigInt()) < 0 {
+		return node.refundAndFailRequest(ctx, req, safe.Receivers, int(safe.Threshold))
+	}
+
+	st, err := solana.BuildTransaction(safe.Holder, safe.Signer, safe.Observer, proposal.TransactionIndex, proposal.Treasury, proposal.NonceAccount, proposal.NonceHash, outputs)
+	logger.Printf("solana.BuildTransaction(%v) => %v %v", req, st, err)
+	if err != nil {
+		return node.failRequest(ctx, req, "")
+	}
+
+	extra = st.Marshal()
+	stx := node.buildStorageTransaction(ctx, req, []byte(common.Base91Encode(extra)))
+	if stx == nil {
+		return node.refundAndFailRequest(ctx, req, safe.Receivers, int(safe.Threshold))
+	}
+	txs := []*mtg.Transaction{stx}
+
+	typ := byte(common.ActionSolanaSafeProposeTransaction)
+	crv := common.SafeChainCurve(safe.Chain)
+	t := node.buildObserverResponseWithStorageTraceId(ctx, req.Id, req.Output, typ, crv, stx.TraceId)
+	if t == nil {
+		return node.refundAndFailRequest(ctx, req, safe.Receivers, int(safe.Threshold))
+	}
+	txs = append(txs, t)
+
+	data := common.MarshalJSONOrPanic(recipients)
+	tx := &store.Transaction{
+		TransactionHash: st.Hash(),
+		RawTransaction:  hex.EncodeToString(extra),
+		Holder:          req.Holder,
+		Chain:           safe.Chain,
+		AssetId:         id.String(),
+		State:           common.RequestStateInitial,
+		Data:            string(data),
+		RequestId:       req.Id,
+		CreatedAt:       req.CreatedAt,
+		UpdatedAt:       req.CreatedAt,
+	}
+	err = node.store.WriteTransactionWithRequest(ctx, tx, nil, txs, req)
+	if err != nil {
+		panic(err)
+	}
+	return txs, ""
+}
+
+// the holder signs the solana message directly, and the observer signs the
+// recovery message, which is only accepted after the timelock since the
+// transaction proposed, and the observer could never spend without the signer
+func (node *Node) processSolanaSafeApproveTransaction(ctx context.Context, req *common.Request) ([]*mtg.Transaction, string) {
+	if req.Role != common.RequestRoleObserver {
+		panic(req.Role)
+	}
+	chain := common.SafeCurveChain(req.Curve)
+	safe, err := node.store.ReadSafe(ctx, req.Holder)
+	if err != nil {
+		panic(fmt.Errorf("store.ReadSafe(%s) => %v", req.Holder, err))
+	}
+	if safe == nil || safe.Chain != chain {
+		return node.failRequest(ctx, req, "")
+	}
+
+	extra := req.ExtraBytes()
+	if len(extra) != 80 {
+		return node.failRequest(ctx, req, "")
+	}
+	rid, err := uuid.FromBytes(extra[:16])
+	if err != nil {
+		return node.failRequest(ctx, req, "")
+	}
+	tx, err := node.store.ReadTransactionByRequestId(ctx, rid.String())
+	if err != nil {
+		panic(fmt.Errorf("store.ReadTransactionByRequestId(%v) => %s %v", req, rid.String(), err))
+	} else if tx == nil {
+		return node.failRequest(ctx, req, "")
+	} else if tx.State != common.RequestStateInitial {
+		return node.failRequest(ctx, req, "")
+	} else if tx.Holder != req.Holder {
+		return node.failRequest(ctx, req, "")
+	}
+
+	b := common.DecodeHexOrPanic(tx.RawTransaction)
+	st, err := solana.UnmarshalSafeTransaction(b)
+	if err != nil {
+		panic(fmt.Errorf("solana.UnmarshalSafeTransaction(%s) => %v", tx.RawTransaction, err))
+	}
+	sig := extra[16:]
+	msg := st.CosignerMessage(safe.Holder)
+	err = solana.VerifyMessageSignature(safe.Holder, msg, sig)
+	logger.Printf("holder: solana.VerifyMessageSignature(%s, %x) => %v", tx.TransactionHash, sig, err)
+	if err != nil {
+		if tx.CreatedAt.Add(safe.Timelock).After(req.CreatedAt) {
+			return node.failRequest(ctx, req, "")
+		}
+		msg = st.CosignerMessage(safe.Observer)
+		err = solana.VerifyMessageSignature(safe.Observer, msg, sig)
+		logger.Printf("observer: solana.VerifyMessageSignature(%s, %x) => %v", tx.TransactionHash, sig, err)
+		if err != nil {
+			return node.failRequest(ctx, req, "")
+		}
+	}
+	st.HolderSignature = sig
+
+	sr := &store.SignatureRequest{
+		TransactionHash: tx.TransactionHash,
+		InputIndex:      0,
+		Signer:          safe.Signer,
+		Curve:           req.Curve,
+		Message:         hex.EncodeToString(msg),
+		State:           common.RequestStateInitial,
+		CreatedAt:       req.CreatedAt,
+		UpdatedAt:       req.CreatedAt,
+	}
+	sr.RequestId = common.UniqueId(req.Id, sr.Message)
+	txs := node.buildSignerSignRequests(ctx, req, []*store.SignatureRequest{sr}, safe.Path)
+	if len(txs) == 0 {
+		return node.failRequest(ctx, req, "")
+	}
+	err = node.store.WriteSignatureRequestsWithRequest(ctx, []*store.SignatureRequest{sr}, tx.TransactionHash, hex.EncodeToString(st.Marshal()), req, txs)
+	logger.Printf("store.WriteSignatureRequestsWithRequest(%s, %d, %v) => %v", tx.TransactionHash, 1, req, err)
+	if err != nil {
+		panic(err)
+	}
+	return txs, ""
+}
+
+func (node *Node) processSolanaSafeSignatureResponse(ctx context.Context, req *common.Request, safe *store.Safe, tx *store.Transaction, old *store.SignatureRequest) ([]*mtg.Transaction, string) {
+	if req.Role != common.RequestRoleSigner {
+		panic(req.Role)
+	}
+
+	sig := req.ExtraBytes()
+	msg := common.DecodeHexOrPanic(old.Message)
+	err := solana.VerifyMessageSignature(safe.Signer, msg, sig)
+	logger.Printf("solana.VerifyMessageSignature(%v) => %v", req, err)
+	if err != nil {
+		return node.failRequest(ctx, req, "")
+	}
+	err = node.store.FinishSignatureRequest(ctx, req)
+	logger.Printf("store.FinishSignatureRequest(%s) => %v", req.Id, err)
+	if err != nil {
+		panic(fmt.Errorf("store.FinishSignatureRequest(%s) => %v", req.Id, err))
+	}
+
+	b := common.DecodeHexOrPanic(tx.RawTransaction)
+	st, err := solana.UnmarshalSafeTransaction(b)
+	if err != nil {
+		panic(fmt.Errorf("solana.UnmarshalSafeTransaction(%s) => %v", tx.RawTransaction, err))
+	}
+	st.Signature = sig
+	if !st.IsFullySigned() {
+		panic(tx.TransactionHash)
+	}
+
+	sbm, err := node.store.ReadAllEthereumTokenBalancesMap(ctx, safe.Address)
+	logger.Printf("store.ReadAllEthereumTokenBalancesMap(%s) => %v %v", safe.Address, sbm, err)
+	if err != nil {
+		panic(err)
+	}
+	outputs, err := st.ExtractOutputs()
+	if err != nil {
+		panic(err)
+	}
+	balance := sbm[solana.SystemProgramAddress]
+	if balance == nil {
+		return node.failRequest(ctx, req, "")
+	}
+	for _, o := range outputs {
+		amt := decimal.RequireFromString(o.Amount).Shift(solana.ValuePrecision).BigInt()
+		closeBalance := new(big.Int).Sub(balance.BigBalance(), amt)
+		if closeBalance.Sign() < 0 {
+			logger.Printf("safe %s close balance %d lower than 0", safe.Address, closeBalance)
+			return node.failRequest(ctx, req, "")
+		}
+		balance.UpdateBalance(new(big.Int).Neg(amt))
+	}
+
+	stx := node.buildStorageTransaction(ctx, req, []byte(common.Base91Encode(st.Marshal())))
+	if stx == nil {
+		return node.failRequest(ctx, req, "")
+	}
+	txs := []*mtg.Transaction{stx}
+
+	id := common.UniqueId(old.TransactionHash, stx.TraceId)
+	typ := byte(common.ActionSolanaSafeApproveTransaction)
+	crv := common.SafeChainCurve(safe.Chain)
+	t := node.buildObserverResponseWithStorageTraceId(ctx, id, req.Output, typ, crv, stx.TraceId)
+	if t == nil {
+		return node.failRequest(ctx, req, "")
+	}
+	txs = append(txs, t)
+
+	raw := hex.EncodeToString(st.Marshal())
+	err = node.store.FinishTransactionSignaturesWithRequest(ctx, old.TransactionHash, raw, req, 0, safe, sbm, nil, txs)
+	logger.Printf("store.FinishTransactionSignaturesWithRequest(%s, %s, %v) => %v", old.TransactionHash, raw, req, err)
+	if err != nil {
+		panic(err)
+	}
+	return txs, ""
+}
@@ -162,3 +162,160 @@ func (s *SQLite3Store) ReadAllEthereumTokenBalancesMap(ctx context.Context, addr
 	}
 	return sbm, nil
 }
+
+type NFTBalance struct {
+	Address      string
+	AssetAddress string
+	TokenId      string
+	TokenType    int
+	balance      string
+	LatestTxHash string
+	UpdatedAt    time.Time
+}
+
+func NFTBalanceKey(assetAddress, tokenId string) string {
+	return fmt.Sprintf("%s:%s", assetAddress, tokenId)
+}
+
+func (nb *NFTBalance) UpdateBalance(change *big.Int) {
+	balance := new(big.Int).Add(nb.BigBalance(), change)
+	if balance.Sign() < 0 {
+		panic(change.String())
+	}
+	nb.balance = balance.String()
+}
+
+func (nb *NFTBalance) BigBalance() *big.Int {
+	b, ok := new(big.Int).SetString(nb.balance, 10)
+	if !ok || b.Sign() < 0 {
+		panic(nb.balance)
+	}
+	return b
+}
+
+func (nb *NFTBalance) BigTokenId() *big.Int {
+	id, ok := new(big.Int).SetString(nb.TokenId, 10)
+	if !ok || id.Sign() < 0 {
+		panic(nb.TokenId)
+	}
+	return id
+}
+
+func (s *SQLite3Store) CreateNFTBalanceDepositFromRequest(ctx context.Context, safe *Safe, nb *NFTBalance, assetId, txHash string, index int64, amount *big.Int, sender string, req *common.Request) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = s.createOrUpdateNFTBalance(ctx, tx, nb)
+	if err != nil {
+		return err
+	}
+
+	vals := []any{txHash, index, assetId, amount.String(), nb.Address, sender, common.RequestStateDone, safe.Chain, safe.Holder, common.ActionObserverHolderDeposit, req.CreatedAt, req.CreatedAt}
+	err = s.execOne(ctx, tx, buildInsertionSQL("deposits", depositsCols), vals...)
+	if err != nil {
+		return fmt.Errorf("INSERT deposits %v", err)
+	}
+
+	err = s.execOne(ctx, tx, "UPDATE requests SET state=?, updated_at=? WHERE request_id=?", common.RequestStateDone, time.Now().UTC(), req.Id)
+	if err != nil {
+		return fmt.Errorf("UPDATE requests %v", err)
+	}
+
+	err = s.writeActionResult(ctx, tx, req.Output.OutputId, "", nil, req.Id)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLite3Store) createOrUpdateNFTBalance(ctx context.Context, tx *sql.Tx, nb *NFTBalance) error {
+	existed, err := s.checkExistence(ctx, tx, "SELECT balance FROM nft_balances WHERE address=? AND asset_address=? AND token_id=?", nb.Address, nb.AssetAddress, nb.TokenId)
+	if err != nil {
+		return err
+	} else if !existed {
+		cols := []string{"address", "asset_address", "token_id", "token_type", "balance", "latest_tx_hash", "updated_at"}
+		vals := []any{nb.Address, nb.AssetAddress, nb.TokenId, nb.TokenType, nb.balance, "", time.Now().UTC()}
+		err = s.execOne(ctx, tx, buildInsertionSQL("nft_balances", cols), vals...)
+		if err != nil {
+			return fmt.Errorf("INSERT nft_balances %v", err)
+		}
+	} else {
+		err = s.execOne(ctx, tx, "UPDATE nft_balances SET balance=?, updated_at=? WHERE address=? AND asset_address=? AND token_id=? AND token_type=?",
+			nb.balance, time.Now().UTC(), nb.Address, nb.AssetAddress, nb.TokenId, nb.TokenType)
+		if err != nil {
+			return fmt.Errorf("UPDATE nft_balances %v", err)
+		}
+	}
+
+	return nil
+}
+
+func (s *SQLite3Store) ReadNFTBalance(ctx context.Context, address, assetAddress, tokenId string, tokenType int) (*NFTBalance, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := "SELECT address,asset_address,token_id,token_type,balance,latest_tx_hash,updated_at FROM nft_balances WHERE address=? AND asset_address=? AND token_id=?"
+	row := tx.QueryRowContext(ctx, query, address, assetAddress, tokenId)
+
+	var nb NFTBalance
+	err = row.Scan(&nb.Address, &nb.AssetAddress, &nb.TokenId, &nb.TokenType, &nb.balance, &nb.LatestTxHash, &nb.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return &NFTBalance{
+			Address:      address,
+			AssetAddress: assetAddress,
+			TokenId:      tokenId,
+			TokenType:    tokenType,
+			balance:      "0",
+			LatestTxHash: "",
+			UpdatedAt:    time.Now().UTC(),
+		}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if nb.TokenType != tokenType {
+		panic(nb.TokenId)
+	}
+	return &nb, nil
+}
+
+// only the tokens still owned by the safe are listed
+func (s *SQLite3Store) ReadAllNFTBalances(ctx context.Context, address string) ([]*NFTBalance, error) {
+	query := "SELECT address,asset_address,token_id,token_type,balance,latest_tx_hash,updated_at FROM nft_balances WHERE address=? AND balance<>'0'"
+	rows, err := s.db.QueryContext(ctx, query, address)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var nbs []*NFTBalance
+	for rows.Next() {
+		var b NFTBalance
+		err = rows.Scan(&b.Address, &b.AssetAddress, &b.TokenId, &b.TokenType, &b.balance, &b.LatestTxHash, &b.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		nbs = append(nbs, &b)
+	}
+	return nbs, nil
+}
+
+func (s *SQLite3Store) ReadAllNFTBalancesMap(ctx context.Context, address string) (map[string]*NFTBalance, error) {
+	nbs, err := s.ReadAllNFTBalances(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+	nbm := make(map[string]*NFTBalance, len(nbs))
+	for _, nb := range nbs {
+		nbm[NFTBalanceKey(nb.AssetAddress, nb.TokenId)] = nb
+	}
+	return nbm, nil
+}
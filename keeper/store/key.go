@@ -110,11 +110,16 @@ func (s *SQLite3Store) AssignSignerAndObserverToHolder(ctx context.Context, req
 		panic(req.Holder)
 	}
 
-	signer, err = readKeyWithRoleAndCurve(ctx, tx, common.RequestRoleSigner, common.NormalizeCurve(req.Curve), maturity, "")
+	signerCurve, observerCurve := common.NormalizeCurve(req.Curve), common.NormalizeCurve(req.Curve)
+	if signerCurve == common.CurveSecp256k1SchnorrBitcoin {
+		// taproot safes share the observer keys with other bitcoin safes
+		observerCurve = common.CurveSecp256k1ECDSABitcoin
+	}
+	signer, err = readKeyWithRoleAndCurve(ctx, tx, common.RequestRoleSigner, signerCurve, maturity, "")
 	if err != nil {
 		return "", "", err
 	}
-	observer, err = readKeyWithRoleAndCurve(ctx, tx, common.RequestRoleObserver, common.NormalizeCurve(req.Curve), maturity, observerPref)
+	observer, err = readKeyWithRoleAndCurve(ctx, tx, common.RequestRoleObserver, observerCurve, maturity, observerPref)
 	if err != nil {
 		return "", "", err
 	}
@@ -123,12 +128,12 @@ func (s *SQLite3Store) AssignSignerAndObserverToHolder(ctx context.Context, req
 	}
 
 	err = s.execOne(ctx, tx, "UPDATE keys SET holder=?, updated_at=? WHERE public_key=? AND holder IS NULL AND role=? AND curve=?",
-		req.Holder, req.CreatedAt, signer, common.RequestRoleSigner, common.NormalizeCurve(req.Curve))
+		req.Holder, req.CreatedAt, signer, common.RequestRoleSigner, signerCurve)
 	if err != nil {
 		return "", "", fmt.Errorf("UPDATE keys %v", err)
 	}
 	err = s.execOne(ctx, tx, "UPDATE keys SET holder=?, updated_at=? WHERE public_key=? AND holder IS NULL AND role=? AND curve=?",
-		req.Holder, req.CreatedAt, observer, common.RequestRoleObserver, common.NormalizeCurve(req.Curve))
+		req.Holder, req.CreatedAt, observer, common.RequestRoleObserver, observerCurve)
 	if err != nil {
 		return "", "", fmt.Errorf("UPDATE keys %v", err)
 	}
@@ -0,0 +1,116 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/MixinNetwork/safe/apps/mixin"
+	"github.com/MixinNetwork/safe/common"
+	"github.com/MixinNetwork/trusted-group/mtg"
+)
+
+func (s *SQLite3Store) WriteMixinOutputFromRequest(ctx context.Context, safe *Safe, utxo *mixin.Input, req *common.Request, assetId, sender string, txs []*mtg.Transaction) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	cols := []string{"transaction_hash", "output_index", "address", "asset_id", "amount", "mask", "state", "spent_by", "request_id", "created_at", "updated_at"}
+	vals := []any{utxo.TransactionHash, utxo.Index, safe.Address, assetId, utxo.Amount, utxo.Mask, common.RequestStateInitial, nil, req.Id, req.CreatedAt, req.CreatedAt}
+	err = s.execOne(ctx, tx, buildInsertionSQL("mixin_outputs", cols), vals...)
+	if err != nil {
+		return fmt.Errorf("INSERT mixin_outputs %v", err)
+	}
+
+	vals = []any{utxo.TransactionHash, utxo.Index, assetId, utxo.Amount, safe.Address, sender, common.RequestStateDone, safe.Chain, safe.Holder, common.ActionObserverHolderDeposit, req.CreatedAt, req.CreatedAt}
+	err = s.execOne(ctx, tx, buildInsertionSQL("deposits", depositsCols), vals...)
+	if err != nil {
+		return fmt.Errorf("INSERT deposits %v", err)
+	}
+
+	err = s.execOne(ctx, tx, "UPDATE requests SET state=?, updated_at=? WHERE request_id=?", common.RequestStateDone, time.Now().UTC(), req.Id)
+	if err != nil {
+		return fmt.Errorf("UPDATE requests %v", err)
+	}
+
+	err = s.writeActionResult(ctx, tx, req.Output.OutputId, "", txs, req.Id)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLite3Store) ReadMixinUTXO(ctx context.Context, transactionHash string, index int) (*mixin.Input, string, string, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, "", "", err
+	}
+	defer tx.Rollback()
+
+	input := &mixin.Input{
+		TransactionHash: transactionHash,
+		Index:           uint32(index),
+	}
+	query := "SELECT address,amount,mask,spent_by FROM mixin_outputs WHERE transaction_hash=? AND output_index=?"
+	row := tx.QueryRowContext(ctx, query, transactionHash, index)
+
+	var address string
+	var spent sql.NullString
+	err = row.Scan(&address, &input.Amount, &input.Mask, &spent)
+	if err == sql.ErrNoRows {
+		return nil, "", "", nil
+	} else if err != nil {
+		return nil, "", "", err
+	}
+	return input, address, spent.String, nil
+}
+
+func (s *SQLite3Store) ListAllMixinUTXOsForHolderAndAsset(ctx context.Context, holder, assetId string) ([]*mixin.Input, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	safe, err := s.readSafe(ctx, tx, holder)
+	if err != nil {
+		return nil, err
+	}
+
+	cols := strings.Join([]string{"transaction_hash", "output_index", "amount", "mask"}, ",")
+	query := fmt.Sprintf("SELECT %s FROM mixin_outputs WHERE address=? AND asset_id=? AND state=? ORDER BY created_at ASC, request_id ASC", cols)
+	rows, err := tx.QueryContext(ctx, query, safe.Address, assetId, common.RequestStateInitial)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var inputs []*mixin.Input
+	for rows.Next() {
+		var input mixin.Input
+		err = rows.Scan(&input.TransactionHash, &input.Index, &input.Amount, &input.Mask)
+		if err != nil {
+			return nil, err
+		}
+		inputs = append(inputs, &input)
+	}
+	return inputs, nil
+}
+
+func (s *SQLite3Store) ReadUnspentMixinUtxoCountForSafe(ctx context.Context, address, assetId string) (int, error) {
+	query := "SELECT COUNT(*) FROM mixin_outputs WHERE address=? AND asset_id=? AND state IN (?, ?)"
+	row := s.db.QueryRowContext(ctx, query, address, assetId, common.RequestStateInitial, common.RequestStatePending)
+	var count int
+	err := row.Scan(&count)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return count, err
+}
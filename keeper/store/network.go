@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"math/big"
 	"strings"
 	"time"
 
@@ -40,9 +41,18 @@ type OperationParams struct {
 	CreatedAt            time.Time
 }
 
+type RelayGasPrice struct {
+	RequestId    string
+	Chain        byte
+	AssetAddress string
+	GasPrice     *big.Int
+	CreatedAt    time.Time
+}
+
 var assetCols = []string{"asset_id", "mixin_id", "asset_key", "symbol", "name", "decimals", "chain", "created_at"}
 var infoCols = []string{"request_id", "chain", "fee", "height", "hash", "created_at"}
 var paramsCols = []string{"request_id", "chain", "price_asset", "price_amount", "transaction_minimum", "created_at"}
+var relayGasPriceCols = []string{"request_id", "chain", "asset_address", "gas_price", "created_at"}
 
 func (s *SQLite3Store) ReadNetworkInfo(ctx context.Context, id string) (*NetworkInfo, error) {
 	query := fmt.Sprintf("SELECT %s FROM network_infos WHERE request_id=?", strings.Join(infoCols, ","))
@@ -149,6 +159,55 @@ func (s *SQLite3Store) WriteOperationParamsFromRequest(ctx context.Context, para
 	return tx.Commit()
 }
 
+func (s *SQLite3Store) ReadLatestRelayGasPrice(ctx context.Context, chain byte, assetAddress string, offset time.Time) (*RelayGasPrice, error) {
+	query := fmt.Sprintf("SELECT %s FROM relay_gas_prices WHERE chain=? AND asset_address=? AND created_at<=? ORDER BY created_at DESC, request_id DESC LIMIT 1", strings.Join(relayGasPriceCols, ","))
+	row := s.db.QueryRowContext(ctx, query, chain, assetAddress, offset)
+
+	var p RelayGasPrice
+	var price string
+	err := row.Scan(&p.RequestId, &p.Chain, &p.AssetAddress, &price, &p.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	p.GasPrice, _ = new(big.Int).SetString(price, 10)
+	return &p, nil
+}
+
+func (s *SQLite3Store) WriteRelayGasPriceFromRequest(ctx context.Context, price *RelayGasPrice, req *common.Request) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	existed, err := s.checkExistence(ctx, tx, "SELECT request_id FROM requests WHERE request_id=? AND state=?", price.RequestId, common.RequestStateDone)
+	if err != nil || existed {
+		return err
+	}
+
+	vals := []any{price.RequestId, price.Chain, price.AssetAddress, price.GasPrice.String(), price.CreatedAt}
+	err = s.execOne(ctx, tx, buildInsertionSQL("relay_gas_prices", relayGasPriceCols), vals...)
+	if err != nil {
+		return fmt.Errorf("INSERT relay_gas_prices %v", err)
+	}
+	err = s.execOne(ctx, tx, "UPDATE requests SET state=?, updated_at=? WHERE request_id=?",
+		common.RequestStateDone, time.Now().UTC(), price.RequestId)
+	if err != nil {
+		return fmt.Errorf("UPDATE requests %v", err)
+	}
+
+	err = s.writeActionResult(ctx, tx, req.Output.OutputId, "", nil, price.RequestId)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
 func (s *SQLite3Store) ReadAssetMeta(ctx context.Context, id string) (*Asset, error) {
 	query := fmt.Sprintf("SELECT %s FROM assets WHERE asset_id=? OR mixin_id=?", strings.Join(assetCols, ","))
 	row := s.db.QueryRowContext(ctx, query, id, id)
@@ -177,6 +177,34 @@ func (s *SQLite3Store) WriteUnfinishedSafe(ctx context.Context, safe *Safe) erro
 	return tx.Commit()
 }
 
+// the tron permission update transaction expires in one day, so it is only
+// built when the safe approved, together with the unfinished safe
+func (s *SQLite3Store) WriteUnfinishedSafeWithTransaction(ctx context.Context, safe *Safe, trx *Transaction) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if safe.State != common.RequestStatePending {
+		panic(safe.State)
+	}
+	err = s.execOne(ctx, tx, buildInsertionSQL("safes", safeCols), safe.values()...)
+	if err != nil {
+		return fmt.Errorf("INSERT safes %v", err)
+	}
+
+	vals := []any{trx.TransactionHash, trx.RawTransaction, trx.Holder, trx.Chain, trx.AssetId, trx.State, trx.Data, trx.RequestId, trx.CreatedAt, trx.UpdatedAt}
+	err = s.execOne(ctx, tx, buildInsertionSQL("transactions", transactionCols), vals...)
+	if err != nil {
+		return fmt.Errorf("INSERT transactions %v", err)
+	}
+	return tx.Commit()
+}
+
 func (s *SQLite3Store) WriteSafeWithRequest(ctx context.Context, safe *Safe, txs []*mtg.Transaction, req *common.Request) error {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
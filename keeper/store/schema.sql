@@ -65,6 +65,19 @@ CREATE INDEX IF NOT EXISTS operation_params_by_chain_created ON operation_params
 
 
 
+CREATE TABLE IF NOT EXISTS relay_gas_prices (
+  request_id           VARCHAR NOT NULL,
+  chain                INTEGER NOT NULL,
+  asset_address        VARCHAR NOT NULL,
+  gas_price            VARCHAR NOT NULL,
+  created_at           TIMESTAMP NOT NULL,
+  PRIMARY KEY ('request_id')
+);
+
+CREATE INDEX IF NOT EXISTS relay_gas_prices_by_chain_address_created ON relay_gas_prices(chain, asset_address, created_at);
+
+
+
 
 CREATE TABLE IF NOT EXISTS assets (
   asset_id      VARCHAR NOT NULL,
@@ -184,6 +197,29 @@ CREATE INDEX IF NOT EXISTS bitcoin_outputs_by_address_state_created ON bitcoin_o
 
 
 
+CREATE TABLE IF NOT EXISTS mixin_outputs (
+  transaction_hash   VARCHAR NOT NULL,
+  output_index       INTEGER NOT NULL,
+  address            VARCHAR NOT NULL,
+  asset_id           VARCHAR NOT NULL,
+  amount             VARCHAR NOT NULL,
+  mask               VARCHAR NOT NULL,
+  state              INTEGER NOT NULL,
+  spent_by           VARCHAR,
+  request_id         VARCHAR NOT NULL,
+  created_at         TIMESTAMP NOT NULL,
+  updated_at         TIMESTAMP NOT NULL,
+  PRIMARY KEY ('transaction_hash', 'output_index')
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS mixin_outputs_by_request_id ON mixin_outputs(request_id);
+CREATE INDEX IF NOT EXISTS mixin_outputs_by_address_asset_state_created ON mixin_outputs(address, asset_id, state, created_at);
+
+
+
+
+
+
 CREATE TABLE IF NOT EXISTS ethereum_balances (
   address            VARCHAR NOT NULL,
   asset_id           VARCHAR NOT NULL,
@@ -197,6 +233,19 @@ CREATE TABLE IF NOT EXISTS ethereum_balances (
 
 
 
+CREATE TABLE IF NOT EXISTS nft_balances (
+  address            VARCHAR NOT NULL,
+  asset_address      VARCHAR NOT NULL,
+  token_id           VARCHAR NOT NULL,
+  token_type         INTEGER NOT NULL,
+  balance            VARCHAR NOT NULL,
+  latest_tx_hash     VARCHAR NOT NULL,
+  updated_at         TIMESTAMP NOT NULL,
+  PRIMARY KEY ('address', 'asset_address', 'token_id')
+);
+
+
+
 
 
 
@@ -148,7 +148,7 @@ func (s *SQLite3Store) FinishSignatureRequest(ctx context.Context, req *common.R
 	return tx.Commit()
 }
 
-func (s *SQLite3Store) FinishTransactionSignaturesWithRequest(ctx context.Context, transactionHash, psbt string, req *common.Request, num int64, safe *Safe, bm map[string]*SafeBalance, txs []*mtg.Transaction) error {
+func (s *SQLite3Store) FinishTransactionSignaturesWithRequest(ctx context.Context, transactionHash, psbt string, req *common.Request, num int64, safe *Safe, bm map[string]*SafeBalance, nbm map[string]*NFTBalance, txs []*mtg.Transaction) error {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
@@ -171,10 +171,11 @@ func (s *SQLite3Store) FinishTransactionSignaturesWithRequest(ctx context.Contex
 	}
 
 	if transactionHasOutputs(safe.Chain) {
-		update := "UPDATE bitcoin_outputs SET state=?, updated_at=? WHERE spent_by=?"
+		table := transactionOutputsTable(safe.Chain)
+		update := fmt.Sprintf("UPDATE %s SET state=?, updated_at=? WHERE spent_by=?", table)
 		err = s.execMultiple(ctx, tx, num, update, common.RequestStateDone, req.CreatedAt, transactionHash)
 		if err != nil {
-			return fmt.Errorf("UPDATE bitcoin_outputs %v", err)
+			return fmt.Errorf("UPDATE %s %v", table, err)
 		}
 	}
 	if transactionHasBalance(safe.Chain) {
@@ -185,6 +186,12 @@ func (s *SQLite3Store) FinishTransactionSignaturesWithRequest(ctx context.Contex
 			}
 		}
 	}
+	for _, nb := range nbm {
+		err = s.createOrUpdateNFTBalance(ctx, tx, nb)
+		if err != nil {
+			return err
+		}
+	}
 
 	err = s.execOne(ctx, tx, "UPDATE safes SET nonce=?, updated_at=? WHERE holder=? AND nonce=?",
 		safe.Nonce+1, time.Now().UTC(), safe.Holder, safe.Nonce)
@@ -9,6 +9,9 @@ import (
 
 	"github.com/MixinNetwork/safe/apps/bitcoin"
 	"github.com/MixinNetwork/safe/apps/ethereum"
+	"github.com/MixinNetwork/safe/apps/mixin"
+	"github.com/MixinNetwork/safe/apps/solana"
+	"github.com/MixinNetwork/safe/apps/tron"
 	"github.com/MixinNetwork/safe/common"
 	"github.com/MixinNetwork/trusted-group/mtg"
 )
@@ -48,7 +51,7 @@ func TransactionInputsFromRawTransaction(trx *Transaction) []*TransactionInput {
 	b := common.DecodeHexOrPanic(trx.RawTransaction)
 	var inputs []*TransactionInput
 	switch trx.Chain {
-	case bitcoin.ChainBitcoin, bitcoin.ChainLitecoin:
+	case bitcoin.ChainBitcoin, bitcoin.ChainLitecoin, bitcoin.ChainBitcoinCash:
 		psbt, _ := bitcoin.UnmarshalPartiallySignedTransaction(b)
 		for _, in := range psbt.UnsignedTx.TxIn {
 			pop := in.PreviousOutPoint
@@ -57,6 +60,14 @@ func TransactionInputsFromRawTransaction(trx *Transaction) []*TransactionInput {
 				Index: pop.Index,
 			})
 		}
+	case mixin.ChainMixinKernel:
+		st, _ := mixin.UnmarshalSafeTransaction(b)
+		for _, in := range st.Transaction.Inputs {
+			inputs = append(inputs, &TransactionInput{
+				Hash:  in.Hash.String(),
+				Index: uint32(in.Index),
+			})
+		}
 	default:
 		panic(trx.Chain)
 	}
@@ -203,11 +214,12 @@ func (s *SQLite3Store) writeTransactionWithRequest(ctx context.Context, tx *sql.
 	if !transactionHasOutputs(trx.Chain) {
 		return nil
 	}
-	query := "UPDATE bitcoin_outputs SET state=?, spent_by=?, updated_at=? WHERE transaction_hash=? AND output_index=?"
+	table := transactionOutputsTable(trx.Chain)
+	query := fmt.Sprintf("UPDATE %s SET state=?, spent_by=?, updated_at=? WHERE transaction_hash=? AND output_index=?", table)
 	for _, utxo := range utxos {
 		err = s.execOne(ctx, tx, query, utxoState, trx.TransactionHash, trx.UpdatedAt, utxo.Hash, utxo.Index)
 		if err != nil {
-			return fmt.Errorf("UPDATE bitcoin_outputs %v", err)
+			return fmt.Errorf("UPDATE %s %v", table, err)
 		}
 	}
 	return nil
@@ -225,12 +237,13 @@ func (s *SQLite3Store) RevokeTransactionWithRequest(ctx context.Context, trx *Tr
 
 	if transactionHasOutputs(trx.Chain) {
 		inputs := TransactionInputsFromRawTransaction(trx)
-		update := "UPDATE bitcoin_outputs SET state=?, spent_by=?, updated_at=? WHERE transaction_hash=? AND output_index=? AND spent_by=?"
-		query := "SELECT address FROM bitcoin_outputs WHERE transaction_hash=? AND output_index=?"
+		table := transactionOutputsTable(trx.Chain)
+		update := fmt.Sprintf("UPDATE %s SET state=?, spent_by=?, updated_at=? WHERE transaction_hash=? AND output_index=? AND spent_by=?", table)
+		query := fmt.Sprintf("SELECT address FROM %s WHERE transaction_hash=? AND output_index=?", table)
 		for _, in := range inputs {
 			err = s.execOne(ctx, tx, update, common.RequestStateInitial, nil, req.CreatedAt, in.Hash, in.Index, trx.TransactionHash)
 			if err != nil {
-				return fmt.Errorf("UPDATE bitcoin_outputs %v", err)
+				return fmt.Errorf("UPDATE %s %v", table, err)
 			}
 
 			var receiver string
@@ -264,7 +277,7 @@ func (s *SQLite3Store) RevokeTransactionWithRequest(ctx context.Context, trx *Tr
 	return tx.Commit()
 }
 
-func (s *SQLite3Store) FailTransactionWithRequest(ctx context.Context, trx *Transaction, safe *Safe, req *common.Request, bm map[string]*SafeBalance, txs []*mtg.Transaction) error {
+func (s *SQLite3Store) FailTransactionWithRequest(ctx context.Context, trx *Transaction, safe *Safe, req *common.Request, bm map[string]*SafeBalance, nbm map[string]*NFTBalance, txs []*mtg.Transaction) error {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
@@ -298,6 +311,12 @@ func (s *SQLite3Store) FailTransactionWithRequest(ctx context.Context, trx *Tran
 			return err
 		}
 	}
+	for _, nb := range nbm {
+		err = s.createOrUpdateNFTBalance(ctx, tx, nb)
+		if err != nil {
+			return err
+		}
+	}
 
 	err = s.writeActionResult(ctx, tx, req.Output.OutputId, "", txs, req.Id)
 	if err != nil {
@@ -321,21 +340,36 @@ func (s *SQLite3Store) readTransaction(ctx context.Context, tx *sql.Tx, transact
 
 func transactionHasOutputs(chain byte) bool {
 	switch chain {
-	case bitcoin.ChainBitcoin, bitcoin.ChainLitecoin:
+	case bitcoin.ChainBitcoin, bitcoin.ChainLitecoin, bitcoin.ChainBitcoinCash, mixin.ChainMixinKernel:
 		return true
-	case ethereum.ChainEthereum, ethereum.ChainPolygon:
+	case solana.ChainSolana, tron.ChainTron:
 		return false
-	default:
-		panic(chain)
 	}
+	if ethereum.IsEvmChain(chain) {
+		return false
+	}
+	panic(chain)
 }
 
 func transactionHasBalance(chain byte) bool {
 	switch chain {
-	case bitcoin.ChainBitcoin, bitcoin.ChainLitecoin:
+	case bitcoin.ChainBitcoin, bitcoin.ChainLitecoin, bitcoin.ChainBitcoinCash, mixin.ChainMixinKernel:
 		return false
-	case ethereum.ChainEthereum, ethereum.ChainPolygon:
+	case solana.ChainSolana, tron.ChainTron:
+		return true
+	}
+	if ethereum.IsEvmChain(chain) {
 		return true
+	}
+	panic(chain)
+}
+
+func transactionOutputsTable(chain byte) string {
+	switch chain {
+	case bitcoin.ChainBitcoin, bitcoin.ChainLitecoin, bitcoin.ChainBitcoinCash:
+		return "bitcoin_outputs"
+	case mixin.ChainMixinKernel:
+		return "mixin_outputs"
 	default:
 		panic(chain)
 	}
@@ -0,0 +1,627 @@
+package keeper
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	"github.com/MixinNetwork/mixin/logger"
+	"github.com/MixinNetwork/safe/apps/tron"
+	"github.com/MixinNetwork/safe/common"
+	"github.com/MixinNetwork/safe/common/abi"
+	"github.com/MixinNetwork/safe/keeper/store"
+	"github.com/MixinNetwork/trusted-group/mtg"
+	"github.com/gofrs/uuid/v5"
+	"github.com/shopspring/decimal"
+)
+
+// the safe address is the signer key, and its owner permission is updated to
+// the signer with the holder or observer keys when the safe approved. the
+// keeper only requests the signer signature after the holder approval, or the
+// observer approval after the timelock. the transaction expires in one day,
+// so the timelock must be shorter than that for the observer recovery.
+
+func tronDefaultDerivationPath() []byte {
+	return []byte{0, 0, 0, 0}
+}
+
+func (node *Node) processTronSafeProposeAccount(ctx context.Context, req *common.Request) ([]*mtg.Transaction, string) {
+	if req.Role != common.RequestRoleHolder {
+		panic(req.Role)
+	}
+	rce := req.ExtraBytes()
+	ver, _ := node.group.ReadKernelTransactionUntilSufficient(ctx, req.MixinHash.String())
+	if len(rce) == 32 && len(ver.References) == 1 && ver.References[0].String() == req.ExtraHEX {
+		stx, _ := node.group.ReadKernelTransactionUntilSufficient(ctx, ver.References[0].String())
+		rce = stx.Extra
+	}
+	arp, err := req.ParseMixinRecipient(ctx, node.mixin, rce)
+	logger.Printf("req.ParseMixinRecipient(%v) => %v %v", req, arp, err)
+	if err != nil {
+		return node.failRequest(ctx, req, "")
+	}
+	if arp.Timelock >= tron.TransactionExpiration {
+		return node.failRequest(ctx, req, "")
+	}
+	chain := common.SafeCurveChain(req.Curve)
+
+	plan, err := node.store.ReadLatestOperationParams(ctx, chain, req.CreatedAt)
+	logger.Printf("store.ReadLatestOperationParams(%d) => %v %v", chain, plan, err)
+	if err != nil {
+		panic(fmt.Errorf("node.ReadLatestOperationParams(%d) => %v", chain, err))
+	} else if plan == nil || !plan.OperationPriceAmount.IsPositive() {
+		return node.refundAndFailRequest(ctx, req, arp.Receivers, int(arp.Threshold))
+	}
+	if req.AssetId != plan.OperationPriceAsset {
+		return node.failRequest(ctx, req, "")
+	}
+	if req.Amount.Cmp(plan.OperationPriceAmount) < 0 {
+		return node.failRequest(ctx, req, "")
+	}
+	safe, err := node.store.ReadSafe(ctx, req.Holder)
+	if err != nil {
+		panic(fmt.Errorf("store.ReadSafe(%s) => %v", req.Holder, err))
+	} else if safe != nil {
+		return node.failRequest(ctx, req, "")
+	}
+	old, err := node.store.ReadSafeProposal(ctx, req.Id)
+	if err != nil {
+		panic(fmt.Errorf("store.ReadSafeProposal(%s) => %v", req.Id, err))
+	} else if old != nil {
+		return node.failRequest(ctx, req, "")
+	}
+
+	signer, observer, err := node.store.AssignSignerAndObserverToHolder(ctx, req, SafeKeyBackupMaturity, arp.Observer)
+	logger.Printf("store.AssignSignerAndObserverToHolder(%s) => %s %s %v", req.Holder, signer, observer, err)
+	if err != nil {
+		panic(fmt.Errorf("store.AssignSignerAndObserverToHolder(%v) => %v", req, err))
+	}
+	if signer == "" || observer == "" {
+		return node.refundAndFailRequest(ctx, req, arp.Receivers, int(arp.Threshold))
+	}
+	if arp.Observer != "" && arp.Observer != observer {
+		panic(fmt.Errorf("store.AssignSignerAndObserverToHolder(%v) => %v %s", req, arp, observer))
+	}
+	if !common.CheckUnique(req.Holder, signer, observer) {
+		return node.refundAndFailRequest(ctx, req, arp.Receivers, int(arp.Threshold))
+	}
+	path := tronDefaultDerivationPath()
+
+	addr, err := tron.PublicKeyToAddress(signer)
+	logger.Verbosef("tron.PublicKeyToAddress(%s) => %s %v", signer, addr, err)
+	if err != nil {
+		panic(err)
+	}
+	old, err = node.store.ReadSafeProposalByAddress(ctx, addr)
+	if err != nil {
+		panic(fmt.Errorf("store.ReadSafeProposalByAddress(%s) => %v", addr, err))
+	} else if old != nil {
+		return node.failRequest(ctx, req, "")
+	}
+
+	extra := []byte(addr)
+	stx := node.buildStorageTransaction(ctx, req, []byte(common.Base91Encode(extra)))
+	if stx == nil {
+		return node.refundAndFailRequest(ctx, req, arp.Receivers, int(arp.Threshold))
+	}
+	txs := []*mtg.Transaction{stx}
+
+	typ := byte(common.ActionTronSafeProposeAccount)
+	crv := common.SafeChainCurve(chain)
+	t := node.buildObserverResponseWithStorageTraceId(ctx, req.Id, req.Output, typ, crv, stx.TraceId)
+	if t == nil {
+		return node.refundAndFailRequest(ctx, req, arp.Receivers, int(arp.Threshold))
+	}
+	txs = append(txs, t)
+
+	sp := &store.SafeProposal{
+		RequestId: req.Id,
+		Chain:     chain,
+		Holder:    req.Holder,
+		Signer:    signer,
+		Observer:  observer,
+		Timelock:  arp.Timelock,
+		Path:      hex.EncodeToString(path),
+		Address:   addr,
+		Extra:     extra,
+		Receivers: arp.Receivers,
+		Threshold: arp.Threshold,
+		CreatedAt: req.CreatedAt,
+		UpdatedAt: req.CreatedAt,
+	}
+	err = node.store.WriteSafeProposalWithRequest(ctx, sp, txs, req)
+	if err != nil {
+		panic(err)
+	}
+	return txs, ""
+}
+
+func (node *Node) processTronSafeApproveAccount(ctx context.Context, req *common.Request) ([]*mtg.Transaction, string) {
+	if req.Role != common.RequestRoleObserver {
+		panic(req.Role)
+	}
+	old, err := node.store.ReadSafe(ctx, req.Holder)
+	if err != nil {
+		panic(fmt.Errorf("store.ReadSafe(%s) => %v", req.Holder, err))
+	} else if old != nil {
+		return node.failRequest(ctx, req, "")
+	}
+	chain := common.SafeCurveChain(req.Curve)
+	safeAssetId := node.getBondAssetId(ctx, node.conf.PolygonKeeperDepositEntry, common.SafeTronChainId, req.Holder)
+
+	extra := req.ExtraBytes()
+	if len(extra) != 81 {
+		return node.failRequest(ctx, req, "")
+	}
+	rid, err := uuid.FromBytes(extra[:16])
+	if err != nil {
+		return node.failRequest(ctx, req, "")
+	}
+	sp, err := node.store.ReadSafeProposal(ctx, rid.String())
+	if err != nil {
+		panic(fmt.Errorf("store.ReadSafeProposal(%v) => %s %v", req, rid.String(), err))
+	} else if sp == nil {
+		return node.failRequest(ctx, req, "")
+	} else if sp.Holder != req.Holder {
+		return node.failRequest(ctx, req, "")
+	} else if sp.Chain != chain {
+		return node.failRequest(ctx, req, "")
+	}
+
+	ms := fmt.Sprintf("APPROVE:%s:%s", rid.String(), sp.Address)
+	err = tron.VerifyMessageSignature(req.Holder, []byte(ms), extra[16:])
+	logger.Printf("tron.VerifyMessageSignature(%v) => %v", req, err)
+	if err != nil {
+		return node.failRequest(ctx, req, "")
+	}
+
+	info, err := node.store.ReadLatestNetworkInfo(ctx, chain, req.CreatedAt)
+	logger.Printf("store.ReadLatestNetworkInfo(%d) => %v %v", chain, info, err)
+	if err != nil {
+		panic(fmt.Errorf("store.ReadLatestNetworkInfo(%d) => %v", chain, err))
+	} else if info == nil {
+		return node.failRequest(ctx, req, "")
+	}
+	block := &tron.ReferenceBlock{Height: info.Height, Hash: info.Hash}
+	st, err := tron.BuildPermissionUpdateTransaction(sp.Address, sp.Holder, sp.Signer, sp.Observer, block, req.CreatedAt)
+	logger.Printf("tron.BuildPermissionUpdateTransaction(%v) => %v %v", sp, st, err)
+	if err != nil {
+		panic(err)
+	}
+
+	safe := &store.Safe{
+		Holder:      sp.Holder,
+		Chain:       sp.Chain,
+		Signer:      sp.Signer,
+		Observer:    sp.Observer,
+		Timelock:    sp.Timelock,
+		Path:        sp.Path,
+		Address:     sp.Address,
+		Extra:       sp.Extra,
+		Receivers:   sp.Receivers,
+		Threshold:   sp.Threshold,
+		RequestId:   req.Id,
+		State:       SafeStatePending,
+		Nonce:       0,
+		SafeAssetId: safeAssetId,
+		CreatedAt:   req.CreatedAt,
+		UpdatedAt:   req.CreatedAt,
+	}
+	tx := &store.Transaction{
+		TransactionHash: st.Hash(),
+		RawTransaction:  hex.EncodeToString(st.Marshal()),
+		Holder:          req.Holder,
+		Chain:           chain,
+		AssetId:         common.SafeTronChainId,
+		State:           common.RequestStateInitial,
+		Data:            "",
+		RequestId:       req.Id,
+		CreatedAt:       req.CreatedAt,
+		UpdatedAt:       req.CreatedAt,
+	}
+	err = node.store.WriteUnfinishedSafeWithTransaction(ctx, safe, tx)
+	if err != nil {
+		panic(fmt.Errorf("store.WriteUnfinishedSafeWithTransaction(%v) => %v", safe, err))
+	}
+
+	sr := &store.SignatureRequest{
+		TransactionHash: tx.TransactionHash,
+		InputIndex:      0,
+		Signer:          sp.Signer,
+		Curve:           req.Curve,
+		Message:         st.Hash(),
+		State:           common.RequestStateInitial,
+		CreatedAt:       req.CreatedAt,
+		UpdatedAt:       req.CreatedAt,
+	}
+	sr.RequestId = common.UniqueId(req.Id, sr.Message)
+	txs := node.buildSignerSignRequests(ctx, req, []*store.SignatureRequest{sr}, safe.Path)
+	if len(txs) == 0 {
+		return node.failRequest(ctx, req, "")
+	}
+	err = node.store.WriteSignatureRequestsWithRequest(ctx, []*store.SignatureRequest{sr}, tx.TransactionHash, "", req, txs)
+	logger.Printf("store.WriteSignatureRequestsWithRequest(%s, %d, %v) => %v", tx.TransactionHash, 1, req, err)
+	if err != nil {
+		panic(err)
+	}
+	return txs, ""
+}
+
+func (node *Node) processTronSafeProposeTransaction(ctx context.Context, req *common.Request) ([]*mtg.Transaction, string) {
+	if req.Role != common.RequestRoleHolder {
+		panic(req.Role)
+	}
+	chain := common.SafeCurveChain(req.Curve)
+	safe, err := node.store.ReadSafe(ctx, req.Holder)
+	if err != nil {
+		panic(fmt.Errorf("store.ReadSafe(%s) => %v", req.Holder, err))
+	}
+	if safe == nil || safe.Chain != chain {
+		return node.failRequest(ctx, req, "")
+	}
+	if safe.State != SafeStateApproved {
+		return node.failRequest(ctx, req, "")
+	}
+
+	pendings, err := node.store.ReadUnfinishedTransactionsByHolder(ctx, safe.Holder)
+	logger.Printf("store.ReadUnfinishedTransactionsByHolder(%s) => %v %v", safe.Holder, len(pendings), err)
+	if len(pendings) > 0 {
+		return node.failRequest(ctx, req, "")
+	}
+
+	meta, err := node.fetchAssetMeta(ctx, req.AssetId)
+	logger.Printf("node.fetchAssetMeta(%s) => %v %v", req.AssetId, meta, err)
+	if err != nil {
+		panic(fmt.Errorf("node.fetchAssetMeta(%s) => %v", req.AssetId, err))
+	}
+	if meta.Chain != common.SafeChainPolygon {
+		return node.failRequest(ctx, req, "")
+	}
+	deployed, err := abi.CheckFactoryAssetDeployed(node.conf.PolygonRPC, meta.AssetKey)
+	logger.Printf("abi.CheckFactoryAssetDeployed(%s) => %v %v", meta.AssetKey, deployed, err)
+	if err != nil || deployed.Sign() <= 0 {
+		panic(fmt.Errorf("api.CheckFatoryAssetDeployed(%s) => %v", meta.AssetKey, err))
+	}
+	id := uuid.Must(uuid.FromBytes(deployed.Bytes()))
+	asset, err := node.fetchAssetMeta(ctx, id.String())
+	logger.Printf("node.fetchAssetMeta(%s) => %v %v", id.String(), asset, err)
+	if err != nil {
+		panic(fmt.Errorf("node.fetchAssetMeta(%s) => %v", id.String(), err))
+	}
+	if asset.Chain != common.SafeChainTron {
+		return node.failRequest(ctx, req, "")
+	}
+	token := tron.TronEmptyAddress
+	if id.String() != common.SafeTronChainId {
+		token = asset.AssetKey
+	}
+	if tron.GenerateAssetId(token) != id.String() {
+		return node.failRequest(ctx, req, "")
+	}
+
+	plan, err := node.store.ReadLatestOperationParams(ctx, safe.Chain, req.CreatedAt)
+	logger.Printf("store.ReadLatestOperationParams(%d) => %v %v", safe.Chain, plan, err)
+	if err != nil {
+		panic(fmt.Errorf("store.ReadLatestOperationParams(%d) => %v", safe.Chain, err))
+	} else if plan == nil || !plan.TransactionMinimum.IsPositive() {
+		return node.refundAndFailRequest(ctx, req, safe.Receivers, int(safe.Threshold))
+	}
+	if req.Amount.Cmp(plan.TransactionMinimum) < 0 {
+		return node.failRequest(ctx, req, "")
+	}
+
+	entry := node.fetchBondAssetReceiver(ctx, safe.Address, id.String())
+	safeAssetId := node.getBondAssetId(ctx, entry, id.String(), req.Holder)
+	logger.Printf("node.getBondAssetId(%s, %s, %s) => %s", entry, id.String(), req.Holder, safeAssetId)
+	if req.AssetId != safeAssetId {
+		panic(req.AssetId)
+	}
+
+	extra := req.ExtraBytes()
+	if len(extra) != 33 {
+		return node.failRequest(ctx, req, "")
+	}
+	// the observer recovery is the approval after timelock, not a new flag
+	if extra[0] != common.FlagProposeNormalTransaction {
+		return node.failRequest(ctx, req, "")
+	}
+	extra = extra[1:]
+
+	var proposal struct {
+		Outputs [][2]string `json:"outputs"`
+	}
+	ver, _ := node.group.ReadKernelTransactionUntilSufficient(ctx, req.MixinHash.String())
+	if len(ver.References) != 1 || ver.References[0].String() != hex.EncodeToString(extra) {
+		return node.failRequest(ctx, req, "")
+	}
+	ptx, _ := node.group.ReadKernelTransactionUntilSufficient(ctx, ver.References[0].String())
+	err = json.Unmarshal(ptx.Extra, &proposal)
+	if err != nil {
+		return node.failRequest(ctx, req, "")
+	}
+	// the tron transaction has only one contract, thus only one output
+	if len(proposal.Outputs) != 1 {
+		return node.failRequest(ctx, req, "")
+	}
+	receiver := proposal.Outputs[0][0]
+	_, err = tron.DecodeAddress(receiver)
+	logger.Printf("tron.DecodeAddress(%s) => %v", receiver, err)
+	if err != nil {
+		return node.failRequest(ctx, req, "")
+	}
+	amt, err := decimal.NewFromString(proposal.Outputs[0][1])
+	if err != nil || !amt.Equal(req.Amount) || amt.Cmp(plan.TransactionMinimum) < 0 {
+		return node.failRequest(ctx, req, "")
+	}
+	amount, err := tron.ParseAmount(amt.String(), int32(asset.Decimals))
+	if err != nil {
+		return node.failRequest(ctx, req, "")
+	}
+
+	// the tron balances share the same table with ethereum
+	balance, err := node.store.ReadEthereumBalance(ctx, safe.Address, id.String(), safeAssetId)
+	logger.Printf("store.ReadEthereumBalance(%s, %s) => %v %v", safe.Address, id.String(), balance, err)
+	if err != nil {
+		panic(err)
+	}
+	if balance.BigBalance().Cmp(amount) < 0 {
+		return node.refundAndFailRequest(ctx, req, safe.Receivers, int(safe.Threshold))
+	}
+
+	info, err := node.store.ReadLatestNetworkInfo(ctx, safe.Chain, req.CreatedAt)
+	logger.Printf("store.ReadLatestNetworkInfo(%d) => %v %v", safe.Chain, info, err)
+	if err != nil {
+		panic(fmt.Errorf("store.ReadLatestNetworkInfo(%d) => %v", safe.Chain, err))
+	} else if info == nil {
+		return node.refundAndFailRequest(ctx, req, safe.Receivers, int(safe.Threshold))
+	}
+	block := &tron.ReferenceBlock{Height: info.Height, Hash: info.Hash}
+	out := &tron.Output{TokenAddress: token, Destination: receiver, Amount: amount}
+	st, err := tron.BuildTransaction(safe.Address, out, block, req.CreatedAt)
+	logger.Printf("tron.BuildTransaction(%v) => %v %v", req, st, err)
+	if err != nil {
+		return node.failRequest(ctx, req, "")
+	}
+
+	extra = st.Marshal()
+	stx := node.buildStorageTransaction(ctx, req, []byte(common.Base91Encode(extra)))
+	if stx == nil {
+		return node.refundAndFailRequest(ctx, req, safe.Receivers, int(safe.Threshold))
+	}
+	txs := []*mtg.Transaction{stx}
+
+	typ := byte(common.ActionTronSafeProposeTransaction)
+	crv := common.SafeChainCurve(safe.Chain)
+	t := node.buildObserverResponseWithStorageTraceId(ctx, req.Id, req.Output, typ, crv, stx.TraceId)
+	if t == nil {
+		return node.refundAndFailRequest(ctx, req, safe.Receivers, int(safe.Threshold))
+	}
+	txs = append(txs, t)
+
+	recipients := []map[string]string{{
+		"receiver": receiver, "amount": amt.String(),
+	}}
+	data := common.MarshalJSONOrPanic(recipients)
+	tx := &store.Transaction{
+		TransactionHash: st.Hash(),
+		RawTransaction:  hex.EncodeToString(extra),
+		Holder:          req.Holder,
+		Chain:           safe.Chain,
+		AssetId:         id.String(),
+		State:           common.RequestStateInitial,
+		Data:            string(data),
+		RequestId:       req.Id,
+		CreatedAt:       req.CreatedAt,
+		UpdatedAt:       req.CreatedAt,
+	}
+	err = node.store.WriteTransactionWithRequest(ctx, tx, nil, txs, req)
+	if err != nil {
+		panic(err)
+	}
+	return txs, ""
+}
+
+// the holder signs the transaction id directly, and the observer signature
+// is only accepted after the timelock since the transaction proposed
+func (node *Node) processTronSafeApproveTransaction(ctx context.Context, req *common.Request) ([]*mtg.Transaction, string) {
+	if req.Role != common.RequestRoleObserver {
+		panic(req.Role)
+	}
+	chain := common.SafeCurveChain(req.Curve)
+	safe, err := node.store.ReadSafe(ctx, req.Holder)
+	if err != nil {
+		panic(fmt.Errorf("store.ReadSafe(%s) => %v", req.Holder, err))
+	}
+	if safe == nil || safe.Chain != chain {
+		return node.failRequest(ctx, req, "")
+	}
+	if safe.State != SafeStateApproved {
+		return node.failRequest(ctx, req, "")
+	}
+
+	extra := req.ExtraBytes()
+	if len(extra) != 81 {
+		return node.failRequest(ctx, req, "")
+	}
+	rid, err := uuid.FromBytes(extra[:16])
+	if err != nil {
+		return node.failRequest(ctx, req, "")
+	}
+	tx, err := node.store.ReadTransactionByRequestId(ctx, rid.String())
+	if err != nil {
+		panic(fmt.Errorf("store.ReadTransactionByRequestId(%v) => %s %v", req, rid.String(), err))
+	} else if tx == nil {
+		return node.failRequest(ctx, req, "")
+	} else if tx.State != common.RequestStateInitial {
+		return node.failRequest(ctx, req, "")
+	} else if tx.Holder != req.Holder {
+		return node.failRequest(ctx, req, "")
+	}
+
+	b := common.DecodeHexOrPanic(tx.RawTransaction)
+	st, err := tron.UnmarshalSafeTransaction(b)
+	if err != nil {
+		panic(fmt.Errorf("tron.UnmarshalSafeTransaction(%s) => %v", tx.RawTransaction, err))
+	}
+	if !st.Expiration().After(req.CreatedAt) {
+		return node.failRequest(ctx, req, "")
+	}
+	sig := extra[16:]
+	err = tron.VerifyHashSignature(safe.Holder, st.TransactionId(), sig)
+	logger.Printf("holder: tron.VerifyHashSignature(%s, %x) => %v", tx.TransactionHash, sig, err)
+	if err != nil {
+		if tx.CreatedAt.Add(safe.Timelock).After(req.CreatedAt) {
+			return node.failRequest(ctx, req, "")
+		}
+		err = tron.VerifyHashSignature(safe.Observer, st.TransactionId(), sig)
+		logger.Printf("observer: tron.VerifyHashSignature(%s, %x) => %v", tx.TransactionHash, sig, err)
+		if err != nil {
+			return node.failRequest(ctx, req, "")
+		}
+	}
+	st.HolderSignature = tron.ProcessSignature(sig)
+
+	sr := &store.SignatureRequest{
+		TransactionHash: tx.TransactionHash,
+		InputIndex:      0,
+		Signer:          safe.Signer,
+		Curve:           req.Curve,
+		Message:         st.Hash(),
+		State:           common.RequestStateInitial,
+		CreatedAt:       req.CreatedAt,
+		UpdatedAt:       req.CreatedAt,
+	}
+	sr.RequestId = common.UniqueId(req.Id, sr.Message)
+	txs := node.buildSignerSignRequests(ctx, req, []*store.SignatureRequest{sr}, safe.Path)
+	if len(txs) == 0 {
+		return node.failRequest(ctx, req, "")
+	}
+	err = node.store.WriteSignatureRequestsWithRequest(ctx, []*store.SignatureRequest{sr}, tx.TransactionHash, hex.EncodeToString(st.Marshal()), req, txs)
+	logger.Printf("store.WriteSignatureRequestsWithRequest(%s, %d, %v) => %v", tx.TransactionHash, 1, req, err)
+	if err != nil {
+		panic(err)
+	}
+	return txs, ""
+}
+
+func (node *Node) processTronSafeSignatureResponse(ctx context.Context, req *common.Request, safe *store.Safe, tx *store.Transaction, old *store.SignatureRequest) ([]*mtg.Transaction, string) {
+	if req.Role != common.RequestRoleSigner {
+		panic(req.Role)
+	}
+
+	sig := req.ExtraBytes()
+	msg := common.DecodeHexOrPanic(old.Message)
+	err := tron.VerifyHashSignature(safe.Signer, msg, sig)
+	logger.Printf("tron.VerifyHashSignature(%v) => %v", req, err)
+	if err != nil {
+		return node.failRequest(ctx, req, "")
+	}
+	err = node.store.FinishSignatureRequest(ctx, req)
+	logger.Printf("store.FinishSignatureRequest(%s) => %v", req.Id, err)
+	if err != nil {
+		panic(fmt.Errorf("store.FinishSignatureRequest(%s) => %v", req.Id, err))
+	}
+
+	b := common.DecodeHexOrPanic(tx.RawTransaction)
+	st, err := tron.UnmarshalSafeTransaction(b)
+	if err != nil {
+		panic(fmt.Errorf("tron.UnmarshalSafeTransaction(%s) => %v", tx.RawTransaction, err))
+	}
+	st.Signature = tron.ProcessSignature(sig)
+	if !st.IsFullySigned() {
+		panic(tx.TransactionHash)
+	}
+	raw := hex.EncodeToString(st.Marshal())
+
+	if safe.State == SafeStatePending {
+		if !st.IsPermissionUpdate() {
+			panic(tx.TransactionHash)
+		}
+		sp, err := node.store.ReadSafeProposalByAddress(ctx, safe.Address)
+		if err != nil {
+			panic(fmt.Errorf("store.ReadSafeProposalByAddress(%s) => %v", safe.Address, err))
+		}
+		spr, err := node.store.ReadRequest(ctx, sp.RequestId)
+		if err != nil {
+			panic(fmt.Errorf("store.ReadRequest(%s) => %v", sp.RequestId, err))
+		}
+
+		stx := node.buildStorageTransaction(ctx, req, []byte(common.Base91Encode(st.Marshal())))
+		if stx == nil {
+			return node.failRequest(ctx, req, "")
+		}
+		txs := []*mtg.Transaction{stx}
+
+		typ := byte(common.ActionTronSafeApproveAccount)
+		crv := common.SafeChainCurve(safe.Chain)
+		id := common.UniqueId(req.Id, safe.Address)
+		t := node.buildObserverResponseWithAssetAndStorageTraceId(ctx, id, req.Output, typ, crv, spr.AssetId, spr.Amount.String(), stx.TraceId)
+		if t == nil {
+			return node.failRequest(ctx, req, spr.AssetId)
+		}
+		txs = append(txs, t)
+
+		err = node.store.FinishSafeWithRequest(ctx, old.TransactionHash, raw, req, safe, txs)
+		if err != nil {
+			panic(err)
+		}
+		return txs, ""
+	}
+
+	sbm, err := node.store.ReadAllEthereumTokenBalancesMap(ctx, safe.Address)
+	logger.Printf("store.ReadAllEthereumTokenBalancesMap(%s) => %v %v", safe.Address, sbm, err)
+	if err != nil {
+		panic(err)
+	}
+	outputs, err := st.ExtractOutputs()
+	if err != nil {
+		panic(err)
+	}
+	for _, o := range outputs {
+		balance := sbm[o.TokenAddress]
+		if balance == nil {
+			return node.failRequest(ctx, req, "")
+		}
+		closeBalance := new(big.Int).Sub(balance.BigBalance(), o.Amount)
+		if closeBalance.Sign() < 0 {
+			logger.Printf("safe %s close balance %d lower than 0", safe.Address, closeBalance)
+			return node.failRequest(ctx, req, "")
+		}
+		balance.UpdateBalance(new(big.Int).Neg(o.Amount))
+	}
+
+	stx := node.buildStorageTransaction(ctx, req, []byte(common.Base91Encode(st.Marshal())))
+	if stx == nil {
+		return node.failRequest(ctx, req, "")
+	}
+	txs := []*mtg.Transaction{stx}
+
+	id := common.UniqueId(old.TransactionHash, stx.TraceId)
+	typ := byte(common.ActionTronSafeApproveTransaction)
+	crv := common.SafeChainCurve(safe.Chain)
+	t := node.buildObserverResponseWithStorageTraceId(ctx, id, req.Output, typ, crv, stx.TraceId)
+	if t == nil {
+		return node.failRequest(ctx, req, "")
+	}
+	txs = append(txs, t)
+
+	err = node.store.FinishTransactionSignaturesWithRequest(ctx, old.TransactionHash, raw, req, 0, safe, sbm, nil, txs)
+	logger.Printf("store.FinishTransactionSignaturesWithRequest(%s, %s, %v) => %v", old.TransactionHash, raw, req, err)
+	if err != nil {
+		panic(err)
+	}
+	return txs, ""
+}
+
+func (node *Node) verifyTronMessageSignatureWithHolderOrObserver(ctx context.Context, safe *store.Safe, ms string, sig []byte) error {
+	msg := []byte(ms)
+	err := tron.VerifyMessageSignature(safe.Holder, msg, sig)
+	logger.Printf("holder: tron.VerifyMessageSignature(%s, %x) => %v", ms, sig, err)
+	if err != nil {
+		err = tron.VerifyMessageSignature(safe.Observer, msg, sig)
+		logger.Printf("observer: tron.VerifyMessageSignature(%s, %x) => %v", ms, sig, err)
+	}
+	return err
+}
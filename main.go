@@ -44,6 +44,19 @@ func main() {
 					},
 				},
 			},
+			{
+				Name:   "encryptsignershares",
+				Usage:  "Encrypt the plain key shares in the signer database",
+				Action: cmd.SignerEncryptShares,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "config",
+						Aliases: []string{"c"},
+						Value:   "~/.mixin/safe/config.toml",
+						Usage:   "The configuration file path",
+					},
+				},
+			},
 			{
 				Name:   "keygen",
 				Usage:  "Request keygen",
@@ -119,6 +132,19 @@ func main() {
 					},
 				},
 			},
+			{
+				Name:   "custodian",
+				Usage:  "Run the custodian worker",
+				Action: cmd.CustodianBootCmd,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "config",
+						Aliases: []string{"c"},
+						Value:   "~/.mixin/safe/config.toml",
+						Usage:   "The configuration file path",
+					},
+				},
+			},
 			{
 				Name:   "observer",
 				Usage:  "Run the observer node",
@@ -191,6 +217,48 @@ func main() {
 					},
 				},
 			},
+			{
+				Name:   "vaultobserverkeys",
+				Usage:  "Move the observer accountant and evm keys to the key vault",
+				Action: cmd.ObserverVaultKeys,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "config",
+						Aliases: []string{"c"},
+						Value:   "~/.mixin/safe/config.toml",
+						Usage:   "The configuration file path",
+					},
+				},
+			},
+			{
+				Name:   "allowobservercalls",
+				Usage:  "Allow or deny the contract calls of an ethereum safe",
+				Action: cmd.ObserverAllowContractCalls,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "config",
+						Aliases: []string{"c"},
+						Value:   "~/.mixin/safe/config.toml",
+						Usage:   "The configuration file path",
+					},
+					&cli.StringFlag{
+						Name:  "address",
+						Usage: "The safe address",
+					},
+					&cli.StringFlag{
+						Name:  "target",
+						Usage: "The target contract address",
+					},
+					&cli.StringFlag{
+						Name:  "selector",
+						Usage: "The 4 bytes method selector in hex",
+					},
+					&cli.BoolFlag{
+						Name:  "deny",
+						Usage: "Remove the call from the allowlist",
+					},
+				},
+			},
 			{
 				Name:   "decode",
 				Usage:  "Decode an operation data",
@@ -331,6 +399,27 @@ func main() {
 					},
 				},
 			},
+			{
+				Name:   "describeaccount",
+				Usage:  "Export the descriptor and wallet policy of a bitcoin safe",
+				Action: cmd.ExportSafeDescriptor,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "config",
+						Aliases: []string{"c"},
+						Value:   "~/.mixin/safe/config.toml",
+						Usage:   "The configuration file path",
+					},
+					&cli.StringFlag{
+						Name:  "holder",
+						Usage: "The safe holder public key",
+					},
+					&cli.StringSliceFlag{
+						Name:  "xpub",
+						Usage: "The holder xpub with key origin, e.g. [9c03cfaf/44'/0'/0']xpub...",
+					},
+				},
+			},
 			{
 				Name:   "proposetransaction",
 				Usage:  "Propose a safe transaction",
@@ -402,6 +491,27 @@ func main() {
 					},
 				},
 			},
+			{
+				Name:   "restore",
+				Usage:  "Restore the signer keys from the saver backups",
+				Action: cmd.SaverRestoreCmd,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "config",
+						Aliases: []string{"c"},
+						Value:   "~/.mixin/safe/config.toml",
+						Usage:   "The configuration file path",
+					},
+					&cli.StringFlag{
+						Name:  "item",
+						Usage: "The saver item id to restore only one backup",
+					},
+					&cli.BoolFlag{
+						Name:  "force",
+						Usage: "Overwrite the share in use with an older backup",
+					},
+				},
+			},
 		},
 	}
 
@@ -6,6 +6,7 @@ import (
 	"database/sql"
 	"encoding/hex"
 	"fmt"
+	"math/big"
 	"slices"
 	"strings"
 	"time"
@@ -14,11 +15,7 @@ import (
 	"github.com/MixinNetwork/safe/apps/bitcoin"
 	"github.com/MixinNetwork/safe/apps/ethereum"
 	"github.com/MixinNetwork/safe/common"
-	"github.com/btcsuite/btcd/btcec/v2"
-	"github.com/btcsuite/btcd/btcec/v2/ecdsa"
-	"github.com/btcsuite/btcd/btcutil"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
-	"github.com/btcsuite/btcd/txscript"
 	"github.com/btcsuite/btcd/wire"
 	"github.com/gofrs/uuid/v5"
 )
@@ -34,6 +31,7 @@ func (node *Node) keeperCombineBitcoinTransactionSignatures(ctx context.Context,
 	switch tx.Chain {
 	case common.SafeChainBitcoin:
 	case common.SafeChainLitecoin:
+	case common.SafeChainBitcoinCash:
 	default:
 		panic(spsbt.Hash())
 	}
@@ -69,34 +67,28 @@ func (node *Node) keeperCombineBitcoinTransactionSignatures(ctx context.Context,
 		if !required {
 			continue
 		}
+		if hpsbt.IsTaprootInput(idx) {
+			node.keeperCombineBitcoinTaprootSignatures(hpsbt, spsbt, idx, tx.Holder, spk, opk, signed[idx])
+			continue
+		}
 		hpin := hpsbt.Inputs[idx]
-		hsig := hpin.PartialSigs[0]
+		pubs := []string{}
+		for _, sig := range hpin.PartialSigs {
+			pubs = append(pubs, hex.EncodeToString(sig.PubKey))
+		}
 
 		signedByHolderObserver := false
 		switch in.Sequence {
 		case bitcoin.MaxTransactionSequence: // normal tx
-			if hex.EncodeToString(hsig.PubKey) != tx.Holder {
+			if !slices.Contains(pubs, tx.Holder) {
 				panic(spsbt.Hash())
 			}
 		default: // recovery tx
-			if len(hpin.PartialSigs) == 1 {
-				// observer signer
-				if hex.EncodeToString(hsig.PubKey) != opk {
-					panic(spsbt.Hash())
-				}
-			} else {
-				// holder observer
-				pubs := []string{}
-				for _, sig := range hpin.PartialSigs {
-					pub := hex.EncodeToString(sig.PubKey)
-					pubs = append(pubs, pub)
-				}
-				if slices.Contains(pubs, tx.Holder) && slices.Contains(pubs, opk) {
-					signedByHolderObserver = true
-				} else {
-					panic(spsbt.Hash())
-				}
+			if !slices.Contains(pubs, opk) {
+				panic(spsbt.Hash())
 			}
+			// holder observer, otherwise observer signer
+			signedByHolderObserver = len(pubs) > 1 && hpsbt.IsInputSatisfied(idx)
 		}
 		if signedByHolderObserver {
 			continue
@@ -130,6 +122,33 @@ func (node *Node) keeperCombineBitcoinTransactionSignatures(ctx context.Context,
 	return err
 }
 
+func (node *Node) keeperCombineBitcoinTaprootSignatures(hpsbt, spsbt *bitcoin.PartiallySignedTransaction, idx int, holder, spk, opk string, signed []byte) {
+	hsig, osig := hpsbt.TaprootSignature(idx, holder), hpsbt.TaprootSignature(idx, opk)
+	switch hpsbt.UnsignedTx.TxIn[idx].Sequence {
+	case bitcoin.MaxTransactionSequence: // normal tx
+		if hsig == nil {
+			panic(spsbt.Hash())
+		}
+	default: // recovery tx
+		if osig == nil {
+			panic(spsbt.Hash())
+		}
+		if hsig != nil {
+			return
+		}
+	}
+
+	ssig := spsbt.TaprootSignature(idx, spk)
+	if !bytes.Equal(ssig, signed) {
+		panic(spsbt.Hash())
+	}
+	err := bitcoin.VerifySignatureSchnorr(spk, spsbt.SigHash(idx), ssig)
+	if err != nil {
+		panic(spsbt.Hash())
+	}
+	hpsbt.WriteTaprootSignature(idx, spk, ssig)
+}
+
 func (node *Node) keeperVerifyEthereumTransactionSignatures(ctx context.Context, extra []byte) error {
 	logger.Printf("node.keeperVerifyEthereumTransactionSignatures(%x)", extra)
 	st, _ := ethereum.UnmarshalSafeTransaction(extra)
@@ -143,9 +162,8 @@ func (node *Node) keeperVerifyEthereumTransactionSignatures(ctx context.Context,
 	if err != nil {
 		return err
 	}
-	switch safe.Chain {
+	switch common.NormalizeSafeChain(safe.Chain) {
 	case common.SafeChainEthereum:
-	case common.SafeChainPolygon:
 	default:
 		panic(st.TxHash)
 	}
@@ -254,7 +272,7 @@ func (node *Node) bitcoinSpendFullySignedTransaction(ctx context.Context, tx *Tr
 		return nil, fmt.Errorf("insufficient accountant balance %d %d", fee, fvb)
 	}
 
-	accountant, err := node.store.ReadAccountantPrivateKey(ctx, feeInput.Address)
+	accountant, err := node.accountantSigner(ctx, feeInput.Address)
 	if err != nil {
 		return nil, err
 	}
@@ -263,7 +281,7 @@ func (node *Node) bitcoinSpendFullySignedTransaction(ctx context.Context, tx *Tr
 		Index:           feeInput.Index,
 		Satoshi:         feeInput.Satoshi,
 	}}
-	msgTx, err = bitcoin.SpendSignedTransaction(hex.EncodeToString(signedBuffer), feeInputs, accountant, tx.Chain)
+	msgTx, err = bitcoin.SpendSignedTransactionWithSigner(hex.EncodeToString(signedBuffer), feeInputs, accountant, tx.Chain)
 	if err != nil {
 		return nil, err
 	}
@@ -325,32 +343,14 @@ func (node *Node) bitcoinRetrieveFeeInputsForTransaction(ctx context.Context, fe
 
 	for idx := range msgTx.TxIn {
 		in := utxos[idx]
-		accountant, err := node.store.ReadAccountantPrivateKey(ctx, in.Address)
+		accountant, err := node.accountantSigner(ctx, in.Address)
 		if err != nil {
 			return nil, err
 		}
-		b := common.DecodeHexOrPanic(accountant)
-		privateKey, publicKey := btcec.PrivKeyFromBytes(b)
-
-		script := publicKey.SerializeCompressed()
-		script = btcutil.Hash160(script)
-		builder := txscript.NewScriptBuilder()
-		builder.AddOp(txscript.OP_0)
-		builder.AddData(script)
-		script, err = builder.Script()
+		err = bitcoin.SignAccountantInputWithSigner(msgTx, idx, in.Satoshi, accountant, tx.Chain)
 		if err != nil {
 			return nil, err
 		}
-		pof := txscript.NewCannedPrevOutputFetcher(script, in.Satoshi)
-		tsh := txscript.NewTxSigHashes(msgTx, pof)
-		hash, err := txscript.CalcWitnessSigHash(script, tsh, txscript.SigHashAll, msgTx, idx, in.Satoshi)
-		if err != nil {
-			return nil, err
-		}
-		signature := ecdsa.Sign(privateKey, hash)
-		sig := append(signature.Serialize(), byte(txscript.SigHashAll))
-		msgTx.TxIn[idx].Witness = append(msgTx.TxIn[idx].Witness, sig)
-		msgTx.TxIn[idx].Witness = append(msgTx.TxIn[idx].Witness, publicKey.SerializeCompressed())
 	}
 
 	raw, err := bitcoin.MarshalWiredTransaction(msgTx, wire.WitnessEncoding, tx.Chain)
@@ -383,7 +383,7 @@ func (node *Node) ethereumTransactionSpendLoop(ctx context.Context, chain byte)
 			panic(err)
 		}
 		for _, tx := range txs {
-			b, err := ethereum.FetchBalanceFromKey(ctx, rpc, node.conf.EVMKey)
+			b, err := node.ethereumFetchSenderBalance(ctx, rpc, min)
 			if err != nil || b.Cmp(min) <= 0 {
 				bs := ethereum.UnitAmount(b, int32(asset.Decimals))
 				logger.Verbosef("ethereum.FetchBalanceFromKey(%d) => %s, %v", chain, bs, err)
@@ -412,6 +412,19 @@ func (node *Node) ethereumTransactionSpendLoop(ctx context.Context, chain byte)
 	}
 }
 
+// the safe refunds the relay bundler in the moved token, so the native
+// balance of the evm key is only required without a bundler
+func (node *Node) ethereumFetchSenderBalance(ctx context.Context, rpc string, min *big.Int) (*big.Int, error) {
+	if node.conf.EVMBundler != "" {
+		return new(big.Int).Add(min, big.NewInt(1)), nil
+	}
+	signer, err := node.evmSigner(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return ethereum.FetchBalance(ctx, rpc, signer.Address())
+}
+
 func (node *Node) ethereumSpendFullySignedTransaction(ctx context.Context, tx *Transaction) (string, error) {
 	b := common.DecodeHexOrPanic(tx.RawTransaction)
 	st, _ := ethereum.UnmarshalSafeTransaction(b)
@@ -452,6 +465,130 @@ func (s *SQLite3Store) AssignBitcoinUTXOByRangeForTransaction(ctx context.Contex
 	return &o, txn.Commit()
 }
 
+func (s *SQLite3Store) ListBitcoinUTXOsByRange(ctx context.Context, chain byte, min, max int64) ([]*Output, error) {
+	query := fmt.Sprintf("SELECT %s FROM bitcoin_outputs WHERE chain=? AND satoshi>=? AND satoshi<=? AND state=? ORDER BY satoshi ASC LIMIT 16", strings.Join(outputCols, ","))
+	rows, err := s.db.QueryContext(ctx, query, chain, min, max, common.RequestStateInitial)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var outputs []*Output
+	for rows.Next() {
+		var o Output
+		err := rows.Scan(&o.TransactionHash, &o.Index, &o.Address, &o.Satoshi, &o.Chain, &o.State, &o.SpentBy, &o.RawTransaction, &o.CreatedAt, &o.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		outputs = append(outputs, &o)
+	}
+	return outputs, nil
+}
+
+func (s *SQLite3Store) AssignBitcoinUTXOsForTransaction(ctx context.Context, utxos []*Output, hash string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	txn, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer txn.Rollback()
+
+	for _, utxo := range utxos {
+		err = s.execOne(ctx, txn, "UPDATE bitcoin_outputs SET state=?,spent_by=?,updated_at=? WHERE transaction_hash=? AND output_index=? AND state=? AND spent_by IS NULL",
+			common.RequestStateDone, hash, time.Now().UTC(), utxo.TransactionHash, utxo.Index, common.RequestStateInitial)
+		if err != nil {
+			return fmt.Errorf("UPDATE bitcoin_outputs %v", err)
+		}
+	}
+	return txn.Commit()
+}
+
+func (s *SQLite3Store) ReleaseBitcoinUTXOsForTransaction(ctx context.Context, utxos []*Output, hash string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	txn, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer txn.Rollback()
+
+	for _, utxo := range utxos {
+		err = s.execOne(ctx, txn, "UPDATE bitcoin_outputs SET state=?,spent_by=NULL,updated_at=? WHERE transaction_hash=? AND output_index=? AND state=? AND spent_by=?",
+			common.RequestStateInitial, time.Now().UTC(), utxo.TransactionHash, utxo.Index, common.RequestStateDone, hash)
+		if err != nil {
+			return fmt.Errorf("UPDATE bitcoin_outputs %v", err)
+		}
+	}
+	return txn.Commit()
+}
+
+// the accountant transaction outputs are all added to the accountant utxos,
+// and its inputs must have been assigned to the transaction already
+func (s *SQLite3Store) WriteBitcoinAccountantTransaction(ctx context.Context, msgTx *wire.MsgTx, receiver string, chain byte) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	txn, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer txn.Rollback()
+
+	signedBuffer, err := bitcoin.MarshalWiredTransaction(msgTx, wire.WitnessEncoding, chain)
+	if err != nil {
+		return err
+	}
+	hash := msgTx.TxHash().String()
+	for _, in := range msgTx.TxIn {
+		err = s.checkBitcoinUTXOSpentBy(ctx, txn, in.PreviousOutPoint, hash)
+		if err != nil {
+			return err
+		}
+	}
+	err = s.writeBitcoinAccountantOutputs(ctx, txn, msgTx, hex.EncodeToString(signedBuffer), receiver, chain)
+	if err != nil {
+		return err
+	}
+	return txn.Commit()
+}
+
+func (s *SQLite3Store) checkBitcoinUTXOSpentBy(ctx context.Context, txn *sql.Tx, op wire.OutPoint, hash string) error {
+	existed, err := s.checkExistence(ctx, txn, "SELECT satoshi FROM bitcoin_outputs WHERE transaction_hash=? AND output_index=? AND state=? AND spent_by=?",
+		op.Hash.String(), op.Index, common.RequestStateDone, hash)
+	if err != nil {
+		return err
+	}
+	if !existed {
+		return fmt.Errorf("bitcoin utxo %s:%d not spent by %s", op.Hash.String(), op.Index, hash)
+	}
+	return nil
+}
+
+func (s *SQLite3Store) writeBitcoinAccountantOutputs(ctx context.Context, txn *sql.Tx, msgTx *wire.MsgTx, raw, receiver string, chain byte) error {
+	hash := msgTx.TxHash().String()
+	for i, out := range msgTx.TxOut {
+		utxo := &Output{
+			TransactionHash: hash,
+			Index:           uint32(i),
+			Address:         receiver,
+			Satoshi:         out.Value,
+			Chain:           chain,
+			State:           common.RequestStateInitial,
+			RawTransaction:  sql.NullString{Valid: true, String: raw},
+			CreatedAt:       time.Now().UTC(),
+			UpdatedAt:       time.Now().UTC(),
+		}
+		err := s.execOne(ctx, txn, buildInsertionSQL("bitcoin_outputs", outputCols), utxo.values()...)
+		if err != nil {
+			return fmt.Errorf("INSERT bitcoin_outputs %v", err)
+		}
+	}
+	return nil
+}
+
 func (s *SQLite3Store) ReadBitcoinUTXO(ctx context.Context, hash string, index int64, chain byte) (*Output, error) {
 	query := fmt.Sprintf("SELECT %s FROM bitcoin_outputs WHERE chain=? AND transaction_hash=? AND output_index=?", strings.Join(outputCols, ","))
 	row := s.db.QueryRowContext(ctx, query, chain, hash, index)
@@ -593,6 +730,18 @@ func (node *Node) bitcoinBroadcastTransactionAndWriteDeposit(ctx context.Context
 
 func (node *Node) ethereumBroadcastTransactionAndWriteDeposit(ctx context.Context, tx *Transaction, st *ethereum.SafeTransaction) (string, error) {
 	rpc, _ := node.ethereumParams(tx.Chain)
+	// the relayed transaction is no longer valid once executed, so it is
+	// polled without the validation until the bundler has the receipt
+	if node.conf.EVMBundler != "" {
+		r, err := st.RelayReceipt(node.conf.EVMBundler)
+		logger.Printf("RelayReceipt(%v, %v) => %v %v", st, node.conf.EVMBundler, r, err)
+		if err != nil {
+			return "", err
+		}
+		if r != nil && r.Reason == "" {
+			return st.RelayTransaction(node.conf.EVMBundler)
+		}
+	}
 	success, validErr := st.ValidTransaction(rpc)
 	if validErr != nil || !success {
 		err := node.store.RefundFullySignedTransactionApproval(ctx, tx.TransactionHash)
@@ -614,8 +763,17 @@ func (node *Node) ethereumBroadcastTransactionAndWriteDeposit(ctx context.Contex
 		return "", fmt.Errorf("ValidTransaction => %t %v", success, validErr)
 	}
 
-	hash, err := st.ExecTransaction(ctx, rpc, node.conf.EVMKey)
-	logger.Printf("ExecTransaction(%v, %v) => %s %v", st, rpc, hash, err)
+	if node.conf.EVMBundler != "" {
+		hash, err := st.RelayTransaction(node.conf.EVMBundler)
+		logger.Printf("RelayTransaction(%v, %v) => %s %v", st, node.conf.EVMBundler, hash, err)
+		return hash, err
+	}
+	signer, err := node.evmSigner(ctx)
+	if err != nil {
+		return "", err
+	}
+	hash, err := st.ExecTransactionWithSigner(ctx, rpc, signer)
+	logger.Printf("ExecTransactionWithSigner(%v, %v) => %s %v", st, rpc, hash, err)
 	if err != nil {
 		return "", err
 	}
@@ -23,8 +23,9 @@ import (
 )
 
 const (
-	bitcoinKeygenRequestTimeKey  = "bitcoin-keygen-request-time"
-	bitcoinKeyDummyHolderPrivate = "75d5f311c8647e3a1d84a0d975b6e50b8c6d3d7f195365320077f41c6a165155"
+	bitcoinKeygenRequestTimeKey        = "bitcoin-keygen-request-time"
+	bitcoinTaprootKeygenRequestTimeKey = "bitcoin-taproot-keygen-request-time"
+	bitcoinKeyDummyHolderPrivate       = "75d5f311c8647e3a1d84a0d975b6e50b8c6d3d7f195365320077f41c6a165155"
 )
 
 func (node *Node) bitcoinParams(chain byte) (string, string) {
@@ -33,6 +34,8 @@ func (node *Node) bitcoinParams(chain byte) (string, string) {
 		return node.conf.BitcoinRPC, common.SafeBitcoinChainId
 	case common.SafeChainLitecoin:
 		return node.conf.LitecoinRPC, common.SafeLitecoinChainId
+	case common.SafeChainBitcoinCash:
+		return node.conf.BitcoinCashRPC, common.SafeBitcoinCashChainId
 	default:
 		panic(chain)
 	}
@@ -96,22 +99,23 @@ func (node *Node) bitcoinDummyHolder() string {
 	return hex.EncodeToString(dk.SerializeCompressed())
 }
 
-func (node *Node) bitcoinReadBlock(ctx context.Context, num int64, chain byte) ([]*bitcoin.RPCTransaction, error) {
+func (node *Node) bitcoinReadBlock(ctx context.Context, num int64, chain byte) (string, []*bitcoin.RPCTransaction, error) {
 	rpc, _ := node.bitcoinParams(chain)
 
 	if num == 0 {
-		return bitcoin.RPCGetRawMempool(chain, rpc)
+		txs, err := bitcoin.RPCGetRawMempool(chain, rpc)
+		return "", txs, err
 	}
 
 	hash, err := bitcoin.RPCGetBlockHash(rpc, num)
 	if err != nil {
-		return nil, err
+		return "", nil, err
 	}
 	block, err := bitcoin.RPCGetBlockWithTransactions(chain, rpc, hash)
 	if err != nil {
-		return nil, err
+		return "", nil, err
 	}
-	return block.Tx, nil
+	return block.Hash, block.Tx, nil
 }
 
 func (node *Node) bitcoinWriteFeeOutput(ctx context.Context, receiver string, tx *bitcoin.RPCTransaction, index int64, value float64, chain byte) error {
@@ -313,6 +317,7 @@ func (node *Node) bitcoinRPCBlocksLoop(ctx context.Context, chain byte) {
 	case common.SafeChainLitecoin:
 		duration = 1 * time.Minute
 	case common.SafeChainBitcoin:
+	case common.SafeChainBitcoinCash:
 	}
 
 	for {
@@ -332,8 +337,18 @@ func (node *Node) bitcoinRPCBlocksLoop(ctx context.Context, chain byte) {
 			time.Sleep(duration)
 			continue
 		}
-		txs, err := node.bitcoinReadBlock(ctx, checkpoint, chain)
-		logger.Printf("node.bitcoinReadBlock(%d, %d) => %d %v", chain, checkpoint, len(txs), err)
+		reorg, err := node.checkChainReorg(ctx, chain, checkpoint)
+		logger.Printf("node.checkChainReorg(%d, %d) => %t %v", chain, checkpoint, reorg, err)
+		if err != nil {
+			time.Sleep(time.Second * 5)
+			continue
+		} else if reorg {
+			continue
+		}
+		err = node.verifyChainReorgs(ctx, chain, checkpoint)
+		logger.Printf("node.verifyChainReorgs(%d, %d) => %v", chain, checkpoint, err)
+		hash, txs, err := node.bitcoinReadBlock(ctx, checkpoint, chain)
+		logger.Printf("node.bitcoinReadBlock(%d, %d) => %s %d %v", chain, checkpoint, hash, len(txs), err)
 		if err != nil {
 			time.Sleep(time.Second * 5)
 			continue
@@ -349,7 +364,7 @@ func (node *Node) bitcoinRPCBlocksLoop(ctx context.Context, chain byte) {
 			}
 		}
 
-		err = node.bitcoinWriteDepositCheckpoint(ctx, checkpoint+1, chain)
+		err = node.writeChainBlockCheckpoint(ctx, chain, checkpoint, hash)
 		if err != nil {
 			panic(err)
 		}
@@ -359,8 +374,7 @@ func (node *Node) bitcoinRPCBlocksLoop(ctx context.Context, chain byte) {
 func (node *Node) bitcoinProcessTransaction(ctx context.Context, tx *bitcoin.RPCTransaction, chain byte) error {
 	for index := range tx.Vout {
 		out := tx.Vout[index]
-		skt := out.ScriptPubKey.Type
-		if skt != bitcoin.ScriptPubKeyTypeWitnessScriptHash && skt != bitcoin.ScriptPubKeyTypeWitnessKeyHash {
+		if !bitcoin.CheckScriptPubKeyType(out.ScriptPubKey.Type, chain) {
 			continue
 		}
 		if out.N != int64(index) {
@@ -380,10 +394,6 @@ func (node *Node) bitcoinProcessTransaction(ctx context.Context, tx *bitcoin.RPC
 	return nil
 }
 
-func (node *Node) bitcoinWriteDepositCheckpoint(ctx context.Context, num int64, chain byte) error {
-	return node.store.WriteProperty(ctx, depositCheckpointKey(chain), fmt.Sprint(num))
-}
-
 func (node *Node) bitcoinTransactionApprovalLoop(ctx context.Context, chain byte) {
 	for {
 		time.Sleep(3 * time.Second)
@@ -560,7 +570,8 @@ func (node *Node) bitcoinCheckKeeperSignedTransaction(ctx context.Context, appro
 
 func (node *Node) checkBitcoinUTXOSignatureRequired(ctx context.Context, pop wire.OutPoint) bool {
 	utxo, _, _ := node.keeperStore.ReadBitcoinUTXO(ctx, pop.Hash.String(), int(pop.Index))
-	return bitcoin.CheckMultisigHolderSignerScript(utxo.Script)
+	return bitcoin.CheckMultisigHolderSignerScript(utxo.Script) ||
+		bitcoin.CheckTaprootHolderSignerScript(utxo.Script)
 }
 
 func (node *Node) httpCreateBitcoinAccountRecoveryRequest(ctx context.Context, safe *store.Safe, raw, hash string) error {
@@ -629,7 +640,11 @@ func (node *Node) httpCreateBitcoinAccountRecoveryRequest(ctx context.Context, s
 	if err != nil || info == nil {
 		return err
 	}
-	sequence := uint64(bitcoin.ParseSequence(safe.Timelock, safe.Chain))
+	blocks, err := bitcoin.ParseSequence(safe.Timelock, safe.Chain)
+	if err != nil {
+		return err
+	}
+	sequence := uint64(blocks)
 
 	var balance int64
 	for idx := range msgTx.TxIn {
@@ -794,12 +809,11 @@ func (node *Node) httpSignBitcoinAccountRecoveryRequest(ctx context.Context, saf
 
 func (node *Node) httpApproveBitcoinTransaction(ctx context.Context, raw string) error {
 	logger.Printf("node.httpApproveBitcoinTransaction(%s)", raw)
-	rb, _ := hex.DecodeString(raw)
-	psbt, err := bitcoin.UnmarshalPartiallySignedTransaction(rb)
+	signed, err := bitcoin.ParsePartiallySignedTransaction(raw)
 	if err != nil {
 		return err
 	}
-	txHash := psbt.Hash()
+	txHash := signed.Hash()
 
 	approval, err := node.store.ReadTransactionApproval(ctx, txHash)
 	logger.Verbosef("store.ReadTransactionApproval(%s) => %v %v", txHash, approval, err)
@@ -812,6 +826,19 @@ func (node *Node) httpApproveBitcoinTransaction(ctx context.Context, raw string)
 	if bitcoin.CheckTransactionPartiallySignedBy(approval.RawTransaction, approval.Holder) {
 		return nil
 	}
+
+	// the psbt could be signed by any external wallet, so only the valid
+	// signatures are merged into the transaction proposed by the keeper
+	psbt, err := bitcoin.ParsePartiallySignedTransaction(approval.RawTransaction)
+	if err != nil {
+		return err
+	}
+	merged, err := psbt.MergeSignatures(signed)
+	logger.Printf("psbt.MergeSignatures(%s) => %d %v", txHash, merged, err)
+	if err != nil {
+		return err
+	}
+	raw = hex.EncodeToString(psbt.Marshal())
 	if !bitcoin.CheckTransactionPartiallySignedBy(raw, approval.Holder) {
 		return nil
 	}
@@ -821,12 +848,30 @@ func (node *Node) httpApproveBitcoinTransaction(ctx context.Context, raw string)
 		return err
 	}
 
-	raw = hex.EncodeToString(psbt.Marshal())
 	err = node.store.AddTransactionPartials(ctx, txHash, raw)
 	logger.Printf("store.AddTransactionPartials(%s) => %v", txHash, err)
 	return err
 }
 
+func (node *Node) buildBitcoinTransactionBIP174(ctx context.Context, safe *store.Safe, raw string, xpubs []string) (string, error) {
+	psbt, err := bitcoin.ParsePartiallySignedTransaction(raw)
+	if err != nil {
+		return "", err
+	}
+	wsa, err := bitcoin.UnmarshalWitnessScriptAccount(safe.Extra)
+	if err != nil {
+		return "", err
+	}
+	if !bitcoin.CheckMultisigHolderSignerScript(wsa.Script) {
+		return psbt.MarshalBIP174(nil, nil)
+	}
+	keys, path, err := keeper.ReadBitcoinSafeDescriptorKeys(ctx, node.keeperStore, safe, xpubs)
+	if err != nil {
+		return "", err
+	}
+	return psbt.MarshalBIP174(keys, path)
+}
+
 func (node *Node) httpRevokeBitcoinTransaction(ctx context.Context, txHash string, sigBase64 string) error {
 	logger.Printf("node.httpRevokeBitcoinTransaction(%s, %s)", txHash, sigBase64)
 	approval, err := node.store.ReadTransactionApproval(ctx, txHash)
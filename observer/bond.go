@@ -72,8 +72,12 @@ func (node *Node) checkOrDeployKeeperBond(ctx context.Context, chain byte, asset
 	}
 	entry := node.fetchBondAssetReceiver(ctx, address, assetId)
 	logger.Printf("node.fetchBondAssetReceiver(%s, %s) => %s", address, assetId, entry)
-	rpc, key := node.conf.PolygonRPC, node.conf.EVMKey
-	return false, abi.GetOrDeployFactoryAsset(ctx, rpc, key, assetId, asset.Symbol, asset.Name, entry, holder)
+	signer, err := node.evmSigner(ctx)
+	if err != nil {
+		return false, err
+	}
+	rpc := node.conf.PolygonRPC
+	return false, abi.GetOrDeployFactoryAssetWithSigner(ctx, rpc, signer, assetId, asset.Symbol, asset.Name, entry, holder)
 }
 
 func (node *Node) fetchBondAsset(ctx context.Context, chain byte, assetId, assetAddress, holder, address string) (*Asset, *Asset, string, error) {
@@ -102,9 +106,8 @@ func (node *Node) fetchAssetMetaFromMessengerOrEthereum(ctx context.Context, id,
 	if err != nil || meta != nil {
 		return meta, err
 	}
-	switch chain {
+	switch common.NormalizeSafeChain(chain) {
 	case common.SafeChainEthereum:
-	case common.SafeChainPolygon:
 	default:
 		panic(chain)
 	}
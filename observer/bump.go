@@ -0,0 +1,397 @@
+package observer
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/MixinNetwork/mixin/logger"
+	"github.com/MixinNetwork/safe/apps/bitcoin"
+	"github.com/MixinNetwork/safe/common"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	FeeBumpKindCPFP = "cpfp"
+	FeeBumpKindRBF  = "rbf"
+
+	bitcoinFeeBumpWindow = 7 * 24 * time.Hour
+)
+
+// a broadcast spend is bumped if not confirmed in about six blocks
+func bitcoinFeeBumpDelay(chain byte) time.Duration {
+	switch chain {
+	case common.SafeChainBitcoin:
+		return time.Hour
+	case common.SafeChainLitecoin:
+		return 15 * time.Minute
+	default:
+		panic(chain)
+	}
+}
+
+func bitcoinAccountantInputSize(chain byte) int64 {
+	if bitcoin.IsSegwitChain(chain) {
+		return 68
+	}
+	return 148
+}
+
+func bitcoinAccountantTransactionSize(chain byte, inputs, outputs int) int64 {
+	return 11 + int64(inputs)*bitcoinAccountantInputSize(chain) + int64(outputs)*34
+}
+
+func bitcoinMempoolSatoshi(value float64) int64 {
+	return bitcoin.ParseSatoshi(decimal.NewFromFloat(value).String())
+}
+
+// the bitcoin cash blocks and mempool are mostly empty,
+// so only the bitcoin and litecoin spends are watched
+func (node *Node) bitcoinFeeBumpLoop(ctx context.Context, chain byte) {
+	for {
+		time.Sleep(time.Minute)
+		offset := time.Now().UTC().Add(-bitcoinFeeBumpWindow)
+		txs, err := node.store.ListBroadcastTransactionApprovals(ctx, chain, offset)
+		if err != nil {
+			panic(err)
+		}
+		for _, tx := range txs {
+			err := node.bitcoinBumpStuckTransaction(ctx, tx)
+			logger.Verbosef("node.bitcoinBumpStuckTransaction(%v) => %v", tx, err)
+		}
+	}
+}
+
+// the spend paying less than the current fee rate is replaced with an
+// additional accountant input, and the spend waiting for an underpaid fee
+// input transaction gets a child of the accountant change output
+func (node *Node) bitcoinBumpStuckTransaction(ctx context.Context, tx *Transaction) error {
+	rpc, _ := node.bitcoinParams(tx.Chain)
+
+	bumps, err := node.store.ListBitcoinFeeBumps(ctx, tx.TransactionHash)
+	if err != nil {
+		return err
+	}
+	last := tx.UpdatedAt
+	if len(bumps) > 0 && bumps[len(bumps)-1].CreatedAt.After(last) {
+		last = bumps[len(bumps)-1].CreatedAt
+	}
+	if last.Add(bitcoinFeeBumpDelay(tx.Chain)).After(time.Now()) {
+		return nil
+	}
+
+	entry, err := bitcoin.RPCGetMempoolEntry(rpc, tx.SpentHash.String)
+	if err != nil || entry == nil {
+		return err
+	}
+	fvb, err := bitcoin.EstimateAvgFee(tx.Chain, rpc)
+	if err != nil {
+		return err
+	}
+
+	switch bitcoinFeeBumpDecision(entry, fvb) {
+	case FeeBumpKindRBF:
+		// the safe inputs don't signal the replacement, and only the bitcoin
+		// core relays the full replacement by default
+		if tx.Chain != common.SafeChainBitcoin {
+			fee := bitcoinMempoolSatoshi(entry.Fees.Base)
+			return fmt.Errorf("bitcoin transaction %s underpaid %d %d", tx.SpentHash.String, fee, fvb)
+		}
+		return node.bitcoinReplaceStuckTransaction(ctx, tx, entry, fvb)
+	case FeeBumpKindCPFP:
+		return node.bitcoinChildPaysStuckTransaction(ctx, tx, fvb)
+	}
+	return nil
+}
+
+// the spend itself paying less than the fee rate is replaced, otherwise the
+// spend is only stuck by its underpaid unconfirmed ancestors
+func bitcoinFeeBumpDecision(entry *bitcoin.MemPoolTransaction, fvb int64) string {
+	fee := bitcoinMempoolSatoshi(entry.Fees.Base)
+	if fee < fvb*entry.VSize {
+		return FeeBumpKindRBF
+	}
+	ancestor := bitcoinMempoolSatoshi(entry.Fees.Ancestor)
+	if entry.AncestorCount > 1 && ancestor < fvb*entry.AncestorSize {
+		return FeeBumpKindCPFP
+	}
+	return ""
+}
+
+// the replacement pays the current fee rate for its own size, and at
+// least the incremental relay fee more than the replaced spend
+func bitcoinReplacementFee(entry *bitcoin.MemPoolTransaction, fvb int64, chain byte) int64 {
+	fee := bitcoinMempoolSatoshi(entry.Fees.Base)
+	vsize := entry.VSize + bitcoinAccountantInputSize(chain)
+	need := fvb*vsize - fee
+	if need < vsize {
+		need = vsize
+	}
+	if need < bitcoin.ValueDust(chain) {
+		need = bitcoin.ValueDust(chain)
+	}
+	return need
+}
+
+// the outputs to split the accountant utxo into the exact replacement fee
+// input and the accountant change, or nil if the utxo is too small
+func bitcoinReplacementSplit(satoshi, need, fvb int64, chain byte) []int64 {
+	fee := fvb * bitcoinAccountantTransactionSize(chain, 1, 2)
+	change := satoshi - need - fee
+	if change < bitcoin.ValueDust(chain) {
+		return nil
+	}
+	return []int64{need, change}
+}
+
+func (node *Node) bitcoinChildPaysStuckTransaction(ctx context.Context, tx *Transaction, fvb int64) error {
+	rpc, _ := node.bitcoinParams(tx.Chain)
+	msgTx, mainCount, err := bitcoinParseSpentTransaction(tx)
+	if err != nil {
+		return err
+	}
+
+	for _, in := range msgTx.TxIn[mainCount:] {
+		hash := in.PreviousOutPoint.Hash.String()
+		parent, err := bitcoin.RPCGetMempoolEntry(rpc, hash)
+		if err != nil {
+			return err
+		}
+		if parent == nil {
+			continue
+		}
+		ancestor := bitcoinMempoolSatoshi(parent.Fees.Ancestor)
+		if ancestor >= fvb*parent.AncestorSize {
+			continue
+		}
+		change, err := node.store.ReadBitcoinUTXO(ctx, hash, 1, tx.Chain)
+		if err != nil {
+			return err
+		}
+		if change == nil || change.State != common.RequestStateInitial {
+			continue
+		}
+
+		vsize := bitcoinAccountantTransactionSize(tx.Chain, 1, 1)
+		fee := fvb*(parent.AncestorSize+vsize) - ancestor
+		if change.Satoshi-fee < bitcoin.ValueDust(tx.Chain) {
+			return fmt.Errorf("insufficient accountant change %s:%d %d %d", hash, change.Index, change.Satoshi, fee)
+		}
+		script, err := bitcoin.ParseAddress(change.Address, tx.Chain)
+		if err != nil {
+			return err
+		}
+		accountant, err := node.accountantSigner(ctx, change.Address)
+		if err != nil {
+			return err
+		}
+
+		child := wire.NewMsgTx(2)
+		child.AddTxIn(&wire.TxIn{
+			PreviousOutPoint: wire.OutPoint{Hash: in.PreviousOutPoint.Hash, Index: change.Index},
+			Sequence:         bitcoin.MaxTransactionSequence,
+		})
+		child.AddTxOut(wire.NewTxOut(change.Satoshi-fee, script))
+		err = bitcoin.SignAccountantInputWithSigner(child, 0, change.Satoshi, accountant, tx.Chain)
+		if err != nil {
+			return err
+		}
+		raw, err := bitcoin.MarshalWiredTransaction(child, wire.WitnessEncoding, tx.Chain)
+		if err != nil {
+			return err
+		}
+		childHash := child.TxHash().String()
+		err = node.bitcoinBroadcastTransaction(childHash, raw, tx.Chain)
+		if err != nil {
+			return fmt.Errorf("node.bitcoinBroadcastTransaction(%s, %x) => %v", childHash, raw, err)
+		}
+
+		bump := &FeeBump{
+			BumpHash:        childHash,
+			TransactionHash: tx.TransactionHash,
+			Kind:            FeeBumpKindCPFP,
+			Chain:           tx.Chain,
+			FeeRate:         fvb,
+			Fee:             fee,
+			RawTransaction:  hex.EncodeToString(raw),
+			CreatedAt:       time.Now().UTC(),
+		}
+		err = node.store.WriteBitcoinChildFeeBump(ctx, bump, child, change.Address)
+		logger.Printf("store.WriteBitcoinChildFeeBump(%v) => %v", bump, err)
+		if err != nil {
+			panic(err)
+		}
+		return nil
+	}
+	return nil
+}
+
+func (node *Node) bitcoinReplaceStuckTransaction(ctx context.Context, tx *Transaction, entry *bitcoin.MemPoolTransaction, fvb int64) error {
+	rpc, _ := node.bitcoinParams(tx.Chain)
+	msgTx, mainCount, err := bitcoinParseSpentTransaction(tx)
+	if err != nil {
+		return err
+	}
+
+	var feeInputs []*bitcoin.Input
+	var signers []bitcoin.AccountantSigner
+	for _, in := range msgTx.TxIn[mainCount:] {
+		hash, index := in.PreviousOutPoint.Hash.String(), in.PreviousOutPoint.Index
+		utxo, err := node.store.ReadBitcoinUTXO(ctx, hash, int64(index), tx.Chain)
+		if err != nil {
+			return err
+		}
+		if utxo == nil {
+			return fmt.Errorf("accountant utxo %s:%d not found", hash, index)
+		}
+		accountant, err := node.accountantSigner(ctx, utxo.Address)
+		if err != nil {
+			return err
+		}
+		feeInputs = append(feeInputs, &bitcoin.Input{
+			TransactionHash: utxo.TransactionHash,
+			Index:           utxo.Index,
+			Satoshi:         utxo.Satoshi,
+		})
+		signers = append(signers, accountant)
+	}
+
+	// the safe inputs sign all the outputs, so the replacement could not add
+	// the accountant change output, and the extra input should be about the
+	// exact fee, then a larger utxo is split with the change output first
+	fee := bitcoinMempoolSatoshi(entry.Fees.Base)
+	need := bitcoinReplacementFee(entry, fvb, tx.Chain)
+	extra, pending, err := node.bitcoinAssignConfirmedUTXOForTransaction(ctx, need, need+bitcoin.ValueDust(tx.Chain), tx)
+	if err != nil {
+		return fmt.Errorf("node.bitcoinAssignConfirmedUTXOForTransaction(%d) => %v", need, err)
+	}
+	if extra == nil && pending {
+		return fmt.Errorf("bitcoin replacement fee input %d not confirmed", need)
+	}
+	if extra == nil {
+		return node.bitcoinSplitReplacementFeeInput(ctx, tx.Chain, need, fvb)
+	}
+	feeInputs = append(feeInputs, &bitcoin.Input{
+		TransactionHash: extra.TransactionHash,
+		Index:           extra.Index,
+		Satoshi:         extra.Satoshi,
+	})
+
+	msgTx.TxIn = msgTx.TxIn[:mainCount]
+	replacement, raw, err := node.bitcoinBuildReplacementTransaction(ctx, msgTx, extra, feeInputs, signers)
+	if err == nil {
+		hash := replacement.TxHash().String()
+		err = node.bitcoinBroadcastTransaction(hash, raw, tx.Chain)
+	}
+	if err != nil {
+		rerr := node.store.ReleaseBitcoinUTXOsForTransaction(ctx, []*Output{extra}, tx.TransactionHash)
+		if rerr != nil {
+			panic(rerr)
+		}
+		return err
+	}
+
+	hash := replacement.TxHash().String()
+	bump := &FeeBump{
+		BumpHash:        hash,
+		TransactionHash: tx.TransactionHash,
+		Kind:            FeeBumpKindRBF,
+		Chain:           tx.Chain,
+		FeeRate:         fvb,
+		Fee:             fee + extra.Satoshi,
+		RawTransaction:  hex.EncodeToString(raw),
+		CreatedAt:       time.Now().UTC(),
+	}
+	err = node.store.WriteBitcoinReplaceFeeBump(ctx, bump, tx.SpentHash.String)
+	logger.Printf("store.WriteBitcoinReplaceFeeBump(%v, %s) => %v", bump, tx.SpentHash.String, err)
+	if err != nil {
+		panic(err)
+	}
+	rtx, err := bitcoin.RPCGetTransaction(tx.Chain, rpc, hash)
+	if err != nil || rtx == nil {
+		return fmt.Errorf("bitcoin.RPCGetTransaction(%s) => %v %v", hash, rtx, err)
+	}
+	return node.bitcoinProcessTransaction(ctx, rtx, tx.Chain)
+}
+
+func (node *Node) bitcoinBuildReplacementTransaction(ctx context.Context, msgTx *wire.MsgTx, extra *Output, feeInputs []*bitcoin.Input, signers []bitcoin.AccountantSigner) (*wire.MsgTx, []byte, error) {
+	accountant, err := node.accountantSigner(ctx, extra.Address)
+	if err != nil {
+		return nil, nil, err
+	}
+	signers = append(signers, accountant)
+
+	raw, err := bitcoin.MarshalWiredTransaction(msgTx, wire.WitnessEncoding, extra.Chain)
+	if err != nil {
+		return nil, nil, err
+	}
+	replacement, err := bitcoin.SpendSignedTransactionWithSigners(hex.EncodeToString(raw), feeInputs, signers, extra.Chain)
+	if err != nil {
+		return nil, nil, err
+	}
+	raw, err = bitcoin.MarshalWiredTransaction(replacement, wire.WitnessEncoding, extra.Chain)
+	return replacement, raw, err
+}
+
+// the replacement could only add the confirmed inputs, and the unconfirmed
+// utxos in the range are pending for the next round
+func (node *Node) bitcoinAssignConfirmedUTXOForTransaction(ctx context.Context, min, max int64, tx *Transaction) (*Output, bool, error) {
+	rpc, _ := node.bitcoinParams(tx.Chain)
+	utxos, err := node.store.ListBitcoinUTXOsByRange(ctx, tx.Chain, min, max)
+	if err != nil {
+		return nil, false, err
+	}
+	for _, utxo := range utxos {
+		rtx, err := bitcoin.RPCGetTransaction(tx.Chain, rpc, utxo.TransactionHash)
+		if err != nil || rtx == nil {
+			return nil, false, fmt.Errorf("bitcoin.RPCGetTransaction(%s) => %v %v", utxo.TransactionHash, rtx, err)
+		}
+		if rtx.BlockHash == "" {
+			continue
+		}
+		return utxo, false, node.store.AssignBitcoinUTXOsForTransaction(ctx, []*Output{utxo}, tx.TransactionHash)
+	}
+	return nil, len(utxos) > 0, nil
+}
+
+// the smallest utxo large enough is split to the exact fee input and the
+// accountant change, and the replacement is made after it is confirmed
+func (node *Node) bitcoinSplitReplacementFeeInput(ctx context.Context, chain byte, need, fvb int64) error {
+	min := need + fvb*bitcoinAccountantTransactionSize(chain, 1, 2) + bitcoin.ValueDust(chain)
+	utxos, err := node.store.ListBitcoinUTXOsByRange(ctx, chain, min, math.MaxInt64)
+	if err != nil {
+		return err
+	}
+	if len(utxos) == 0 {
+		return fmt.Errorf("insufficient accountant balance %d %d", need, fvb)
+	}
+	input := utxos[0]
+	outputs := bitcoinReplacementSplit(input.Satoshi, need, fvb, chain)
+	if outputs == nil {
+		return fmt.Errorf("invalid accountant utxo %s:%d %d", input.TransactionHash, input.Index, input.Satoshi)
+	}
+	return node.bitcoinSendAccountantTransaction(ctx, chain, []*Output{input}, outputs, input.Address)
+}
+
+// the spent transaction is the fully signed safe transaction with the
+// accountant fee inputs appended after the safe inputs
+func bitcoinParseSpentTransaction(tx *Transaction) (*wire.MsgTx, int, error) {
+	psbt, err := bitcoin.UnmarshalPartiallySignedTransaction(common.DecodeHexOrPanic(tx.RawTransaction))
+	if err != nil {
+		return nil, 0, err
+	}
+	var msgTx wire.MsgTx
+	err = msgTx.Deserialize(bytes.NewReader(common.DecodeHexOrPanic(tx.SpentRaw.String)))
+	if err != nil {
+		return nil, 0, err
+	}
+	mainCount := len(psbt.UnsignedTx.TxIn)
+	if len(msgTx.TxIn) <= mainCount {
+		return nil, 0, fmt.Errorf("bitcoin transaction %s without fee input", tx.SpentHash.String)
+	}
+	return &msgTx, mainCount, nil
+}
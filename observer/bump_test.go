@@ -0,0 +1,195 @@
+package observer
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/safe/apps/bitcoin"
+	"github.com/MixinNetwork/safe/common"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testBumpApprovalHash = "3cbe8ac67374b48066c5f3e3fe45ca9c7043aa29c4d37a9518242fd7f0f5be1b"
+	testBumpSpentHash    = "fcc2dc6e90d454ec76cc48925096281735ed85ccd93a73b87cd303be9f28478e"
+	testBumpReplaceHash  = "09f837325c7285c2e118942536677926221a2eb882457b0f6aecc52b197aa201"
+	testBumpFeeInputHash = "9b76c7a3f60063c59d11d9fdf11467fdf56d496c1dfa559c78d06da756d6e204"
+	testBumpAccountant   = "bc1qevu9qqpfqp4s9jq3xxulfh08rgyjy8rn76aj7e"
+)
+
+func TestBitcoinFeeBumpStore(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+
+	root, err := os.MkdirTemp("", "safe-bump-test")
+	require.Nil(err)
+	defer os.RemoveAll(root)
+	db, err := OpenSQLite3Store(root + "/safe.sqlite3")
+	require.Nil(err)
+
+	now := time.Now().UTC()
+	approval := &Transaction{
+		TransactionHash: testBumpApprovalHash,
+		RawTransaction:  "psbt",
+		Chain:           common.SafeChainBitcoin,
+		Holder:          "holder",
+		Signer:          "signer",
+		State:           common.RequestStateDone,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+	err = db.WriteTransactionApprovalIfNotExists(ctx, approval)
+	require.Nil(err)
+	txs, err := db.ListBroadcastTransactionApprovals(ctx, common.SafeChainBitcoin, now.Add(-time.Hour))
+	require.Nil(err)
+	require.Len(txs, 0)
+	err = db.ConfirmFullySignedTransactionApproval(ctx, testBumpApprovalHash, testBumpSpentHash, "spent")
+	require.Nil(err)
+	txs, err = db.ListBroadcastTransactionApprovals(ctx, common.SafeChainBitcoin, now.Add(-time.Hour))
+	require.Nil(err)
+	require.Len(txs, 1)
+	txs, err = db.ListBroadcastTransactionApprovals(ctx, common.SafeChainBitcoin, now.Add(time.Hour))
+	require.Nil(err)
+	require.Len(txs, 0)
+
+	for i, satoshi := range []int64{20000, 5000, 9000} {
+		err = db.WriteBitcoinUTXOIfNotExists(ctx, &Output{
+			TransactionHash: testBumpFeeInputHash,
+			Index:           uint32(i),
+			Address:         testBumpAccountant,
+			Satoshi:         satoshi,
+			Chain:           common.SafeChainBitcoin,
+			State:           common.RequestStateInitial,
+			CreatedAt:       now,
+			UpdatedAt:       now,
+		})
+		require.Nil(err)
+	}
+	utxos, err := db.ListBitcoinUTXOsByRange(ctx, common.SafeChainBitcoin, 5000, 10000)
+	require.Nil(err)
+	require.Len(utxos, 2)
+	require.Equal(int64(5000), utxos[0].Satoshi)
+	require.Equal(int64(9000), utxos[1].Satoshi)
+	err = db.AssignBitcoinUTXOsForTransaction(ctx, utxos[:1], testBumpApprovalHash)
+	require.Nil(err)
+	err = db.AssignBitcoinUTXOsForTransaction(ctx, utxos[:1], testBumpApprovalHash)
+	require.NotNil(err)
+	err = db.ReleaseBitcoinUTXOsForTransaction(ctx, utxos[:1], testBumpApprovalHash)
+	require.Nil(err)
+	err = db.AssignBitcoinUTXOsForTransaction(ctx, utxos[:1], testBumpApprovalHash)
+	require.Nil(err)
+	utxos, err = db.ListBitcoinUTXOsByRange(ctx, common.SafeChainBitcoin, 5000, 10000)
+	require.Nil(err)
+	require.Len(utxos, 1)
+
+	err = db.WritePendingDepositIfNotExists(ctx, &Deposit{
+		TransactionHash: testBumpSpentHash,
+		OutputIndex:     1,
+		AssetId:         "asset",
+		Amount:          "0.0001",
+		Receiver:        "receiver",
+		Sender:          "sender",
+		State:           common.RequestStateInitial,
+		Chain:           common.SafeChainBitcoin,
+		Holder:          "holder",
+		Category:        common.ActionObserverHolderDeposit,
+		RequestId:       "request",
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	})
+	require.Nil(err)
+	replace := &FeeBump{
+		BumpHash:        testBumpReplaceHash,
+		TransactionHash: testBumpApprovalHash,
+		Kind:            FeeBumpKindRBF,
+		Chain:           common.SafeChainBitcoin,
+		FeeRate:         30,
+		Fee:             9000,
+		RawTransaction:  "replace",
+		CreatedAt:       now,
+	}
+	err = db.WriteBitcoinReplaceFeeBump(ctx, replace, testBumpSpentHash)
+	require.Nil(err)
+	err = db.WriteBitcoinReplaceFeeBump(ctx, replace, testBumpSpentHash)
+	require.NotNil(err)
+	tx, err := db.ReadTransactionApproval(ctx, testBumpApprovalHash)
+	require.Nil(err)
+	require.Equal(sql.NullString{Valid: true, String: testBumpReplaceHash}, tx.SpentHash)
+	require.Equal("replace", tx.SpentRaw.String)
+	deposits, err := db.ListDeposits(ctx, int(common.SafeChainBitcoin), "", common.RequestStateInitial, 0)
+	require.Nil(err)
+	require.Len(deposits, 0)
+
+	hash, _ := chainhash.NewHashFromStr(testBumpFeeInputHash)
+	script, err := bitcoin.ParseAddress(testBumpAccountant, common.SafeChainBitcoin)
+	require.Nil(err)
+	child := wire.NewMsgTx(2)
+	child.AddTxIn(&wire.TxIn{PreviousOutPoint: wire.OutPoint{Hash: *hash, Index: 0}})
+	child.AddTxOut(wire.NewTxOut(16000, script))
+	cpfp := &FeeBump{
+		BumpHash:        child.TxHash().String(),
+		TransactionHash: testBumpApprovalHash,
+		Kind:            FeeBumpKindCPFP,
+		Chain:           common.SafeChainBitcoin,
+		FeeRate:         30,
+		Fee:             4000,
+		RawTransaction:  "child",
+		CreatedAt:       now.Add(time.Second),
+	}
+	err = db.WriteBitcoinChildFeeBump(ctx, cpfp, child, testBumpAccountant)
+	require.Nil(err)
+	spent, err := db.ReadBitcoinUTXO(ctx, testBumpFeeInputHash, 0, common.SafeChainBitcoin)
+	require.Nil(err)
+	require.Equal(common.RequestStateDone, int(spent.State))
+	require.Equal(cpfp.BumpHash, spent.SpentBy.String)
+	output, err := db.ReadBitcoinUTXO(ctx, cpfp.BumpHash, 0, common.SafeChainBitcoin)
+	require.Nil(err)
+	require.Equal(int64(16000), output.Satoshi)
+	require.Equal(common.RequestStateInitial, int(output.State))
+
+	bumps, err := db.ListBitcoinFeeBumps(ctx, testBumpApprovalHash)
+	require.Nil(err)
+	require.Len(bumps, 2)
+	require.Equal(FeeBumpKindRBF, bumps[0].Kind)
+	require.Equal(FeeBumpKindCPFP, bumps[1].Kind)
+	node := &Node{}
+	view := node.viewFeeBumps(bumps)
+	require.Equal(testBumpReplaceHash, view[0]["hash"])
+	require.Equal(int64(4000), view[1]["fee"])
+}
+
+func TestBitcoinFeeBumpDecision(t *testing.T) {
+	require := require.New(t)
+
+	entry := &bitcoin.MemPoolTransaction{VSize: 200, AncestorCount: 1, AncestorSize: 200}
+	entry.Fees.Base = 0.00002
+	entry.Fees.Ancestor = 0.00002
+	require.Equal(FeeBumpKindRBF, bitcoinFeeBumpDecision(entry, 20))
+	require.Equal("", bitcoinFeeBumpDecision(entry, 10))
+	require.Equal("", bitcoinFeeBumpDecision(entry, 5))
+
+	entry.AncestorCount, entry.AncestorSize = 2, 350
+	entry.Fees.Ancestor = 0.000025
+	require.Equal(FeeBumpKindCPFP, bitcoinFeeBumpDecision(entry, 10))
+	require.Equal("", bitcoinFeeBumpDecision(entry, 7))
+	require.Equal(FeeBumpKindRBF, bitcoinFeeBumpDecision(entry, 11))
+
+	entry.AncestorCount, entry.AncestorSize = 1, 200
+	entry.Fees.Base = 0.00002
+	require.Equal(int64(2556), bitcoinReplacementFee(entry, 17, common.SafeChainBitcoin))
+	require.Equal(int64(1000), bitcoinReplacementFee(entry, 11, common.SafeChainBitcoin))
+	require.Equal(int64(1000), bitcoinReplacementFee(entry, 5, common.SafeChainBitcoin))
+	require.Equal(int64(10000), bitcoinReplacementFee(entry, 30, common.SafeChainLitecoin))
+	entry.VSize = 2000
+	require.Equal(int64(2068), bitcoinReplacementFee(entry, 1, common.SafeChainBitcoin))
+
+	require.Nil(bitcoinReplacementSplit(5000, 2556, 10, common.SafeChainBitcoin))
+	require.Nil(bitcoinReplacementSplit(5025, 2556, 10, common.SafeChainBitcoin))
+	require.Equal([]int64{2556, 1000}, bitcoinReplacementSplit(5026, 2556, 10, common.SafeChainBitcoin))
+	require.Equal([]int64{2556, 95974}, bitcoinReplacementSplit(100000, 2556, 10, common.SafeChainBitcoin))
+}
@@ -18,6 +18,7 @@ import (
 	"github.com/MixinNetwork/safe/common"
 	"github.com/MixinNetwork/safe/keeper"
 	"github.com/MixinNetwork/safe/keeper/store"
+	gc "github.com/ethereum/go-ethereum/common"
 	"github.com/gofrs/uuid/v5"
 	"github.com/shopspring/decimal"
 )
@@ -47,31 +48,24 @@ func (node *Node) deployEthereumGnosisSafeAccount(ctx context.Context, data []by
 		return err
 	}
 
-	tx, err := node.keeperStore.ReadTransaction(ctx, gs.TxHash)
-	if err != nil || tx == nil {
-		return fmt.Errorf("keeperStore.ReadTransaction(%s) => %v %v", gs.TxHash, tx, err)
+	// the bundler relays the deployment without blocking the snapshots loop,
+	// and the safe relays loop polls it until the account is deployed
+	if node.conf.EVMBundler != "" {
+		err = node.store.WriteSafeRelayIfNotExists(ctx, safe.Address, safe.Chain, gs.TxHash)
+		logger.Printf("store.WriteSafeRelayIfNotExists(%s, %s) => %v", safe.Address, gs.TxHash, err)
+		return err
 	}
-	raw, err := hex.DecodeString(tx.RawTransaction)
+	t, owners, err := node.readEthereumSafeDeployment(ctx, safe, gs.TxHash)
 	if err != nil {
 		return err
 	}
-	t, err := ethereum.UnmarshalSafeTransaction(raw)
-	logger.Printf("ethereum.UnmarshalSafeTransaction(%s) => %v %v", tx.RawTransaction, t, err)
+	signer, err := node.evmSigner(ctx)
 	if err != nil {
 		return err
 	}
-	owners, pubs := ethereum.GetSortedSafeOwners(safe.Holder, safe.Signer, safe.Observer)
-	logger.Printf("ethereum.GetSortedSafeOwners(%s, %s, %s) => %v %v", safe.Holder, safe.Signer, safe.Observer, owners, pubs)
-	var index int64
-	for i, pub := range pubs {
-		if pub == safe.Observer {
-			index = int64(i)
-		}
-	}
-	timelock := int64(safe.Timelock / time.Hour)
 	chainId := ethereum.GetEvmChainID(int64(safe.Chain))
-	sa, err := ethereum.GetOrDeploySafeAccount(ctx, rpc, node.conf.EVMKey, chainId, owners, 2, timelock, index, t)
-	logger.Printf("ethereum.GetOrDeploySafeAccount(%s, %d, %v, %d, %d, %v) => %s %v", rpc, chainId, owners, 2, timelock, t, sa, err)
+	sa, err := ethereum.GetOrDeploySafeAccountWithSigner(ctx, rpc, signer, chainId, owners, 2, t)
+	logger.Printf("ethereum.GetOrDeploySafeAccountWithSigner(%s, %d, %v, %d, %v) => %s %v", rpc, chainId, owners, 2, t, sa, err)
 	if err != nil {
 		return err
 	}
@@ -80,15 +74,76 @@ func (node *Node) deployEthereumGnosisSafeAccount(ctx context.Context, data []by
 	return err
 }
 
+func (node *Node) readEthereumSafeDeployment(ctx context.Context, safe *store.Safe, hash string) (*ethereum.SafeTransaction, []string, error) {
+	tx, err := node.keeperStore.ReadTransaction(ctx, hash)
+	if err != nil || tx == nil {
+		return nil, nil, fmt.Errorf("keeperStore.ReadTransaction(%s) => %v %v", hash, tx, err)
+	}
+	raw, err := hex.DecodeString(tx.RawTransaction)
+	if err != nil {
+		return nil, nil, err
+	}
+	t, err := ethereum.UnmarshalSafeTransaction(raw)
+	logger.Printf("ethereum.UnmarshalSafeTransaction(%s) => %v %v", tx.RawTransaction, t, err)
+	if err != nil {
+		return nil, nil, err
+	}
+	owners, pubs := ethereum.GetSortedSafeOwners(safe.Holder, safe.Signer, safe.Observer)
+	logger.Printf("ethereum.GetSortedSafeOwners(%s, %s, %s) => %v %v", safe.Holder, safe.Signer, safe.Observer, owners, pubs)
+	return t, owners, nil
+}
+
+func (node *Node) ethereumSafeRelayLoop(ctx context.Context, chain byte) {
+	rpc, _ := node.ethereumParams(chain)
+	chainId := ethereum.GetEvmChainID(int64(chain))
+
+	for {
+		time.Sleep(3 * time.Second)
+		relays, err := node.store.ListInitialSafeRelays(ctx, chain)
+		if err != nil {
+			panic(err)
+		}
+		for _, r := range relays {
+			safe, err := node.keeperStore.ReadSafeByAddress(ctx, r.Address)
+			if err != nil || safe == nil {
+				panic(fmt.Errorf("keeperStore.ReadSafeByAddress(%s) => %v %v", r.Address, safe, err))
+			}
+			t, owners, err := node.readEthereumSafeDeployment(ctx, safe, r.TransactionHash)
+			if err != nil {
+				panic(err)
+			}
+			sa, err := ethereum.GetOrRelaySafeAccount(rpc, node.conf.EVMBundler, chainId, owners, 2, t)
+			logger.Printf("ethereum.GetOrRelaySafeAccount(%s, %d, %v, %d, %v) => %s %v", rpc, chainId, owners, 2, t, sa, err)
+			if err != nil {
+				continue
+			}
+			err = node.store.FinishSafeRelay(ctx, r.Address)
+			if err != nil {
+				panic(err)
+			}
+			err = node.store.MarkAccountApproved(ctx, r.Address)
+			if err != nil {
+				panic(err)
+			}
+		}
+	}
+}
+
 func (node *Node) ethereumParams(chain byte) (string, string) {
 	switch chain {
 	case common.SafeChainEthereum:
 		return node.conf.EthereumRPC, common.SafeEthereumChainId
 	case common.SafeChainPolygon:
 		return node.conf.PolygonRPC, common.SafePolygonChainId
-	default:
-		panic(chain)
+	case common.SafeChainMVM:
+		return node.conf.MVMRPC, common.SafeMVMChainId
+	}
+	for _, c := range node.conf.EvmChains {
+		if c.Chain == chain {
+			return c.RPC, c.MixinChainId
+		}
 	}
+	panic(chain)
 }
 
 func (node *Node) ethereumNetworkInfoLoop(ctx context.Context, chain byte) {
@@ -140,31 +195,66 @@ func (node *Node) ethereumNetworkInfoLoop(ctx context.Context, chain byte) {
 		action := common.ActionObserverUpdateNetworkStatus
 		err = node.sendKeeperResponse(ctx, dummy, byte(action), chain, id, extra)
 		logger.Verbosef("node.sendKeeperResponse(%d, %s, %x) => %v", chain, id, extra, err)
+
+		if node.conf.EVMBundler != "" {
+			err = node.ethereumSendRelayGasPrices(ctx, chain, height)
+			logger.Verbosef("node.ethereumSendRelayGasPrices(%d, %d) => %v", chain, height, err)
+		}
+	}
+}
+
+// the bundler quotes the gas prices in the tokens it accepts, and the keeper
+// builds the safe transactions to refund the bundler with these prices
+func (node *Node) ethereumSendRelayGasPrices(ctx context.Context, chain byte, height int64) error {
+	chainId := ethereum.GetEvmChainID(int64(chain))
+	prices, err := ethereum.RelayGetGasPrices(node.conf.EVMBundler, chainId)
+	if err != nil {
+		return err
+	}
+	dummy := node.bitcoinDummyHolder()
+	for token, price := range prices {
+		old, err := node.keeperStore.ReadLatestRelayGasPrice(ctx, chain, token, time.Now())
+		if err != nil {
+			return err
+		}
+		if old != nil && old.GasPrice.Cmp(price) == 0 {
+			continue
+		}
+		extra := []byte{chain}
+		extra = append(extra, gc.HexToAddress(token).Bytes()...)
+		extra = append(extra, price.Bytes()...)
+		id := common.UniqueId("ActionObserverSetRelayGasPrice", dummy)
+		id = common.UniqueId(id, fmt.Sprintf("%s:%s:%d", token, price, height))
+		err = node.sendKeeperResponse(ctx, dummy, common.ActionObserverSetRelayGasPrice, chain, id, extra)
+		if err != nil {
+			return err
+		}
 	}
+	return nil
 }
 
-func (node *Node) ethereumReadBlock(ctx context.Context, num int64, chain byte) error {
+func (node *Node) ethereumReadBlock(ctx context.Context, num int64, chain byte) (string, error) {
 	rpc, ethAssetId := node.ethereumParams(chain)
 
 	blockTraces, err := ethereum.RPCDebugTraceBlockByNumber(rpc, num)
 	if err != nil {
-		return err
+		return "", err
 	}
 	if len(blockTraces) == 0 {
-		return nil
+		return ethereum.RPCGetBlockHash(rpc, num)
 	}
 	block, err := ethereum.RPCGetBlockWithTransactions(rpc, num)
 	if err != nil {
-		return err
+		return "", err
 	}
 	erc20Transfers, err := ethereum.GetERC20TransferLogFromBlock(ctx, rpc, int64(chain), num)
 	if err != nil {
-		return err
+		return "", err
 	}
 	transfers := ethereum.LoopBlockTraces(chain, ethAssetId, blockTraces, block.Tx)
 	transfers = append(transfers, erc20Transfers...)
 
-	return node.ethereumProcessBlock(ctx, chain, block, transfers)
+	return block.Hash, node.ethereumProcessBlock(ctx, chain, block, transfers)
 }
 
 func (node *Node) ethereumWritePendingDeposit(ctx context.Context, transfer *ethereum.Transfer, chain byte) error {
@@ -366,6 +456,8 @@ func (node *Node) ethereumRPCBlocksLoop(ctx context.Context, chain byte) {
 	switch chain {
 	case ethereum.ChainPolygon:
 		duration = 2 * time.Second
+	case ethereum.ChainMVM:
+		duration = 1 * time.Second
 	case ethereum.ChainEthereum:
 	}
 
@@ -386,14 +478,24 @@ func (node *Node) ethereumRPCBlocksLoop(ctx context.Context, chain byte) {
 			time.Sleep(duration)
 			continue
 		}
-		err = node.ethereumReadBlock(ctx, checkpoint, chain)
-		logger.Printf("node.ethereumReadBlock(%d, %d) => %v", chain, checkpoint, err)
+		reorg, err := node.checkChainReorg(ctx, chain, checkpoint)
+		logger.Printf("node.checkChainReorg(%d, %d) => %t %v", chain, checkpoint, reorg, err)
+		if err != nil {
+			time.Sleep(time.Second * 5)
+			continue
+		} else if reorg {
+			continue
+		}
+		err = node.verifyChainReorgs(ctx, chain, checkpoint)
+		logger.Printf("node.verifyChainReorgs(%d, %d) => %v", chain, checkpoint, err)
+		hash, err := node.ethereumReadBlock(ctx, checkpoint, chain)
+		logger.Printf("node.ethereumReadBlock(%d, %d) => %s %v", chain, checkpoint, hash, err)
 		if err != nil {
 			time.Sleep(time.Second * 5)
 			continue
 		}
 
-		err = node.ethereumWriteDepositCheckpoint(ctx, checkpoint+1, chain)
+		err = node.writeChainBlockCheckpoint(ctx, chain, checkpoint, hash)
 		if err != nil {
 			panic(err)
 		}
@@ -402,6 +504,18 @@ func (node *Node) ethereumRPCBlocksLoop(ctx context.Context, chain byte) {
 
 func (node *Node) ethereumProcessBlock(ctx context.Context, chain byte, block *ethereum.RPCBlockWithTransactions, transfers []*ethereum.Transfer) error {
 	rpc, _ := node.ethereumParams(chain)
+	var fungibles []*ethereum.Transfer
+	for _, t := range transfers {
+		if t.TokenId == nil {
+			fungibles = append(fungibles, t)
+			continue
+		}
+		err := node.ethereumSendNFTDeposit(ctx, chain, block.Height, t)
+		if err != nil {
+			return err
+		}
+	}
+	transfers = fungibles
 	changes, err := node.parseEthereumBlockBalanceChanges(ctx, chain, transfers)
 	logger.Printf("node.parseEthereumBlockBalanceChanges(%d, %d, %d) => %d %v", chain, block.Height, len(transfers), len(changes), err)
 	if err != nil || len(changes) == 0 {
@@ -467,6 +581,58 @@ func (node *Node) ethereumProcessTransaction(ctx context.Context, tx *ethereum.R
 	return nil
 }
 
+// the nft deposits have no bond and pending deposit, they are
+// sent to the keeper directly once finalized in the keeper network info
+func (node *Node) ethereumSendNFTDeposit(ctx context.Context, chain byte, height uint64, t *ethereum.Transfer) error {
+	safe, err := node.keeperStore.ReadSafeByAddress(ctx, t.Receiver)
+	logger.Verbosef("keeperStore.ReadSafeByAddress(%s) => %v %v", t.Receiver, safe, err)
+	if err != nil {
+		return fmt.Errorf("keeperStore.ReadSafeByAddress(%s) => %v %v", t.Receiver, safe, err)
+	} else if safe == nil || safe.Chain != chain {
+		return nil
+	}
+	old, err := node.keeperStore.ReadDeposit(ctx, t.Hash, t.Index)
+	logger.Printf("keeperStore.ReadDeposit(%s, %d, %s, %s) => %v %v", t.Hash, t.Index, t.AssetId, t.Receiver, old, err)
+	if err != nil {
+		return fmt.Errorf("keeperStore.ReadDeposit(%s, %d, %s, %s) => %v %v", t.Hash, t.Index, t.AssetId, t.Receiver, old, err)
+	} else if old != nil {
+		return nil
+	}
+	id := common.UniqueId(t.AssetId, safe.Holder)
+	id = common.UniqueId(id, fmt.Sprintf("%s:%d", t.Hash, t.Index))
+	request, err := node.keeperStore.ReadRequest(ctx, id)
+	logger.Printf("keeperStore.ReadRequest(%s) => %v %v", id, request, err)
+	if err != nil || request != nil {
+		return err
+	}
+
+	for {
+		info, err := node.keeperStore.ReadLatestNetworkInfo(ctx, chain, time.Now())
+		if err != nil {
+			return fmt.Errorf("keeperStore.ReadLatestNetworkInfo(%d) => %v", chain, err)
+		}
+		if info != nil && info.Height >= height && ethereum.CheckFinalization(info.Height-height+1, chain) {
+			break
+		}
+		time.Sleep(5 * time.Second)
+	}
+
+	hash, err := crypto.HashFromString(strings.TrimPrefix(t.Hash, "0x"))
+	if err != nil {
+		panic(t.Hash)
+	}
+	extra := []byte{chain}
+	extra = append(extra, uuid.Must(uuid.FromString(t.AssetId)).Bytes()...)
+	extra = append(extra, hash[:]...)
+	extra = append(extra, gc.HexToAddress(t.TokenAddress).Bytes()...)
+	extra = binary.BigEndian.AppendUint64(extra, uint64(t.Index))
+	extra = append(extra, 0, byte(t.TokenType))
+	extra = append(extra, t.Value.Bytes()...)
+	err = node.sendKeeperResponse(ctx, safe.Holder, common.ActionObserverHolderDeposit, chain, id, extra)
+	logger.Printf("node.sendKeeperResponse(%s, %s, %x) => %v", safe.Holder, id, extra, err)
+	return err
+}
+
 func (node *Node) parseEthereumBlockBalanceChanges(ctx context.Context, chain byte, ts []*ethereum.Transfer) (map[string]*big.Int, error) {
 	changes := make(map[string]*big.Int)
 	for _, t := range ts {
@@ -499,10 +665,6 @@ func (node *Node) parseEthereumBlockBalanceChanges(ctx context.Context, chain by
 	return changes, nil
 }
 
-func (node *Node) ethereumWriteDepositCheckpoint(ctx context.Context, num int64, chain byte) error {
-	return node.store.WriteProperty(ctx, depositCheckpointKey(chain), fmt.Sprint(num))
-}
-
 func (node *Node) ethereumTransactionApprovalLoop(ctx context.Context, chain byte) {
 	for {
 		time.Sleep(3 * time.Second)
@@ -541,6 +703,17 @@ func (node *Node) sendToKeeperEthereumApproveNormalTransaction(ctx context.Conte
 	if !ethereum.CheckTransactionPartiallySignedBy(approval.RawTransaction, approval.Holder) {
 		panic(approval.RawTransaction)
 	}
+	st, err := ethereum.UnmarshalSafeTransaction(common.DecodeHexOrPanic(approval.RawTransaction))
+	if err != nil {
+		return err
+	}
+	allowed, err := node.checkEthereumContractCallAllowed(ctx, approval.Holder, st)
+	logger.Printf("node.checkEthereumContractCallAllowed(%s) => %t %v", st.TxHash, allowed, err)
+	if err != nil {
+		return err
+	} else if !allowed {
+		return node.rejectEthereumContractCall(ctx, approval, st)
+	}
 
 	rawId := common.UniqueId(approval.RawTransaction, approval.RawTransaction)
 	raw := common.DecodeHexOrPanic(approval.RawTransaction)
@@ -744,11 +917,26 @@ func (node *Node) httpCreateEthereumAccountRecoveryRequest(ctx context.Context,
 	if err != nil {
 		return err
 	}
-	outputs := st.ExtractOutputs()
-	if len(outputs) != len(sbm) {
-		return fmt.Errorf("inconsistent number between outputs and balances: %d, %d", len(outputs), len(sbm))
+	nbm, err := node.keeperStore.ReadAllNFTBalancesMap(ctx, safe.Address)
+	logger.Printf("store.ReadAllNFTBalancesMap(%s) => %v %v", safe.Address, nbm, err)
+	if err != nil {
+		return err
+	}
+	outputs, err := st.ExtractOutputs()
+	if err != nil {
+		return err
+	}
+	if len(outputs) != len(sbm)+len(nbm) {
+		return fmt.Errorf("inconsistent number between outputs and balances: %d, %d, %d", len(outputs), len(sbm), len(nbm))
 	}
 	for _, o := range outputs {
+		if o.TokenId != nil {
+			nb := nbm[store.NFTBalanceKey(o.TokenAddress, o.TokenId.String())]
+			if nb == nil || nb.BigBalance().Cmp(o.Amount) != 0 {
+				return fmt.Errorf("inconsistent amount between %s nft balance and output: %v", o.TokenAddress, o)
+			}
+			continue
+		}
 		sbb := sbm[o.TokenAddress].BigBalance()
 		if sbb.Cmp(o.Amount) != 0 {
 			return fmt.Errorf("inconsistent amount between %s balance and output: %d, %d", o.TokenAddress, sbb, o.Amount)
@@ -842,11 +1030,26 @@ func (node *Node) httpSignEthereumAccountRecoveryRequest(ctx context.Context, sa
 	if err != nil {
 		return err
 	}
-	outputs := st.ExtractOutputs()
-	if len(outputs) != len(sbm) {
-		return fmt.Errorf("inconsistent number between outputs and balances: %d, %d", len(outputs), len(sbm))
+	nbm, err := node.keeperStore.ReadAllNFTBalancesMap(ctx, safe.Address)
+	logger.Printf("store.ReadAllNFTBalancesMap(%s) => %v %v", safe.Address, nbm, err)
+	if err != nil {
+		return err
+	}
+	outputs, err := st.ExtractOutputs()
+	if err != nil {
+		return err
+	}
+	if len(outputs) != len(sbm)+len(nbm) {
+		return fmt.Errorf("inconsistent number between outputs and balances: %d, %d, %d", len(outputs), len(sbm), len(nbm))
 	}
 	for _, o := range outputs {
+		if o.TokenId != nil {
+			nb := nbm[store.NFTBalanceKey(o.TokenAddress, o.TokenId.String())]
+			if nb == nil || nb.BigBalance().Cmp(o.Amount) != 0 {
+				return fmt.Errorf("inconsistent amount between %s nft balance and output: %v", o.TokenAddress, o)
+			}
+			continue
+		}
 		sbb := sbm[o.TokenAddress].BigBalance()
 		if sbb.Cmp(o.Amount) != 0 {
 			return fmt.Errorf("inconsistent amount between %s balance and output: %d, %d", o.TokenAddress, sbb, o.Amount)
@@ -914,12 +1117,75 @@ func (node *Node) httpApproveEthereumTransaction(ctx context.Context, raw string
 	if err != nil || tx == nil {
 		return err
 	}
+	allowed, err := node.checkEthereumContractCallAllowed(ctx, tx.Holder, st)
+	logger.Printf("node.checkEthereumContractCallAllowed(%s) => %t %v", st.TxHash, allowed, err)
+	if err != nil {
+		return err
+	} else if !allowed {
+		return fmt.Errorf("HTTP: %d", http.StatusNotAcceptable)
+	}
 
 	err = node.store.AddTransactionPartials(ctx, st.TxHash, raw)
 	logger.Printf("store.AddTransactionPartials(%s) => %v", st.TxHash, err)
 	return err
 }
 
+// the contract calls are only approved when the target and selector
+// are in the allowlist of the safe, and the transfers are always allowed
+func (node *Node) checkEthereumContractCallAllowed(ctx context.Context, holder string, st *ethereum.SafeTransaction) (bool, error) {
+	call := st.ExtractContractCall()
+	if call == nil {
+		return true, nil
+	}
+	safe, err := node.keeperStore.ReadSafe(ctx, holder)
+	if err != nil || safe == nil {
+		return false, err
+	}
+	if _, err := call.Arguments(); err != nil {
+		return false, nil
+	}
+	return node.store.CheckCallAllowed(ctx, safe.Address, call.Target, call.Selector)
+}
+
+// the call allowed by the holder approval may be removed from the allowlist
+// before it is sent to the keeper, then the approval is rejected
+func (node *Node) rejectEthereumContractCall(ctx context.Context, approval *Transaction, st *ethereum.SafeTransaction) error {
+	err := node.store.RejectTransactionApproval(ctx, approval.TransactionHash)
+	logger.Printf("store.RejectTransactionApproval(%s) => %v", approval.TransactionHash, err)
+	if err != nil {
+		return err
+	}
+	call := st.ExtractContractCall()
+	msg := fmt.Sprintf("⚠️ Observer rejected ethereum contract call of %s\n", approval.Holder)
+	msg = msg + fmt.Sprintf("🔗 Transaction: %s\n", approval.TransactionHash)
+	msg = msg + fmt.Sprintf("📝 Call: %s %s", call.Target, call.Selector)
+	return node.sendMonitorAlert(ctx, "call-rejected-"+approval.TransactionHash, msg, time.Hour)
+}
+
+func (node *Node) viewEthereumContractCall(ctx context.Context, holder, raw string) (map[string]any, error) {
+	st, err := ethereum.UnmarshalSafeTransaction(common.DecodeHexOrPanic(raw))
+	if err != nil {
+		return nil, err
+	}
+	call := st.ExtractContractCall()
+	if call == nil {
+		return nil, nil
+	}
+	allowed, err := node.checkEthereumContractCallAllowed(ctx, holder, st)
+	if err != nil {
+		return nil, err
+	}
+	args, _ := call.Arguments()
+	return map[string]any{
+		"target":    call.Target,
+		"value":     call.Value.String(),
+		"selector":  call.Selector,
+		"method":    call.Method(),
+		"arguments": args,
+		"allowed":   allowed,
+	}, nil
+}
+
 func (node *Node) httpRevokeEthereumTransaction(ctx context.Context, txHash string, sigHex string) error {
 	logger.Printf("node.httpRevokeEthereumTransaction(%s, %s)", txHash, sigHex)
 	approval, err := node.store.ReadTransactionApproval(ctx, txHash)
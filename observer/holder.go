@@ -15,9 +15,14 @@ import (
 	"github.com/MixinNetwork/mixin/logger"
 	"github.com/MixinNetwork/safe/apps/bitcoin"
 	"github.com/MixinNetwork/safe/apps/ethereum"
+	m "github.com/MixinNetwork/safe/apps/mixin"
+	"github.com/MixinNetwork/safe/apps/solana"
+	"github.com/MixinNetwork/safe/apps/tron"
 	"github.com/MixinNetwork/safe/common"
+	"github.com/btcsuite/btcd/btcutil/base58"
 	gc "github.com/ethereum/go-ethereum/common"
 	"github.com/gofrs/uuid/v5"
+	"github.com/shopspring/decimal"
 )
 
 func (node *Node) getSafeStatus(ctx context.Context, proposalId string) (string, error) {
@@ -38,19 +43,21 @@ func (node *Node) getSafeStatus(ctx context.Context, proposalId string) (string,
 func (node *Node) keeperSaveAccountProposal(ctx context.Context, chain byte, extra []byte, createdAt time.Time) error {
 	logger.Printf("node.keeperSaveAccountProposal(%d, %x, %s)", chain, extra, createdAt)
 	var address string
-	switch chain {
-	case common.SafeChainBitcoin, common.SafeChainLitecoin:
+	switch common.NormalizeSafeChain(chain) {
+	case common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainBitcoinCash:
 		wsa, err := bitcoin.UnmarshalWitnessScriptAccount(extra)
 		if err != nil {
 			return err
 		}
 		address = wsa.Address
-	case common.SafeChainEthereum, common.SafeChainPolygon:
+	case common.SafeChainEthereum:
 		gs, err := ethereum.UnmarshalGnosisSafe(extra)
 		if err != nil {
 			return err
 		}
 		address = gs.Address
+	case common.SafeChainMixinKernel, common.SafeChainSolana, common.SafeChainTron:
+		address = string(extra)
 	default:
 		panic(chain)
 	}
@@ -63,11 +70,17 @@ func (node *Node) keeperSaveAccountProposal(ctx context.Context, chain byte, ext
 	}
 
 	var assetId string
-	switch sp.Chain {
-	case common.SafeChainBitcoin, common.SafeChainLitecoin:
+	switch common.NormalizeSafeChain(sp.Chain) {
+	case common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainBitcoinCash:
 		_, assetId = node.bitcoinParams(sp.Chain)
-	case common.SafeChainEthereum, common.SafeChainPolygon:
+	case common.SafeChainEthereum:
 		_, assetId = node.ethereumParams(sp.Chain)
+	case common.SafeChainMixinKernel:
+		assetId = common.SafeMixinKernelAssetId
+	case common.SafeChainSolana:
+		assetId = common.SafeSolanaChainId
+	case common.SafeChainTron:
+		assetId = common.SafeTronChainId
 	}
 	_, err = node.checkOrDeployKeeperBond(ctx, chain, assetId, "", sp.Holder, sp.Address)
 	logger.Printf("node.checkOrDeployKeeperBond(%s, %s) => %v", assetId, sp.Holder, err)
@@ -80,13 +93,22 @@ func (node *Node) keeperSaveAccountProposal(ctx context.Context, chain byte, ext
 func (node *Node) keeperSaveTransactionProposal(ctx context.Context, chain byte, extra []byte, createdAt time.Time) error {
 	logger.Printf("node.keeperSaveTransactionProposal(%x, %s)", extra, createdAt)
 	var txHash string
-	switch chain {
-	case common.SafeChainBitcoin, common.SafeChainLitecoin:
+	switch common.NormalizeSafeChain(chain) {
+	case common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainBitcoinCash:
 		psbt, _ := bitcoin.UnmarshalPartiallySignedTransaction(extra)
 		txHash = psbt.UnsignedTx.TxHash().String()
-	case common.SafeChainEthereum, common.SafeChainPolygon:
+	case common.SafeChainEthereum:
 		t, _ := ethereum.UnmarshalSafeTransaction(extra)
 		txHash = t.TxHash
+	case common.SafeChainMixinKernel:
+		st, _ := m.UnmarshalSafeTransaction(extra)
+		txHash = st.Hash()
+	case common.SafeChainSolana:
+		st, _ := solana.UnmarshalSafeTransaction(extra)
+		txHash = st.Hash()
+	case common.SafeChainTron:
+		st, _ := tron.UnmarshalSafeTransaction(extra)
+		txHash = st.Hash()
 	}
 	tx, err := node.keeperStore.ReadTransaction(ctx, txHash)
 	if err != nil {
@@ -121,8 +143,8 @@ func (node *Node) httpApproveSafeAccount(ctx context.Context, addr, signature st
 	}
 
 	var sig []byte
-	switch sp.Chain {
-	case common.SafeChainBitcoin, common.SafeChainLitecoin:
+	switch common.NormalizeSafeChain(sp.Chain) {
+	case common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainBitcoinCash:
 		sig, err = base64.RawURLEncoding.DecodeString(signature)
 		if err != nil {
 			return err
@@ -134,7 +156,7 @@ func (node *Node) httpApproveSafeAccount(ctx context.Context, addr, signature st
 		if err != nil {
 			return err
 		}
-	case common.SafeChainPolygon, common.SafeChainEthereum:
+	case common.SafeChainEthereum:
 		sig, err = hex.DecodeString(signature)
 		if err != nil {
 			return err
@@ -163,6 +185,39 @@ func (node *Node) httpApproveSafeAccount(ctx context.Context, addr, signature st
 		if err != nil {
 			return err
 		}
+	case common.SafeChainMixinKernel:
+		sig, err = hex.DecodeString(signature)
+		if err != nil {
+			return err
+		}
+		ms := fmt.Sprintf("APPROVE:%s:%s", sp.RequestId, sp.Address)
+		err = m.VerifyMessageSignature(sp.Holder, []byte(ms), sig)
+		logger.Printf("mixin.VerifyMessageSignature(%v) => %v", sp, err)
+		if err != nil {
+			return err
+		}
+	case common.SafeChainSolana:
+		sig, err = hex.DecodeString(signature)
+		if err != nil {
+			return err
+		}
+		ms := fmt.Sprintf("APPROVE:%s:%s", sp.RequestId, sp.Address)
+		err = solana.VerifyMessageSignature(sp.Holder, []byte(ms), sig)
+		logger.Printf("solana.VerifyMessageSignature(%v) => %v", sp, err)
+		if err != nil {
+			return err
+		}
+	case common.SafeChainTron:
+		sig, err = hex.DecodeString(signature)
+		if err != nil {
+			return err
+		}
+		ms := fmt.Sprintf("APPROVE:%s:%s", sp.RequestId, sp.Address)
+		err = tron.VerifyMessageSignature(sp.Holder, []byte(ms), sig)
+		logger.Printf("tron.VerifyMessageSignature(%v) => %v", sp, err)
+		if err != nil {
+			return err
+		}
 	default:
 		return fmt.Errorf("HTTP: %d", http.StatusNotAcceptable)
 	}
@@ -190,10 +245,10 @@ func (node *Node) httpCreateSafeAccountRecoveryRequest(ctx context.Context, addr
 		return fmt.Errorf("HTTP: %d", http.StatusNotAcceptable)
 	}
 
-	switch safe.Chain {
-	case common.SafeChainBitcoin, common.SafeChainLitecoin:
+	switch common.NormalizeSafeChain(safe.Chain) {
+	case common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainBitcoinCash:
 		return node.httpCreateBitcoinAccountRecoveryRequest(ctx, safe, raw, hash)
-	case common.SafeChainPolygon, common.SafeChainEthereum:
+	case common.SafeChainEthereum:
 		return node.httpCreateEthereumAccountRecoveryRequest(ctx, safe, raw, hash)
 	default:
 		return fmt.Errorf("HTTP: %d", http.StatusNotAcceptable)
@@ -229,10 +284,10 @@ func (node *Node) httpSignAccountRecoveryRequest(ctx context.Context, addr, raw,
 		return fmt.Errorf("HTTP: %d", http.StatusNotAcceptable)
 	}
 
-	switch safe.Chain {
-	case common.SafeChainBitcoin, common.SafeChainLitecoin:
+	switch common.NormalizeSafeChain(safe.Chain) {
+	case common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainBitcoinCash:
 		return node.httpSignBitcoinAccountRecoveryRequest(ctx, safe, raw, hash)
-	case common.SafeChainPolygon, common.SafeChainEthereum:
+	case common.SafeChainEthereum:
 		return node.httpSignEthereumAccountRecoveryRequest(ctx, safe, raw, hash)
 	default:
 		return fmt.Errorf("HTTP: %d", http.StatusNotAcceptable)
@@ -240,22 +295,34 @@ func (node *Node) httpSignAccountRecoveryRequest(ctx context.Context, addr, raw,
 }
 
 func (node *Node) httpApproveSafeTransaction(ctx context.Context, chain byte, raw string) error {
-	switch chain {
-	case common.SafeChainBitcoin, common.SafeChainLitecoin:
+	switch common.NormalizeSafeChain(chain) {
+	case common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainBitcoinCash:
 		return node.httpApproveBitcoinTransaction(ctx, raw)
-	case common.SafeChainPolygon, common.SafeChainEthereum:
+	case common.SafeChainEthereum:
 		return node.httpApproveEthereumTransaction(ctx, raw)
+	case common.SafeChainMixinKernel:
+		return node.httpApproveMixinTransaction(ctx, raw)
+	case common.SafeChainSolana:
+		return node.httpApproveSolanaTransaction(ctx, raw)
+	case common.SafeChainTron:
+		return node.httpApproveTronTransaction(ctx, raw)
 	default:
 		return fmt.Errorf("HTTP: %d", http.StatusNotAcceptable)
 	}
 }
 
 func (node *Node) httpRevokeSafeTransaction(ctx context.Context, chain byte, hash, sig string) error {
-	switch chain {
-	case common.SafeChainBitcoin, common.SafeChainLitecoin:
+	switch common.NormalizeSafeChain(chain) {
+	case common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainBitcoinCash:
 		return node.httpRevokeBitcoinTransaction(ctx, hash, sig)
-	case common.SafeChainPolygon, common.SafeChainEthereum:
+	case common.SafeChainEthereum:
 		return node.httpRevokeEthereumTransaction(ctx, hash, sig)
+	case common.SafeChainMixinKernel:
+		return node.httpRevokeMixinTransaction(ctx, hash, sig)
+	case common.SafeChainSolana:
+		return node.httpRevokeSolanaTransaction(ctx, hash, sig)
+	case common.SafeChainTron:
+		return node.httpRevokeTronTransaction(ctx, hash, sig)
 	default:
 		return fmt.Errorf("HTTP: %d", http.StatusNotAcceptable)
 	}
@@ -277,17 +344,25 @@ func (node *Node) holderPayTransactionApproval(ctx context.Context, chain byte,
 		return err
 	}
 	var signedByHolder, signedByObserver bool
-	switch chain {
-	case common.SafeChainBitcoin, common.SafeChainLitecoin:
+	switch common.NormalizeSafeChain(chain) {
+	case common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainBitcoinCash:
 		signedByHolder = bitcoin.CheckTransactionPartiallySignedBy(approval.RawTransaction, safe.Holder)
 		opk, err := node.deriveBIP32WithKeeperPath(ctx, safe.Observer, safe.Path)
 		if err != nil {
 			panic(err)
 		}
 		signedByObserver = bitcoin.CheckTransactionPartiallySignedBy(approval.RawTransaction, opk)
-	case common.SafeChainPolygon, common.SafeChainEthereum:
+	case common.SafeChainEthereum:
 		signedByHolder = ethereum.CheckTransactionPartiallySignedBy(approval.RawTransaction, safe.Holder)
 		signedByObserver = ethereum.CheckTransactionPartiallySignedBy(approval.RawTransaction, safe.Observer)
+	case common.SafeChainMixinKernel:
+		signedByHolder = m.CheckTransactionPartiallySignedBy(approval.RawTransaction, safe.Holder)
+	case common.SafeChainSolana:
+		signedByHolder = solana.CheckTransactionPartiallySignedBy(approval.RawTransaction, safe.Holder)
+		signedByObserver = solana.CheckTransactionPartiallySignedBy(approval.RawTransaction, safe.Observer)
+	case common.SafeChainTron:
+		signedByHolder = tron.CheckTransactionPartiallySignedBy(approval.RawTransaction, safe.Holder)
+		signedByObserver = tron.CheckTransactionPartiallySignedBy(approval.RawTransaction, safe.Observer)
 	}
 	if !signedByHolder && !signedByObserver {
 		return nil
@@ -296,34 +371,73 @@ func (node *Node) holderPayTransactionApproval(ctx context.Context, chain byte,
 }
 
 func (deposit *Deposit) encodeKeeperExtra(decimals int32) []byte {
-	txHash := strings.TrimPrefix(deposit.TransactionHash, "0x")
-	hash, err := crypto.HashFromString(txHash)
-	if err != nil {
-		panic(txHash)
-	}
-
 	extra := []byte{deposit.Chain}
 	extra = append(extra, uuid.Must(uuid.FromString(deposit.AssetId)).Bytes()...)
-	extra = append(extra, hash[:]...)
 	switch deposit.Chain {
-	case common.SafeChainEthereum, common.SafeChainPolygon:
+	case common.SafeChainSolana:
+		// the solana transaction id is the 64 bytes signature
+		sig := base58.Decode(deposit.TransactionHash)
+		if len(sig) != 64 {
+			panic(deposit.TransactionHash)
+		}
+		extra = append(extra, sig...)
+	default:
+		txHash := strings.TrimPrefix(deposit.TransactionHash, "0x")
+		hash, err := crypto.HashFromString(txHash)
+		if err != nil {
+			panic(txHash)
+		}
+		extra = append(extra, hash[:]...)
+	}
+	switch common.NormalizeSafeChain(deposit.Chain) {
+	case common.SafeChainEthereum:
 		extra = append(extra, gc.HexToAddress(deposit.AssetAddress).Bytes()...)
+	case common.SafeChainTron:
+		addr, err := tron.DecodeAddress(deposit.AssetAddress)
+		if err != nil {
+			panic(deposit.AssetAddress)
+		}
+		extra = append(extra, addr[1:]...)
+	}
+	switch deposit.Chain {
+	case common.SafeChainMixinKernel:
+		// the kernel output index is small enough, and the space is
+		// saved for the ghost mask, which is kept as the asset address
+		extra = binary.BigEndian.AppendUint16(extra, uint16(deposit.OutputIndex))
+		extra = append(extra, common.DecodeHexOrPanic(deposit.AssetAddress)...)
+	default:
+		extra = binary.BigEndian.AppendUint64(extra, uint64(deposit.OutputIndex))
 	}
-	extra = binary.BigEndian.AppendUint64(extra, uint64(deposit.OutputIndex))
 	extra = append(extra, deposit.bigAmount(decimals).Bytes()...)
 	return extra
 }
 
 func (d *Deposit) bigAmount(decimals int32) *big.Int {
-	switch d.Chain {
-	case common.SafeChainBitcoin, common.SafeChainLitecoin:
+	switch common.NormalizeSafeChain(d.Chain) {
+	case common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainBitcoinCash:
 		if decimals != bitcoin.ValuePrecision {
 			panic(decimals)
 		}
 		satoshi := bitcoin.ParseSatoshi(d.Amount)
 		return new(big.Int).SetInt64(satoshi)
-	case common.SafeChainPolygon, common.SafeChainEthereum:
+	case common.SafeChainEthereum:
 		return ethereum.ParseAmount(d.Amount, decimals)
+	case common.SafeChainMixinKernel:
+		if decimals != m.ValuePrecision {
+			panic(decimals)
+		}
+		return decimal.RequireFromString(d.Amount).Shift(decimals).BigInt()
+	case common.SafeChainSolana:
+		if decimals != solana.ValuePrecision {
+			panic(decimals)
+		}
+		return decimal.RequireFromString(d.Amount).Shift(decimals).BigInt()
+	case common.SafeChainTron:
+		amount, err := tron.ParseAmount(d.Amount, decimals)
+		if err != nil {
+			panic(err)
+		}
+		return amount
 	}
 	panic(0)
 }
@@ -18,6 +18,7 @@ import (
 	"github.com/MixinNetwork/safe/apps/bitcoin"
 	"github.com/MixinNetwork/safe/apps/ethereum"
 	"github.com/MixinNetwork/safe/common"
+	"github.com/MixinNetwork/safe/keeper"
 	"github.com/MixinNetwork/safe/keeper/store"
 	"github.com/btcsuite/btcd/btcutil"
 	"github.com/dimfeld/httptreemux/v5"
@@ -106,6 +107,7 @@ func (node *Node) StartHTTP(version, readme string) {
 	router.GET("/recoveries/:id", node.httpGetRecovery)
 	router.POST("/recoveries/:id", node.httpSignRecovery)
 	router.GET("/accounts/:id", node.httpGetAccount)
+	router.GET("/accounts/:id/descriptor", node.httpGetAccountDescriptor)
 	router.POST("/accounts/:id", node.httpApproveAccount)
 	router.GET("/transactions/:id", node.httpGetTransaction)
 	router.POST("/transactions/:id", node.httpApproveTransaction)
@@ -161,7 +163,11 @@ func (node *Node) httpFavicon(w http.ResponseWriter, r *http.Request, params map
 
 func (node *Node) httpListChains(w http.ResponseWriter, r *http.Request, params map[string]string) {
 	var cs []map[string]any
-	for _, c := range []byte{common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainPolygon, common.SafeChainEthereum} {
+	chains := []byte{common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainBitcoinCash, common.SafeChainPolygon, common.SafeChainEthereum, common.SafeChainMVM, common.SafeChainTron}
+	for _, c := range node.conf.EvmChains {
+		chains = append(chains, c.Chain)
+	}
+	for _, c := range chains {
 		info, err := node.keeperStore.ReadLatestNetworkInfo(r.Context(), c, time.Now())
 		if err != nil {
 			common.RenderError(w, r, err)
@@ -189,8 +195,8 @@ func (node *Node) httpListChains(w http.ResponseWriter, r *http.Request, params
 		chain["deposit"] = map[string]any{
 			"checkpoint": ckp,
 		}
-		switch c {
-		case common.SafeChainBitcoin, common.SafeChainLitecoin:
+		switch common.NormalizeSafeChain(c) {
+		case common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainBitcoinCash:
 			c, s, err := node.readChainAccountantBalance(r.Context(), int(c))
 			if err != nil {
 				common.RenderError(w, r, err)
@@ -202,13 +208,19 @@ func (node *Node) httpListChains(w http.ResponseWriter, r *http.Request, params
 			accountant := make(map[string]any)
 			accountant["outputs"] = outputs
 			chain["accountant"] = accountant
-		case common.SafeChainEthereum, common.SafeChainPolygon:
-			addr, err := ethereum.PrivToAddress(node.conf.EVMKey)
+		case common.SafeChainEthereum:
+			if node.conf.EVMBundler != "" {
+				chain["relay"] = true
+				break
+			}
+			signer, err := node.evmSigner(r.Context())
 			if err != nil {
 				common.RenderError(w, r, err)
 				return
 			}
-			chain["sender"] = addr
+			chain["sender"] = signer.Address()
+		case common.SafeChainTron:
+			chain["sender"] = node.tronFundingAddress()
 		}
 		cs = append(cs, chain)
 	}
@@ -320,6 +332,56 @@ func (node *Node) httpGetAccount(w http.ResponseWriter, r *http.Request, params
 	node.renderAccount(r.Context(), w, r, safe)
 }
 
+func (node *Node) httpGetAccountDescriptor(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	sp, _, err := node.readSafeProposalOrRequest(r.Context(), params["id"])
+	if err != nil {
+		common.RenderError(w, r, err)
+		return
+	}
+	if sp == nil {
+		common.RenderJSON(w, r, http.StatusNotFound, map[string]any{"error": "safe"})
+		return
+	}
+	safe, err := node.keeperStore.ReadSafe(r.Context(), sp.Holder)
+	if err != nil {
+		common.RenderError(w, r, err)
+		return
+	}
+	if safe == nil || safe.Address != sp.Address {
+		common.RenderJSON(w, r, http.StatusNotFound, map[string]any{"error": "safe"})
+		return
+	}
+	// the descriptor is wsh(), so the legacy script hash chains have none
+	switch safe.Chain {
+	case common.SafeChainBitcoin, common.SafeChainLitecoin:
+	default:
+		common.RenderJSON(w, r, http.StatusNotFound, map[string]any{"error": "chain"})
+		return
+	}
+	wsa, err := bitcoin.UnmarshalWitnessScriptAccount(safe.Extra)
+	if err != nil {
+		common.RenderError(w, r, err)
+		return
+	}
+	if !bitcoin.CheckMultisigHolderSignerScript(wsa.Script) {
+		common.RenderJSON(w, r, http.StatusNotFound, map[string]any{"error": "descriptor"})
+		return
+	}
+
+	sd, err := keeper.BuildBitcoinSafeDescriptor(r.Context(), node.keeperStore, safe, r.URL.Query()["xpub"])
+	if err != nil {
+		common.RenderJSON(w, r, http.StatusBadRequest, map[string]any{"error": err.Error()})
+		return
+	}
+	common.RenderJSON(w, r, http.StatusOK, map[string]any{
+		"chain":      safe.Chain,
+		"id":         safe.RequestId,
+		"address":    safe.Address,
+		"descriptor": sd.Descriptor,
+		"policy":     sd.Policy,
+	})
+}
+
 func (node *Node) httpApproveAccount(w http.ResponseWriter, r *http.Request, params map[string]string) {
 	var body struct {
 		Action    string `json:"action"`
@@ -469,11 +531,47 @@ func (node *Node) httpGetTransaction(w http.ResponseWriter, r *http.Request, par
 		"signers":         approval.Signers(r.Context(), node, safe),
 		"state":           common.StateName(tx.State),
 	}
+	if common.NormalizeSafeChain(tx.Chain) == common.SafeChainEthereum {
+		call, err := node.viewEthereumContractCall(r.Context(), tx.Holder, approval.RawTransaction)
+		if err != nil {
+			common.RenderError(w, r, err)
+			return
+		}
+		if call != nil {
+			data["call"] = call
+		}
+		if call != nil && approval.State == common.RequestStateFailed && !call["allowed"].(bool) {
+			data["state"] = "rejected"
+		}
+	}
+	if r.URL.Query().Get("format") == "psbt" {
+		switch tx.Chain {
+		case common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainBitcoinCash:
+		default:
+			common.RenderJSON(w, r, http.StatusBadRequest, map[string]any{"error": "format"})
+			return
+		}
+		b64, err := node.buildBitcoinTransactionBIP174(r.Context(), safe, approval.RawTransaction, r.URL.Query()["xpub"])
+		if err != nil {
+			common.RenderJSON(w, r, http.StatusBadRequest, map[string]any{"error": err.Error()})
+			return
+		}
+		data["psbt"] = b64
+	}
 	if approval.SpentRaw.Valid {
 		data["hash"] = approval.SpentHash.String
 		data["raw"] = approval.SpentRaw.String
 		data["state"] = "spent"
 	}
+	switch tx.Chain {
+	case common.SafeChainBitcoin, common.SafeChainLitecoin:
+		bumps, err := node.store.ListBitcoinFeeBumps(r.Context(), tx.TransactionHash)
+		if err != nil {
+			common.RenderError(w, r, err)
+			return
+		}
+		data["bumps"] = node.viewFeeBumps(bumps)
+	}
 	common.RenderJSON(w, r, http.StatusOK, data)
 }
 
@@ -607,6 +705,24 @@ func (node *Node) buildBitcoinWitnessAccountWithDerivation(ctx context.Context,
 	if err != nil {
 		return nil, fmt.Errorf("bitcoin.DeriveBIP32(%s) => %v", safe.Observer, err)
 	}
+	sk, err := node.keeperStore.ReadKey(ctx, safe.Signer)
+	if err != nil {
+		return nil, fmt.Errorf("keeperStore.ReadKey(%s) => %v", safe.Signer, err)
+	}
+	if sk.Curve == common.CurveSecp256k1SchnorrBitcoin {
+		return bitcoin.BuildTaprootScriptAccount(safe.Holder, sdk, odk, safe.Timelock, safe.Chain)
+	}
+	wsa, err := bitcoin.UnmarshalWitnessScriptAccount(safe.Extra)
+	if err != nil {
+		return nil, fmt.Errorf("bitcoin.UnmarshalWitnessScriptAccount(%x) => %v", safe.Extra, err)
+	}
+	policy, err := bitcoin.ParseHolderPolicy(wsa.Script)
+	if err != nil {
+		return nil, fmt.Errorf("bitcoin.ParseHolderPolicy(%x) => %v", wsa.Script, err)
+	}
+	if policy != nil {
+		return bitcoin.BuildPolicyScriptAccount(policy, sdk, odk, safe.Timelock, safe.Chain)
+	}
 	return bitcoin.BuildWitnessScriptAccount(safe.Holder, sdk, odk, safe.Timelock, safe.Chain)
 }
 
@@ -676,7 +792,7 @@ func (node *Node) viewDeposits(ctx context.Context, deposits []*Deposit, sent ma
 			dm["sent_hash"] = d.TransactionHash
 		} else {
 			switch d.Chain {
-			case common.SafeChainBitcoin, common.SafeChainLitecoin:
+			case common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainBitcoinCash:
 				dm["change"] = node.bitcoinCheckDepositChange(ctx, d.TransactionHash, d.OutputIndex, sent[d.TransactionHash])
 			}
 		}
@@ -685,6 +801,20 @@ func (node *Node) viewDeposits(ctx context.Context, deposits []*Deposit, sent ma
 	return view
 }
 
+func (node *Node) viewFeeBumps(bumps []*FeeBump) []map[string]any {
+	view := make([]map[string]any, 0)
+	for _, b := range bumps {
+		view = append(view, map[string]any{
+			"hash":       b.BumpHash,
+			"kind":       b.Kind,
+			"fee_rate":   b.FeeRate,
+			"fee":        b.Fee,
+			"created_at": b.CreatedAt,
+		})
+	}
+	return view
+}
+
 func (node *Node) viewRecoveries(ctx context.Context, recoveries []*Recovery) []map[string]any {
 	view := make([]map[string]any, 0)
 	for _, r := range recoveries {
@@ -745,6 +875,14 @@ func viewBalances(bs []*store.SafeBalance, txs []*store.Transaction) (map[string
 	return assetBalance, pendingBalances
 }
 
+func viewCallAllowlist(calls [][2]string) []map[string]string {
+	view := make([]map[string]string, len(calls))
+	for i, c := range calls {
+		view[i] = map[string]string{"target": c[0], "selector": c[1]}
+	}
+	return view
+}
+
 func viewPendingBalances(txs []*store.Transaction) map[string]*AssetBalance {
 	assetBalance := make(map[string]*AssetBalance)
 	for _, tx := range txs {
@@ -752,8 +890,14 @@ func viewPendingBalances(txs []*store.Transaction) map[string]*AssetBalance {
 		st, _ := ethereum.UnmarshalSafeTransaction(raw)
 		chainAssetId := ethereum.GetMixinChainID(int64(tx.Chain))
 
-		outputs := st.ExtractOutputs()
+		outputs, err := st.ExtractOutputs()
+		if err != nil {
+			continue
+		}
 		for _, out := range outputs {
+			if out.TokenId != nil {
+				continue
+			}
 			assetId := chainAssetId
 			if out.TokenAddress != ethereum.EthereumEmptyAddress {
 				assetId = ethereum.GenerateAssetId(tx.Chain, out.TokenAddress)
@@ -793,8 +937,8 @@ func (node *Node) renderAccount(ctx context.Context, w http.ResponseWriter, r *h
 	if safe != nil {
 		safeAssetId = safe.SafeAssetId
 	}
-	switch sp.Chain {
-	case common.SafeChainBitcoin, common.SafeChainLitecoin:
+	switch common.NormalizeSafeChain(sp.Chain) {
+	case common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainBitcoinCash:
 		wsa, err := node.buildBitcoinWitnessAccountWithDerivation(r.Context(), sp)
 		if err != nil {
 			common.RenderError(w, r, err)
@@ -825,7 +969,7 @@ func (node *Node) renderAccount(ctx context.Context, w http.ResponseWriter, r *h
 			"safe_asset_id": safeAssetId,
 			"state":         status,
 		})
-	case common.SafeChainPolygon, common.SafeChainEthereum:
+	case common.SafeChainEthereum:
 		balances, err := node.keeperStore.ReadAllEthereumTokenBalances(r.Context(), sp.Address)
 		if err != nil {
 			common.RenderError(w, r, err)
@@ -836,6 +980,11 @@ func (node *Node) renderAccount(ctx context.Context, w http.ResponseWriter, r *h
 			common.RenderError(w, r, err)
 			return
 		}
+		calls, err := node.store.ListCallAllowlist(r.Context(), sp.Address)
+		if err != nil {
+			common.RenderError(w, r, err)
+			return
+		}
 		nonce := 0
 		if safe != nil {
 			nonce = int(safe.Nonce)
@@ -847,11 +996,37 @@ func (node *Node) renderAccount(ctx context.Context, w http.ResponseWriter, r *h
 			"address":        sp.Address,
 			"balances":       bs,
 			"pendingbalance": ps,
+			"calls":          viewCallAllowlist(calls),
 			"nonce":          nonce,
 			"keys":           node.viewSafeXPubs(r.Context(), sp),
 			"safe_asset_id":  safeAssetId,
 			"state":          status,
 		})
+	case common.SafeChainMixinKernel:
+		common.RenderJSON(w, r, http.StatusOK, map[string]any{
+			"chain":         sp.Chain,
+			"id":            sp.RequestId,
+			"address":       sp.Address,
+			"keys":          []string{sp.Holder, sp.Signer, sp.Observer},
+			"safe_asset_id": safeAssetId,
+			"state":         status,
+		})
+	case common.SafeChainSolana, common.SafeChainTron:
+		balances, err := node.keeperStore.ReadAllEthereumTokenBalances(r.Context(), sp.Address)
+		if err != nil {
+			common.RenderError(w, r, err)
+			return
+		}
+		bs, _ := viewBalances(balances, nil)
+		common.RenderJSON(w, r, http.StatusOK, map[string]any{
+			"chain":         sp.Chain,
+			"id":            sp.RequestId,
+			"address":       sp.Address,
+			"balances":      bs,
+			"keys":          []string{sp.Holder, sp.Signer, sp.Observer},
+			"safe_asset_id": safeAssetId,
+			"state":         status,
+		})
 	default:
 		common.RenderJSON(w, r, http.StatusNotFound, map[string]any{"error": "chain"})
 	}
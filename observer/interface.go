@@ -3,32 +3,43 @@ package observer
 import (
 	"fmt"
 
+	"github.com/MixinNetwork/safe/apps/ethereum"
 	"github.com/shopspring/decimal"
 )
 
 type Configuration struct {
-	KeeperAppId                 string `toml:"keeper-app-id"`
-	StoreDir                    string `toml:"store-dir"`
-	PrivateKey                  string `toml:"private-key"`
-	Timestamp                   int64  `toml:"timestamp"`
-	KeeperStoreDir              string `toml:"keeper-store-dir"`
-	KeeperPublicKey             string `toml:"keeper-public-key"`
-	AssetId                     string `toml:"asset-id"`
-	CustomKeyPriceAssetId       string `toml:"custom-key-price-asset-id"`
-	CustomKeyPriceAmount        string `toml:"custom-key-price-amount"`
-	OperationPriceAssetId       string `toml:"operation-price-asset-id"`
-	OperationPriceAmount        string `toml:"operation-price-amount"`
-	TransactionMinimum          string `toml:"transaction-minimum"`
-	MixinMessengerAPI           string `toml:"mixin-messenger-api"`
-	MixinRPC                    string `toml:"mixin-rpc"`
-	BitcoinRPC                  string `toml:"bitcoin-rpc"`
-	LitecoinRPC                 string `toml:"litecoin-rpc"`
-	EthereumRPC                 string `toml:"ethereum-rpc"`
-	PolygonRPC                  string `toml:"polygon-rpc"`
-	PolygonFactoryAddress       string `toml:"polygon-factory-address"`
-	PolygonObserverDepositEntry string `toml:"polygon-observer-deposit-entry"`
-	PolygonKeeperDepositEntry   string `toml:"polygon-keeper-deposit-entry"`
-	EVMKey                      string `toml:"evm-key"`
+	KeeperAppId                 string                `toml:"keeper-app-id"`
+	StoreDir                    string                `toml:"store-dir"`
+	PrivateKey                  string                `toml:"private-key"`
+	Timestamp                   int64                 `toml:"timestamp"`
+	KeeperStoreDir              string                `toml:"keeper-store-dir"`
+	KeeperPublicKey             string                `toml:"keeper-public-key"`
+	AssetId                     string                `toml:"asset-id"`
+	CustomKeyPriceAssetId       string                `toml:"custom-key-price-asset-id"`
+	CustomKeyPriceAmount        string                `toml:"custom-key-price-amount"`
+	OperationPriceAssetId       string                `toml:"operation-price-asset-id"`
+	OperationPriceAmount        string                `toml:"operation-price-amount"`
+	TransactionMinimum          string                `toml:"transaction-minimum"`
+	MixinMessengerAPI           string                `toml:"mixin-messenger-api"`
+	MonitorConversaionId        string                `toml:"monitor-conversation-id"`
+	MixinRPC                    string                `toml:"mixin-rpc"`
+	BitcoinRPC                  string                `toml:"bitcoin-rpc"`
+	LitecoinRPC                 string                `toml:"litecoin-rpc"`
+	BitcoinCashRPC              string                `toml:"bitcoin-cash-rpc"`
+	EthereumRPC                 string                `toml:"ethereum-rpc"`
+	PolygonRPC                  string                `toml:"polygon-rpc"`
+	PolygonFactoryAddress       string                `toml:"polygon-factory-address"`
+	PolygonObserverDepositEntry string                `toml:"polygon-observer-deposit-entry"`
+	PolygonKeeperDepositEntry   string                `toml:"polygon-keeper-deposit-entry"`
+	MVMRPC                      string                `toml:"mvm-rpc"`
+	SolanaRPC                   string                `toml:"solana-rpc"`
+	TronRPC                     string                `toml:"tron-rpc"`
+	TronKey                     string                `toml:"tron-key"`
+	EVMKey                      string                `toml:"evm-key"`
+	EVMBundler                  string                `toml:"evm-bundler"`
+	EvmChains                   []*ethereum.EvmChain  `toml:"evm-chains"`
+	KeyVault                    KeyVaultConfiguration `toml:"key-vault"`
+	AccountantPools             []*AccountantPool     `toml:"accountant-pools"`
 	App                         struct {
 		AppId             string `toml:"app-id"`
 		SessionId         string `toml:"session-id"`
@@ -48,5 +59,11 @@ func (c *Configuration) Validate() error {
 	if decimal.RequireFromString(c.TransactionMinimum).Sign() <= 0 {
 		return fmt.Errorf("Configuration.Validate(transaction) minimum %s", c.TransactionMinimum)
 	}
+	for _, p := range c.AccountantPools {
+		err := p.validate()
+		if err != nil {
+			return fmt.Errorf("Configuration.Validate(accountant) %v", err)
+		}
+	}
 	return nil
 }
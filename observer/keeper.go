@@ -26,6 +26,9 @@ func (node *Node) deriveBIP32WithKeeperPath(ctx context.Context, public, path st
 	if err != nil {
 		return "", fmt.Errorf("keeperStore.ReadKey(%s) => %v", public, err)
 	}
+	if sk.Curve == common.CurveSecp256k1SchnorrBitcoin {
+		return bitcoin.DeriveTaprootBIP32(public, common.DecodeHexOrPanic(sk.Extra), path32...)
+	}
 	_, sdk, err := bitcoin.DeriveBIP32(public, common.DecodeHexOrPanic(sk.Extra), path32...)
 	return sdk, err
 }
@@ -50,18 +53,7 @@ func (node *Node) sendKeeperResponse(ctx context.Context, holder string, typ, ch
 }
 
 func (node *Node) sendKeeperResponseWithReferences(ctx context.Context, holder string, typ, chain uint8, id string, extra []byte, references []crypto.Hash) error {
-	crv := byte(common.CurveSecp256k1ECDSABitcoin)
-	switch chain {
-	case common.SafeChainBitcoin:
-	case common.SafeChainLitecoin:
-		crv = common.CurveSecp256k1ECDSALitecoin
-	case common.SafeChainEthereum:
-		crv = common.CurveSecp256k1ECDSAEthereum
-	case common.SafeChainPolygon:
-		crv = common.CurveSecp256k1ECDSAPolygon
-	default:
-		panic(chain)
-	}
+	crv := common.SafeChainCurve(chain)
 	op := &common.Operation{
 		Id:     id,
 		Type:   typ,
@@ -15,6 +15,13 @@ func (node *Node) safeKeyLoop(ctx context.Context, chain byte) {
 			panic(err)
 		}
 
+		if chain == common.SafeChainBitcoin {
+			err = node.safeRequestTaprootSignerKeys(ctx)
+			if err != nil {
+				panic(err)
+			}
+		}
+
 		err = node.safeAddObserverKeys(ctx, chain)
 		if err != nil {
 			panic(err)
@@ -31,6 +38,10 @@ func (node *Node) safeAddObserverKeys(ctx context.Context, chain byte) error {
 		crv = common.CurveSecp256k1ECDSABitcoin
 	case common.SafeChainEthereum:
 		crv = common.CurveSecp256k1ECDSAEthereum
+	case common.SafeChainMixinKernel:
+		crv = common.CurveEdwards25519Mixin
+	case common.SafeChainSolana:
+		crv = common.CurveEdwards25519Default
 	}
 	count, err := node.keeperStore.CountSpareKeys(ctx, crv, common.RequestFlagNone, common.RequestRoleObserver)
 	if err != nil {
@@ -76,6 +87,10 @@ func (node *Node) safeRequestSignerKeys(ctx context.Context, chain byte) error {
 		crv = common.CurveSecp256k1ECDSABitcoin
 	case common.SafeChainEthereum:
 		crv = common.CurveSecp256k1ECDSAEthereum
+	case common.SafeChainMixinKernel:
+		crv = common.CurveEdwards25519Mixin
+	case common.SafeChainSolana:
+		crv = common.CurveEdwards25519Default
 	}
 	count, err := node.keeperStore.CountSpareKeys(ctx, crv, common.RequestFlagNone, common.RequestRoleSigner)
 	if err != nil || count > 1000 {
@@ -85,7 +100,7 @@ func (node *Node) safeRequestSignerKeys(ctx context.Context, chain byte) error {
 	if err != nil || requested.Add(60*time.Minute).After(time.Now()) {
 		return err
 	}
-	dummy := node.bitcoinDummyHolder()
+	dummy := node.chainDummyHolder(chain)
 	id := common.UniqueId(requested.String(), requested.String())
 	keysCount := []byte{16}
 	err = node.sendKeeperResponse(ctx, dummy, common.ActionObserverRequestSignerKeys, chain, id, keysCount)
@@ -95,11 +110,42 @@ func (node *Node) safeRequestSignerKeys(ctx context.Context, chain byte) error {
 	return node.writeSignerKeygenRequestTime(ctx, chain)
 }
 
+// taproot signer keys are schnorr keys of the bitcoin chain, so the request
+// curve can't be derived from the chain as other keeper responses
+func (node *Node) safeRequestTaprootSignerKeys(ctx context.Context) error {
+	crv := byte(common.CurveSecp256k1SchnorrBitcoin)
+	count, err := node.keeperStore.CountSpareKeys(ctx, crv, common.RequestFlagNone, common.RequestRoleSigner)
+	if err != nil || count > 1000 {
+		return err
+	}
+	requested, err := node.readRequestTime(ctx, bitcoinTaprootKeygenRequestTimeKey)
+	if err != nil || requested.Add(60*time.Minute).After(time.Now()) {
+		return err
+	}
+	id := common.UniqueId(requested.String(), bitcoinTaprootKeygenRequestTimeKey)
+	op := &common.Operation{
+		Id:     id,
+		Type:   common.ActionObserverRequestSignerKeys,
+		Curve:  crv,
+		Public: node.bitcoinDummyHolder(),
+		Extra:  []byte{16},
+	}
+	err = node.sendKeeperTransactionWithReferences(ctx, op, nil)
+	if err != nil {
+		return err
+	}
+	return node.writeRequestTime(ctx, bitcoinTaprootKeygenRequestTimeKey)
+}
+
 func (node *Node) readSignerKeygenRequestTime(ctx context.Context, chain byte) (time.Time, error) {
 	key, err := node.chainKeygenRequestTimeKey(chain)
 	if err != nil {
 		return time.Unix(0, node.conf.Timestamp), err
 	}
+	return node.readRequestTime(ctx, key)
+}
+
+func (node *Node) readRequestTime(ctx context.Context, key string) (time.Time, error) {
 	val, err := node.store.ReadProperty(ctx, key)
 	if err != nil || val == "" {
 		return time.Unix(0, node.conf.Timestamp), err
@@ -112,6 +158,10 @@ func (node *Node) writeSignerKeygenRequestTime(ctx context.Context, chain byte)
 	if err != nil {
 		return err
 	}
+	return node.writeRequestTime(ctx, key)
+}
+
+func (node *Node) writeRequestTime(ctx context.Context, key string) error {
 	return node.store.WriteProperty(ctx, key, time.Now().Format(time.RFC3339Nano))
 }
 
@@ -121,7 +171,22 @@ func (node *Node) chainKeygenRequestTimeKey(chain byte) (string, error) {
 		return bitcoinKeygenRequestTimeKey, nil
 	case common.SafeChainEthereum:
 		return ethereumKeygenRequestTimeKey, nil
+	case common.SafeChainMixinKernel:
+		return mixinKeygenRequestTimeKey, nil
+	case common.SafeChainSolana:
+		return solanaKeygenRequestTimeKey, nil
 	default:
 		return "", fmt.Errorf("invalid keygen request chain")
 	}
 }
+
+func (node *Node) chainDummyHolder(chain byte) string {
+	switch chain {
+	case common.SafeChainMixinKernel:
+		return node.mixinDummyHolder()
+	case common.SafeChainSolana:
+		return node.solanaDummyHolder()
+	default:
+		return node.bitcoinDummyHolder()
+	}
+}
@@ -70,20 +70,24 @@ func (node *Node) checkOrDeployPolygonBond(ctx context.Context, entry string, ch
 	if bond != nil {
 		return true, nil
 	}
-	rpc, key := node.conf.PolygonRPC, node.conf.EVMKey
-	return false, abi.GetOrDeployFactoryAsset(ctx, rpc, key, assetId, asset.Symbol, asset.Name, entry, holder)
+	signer, err := node.evmSigner(ctx)
+	if err != nil {
+		return false, err
+	}
+	rpc := node.conf.PolygonRPC
+	return false, abi.GetOrDeployFactoryAssetWithSigner(ctx, rpc, signer, assetId, asset.Symbol, asset.Name, entry, holder)
 }
 
 func (node *Node) deployPolygonBondAssets(ctx context.Context, safes []*store.Safe, receiver string) error {
 	for _, safe := range safes {
-		switch safe.Chain {
+		switch common.NormalizeSafeChain(safe.Chain) {
 		case common.SafeChainBitcoin, common.SafeChainLitecoin:
 			_, assetId := node.bitcoinParams(safe.Chain)
 			_, err := node.checkOrDeployPolygonBond(ctx, receiver, safe.Chain, assetId, "", safe.Holder)
 			if err != nil {
 				return err
 			}
-		case common.SafeChainEthereum, common.SafeChainPolygon:
+		case common.SafeChainEthereum:
 			_, assetId := node.ethereumParams(safe.Chain)
 			balances, err := node.keeperStore.ReadAllEthereumTokenBalances(ctx, safe.Address)
 			if err != nil {
@@ -115,19 +119,8 @@ func (node *Node) distributePolygonBondAsset(ctx context.Context, receiver strin
 	}
 
 	traceId := common.UniqueId(bond.AssetId, safe.RequestId)
-	crv := byte(common.CurveSecp256k1ECDSABitcoin)
+	crv := common.SafeChainCurve(safe.Chain)
 	extra := gc.HexToAddress(receiver).Bytes()
-	switch safe.Chain {
-	case common.SafeChainBitcoin:
-	case common.SafeChainLitecoin:
-		crv = common.CurveSecp256k1ECDSALitecoin
-	case common.SafeChainEthereum:
-		crv = common.CurveSecp256k1ECDSAEthereum
-	case common.SafeChainPolygon:
-		crv = common.CurveSecp256k1ECDSAPolygon
-	default:
-		panic(safe.Chain)
-	}
 	op := &common.Operation{
 		Id:     traceId,
 		Type:   common.ActionMigrateSafeToken,
@@ -187,7 +180,7 @@ func userNotRegistered(err error) bool {
 }
 
 func (node *Node) distributePolygonBondAssetsForSafe(ctx context.Context, safe *store.Safe, receiver string) (bool, bool, error) {
-	switch safe.Chain {
+	switch common.NormalizeSafeChain(safe.Chain) {
 	case common.SafeChainBitcoin, common.SafeChainLitecoin:
 		_, assetId := node.bitcoinParams(safe.Chain)
 		_, bond, _, err := node.fetchPolygonBondAsset(ctx, receiver, safe.Chain, assetId, "", safe.Holder)
@@ -211,7 +204,7 @@ func (node *Node) distributePolygonBondAssetsForSafe(ctx context.Context, safe *
 			return false, false, err
 		}
 		return true, false, nil
-	case common.SafeChainEthereum, common.SafeChainPolygon:
+	case common.SafeChainEthereum:
 		balances, err := node.keeperStore.ReadAllEthereumTokenBalances(ctx, safe.Address)
 		if err != nil {
 			return false, false, err
@@ -0,0 +1,492 @@
+package observer
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	mc "github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/logger"
+	m "github.com/MixinNetwork/safe/apps/mixin"
+	"github.com/MixinNetwork/safe/common"
+	"github.com/MixinNetwork/safe/keeper"
+	"github.com/MixinNetwork/safe/keeper/store"
+	"github.com/gofrs/uuid/v5"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	mixinKeygenRequestTimeKey  = "mixin-keygen-request-time"
+	mixinKeyDummyHolderPrivate = "5c172247c9db854486fb9f34e7409f163e2527f7b2cc2b0c6cb75d7a8538d3db934a7e0b7f88c3a7b4e1c2389fa90c91f501c735051bcb66483ddf3e2e63f39b"
+)
+
+func (node *Node) mixinDummyHolder() string {
+	seed := common.DecodeHexOrPanic(mixinKeyDummyHolderPrivate)
+	key := crypto.NewKeyFromSeed(seed)
+	return key.Public().String()
+}
+
+func (node *Node) deployMixinSafeBond(ctx context.Context, data []byte) error {
+	logger.Printf("node.deployMixinSafeBond(%s)", string(data))
+	safe, err := node.keeperStore.ReadSafeByAddress(ctx, string(data))
+	if err != nil {
+		return fmt.Errorf("keeperStore.ReadSafeByAddress(%s) => %v", string(data), err)
+	}
+	assetId := common.SafeMixinKernelAssetId
+	_, err = node.checkOrDeployKeeperBond(ctx, safe.Chain, assetId, "", safe.Holder, safe.Address)
+	logger.Printf("node.checkOrDeployKeeperBond(%s, %s) => %v", assetId, safe.Holder, err)
+	if err != nil {
+		return fmt.Errorf("node.checkOrDeployKeeperBond(%s, %s) => %v", assetId, safe.Holder, err)
+	}
+	err = node.store.MarkAccountApproved(ctx, safe.Address)
+	logger.Printf("store.MarkAccountApproved(%s) => %v", safe.Address, err)
+	return err
+}
+
+func (node *Node) mixinRPCBlocksLoop(ctx context.Context) {
+	chain := byte(common.SafeChainMixinKernel)
+
+	for {
+		checkpoint, err := node.readDepositCheckpoint(ctx, chain)
+		if err != nil {
+			panic(err)
+		}
+		snapshots, err := m.RPCListSnapshots(ctx, node.conf.MixinRPC, uint64(checkpoint), 100)
+		logger.Printf("mixin.RPCListSnapshots(%d) => %d %v", checkpoint, len(snapshots), err)
+		if err != nil {
+			time.Sleep(time.Second * 5)
+			continue
+		}
+		safes, err := node.listMixinSafesWithViewKeys(ctx)
+		if err != nil {
+			panic(err)
+		}
+
+		for i := range snapshots {
+			s := &snapshots[i]
+			checkpoint = int64(s.Topology) + 1
+			for j := range s.Transaction {
+				err := node.mixinProcessTransaction(ctx, &s.Transaction[j], safes)
+				if err != nil {
+					panic(err)
+				}
+			}
+		}
+		if len(snapshots) < 100 {
+			time.Sleep(time.Second)
+		}
+
+		err = node.mixinWriteDepositCheckpoint(ctx, checkpoint)
+		if err != nil {
+			panic(err)
+		}
+	}
+}
+
+func (node *Node) mixinWriteDepositCheckpoint(ctx context.Context, num int64) error {
+	return node.store.WriteProperty(ctx, depositCheckpointKey(common.SafeChainMixinKernel), fmt.Sprint(num))
+}
+
+// all outputs have to be checked against all the kernel safes, because the
+// ghost keys could only be recognized with the private view keys
+func (node *Node) listMixinSafesWithViewKeys(ctx context.Context) (map[*store.Safe]crypto.Key, error) {
+	safes, err := node.keeperStore.ListSafesWithState(ctx, keeper.SafeStateApproved)
+	if err != nil {
+		return nil, err
+	}
+	keys := make(map[*store.Safe]crypto.Key)
+	for _, safe := range safes {
+		if safe.Chain != common.SafeChainMixinKernel {
+			continue
+		}
+		priv, err := node.store.ReadAccountantPrivateKey(ctx, safe.Observer)
+		if err != nil {
+			return nil, err
+		}
+		key, err := crypto.KeyFromString(priv)
+		if err != nil {
+			return nil, fmt.Errorf("invalid mixin view key %s", safe.Observer)
+		}
+		keys[safe] = key
+	}
+	return keys, nil
+}
+
+func (node *Node) mixinProcessTransaction(ctx context.Context, tx *m.RPCTransaction, safes map[*store.Safe]crypto.Key) error {
+	if len(safes) == 0 {
+		return nil
+	}
+	asset, err := node.fetchAssetMeta(ctx, tx.Asset)
+	if err != nil {
+		return fmt.Errorf("node.fetchAssetMeta(%s) => %v", tx.Asset, err)
+	}
+	if asset == nil || asset.Chain != common.SafeChainMixinKernel {
+		return nil
+	}
+
+	for index, out := range tx.Output {
+		if out.Type != mc.OutputTypeScript || len(out.Keys) != 3 {
+			continue
+		}
+		if out.Script != mc.NewThresholdScript(m.SafeThreshold).String() {
+			continue
+		}
+		R, err := crypto.KeyFromString(out.Mask)
+		if err != nil || !R.CheckKey() {
+			continue
+		}
+		for safe, a := range safes {
+			mask := m.DeriveGhostMask(&R, &a, uint64(index))
+			keys, err := m.GhostPublicKeys(safe.Holder, safe.Signer, safe.Observer, mask)
+			if err != nil || !slices.Equal(keys, out.Keys) {
+				continue
+			}
+			err = node.mixinWritePendingDeposit(ctx, safe, asset, tx, index, out.Amount, mask)
+			if err != nil {
+				return err
+			}
+			break
+		}
+	}
+	return nil
+}
+
+func (node *Node) mixinCheckDepositChange(ctx context.Context, transactionHash string, outputIndex int) bool {
+	tx, err := node.keeperStore.ReadTransaction(ctx, transactionHash)
+	if err != nil {
+		panic(fmt.Errorf("keeperStore.ReadTransaction(%s) => %v", transactionHash, err))
+	} else if tx == nil {
+		return false
+	}
+	var recipients []map[string]string
+	err = json.Unmarshal([]byte(tx.Data), &recipients)
+	if err != nil || len(recipients) == 0 {
+		panic(fmt.Errorf("store.ReadTransaction(%s) => %s", transactionHash, tx.Data))
+	}
+	return outputIndex >= len(recipients)
+}
+
+func (node *Node) mixinWritePendingDeposit(ctx context.Context, safe *store.Safe, asset *Asset, tx *m.RPCTransaction, index int, value string, mask []byte) error {
+	amount := decimal.RequireFromString(value)
+	minimum := decimal.RequireFromString(node.conf.TransactionMinimum)
+	change := node.mixinCheckDepositChange(ctx, tx.Hash, index)
+	if amount.Cmp(minimum) < 0 && !change {
+		return nil
+	}
+
+	old, _, _, err := node.keeperStore.ReadMixinUTXO(ctx, tx.Hash, index)
+	logger.Printf("keeperStore.ReadMixinUTXO(%s, %d) => %v %v", tx.Hash, index, old, err)
+	if err != nil {
+		return fmt.Errorf("keeperStore.ReadMixinUTXO(%s, %d) => %v", tx.Hash, index, err)
+	} else if old != nil {
+		return nil
+	}
+
+	c, err := node.keeperStore.ReadUnspentMixinUtxoCountForSafe(ctx, safe.Address, asset.AssetId)
+	logger.Printf("keeperStore.ReadUnspentMixinUtxoCountForSafe(%s, %s) => %d %v", safe.Address, asset.AssetId, c, err)
+	if err != nil || c >= m.MaxUnspentUtxo/2 {
+		return err
+	}
+
+	id := common.UniqueId(asset.AssetId, safe.Holder)
+	id = common.UniqueId(id, fmt.Sprintf("%s:%d", tx.Hash, index))
+	createdAt := time.Now().UTC()
+	deposit := &Deposit{
+		TransactionHash: tx.Hash,
+		OutputIndex:     int64(index),
+		AssetId:         asset.AssetId,
+		Amount:          amount.String(),
+		Receiver:        safe.Address,
+		Holder:          safe.Holder,
+		Category:        common.ActionObserverHolderDeposit,
+		State:           common.RequestStateInitial,
+		Chain:           common.SafeChainMixinKernel,
+		RequestId:       id,
+		CreatedAt:       createdAt,
+		UpdatedAt:       createdAt,
+	}
+	// the kernel asset has no contract address, and the ghost mask is
+	// required by the keeper to verify and spend the output
+	deposit.AssetAddress = hex.EncodeToString(mask)
+	if len(tx.Input) > 0 {
+		deposit.Sender = fmt.Sprintf("%s:%d", tx.Input[0].Hash, tx.Input[0].Index)
+	}
+
+	err = node.store.WritePendingDepositIfNotExists(ctx, deposit)
+	if err != nil {
+		return fmt.Errorf("store.WritePendingDeposit(%v) => %v", deposit, err)
+	}
+	return nil
+}
+
+// the kernel snapshots are final once listed, so no confirmations needed
+func (node *Node) mixinConfirmPendingDeposit(ctx context.Context, deposit *Deposit) error {
+	safe, err := node.keeperStore.ReadSafe(ctx, deposit.Holder)
+	logger.Printf("node.mixinConfirmPendingDeposit(%v) => %v %v", deposit, safe, err)
+	if err != nil || safe == nil {
+		return err
+	}
+	bonded, err := node.checkOrDeployKeeperBond(ctx, deposit.Chain, deposit.AssetId, "", deposit.Holder, safe.Address)
+	logger.Printf("node.checkOrDeployKeeperBond(%v) => %t %v", deposit, bonded, err)
+	if err != nil {
+		return fmt.Errorf("node.checkOrDeployKeeperBond(%s) => %v", deposit.Holder, err)
+	} else if !bonded {
+		return nil
+	}
+	return node.sendKeeperDepositTransaction(ctx, deposit, m.ValuePrecision)
+}
+
+func (node *Node) mixinDepositConfirmLoop(ctx context.Context) {
+	for {
+		time.Sleep(3 * time.Second)
+		deposits, err := node.store.ListDeposits(ctx, common.SafeChainMixinKernel, "", common.RequestStateInitial, 0)
+		if err != nil {
+			panic(err)
+		}
+		for _, d := range deposits {
+			err := node.mixinConfirmPendingDeposit(ctx, d)
+			if err != nil {
+				panic(err)
+			}
+		}
+	}
+}
+
+func (node *Node) mixinTransactionApprovalLoop(ctx context.Context) {
+	for {
+		time.Sleep(3 * time.Second)
+		approvals, err := node.store.ListPendingTransactionApprovals(ctx, common.SafeChainMixinKernel)
+		if err != nil {
+			panic(err)
+		}
+		for _, approval := range approvals {
+			err := node.sendToKeeperMixinApproveTransaction(ctx, approval)
+			logger.Verbosef("node.sendToKeeperMixinApproveTransaction(%v) => %v", approval, err)
+			if err != nil {
+				panic(err)
+			}
+		}
+	}
+}
+
+func (node *Node) sendToKeeperMixinApproveTransaction(ctx context.Context, approval *Transaction) error {
+	requests, err := node.keeperStore.ListAllSignaturesForTransaction(ctx, approval.TransactionHash, common.RequestStateDone)
+	if err != nil {
+		return err
+	}
+	st, err := m.UnmarshalSafeTransaction(common.DecodeHexOrPanic(approval.RawTransaction))
+	if err != nil {
+		panic(approval.RawTransaction)
+	}
+	if len(requests) == len(st.Transaction.Inputs) {
+		return nil
+	}
+	if !m.CheckTransactionPartiallySignedBy(approval.RawTransaction, approval.Holder) {
+		panic(approval.RawTransaction)
+	}
+
+	tx, err := node.keeperStore.ReadTransaction(ctx, approval.TransactionHash)
+	if err != nil {
+		return err
+	}
+	id := common.UniqueId(approval.TransactionHash, approval.TransactionHash)
+	rid := uuid.Must(uuid.FromString(tx.RequestId))
+	extra := append(rid.Bytes(), st.InputSignature(0, m.HolderKeyIndex)...)
+	action := common.ActionMixinSafeApproveTransaction
+	err = node.sendKeeperResponse(ctx, tx.Holder, byte(action), approval.Chain, id, extra)
+	logger.Printf("node.sendKeeperResponse(%s, %d, %s, %x) => %v", tx.Holder, action, id, extra, err)
+	if err != nil {
+		return err
+	}
+
+	if approval.UpdatedAt.Add(keeper.SafeSignatureTimeout).After(time.Now()) {
+		return nil
+	}
+	id = common.UniqueId(id, approval.UpdatedAt.String())
+	err = node.sendKeeperResponse(ctx, tx.Holder, byte(action), approval.Chain, id, extra)
+	logger.Printf("node.sendKeeperResponse(%s, %d, %s, %x) => %v", tx.Holder, action, id, extra, err)
+	if err != nil {
+		return err
+	}
+	return node.store.UpdateTransactionApprovalRequestTime(ctx, approval.TransactionHash)
+}
+
+func (node *Node) keeperSaveMixinTransactionSignatures(ctx context.Context, extra []byte) error {
+	logger.Printf("node.keeperSaveMixinTransactionSignatures(%x)", extra)
+	st, err := m.UnmarshalSafeTransaction(extra)
+	if err != nil {
+		return err
+	}
+	tx, err := node.store.ReadTransactionApproval(ctx, st.Hash())
+	if err != nil || tx.State >= common.RequestStateDone {
+		return err
+	}
+	if tx.Chain != common.SafeChainMixinKernel {
+		panic(st.Hash())
+	}
+	// the keeper only has the signer signatures, and the holder signatures
+	// are merged from the transaction approved by the holder
+	hst, err := m.UnmarshalSafeTransaction(common.DecodeHexOrPanic(tx.RawTransaction))
+	if err != nil {
+		panic(tx.RawTransaction)
+	}
+	for i, sm := range st.Transaction.SignaturesMap {
+		sig := hst.InputSignature(i, m.HolderKeyIndex)
+		if sig == nil {
+			panic(st.Hash())
+		}
+		var s crypto.Signature
+		copy(s[:], sig)
+		sm[m.HolderKeyIndex] = &s
+	}
+	raw := hex.EncodeToString(st.Marshal())
+	if !st.IsFullySigned() || !m.CheckTransactionPartiallySignedBy(raw, tx.Holder) {
+		panic(st.Hash())
+	}
+	err = node.store.FinishTransactionSignatures(ctx, st.Hash(), raw)
+	logger.Printf("store.FinishTransactionSignatures(%s) => %v", st.Hash(), err)
+	return err
+}
+
+func (node *Node) mixinTransactionSpendLoop(ctx context.Context) {
+	for {
+		time.Sleep(3 * time.Second)
+		txs, err := node.store.ListFullySignedTransactionApprovals(ctx, common.SafeChainMixinKernel)
+		if err != nil {
+			panic(err)
+		}
+		for _, tx := range txs {
+			st, err := m.UnmarshalSafeTransaction(common.DecodeHexOrPanic(tx.RawTransaction))
+			if err != nil {
+				panic(err)
+			}
+			spentRaw := hex.EncodeToString(st.Transaction.Marshal())
+			spentHash, err := m.RPCSendRawTransaction(ctx, node.conf.MixinRPC, spentRaw)
+			logger.Verbosef("mixin.RPCSendRawTransaction(%s) => %s %v", tx.TransactionHash, spentHash, err)
+			if err != nil {
+				break
+			}
+			if spentHash != st.Hash() {
+				panic(fmt.Errorf("mixin.RPCSendRawTransaction(%s) => %s", st.Hash(), spentHash))
+			}
+			err = node.store.ConfirmFullySignedTransactionApproval(ctx, tx.TransactionHash, spentHash, spentRaw)
+			if err != nil {
+				panic(err)
+			}
+		}
+	}
+}
+
+func (node *Node) httpApproveMixinTransaction(ctx context.Context, raw string) error {
+	logger.Printf("node.httpApproveMixinTransaction(%s)", raw)
+	rb, err := hex.DecodeString(raw)
+	if err != nil {
+		return err
+	}
+	st, err := m.UnmarshalSafeTransaction(rb)
+	if err != nil {
+		return err
+	}
+	txHash := st.Hash()
+
+	approval, err := node.store.ReadTransactionApproval(ctx, txHash)
+	logger.Verbosef("store.ReadTransactionApproval(%s) => %v %v", txHash, approval, err)
+	if err != nil || approval == nil {
+		return err
+	}
+	if approval.State != common.RequestStateInitial {
+		return nil
+	}
+	if m.CheckTransactionPartiallySignedBy(approval.RawTransaction, approval.Holder) {
+		return nil
+	}
+	tx, err := node.keeperStore.ReadTransaction(ctx, txHash)
+	logger.Verbosef("keeperStore.ReadTransaction(%s) => %v %v", txHash, tx, err)
+	if err != nil || tx == nil {
+		return err
+	}
+
+	// only the holder signatures are taken, the transaction and masks are the
+	// ones proposed by the keeper, the transaction is guaranteed by the same
+	// payload hash, and the holder signatures are verified with the masks
+	pst, err := m.UnmarshalSafeTransaction(common.DecodeHexOrPanic(approval.RawTransaction))
+	if err != nil {
+		panic(approval.RawTransaction)
+	}
+	pst.Transaction.SignaturesMap = make([]map[uint16]*crypto.Signature, len(pst.Transaction.Inputs))
+	for i := range pst.Transaction.SignaturesMap {
+		sig := st.InputSignature(i, m.HolderKeyIndex)
+		if sig == nil {
+			return fmt.Errorf("HTTP: %d", http.StatusNotAcceptable)
+		}
+		var s crypto.Signature
+		copy(s[:], sig)
+		pst.Transaction.SignaturesMap[i] = map[uint16]*crypto.Signature{m.HolderKeyIndex: &s}
+	}
+	raw = hex.EncodeToString(pst.Marshal())
+	if !m.CheckTransactionPartiallySignedBy(raw, approval.Holder) {
+		return fmt.Errorf("HTTP: %d", http.StatusNotAcceptable)
+	}
+	err = node.store.AddTransactionPartials(ctx, txHash, raw)
+	logger.Printf("store.AddTransactionPartials(%s) => %v", txHash, err)
+	return err
+}
+
+func (node *Node) httpRevokeMixinTransaction(ctx context.Context, txHash string, sigHex string) error {
+	logger.Printf("node.httpRevokeMixinTransaction(%s, %s)", txHash, sigHex)
+	approval, err := node.store.ReadTransactionApproval(ctx, txHash)
+	logger.Verbosef("store.ReadTransactionApproval(%s) => %v %v", txHash, approval, err)
+	if err != nil || approval == nil {
+		return err
+	}
+	if approval.State != common.RequestStateInitial {
+		return nil
+	}
+	if m.CheckTransactionPartiallySignedBy(approval.RawTransaction, approval.Holder) {
+		return nil
+	}
+
+	tx, err := node.keeperStore.ReadTransaction(ctx, txHash)
+	logger.Verbosef("keeperStore.ReadTransaction(%s) => %v %v", txHash, tx, err)
+	if err != nil {
+		return err
+	}
+
+	sig, err := hex.DecodeString(sigHex)
+	if err != nil {
+		return err
+	}
+	ms := fmt.Sprintf("REVOKE:%s:%s", tx.RequestId, tx.TransactionHash)
+	err = m.VerifyMessageSignature(tx.Holder, []byte(ms), sig)
+	logger.Printf("holder: mixin.VerifyMessageSignature(%v) => %v", tx, err)
+	if err != nil {
+		safe, err := node.keeperStore.ReadSafe(ctx, tx.Holder)
+		if err != nil {
+			return err
+		}
+		err = m.VerifyMessageSignature(safe.Observer, []byte(ms), sig)
+		logger.Printf("observer: mixin.VerifyMessageSignature(%v) => %v", tx, err)
+		if err != nil {
+			return err
+		}
+	}
+
+	id := common.UniqueId(approval.TransactionHash, approval.TransactionHash)
+	rid := uuid.Must(uuid.FromString(tx.RequestId))
+	extra := append(rid.Bytes(), sig...)
+	action := common.ActionMixinSafeRevokeTransaction
+	err = node.sendKeeperResponse(ctx, tx.Holder, byte(action), approval.Chain, id, extra)
+	logger.Printf("node.sendKeeperResponse(%s, %d, %s, %x)", tx.Holder, action, id, extra)
+	if err != nil {
+		return err
+	}
+
+	err = node.store.RevokeTransactionApproval(ctx, txHash, sigHex+":"+approval.RawTransaction)
+	logger.Printf("store.RevokeTransactionApproval(%s) => %v", txHash, err)
+	return err
+}
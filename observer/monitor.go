@@ -0,0 +1,48 @@
+package observer
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/MixinNetwork/mixin/logger"
+	"github.com/MixinNetwork/safe/common"
+	"github.com/fox-one/mixin-sdk-go/v2"
+)
+
+// the alerts are only logged without the monitor conversation, and the
+// same alert is sent again at most once in the interval
+func (node *Node) sendMonitorAlert(ctx context.Context, key, msg string, interval time.Duration) error {
+	key = "monitor-alert-" + key
+	val, err := node.store.ReadProperty(ctx, key)
+	if err != nil {
+		return err
+	}
+	if val != "" {
+		ts, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			panic(val)
+		}
+		if time.Unix(0, ts).Add(interval).After(time.Now()) {
+			return nil
+		}
+	}
+
+	now := time.Now().UTC()
+	msg = fmt.Sprintf("%s\n⏲️ %s", msg, now.Format(time.RFC3339))
+	logger.Printf("node.sendMonitorAlert(%s) => %s", key, msg)
+	if conv := node.conf.MonitorConversaionId; conv != "" {
+		err = node.mixin.SendMessage(ctx, &mixin.MessageRequest{
+			ConversationID: conv,
+			Category:       mixin.MessageCategoryPlainText,
+			MessageID:      common.UniqueId(conv, msg),
+			Data:           base64.RawURLEncoding.EncodeToString([]byte(msg)),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return node.store.WriteProperty(ctx, key, fmt.Sprint(now.UnixNano()))
+}
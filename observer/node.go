@@ -14,6 +14,7 @@ import (
 	"github.com/MixinNetwork/safe/apps/bitcoin"
 	"github.com/MixinNetwork/safe/apps/ethereum"
 	m "github.com/MixinNetwork/safe/apps/mixin"
+	"github.com/MixinNetwork/safe/apps/tron"
 	"github.com/MixinNetwork/safe/common"
 	"github.com/MixinNetwork/safe/common/abi"
 	"github.com/MixinNetwork/safe/keeper/store"
@@ -37,6 +38,7 @@ type Node struct {
 	mixin       *mixin.Client
 	keeperStore *store.SQLite3Store
 	store       *SQLite3Store
+	vault       KeyVault
 }
 
 func NewNode(db *SQLite3Store, kd *store.SQLite3Store, conf *Configuration, keeper *mtg.Configuration, mixin *mixin.Client) *Node {
@@ -52,7 +54,15 @@ func NewNode(db *SQLite3Store, kd *store.SQLite3Store, conf *Configuration, keep
 		mixin:       mixin,
 	}
 	node.aesKey = common.ECDHEd25519(conf.PrivateKey, conf.KeeperPublicKey)
+	node.vault, err = OpenKeyVault(&conf.KeyVault)
+	if err != nil {
+		panic(err)
+	}
 	abi.InitFactoryContractAddress(conf.PolygonFactoryAddress)
+	err = common.RegisterEvmChains(conf.EvmChains)
+	if err != nil {
+		panic(err)
+	}
 	return node
 }
 
@@ -63,34 +73,71 @@ func (node *Node) Boot(ctx context.Context) {
 	}
 	go node.MigrateSafeAssets(ctx)
 
-	for _, chain := range []byte{
+	chains := []byte{
 		common.SafeChainBitcoin,
 		common.SafeChainLitecoin,
+		common.SafeChainBitcoinCash,
 		common.SafeChainPolygon,
 		common.SafeChainEthereum,
-	} {
+		common.SafeChainMVM,
+		common.SafeChainMixinKernel,
+		common.SafeChainSolana,
+		common.SafeChainTron,
+	}
+	for _, c := range node.conf.EvmChains {
+		chains = append(chains, c.Chain)
+	}
+	for _, chain := range chains {
 		err := node.sendPriceInfo(ctx, chain)
 		if err != nil {
 			panic(err)
 		}
 
-		switch chain {
-		case common.SafeChainBitcoin, common.SafeChainLitecoin:
+		switch common.NormalizeSafeChain(chain) {
+		case common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainBitcoinCash:
 			go node.bitcoinNetworkInfoLoop(ctx, chain)
 			go node.bitcoinRPCBlocksLoop(ctx, chain)
 			go node.bitcoinDepositConfirmLoop(ctx, chain)
 			go node.bitcoinTransactionApprovalLoop(ctx, chain)
 			go node.bitcoinTransactionSpendLoop(ctx, chain)
-		case common.SafeChainPolygon, common.SafeChainEthereum:
+			switch chain {
+			case common.SafeChainBitcoin, common.SafeChainLitecoin:
+				go node.bitcoinFeeBumpLoop(ctx, chain)
+			}
+		case common.SafeChainEthereum:
 			go node.ethereumNetworkInfoLoop(ctx, chain)
 			go node.ethereumRPCBlocksLoop(ctx, chain)
 			go node.ethereumDepositConfirmLoop(ctx, chain)
 			go node.ethereumTransactionApprovalLoop(ctx, chain)
 			go node.ethereumTransactionSpendLoop(ctx, chain)
+			if node.conf.EVMBundler != "" {
+				go node.ethereumSafeRelayLoop(ctx, chain)
+			}
+		case common.SafeChainMixinKernel:
+			go node.mixinRPCBlocksLoop(ctx)
+			go node.mixinDepositConfirmLoop(ctx)
+			go node.mixinTransactionApprovalLoop(ctx)
+			go node.mixinTransactionSpendLoop(ctx)
+		case common.SafeChainSolana:
+			go node.solanaRPCBlocksLoop(ctx)
+			go node.solanaDepositConfirmLoop(ctx)
+			go node.solanaTransactionApprovalLoop(ctx)
+			go node.solanaTransactionSpendLoop(ctx)
+		case common.SafeChainTron:
+			go node.tronNetworkInfoLoop(ctx)
+			go node.tronRPCBlocksLoop(ctx)
+			go node.tronDepositConfirmLoop(ctx)
+			go node.tronTransactionApprovalLoop(ctx)
+			go node.tronTransactionSpendLoop(ctx)
 		}
 	}
+	for _, pool := range node.conf.AccountantPools {
+		go node.bitcoinAccountantPoolLoop(ctx, pool)
+	}
 	go node.safeKeyLoop(ctx, common.SafeChainBitcoin)
 	go node.safeKeyLoop(ctx, common.SafeChainEthereum)
+	go node.safeKeyLoop(ctx, common.SafeChainMixinKernel)
+	go node.safeKeyLoop(ctx, common.SafeChainSolana)
 	go node.mixinWithdrawalsLoop(ctx)
 	go node.sendAccountApprovals(ctx)
 	node.snapshotsLoop(ctx)
@@ -98,11 +145,17 @@ func (node *Node) Boot(ctx context.Context) {
 
 func (node *Node) sendPriceInfo(ctx context.Context, chain byte) error {
 	var assetId string
-	switch chain {
-	case common.SafeChainBitcoin, common.SafeChainLitecoin:
+	switch common.NormalizeSafeChain(chain) {
+	case common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainBitcoinCash:
 		_, assetId = node.bitcoinParams(chain)
-	case common.SafeChainPolygon, common.SafeChainEthereum:
+	case common.SafeChainEthereum:
 		_, assetId = node.ethereumParams(chain)
+	case common.SafeChainMixinKernel:
+		assetId = common.SafeMixinKernelAssetId
+	case common.SafeChainSolana:
+		assetId = common.SafeSolanaChainId
+	case common.SafeChainTron:
+		assetId = common.SafeTronChainId
 	default:
 		panic(chain)
 	}
@@ -124,7 +177,7 @@ func (node *Node) sendPriceInfo(ctx context.Context, chain byte) error {
 	if minimum.IntPart() < 10000 {
 		panic(node.conf.TransactionMinimum)
 	}
-	dummy := node.bitcoinDummyHolder()
+	dummy := node.chainDummyHolder(chain)
 	id := common.UniqueId("ActionObserverSetOperationParams", dummy)
 	id = common.UniqueId(id, assetId)
 	id = common.UniqueId(id, asset.AssetId)
@@ -164,8 +217,8 @@ func (node *Node) sendAccountApprovals(ctx context.Context) {
 			var extra []byte
 			var action byte
 			var assetId string
-			switch sp.Chain {
-			case common.SafeChainBitcoin, common.SafeChainLitecoin:
+			switch common.NormalizeSafeChain(sp.Chain) {
+			case common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainBitcoinCash:
 				_, assetId = node.bitcoinParams(sp.Chain)
 				sig, err := base64.RawURLEncoding.DecodeString(account.Signature.String)
 				if err != nil {
@@ -173,7 +226,7 @@ func (node *Node) sendAccountApprovals(ctx context.Context) {
 				}
 				action = common.ActionBitcoinSafeApproveAccount
 				extra = append(rid.Bytes(), sig...)
-			case common.SafeChainPolygon, common.SafeChainEthereum:
+			case common.SafeChainEthereum:
 				_, assetId = node.ethereumParams(sp.Chain)
 				sig, err := hex.DecodeString(account.Signature.String)
 				if err != nil {
@@ -181,6 +234,30 @@ func (node *Node) sendAccountApprovals(ctx context.Context) {
 				}
 				action = common.ActionEthereumSafeApproveAccount
 				extra = append(rid.Bytes(), sig...)
+			case common.SafeChainMixinKernel:
+				assetId = common.SafeMixinKernelAssetId
+				sig, err := hex.DecodeString(account.Signature.String)
+				if err != nil {
+					panic(err)
+				}
+				action = common.ActionMixinSafeApproveAccount
+				extra = append(rid.Bytes(), sig...)
+			case common.SafeChainSolana:
+				assetId = common.SafeSolanaChainId
+				sig, err := hex.DecodeString(account.Signature.String)
+				if err != nil {
+					panic(err)
+				}
+				action = common.ActionSolanaSafeApproveAccount
+				extra = append(rid.Bytes(), sig...)
+			case common.SafeChainTron:
+				assetId = common.SafeTronChainId
+				sig, err := hex.DecodeString(account.Signature.String)
+				if err != nil {
+					panic(err)
+				}
+				action = common.ActionTronSafeApproveAccount
+				extra = append(rid.Bytes(), sig...)
 			default:
 				panic(sp.Chain)
 			}
@@ -326,15 +403,15 @@ func (node *Node) processMixinWithdrawalSnapshot(ctx context.Context, s *m.RPCSn
 			return err
 		}
 		hash := string(extra[64:])
-		switch asset.Chain {
-		case common.SafeChainBitcoin, common.SafeChainLitecoin:
+		switch common.NormalizeSafeChain(asset.Chain) {
+		case common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainBitcoinCash:
 			rpc, _ := node.bitcoinParams(asset.Chain)
 			btx, err := bitcoin.RPCGetTransaction(asset.Chain, rpc, hash)
 			if err != nil {
 				return err
 			}
 			return node.bitcoinProcessTransaction(ctx, btx, asset.Chain)
-		case common.SafeChainEthereum, common.SafeChainPolygon:
+		case common.SafeChainEthereum:
 			rpc, _ := node.ethereumParams(asset.Chain)
 			etx, err := ethereum.RPCGetTransactionByHash(rpc, hash)
 			if err != nil {
@@ -431,7 +508,7 @@ func (node *Node) handleKeeperResponse(ctx context.Context, s *mixin.SafeSnapsho
 	switch s.AssetID {
 	case node.conf.AssetId:
 		switch op.Type {
-		case common.ActionBitcoinSafeApproveAccount, common.ActionEthereumSafeApproveAccount:
+		case common.ActionBitcoinSafeApproveAccount, common.ActionEthereumSafeApproveAccount, common.ActionMixinSafeApproveAccount, common.ActionSolanaSafeApproveAccount, common.ActionTronSafeApproveAccount:
 			return false, nil
 		}
 		if s.Amount.Cmp(decimal.NewFromInt(1)) < 0 {
@@ -439,7 +516,7 @@ func (node *Node) handleKeeperResponse(ctx context.Context, s *mixin.SafeSnapsho
 		}
 	case params.OperationPriceAsset:
 		switch op.Type {
-		case common.ActionBitcoinSafeApproveAccount, common.ActionEthereumSafeApproveAccount:
+		case common.ActionBitcoinSafeApproveAccount, common.ActionEthereumSafeApproveAccount, common.ActionMixinSafeApproveAccount, common.ActionSolanaSafeApproveAccount, common.ActionTronSafeApproveAccount:
 		default:
 			return false, nil
 		}
@@ -463,18 +540,30 @@ func (node *Node) handleKeeperResponse(ctx context.Context, s *mixin.SafeSnapsho
 	}
 
 	switch op.Type {
-	case common.ActionBitcoinSafeProposeTransaction, common.ActionEthereumSafeProposeTransaction:
+	case common.ActionBitcoinSafeProposeTransaction, common.ActionEthereumSafeProposeTransaction, common.ActionMixinSafeProposeTransaction, common.ActionSolanaSafeProposeTransaction, common.ActionTronSafeProposeTransaction:
 		return true, node.keeperSaveTransactionProposal(ctx, chain, data, s.CreatedAt)
 	case common.ActionBitcoinSafeApproveTransaction:
 		return true, node.keeperCombineBitcoinTransactionSignatures(ctx, data)
 	case common.ActionEthereumSafeApproveTransaction:
 		return true, node.keeperVerifyEthereumTransactionSignatures(ctx, data)
-	case common.ActionBitcoinSafeProposeAccount, common.ActionEthereumSafeProposeAccount:
+	case common.ActionMixinSafeApproveTransaction:
+		return true, node.keeperSaveMixinTransactionSignatures(ctx, data)
+	case common.ActionSolanaSafeApproveTransaction:
+		return true, node.keeperSaveSolanaTransactionSignatures(ctx, data)
+	case common.ActionTronSafeApproveTransaction:
+		return true, node.keeperSaveTronTransactionSignatures(ctx, data)
+	case common.ActionBitcoinSafeProposeAccount, common.ActionEthereumSafeProposeAccount, common.ActionMixinSafeProposeAccount, common.ActionSolanaSafeProposeAccount, common.ActionTronSafeProposeAccount:
 		return true, node.keeperSaveAccountProposal(ctx, chain, data, s.CreatedAt)
 	case common.ActionBitcoinSafeApproveAccount:
 		return true, node.deployBitcoinSafeBond(ctx, data)
 	case common.ActionEthereumSafeApproveAccount:
 		return true, node.deployEthereumGnosisSafeAccount(ctx, data)
+	case common.ActionMixinSafeApproveAccount:
+		return true, node.deployMixinSafeBond(ctx, data)
+	case common.ActionSolanaSafeApproveAccount:
+		return true, node.deploySolanaSafeBond(ctx, data)
+	case common.ActionTronSafeApproveAccount:
+		return true, node.deployTronSafeAccount(ctx, data)
 	}
 	return true, nil
 }
@@ -493,7 +582,7 @@ func (node *Node) writeSnapshotsCheckpoint(ctx context.Context, offset time.Time
 
 func (node *Node) readDepositCheckpoint(ctx context.Context, chain byte) (int64, error) {
 	key := depositCheckpointKey(chain)
-	min := depositCheckpointDefault(chain)
+	min := node.depositCheckpointDefault(chain)
 	ckt, err := node.store.ReadProperty(ctx, key)
 	if err != nil || ckt == "" {
 		return min, err
@@ -530,27 +619,47 @@ func (node *Node) safeUser() bot.SafeUser {
 	}
 }
 
-func depositCheckpointDefault(chain byte) int64 {
+func (node *Node) depositCheckpointDefault(chain byte) int64 {
 	switch chain {
 	case common.SafeChainBitcoin:
 		return 802220
 	case common.SafeChainLitecoin:
 		return 2523300
+	case common.SafeChainBitcoinCash:
+		return 820000
 	case common.SafeChainPolygon:
 		return 52950000
 	case common.SafeChainEthereum:
 		return 19175473
-	default:
-		panic(chain)
+	case common.SafeChainMVM:
+		return 52880000
+	case common.SafeChainMixinKernel:
+		return 4655227
+	case common.SafeChainSolana:
+		return 248200000
+	case common.SafeChainTron:
+		return 66000000
+	}
+	for _, c := range node.conf.EvmChains {
+		if c.Chain == chain {
+			return c.Checkpoint
+		}
 	}
+	panic(chain)
 }
 
 func depositCheckpointKey(chain byte) string {
-	switch chain {
-	case common.SafeChainBitcoin, common.SafeChainLitecoin:
+	switch common.NormalizeSafeChain(chain) {
+	case common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainBitcoinCash:
 		return fmt.Sprintf("bitcoin-deposit-checkpoint-%d", chain)
-	case common.SafeChainEthereum, common.SafeChainPolygon:
+	case common.SafeChainEthereum:
 		return fmt.Sprintf("ethereum-deposit-checkpoint-%d", chain)
+	case common.SafeChainMixinKernel:
+		return fmt.Sprintf("mixin-deposit-checkpoint-%d", chain)
+	case common.SafeChainSolana:
+		return fmt.Sprintf("solana-deposit-checkpoint-%d", chain)
+	case common.SafeChainTron:
+		return fmt.Sprintf("tron-deposit-checkpoint-%d", chain)
 	default:
 		panic(chain)
 	}
@@ -570,13 +679,19 @@ func (node *Node) getChainFinalizationDelay(chain byte) int64 {
 		return 3
 	case common.SafeChainLitecoin:
 		return 6
+	case common.SafeChainBitcoinCash:
+		return 6
 	case common.SafeChainEthereum:
 		return 32
 	case common.SafeChainPolygon:
 		return 512
-	default:
-		panic(chain)
+	case common.SafeChainTron:
+		return tron.TransactionConfirmations
 	}
+	if c := ethereum.GetEvmChain(chain); c != nil {
+		return c.Finalization
+	}
+	panic(chain)
 }
 
 func (node *Node) getSafeChainFromAssetChainId(chainId string) byte {
@@ -585,10 +700,19 @@ func (node *Node) getSafeChainFromAssetChainId(chainId string) byte {
 		return common.SafeChainBitcoin
 	case common.SafeLitecoinChainId:
 		return common.SafeChainLitecoin
+	case common.SafeBitcoinCashChainId:
+		return common.SafeChainBitcoinCash
 	case common.SafeEthereumChainId:
 		return common.SafeChainEthereum
 	case common.SafePolygonChainId:
 		return common.SafeChainPolygon
+	case common.SafeMVMChainId:
+		return common.SafeChainMVM
+	case common.SafeMixinKernelAssetId:
+		return common.SafeChainMixinKernel
+	}
+	if c := ethereum.GetEvmChainByMixinChainId(chainId); c != nil {
+		return c.Chain
 	}
 	return 0
 }
@@ -0,0 +1,246 @@
+package observer
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/MixinNetwork/mixin/logger"
+	"github.com/MixinNetwork/safe/apps/bitcoin"
+	"github.com/MixinNetwork/safe/common"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+const (
+	accountantPoolMaxInputs    = 100
+	accountantPoolMaxOutputs   = 50
+	accountantPoolAlertDelay   = 6 * time.Hour
+	bitcoinExpectedSpendVBytes = 400
+)
+
+// the accountant pool keeps target count of fee utxos in each size band, a band
+// covers the satoshi from its size to the next band size, and the last band
+// covers up to twice of its size
+type AccountantPool struct {
+	Chain              byte    `toml:"chain"`
+	Bands              []int64 `toml:"bands"`
+	Target             int     `toml:"target"`
+	ConsolidateFeeRate int64   `toml:"consolidate-fee-rate"`
+	ExpectedSpends     int64   `toml:"expected-spends"`
+}
+
+func (p *AccountantPool) validate() error {
+	switch p.Chain {
+	case common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainBitcoinCash:
+	default:
+		return fmt.Errorf("invalid accountant pool chain %d", p.Chain)
+	}
+	if len(p.Bands) == 0 || p.Target <= 0 {
+		return fmt.Errorf("invalid accountant pool %d bands %v target %d", p.Chain, p.Bands, p.Target)
+	}
+	for i, b := range p.Bands {
+		if b < bitcoin.ValueDust(p.Chain) || (i > 0 && b <= p.Bands[i-1]) {
+			return fmt.Errorf("invalid accountant pool %d bands %v", p.Chain, p.Bands)
+		}
+	}
+	if p.ConsolidateFeeRate < 0 || p.ExpectedSpends < 0 {
+		return fmt.Errorf("invalid accountant pool %d fee rate %d spends %d", p.Chain, p.ConsolidateFeeRate, p.ExpectedSpends)
+	}
+	return nil
+}
+
+// the band index of the satoshi, -1 for the dust below the first band,
+// and the bands count for the large utxo to be split
+func (p *AccountantPool) band(satoshi int64) int {
+	last := len(p.Bands) - 1
+	if satoshi >= 2*p.Bands[last] {
+		return last + 1
+	}
+	for i := last; i >= 0; i-- {
+		if satoshi >= p.Bands[i] {
+			return i
+		}
+	}
+	return -1
+}
+
+// the outputs to split for the bands below the target count, from the
+// smallest band, and limited by the outputs count of a transaction
+func (p *AccountantPool) deficits(utxos []*Output) []int64 {
+	counts := make([]int, len(p.Bands))
+	for _, u := range utxos {
+		i := p.band(u.Satoshi)
+		if i >= 0 && i < len(p.Bands) {
+			counts[i] = counts[i] + 1
+		}
+	}
+	var outputs []int64
+	for i, b := range p.Bands {
+		for c := counts[i]; c < p.Target && len(outputs) < accountantPoolMaxOutputs; c++ {
+			outputs = append(outputs, b)
+		}
+	}
+	return outputs
+}
+
+func (node *Node) bitcoinAccountantPoolLoop(ctx context.Context, pool *AccountantPool) {
+	for {
+		time.Sleep(10 * time.Minute)
+		err := node.bitcoinManageAccountantPool(ctx, pool)
+		logger.Printf("node.bitcoinManageAccountantPool(%d) => %v", pool.Chain, err)
+	}
+}
+
+// the dust is consolidated only when the fee rate is low, otherwise the
+// largest utxo is split for the bands below the target count
+func (node *Node) bitcoinManageAccountantPool(ctx context.Context, pool *AccountantPool) error {
+	rpc, _ := node.bitcoinParams(pool.Chain)
+	fvb, err := bitcoin.EstimateAvgFee(pool.Chain, rpc)
+	if err != nil {
+		return err
+	}
+	err = node.bitcoinCheckAccountantBalance(ctx, pool, fvb)
+	if err != nil {
+		return err
+	}
+
+	utxos, err := node.store.ReadBitcoinUTXOs(ctx, pool.Chain)
+	if err != nil {
+		return err
+	}
+	var dust, large []*Output
+	for _, u := range utxos {
+		switch pool.band(u.Satoshi) {
+		case -1:
+			dust = append(dust, u)
+		case len(pool.Bands):
+			large = append(large, u)
+		}
+	}
+
+	if fvb <= pool.ConsolidateFeeRate && len(dust) > 1 {
+		return node.bitcoinConsolidateAccountantUTXOs(ctx, pool, dust, fvb)
+	}
+	outputs := pool.deficits(utxos)
+	if len(outputs) == 0 || len(large) == 0 {
+		return nil
+	}
+	input := slices.MaxFunc(large, func(a, b *Output) int {
+		return int(a.Satoshi - b.Satoshi)
+	})
+	return node.bitcoinSplitAccountantUTXO(ctx, pool, input, outputs, fvb)
+}
+
+func (node *Node) bitcoinConsolidateAccountantUTXOs(ctx context.Context, pool *AccountantPool, dust []*Output, fvb int64) error {
+	if len(dust) > accountantPoolMaxInputs {
+		dust = dust[:accountantPoolMaxInputs]
+	}
+	var total int64
+	for _, u := range dust {
+		total = total + u.Satoshi
+	}
+	fee := fvb * bitcoinAccountantTransactionSize(pool.Chain, len(dust), 1)
+	if total-fee < pool.Bands[0] {
+		return nil
+	}
+	return node.bitcoinSendAccountantTransaction(ctx, pool.Chain, dust, []int64{total - fee}, dust[0].Address)
+}
+
+func (node *Node) bitcoinSplitAccountantUTXO(ctx context.Context, pool *AccountantPool, input *Output, bands []int64, fvb int64) error {
+	var outputs []int64
+	var total int64
+	for _, b := range bands {
+		fee := fvb * bitcoinAccountantTransactionSize(pool.Chain, 1, len(outputs)+2)
+		if input.Satoshi < total+b+fee {
+			break
+		}
+		outputs = append(outputs, b)
+		total = total + b
+	}
+	if len(outputs) == 0 {
+		return nil
+	}
+	fee := fvb * bitcoinAccountantTransactionSize(pool.Chain, 1, len(outputs)+1)
+	if change := input.Satoshi - total - fee; change >= bitcoin.ValueDust(pool.Chain) {
+		outputs = append(outputs, change)
+	}
+	return node.bitcoinSendAccountantTransaction(ctx, pool.Chain, []*Output{input}, outputs, input.Address)
+}
+
+// the inputs are assigned to the transaction before the broadcast, so
+// they could not be taken by the fee inputs of the safe spends
+func (node *Node) bitcoinSendAccountantTransaction(ctx context.Context, chain byte, inputs []*Output, outputs []int64, receiver string) error {
+	script, err := bitcoin.ParseAddress(receiver, chain)
+	if err != nil {
+		return err
+	}
+	msgTx := wire.NewMsgTx(2)
+	for _, in := range inputs {
+		hash, err := chainhash.NewHashFromStr(in.TransactionHash)
+		if err != nil {
+			return err
+		}
+		msgTx.AddTxIn(&wire.TxIn{
+			PreviousOutPoint: wire.OutPoint{Hash: *hash, Index: in.Index},
+			Sequence:         bitcoin.MaxTransactionSequence,
+		})
+	}
+	for _, satoshi := range outputs {
+		msgTx.AddTxOut(wire.NewTxOut(satoshi, script))
+	}
+	for idx, in := range inputs {
+		accountant, err := node.accountantSigner(ctx, in.Address)
+		if err != nil {
+			return err
+		}
+		err = bitcoin.SignAccountantInputWithSigner(msgTx, idx, in.Satoshi, accountant, chain)
+		if err != nil {
+			return err
+		}
+	}
+	raw, err := bitcoin.MarshalWiredTransaction(msgTx, wire.WitnessEncoding, chain)
+	if err != nil {
+		return err
+	}
+
+	hash := msgTx.TxHash().String()
+	err = node.store.AssignBitcoinUTXOsForTransaction(ctx, inputs, hash)
+	if err != nil {
+		return err
+	}
+	err = node.bitcoinBroadcastTransaction(hash, raw, chain)
+	if err != nil {
+		rerr := node.store.ReleaseBitcoinUTXOsForTransaction(ctx, inputs, hash)
+		if rerr != nil {
+			panic(rerr)
+		}
+		return fmt.Errorf("node.bitcoinBroadcastTransaction(%s, %x) => %v", hash, raw, err)
+	}
+	err = node.store.WriteBitcoinAccountantTransaction(ctx, msgTx, receiver, chain)
+	logger.Printf("store.WriteBitcoinAccountantTransaction(%s, %d, %d) => %v", hash, len(inputs), len(outputs), err)
+	if err != nil {
+		panic(err)
+	}
+	return nil
+}
+
+func (node *Node) bitcoinCheckAccountantBalance(ctx context.Context, pool *AccountantPool, fvb int64) error {
+	if pool.ExpectedSpends == 0 {
+		return nil
+	}
+	count, satoshi, err := node.readChainAccountantBalance(ctx, int(pool.Chain))
+	if err != nil {
+		return err
+	}
+	spends := int64(satoshi) / (fvb * bitcoinExpectedSpendVBytes)
+	if spends >= pool.ExpectedSpends {
+		return nil
+	}
+	msg := fmt.Sprintf("🚨 Observer accountant balance low for chain %d\n", pool.Chain)
+	msg = msg + fmt.Sprintf("💰 Outputs: %d %d\n", count, satoshi)
+	msg = msg + fmt.Sprintf("🧾 Spends: %d/%d at %d", spends, pool.ExpectedSpends, fvb)
+	key := fmt.Sprintf("accountant-balance-%d", pool.Chain)
+	return node.sendMonitorAlert(ctx, key, msg, accountantPoolAlertDelay)
+}
@@ -0,0 +1,77 @@
+package observer
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/safe/apps/bitcoin"
+	"github.com/MixinNetwork/safe/common"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccountantPool(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+
+	pool := &AccountantPool{Chain: common.SafeChainBitcoin, Bands: []int64{5000, 20000}, Target: 2}
+	require.Nil(pool.validate())
+	require.NotNil((&AccountantPool{Chain: common.SafeChainEthereum, Bands: []int64{5000}, Target: 2}).validate())
+	require.NotNil((&AccountantPool{Chain: common.SafeChainBitcoin, Bands: []int64{20000, 5000}, Target: 2}).validate())
+	require.NotNil((&AccountantPool{Chain: common.SafeChainBitcoin, Bands: []int64{500}, Target: 2}).validate())
+	require.NotNil((&AccountantPool{Chain: common.SafeChainBitcoin, Bands: []int64{5000}}).validate())
+	require.Equal(-1, pool.band(4999))
+	require.Equal(0, pool.band(5000))
+	require.Equal(0, pool.band(19999))
+	require.Equal(1, pool.band(39999))
+	require.Equal(2, pool.band(40000))
+
+	root, err := os.MkdirTemp("", "safe-pool-test")
+	require.Nil(err)
+	defer os.RemoveAll(root)
+	db, err := OpenSQLite3Store(root + "/safe.sqlite3")
+	require.Nil(err)
+
+	now := time.Now().UTC()
+	for i, satoshi := range []int64{1000, 2000, 6000, 100000} {
+		err = db.WriteBitcoinUTXOIfNotExists(ctx, &Output{
+			TransactionHash: testBumpFeeInputHash,
+			Index:           uint32(i),
+			Address:         testBumpAccountant,
+			Satoshi:         satoshi,
+			Chain:           common.SafeChainBitcoin,
+			State:           common.RequestStateInitial,
+			CreatedAt:       now,
+			UpdatedAt:       now,
+		})
+		require.Nil(err)
+	}
+	utxos, err := db.ReadBitcoinUTXOs(ctx, common.SafeChainBitcoin)
+	require.Nil(err)
+	require.Len(utxos, 4)
+	require.Equal([]int64{5000, 20000, 20000}, pool.deficits(utxos))
+
+	hash, _ := chainhash.NewHashFromStr(testBumpFeeInputHash)
+	script, err := bitcoin.ParseAddress(testBumpAccountant, common.SafeChainBitcoin)
+	require.Nil(err)
+	msgTx := wire.NewMsgTx(2)
+	msgTx.AddTxIn(&wire.TxIn{PreviousOutPoint: wire.OutPoint{Hash: *hash, Index: 3}})
+	for _, satoshi := range []int64{5000, 20000, 20000, 50000} {
+		msgTx.AddTxOut(wire.NewTxOut(satoshi, script))
+	}
+	err = db.WriteBitcoinAccountantTransaction(ctx, msgTx, testBumpAccountant, common.SafeChainBitcoin)
+	require.NotNil(err)
+	inputs := []*Output{utxos[3]}
+	err = db.AssignBitcoinUTXOsForTransaction(ctx, inputs, msgTx.TxHash().String())
+	require.Nil(err)
+	err = db.WriteBitcoinAccountantTransaction(ctx, msgTx, testBumpAccountant, common.SafeChainBitcoin)
+	require.Nil(err)
+
+	utxos, err = db.ReadBitcoinUTXOs(ctx, common.SafeChainBitcoin)
+	require.Nil(err)
+	require.Len(utxos, 7)
+	require.Len(pool.deficits(utxos), 0)
+}
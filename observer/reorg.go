@@ -0,0 +1,253 @@
+package observer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/MixinNetwork/mixin/logger"
+	"github.com/MixinNetwork/safe/apps/bitcoin"
+	"github.com/MixinNetwork/safe/apps/ethereum"
+	"github.com/MixinNetwork/safe/common"
+)
+
+const (
+	chainBlocksWindow          = 2048
+	chainReorgAlertDelay       = time.Hour
+	depositOrphanedAlertDelay  = 24 * time.Hour
+	bitcoinTransactionNotFound = "No such mempool or blockchain transaction"
+)
+
+const (
+	chainTransactionFinalized = iota + 1
+	chainTransactionPending
+	chainTransactionOrphaned
+)
+
+// the mempool has no block hash, and only advances the checkpoint
+func (node *Node) writeChainBlockCheckpoint(ctx context.Context, chain byte, height int64, hash string) error {
+	key := depositCheckpointKey(chain)
+	if hash == "" {
+		return node.store.WriteProperty(ctx, key, fmt.Sprint(height+1))
+	}
+	block := &ChainBlock{
+		Chain:     chain,
+		Height:    height,
+		Hash:      hash,
+		CreatedAt: time.Now().UTC(),
+	}
+	return node.store.WriteChainBlockCheckpoint(ctx, block, key, chainBlocksWindow)
+}
+
+// the blocks below the checkpoint are compared with the chain from the
+// highest one, and the checkpoint is rewound to the lowest changed block,
+// the deposits are verified later after the rewound blocks are rescanned
+func (node *Node) checkChainReorg(ctx context.Context, chain byte, checkpoint int64) (bool, error) {
+	fork := checkpoint
+	for {
+		b, err := node.store.ReadChainBlock(ctx, chain, fork-1)
+		if err != nil || b == nil {
+			return false, err
+		}
+		hash, err := node.chainBlockHash(chain, b.Height)
+		if err != nil {
+			return false, err
+		}
+		if hash == b.Hash {
+			break
+		}
+		logger.Printf("node.checkChainReorg(%d, %d) => %s %s", chain, b.Height, b.Hash, hash)
+		fork = b.Height
+		prev, err := node.store.ReadChainBlock(ctx, chain, fork-1)
+		if err != nil {
+			return false, err
+		} else if prev == nil {
+			return false, node.alertDeepChainReorg(ctx, chain, checkpoint, fork)
+		}
+	}
+	if fork == checkpoint {
+		return false, nil
+	}
+
+	last, err := node.store.ReadChainBlock(ctx, chain, fork-1)
+	if err != nil {
+		return false, err
+	}
+	now := time.Now().UTC()
+	r := &ChainReorg{
+		Chain:      chain,
+		Fork:       fork,
+		Checkpoint: checkpoint,
+		ForkedAt:   last.CreatedAt,
+		State:      common.RequestStateInitial,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	err = node.store.RewindChainBlockCheckpoint(ctx, r, depositCheckpointKey(chain))
+	logger.Printf("store.RewindChainBlockCheckpoint(%d, %d, %d) => %v", chain, checkpoint, fork, err)
+	if err != nil {
+		return false, err
+	}
+
+	msg := fmt.Sprintf("⚠️ Observer chain %d reorganized\n", chain)
+	msg = msg + fmt.Sprintf("⛓️ Checkpoint: %d => %d", checkpoint, fork)
+	key := fmt.Sprintf("chain-reorg-%d-%d", chain, fork)
+	return true, node.sendMonitorAlert(ctx, key, msg, chainReorgAlertDelay)
+}
+
+// the deposit loop can't continue without the common block, and must be
+// fixed manually by rewinding the checkpoint below the fork
+func (node *Node) alertDeepChainReorg(ctx context.Context, chain byte, checkpoint, fork int64) error {
+	msg := fmt.Sprintf("🚨🚨 Observer chain %d reorganized deeper than the window %d\n", chain, chainBlocksWindow)
+	msg = msg + fmt.Sprintf("⛓️ Checkpoint: %d => %d", checkpoint, fork)
+	key := fmt.Sprintf("chain-reorg-deep-%d", chain)
+	err := node.sendMonitorAlert(ctx, key, msg, chainReorgAlertDelay)
+	if err != nil {
+		return err
+	}
+	return fmt.Errorf("node.checkChainReorg(%d, %d) => reorg deeper than the window", chain, fork)
+}
+
+// the reorganization is verified only after the deposit loop has rescanned
+// all the rewound blocks, which are then finalized in the new chain
+func (node *Node) verifyChainReorgs(ctx context.Context, chain byte, checkpoint int64) error {
+	reorgs, err := node.store.ListPendingChainReorgs(ctx, chain, checkpoint)
+	if err != nil {
+		return err
+	}
+	for _, r := range reorgs {
+		pending, err := node.verifyOrphanedDeposits(ctx, chain, r.ForkedAt)
+		logger.Printf("node.verifyOrphanedDeposits(%d, %d) => %t %v", chain, r.Fork, pending, err)
+		if err != nil || pending {
+			return err
+		}
+		err = node.store.FinishChainReorg(ctx, chain, r.Fork)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// the deposits written since the last common block are verified again, and
+// the orphaned deposit already sent to the keeper needs manual handling, the
+// deposit not finalized yet is pending and verified again later
+func (node *Node) verifyOrphanedDeposits(ctx context.Context, chain byte, offset time.Time) (bool, error) {
+	deposits, err := node.store.ListChainDepositsSince(ctx, chain, offset)
+	if err != nil {
+		return false, err
+	}
+	var pending bool
+	for _, d := range deposits {
+		if d.State == common.RequestStateFailed {
+			continue
+		}
+		state, err := node.chainTransactionState(chain, d.TransactionHash)
+		logger.Printf("node.chainTransactionState(%d, %s) => %d %v", chain, d.TransactionHash, state, err)
+		if err != nil {
+			return false, err
+		}
+		switch state {
+		case chainTransactionFinalized:
+			continue
+		case chainTransactionPending:
+			pending = true
+			continue
+		}
+
+		sent := d.State == common.RequestStateDone
+		if !sent {
+			req, err := node.keeperStore.ReadRequest(ctx, d.RequestId)
+			if err != nil {
+				return false, err
+			}
+			sent = req != nil
+		}
+		err = node.store.WriteOrphanedDeposit(ctx, d, sent)
+		logger.Printf("store.WriteOrphanedDeposit(%v, %t) => %v", d, sent, err)
+		if err != nil {
+			return false, err
+		} else if !sent {
+			continue
+		}
+
+		msg := fmt.Sprintf("🚨🚨 Observer deposit orphaned by chain %d reorg\n", chain)
+		msg = msg + fmt.Sprintf("🔗 Transaction: %s:%d\n", d.TransactionHash, d.OutputIndex)
+		msg = msg + fmt.Sprintf("💰 Amount: %s %s\n", d.Amount, d.AssetId)
+		msg = msg + fmt.Sprintf("🔑 Holder: %s\n", d.Holder)
+		msg = msg + fmt.Sprintf("🆔 Request: %s", d.RequestId)
+		err = node.sendMonitorAlert(ctx, "deposit-orphaned-"+d.RequestId, msg, depositOrphanedAlertDelay)
+		if err != nil {
+			return false, err
+		}
+	}
+	return pending, nil
+}
+
+func (node *Node) chainBlockHash(chain byte, height int64) (string, error) {
+	switch common.NormalizeSafeChain(chain) {
+	case common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainBitcoinCash:
+		rpc, _ := node.bitcoinParams(chain)
+		return bitcoin.RPCGetBlockHash(rpc, height)
+	case common.SafeChainEthereum:
+		rpc, _ := node.ethereumParams(chain)
+		return ethereum.RPCGetBlockHash(rpc, height)
+	default:
+		panic(chain)
+	}
+}
+
+// the transaction in mempool or in a block not finalized yet is pending, and
+// the transaction not found or only in a stale block is orphaned
+func (node *Node) chainTransactionState(chain byte, hash string) (int, error) {
+	delay := node.getChainFinalizationDelay(chain)
+	switch common.NormalizeSafeChain(chain) {
+	case common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainBitcoinCash:
+		rpc, _ := node.bitcoinParams(chain)
+		tx, err := bitcoin.RPCGetTransaction(chain, rpc, hash)
+		if err != nil && strings.Contains(err.Error(), bitcoinTransactionNotFound) {
+			return chainTransactionOrphaned, nil
+		} else if err != nil {
+			return 0, err
+		} else if tx.BlockHash == "" {
+			return chainTransactionPending, nil
+		}
+		block, err := bitcoin.RPCGetBlock(rpc, tx.BlockHash)
+		if err != nil {
+			return 0, err
+		}
+		switch {
+		case int64(block.Confirmations) >= delay:
+			return chainTransactionFinalized, nil
+		case block.Confirmations > 0:
+			return chainTransactionPending, nil
+		default:
+			return chainTransactionOrphaned, nil
+		}
+	case common.SafeChainEthereum:
+		rpc, _ := node.ethereumParams(chain)
+		tx, err := ethereum.RPCGetTransactionByHash(rpc, hash)
+		if err != nil {
+			return 0, err
+		} else if tx.Hash == "" {
+			return chainTransactionOrphaned, nil
+		} else if tx.BlockHash == "" {
+			return chainTransactionPending, nil
+		}
+		block, err := ethereum.RPCGetBlockHash(rpc, int64(tx.BlockHeight))
+		if err != nil {
+			return 0, err
+		}
+		height, err := ethereum.RPCGetBlockHeight(rpc)
+		if err != nil {
+			return 0, err
+		}
+		if block == tx.BlockHash && int64(tx.BlockHeight)+delay <= height+1 {
+			return chainTransactionFinalized, nil
+		}
+		return chainTransactionPending, nil
+	default:
+		panic(chain)
+	}
+}
@@ -0,0 +1,304 @@
+package observer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/safe/common"
+	"github.com/MixinNetwork/safe/keeper/store"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChainReorgStore(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+
+	root, err := os.MkdirTemp("", "safe-reorg-test")
+	require.Nil(err)
+	defer os.RemoveAll(root)
+	db, err := OpenSQLite3Store(root + "/safe.sqlite3")
+	require.Nil(err)
+
+	chain := byte(common.SafeChainBitcoin)
+	key := depositCheckpointKey(chain)
+	start := time.Now().UTC()
+	for h := int64(100); h < 110; h++ {
+		b := &ChainBlock{
+			Chain:     chain,
+			Height:    h,
+			Hash:      fmt.Sprintf("block-%d", h),
+			CreatedAt: start.Add(time.Duration(h-100) * time.Minute),
+		}
+		err = db.WriteChainBlockCheckpoint(ctx, b, key, 4)
+		require.Nil(err)
+		err = db.WriteChainBlockCheckpoint(ctx, b, key, 4)
+		require.NotNil(err)
+	}
+	val, err := db.ReadProperty(ctx, key)
+	require.Nil(err)
+	require.Equal("110", val)
+	b, err := db.ReadChainBlock(ctx, chain, 104)
+	require.Nil(err)
+	require.Nil(b)
+	b, err = db.ReadChainBlock(ctx, chain, 105)
+	require.Nil(err)
+	require.Equal("block-105", b.Hash)
+
+	for i, state := range []int{common.RequestStateInitial, common.RequestStateInitial, common.RequestStateDone} {
+		d := &Deposit{
+			TransactionHash: testBumpSpentHash,
+			OutputIndex:     int64(i),
+			AssetId:         "asset",
+			Amount:          "0.0001",
+			Receiver:        "receiver",
+			Sender:          "sender",
+			State:           common.RequestStateInitial,
+			Chain:           chain,
+			Holder:          "holder",
+			Category:        common.ActionObserverHolderDeposit,
+			RequestId:       fmt.Sprintf("request-%d", i),
+			CreatedAt:       start.Add(time.Duration(i) * time.Hour),
+			UpdatedAt:       start,
+		}
+		err = db.WritePendingDepositIfNotExists(ctx, d)
+		require.Nil(err)
+		if state == common.RequestStateDone {
+			err = db.ConfirmPendingDeposit(ctx, d.TransactionHash, d.OutputIndex, d.RequestId)
+			require.Nil(err)
+		}
+	}
+	deposits, err := db.ListChainDepositsSince(ctx, chain, start.Add(time.Minute))
+	require.Nil(err)
+	require.Len(deposits, 2)
+	err = db.WriteOrphanedDeposit(ctx, deposits[0], false)
+	require.Nil(err)
+	err = db.WriteOrphanedDeposit(ctx, deposits[0], false)
+	require.NotNil(err)
+	err = db.WriteOrphanedDeposit(ctx, deposits[1], true)
+	require.Nil(err)
+	deposits, err = db.ListChainDepositsSince(ctx, chain, start)
+	require.Nil(err)
+	require.Len(deposits, 2)
+	require.Equal(common.RequestStateInitial, deposits[0].State)
+	require.Equal(common.RequestStateFailed, deposits[1].State)
+
+	reorg := &ChainReorg{
+		Chain:      chain,
+		Fork:       107,
+		Checkpoint: 110,
+		ForkedAt:   start,
+		State:      common.RequestStateInitial,
+		CreatedAt:  start,
+		UpdatedAt:  start,
+	}
+	err = db.RewindChainBlockCheckpoint(ctx, reorg, key)
+	require.Nil(err)
+	reorg.Checkpoint = 108
+	err = db.RewindChainBlockCheckpoint(ctx, reorg, key)
+	require.Nil(err)
+	reorgs, err := db.ListPendingChainReorgs(ctx, chain, 109)
+	require.Nil(err)
+	require.Len(reorgs, 0)
+	reorgs, err = db.ListPendingChainReorgs(ctx, chain, 110)
+	require.Nil(err)
+	require.Len(reorgs, 1)
+	require.Equal(int64(110), reorgs[0].Checkpoint)
+	err = db.FinishChainReorg(ctx, chain, 107)
+	require.Nil(err)
+	err = db.FinishChainReorg(ctx, chain, 107)
+	require.NotNil(err)
+	reorgs, err = db.ListPendingChainReorgs(ctx, chain, 110)
+	require.Nil(err)
+	require.Len(reorgs, 0)
+	val, err = db.ReadProperty(ctx, key)
+	require.Nil(err)
+	require.Equal("107", val)
+	b, err = db.ReadChainBlock(ctx, chain, 107)
+	require.Nil(err)
+	require.Nil(b)
+	b, err = db.ReadChainBlock(ctx, chain, 106)
+	require.Nil(err)
+	require.Equal("block-106", b.Hash)
+}
+
+func TestCheckChainReorg(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+
+	root, err := os.MkdirTemp("", "safe-reorg-test")
+	require.Nil(err)
+	defer os.RemoveAll(root)
+	db, err := OpenSQLite3Store(root + "/safe.sqlite3")
+	require.Nil(err)
+	kd, err := store.OpenSQLite3Store(root + "/keeper.sqlite3")
+	require.Nil(err)
+
+	rpc := &testReorgRPC{blocks: make(map[int64]string), txs: make(map[string]string), confirmations: make(map[string]int)}
+	server := httptest.NewServer(rpc)
+	defer server.Close()
+	node := &Node{conf: &Configuration{BitcoinRPC: server.URL}, store: db, keeperStore: kd}
+
+	chain := byte(common.SafeChainBitcoin)
+	key := depositCheckpointKey(chain)
+	start := time.Now().UTC()
+	for h := int64(100); h < 110; h++ {
+		rpc.setBlock(h, fmt.Sprintf("block-%d", h))
+		err = node.writeChainBlockCheckpoint(ctx, chain, h, fmt.Sprintf("block-%d", h))
+		require.Nil(err)
+	}
+	reorg, err := node.checkChainReorg(ctx, chain, 110)
+	require.Nil(err)
+	require.False(reorg)
+
+	for i, state := range []int{common.RequestStateDone, common.RequestStateInitial, common.RequestStateDone} {
+		d := &Deposit{
+			TransactionHash: fmt.Sprintf("%064x", i),
+			AssetId:         "asset",
+			Amount:          "0.0001",
+			Receiver:        "receiver",
+			Sender:          "sender",
+			State:           common.RequestStateInitial,
+			Chain:           chain,
+			Holder:          "holder",
+			Category:        common.ActionObserverHolderDeposit,
+			RequestId:       fmt.Sprintf("request-%d", i),
+			CreatedAt:       time.Now().UTC(),
+			UpdatedAt:       start,
+		}
+		err = db.WritePendingDepositIfNotExists(ctx, d)
+		require.Nil(err)
+		if state == common.RequestStateDone {
+			err = db.ConfirmPendingDeposit(ctx, d.TransactionHash, d.OutputIndex, d.RequestId)
+			require.Nil(err)
+		}
+	}
+
+	// the reorganized blocks are rewound without verifying the deposits
+	rpc.setBlock(108, "block-108-new")
+	rpc.setBlock(109, "block-109-new")
+	reorg, err = node.checkChainReorg(ctx, chain, 110)
+	require.Nil(err)
+	require.True(reorg)
+	val, err := db.ReadProperty(ctx, key)
+	require.Nil(err)
+	require.Equal("108", val)
+	b, err := db.ReadChainBlock(ctx, chain, 108)
+	require.Nil(err)
+	require.Nil(b)
+	reorg, err = node.checkChainReorg(ctx, chain, 108)
+	require.Nil(err)
+	require.False(reorg)
+	err = node.verifyChainReorgs(ctx, chain, 108)
+	require.Nil(err)
+	deposits, err := db.ListChainDepositsSince(ctx, chain, start)
+	require.Nil(err)
+	require.Len(deposits, 3)
+
+	// the deposit in mempool is pending after the rescan
+	for h := int64(108); h < 110; h++ {
+		err = node.writeChainBlockCheckpoint(ctx, chain, h, rpc.blocks[h])
+		require.Nil(err)
+	}
+	rpc.setTransaction(fmt.Sprintf("%064x", 0), "block-108-new", 3)
+	rpc.setTransaction(fmt.Sprintf("%064x", 2), "", 0)
+	err = node.verifyChainReorgs(ctx, chain, 110)
+	require.Nil(err)
+	reorgs, err := db.ListPendingChainReorgs(ctx, chain, 110)
+	require.Nil(err)
+	require.Len(reorgs, 1)
+	deposits, err = db.ListChainDepositsSince(ctx, chain, start)
+	require.Nil(err)
+	require.Len(deposits, 2)
+	require.Equal(common.RequestStateDone, deposits[0].State)
+	require.Equal(common.RequestStateDone, deposits[1].State)
+
+	// the deposit dropped from the mempool is orphaned after it was sent
+	rpc.setTransaction(fmt.Sprintf("%064x", 2), "block-109", -1)
+	err = node.verifyChainReorgs(ctx, chain, 110)
+	require.Nil(err)
+	reorgs, err = db.ListPendingChainReorgs(ctx, chain, 110)
+	require.Nil(err)
+	require.Len(reorgs, 0)
+	deposits, err = db.ListChainDepositsSince(ctx, chain, start)
+	require.Nil(err)
+	require.Len(deposits, 2)
+	require.Equal(common.RequestStateDone, deposits[0].State)
+	require.Equal(common.RequestStateFailed, deposits[1].State)
+	val, err = db.ReadProperty(ctx, "monitor-alert-deposit-orphaned-request-2")
+	require.Nil(err)
+	require.NotEqual("", val)
+
+	// the reorg deeper than the window stops the loop with an alert
+	for h := int64(100); h < 110; h++ {
+		rpc.setBlock(h, fmt.Sprintf("block-%d-deep", h))
+	}
+	_, err = node.checkChainReorg(ctx, chain, 110)
+	require.NotNil(err)
+	require.Contains(err.Error(), "deeper than the window")
+	val, err = db.ReadProperty(ctx, fmt.Sprintf("monitor-alert-chain-reorg-deep-%d", chain))
+	require.Nil(err)
+	require.NotEqual("", val)
+	val, err = db.ReadProperty(ctx, key)
+	require.Nil(err)
+	require.Equal("110", val)
+}
+
+type testReorgRPC struct {
+	sync.Mutex
+	blocks        map[int64]string
+	txs           map[string]string
+	confirmations map[string]int
+}
+
+func (r *testReorgRPC) setBlock(height int64, hash string) {
+	r.Lock()
+	defer r.Unlock()
+	r.blocks[height] = hash
+	r.confirmations[hash] = 1
+}
+
+func (r *testReorgRPC) setTransaction(hash, block string, confirmations int) {
+	r.Lock()
+	defer r.Unlock()
+	r.txs[hash] = block
+	if block != "" {
+		r.confirmations[block] = confirmations
+	}
+}
+
+func (r *testReorgRPC) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.Lock()
+	defer r.Unlock()
+
+	var call struct {
+		Method string `json:"method"`
+		Params []any  `json:"params"`
+	}
+	err := json.NewDecoder(req.Body).Decode(&call)
+	if err != nil {
+		panic(err)
+	}
+	var result any
+	switch call.Method {
+	case "getblockhash":
+		result = r.blocks[int64(call.Params[0].(float64))]
+	case "getrawtransaction":
+		block, found := r.txs[call.Params[0].(string)]
+		if !found {
+			json.NewEncoder(w).Encode(map[string]any{"error": map[string]any{"code": -5, "message": bitcoinTransactionNotFound}})
+			return
+		}
+		result = map[string]any{"txid": call.Params[0], "blockhash": block}
+	case "getblock":
+		hash := call.Params[0].(string)
+		result = map[string]any{"hash": hash, "confirmations": r.confirmations[hash]}
+	}
+	json.NewEncoder(w).Encode(map[string]any{"result": result})
+}
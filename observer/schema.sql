@@ -121,6 +121,46 @@ CREATE INDEX IF NOT EXISTS bitcoin_outputs_by_chain_state_created ON bitcoin_out
 
 
 
+CREATE TABLE IF NOT EXISTS bitcoin_fee_bumps (
+  bump_hash          VARCHAR NOT NULL,
+  transaction_hash   VARCHAR NOT NULL,
+  kind               VARCHAR NOT NULL,
+  chain              INTEGER NOT NULL,
+  fee_rate           INTEGER NOT NULL,
+  fee                INTEGER NOT NULL,
+  raw_transaction    VARCHAR NOT NULL,
+  created_at         TIMESTAMP NOT NULL,
+  PRIMARY KEY ('bump_hash')
+);
+
+CREATE INDEX IF NOT EXISTS bitcoin_fee_bumps_by_transaction_created ON bitcoin_fee_bumps(transaction_hash, created_at);
+
+
+
+
+CREATE TABLE IF NOT EXISTS chain_blocks (
+  chain              INTEGER NOT NULL,
+  height             INTEGER NOT NULL,
+  hash               VARCHAR NOT NULL,
+  created_at         TIMESTAMP NOT NULL,
+  PRIMARY KEY ('chain', 'height')
+);
+
+CREATE TABLE IF NOT EXISTS chain_reorgs (
+  chain              INTEGER NOT NULL,
+  fork               INTEGER NOT NULL,
+  checkpoint         INTEGER NOT NULL,
+  forked_at          TIMESTAMP NOT NULL,
+  state              INTEGER NOT NULL,
+  created_at         TIMESTAMP NOT NULL,
+  updated_at         TIMESTAMP NOT NULL,
+  PRIMARY KEY ('chain', 'fork')
+);
+
+CREATE INDEX IF NOT EXISTS chain_reorgs_by_chain_state_checkpoint ON chain_reorgs(chain, state, checkpoint);
+
+
+
 CREATE TABLE IF NOT EXISTS recoveries (
   address            VARCHAR NOT NULL,
   chain              INTEGER NOT NULL,
@@ -133,3 +173,27 @@ CREATE TABLE IF NOT EXISTS recoveries (
   updated_at         TIMESTAMP NOT NULL,
   PRIMARY KEY ('address')
 );
+
+
+
+CREATE TABLE IF NOT EXISTS call_allowlists (
+  address            VARCHAR NOT NULL,
+  target             VARCHAR NOT NULL,
+  selector           VARCHAR NOT NULL,
+  created_at         TIMESTAMP NOT NULL,
+  PRIMARY KEY ('address', 'target', 'selector')
+);
+
+
+
+CREATE TABLE IF NOT EXISTS safe_relays (
+  address            VARCHAR NOT NULL,
+  chain              INTEGER NOT NULL,
+  transaction_hash   VARCHAR NOT NULL,
+  state              INTEGER NOT NULL,
+  created_at         TIMESTAMP NOT NULL,
+  updated_at         TIMESTAMP NOT NULL,
+  PRIMARY KEY ('address')
+);
+
+CREATE INDEX IF NOT EXISTS safe_relays_by_chain_state_created ON safe_relays(chain, state, created_at);
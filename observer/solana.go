@@ -0,0 +1,435 @@
+package observer
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/MixinNetwork/mixin/logger"
+	"github.com/MixinNetwork/safe/apps/solana"
+	"github.com/MixinNetwork/safe/common"
+	"github.com/MixinNetwork/safe/keeper"
+	"github.com/MixinNetwork/safe/keeper/store"
+	"github.com/gofrs/uuid/v5"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	solanaKeygenRequestTimeKey  = "solana-keygen-request-time"
+	solanaKeyDummyHolderPrivate = "2a3a5e0c8d7c47b8b5ad1e4f8f0c6d9e1b7a3c5d4e6f708192a3b4c5d6e7f809"
+)
+
+func (node *Node) solanaDummyHolder() string {
+	seed := common.DecodeHexOrPanic(solanaKeyDummyHolderPrivate)
+	priv := ed25519.NewKeyFromSeed(seed)
+	return hex.EncodeToString(priv.Public().(ed25519.PublicKey))
+}
+
+func (node *Node) deploySolanaSafeBond(ctx context.Context, data []byte) error {
+	logger.Printf("node.deploySolanaSafeBond(%s)", string(data))
+	safe, err := node.keeperStore.ReadSafeByAddress(ctx, string(data))
+	if err != nil {
+		return fmt.Errorf("keeperStore.ReadSafeByAddress(%s) => %v", string(data), err)
+	}
+	assetId := common.SafeSolanaChainId
+	_, err = node.checkOrDeployKeeperBond(ctx, safe.Chain, assetId, "", safe.Holder, safe.Address)
+	logger.Printf("node.checkOrDeployKeeperBond(%s, %s) => %v", assetId, safe.Holder, err)
+	if err != nil {
+		return fmt.Errorf("node.checkOrDeployKeeperBond(%s, %s) => %v", assetId, safe.Holder, err)
+	}
+	err = node.store.MarkAccountApproved(ctx, safe.Address)
+	logger.Printf("store.MarkAccountApproved(%s) => %v", safe.Address, err)
+	return err
+}
+
+func (node *Node) solanaRPCBlocksLoop(ctx context.Context) {
+	chain := byte(common.SafeChainSolana)
+
+	for {
+		checkpoint, err := node.readDepositCheckpoint(ctx, chain)
+		if err != nil {
+			panic(err)
+		}
+		slot, err := solana.RPCGetSlot(node.conf.SolanaRPC)
+		logger.Printf("solana.RPCGetSlot(%d) => %d %v", checkpoint, slot, err)
+		if err != nil || checkpoint > slot {
+			time.Sleep(time.Second * 5)
+			continue
+		}
+		block, err := solana.RPCGetBlock(node.conf.SolanaRPC, checkpoint)
+		logger.Verbosef("solana.RPCGetBlock(%d) => %v", checkpoint, err)
+		if err != nil {
+			time.Sleep(time.Second * 5)
+			continue
+		}
+		if block != nil {
+			err = node.solanaProcessBlock(ctx, block)
+			if err != nil {
+				panic(err)
+			}
+		}
+
+		err = node.solanaWriteDepositCheckpoint(ctx, checkpoint+1)
+		if err != nil {
+			panic(err)
+		}
+	}
+}
+
+func (node *Node) solanaWriteDepositCheckpoint(ctx context.Context, num int64) error {
+	return node.store.WriteProperty(ctx, depositCheckpointKey(common.SafeChainSolana), fmt.Sprint(num))
+}
+
+func (node *Node) solanaProcessBlock(ctx context.Context, block *solana.RPCBlock) error {
+	safes, err := node.listSolanaSafes(ctx)
+	if err != nil || len(safes) == 0 {
+		return err
+	}
+	for _, tx := range block.Transactions {
+		for _, t := range tx.ExtractTransfers() {
+			safe := safes[t.Receiver]
+			if safe == nil || t.Sender == t.Receiver {
+				continue
+			}
+			err := node.solanaWritePendingDeposit(ctx, safe, t)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (node *Node) listSolanaSafes(ctx context.Context) (map[string]*store.Safe, error) {
+	safes, err := node.keeperStore.ListSafesWithState(ctx, keeper.SafeStateApproved)
+	if err != nil {
+		return nil, err
+	}
+	addrs := make(map[string]*store.Safe)
+	for _, safe := range safes {
+		if safe.Chain != common.SafeChainSolana {
+			continue
+		}
+		addrs[safe.Address] = safe
+	}
+	return addrs, nil
+}
+
+func (node *Node) solanaWritePendingDeposit(ctx context.Context, safe *store.Safe, transfer *solana.Transfer) error {
+	amount := decimal.NewFromInt(int64(transfer.Lamports)).Shift(-solana.ValuePrecision)
+	minimum := decimal.RequireFromString(node.conf.TransactionMinimum)
+	if amount.Cmp(minimum) < 0 {
+		return nil
+	}
+
+	old, err := node.keeperStore.ReadDeposit(ctx, transfer.Signature, transfer.Index)
+	logger.Printf("keeperStore.ReadDeposit(%s, %d, %s) => %v %v", transfer.Signature, transfer.Index, transfer.Receiver, old, err)
+	if err != nil {
+		return fmt.Errorf("keeperStore.ReadDeposit(%s, %d) => %v", transfer.Signature, transfer.Index, err)
+	} else if old != nil {
+		return nil
+	}
+
+	assetId := common.SafeSolanaChainId
+	id := common.UniqueId(assetId, safe.Holder)
+	id = common.UniqueId(id, fmt.Sprintf("%s:%d", transfer.Signature, transfer.Index))
+	createdAt := time.Now().UTC()
+	deposit := &Deposit{
+		TransactionHash: transfer.Signature,
+		OutputIndex:     transfer.Index,
+		AssetId:         assetId,
+		AssetAddress:    solana.SystemProgramAddress,
+		Amount:          amount.String(),
+		Receiver:        transfer.Receiver,
+		Sender:          transfer.Sender,
+		Holder:          safe.Holder,
+		Category:        common.ActionObserverHolderDeposit,
+		State:           common.RequestStateInitial,
+		Chain:           common.SafeChainSolana,
+		RequestId:       id,
+		CreatedAt:       createdAt,
+		UpdatedAt:       createdAt,
+	}
+
+	err = node.store.WritePendingDepositIfNotExists(ctx, deposit)
+	if err != nil {
+		return fmt.Errorf("store.WritePendingDeposit(%v) => %v", deposit, err)
+	}
+	return nil
+}
+
+// the blocks are scanned with the finalized commitment, so no confirmations needed
+func (node *Node) solanaConfirmPendingDeposit(ctx context.Context, deposit *Deposit) error {
+	safe, err := node.keeperStore.ReadSafe(ctx, deposit.Holder)
+	logger.Printf("node.solanaConfirmPendingDeposit(%v) => %v %v", deposit, safe, err)
+	if err != nil || safe == nil {
+		return err
+	}
+	bonded, err := node.checkOrDeployKeeperBond(ctx, deposit.Chain, deposit.AssetId, "", deposit.Holder, safe.Address)
+	logger.Printf("node.checkOrDeployKeeperBond(%v) => %t %v", deposit, bonded, err)
+	if err != nil {
+		return fmt.Errorf("node.checkOrDeployKeeperBond(%s) => %v", deposit.Holder, err)
+	} else if !bonded {
+		return nil
+	}
+	return node.sendKeeperDepositTransaction(ctx, deposit, solana.ValuePrecision)
+}
+
+func (node *Node) solanaDepositConfirmLoop(ctx context.Context) {
+	for {
+		time.Sleep(3 * time.Second)
+		deposits, err := node.store.ListDeposits(ctx, common.SafeChainSolana, "", common.RequestStateInitial, 0)
+		if err != nil {
+			panic(err)
+		}
+		for _, d := range deposits {
+			err := node.solanaConfirmPendingDeposit(ctx, d)
+			if err != nil {
+				panic(err)
+			}
+		}
+	}
+}
+
+func (node *Node) solanaTransactionApprovalLoop(ctx context.Context) {
+	for {
+		time.Sleep(3 * time.Second)
+		approvals, err := node.store.ListPendingTransactionApprovals(ctx, common.SafeChainSolana)
+		if err != nil {
+			panic(err)
+		}
+		for _, approval := range approvals {
+			err := node.sendToKeeperSolanaApproveTransaction(ctx, approval)
+			logger.Verbosef("node.sendToKeeperSolanaApproveTransaction(%v) => %v", approval, err)
+			if err != nil {
+				panic(err)
+			}
+		}
+	}
+}
+
+func (node *Node) sendToKeeperSolanaApproveTransaction(ctx context.Context, approval *Transaction) error {
+	requests, err := node.keeperStore.ListAllSignaturesForTransaction(ctx, approval.TransactionHash, common.RequestStateDone)
+	if err != nil {
+		return err
+	}
+	if len(requests) > 0 {
+		return nil
+	}
+	st, err := solana.UnmarshalSafeTransaction(common.DecodeHexOrPanic(approval.RawTransaction))
+	if err != nil {
+		panic(approval.RawTransaction)
+	}
+	safe, err := node.keeperStore.ReadSafe(ctx, approval.Holder)
+	if err != nil {
+		return err
+	}
+	signedByHolder := solana.CheckTransactionPartiallySignedBy(approval.RawTransaction, safe.Holder)
+	signedByObserver := solana.CheckTransactionPartiallySignedBy(approval.RawTransaction, safe.Observer)
+	if !signedByHolder && !signedByObserver {
+		panic(approval.RawTransaction)
+	}
+
+	tx, err := node.keeperStore.ReadTransaction(ctx, approval.TransactionHash)
+	if err != nil {
+		return err
+	}
+	id := common.UniqueId(approval.TransactionHash, approval.TransactionHash)
+	rid := uuid.Must(uuid.FromString(tx.RequestId))
+	extra := append(rid.Bytes(), st.HolderSignature...)
+	action := common.ActionSolanaSafeApproveTransaction
+	err = node.sendKeeperResponse(ctx, tx.Holder, byte(action), approval.Chain, id, extra)
+	logger.Printf("node.sendKeeperResponse(%s, %d, %s, %x) => %v", tx.Holder, action, id, extra, err)
+	if err != nil {
+		return err
+	}
+
+	// the observer approval is only accepted by the keeper after the timelock
+	if approval.UpdatedAt.Add(keeper.SafeSignatureTimeout).After(time.Now()) {
+		return nil
+	}
+	id = common.UniqueId(id, approval.UpdatedAt.String())
+	err = node.sendKeeperResponse(ctx, tx.Holder, byte(action), approval.Chain, id, extra)
+	logger.Printf("node.sendKeeperResponse(%s, %d, %s, %x) => %v", tx.Holder, action, id, extra, err)
+	if err != nil {
+		return err
+	}
+	return node.store.UpdateTransactionApprovalRequestTime(ctx, approval.TransactionHash)
+}
+
+func (node *Node) keeperSaveSolanaTransactionSignatures(ctx context.Context, extra []byte) error {
+	logger.Printf("node.keeperSaveSolanaTransactionSignatures(%x)", extra)
+	st, err := solana.UnmarshalSafeTransaction(extra)
+	if err != nil {
+		return err
+	}
+	tx, err := node.store.ReadTransactionApproval(ctx, st.Hash())
+	if err != nil || tx.State >= common.RequestStateDone {
+		return err
+	}
+	if tx.Chain != common.SafeChainSolana {
+		panic(st.Hash())
+	}
+	if !st.IsFullySigned() {
+		panic(st.Hash())
+	}
+	raw := hex.EncodeToString(st.Marshal())
+	err = node.store.FinishTransactionSignatures(ctx, st.Hash(), raw)
+	logger.Printf("store.FinishTransactionSignatures(%s) => %v", st.Hash(), err)
+	return err
+}
+
+func (node *Node) solanaTransactionSpendLoop(ctx context.Context) {
+	for {
+		time.Sleep(3 * time.Second)
+		txs, err := node.store.ListFullySignedTransactionApprovals(ctx, common.SafeChainSolana)
+		if err != nil {
+			panic(err)
+		}
+		for _, tx := range txs {
+			st, err := solana.UnmarshalSafeTransaction(common.DecodeHexOrPanic(tx.RawTransaction))
+			if err != nil {
+				panic(err)
+			}
+			spentRaw := hex.EncodeToString(st.Serialize())
+			spentHash, err := node.solanaSendTransaction(st)
+			logger.Verbosef("node.solanaSendTransaction(%s) => %s %v", tx.TransactionHash, spentHash, err)
+			if err != nil {
+				break
+			}
+			if spentHash != st.TransactionId() {
+				panic(fmt.Errorf("solana.RPCSendTransaction(%s) => %s", st.TransactionId(), spentHash))
+			}
+			err = node.store.ConfirmFullySignedTransactionApproval(ctx, tx.TransactionHash, spentHash, spentRaw)
+			if err != nil {
+				panic(err)
+			}
+		}
+	}
+}
+
+// the durable nonce is advanced once the transaction is processed, so the
+// transaction is checked before sending again to confirm a previous broadcast
+func (node *Node) solanaSendTransaction(st *solana.SafeTransaction) (string, error) {
+	id := st.TransactionId()
+	rtx, err := solana.RPCGetTransaction(node.conf.SolanaRPC, id)
+	if err != nil {
+		return "", err
+	}
+	if rtx != nil {
+		return rtx.Signature(), nil
+	}
+	return solana.RPCSendTransaction(node.conf.SolanaRPC, st.Serialize())
+}
+
+func (node *Node) httpApproveSolanaTransaction(ctx context.Context, raw string) error {
+	logger.Printf("node.httpApproveSolanaTransaction(%s)", raw)
+	rb, err := hex.DecodeString(raw)
+	if err != nil {
+		return err
+	}
+	st, err := solana.UnmarshalSafeTransaction(rb)
+	if err != nil {
+		return err
+	}
+	txHash := st.Hash()
+
+	approval, err := node.store.ReadTransactionApproval(ctx, txHash)
+	logger.Verbosef("store.ReadTransactionApproval(%s) => %v %v", txHash, approval, err)
+	if err != nil || approval == nil {
+		return err
+	}
+	if approval.State != common.RequestStateInitial {
+		return nil
+	}
+	safe, err := node.keeperStore.ReadSafe(ctx, approval.Holder)
+	if err != nil {
+		return err
+	}
+	if solana.CheckTransactionPartiallySignedBy(approval.RawTransaction, safe.Holder) {
+		return nil
+	}
+	if solana.CheckTransactionPartiallySignedBy(approval.RawTransaction, safe.Observer) {
+		return nil
+	}
+
+	// only the holder or observer signature is taken, the messages are the ones
+	// proposed by the keeper, the holder message is guaranteed by the same hash
+	pst, err := solana.UnmarshalSafeTransaction(common.DecodeHexOrPanic(approval.RawTransaction))
+	if err != nil {
+		panic(approval.RawTransaction)
+	}
+	pst.HolderSignature = st.HolderSignature
+	raw = hex.EncodeToString(pst.Marshal())
+	signedByHolder := solana.CheckTransactionPartiallySignedBy(raw, safe.Holder)
+	signedByObserver := solana.CheckTransactionPartiallySignedBy(raw, safe.Observer)
+	if !signedByHolder && !signedByObserver {
+		return fmt.Errorf("HTTP: %d", http.StatusNotAcceptable)
+	}
+	tx, err := node.keeperStore.ReadTransaction(ctx, txHash)
+	logger.Verbosef("keeperStore.ReadTransaction(%s) => %v %v", txHash, tx, err)
+	if err != nil || tx == nil {
+		return err
+	}
+
+	err = node.store.AddTransactionPartials(ctx, txHash, raw)
+	logger.Printf("store.AddTransactionPartials(%s) => %v", txHash, err)
+	return err
+}
+
+func (node *Node) httpRevokeSolanaTransaction(ctx context.Context, txHash string, sigHex string) error {
+	logger.Printf("node.httpRevokeSolanaTransaction(%s, %s)", txHash, sigHex)
+	approval, err := node.store.ReadTransactionApproval(ctx, txHash)
+	logger.Verbosef("store.ReadTransactionApproval(%s) => %v %v", txHash, approval, err)
+	if err != nil || approval == nil {
+		return err
+	}
+	if approval.State != common.RequestStateInitial {
+		return nil
+	}
+	if solana.CheckTransactionPartiallySignedBy(approval.RawTransaction, approval.Holder) {
+		return nil
+	}
+
+	tx, err := node.keeperStore.ReadTransaction(ctx, txHash)
+	logger.Verbosef("keeperStore.ReadTransaction(%s) => %v %v", txHash, tx, err)
+	if err != nil {
+		return err
+	}
+
+	sig, err := hex.DecodeString(sigHex)
+	if err != nil {
+		return err
+	}
+	ms := fmt.Sprintf("REVOKE:%s:%s", tx.RequestId, tx.TransactionHash)
+	err = solana.VerifyMessageSignature(tx.Holder, []byte(ms), sig)
+	logger.Printf("holder: solana.VerifyMessageSignature(%v) => %v", tx, err)
+	if err != nil {
+		safe, err := node.keeperStore.ReadSafe(ctx, tx.Holder)
+		if err != nil {
+			return err
+		}
+		err = solana.VerifyMessageSignature(safe.Observer, []byte(ms), sig)
+		logger.Printf("observer: solana.VerifyMessageSignature(%v) => %v", tx, err)
+		if err != nil {
+			return err
+		}
+	}
+
+	id := common.UniqueId(approval.TransactionHash, approval.TransactionHash)
+	rid := uuid.Must(uuid.FromString(tx.RequestId))
+	extra := append(rid.Bytes(), sig...)
+	action := common.ActionSolanaSafeRevokeTransaction
+	err = node.sendKeeperResponse(ctx, tx.Holder, byte(action), approval.Chain, id, extra)
+	logger.Printf("node.sendKeeperResponse(%s, %d, %s, %x)", tx.Holder, action, id, extra)
+	if err != nil {
+		return err
+	}
+
+	err = node.store.RevokeTransactionApproval(ctx, txHash, sigHex+":"+approval.RawTransaction)
+	logger.Printf("store.RevokeTransactionApproval(%s) => %v", txHash, err)
+	return err
+}
@@ -10,9 +10,13 @@ import (
 
 	"github.com/MixinNetwork/safe/apps/bitcoin"
 	"github.com/MixinNetwork/safe/apps/ethereum"
+	"github.com/MixinNetwork/safe/apps/mixin"
+	"github.com/MixinNetwork/safe/apps/solana"
+	"github.com/MixinNetwork/safe/apps/tron"
 	"github.com/MixinNetwork/safe/common"
 	"github.com/MixinNetwork/safe/keeper/store"
 	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/wire"
 )
 
 type Account struct {
@@ -77,6 +81,36 @@ type Output struct {
 	UpdatedAt       time.Time
 }
 
+type FeeBump struct {
+	BumpHash        string
+	TransactionHash string
+	Kind            string
+	Chain           byte
+	FeeRate         int64
+	Fee             int64
+	RawTransaction  string
+	CreatedAt       time.Time
+}
+
+type ChainBlock struct {
+	Chain     byte
+	Height    int64
+	Hash      string
+	CreatedAt time.Time
+}
+
+// ChainReorg keeps the deposits written since ForkedAt to be verified again,
+// after the blocks below Checkpoint are rescanned and finalized in the new chain
+type ChainReorg struct {
+	Chain      byte
+	Fork       int64
+	Checkpoint int64
+	ForkedAt   time.Time
+	State      int
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
 type Recovery struct {
 	Address         string
 	Chain           byte
@@ -111,6 +145,24 @@ func (o *Output) values() []any {
 	return []any{o.TransactionHash, o.Index, o.Address, o.Satoshi, o.Chain, o.State, o.SpentBy, o.RawTransaction, o.CreatedAt, o.UpdatedAt}
 }
 
+var feeBumpCols = []string{"bump_hash", "transaction_hash", "kind", "chain", "fee_rate", "fee", "raw_transaction", "created_at"}
+
+func (b *FeeBump) values() []any {
+	return []any{b.BumpHash, b.TransactionHash, b.Kind, b.Chain, b.FeeRate, b.Fee, b.RawTransaction, b.CreatedAt}
+}
+
+var chainBlockCols = []string{"chain", "height", "hash", "created_at"}
+
+func (b *ChainBlock) values() []any {
+	return []any{b.Chain, b.Height, b.Hash, b.CreatedAt}
+}
+
+var chainReorgCols = []string{"chain", "fork", "checkpoint", "forked_at", "state", "created_at", "updated_at"}
+
+func (r *ChainReorg) values() []any {
+	return []any{r.Chain, r.Fork, r.Checkpoint, r.ForkedAt, r.State, r.CreatedAt, r.UpdatedAt}
+}
+
 var recoveryCols = []string{"address", "chain", "holder", "observer", "raw_transaction", "transaction_hash", "state", "created_at", "updated_at"}
 
 func (r *Recovery) values() []any {
@@ -139,7 +191,7 @@ func (t *Transaction) Signers(ctx context.Context, node *Node, safe *store.Safe)
 
 	opk, spk := safe.Observer, safe.Signer
 	switch safe.Chain {
-	case common.SafeChainBitcoin, common.SafeChainLitecoin:
+	case common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainBitcoinCash:
 		var err error
 		opk, err = node.deriveBIP32WithKeeperPath(ctx, safe.Observer, safe.Path)
 		if err != nil {
@@ -154,11 +206,27 @@ func (t *Transaction) Signers(ctx context.Context, node *Node, safe *store.Safe)
 	pubs := []string{t.Holder, spk, opk}
 	for idx, pub := range pubs {
 		isSigned := false
-		switch safe.Chain {
-		case common.SafeChainBitcoin, common.SafeChainLitecoin:
+		switch common.NormalizeSafeChain(safe.Chain) {
+		case common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainBitcoinCash:
 			isSigned = bitcoin.CheckTransactionPartiallySignedBy(t.RawTransaction, pub)
-		case common.SafeChainPolygon, common.SafeChainEthereum:
+		case common.SafeChainEthereum:
 			isSigned = ethereum.CheckTransactionPartiallySignedBy(t.RawTransaction, pub)
+		case common.SafeChainMixinKernel:
+			isSigned = mixin.CheckTransactionPartiallySignedBy(t.RawTransaction, pub)
+		case common.SafeChainSolana:
+			switch idx {
+			case 0, 2:
+				isSigned = solana.CheckTransactionPartiallySignedBy(t.RawTransaction, pub)
+			case 1:
+				isSigned = solana.CheckTransactionFullySigned(t.RawTransaction)
+			}
+		case common.SafeChainTron:
+			switch idx {
+			case 0, 2:
+				isSigned = tron.CheckTransactionPartiallySignedBy(t.RawTransaction, pub)
+			case 1:
+				isSigned = tron.CheckTransactionFullySigned(t.RawTransaction)
+			}
 		default:
 			panic(safe.Chain)
 		}
@@ -200,6 +268,80 @@ func (s *SQLite3Store) WriteAccountProposalIfNotExists(ctx context.Context, addr
 	return tx.Commit()
 }
 
+// the safe account relayed to the bundler, with the guard transaction hash
+type SafeRelay struct {
+	Address         string
+	Chain           byte
+	TransactionHash string
+	State           int
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+}
+
+var safeRelayCols = []string{"address", "chain", "transaction_hash", "state", "created_at", "updated_at"}
+
+func (s *SQLite3Store) WriteSafeRelayIfNotExists(ctx context.Context, address string, chain byte, hash string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	existed, err := s.checkExistence(ctx, tx, "SELECT state FROM safe_relays WHERE address=?", address)
+	if err != nil || existed {
+		return err
+	}
+
+	now := time.Now().UTC()
+	vals := []any{address, chain, hash, common.RequestStateInitial, now, now}
+	err = s.execOne(ctx, tx, buildInsertionSQL("safe_relays", safeRelayCols), vals...)
+	if err != nil {
+		return fmt.Errorf("INSERT safe_relays %v", err)
+	}
+	return tx.Commit()
+}
+
+func (s *SQLite3Store) ListInitialSafeRelays(ctx context.Context, chain byte) ([]*SafeRelay, error) {
+	query := fmt.Sprintf("SELECT %s FROM safe_relays WHERE chain=? AND state=? ORDER BY created_at ASC LIMIT 100", strings.Join(safeRelayCols, ","))
+	rows, err := s.db.QueryContext(ctx, query, chain, common.RequestStateInitial)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var relays []*SafeRelay
+	for rows.Next() {
+		var r SafeRelay
+		err := rows.Scan(&r.Address, &r.Chain, &r.TransactionHash, &r.State, &r.CreatedAt, &r.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		relays = append(relays, &r)
+	}
+	return relays, nil
+}
+
+func (s *SQLite3Store) FinishSafeRelay(ctx context.Context, address string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = s.execOne(ctx, tx, "UPDATE safe_relays SET state=?, updated_at=? WHERE address=? AND state=?",
+		common.RequestStateDone, time.Now().UTC(), address, common.RequestStateInitial)
+	if err != nil {
+		return fmt.Errorf("UPDATE safe_relays %v", err)
+	}
+	return tx.Commit()
+}
+
 func (s *SQLite3Store) CheckAccountProposed(ctx context.Context, addr string) (bool, error) {
 	rows, err := s.db.QueryContext(ctx, "SELECT created_at FROM accounts WHERE address=?", addr)
 	if err != nil {
@@ -519,6 +661,26 @@ func (s *SQLite3Store) ListFullySignedTransactionApprovals(ctx context.Context,
 	return approvals, nil
 }
 
+func (s *SQLite3Store) ListBroadcastTransactionApprovals(ctx context.Context, chain byte, offset time.Time) ([]*Transaction, error) {
+	query := fmt.Sprintf("SELECT %s FROM transactions WHERE chain=? AND state=? AND created_at>=? AND spent_hash IS NOT NULL ORDER BY created_at ASC", strings.Join(transactionCols, ","))
+	rows, err := s.db.QueryContext(ctx, query, chain, common.RequestStateDone, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var approvals []*Transaction
+	for rows.Next() {
+		var t Transaction
+		err = rows.Scan(&t.TransactionHash, &t.RawTransaction, &t.Chain, &t.Holder, &t.Signer, &t.State, &t.SpentHash, &t.SpentRaw, &t.CreatedAt, &t.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		approvals = append(approvals, &t)
+	}
+	return approvals, nil
+}
+
 func (s *SQLite3Store) ListPendingTransactionApprovals(ctx context.Context, chain byte) ([]*Transaction, error) {
 	query := fmt.Sprintf("SELECT %s FROM transactions WHERE chain=? AND state=? ORDER BY created_at ASC", strings.Join(transactionCols, ","))
 	rows, err := s.db.QueryContext(ctx, query, chain, common.RequestStatePending)
@@ -593,6 +755,27 @@ func (s *SQLite3Store) RevokeTransactionApproval(ctx context.Context, transactio
 	return tx.Commit()
 }
 
+// the approval is rejected when its contract call is no longer allowed, and
+// kept with the holder signature to be shown as rejected
+func (s *SQLite3Store) RejectTransactionApproval(ctx context.Context, transactionHash string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = s.execOne(ctx, tx, "UPDATE transactions SET state=?, updated_at=? WHERE transaction_hash=? AND state=?",
+		common.RequestStateFailed, time.Now().UTC(), transactionHash, common.RequestStatePending)
+	if err != nil {
+		return fmt.Errorf("UPDATE transactions %v", err)
+	}
+
+	return tx.Commit()
+}
+
 func (s *SQLite3Store) AddTransactionPartials(ctx context.Context, transactionHash string, raw string) error {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
@@ -681,7 +864,9 @@ func (s *SQLite3Store) ReadTransactionApproval(ctx context.Context, hash string)
 	return &t, err
 }
 
-func (s *SQLite3Store) WriteAccountantKeys(ctx context.Context, crv byte, keys map[string]*btcec.PrivateKey) error {
+// the accountant keys are imported to the key vault if configured, and
+// only the vault label is kept in the private key column
+func (s *SQLite3Store) WriteAccountantKeys(ctx context.Context, crv byte, keys map[string]*btcec.PrivateKey, vault KeyVault) error {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
@@ -693,8 +878,16 @@ func (s *SQLite3Store) WriteAccountantKeys(ctx context.Context, crv byte, keys m
 
 	for addr, priv := range keys {
 		pub := hex.EncodeToString(priv.PubKey().SerializeCompressed())
+		key := hex.EncodeToString(priv.Serialize())
+		if vault != nil {
+			err = vault.ImportKey(ctx, addr, priv.Serialize())
+			if err != nil {
+				return err
+			}
+			key = vaultAccountantPrefix + addr
+		}
 		cols := []string{"public_key", "private_key", "address", "curve", "created_at"}
-		vals := []any{pub, hex.EncodeToString(priv.Serialize()), addr, crv, time.Now().UTC()}
+		vals := []any{pub, key, addr, crv, time.Now().UTC()}
 		err = s.execOne(ctx, tx, buildInsertionSQL("accountants", cols), vals...)
 		if err != nil {
 			return fmt.Errorf("INSERT accountants %v", err)
@@ -704,6 +897,52 @@ func (s *SQLite3Store) WriteAccountantKeys(ctx context.Context, crv byte, keys m
 	return tx.Commit()
 }
 
+func (s *SQLite3Store) MoveAccountantKeysToVault(ctx context.Context, vault KeyVault) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	query := "SELECT address, private_key FROM accountants WHERE curve!=? AND private_key NOT LIKE ?"
+	rows, err := tx.QueryContext(ctx, query, common.CurveEdwards25519Mixin, vaultAccountantPrefix+"%")
+	if err != nil {
+		return 0, err
+	}
+	var accountants [][2]string
+	for rows.Next() {
+		var addr, key string
+		err = rows.Scan(&addr, &key)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+		accountants = append(accountants, [2]string{addr, key})
+	}
+	rows.Close()
+
+	for _, a := range accountants {
+		priv, err := hex.DecodeString(a[1])
+		if err != nil {
+			return 0, err
+		}
+		err = vault.ImportKey(ctx, a[0], priv)
+		if err != nil {
+			return 0, err
+		}
+		err = s.execOne(ctx, tx, "UPDATE accountants SET private_key=? WHERE address=? AND private_key=?",
+			vaultAccountantPrefix+a[0], a[0], a[1])
+		if err != nil {
+			return 0, fmt.Errorf("UPDATE accountants %v", err)
+		}
+	}
+
+	return len(accountants), tx.Commit()
+}
+
 func (s *SQLite3Store) ReadAccountantPrivateKey(ctx context.Context, address string) (string, error) {
 	query := "SELECT private_key FROM accountants WHERE address=?"
 	row := s.db.QueryRowContext(ctx, query, address)
@@ -738,6 +977,36 @@ func (s *SQLite3Store) WriteObserverKeys(ctx context.Context, crv byte, publics
 	return tx.Commit()
 }
 
+// the kernel observer keys are view keys, and the private view keys are
+// kept as accountants to scan the deposits to the safe addresses
+func (s *SQLite3Store) WriteMixinObserverKeys(ctx context.Context, keys map[string]string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for pub, priv := range keys {
+		cols := []string{"public_key", "curve", "chain_code", "created_at"}
+		vals := []any{pub, common.CurveEdwards25519Mixin, hex.EncodeToString(make([]byte, 32)), time.Now().UTC()}
+		err = s.execOne(ctx, tx, buildInsertionSQL("observers", cols), vals...)
+		if err != nil {
+			return fmt.Errorf("INSERT observers %v", err)
+		}
+		cols = []string{"public_key", "private_key", "address", "curve", "created_at"}
+		vals = []any{pub, priv, pub, common.CurveEdwards25519Mixin, time.Now().UTC()}
+		err = s.execOne(ctx, tx, buildInsertionSQL("accountants", cols), vals...)
+		if err != nil {
+			return fmt.Errorf("INSERT accountants %v", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
 func (s *SQLite3Store) ReadObserverKey(ctx context.Context, crv byte) (string, []byte, error) {
 	var public, chainCode string
 	row := s.db.QueryRowContext(ctx, "SELECT public_key,chain_code FROM observers WHERE curve=? LIMIT 1", crv)
@@ -876,3 +1145,320 @@ func (s *SQLite3Store) ListInitialRecoveries(ctx context.Context, offset int64)
 	}
 	return recoveries, nil
 }
+
+func (s *SQLite3Store) WriteCallAllowlist(ctx context.Context, address, target, selector string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	existed, err := s.checkExistence(ctx, tx, "SELECT created_at FROM call_allowlists WHERE address=? AND target=? AND selector=?", address, target, selector)
+	if err != nil || existed {
+		return err
+	}
+	cols := []string{"address", "target", "selector", "created_at"}
+	vals := []any{address, target, selector, time.Now().UTC()}
+	err = s.execOne(ctx, tx, buildInsertionSQL("call_allowlists", cols), vals...)
+	if err != nil {
+		return fmt.Errorf("INSERT call_allowlists %v", err)
+	}
+
+	return tx.Commit()
+}
+
+func (s *SQLite3Store) DeleteCallAllowlist(ctx context.Context, address, target, selector string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = s.execOne(ctx, tx, "DELETE FROM call_allowlists WHERE address=? AND target=? AND selector=?", address, target, selector)
+	if err != nil {
+		return fmt.Errorf("DELETE call_allowlists %v", err)
+	}
+
+	return tx.Commit()
+}
+
+func (s *SQLite3Store) CheckCallAllowed(ctx context.Context, address, target, selector string) (bool, error) {
+	row := s.db.QueryRowContext(ctx, "SELECT created_at FROM call_allowlists WHERE address=? AND target=? AND selector=?", address, target, selector)
+	var createdAt time.Time
+	err := row.Scan(&createdAt)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (s *SQLite3Store) ListCallAllowlist(ctx context.Context, address string) ([][2]string, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT target,selector FROM call_allowlists WHERE address=? ORDER BY created_at ASC", address)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var calls [][2]string
+	for rows.Next() {
+		var target, selector string
+		err = rows.Scan(&target, &selector)
+		if err != nil {
+			return nil, err
+		}
+		calls = append(calls, [2]string{target, selector})
+	}
+	return calls, nil
+}
+
+// the child spends the accountant change output of the fee input transaction,
+// and its output is added back to the accountant utxos
+func (s *SQLite3Store) WriteBitcoinChildFeeBump(ctx context.Context, bump *FeeBump, msgTx *wire.MsgTx, receiver string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, in := range msgTx.TxIn {
+		err = s.execOne(ctx, tx, "UPDATE bitcoin_outputs SET state=?,spent_by=?,updated_at=? WHERE transaction_hash=? AND output_index=? AND state=? AND spent_by IS NULL",
+			common.RequestStateDone, bump.BumpHash, time.Now().UTC(), in.PreviousOutPoint.Hash.String(), in.PreviousOutPoint.Index, common.RequestStateInitial)
+		if err != nil {
+			return fmt.Errorf("UPDATE bitcoin_outputs %v", err)
+		}
+	}
+	err = s.writeBitcoinAccountantOutputs(ctx, tx, msgTx, bump.RawTransaction, receiver, bump.Chain)
+	if err != nil {
+		return err
+	}
+
+	err = s.execOne(ctx, tx, buildInsertionSQL("bitcoin_fee_bumps", feeBumpCols), bump.values()...)
+	if err != nil {
+		return fmt.Errorf("INSERT bitcoin_fee_bumps %v", err)
+	}
+	return tx.Commit()
+}
+
+// the replacement becomes the spent transaction of the approval, and the
+// pending deposits of the replaced transaction would never be confirmed
+func (s *SQLite3Store) WriteBitcoinReplaceFeeBump(ctx context.Context, bump *FeeBump, spentHash string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := "UPDATE transactions SET spent_hash=?, spent_raw=?, updated_at=? WHERE transaction_hash=? AND state=? AND spent_hash=?"
+	err = s.execOne(ctx, tx, query, bump.BumpHash, bump.RawTransaction, time.Now().UTC(), bump.TransactionHash, common.RequestStateDone, spentHash)
+	if err != nil {
+		return fmt.Errorf("UPDATE transactions %v", err)
+	}
+	_, err = tx.ExecContext(ctx, "DELETE FROM deposits WHERE transaction_hash=? AND state=?", spentHash, common.RequestStateInitial)
+	if err != nil {
+		return fmt.Errorf("DELETE deposits %v", err)
+	}
+
+	err = s.execOne(ctx, tx, buildInsertionSQL("bitcoin_fee_bumps", feeBumpCols), bump.values()...)
+	if err != nil {
+		return fmt.Errorf("INSERT bitcoin_fee_bumps %v", err)
+	}
+	return tx.Commit()
+}
+
+func (s *SQLite3Store) ListBitcoinFeeBumps(ctx context.Context, transactionHash string) ([]*FeeBump, error) {
+	query := fmt.Sprintf("SELECT %s FROM bitcoin_fee_bumps WHERE transaction_hash=? ORDER BY created_at ASC", strings.Join(feeBumpCols, ","))
+	rows, err := s.db.QueryContext(ctx, query, transactionHash)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var bumps []*FeeBump
+	for rows.Next() {
+		var b FeeBump
+		err = rows.Scan(&b.BumpHash, &b.TransactionHash, &b.Kind, &b.Chain, &b.FeeRate, &b.Fee, &b.RawTransaction, &b.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		bumps = append(bumps, &b)
+	}
+	return bumps, nil
+}
+
+// the block hashes are kept within the window below the checkpoint, which
+// is much deeper than the finalization delay of all chains
+func (s *SQLite3Store) WriteChainBlockCheckpoint(ctx context.Context, b *ChainBlock, key string, window int64) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	txn, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer txn.Rollback()
+
+	err = s.execOne(ctx, txn, buildInsertionSQL("chain_blocks", chainBlockCols), b.values()...)
+	if err != nil {
+		return fmt.Errorf("INSERT chain_blocks %v", err)
+	}
+	_, err = txn.ExecContext(ctx, "DELETE FROM chain_blocks WHERE chain=? AND height<?", b.Chain, b.Height-window)
+	if err != nil {
+		return fmt.Errorf("DELETE chain_blocks %v", err)
+	}
+	err = s.writeProperty(ctx, txn, key, fmt.Sprint(b.Height+1))
+	if err != nil {
+		return err
+	}
+	return txn.Commit()
+}
+
+// the same fork may be reorganized again before the verification, and the
+// highest checkpoint is kept to rescan all the blocks of both chains
+func (s *SQLite3Store) RewindChainBlockCheckpoint(ctx context.Context, r *ChainReorg, key string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	txn, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer txn.Rollback()
+
+	_, err = txn.ExecContext(ctx, "DELETE FROM chain_blocks WHERE chain=? AND height>=?", r.Chain, r.Fork)
+	if err != nil {
+		return fmt.Errorf("DELETE chain_blocks %v", err)
+	}
+	err = s.writeProperty(ctx, txn, key, fmt.Sprint(r.Fork))
+	if err != nil {
+		return err
+	}
+
+	existed, err := s.checkExistence(ctx, txn, "SELECT state FROM chain_reorgs WHERE chain=? AND fork=?", r.Chain, r.Fork)
+	if err != nil {
+		return err
+	}
+	if existed {
+		query := "UPDATE chain_reorgs SET checkpoint=MAX(checkpoint, ?), state=?, updated_at=? WHERE chain=? AND fork=?"
+		err = s.execOne(ctx, txn, query, r.Checkpoint, common.RequestStateInitial, r.UpdatedAt, r.Chain, r.Fork)
+		if err != nil {
+			return fmt.Errorf("UPDATE chain_reorgs %v", err)
+		}
+	} else {
+		err = s.execOne(ctx, txn, buildInsertionSQL("chain_reorgs", chainReorgCols), r.values()...)
+		if err != nil {
+			return fmt.Errorf("INSERT chain_reorgs %v", err)
+		}
+	}
+	return txn.Commit()
+}
+
+func (s *SQLite3Store) ListPendingChainReorgs(ctx context.Context, chain byte, checkpoint int64) ([]*ChainReorg, error) {
+	query := fmt.Sprintf("SELECT %s FROM chain_reorgs WHERE chain=? AND state=? AND checkpoint<=? ORDER BY fork ASC", strings.Join(chainReorgCols, ","))
+	rows, err := s.db.QueryContext(ctx, query, chain, common.RequestStateInitial, checkpoint)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reorgs []*ChainReorg
+	for rows.Next() {
+		var r ChainReorg
+		err := rows.Scan(&r.Chain, &r.Fork, &r.Checkpoint, &r.ForkedAt, &r.State, &r.CreatedAt, &r.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		reorgs = append(reorgs, &r)
+	}
+	return reorgs, nil
+}
+
+func (s *SQLite3Store) FinishChainReorg(ctx context.Context, chain byte, fork int64) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	txn, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer txn.Rollback()
+
+	query := "UPDATE chain_reorgs SET state=?, updated_at=? WHERE chain=? AND fork=? AND state=?"
+	err = s.execOne(ctx, txn, query, common.RequestStateDone, time.Now().UTC(), chain, fork, common.RequestStateInitial)
+	if err != nil {
+		return fmt.Errorf("UPDATE chain_reorgs %v", err)
+	}
+	return txn.Commit()
+}
+
+func (s *SQLite3Store) ReadChainBlock(ctx context.Context, chain byte, height int64) (*ChainBlock, error) {
+	query := fmt.Sprintf("SELECT %s FROM chain_blocks WHERE chain=? AND height=?", strings.Join(chainBlockCols, ","))
+	row := s.db.QueryRowContext(ctx, query, chain, height)
+
+	var b ChainBlock
+	err := row.Scan(&b.Chain, &b.Height, &b.Hash, &b.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return &b, err
+}
+
+func (s *SQLite3Store) ListChainDepositsSince(ctx context.Context, chain byte, offset time.Time) ([]*Deposit, error) {
+	query := fmt.Sprintf("SELECT %s FROM deposits WHERE chain=? AND created_at>=? ORDER BY created_at ASC", strings.Join(depositsCols, ","))
+	rows, err := s.db.QueryContext(ctx, query, chain, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deposits []*Deposit
+	for rows.Next() {
+		var d Deposit
+		err := rows.Scan(&d.TransactionHash, &d.OutputIndex, &d.AssetId, &d.AssetAddress, &d.Amount, &d.Receiver, &d.Sender, &d.State, &d.Chain, &d.Holder, &d.Category, &d.RequestId, &d.CreatedAt, &d.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		deposits = append(deposits, &d)
+	}
+	return deposits, nil
+}
+
+// the orphaned deposit not sent to the keeper is deleted, and will be written
+// again if its transaction is included by a block of the new chain, otherwise
+// it is failed to stop the confirmation and kept for manual handling
+func (s *SQLite3Store) WriteOrphanedDeposit(ctx context.Context, d *Deposit, sent bool) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	txn, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer txn.Rollback()
+
+	if sent {
+		query := "UPDATE deposits SET state=?, updated_at=? WHERE transaction_hash=? AND output_index=? AND state IN (?, ?)"
+		err = s.execOne(ctx, txn, query, common.RequestStateFailed, time.Now().UTC(), d.TransactionHash, d.OutputIndex, common.RequestStateInitial, common.RequestStateDone)
+		if err != nil {
+			return fmt.Errorf("UPDATE deposits %v", err)
+		}
+	} else {
+		query := "DELETE FROM deposits WHERE transaction_hash=? AND output_index=? AND state=?"
+		err = s.execOne(ctx, txn, query, d.TransactionHash, d.OutputIndex, common.RequestStateInitial)
+		if err != nil {
+			return fmt.Errorf("DELETE deposits %v", err)
+		}
+	}
+	return txn.Commit()
+}
@@ -0,0 +1,620 @@
+package observer
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/MixinNetwork/mixin/logger"
+	"github.com/MixinNetwork/safe/apps/tron"
+	"github.com/MixinNetwork/safe/common"
+	"github.com/MixinNetwork/safe/keeper"
+	"github.com/MixinNetwork/safe/keeper/store"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/gofrs/uuid/v5"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	// the trx burned to activate a new account and the bandwidth of transfers
+	tronAccountActivationFee = 1100000
+	tronTransferBandwidthFee = 1000000
+)
+
+func (node *Node) tronFundingAddress() string {
+	key, err := crypto.HexToECDSA(node.conf.TronKey)
+	if err != nil {
+		panic(err)
+	}
+	return tron.EncodeAddress(crypto.PubkeyToAddress(key.PublicKey).Bytes())
+}
+
+// the permission update burns trx of the safe account, so the account is
+// funded by the observer before the signed transaction is broadcasted
+func (node *Node) deployTronSafeAccount(ctx context.Context, data []byte) error {
+	logger.Printf("node.deployTronSafeAccount(%x)", data)
+	st, err := tron.UnmarshalSafeTransaction(data)
+	if err != nil {
+		return fmt.Errorf("tron.UnmarshalSafeTransaction(%x) => %v", data, err)
+	}
+	if !st.IsPermissionUpdate() || !st.IsFullySigned() {
+		panic(st.Hash())
+	}
+	addr, err := st.Owner()
+	if err != nil {
+		panic(err)
+	}
+	safe, err := node.keeperStore.ReadSafeByAddress(ctx, addr)
+	if err != nil || safe == nil {
+		return fmt.Errorf("keeperStore.ReadSafeByAddress(%s) => %v %v", addr, safe, err)
+	}
+	assetId := common.SafeTronChainId
+	_, err = node.checkOrDeployKeeperBond(ctx, safe.Chain, assetId, "", safe.Holder, safe.Address)
+	logger.Printf("node.checkOrDeployKeeperBond(%s, %s) => %v", assetId, safe.Holder, err)
+	if err != nil {
+		return fmt.Errorf("node.checkOrDeployKeeperBond(%s, %s) => %v", assetId, safe.Holder, err)
+	}
+
+	rtx, _, err := tron.RPCGetTransaction(node.conf.TronRPC, st.Hash())
+	if err != nil {
+		return err
+	}
+	if rtx == nil {
+		err = node.tronFundSafeAccount(ctx, safe.Address, st.Hash(), tron.PermissionUpdateFee+tronAccountActivationFee)
+		if err != nil {
+			return err
+		}
+		id, err := tron.RPCBroadcastTransaction(node.conf.TronRPC, st.Serialize())
+		logger.Printf("tron.RPCBroadcastTransaction(%s) => %s %v", st.Hash(), id, err)
+		if err != nil {
+			return err
+		}
+	}
+	err = node.store.MarkAccountApproved(ctx, safe.Address)
+	logger.Printf("store.MarkAccountApproved(%s) => %v", safe.Address, err)
+	return err
+}
+
+// the fee is paid only once for each transaction, and the transfer from the
+// funding address is not accepted as a deposit to the safe
+func (node *Node) tronFundSafeAccount(ctx context.Context, addr, hash string, amount int64) error {
+	key := fmt.Sprintf("tron-fee-%s", hash)
+	paid, err := node.store.ReadProperty(ctx, key)
+	if err != nil || paid == "" {
+		balance, err := tron.RPCGetBalance(node.conf.TronRPC, addr)
+		logger.Printf("tron.RPCGetBalance(%s) => %d %v", addr, balance, err)
+		if err != nil {
+			return err
+		}
+		if balance < amount {
+			id, err := tron.TransferFromPrivateKey(node.conf.TronRPC, node.conf.TronKey, addr, amount-balance)
+			logger.Printf("tron.TransferFromPrivateKey(%s, %d) => %s %v", addr, amount-balance, id, err)
+			if err != nil {
+				return err
+			}
+			paid = id
+		}
+		err = node.store.WriteProperty(ctx, key, fmt.Sprintf("%s:%d", paid, amount))
+		if err != nil {
+			return err
+		}
+	}
+
+	for i := 0; i < 12; i++ {
+		balance, err := tron.RPCGetBalance(node.conf.TronRPC, addr)
+		logger.Verbosef("tron.RPCGetBalance(%s) => %d %v", addr, balance, err)
+		if err != nil {
+			return err
+		}
+		if balance >= amount {
+			return nil
+		}
+		time.Sleep(5 * time.Second)
+	}
+	return fmt.Errorf("insufficient tron balance %s %d", addr, amount)
+}
+
+func (node *Node) tronNetworkInfoLoop(ctx context.Context) {
+	chain := byte(common.SafeChainTron)
+
+	for {
+		time.Sleep(depositNetworkInfoDelay)
+		block, err := tron.RPCGetNowBlock(node.conf.TronRPC)
+		if err != nil {
+			logger.Printf("tron.RPCGetNowBlock() => %v", err)
+			continue
+		}
+		delay := node.getChainFinalizationDelay(chain)
+		if delay > block.Height() || delay < 1 {
+			panic(delay)
+		}
+		height := block.Height() + 1 - delay
+		info, err := node.keeperStore.ReadLatestNetworkInfo(ctx, chain, time.Now())
+		if err != nil {
+			panic(err)
+		}
+		if info != nil && info.Height > uint64(height) {
+			logger.Printf("node.keeperStore.ReadLatestNetworkInfo(%d) => %v %d", chain, info, height)
+			continue
+		}
+		block, err = tron.RPCGetBlockByNumber(node.conf.TronRPC, height)
+		if err != nil || block == nil {
+			logger.Printf("tron.RPCGetBlockByNumber(%d) => %v %v", height, block, err)
+			continue
+		}
+		hash, err := hex.DecodeString(block.BlockID)
+		if err != nil || len(hash) != 32 {
+			panic(block.BlockID)
+		}
+		extra := []byte{chain}
+		extra = binary.BigEndian.AppendUint64(extra, 0)
+		extra = binary.BigEndian.AppendUint64(extra, uint64(height))
+		extra = append(extra, hash...)
+		id := common.UniqueId(common.SafeTronChainId, fmt.Sprintf("%s:%d", block.BlockID, height))
+		id = common.UniqueId(id, fmt.Sprint(time.Now().UnixNano()))
+		logger.Printf("node.tronNetworkInfoLoop(%d) => %d %s %s", chain, height, block.BlockID, id)
+
+		dummy := node.bitcoinDummyHolder()
+		action := common.ActionObserverUpdateNetworkStatus
+		err = node.sendKeeperResponse(ctx, dummy, byte(action), chain, id, extra)
+		logger.Verbosef("node.sendKeeperResponse(%d, %s, %x) => %v", chain, id, extra, err)
+	}
+}
+
+func (node *Node) tronRPCBlocksLoop(ctx context.Context) {
+	chain := byte(common.SafeChainTron)
+
+	for {
+		checkpoint, err := node.readDepositCheckpoint(ctx, chain)
+		if err != nil {
+			panic(err)
+		}
+		block, err := tron.RPCGetNowBlock(node.conf.TronRPC)
+		logger.Printf("tron.RPCGetNowBlock(%d) => %v", checkpoint, err)
+		if err != nil || checkpoint > block.Height() {
+			time.Sleep(time.Second * 3)
+			continue
+		}
+		block, err = tron.RPCGetBlockByNumber(node.conf.TronRPC, checkpoint)
+		logger.Verbosef("tron.RPCGetBlockByNumber(%d) => %v", checkpoint, err)
+		if err != nil || block == nil {
+			time.Sleep(time.Second * 3)
+			continue
+		}
+		err = node.tronProcessBlock(ctx, block)
+		if err != nil {
+			panic(err)
+		}
+
+		err = node.tronWriteDepositCheckpoint(ctx, checkpoint+1)
+		if err != nil {
+			panic(err)
+		}
+	}
+}
+
+func (node *Node) tronWriteDepositCheckpoint(ctx context.Context, num int64) error {
+	return node.store.WriteProperty(ctx, depositCheckpointKey(common.SafeChainTron), fmt.Sprint(num))
+}
+
+func (node *Node) tronProcessBlock(ctx context.Context, block *tron.RPCBlock) error {
+	safes, err := node.listTronSafes(ctx)
+	if err != nil || len(safes) == 0 {
+		return err
+	}
+	funding := node.tronFundingAddress()
+	for _, tx := range block.Transactions {
+		for _, t := range tx.ExtractTransfers() {
+			safe := safes[t.Receiver]
+			if safe == nil || t.Sender == t.Receiver || t.Sender == funding {
+				continue
+			}
+			err := node.tronWritePendingDeposit(ctx, safe, t)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (node *Node) listTronSafes(ctx context.Context) (map[string]*store.Safe, error) {
+	safes, err := node.keeperStore.ListSafesWithState(ctx, keeper.SafeStateApproved)
+	if err != nil {
+		return nil, err
+	}
+	addrs := make(map[string]*store.Safe)
+	for _, safe := range safes {
+		if safe.Chain != common.SafeChainTron {
+			continue
+		}
+		addrs[safe.Address] = safe
+	}
+	return addrs, nil
+}
+
+func (node *Node) tronWritePendingDeposit(ctx context.Context, safe *store.Safe, transfer *tron.Transfer) error {
+	old, err := node.keeperStore.ReadDeposit(ctx, transfer.Hash, transfer.Index)
+	logger.Printf("keeperStore.ReadDeposit(%s, %d, %s, %s) => %v %v", transfer.Hash, transfer.Index, transfer.AssetId, transfer.Receiver, old, err)
+	if err != nil {
+		return fmt.Errorf("keeperStore.ReadDeposit(%s, %d) => %v", transfer.Hash, transfer.Index, err)
+	} else if old != nil {
+		return nil
+	}
+
+	asset, err := node.fetchAssetMeta(ctx, transfer.AssetId)
+	logger.Printf("node.fetchAssetMeta(%s) => %v %v", transfer.AssetId, asset, err)
+	if err != nil || asset == nil {
+		return err
+	}
+	if asset.Chain != common.SafeChainTron {
+		return nil
+	}
+	decimals := int32(tron.ValuePrecision)
+	if transfer.AssetId != common.SafeTronChainId {
+		decimals = int32(asset.Decimals)
+	}
+	amount := decimal.NewFromBigInt(transfer.Value, -decimals)
+	minimum := decimal.RequireFromString(node.conf.TransactionMinimum)
+	if transfer.AssetId == common.SafeTronChainId && amount.Cmp(minimum) < 0 {
+		return nil
+	}
+
+	_, err = node.checkOrDeployKeeperBond(ctx, safe.Chain, transfer.AssetId, transfer.TokenAddress, safe.Holder, safe.Address)
+	if err != nil {
+		return fmt.Errorf("node.checkOrDeployKeeperBond(%s) => %v", safe.Holder, err)
+	}
+
+	id := common.UniqueId(transfer.AssetId, safe.Holder)
+	id = common.UniqueId(id, fmt.Sprintf("%s:%d", transfer.Hash, transfer.Index))
+	createdAt := time.Now().UTC()
+	deposit := &Deposit{
+		TransactionHash: transfer.Hash,
+		OutputIndex:     transfer.Index,
+		AssetId:         transfer.AssetId,
+		AssetAddress:    transfer.TokenAddress,
+		Amount:          amount.String(),
+		Receiver:        transfer.Receiver,
+		Sender:          transfer.Sender,
+		Holder:          safe.Holder,
+		Category:        common.ActionObserverHolderDeposit,
+		State:           common.RequestStateInitial,
+		Chain:           common.SafeChainTron,
+		RequestId:       id,
+		CreatedAt:       createdAt,
+		UpdatedAt:       createdAt,
+	}
+
+	err = node.store.WritePendingDepositIfNotExists(ctx, deposit)
+	if err != nil {
+		return fmt.Errorf("store.WritePendingDeposit(%v) => %v", deposit, err)
+	}
+	return nil
+}
+
+func (node *Node) tronConfirmPendingDeposit(ctx context.Context, deposit *Deposit) error {
+	asset, err := node.store.ReadAssetMeta(ctx, deposit.AssetId)
+	if err != nil || asset == nil {
+		return err
+	}
+	safe, err := node.keeperStore.ReadSafe(ctx, deposit.Holder)
+	if err != nil || safe == nil {
+		return err
+	}
+	bonded, err := node.checkOrDeployKeeperBond(ctx, deposit.Chain, deposit.AssetId, asset.AssetKey, deposit.Holder, safe.Address)
+	if err != nil {
+		return fmt.Errorf("node.checkOrDeployKeeperBond(%s) => %v", deposit.Holder, err)
+	} else if !bonded {
+		return nil
+	}
+	decimals := int32(tron.ValuePrecision)
+	if deposit.AssetId != common.SafeTronChainId {
+		decimals = int32(asset.Decimals)
+	}
+
+	info, err := node.keeperStore.ReadLatestNetworkInfo(ctx, deposit.Chain, time.Now())
+	if err != nil {
+		return fmt.Errorf("keeperStore.ReadLatestNetworkInfo(%d) => %v", deposit.Chain, err)
+	} else if info == nil {
+		return nil
+	}
+	if info.CreatedAt.After(time.Now()) {
+		panic(fmt.Errorf("malicious tron network info %v", info))
+	}
+
+	_, ti, err := tron.RPCGetTransaction(node.conf.TronRPC, deposit.TransactionHash)
+	if err != nil {
+		return err
+	}
+	if ti == nil {
+		return nil
+	}
+	confirmations := int64(info.Height) - ti.BlockNumber + 1
+	if int64(info.Height) < ti.BlockNumber {
+		confirmations = 0
+	}
+	isSafe, err := node.checkTrustedSender(ctx, deposit.Sender)
+	if err != nil {
+		return fmt.Errorf("node.checkTrustedSender(%s) => %v", deposit.Sender, err)
+	}
+	if isSafe && confirmations > 0 {
+		confirmations = 1000000
+	}
+	if confirmations < tron.TransactionConfirmations {
+		return nil
+	}
+
+	return node.sendKeeperDepositTransaction(ctx, deposit, decimals)
+}
+
+func (node *Node) tronDepositConfirmLoop(ctx context.Context) {
+	for {
+		time.Sleep(3 * time.Second)
+		deposits, err := node.store.ListDeposits(ctx, common.SafeChainTron, "", common.RequestStateInitial, 0)
+		if err != nil {
+			panic(err)
+		}
+		for _, d := range deposits {
+			err := node.tronConfirmPendingDeposit(ctx, d)
+			if err != nil {
+				panic(err)
+			}
+		}
+	}
+}
+
+func (node *Node) tronTransactionApprovalLoop(ctx context.Context) {
+	for {
+		time.Sleep(3 * time.Second)
+		approvals, err := node.store.ListPendingTransactionApprovals(ctx, common.SafeChainTron)
+		if err != nil {
+			panic(err)
+		}
+		for _, approval := range approvals {
+			err := node.sendToKeeperTronApproveTransaction(ctx, approval)
+			logger.Verbosef("node.sendToKeeperTronApproveTransaction(%v) => %v", approval, err)
+			if err != nil {
+				panic(err)
+			}
+		}
+	}
+}
+
+func (node *Node) sendToKeeperTronApproveTransaction(ctx context.Context, approval *Transaction) error {
+	requests, err := node.keeperStore.ListAllSignaturesForTransaction(ctx, approval.TransactionHash, common.RequestStateDone)
+	if err != nil {
+		return err
+	}
+	if len(requests) > 0 {
+		return nil
+	}
+	st, err := tron.UnmarshalSafeTransaction(common.DecodeHexOrPanic(approval.RawTransaction))
+	if err != nil {
+		panic(approval.RawTransaction)
+	}
+	safe, err := node.keeperStore.ReadSafe(ctx, approval.Holder)
+	if err != nil {
+		return err
+	}
+	signedByHolder := tron.CheckTransactionPartiallySignedBy(approval.RawTransaction, safe.Holder)
+	signedByObserver := tron.CheckTransactionPartiallySignedBy(approval.RawTransaction, safe.Observer)
+	if !signedByHolder && !signedByObserver {
+		panic(approval.RawTransaction)
+	}
+
+	tx, err := node.keeperStore.ReadTransaction(ctx, approval.TransactionHash)
+	if err != nil {
+		return err
+	}
+	id := common.UniqueId(approval.TransactionHash, approval.TransactionHash)
+	rid := uuid.Must(uuid.FromString(tx.RequestId))
+	extra := append(rid.Bytes(), st.HolderSignature...)
+	action := common.ActionTronSafeApproveTransaction
+	err = node.sendKeeperResponse(ctx, tx.Holder, byte(action), approval.Chain, id, extra)
+	logger.Printf("node.sendKeeperResponse(%s, %d, %s, %x) => %v", tx.Holder, action, id, extra, err)
+	if err != nil {
+		return err
+	}
+
+	// the observer approval is only accepted by the keeper after the timelock
+	if approval.UpdatedAt.Add(keeper.SafeSignatureTimeout).After(time.Now()) {
+		return nil
+	}
+	id = common.UniqueId(id, approval.UpdatedAt.String())
+	err = node.sendKeeperResponse(ctx, tx.Holder, byte(action), approval.Chain, id, extra)
+	logger.Printf("node.sendKeeperResponse(%s, %d, %s, %x) => %v", tx.Holder, action, id, extra, err)
+	if err != nil {
+		return err
+	}
+	return node.store.UpdateTransactionApprovalRequestTime(ctx, approval.TransactionHash)
+}
+
+func (node *Node) keeperSaveTronTransactionSignatures(ctx context.Context, extra []byte) error {
+	logger.Printf("node.keeperSaveTronTransactionSignatures(%x)", extra)
+	st, err := tron.UnmarshalSafeTransaction(extra)
+	if err != nil {
+		return err
+	}
+	tx, err := node.store.ReadTransactionApproval(ctx, st.Hash())
+	if err != nil || tx.State >= common.RequestStateDone {
+		return err
+	}
+	if tx.Chain != common.SafeChainTron {
+		panic(st.Hash())
+	}
+	if !st.IsFullySigned() {
+		panic(st.Hash())
+	}
+	raw := hex.EncodeToString(st.Marshal())
+	err = node.store.FinishTransactionSignatures(ctx, st.Hash(), raw)
+	logger.Printf("store.FinishTransactionSignatures(%s) => %v", st.Hash(), err)
+	return err
+}
+
+func (node *Node) tronTransactionSpendLoop(ctx context.Context) {
+	for {
+		time.Sleep(3 * time.Second)
+		txs, err := node.store.ListFullySignedTransactionApprovals(ctx, common.SafeChainTron)
+		if err != nil {
+			panic(err)
+		}
+		for _, tx := range txs {
+			st, err := tron.UnmarshalSafeTransaction(common.DecodeHexOrPanic(tx.RawTransaction))
+			if err != nil {
+				panic(err)
+			}
+			spentRaw := hex.EncodeToString(st.Serialize())
+			spentHash, err := node.tronSendTransaction(ctx, st)
+			logger.Verbosef("node.tronSendTransaction(%s) => %s %v", tx.TransactionHash, spentHash, err)
+			if err != nil {
+				break
+			}
+			if spentHash != st.Hash() {
+				panic(fmt.Errorf("tron.RPCBroadcastTransaction(%s) => %s", st.Hash(), spentHash))
+			}
+			err = node.store.ConfirmFullySignedTransactionApproval(ctx, tx.TransactionHash, spentHash, spentRaw)
+			if err != nil {
+				panic(err)
+			}
+		}
+	}
+}
+
+// the bandwidth and energy are paid by the trx of the safe account, which
+// is topped up by the observer before the broadcast
+func (node *Node) tronSendTransaction(ctx context.Context, st *tron.SafeTransaction) (string, error) {
+	rtx, _, err := tron.RPCGetTransaction(node.conf.TronRPC, st.Hash())
+	if err != nil {
+		return "", err
+	}
+	if rtx != nil {
+		return rtx.TxID, nil
+	}
+	addr, err := st.Owner()
+	if err != nil {
+		panic(err)
+	}
+	outputs, err := st.ExtractOutputs()
+	if err != nil {
+		panic(err)
+	}
+	amount := int64(tronTransferBandwidthFee)
+	if outputs[0].TokenAddress != tron.TronEmptyAddress {
+		amount = tron.TransactionFeeLimit
+	} else {
+		amount = amount + outputs[0].Amount.Int64()
+	}
+	err = node.tronFundSafeAccount(ctx, addr, st.Hash(), amount)
+	if err != nil {
+		return "", err
+	}
+	return tron.RPCBroadcastTransaction(node.conf.TronRPC, st.Serialize())
+}
+
+func (node *Node) httpApproveTronTransaction(ctx context.Context, raw string) error {
+	logger.Printf("node.httpApproveTronTransaction(%s)", raw)
+	rb, err := hex.DecodeString(raw)
+	if err != nil {
+		return err
+	}
+	st, err := tron.UnmarshalSafeTransaction(rb)
+	if err != nil {
+		return err
+	}
+	txHash := st.Hash()
+
+	approval, err := node.store.ReadTransactionApproval(ctx, txHash)
+	logger.Verbosef("store.ReadTransactionApproval(%s) => %v %v", txHash, approval, err)
+	if err != nil || approval == nil {
+		return err
+	}
+	if approval.State != common.RequestStateInitial {
+		return nil
+	}
+	safe, err := node.keeperStore.ReadSafe(ctx, approval.Holder)
+	if err != nil {
+		return err
+	}
+	if tron.CheckTransactionPartiallySignedBy(approval.RawTransaction, safe.Holder) {
+		return nil
+	}
+	if tron.CheckTransactionPartiallySignedBy(approval.RawTransaction, safe.Observer) {
+		return nil
+	}
+	signedByHolder := tron.CheckTransactionPartiallySignedBy(raw, safe.Holder)
+	signedByObserver := tron.CheckTransactionPartiallySignedBy(raw, safe.Observer)
+	if !signedByHolder && !signedByObserver {
+		return fmt.Errorf("HTTP: %d", http.StatusNotAcceptable)
+	}
+	tx, err := node.keeperStore.ReadTransaction(ctx, txHash)
+	logger.Verbosef("keeperStore.ReadTransaction(%s) => %v %v", txHash, tx, err)
+	if err != nil || tx == nil {
+		return err
+	}
+
+	// only the holder or observer signature is taken, the raw data is the one
+	// proposed by the keeper, which is guaranteed by the same transaction hash
+	st.HolderSignature = tron.ProcessSignature(st.HolderSignature)
+	st.Signature = nil
+	raw = hex.EncodeToString(st.Marshal())
+	err = node.store.AddTransactionPartials(ctx, txHash, raw)
+	logger.Printf("store.AddTransactionPartials(%s) => %v", txHash, err)
+	return err
+}
+
+func (node *Node) httpRevokeTronTransaction(ctx context.Context, txHash string, sigHex string) error {
+	logger.Printf("node.httpRevokeTronTransaction(%s, %s)", txHash, sigHex)
+	approval, err := node.store.ReadTransactionApproval(ctx, txHash)
+	logger.Verbosef("store.ReadTransactionApproval(%s) => %v %v", txHash, approval, err)
+	if err != nil || approval == nil {
+		return err
+	}
+	if approval.State != common.RequestStateInitial {
+		return nil
+	}
+	if tron.CheckTransactionPartiallySignedBy(approval.RawTransaction, approval.Holder) {
+		return nil
+	}
+
+	tx, err := node.keeperStore.ReadTransaction(ctx, txHash)
+	logger.Verbosef("keeperStore.ReadTransaction(%s) => %v %v", txHash, tx, err)
+	if err != nil {
+		return err
+	}
+
+	sig, err := hex.DecodeString(sigHex)
+	if err != nil {
+		return err
+	}
+	ms := fmt.Sprintf("REVOKE:%s:%s", tx.RequestId, tx.TransactionHash)
+	err = tron.VerifyMessageSignature(tx.Holder, []byte(ms), sig)
+	logger.Printf("holder: tron.VerifyMessageSignature(%v) => %v", tx, err)
+	if err != nil {
+		safe, err := node.keeperStore.ReadSafe(ctx, tx.Holder)
+		if err != nil {
+			return err
+		}
+		err = tron.VerifyMessageSignature(safe.Observer, []byte(ms), sig)
+		logger.Printf("observer: tron.VerifyMessageSignature(%v) => %v", tx, err)
+		if err != nil {
+			return err
+		}
+	}
+
+	id := common.UniqueId(approval.TransactionHash, approval.TransactionHash)
+	rid := uuid.Must(uuid.FromString(tx.RequestId))
+	extra := append(rid.Bytes(), sig...)
+	action := common.ActionTronSafeRevokeTransaction
+	err = node.sendKeeperResponse(ctx, tx.Holder, byte(action), approval.Chain, id, extra)
+	logger.Printf("node.sendKeeperResponse(%s, %d, %s, %x)", tx.Holder, action, id, extra)
+	if err != nil {
+		return err
+	}
+
+	err = node.store.RevokeTransactionApproval(ctx, txHash, sigHex+":"+approval.RawTransaction)
+	logger.Printf("store.RevokeTransactionApproval(%s) => %v", txHash, err)
+	return err
+}
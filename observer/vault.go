@@ -0,0 +1,339 @@
+package observer
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/MixinNetwork/safe/apps/bitcoin"
+	"github.com/MixinNetwork/safe/apps/ethereum"
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/ecdsa"
+	gc "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"golang.org/x/crypto/argon2"
+)
+
+const (
+	KeyVaultTypeFile   = "file"
+	KeyVaultTypePKCS11 = "pkcs11"
+
+	VaultEVMKeyLabel      = "evm"
+	vaultAccountantPrefix = "vault:"
+
+	// the file vault passphrase is never kept in the configuration file
+	KeyVaultPassphraseEnv = "SAFE_OBSERVER_KEY_VAULT_PASSPHRASE"
+)
+
+// KeyVault keeps the secp256k1 private keys of the observer, and only
+// signs the digests with them, so the raw keys are never exposed to the
+// observer store or configuration
+type KeyVault interface {
+	ImportKey(ctx context.Context, label string, priv []byte) error
+	PublicKey(ctx context.Context, label string) ([]byte, error)
+	Sign(ctx context.Context, label string, digest []byte) ([]byte, error)
+	Close() error
+}
+
+type KeyVaultConfiguration struct {
+	Type   string `toml:"type"`
+	Path   string `toml:"path"`
+	Module string `toml:"module"`
+	Token  string `toml:"token"`
+	Pin    string `toml:"pin"`
+}
+
+func OpenKeyVault(conf *KeyVaultConfiguration) (KeyVault, error) {
+	switch conf.Type {
+	case "":
+		return nil, nil
+	case KeyVaultTypeFile:
+		return OpenFileKeyVault(conf.Path, os.Getenv(KeyVaultPassphraseEnv))
+	case KeyVaultTypePKCS11:
+		return OpenPKCS11KeyVault(conf.Module, conf.Token, conf.Pin)
+	}
+	return nil, fmt.Errorf("invalid key vault type %s", conf.Type)
+}
+
+type fileKeyVault struct {
+	sync.Mutex
+	path string
+	salt []byte
+	kek  []byte
+	keys map[string]*btcec.PrivateKey
+}
+
+type fileKeyVaultData struct {
+	Salt string `json:"salt"`
+	Data string `json:"data"`
+}
+
+// the vault file is sealed as a whole with a key derived from the passphrase,
+// and it is created with a random salt on the first imported key
+func OpenFileKeyVault(path, passphrase string) (KeyVault, error) {
+	if len(passphrase) < 16 {
+		return nil, fmt.Errorf("passphrase too short %d", len(passphrase))
+	}
+	v := &fileKeyVault{path: path, keys: make(map[string]*btcec.PrivateKey)}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		v.salt = make([]byte, 16)
+		_, err = rand.Read(v.salt)
+		if err != nil {
+			return nil, err
+		}
+		v.kek = fileKeyVaultKEK(passphrase, v.salt)
+		return v, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var fd fileKeyVaultData
+	err = json.Unmarshal(data, &fd)
+	if err != nil {
+		return nil, err
+	}
+	v.salt, err = hex.DecodeString(fd.Salt)
+	if err != nil {
+		return nil, err
+	}
+	v.kek = fileKeyVaultKEK(passphrase, v.salt)
+	sealed, err := hex.DecodeString(fd.Data)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := fileKeyVaultOpen(v.kek, sealed)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key vault passphrase %s", path)
+	}
+	var keys map[string]string
+	err = json.Unmarshal(plain, &keys)
+	if err != nil {
+		return nil, err
+	}
+	for label, k := range keys {
+		b, err := hex.DecodeString(k)
+		if err != nil {
+			return nil, err
+		}
+		v.keys[label], _ = btcec.PrivKeyFromBytes(b)
+	}
+	return v, nil
+}
+
+func fileKeyVaultKEK(passphrase string, salt []byte) []byte {
+	return argon2.IDKey([]byte(passphrase), salt, 3, 64*1024, 4, 32)
+}
+
+func fileKeyVaultSeal(key, plain []byte) ([]byte, error) {
+	aead, err := fileKeyVaultAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plain, nil), nil
+}
+
+func fileKeyVaultOpen(key, sealed []byte) ([]byte, error) {
+	aead, err := fileKeyVaultAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, fmt.Errorf("invalid sealed data size %d", len(sealed))
+	}
+	return aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+}
+
+func fileKeyVaultAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func (v *fileKeyVault) ImportKey(ctx context.Context, label string, priv []byte) error {
+	v.Lock()
+	defer v.Unlock()
+
+	key, _ := btcec.PrivKeyFromBytes(priv)
+	if old := v.keys[label]; old != nil {
+		if old.Key.Equals(&key.Key) {
+			return nil
+		}
+		return fmt.Errorf("key vault label %s exists", label)
+	}
+	v.keys[label] = key
+
+	keys := make(map[string]string)
+	for l, k := range v.keys {
+		keys[l] = hex.EncodeToString(k.Serialize())
+	}
+	plain, err := json.Marshal(keys)
+	if err != nil {
+		panic(err)
+	}
+	sealed, err := fileKeyVaultSeal(v.kek, plain)
+	if err != nil {
+		delete(v.keys, label)
+		return err
+	}
+	data, err := json.Marshal(fileKeyVaultData{
+		Salt: hex.EncodeToString(v.salt),
+		Data: hex.EncodeToString(sealed),
+	})
+	if err != nil {
+		panic(err)
+	}
+	tmp := v.path + ".tmp"
+	err = os.WriteFile(tmp, data, 0600)
+	if err != nil {
+		delete(v.keys, label)
+		return err
+	}
+	err = os.Rename(tmp, v.path)
+	if err != nil {
+		delete(v.keys, label)
+	}
+	return err
+}
+
+func (v *fileKeyVault) PublicKey(ctx context.Context, label string) ([]byte, error) {
+	v.Lock()
+	defer v.Unlock()
+
+	key := v.keys[label]
+	if key == nil {
+		return nil, fmt.Errorf("key vault label %s not found", label)
+	}
+	return key.PubKey().SerializeCompressed(), nil
+}
+
+func (v *fileKeyVault) Sign(ctx context.Context, label string, digest []byte) ([]byte, error) {
+	v.Lock()
+	defer v.Unlock()
+
+	key := v.keys[label]
+	if key == nil {
+		return nil, fmt.Errorf("key vault label %s not found", label)
+	}
+	sig := ecdsa.SignCompact(key, digest, true)
+	return sig[1:], nil
+}
+
+func (v *fileKeyVault) Close() error {
+	return nil
+}
+
+// the hsm may return high s signatures, which are rejected by both
+// the bitcoin and ethereum networks
+func normalizeVaultSignature(sig []byte) ([]byte, error) {
+	if len(sig) != 64 {
+		return nil, fmt.Errorf("invalid signature size %d", len(sig))
+	}
+	n := btcec.S256().N
+	s := new(big.Int).SetBytes(sig[32:])
+	if s.Cmp(new(big.Int).Rsh(n, 1)) > 0 {
+		s.Sub(n, s)
+	}
+	return append(append([]byte{}, sig[:32]...), s.FillBytes(make([]byte, 32))...), nil
+}
+
+type vaultEVMSigner struct {
+	vault   KeyVault
+	label   string
+	public  []byte
+	address gc.Address
+}
+
+func (s *vaultEVMSigner) Address() gc.Address {
+	return s.address
+}
+
+func (s *vaultEVMSigner) SignDigest(ctx context.Context, digest []byte) ([]byte, error) {
+	sig, err := s.vault.Sign(ctx, s.label, digest)
+	if err != nil {
+		return nil, err
+	}
+	return ethereum.RecoverableSignature(digest, sig, s.public)
+}
+
+type vaultAccountantSigner struct {
+	ctx    context.Context
+	vault  KeyVault
+	label  string
+	public []byte
+}
+
+func (s *vaultAccountantSigner) PublicKey() []byte {
+	return s.public
+}
+
+func (s *vaultAccountantSigner) Sign(hash []byte) ([]byte, error) {
+	sig, err := s.vault.Sign(s.ctx, s.label, hash)
+	if err != nil {
+		return nil, err
+	}
+	return bitcoin.EncodeSignatureDER(sig)
+}
+
+func (node *Node) evmSigner(ctx context.Context) (ethereum.EVMSigner, error) {
+	if node.vault == nil {
+		return ethereum.NewPrivateKeySigner(node.conf.EVMKey)
+	}
+	pub, err := node.vault.PublicKey(ctx, VaultEVMKeyLabel)
+	if err != nil {
+		return nil, err
+	}
+	key, err := crypto.DecompressPubkey(pub)
+	if err != nil {
+		return nil, err
+	}
+	return &vaultEVMSigner{
+		vault:   node.vault,
+		label:   VaultEVMKeyLabel,
+		public:  pub,
+		address: crypto.PubkeyToAddress(*key),
+	}, nil
+}
+
+func (node *Node) accountantSigner(ctx context.Context, address string) (bitcoin.AccountantSigner, error) {
+	accountant, err := node.store.ReadAccountantPrivateKey(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+	if accountant == "" {
+		return nil, fmt.Errorf("accountant %s not found", address)
+	}
+	if !strings.HasPrefix(accountant, vaultAccountantPrefix) {
+		b, err := hex.DecodeString(accountant)
+		if err != nil {
+			return nil, err
+		}
+		priv, _ := btcec.PrivKeyFromBytes(b)
+		return bitcoin.NewPrivateKeySigner(priv), nil
+	}
+	if node.vault == nil {
+		return nil, fmt.Errorf("accountant %s in key vault", address)
+	}
+	label := strings.TrimPrefix(accountant, vaultAccountantPrefix)
+	pub, err := node.vault.PublicKey(ctx, label)
+	if err != nil {
+		return nil, err
+	}
+	return &vaultAccountantSigner{ctx: ctx, vault: node.vault, label: label, public: pub}, nil
+}
@@ -0,0 +1,213 @@
+package observer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/miekg/pkcs11"
+)
+
+// the der encoded object identifier of the secp256k1 curve, 1.3.132.0.10
+var pkcs11Secp256k1Params = []byte{0x06, 0x05, 0x2b, 0x81, 0x04, 0x00, 0x0a}
+
+type pkcs11KeyVault struct {
+	sync.Mutex
+	p       *pkcs11.Ctx
+	session pkcs11.SessionHandle
+}
+
+func OpenPKCS11KeyVault(module, token, pin string) (KeyVault, error) {
+	p := pkcs11.New(module)
+	if p == nil {
+		return nil, fmt.Errorf("invalid pkcs11 module %s", module)
+	}
+	err := p.Initialize()
+	if err != nil {
+		p.Destroy()
+		return nil, err
+	}
+	v := &pkcs11KeyVault{p: p}
+
+	slots, err := p.GetSlotList(true)
+	if err != nil {
+		v.finalize()
+		return nil, err
+	}
+	for _, slot := range slots {
+		info, err := p.GetTokenInfo(slot)
+		if err != nil {
+			v.finalize()
+			return nil, err
+		}
+		if info.Label != token {
+			continue
+		}
+		v.session, err = p.OpenSession(slot, pkcs11.CKF_SERIAL_SESSION|pkcs11.CKF_RW_SESSION)
+		if err != nil {
+			v.finalize()
+			return nil, err
+		}
+		err = p.Login(v.session, pkcs11.CKU_USER, pin)
+		if err != nil {
+			p.CloseSession(v.session)
+			v.finalize()
+			return nil, err
+		}
+		return v, nil
+	}
+	v.finalize()
+	return nil, fmt.Errorf("pkcs11 token %s not found", token)
+}
+
+func (v *pkcs11KeyVault) ImportKey(ctx context.Context, label string, priv []byte) error {
+	v.Lock()
+	defer v.Unlock()
+
+	key, pub := btcec.PrivKeyFromBytes(priv)
+	old, err := v.readPublicKey(label)
+	if err != nil {
+		return err
+	}
+	if old != nil {
+		if bytes.Equal(old, pub.SerializeCompressed()) {
+			return nil
+		}
+		return fmt.Errorf("key vault label %s exists", label)
+	}
+
+	point := append([]byte{0x04, 0x41}, pub.SerializeUncompressed()...)
+	public := []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_PUBLIC_KEY),
+		pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_EC),
+		pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
+		pkcs11.NewAttribute(pkcs11.CKA_VERIFY, true),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, label),
+		pkcs11.NewAttribute(pkcs11.CKA_EC_PARAMS, pkcs11Secp256k1Params),
+		pkcs11.NewAttribute(pkcs11.CKA_EC_POINT, point),
+	}
+	private := []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_PRIVATE_KEY),
+		pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_EC),
+		pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
+		pkcs11.NewAttribute(pkcs11.CKA_PRIVATE, true),
+		pkcs11.NewAttribute(pkcs11.CKA_SENSITIVE, true),
+		pkcs11.NewAttribute(pkcs11.CKA_EXTRACTABLE, false),
+		pkcs11.NewAttribute(pkcs11.CKA_SIGN, true),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, label),
+		pkcs11.NewAttribute(pkcs11.CKA_EC_PARAMS, pkcs11Secp256k1Params),
+		pkcs11.NewAttribute(pkcs11.CKA_VALUE, key.Serialize()),
+	}
+	handle, err := v.p.CreateObject(v.session, private)
+	if err != nil {
+		return err
+	}
+	_, err = v.p.CreateObject(v.session, public)
+	if err != nil {
+		v.p.DestroyObject(v.session, handle)
+	}
+	return err
+}
+
+func (v *pkcs11KeyVault) PublicKey(ctx context.Context, label string) ([]byte, error) {
+	v.Lock()
+	defer v.Unlock()
+
+	pub, err := v.readPublicKey(label)
+	if err != nil {
+		return nil, err
+	}
+	if pub == nil {
+		return nil, fmt.Errorf("key vault label %s not found", label)
+	}
+	return pub, nil
+}
+
+func (v *pkcs11KeyVault) Sign(ctx context.Context, label string, digest []byte) ([]byte, error) {
+	v.Lock()
+	defer v.Unlock()
+
+	handle, err := v.findObject(pkcs11.CKO_PRIVATE_KEY, label)
+	if err != nil {
+		return nil, err
+	}
+	if handle == nil {
+		return nil, fmt.Errorf("key vault label %s not found", label)
+	}
+	mechanism := []*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_ECDSA, nil)}
+	err = v.p.SignInit(v.session, mechanism, *handle)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := v.p.Sign(v.session, digest)
+	if err != nil {
+		return nil, err
+	}
+	return normalizeVaultSignature(sig)
+}
+
+func (v *pkcs11KeyVault) Close() error {
+	v.Lock()
+	defer v.Unlock()
+
+	v.p.Logout(v.session)
+	v.p.CloseSession(v.session)
+	v.finalize()
+	return nil
+}
+
+func (v *pkcs11KeyVault) finalize() {
+	v.p.Finalize()
+	v.p.Destroy()
+}
+
+func (v *pkcs11KeyVault) readPublicKey(label string) ([]byte, error) {
+	handle, err := v.findObject(pkcs11.CKO_PUBLIC_KEY, label)
+	if err != nil || handle == nil {
+		return nil, err
+	}
+	attrs, err := v.p.GetAttributeValue(v.session, *handle, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_EC_POINT, nil),
+	})
+	if err != nil {
+		return nil, err
+	}
+	point := attrs[0].Value
+	if len(point) != 67 || point[0] != 0x04 || point[1] != 0x41 {
+		return nil, fmt.Errorf("invalid ec point %x", point)
+	}
+	pub, err := btcec.ParsePubKey(point[2:])
+	if err != nil {
+		return nil, err
+	}
+	return pub.SerializeCompressed(), nil
+}
+
+func (v *pkcs11KeyVault) findObject(class uint, label string) (*pkcs11.ObjectHandle, error) {
+	template := []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, class),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, label),
+	}
+	err := v.p.FindObjectsInit(v.session, template)
+	if err != nil {
+		return nil, err
+	}
+	handles, _, err := v.p.FindObjects(v.session, 2)
+	if err != nil {
+		v.p.FindObjectsFinal(v.session)
+		return nil, err
+	}
+	err = v.p.FindObjectsFinal(v.session)
+	if err != nil {
+		return nil, err
+	}
+	switch len(handles) {
+	case 0:
+		return nil, nil
+	case 1:
+		return &handles[0], nil
+	}
+	return nil, fmt.Errorf("duplicated key vault label %s", label)
+}
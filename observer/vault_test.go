@@ -0,0 +1,139 @@
+package observer
+
+import (
+	"context"
+	"encoding/hex"
+	"math/big"
+	"os"
+	"testing"
+
+	"github.com/MixinNetwork/safe/apps/bitcoin"
+	"github.com/MixinNetwork/safe/common"
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/ecdsa"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testVaultAccountantPrivate = "2fe2d4b1c3c76b3b7b4ef4d65a7c4f8df8ff7c7aeea4b70a4b2fb2f5b0c0e51d"
+	testVaultEVMPrivate        = "b8a3f5f2f38c9f1a40c7f9ab8b4a3ef09e2fb5b46c7d9e0d2a71c5c1d8a3e4f6"
+	testVaultPassphrase        = "observer-key-vault-passphrase"
+)
+
+func TestFileKeyVault(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+
+	root, err := os.MkdirTemp("", "safe-vault-test")
+	require.Nil(err)
+	defer os.RemoveAll(root)
+	path := root + "/vault.json"
+
+	_, err = OpenFileKeyVault(path, "short")
+	require.NotNil(err)
+	vault, err := OpenFileKeyVault(path, testVaultPassphrase)
+	require.Nil(err)
+	testKeyVault(ctx, require, vault)
+
+	vault, err = OpenFileKeyVault(path, testVaultPassphrase)
+	require.Nil(err)
+	pub, err := vault.PublicKey(ctx, VaultEVMKeyLabel)
+	require.Nil(err)
+	priv, _ := btcec.PrivKeyFromBytes(common.DecodeHexOrPanic(testVaultEVMPrivate))
+	require.Equal(priv.PubKey().SerializeCompressed(), pub)
+	_, err = OpenFileKeyVault(path, testVaultPassphrase+"-invalid")
+	require.NotNil(err)
+
+	db, err := OpenSQLite3Store(root + "/safe.sqlite3")
+	require.Nil(err)
+	node := &Node{conf: &Configuration{}, store: db, vault: vault}
+	accountant, _ := btcec.PrivKeyFromBytes(common.DecodeHexOrPanic(testVaultAccountantPrivate))
+	keys := map[string]*btcec.PrivateKey{"bc1qaccountantwithvault": accountant}
+	err = db.WriteAccountantKeys(ctx, common.CurveSecp256k1ECDSABitcoin, keys, nil)
+	require.Nil(err)
+	key, err := db.ReadAccountantPrivateKey(ctx, "bc1qaccountantwithvault")
+	require.Nil(err)
+	require.Equal(testVaultAccountantPrivate, key)
+	count, err := db.MoveAccountantKeysToVault(ctx, vault)
+	require.Nil(err)
+	require.Equal(1, count)
+	count, err = db.MoveAccountantKeysToVault(ctx, vault)
+	require.Nil(err)
+	require.Equal(0, count)
+	key, err = db.ReadAccountantPrivateKey(ctx, "bc1qaccountantwithvault")
+	require.Nil(err)
+	require.Equal(vaultAccountantPrefix+"bc1qaccountantwithvault", key)
+
+	signer, err := node.accountantSigner(ctx, "bc1qaccountantwithvault")
+	require.Nil(err)
+	require.Equal(accountant.PubKey().SerializeCompressed(), signer.PublicKey())
+	hash := crypto.Keccak256([]byte("accountant"))
+	der, err := signer.Sign(hash)
+	require.Nil(err)
+	sig, err := ecdsa.ParseDERSignature(der)
+	require.Nil(err)
+	require.True(sig.Verify(hash, accountant.PubKey()))
+	node.vault = nil
+	_, err = node.accountantSigner(ctx, "bc1qaccountantwithvault")
+	require.NotNil(err)
+}
+
+func TestPKCS11KeyVault(t *testing.T) {
+	module, token := os.Getenv("SOFTHSM2_MODULE"), os.Getenv("SOFTHSM2_TOKEN")
+	if module == "" || token == "" {
+		t.Skip("SOFTHSM2_MODULE and SOFTHSM2_TOKEN not set")
+	}
+	require := require.New(t)
+	ctx := context.Background()
+
+	vault, err := OpenPKCS11KeyVault(module, token, os.Getenv("SOFTHSM2_PIN"))
+	require.Nil(err)
+	defer vault.Close()
+	testKeyVault(ctx, require, vault)
+}
+
+func testKeyVault(ctx context.Context, require *require.Assertions, vault KeyVault) {
+	priv := common.DecodeHexOrPanic(testVaultEVMPrivate)
+	err := vault.ImportKey(ctx, VaultEVMKeyLabel, priv)
+	require.Nil(err)
+	err = vault.ImportKey(ctx, VaultEVMKeyLabel, priv)
+	require.Nil(err)
+	err = vault.ImportKey(ctx, VaultEVMKeyLabel, common.DecodeHexOrPanic(testVaultAccountantPrivate))
+	require.NotNil(err)
+	_, err = vault.PublicKey(ctx, "invalid")
+	require.NotNil(err)
+
+	node := &Node{conf: &Configuration{}, vault: vault}
+	signer, err := node.evmSigner(ctx)
+	require.Nil(err)
+	key, err := crypto.HexToECDSA(testVaultEVMPrivate)
+	require.Nil(err)
+	require.Equal(crypto.PubkeyToAddress(key.PublicKey), signer.Address())
+
+	half := new(big.Int).Rsh(btcec.S256().N, 1)
+	for i := 0; i < 8; i++ {
+		digest := crypto.Keccak256([]byte{byte(i)})
+		sig, err := vault.Sign(ctx, VaultEVMKeyLabel, digest)
+		require.Nil(err)
+		require.Len(sig, 64)
+		require.True(new(big.Int).SetBytes(sig[32:]).Cmp(half) <= 0)
+		rsv, err := signer.SignDigest(ctx, digest)
+		require.Nil(err)
+		pub, err := crypto.SigToPub(digest, rsv)
+		require.Nil(err)
+		require.Equal(signer.Address(), crypto.PubkeyToAddress(*pub))
+		der, err := bitcoin.EncodeSignatureDER(sig)
+		require.Nil(err)
+		_, err = ecdsa.ParseDERSignature(der)
+		require.Nil(err)
+	}
+
+	sig, err := vault.Sign(ctx, VaultEVMKeyLabel, crypto.Keccak256([]byte("high")))
+	require.Nil(err)
+	s := new(big.Int).Sub(btcec.S256().N, new(big.Int).SetBytes(sig[32:]))
+	high := append(append([]byte{}, sig[:32]...), s.FillBytes(make([]byte, 32))...)
+	normalized, err := normalizeVaultSignature(high)
+	require.Nil(err)
+	require.Equal(hex.EncodeToString(sig), hex.EncodeToString(normalized))
+}
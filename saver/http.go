@@ -3,21 +3,31 @@ package saver
 import (
 	"context"
 	_ "embed"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/MixinNetwork/mixin/crypto"
 	"github.com/MixinNetwork/safe/common"
 	"github.com/dimfeld/httptreemux/v5"
 )
 
+const (
+	itemsListLimit    = 100
+	requestExpiration = 5 * time.Minute
+)
+
 func StartHTTP(store *SQLite3Store, port int) error {
 	router := httptreemux.New()
 	router.PanicHandler = common.HandlePanic
 	router.NotFoundHandler = common.HandleNotFound
 
 	router.POST("/", createItem)
+	router.GET("/nodes/:id/items", listItems)
+	router.GET("/items/:id", readItem)
 	handler := handleSession(router, store)
 	listen := fmt.Sprintf(":%d", port)
 	return http.ListenAndServe(listen, handler)
@@ -60,6 +70,99 @@ func createItem(w http.ResponseWriter, r *http.Request, params map[string]string
 	}
 }
 
+func listItems(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	store := r.Context().Value("store").(*SQLite3Store)
+	err := verifyRequest(r, store, params["id"])
+	if err != nil {
+		common.RenderJSON(w, r, http.StatusUnauthorized, map[string]any{"error": err.Error()})
+		return
+	}
+
+	var offset time.Time
+	if o := r.URL.Query().Get("offset"); o != "" {
+		offset, err = time.Parse(time.RFC3339Nano, o)
+		if err != nil {
+			common.RenderJSON(w, r, http.StatusBadRequest, map[string]any{"error": "offset"})
+			return
+		}
+	}
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit <= 0 || limit > itemsListLimit {
+		limit = itemsListLimit
+	}
+
+	items, err := store.ListItemsForNodeByOffset(r.Context(), params["id"], offset, limit)
+	if err != nil {
+		common.RenderError(w, r, err)
+		return
+	}
+	view := make([]map[string]any, len(items))
+	for i, item := range items {
+		view[i] = viewItem(item)
+	}
+	common.RenderJSON(w, r, http.StatusOK, view)
+}
+
+func readItem(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	store := r.Context().Value("store").(*SQLite3Store)
+	item, err := store.ReadItem(r.Context(), params["id"])
+	if err != nil {
+		common.RenderError(w, r, err)
+		return
+	}
+	if item == nil {
+		common.HandleNotFound(w, r)
+		return
+	}
+	err = verifyRequest(r, store, item.NodeId)
+	if err != nil {
+		common.RenderJSON(w, r, http.StatusUnauthorized, map[string]any{"error": err.Error()})
+		return
+	}
+	common.RenderJSON(w, r, http.StatusOK, viewItem(item))
+}
+
+// the node signs its node id, the request method, the request uri and
+// the unix timestamp, the timestamp should be close to the saver time
+func verifyRequest(r *http.Request, store *SQLite3Store, nodeId string) error {
+	pub, err := store.ReadNodePublicKey(r.Context(), nodeId)
+	if err != nil {
+		return fmt.Errorf("node")
+	}
+	ts, err := strconv.ParseInt(r.Header.Get("X-Request-Timestamp"), 10, 64)
+	if err != nil {
+		return fmt.Errorf("timestamp")
+	}
+	if d := time.Since(time.Unix(ts, 0)); d > requestExpiration || d < -requestExpiration {
+		return fmt.Errorf("timestamp")
+	}
+	var sig crypto.Signature
+	b, err := hex.DecodeString(r.Header.Get("X-Request-Signature"))
+	if err != nil || len(b) != len(sig) {
+		return fmt.Errorf("signature")
+	}
+	copy(sig[:], b)
+	hash := RequestHash(nodeId, r.Method, r.URL.RequestURI(), ts)
+	if !pub.Verify(hash, sig) {
+		return fmt.Errorf("signature")
+	}
+	return nil
+}
+
+func RequestHash(nodeId, method, uri string, timestamp int64) crypto.Hash {
+	msg := fmt.Sprintf("%s%s%s%d", nodeId, method, uri, timestamp)
+	return crypto.Sha256Hash([]byte(msg))
+}
+
+func viewItem(item *Item) map[string]any {
+	return map[string]any{
+		"id":         item.Id,
+		"node_id":    item.NodeId,
+		"data":       json.RawMessage(item.Data),
+		"created_at": item.CreatedAt,
+	}
+}
+
 func handleSession(handler http.Handler, store *SQLite3Store) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		ctx := context.WithValue(r.Context(), "store", store)
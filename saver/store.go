@@ -16,8 +16,10 @@ import (
 var SCHEMA string
 
 type Item struct {
-	Id   string
-	Data string
+	Id        string
+	NodeId    string
+	Data      string
+	CreatedAt time.Time
 }
 
 type SQLite3Store struct {
@@ -114,8 +116,30 @@ func (s *SQLite3Store) WriteItemIfNotExist(ctx context.Context, id, nodeId, data
 	return tx.Commit()
 }
 
+func (s *SQLite3Store) ReadItem(ctx context.Context, id string) (*Item, error) {
+	query := "SELECT id,node_id,data,created_at FROM items WHERE id=?"
+	row := s.db.QueryRowContext(ctx, query, id)
+
+	var item Item
+	err := row.Scan(&item.Id, &item.NodeId, &item.Data, &item.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return &item, err
+}
+
 func (s *SQLite3Store) ListItemsForNode(ctx context.Context, nodeId string) ([]*Item, error) {
-	rows, err := s.db.QueryContext(ctx, "SELECT id,data FROM items WHERE node_id=? ORDER BY node_id,created_at ASC", nodeId)
+	query := "SELECT id,node_id,data,created_at FROM items WHERE node_id=? ORDER BY node_id,created_at ASC"
+	return s.listItemsByQuery(ctx, query, nodeId)
+}
+
+func (s *SQLite3Store) ListItemsForNodeByOffset(ctx context.Context, nodeId string, offset time.Time, limit int) ([]*Item, error) {
+	query := fmt.Sprintf("SELECT id,node_id,data,created_at FROM items WHERE node_id=? AND created_at>? ORDER BY node_id,created_at ASC LIMIT %d", limit)
+	return s.listItemsByQuery(ctx, query, nodeId, offset)
+}
+
+func (s *SQLite3Store) listItemsByQuery(ctx context.Context, query string, params ...any) ([]*Item, error) {
+	rows, err := s.db.QueryContext(ctx, query, params...)
 	if err != nil {
 		return nil, err
 	}
@@ -124,7 +148,7 @@ func (s *SQLite3Store) ListItemsForNode(ctx context.Context, nodeId string) ([]*
 	var items []*Item
 	for rows.Next() {
 		var item Item
-		err := rows.Scan(&item.Id, &item.Data)
+		err := rows.Scan(&item.Id, &item.NodeId, &item.Data, &item.CreatedAt)
 		if err != nil {
 			return nil, err
 		}
@@ -1,19 +1,27 @@
 package signer
 
 import (
+	"bytes"
 	"context"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"net/http"
+	"net/url"
 	"strings"
+	"time"
 
 	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/logger"
 	"github.com/MixinNetwork/safe/common"
+	"github.com/MixinNetwork/safe/saver"
 	"github.com/gofrs/uuid/v5"
 )
 
+const backupListLimit = 100
+
 func (node *Node) sendKeygenBackup(ctx context.Context, op *common.Operation, share []byte) (bool, error) {
-	if node.conf.SaverAPI == "" {
+	if len(node.saverAPIs) == 0 {
 		return false, nil
 	}
 
@@ -24,8 +32,28 @@ func (node *Node) sendKeygenBackup(ctx context.Context, op *common.Operation, sh
 	share = append(sid.Bytes(), share...)
 	share = common.AESEncrypt(secret[:], share, sid.String())
 	public := common.AESEncrypt(secret[:], op.Encode(), op.Id)
+	pieces, err := splitKeygenBackup(share, len(node.saverAPIs), node.saverThreshold)
+	if err != nil {
+		return false, err
+	}
+
+	var acks int
+	for i, api := range node.saverAPIs {
+		err = node.postKeygenBackup(api, sid.String(), op, public, pieces[i])
+		logger.Printf("node.postKeygenBackup(%s, %v) => %v", api, op, err)
+		if err == nil {
+			acks += 1
+		}
+	}
+	if acks < node.saverThreshold {
+		return false, fmt.Errorf("node.sendKeygenBackup(%v) => %d/%d %v", op, acks, node.saverThreshold, err)
+	}
+	return true, nil
+}
+
+func (node *Node) postKeygenBackup(api, sid string, op *common.Operation, public, share []byte) error {
 	data := map[string]string{
-		"id":         sid.String(),
+		"id":         sid,
 		"node_id":    string(node.id),
 		"session_id": op.Id,
 		"public":     base64.RawURLEncoding.EncodeToString(public),
@@ -39,9 +67,9 @@ func (node *Node) sendKeygenBackup(ctx context.Context, op *common.Operation, sh
 
 	msg = string(common.MarshalJSONOrPanic(data))
 	reader := strings.NewReader(msg)
-	resp, err := node.backupClient.Post(node.conf.SaverAPI, "application/json", reader)
+	resp, err := node.backupClient.Post(api, "application/json", reader)
 	if err != nil || resp.StatusCode != 200 {
-		return false, fmt.Errorf("backupClient.Post(%s, %v) => %v %v", node.conf.SaverAPI, op, resp, err)
+		return fmt.Errorf("backupClient.Post(%s, %v) => %v %v", api, op, resp, err)
 	}
 	defer resp.Body.Close()
 
@@ -50,8 +78,289 @@ func (node *Node) sendKeygenBackup(ctx context.Context, op *common.Operation, sh
 		Size int    `json:"size"`
 	}
 	err = json.NewDecoder(resp.Body).Decode(&body)
-	if err != nil || body.Id != sid.String() || body.Size != len(msg) {
-		return false, fmt.Errorf("backupClient.Post(%s, %v) => %v %v", node.conf.SaverAPI, op, body, err)
+	if err != nil || body.Id != sid || body.Size != len(msg) {
+		return fmt.Errorf("backupClient.Post(%s, %v) => %v %v", api, op, body, err)
 	}
-	return true, nil
+	return nil
+}
+
+// a single saver keeps the whole encrypted share, whose first bytes are the
+// AES nonce from the backup id, otherwise each saver keeps a shamir piece
+// prefixed with the threshold, and any threshold pieces restore the share
+func splitKeygenBackup(cipher []byte, savers, threshold int) ([][]byte, error) {
+	if savers == 1 {
+		return [][]byte{cipher}, nil
+	}
+	parts, err := common.ShamirSplit(cipher, savers, threshold)
+	if err != nil {
+		return nil, err
+	}
+	for i, p := range parts {
+		parts[i] = append([]byte{byte(threshold)}, p...)
+	}
+	return parts, nil
+}
+
+func combineKeygenBackup(sid uuid.UUID, pieces [][]byte) ([]byte, error) {
+	var threshold int
+	parts := make(map[byte][]byte)
+	for _, p := range pieces {
+		if bytes.HasPrefix(p, sid.Bytes()[:12]) {
+			return p, nil
+		}
+		if len(p) < 3 || p[0] == 0 || (threshold > 0 && int(p[0]) != threshold) {
+			return nil, fmt.Errorf("invalid backup piece %s %x", sid, p[:min(len(p), 2)])
+		}
+		threshold = int(p[0])
+		parts[p[1]] = p[1:]
+	}
+	if threshold == 0 || len(parts) < threshold {
+		return nil, fmt.Errorf("insufficient backup pieces %s %d/%d", sid, len(parts), threshold)
+	}
+	var combine [][]byte
+	for _, p := range parts {
+		combine = append(combine, p)
+	}
+	return common.ShamirCombine(combine[:threshold])
+}
+
+type KeygenBackup struct {
+	Id        string
+	Operation *common.Operation
+	Share     []byte
+	CreatedAt time.Time
+}
+
+type saverItem struct {
+	Id     string `json:"id"`
+	NodeId string `json:"node_id"`
+	Data   struct {
+		Id        string `json:"id"`
+		NodeId    string `json:"node_id"`
+		SessionId string `json:"session_id"`
+		Public    string `json:"public"`
+		Share     string `json:"share"`
+	} `json:"data"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func FetchKeygenBackups(ctx context.Context, client *http.Client, apis []string, nodeId string, key *crypto.Key) ([]*KeygenBackup, error) {
+	var ids []string
+	var fetched int
+	groups := make(map[string][]*saverItem)
+	for _, api := range apis {
+		items, err := listSaverItems(ctx, client, api, nodeId, key)
+		logger.Printf("listSaverItems(%s, %s) => %d %v", api, nodeId, len(items), err)
+		if err != nil {
+			continue
+		}
+		fetched += 1
+		for _, item := range items {
+			if groups[item.Id] == nil {
+				ids = append(ids, item.Id)
+			}
+			groups[item.Id] = append(groups[item.Id], item)
+		}
+	}
+	if fetched == 0 && len(apis) > 0 {
+		return nil, fmt.Errorf("FetchKeygenBackups(%s) => no saver available", nodeId)
+	}
+
+	var backups []*KeygenBackup
+	for _, id := range ids {
+		b, err := decryptKeygenBackup(groups[id], nodeId, key)
+		if err != nil {
+			logger.Printf("decryptKeygenBackup(%s) => %v", id, err)
+			continue
+		}
+		backups = append(backups, b)
+	}
+	return backups, nil
+}
+
+func ReadKeygenBackup(ctx context.Context, client *http.Client, apis []string, nodeId, id string, key *crypto.Key) (*KeygenBackup, error) {
+	var items []*saverItem
+	for _, api := range apis {
+		var item saverItem
+		err := requestSaver(ctx, client, api, "/items/"+id, nodeId, key, &item)
+		logger.Printf("requestSaver(%s, %s) => %v", api, id, err)
+		if err != nil {
+			continue
+		}
+		items = append(items, &item)
+	}
+	if len(items) == 0 {
+		return nil, fmt.Errorf("ReadKeygenBackup(%s) => not found", id)
+	}
+	return decryptKeygenBackup(items, nodeId, key)
+}
+
+func listSaverItems(ctx context.Context, client *http.Client, api, nodeId string, key *crypto.Key) ([]*saverItem, error) {
+	var offset time.Time
+	var all []*saverItem
+	for {
+		query := url.Values{}
+		query.Set("offset", offset.Format(time.RFC3339Nano))
+		query.Set("limit", fmt.Sprint(backupListLimit))
+		path := fmt.Sprintf("/nodes/%s/items?%s", nodeId, query.Encode())
+		var items []*saverItem
+		err := requestSaver(ctx, client, api, path, nodeId, key, &items)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range items {
+			all = append(all, item)
+			offset = item.CreatedAt
+		}
+		if len(items) < backupListLimit {
+			return all, nil
+		}
+	}
+}
+
+func requestSaver(ctx context.Context, client *http.Client, api, path, nodeId string, key *crypto.Key, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(api, "/")+path, nil)
+	if err != nil {
+		return err
+	}
+	ts := time.Now().Unix()
+	hash := saver.RequestHash(nodeId, req.Method, req.URL.RequestURI(), ts)
+	req.Header.Set("X-Request-Timestamp", fmt.Sprint(ts))
+	req.Header.Set("X-Request-Signature", key.Sign(hash).String())
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("backupClient.Get(%s) => %v", path, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("backupClient.Get(%s) => %d", path, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func decryptKeygenBackup(items []*saverItem, nodeId string, key *crypto.Key) (*KeygenBackup, error) {
+	item := items[0]
+	sid, err := uuid.FromString(item.Id)
+	if err != nil {
+		return nil, fmt.Errorf("invalid backup id %s", item.Id)
+	}
+	createdAt := item.CreatedAt
+	pieces := make([][]byte, len(items))
+	for i, it := range items {
+		if it.Id != item.Id || it.Data.Id != item.Id {
+			return nil, fmt.Errorf("invalid backup id %s %s", it.Id, it.Data.Id)
+		}
+		if it.NodeId != nodeId || it.Data.NodeId != nodeId {
+			return nil, fmt.Errorf("invalid backup node %s %s", it.Id, it.NodeId)
+		}
+		if it.Data.SessionId != item.Data.SessionId || it.Data.Public != item.Data.Public {
+			return nil, fmt.Errorf("inconsistent backup %s", it.Id)
+		}
+		pieces[i], err = base64.RawURLEncoding.DecodeString(it.Data.Share)
+		if err != nil {
+			return nil, err
+		}
+		if it.CreatedAt.Before(createdAt) {
+			createdAt = it.CreatedAt
+		}
+	}
+	secret := crypto.Sha256Hash([]byte(key.String() + sid.String()))
+	secret = crypto.Sha256Hash(secret[:])
+
+	public, err := base64.RawURLEncoding.DecodeString(item.Data.Public)
+	if err != nil {
+		return nil, err
+	}
+	public, err = common.AESDecryptWithError(secret[:], public)
+	if err != nil {
+		return nil, fmt.Errorf("common.AESDecrypt(%s) => %v", item.Id, err)
+	}
+	op, err := common.DecodeOperation(public)
+	if err != nil || op.Id != item.Data.SessionId {
+		return nil, fmt.Errorf("common.DecodeOperation(%s) => %v %v", item.Id, op, err)
+	}
+
+	share, err := combineKeygenBackup(sid, pieces)
+	if err != nil {
+		return nil, err
+	}
+	share, err = common.AESDecryptWithError(secret[:], share)
+	if err != nil {
+		return nil, fmt.Errorf("common.AESDecrypt(%s) => %v", item.Id, err)
+	}
+	if len(share) <= 16 || !bytes.Equal(share[:16], sid.Bytes()) {
+		return nil, fmt.Errorf("invalid backup share %s", item.Id)
+	}
+	return &KeygenBackup{
+		Id:        item.Id,
+		Operation: op,
+		Share:     share[16:],
+		CreatedAt: createdAt,
+	}, nil
+}
+
+// a key may have multiple backups after refresh or reshare, the latest
+// one is the share in use, and the keygen one has the key session id
+func latestKeygenBackups(backups []*KeygenBackup) (map[string]*KeygenBackup, map[string]string) {
+	latest, sessions := make(map[string]*KeygenBackup), make(map[string]string)
+	for _, b := range backups {
+		public := b.Operation.Public
+		if old := latest[public]; old == nil || !b.CreatedAt.Before(old.CreatedAt) {
+			latest[public] = b
+		}
+		if b.Operation.Type == common.OperationTypeKeygenInput {
+			sessions[public] = b.Operation.Id
+		}
+	}
+	return latest, sessions
+}
+
+func RestoreKeygenBackups(ctx context.Context, store *SQLite3Store, backups []*KeygenBackup, force bool) (int, error) {
+	latest, sessions := latestKeygenBackups(backups)
+	for public, b := range latest {
+		sessionId := sessions[public]
+		if sessionId == "" {
+			sessionId = b.Operation.Id
+		}
+		err := store.RestoreKey(ctx, sessionId, b.Operation.Curve, public, b.Share, force)
+		if err != nil {
+			return 0, fmt.Errorf("store.RestoreKey(%s) => %v", public, err)
+		}
+	}
+	return len(latest), nil
+}
+
+// a backed up key may still be unrecoverable, e.g. a reshare backup of a
+// failed session is newer than the share in use, or the savers lost data,
+// then the key is marked unbacked and the backup loop sends it again
+func (node *Node) verifyKeygenBackups(ctx context.Context) (int, error) {
+	backups, err := FetchKeygenBackups(ctx, node.backupClient, node.saverAPIs, string(node.id), node.saverKey)
+	if err != nil {
+		return 0, err
+	}
+	latest, _ := latestKeygenBackups(backups)
+
+	keys, err := node.store.ListBackupedKeys(ctx)
+	if err != nil {
+		return 0, err
+	}
+	var invalid int
+	for _, key := range keys {
+		share, err := node.store.DecodeKeyShare(ctx, key)
+		if err != nil {
+			panic(err)
+		}
+		b := latest[key.Public]
+		if b != nil && b.Operation.Curve == key.Curve && bytes.Equal(b.Share, share) {
+			continue
+		}
+		logger.Printf("node.verifyKeygenBackups(%s) => invalid backup %v", key.Public, b)
+		err = node.store.ResetKeyBackup(ctx, key.Public, share)
+		if err != nil {
+			return 0, err
+		}
+		invalid += 1
+	}
+	return invalid, nil
 }
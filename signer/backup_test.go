@@ -0,0 +1,210 @@
+package signer
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/safe/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackup(t *testing.T) {
+	require := require.New(t)
+	ctx, nodes, _ := TestPrepare(require)
+
+	edwards := testFROSTKeyGen(ctx, require, nodes, common.CurveEdwards25519Default)
+	taproot := testFROSTKeyGen(ctx, require, nodes, common.CurveSecp256k1SchnorrBitcoin)
+	publics := []string{edwards, taproot}
+
+	for i, node := range nodes {
+		backups, err := FetchKeygenBackups(ctx, node.backupClient, node.saverAPIs, string(node.id), node.saverKey)
+		require.Nil(err)
+		require.Len(backups, 2)
+		for _, b := range backups {
+			_, _, share, err := node.store.ReadKeyByFingerprint(ctx, hex.EncodeToString(common.Fingerprint(b.Operation.Public)))
+			require.Nil(err)
+			require.True(bytes.Equal(share, b.Share))
+			rb, err := ReadKeygenBackup(ctx, node.backupClient, node.saverAPIs, string(node.id), b.Id, node.saverKey)
+			require.Nil(err)
+			require.Equal(b.Operation, rb.Operation)
+			require.True(bytes.Equal(b.Share, rb.Share))
+		}
+		other := nodes[(i+1)%len(nodes)]
+		_, err = ReadKeygenBackup(ctx, node.backupClient, node.saverAPIs, string(node.id), backups[0].Id, other.saverKey)
+		require.NotNil(err)
+
+		invalid, err := node.verifyKeygenBackups(ctx)
+		require.Nil(err)
+		require.Equal(0, invalid)
+	}
+
+	node := nodes[0]
+	keys, err := node.store.ListBackupedKeys(ctx)
+	require.Nil(err)
+	require.Len(keys, 2)
+	op := keys[0].asOperation()
+	op.Type = common.OperationTypeRefreshInput
+	saved, err := node.sendKeygenBackup(ctx, op, []byte("invalid-share"))
+	require.Nil(err)
+	require.True(saved)
+	invalid, err := node.verifyKeygenBackups(ctx)
+	require.Nil(err)
+	require.Equal(1, invalid)
+	keys, err = node.store.ListUnbackupedKeys(ctx, 10)
+	require.Nil(err)
+	require.Len(keys, 1)
+	share, err := common.Base91Decode(keys[0].Share)
+	require.Nil(err)
+	saved, err = node.sendKeygenBackup(ctx, keys[0].asOperation(), share)
+	require.Nil(err)
+	require.True(saved)
+	err = node.store.MarkKeyBackuped(ctx, keys[0].Public)
+	require.Nil(err)
+	invalid, err = node.verifyKeygenBackups(ctx)
+	require.Nil(err)
+	require.Equal(0, invalid)
+
+	dir, err := os.MkdirTemp("", "safe-restore-test-")
+	require.Nil(err)
+	store, err := OpenSQLite3Store(dir + "/mpc.sqlite3")
+	require.Nil(err)
+	defer store.Close()
+	backups, err := FetchKeygenBackups(ctx, node.backupClient, node.saverAPIs, string(node.id), node.saverKey)
+	require.Nil(err)
+	require.Len(backups, 4)
+	count, err := RestoreKeygenBackups(ctx, store, backups, false)
+	require.Nil(err)
+	require.Equal(2, count)
+	testBackupRestoreCheck(ctx, require, node, store, publics)
+	count, err = RestoreKeygenBackups(ctx, store, backups, false)
+	require.Nil(err)
+	require.Equal(2, count)
+	testBackupRestoreCheck(ctx, require, node, store, publics)
+}
+
+func TestRestoreStaleBackup(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+
+	dir, err := os.MkdirTemp("", "safe-restore-stale-test-")
+	require.Nil(err)
+	defer os.RemoveAll(dir)
+	store, err := OpenSQLite3Store(dir + "/mpc.sqlite3")
+	require.Nil(err)
+	defer store.Close()
+
+	seed := crypto.Sha256Hash([]byte("restore-stale-backup"))
+	public := hex.EncodeToString(seed[:])
+	fingerprint := hex.EncodeToString(common.Fingerprint(public))
+	sessionId := "0e3a4f6c-7b8d-4e2f-9a1b-3c5d7e9f1a2b"
+	err = store.RestoreKey(ctx, sessionId, common.CurveEdwards25519Default, public, []byte("keygen-share"), false)
+	require.Nil(err)
+	_, err = store.db.ExecContext(ctx, "UPDATE keys SET share=? WHERE public=?", common.Base91Encode([]byte("refreshed-share")), public)
+	require.Nil(err)
+
+	// the keygen backup is older than the refreshed share in use
+	err = store.RestoreKey(ctx, sessionId, common.CurveEdwards25519Default, public, []byte("keygen-share"), false)
+	require.NotNil(err)
+	_, _, share, err := store.ReadKeyByFingerprint(ctx, fingerprint)
+	require.Nil(err)
+	require.Equal([]byte("refreshed-share"), share)
+
+	err = store.RestoreKey(ctx, sessionId, common.CurveEdwards25519Default, public, []byte("refreshed-share"), false)
+	require.Nil(err)
+	_, _, share, err = store.ReadKeyByFingerprint(ctx, fingerprint)
+	require.Nil(err)
+	require.Equal([]byte("refreshed-share"), share)
+
+	err = store.RestoreKey(ctx, sessionId, common.CurveEdwards25519Default, public, []byte("keygen-share"), true)
+	require.Nil(err)
+	_, _, share, err = store.ReadKeyByFingerprint(ctx, fingerprint)
+	require.Nil(err)
+	require.Equal([]byte("keygen-share"), share)
+}
+
+func testBackupRestoreCheck(ctx context.Context, require *require.Assertions, node *Node, store *SQLite3Store, publics []string) {
+	for _, public := range publics {
+		fingerprint := hex.EncodeToString(common.Fingerprint(public))
+		holder, crv, share, err := node.store.ReadKeyByFingerprint(ctx, fingerprint)
+		require.Nil(err)
+		restored, rc, rs, err := store.ReadKeyByFingerprint(ctx, fingerprint)
+		require.Nil(err)
+		require.Equal(holder, restored)
+		require.Equal(crv, rc)
+		require.True(bytes.Equal(share, rs))
+	}
+	keys, err := store.ListUnbackupedKeys(ctx, 10)
+	require.Nil(err)
+	require.Len(keys, 0)
+}
+
+func TestBackupSavers(t *testing.T) {
+	require := require.New(t)
+	ctx, nodes, _ := TestPrepare(require)
+
+	node := nodes[0]
+	apis := []string{node.saverAPIs[0]}
+	for range 2 {
+		store, port := testStartSaver(require)
+		err := store.WriteNodePublicKey(ctx, string(node.id), node.saverKey.Public().String())
+		require.Nil(err)
+		apis = append(apis, fmt.Sprintf("http://localhost:%d", port))
+	}
+	apis = append(apis, fmt.Sprintf("http://localhost:%d", getFreePort()))
+	node.saverAPIs, node.saverThreshold = apis, 3
+	time.Sleep(time.Second)
+
+	edwards := testFROSTKeyGen(ctx, require, nodes, common.CurveEdwards25519Default)
+	_, crv, share, err := node.store.ReadKeyByFingerprint(ctx, hex.EncodeToString(common.Fingerprint(edwards)))
+	require.Nil(err)
+	require.Equal(uint8(common.CurveEdwards25519Default), crv)
+
+	for _, i := range [][]int{{0, 1, 2}, {0, 1, 2, 3}, {2, 1, 0}} {
+		var savers []string
+		for _, j := range i {
+			savers = append(savers, apis[j])
+		}
+		backups, err := FetchKeygenBackups(ctx, node.backupClient, savers, string(node.id), node.saverKey)
+		require.Nil(err)
+		require.Len(backups, 1)
+		require.Equal(edwards, backups[0].Operation.Public)
+		require.True(bytes.Equal(share, backups[0].Share))
+		b, err := ReadKeygenBackup(ctx, node.backupClient, savers, string(node.id), backups[0].Id, node.saverKey)
+		require.Nil(err)
+		require.True(bytes.Equal(share, b.Share))
+	}
+	for _, i := range [][]int{{0, 1}, {1, 2, 3}, {3}} {
+		var savers []string
+		for _, j := range i {
+			savers = append(savers, apis[j])
+		}
+		backups, err := FetchKeygenBackups(ctx, node.backupClient, savers, string(node.id), node.saverKey)
+		if i[0] == 3 {
+			require.NotNil(err)
+		} else {
+			require.Nil(err)
+		}
+		require.Len(backups, 0)
+	}
+	invalid, err := node.verifyKeygenBackups(ctx)
+	require.Nil(err)
+	require.Equal(0, invalid)
+
+	node.saverThreshold = 4
+	op := &common.Operation{Id: common.UniqueId(edwards, "backup"), Type: common.OperationTypeRefreshInput, Curve: crv, Public: edwards}
+	saved, err := node.sendKeygenBackup(ctx, op, share)
+	require.NotNil(err)
+	require.False(saved)
+	backups, err := FetchKeygenBackups(ctx, node.backupClient, apis, string(node.id), node.saverKey)
+	require.Nil(err)
+	require.Len(backups, 1)
+	invalid, err = node.verifyKeygenBackups(ctx)
+	require.Nil(err)
+	require.Equal(0, invalid)
+}
@@ -19,9 +19,9 @@ const (
 	cmpSignRoundTimeout   = 5 * time.Minute
 )
 
-func (node *Node) cmpKeygen(ctx context.Context, sessionId []byte, crv byte) (*KeygenResult, error) {
+func (node *Node) cmpKeygen(ctx context.Context, set *PartySet, sessionId []byte, crv byte) (*KeygenResult, error) {
 	logger.Printf("node.cmpKeygen(%x)", sessionId)
-	start, err := cmp.Keygen(curve.Secp256k1{}, node.id, node.members, node.threshold, nil)(sessionId)
+	start, err := cmp.Keygen(curve.Secp256k1{}, node.id, set.Members, set.Threshold, nil)(sessionId)
 	if err != nil {
 		return nil, fmt.Errorf("cmp.Keygen(%x) => %v", sessionId, err)
 	}
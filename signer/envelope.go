@@ -0,0 +1,214 @@
+package signer
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/MixinNetwork/safe/common"
+	"golang.org/x/crypto/argon2"
+)
+
+// the hyphen is not in the base91 alphabet, so an envelope never collides
+// with the legacy plain shares and both could be read during migration
+const shareEnvelopePrefix = "ENVELOPE-1-"
+
+const passphraseSaltSize = 16
+
+// ShareKMS wraps the random data key of every share envelope with the key
+// encryption key, which never touches the signer database
+type ShareKMS interface {
+	WrapKey(ctx context.Context, dek, aad []byte) ([]byte, error)
+	UnwrapKey(ctx context.Context, wrapped, aad []byte) ([]byte, error)
+}
+
+type LocalKMS struct {
+	kek []byte
+}
+
+func NewLocalKMS(kek []byte) (*LocalKMS, error) {
+	if len(kek) != 32 {
+		return nil, fmt.Errorf("invalid key encryption key size %d", len(kek))
+	}
+	return &LocalKMS{kek: kek}, nil
+}
+
+// PassphraseKMS derives the key encryption key from the passphrase with a
+// random salt, which is stored in front of every wrapped key, so the keys
+// wrapped with the other salts are still unwrapped with the same passphrase
+type PassphraseKMS struct {
+	sync.Mutex
+	passphrase []byte
+	salt       []byte
+	keks       map[string][]byte
+}
+
+func NewPassphraseKMS(passphrase string) (*PassphraseKMS, error) {
+	if len(passphrase) < 16 {
+		return nil, fmt.Errorf("passphrase too short %d", len(passphrase))
+	}
+	salt := make([]byte, passphraseSaltSize)
+	_, err := rand.Read(salt)
+	if err != nil {
+		return nil, err
+	}
+	k := &PassphraseKMS{
+		passphrase: []byte(passphrase),
+		salt:       salt,
+		keks:       make(map[string][]byte),
+	}
+	k.kek(salt)
+	return k, nil
+}
+
+func (k *PassphraseKMS) WrapKey(ctx context.Context, dek, aad []byte) ([]byte, error) {
+	sealed, err := aesGCMSeal(k.kek(k.salt), dek, aad)
+	if err != nil {
+		return nil, err
+	}
+	return append(append([]byte{}, k.salt...), sealed...), nil
+}
+
+func (k *PassphraseKMS) UnwrapKey(ctx context.Context, wrapped, aad []byte) ([]byte, error) {
+	if len(wrapped) <= passphraseSaltSize {
+		return nil, fmt.Errorf("invalid wrapped key size %d", len(wrapped))
+	}
+	salt, sealed := wrapped[:passphraseSaltSize], wrapped[passphraseSaltSize:]
+	return aesGCMOpen(k.kek(salt), sealed, aad)
+}
+
+func (k *PassphraseKMS) kek(salt []byte) []byte {
+	k.Lock()
+	defer k.Unlock()
+
+	key := hex.EncodeToString(salt)
+	if kek := k.keks[key]; kek != nil {
+		return kek
+	}
+	kek := argon2.IDKey(k.passphrase, salt, 3, 64*1024, 4, 32)
+	k.keks[key] = kek
+	return kek
+}
+
+// the key file contains the hex of a 32 bytes key encryption key
+func NewFileKMS(path string) (*LocalKMS, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	kek, err := hex.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid key file %s %v", path, err)
+	}
+	return NewLocalKMS(kek)
+}
+
+func (k *LocalKMS) WrapKey(ctx context.Context, dek, aad []byte) ([]byte, error) {
+	return aesGCMSeal(k.kek, dek, aad)
+}
+
+func (k *LocalKMS) UnwrapKey(ctx context.Context, wrapped, aad []byte) ([]byte, error) {
+	return aesGCMOpen(k.kek, wrapped, aad)
+}
+
+func (c *Configuration) ShareKMS() (ShareKMS, error) {
+	switch {
+	case c.SharePassphrase != "" && c.ShareKeyFile != "":
+		return nil, fmt.Errorf("both share passphrase and key file configured")
+	case c.SharePassphrase != "":
+		return NewPassphraseKMS(c.SharePassphrase)
+	case c.ShareKeyFile != "":
+		return NewFileKMS(c.ShareKeyFile)
+	}
+	return nil, nil
+}
+
+// the envelope is bound to the public key, so a share can't be moved to
+// another key row without failing the decryption
+func sealShareEnvelope(ctx context.Context, kms ShareKMS, public string, conf []byte) (string, error) {
+	aad := []byte(public)
+	dek := make([]byte, 32)
+	_, err := rand.Read(dek)
+	if err != nil {
+		return "", err
+	}
+	wrapped, err := kms.WrapKey(ctx, dek, aad)
+	if err != nil {
+		return "", err
+	}
+	sealed, err := aesGCMSeal(dek, conf, aad)
+	if err != nil {
+		return "", err
+	}
+	payload := binary.BigEndian.AppendUint16(nil, uint16(len(wrapped)))
+	payload = append(payload, wrapped...)
+	payload = append(payload, sealed...)
+	return shareEnvelopePrefix + common.Base91Encode(payload), nil
+}
+
+func openShareEnvelope(ctx context.Context, kms ShareKMS, public, share string) ([]byte, error) {
+	if kms == nil {
+		return nil, fmt.Errorf("share of %s encrypted without kms", public)
+	}
+	payload, err := common.Base91Decode(strings.TrimPrefix(share, shareEnvelopePrefix))
+	if err != nil {
+		return nil, err
+	}
+	if len(payload) < 2 {
+		return nil, fmt.Errorf("invalid share envelope %s", public)
+	}
+	size := int(binary.BigEndian.Uint16(payload[:2]))
+	if len(payload) < 2+size {
+		return nil, fmt.Errorf("invalid share envelope %s", public)
+	}
+	aad := []byte(public)
+	dek, err := kms.UnwrapKey(ctx, payload[2:2+size], aad)
+	if err != nil {
+		return nil, fmt.Errorf("kms.UnwrapKey(%s) => %v", public, err)
+	}
+	return aesGCMOpen(dek, payload[2+size:], aad)
+}
+
+func isShareEnvelope(share string) bool {
+	return strings.HasPrefix(share, shareEnvelopePrefix)
+}
+
+func aesGCMSeal(key, plain, aad []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plain, aad), nil
+}
+
+func aesGCMOpen(key, sealed, aad []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, fmt.Errorf("invalid sealed data size %d", len(sealed))
+	}
+	nonce, cipher := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, cipher, aad)
+}
@@ -0,0 +1,114 @@
+package signer
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"os"
+	"testing"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/safe/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShareEnvelope(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+
+	dir, err := os.MkdirTemp("", "safe-envelope-test-")
+	require.Nil(err)
+	defer os.RemoveAll(dir)
+	store, err := OpenSQLite3Store(dir + "/mpc.sqlite3")
+	require.Nil(err)
+	defer store.Close()
+
+	seed := crypto.Sha256Hash([]byte("share-envelope"))
+	public, conf := hex.EncodeToString(seed[:]), []byte("plain-key-share")
+	fingerprint := hex.EncodeToString(common.Fingerprint(public))
+	err = store.RestoreKey(ctx, "b5e2a4a4-4a8a-4dfb-9a0b-5a6bd5d1e6f0", common.CurveEdwards25519Default, public, conf, false)
+	require.Nil(err)
+	keys, err := store.ListBackupedKeys(ctx)
+	require.Nil(err)
+	require.Len(keys, 1)
+	require.Equal(common.Base91Encode(conf), keys[0].Share)
+
+	keyFile := dir + "/kek"
+	err = os.WriteFile(keyFile, []byte(hex.EncodeToString(seed[:])+"\n"), 0600)
+	require.Nil(err)
+	kms, err := (&Configuration{ShareKeyFile: keyFile}).ShareKMS()
+	require.Nil(err)
+	_, err = store.EncryptKeyShares(ctx)
+	require.NotNil(err)
+	store.EnableShareEncryption(kms)
+	count, err := store.EncryptKeyShares(ctx)
+	require.Nil(err)
+	require.Equal(1, count)
+	count, err = store.EncryptKeyShares(ctx)
+	require.Nil(err)
+	require.Equal(0, count)
+
+	keys, err = store.ListBackupedKeys(ctx)
+	require.Nil(err)
+	require.Len(keys, 1)
+	require.True(isShareEnvelope(keys[0].Share))
+	require.False(bytes.Contains([]byte(keys[0].Share), []byte(common.Base91Encode(conf))))
+	share, err := store.DecodeKeyShare(ctx, keys[0])
+	require.Nil(err)
+	require.Equal(conf, share)
+	pub, _, share, err := store.ReadKeyByFingerprint(ctx, fingerprint)
+	require.Nil(err)
+	require.Equal(public, pub)
+	require.Equal(conf, share)
+
+	err = store.WriteRefreshShare(ctx, "b5e2a4a4-4a8a-4dfb-9a0b-5a6bd5d1e6f0", public, []byte("invalid"), []byte("next-key-share"), true)
+	require.NotNil(err)
+	_, _, share, err = store.ReadKeyByFingerprint(ctx, fingerprint)
+	require.Nil(err)
+	require.Equal(conf, share)
+
+	moved := *keys[0]
+	moved.Public = hex.EncodeToString(seed[1:])
+	_, err = store.DecodeKeyShare(ctx, &moved)
+	require.NotNil(err)
+	other, err := NewPassphraseKMS("another-share-passphrase")
+	require.Nil(err)
+	store.EnableShareEncryption(other)
+	_, _, _, err = store.ReadKeyByFingerprint(ctx, fingerprint)
+	require.NotNil(err)
+	store.EnableShareEncryption(nil)
+	_, _, _, err = store.ReadKeyByFingerprint(ctx, fingerprint)
+	require.NotNil(err)
+}
+
+func TestPassphraseKMS(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+
+	dek, aad := bytes.Repeat([]byte{7}, 32), []byte("public")
+	a, err := NewPassphraseKMS("share-passphrase-for-test")
+	require.Nil(err)
+	b, err := NewPassphraseKMS("share-passphrase-for-test")
+	require.Nil(err)
+	require.NotEqual(a.salt, b.salt)
+
+	wa, err := a.WrapKey(ctx, dek, aad)
+	require.Nil(err)
+	wb, err := b.WrapKey(ctx, dek, aad)
+	require.Nil(err)
+	require.Equal(a.salt, wa[:passphraseSaltSize])
+	require.NotEqual(wa, wb)
+	key, err := b.UnwrapKey(ctx, wa, aad)
+	require.Nil(err)
+	require.Equal(dek, key)
+	key, err = a.UnwrapKey(ctx, wb, aad)
+	require.Nil(err)
+	require.Equal(dek, key)
+
+	_, err = a.UnwrapKey(ctx, wb, []byte("other"))
+	require.NotNil(err)
+	c, err := NewPassphraseKMS("another-share-passphrase")
+	require.Nil(err)
+	_, err = c.UnwrapKey(ctx, wa, aad)
+	require.NotNil(err)
+}
@@ -65,7 +65,8 @@ func TestCMPEthereumERC20Transaction(t *testing.T) {
 	tx, err := ethereum.CreateTransaction(ctx, ethereum.TypeERC20Tx, int64(chainID), id, accountAddress, destination, assetAddress, value, new(big.Int).SetInt64(int64(n)))
 	require.Nil(err)
 
-	outputs := tx.ExtractOutputs()
+	outputs, err := tx.ExtractOutputs()
+	require.Nil(err)
 	require.Len(outputs, 1)
 	require.Equal(assetAddress, outputs[0].TokenAddress)
 	require.Equal(destination, outputs[0].Destination)
@@ -97,7 +98,8 @@ func TestCMPEthereumMultiSendTransaction(t *testing.T) {
 	tx, err := ethereum.CreateTransactionFromOutputs(ctx, ethereum.TypeMultiSendTx, int64(chainID), id, accountAddress, outputs, new(big.Int).SetInt64(int64(n)))
 	require.Nil(err)
 
-	parsedOutputs := tx.ExtractOutputs()
+	parsedOutputs, err := tx.ExtractOutputs()
+	require.Nil(err)
 	require.Len(parsedOutputs, 2)
 	for i, po := range parsedOutputs {
 		o := outputs[i]
@@ -124,7 +126,8 @@ func TestCMPEthereumTransaction(t *testing.T) {
 	tx, err := ethereum.CreateTransaction(ctx, ethereum.TypeETHTx, int64(chainID), id, accountAddress, destination, tokenAddress, value, nonce)
 	require.Nil(err)
 
-	outputs := tx.ExtractOutputs()
+	outputs, err := tx.ExtractOutputs()
+	require.Nil(err)
 	require.Len(outputs, 1)
 	require.Equal(ethereum.EthereumEmptyAddress, outputs[0].TokenAddress)
 	require.Equal(destination, outputs[0].Destination)
@@ -135,6 +138,37 @@ func TestCMPEthereumTransaction(t *testing.T) {
 	require.Equal(raw, hex.EncodeToString(signedTx.Marshal()))
 }
 
+func TestCMPEthereumContractCallTransaction(t *testing.T) {
+	ctx := context.Background()
+	require := require.New(t)
+	accountAddress := testPrepareEthereumAccount(ctx, require)
+
+	target := "0xc2132D05D31c914a87C6611C10748AEb04B58e8F"
+	data := common.FromHex("0x095ea7b3000000000000000000000000a03a8590bb3a2ca5c747c8b99c63da399424a0550000000000000000000000000000000000000000000000000000000000000064")
+	id := "b231eebd-78ec-44f7-aeeb-7cf0b73ed070"
+	tx, err := ethereum.CreateContractCallTransaction(ctx, int64(chainID), id, accountAddress, target, big.NewInt(0), data, big.NewInt(1))
+	require.Nil(err)
+
+	call := tx.ExtractContractCall()
+	require.NotNil(call)
+	require.Equal(target, call.Target)
+	require.Equal("095ea7b3", call.Selector)
+	require.Equal("approve(address,uint256)", call.Method())
+	args, err := call.Arguments()
+	require.Nil(err)
+	require.Equal([]string{"0xA03A8590BB3A2cA5c747c8b99C63DA399424a055", "100"}, args)
+	outputs, err := tx.ExtractOutputs()
+	require.Nil(err)
+	require.Len(outputs, 1)
+	require.Equal(ethereum.EthereumEmptyAddress, outputs[0].TokenAddress)
+	require.Equal(target, outputs[0].Destination)
+	require.Equal("0", outputs[0].Amount.String())
+
+	data = common.FromHex("0xa9059cbb000000000000000000000000a03a8590bb3a2ca5c747c8b99c63da399424a0550000000000000000000000000000000000000000000000000000000000000064")
+	_, err = ethereum.CreateContractCallTransaction(ctx, int64(chainID), id, accountAddress, target, big.NewInt(0), data, big.NewInt(1))
+	require.NotNil(err)
+}
+
 func testPrepareEthereumAccount(ctx context.Context, require *require.Assertions) string {
 	ah, err := ethereumAddressFromPriv(testEthereumKeyHolder)
 	require.Nil(err)
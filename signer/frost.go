@@ -19,9 +19,9 @@ const (
 	frostSignRoundTimeout   = 5 * time.Minute
 )
 
-func (node *Node) frostKeygen(ctx context.Context, sessionId []byte, group curve.Curve) (*KeygenResult, error) {
+func (node *Node) frostKeygen(ctx context.Context, set *PartySet, sessionId []byte, group curve.Curve) (*KeygenResult, error) {
 	logger.Printf("node.frostKeygen(%x)", sessionId)
-	start, err := frost.Keygen(group, node.id, node.members, node.threshold)(sessionId)
+	start, err := frost.Keygen(group, node.id, set.Members, set.Threshold)(sessionId)
 	if err != nil {
 		return nil, fmt.Errorf("frost.Keygen(%x) => %v", sessionId, err)
 	}
@@ -41,14 +41,14 @@ func testFROSTKeyGen(ctx context.Context, require *require.Assertions, nodes []*
 		memo := mtg.EncodeMixinExtraBase64(node.conf.AppId, node.encryptOperation(op))
 		memo = hex.EncodeToString([]byte(memo))
 		out := &mtg.Action{
-			OutputId:        uuid.Must(uuid.NewV4()).String(),
-			TransactionHash: crypto.Sha256Hash([]byte(op.Id)).String(),
 			UnifiedOutput: mtg.UnifiedOutput{
-				AppId:     node.conf.AppId,
-				AssetId:   node.conf.KeeperAssetId,
-				Extra:     memo,
-				Amount:    decimal.NewFromInt(1),
-				CreatedAt: time.Now(),
+				OutputId:        uuid.Must(uuid.NewV4()).String(),
+				TransactionHash: crypto.Sha256Hash([]byte(op.Id)).String(),
+				AppId:           node.conf.AppId,
+				AssetId:         node.conf.KeeperAssetId,
+				Extra:           memo,
+				Amount:          decimal.NewFromInt(1),
+				CreatedAt:       time.Now(),
 			},
 		}
 
@@ -87,14 +87,14 @@ func testFROSTSign(ctx context.Context, require *require.Assertions, nodes []*No
 	memo := mtg.EncodeMixinExtraBase64(node.conf.AppId, node.encryptOperation(sop))
 	memo = hex.EncodeToString([]byte(memo))
 	out := &mtg.Action{
-		OutputId:        uuid.Must(uuid.NewV4()).String(),
-		TransactionHash: crypto.Sha256Hash([]byte(sop.Id)).String(),
 		UnifiedOutput: mtg.UnifiedOutput{
-			AppId:     node.conf.AppId,
-			AssetId:   node.conf.KeeperAssetId,
-			Extra:     memo,
-			Amount:    decimal.NewFromInt(1),
-			CreatedAt: time.Now(),
+			OutputId:        uuid.Must(uuid.NewV4()).String(),
+			TransactionHash: crypto.Sha256Hash([]byte(sop.Id)).String(),
+			AppId:           node.conf.AppId,
+			AssetId:         node.conf.KeeperAssetId,
+			Extra:           memo,
+			Amount:          decimal.NewFromInt(1),
+			CreatedAt:       time.Now(),
 		},
 	}
 	op := TestProcessOutput(ctx, require, nodes, out, sid)
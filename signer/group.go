@@ -3,6 +3,8 @@ package signer
 import (
 	"bytes"
 	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
 	"database/sql"
 	"encoding/binary"
 	"encoding/hex"
@@ -14,6 +16,7 @@ import (
 	"github.com/MixinNetwork/mixin/logger"
 	"github.com/MixinNetwork/multi-party-sig/pkg/math/curve"
 	"github.com/MixinNetwork/multi-party-sig/pkg/party"
+	"github.com/MixinNetwork/multi-party-sig/pkg/taproot"
 	"github.com/MixinNetwork/multi-party-sig/protocols/cmp"
 	"github.com/MixinNetwork/multi-party-sig/protocols/frost"
 	"github.com/MixinNetwork/multi-party-sig/protocols/frost/sign"
@@ -45,6 +48,7 @@ type Session struct {
 	State      byte
 	CreatedAt  time.Time
 	PreparedAt sql.NullTime
+	Sequence   uint64
 }
 
 type KeygenResult struct {
@@ -124,13 +128,25 @@ func (node *Node) processAction(ctx context.Context, out *mtg.Action) (string, [
 		if err != nil {
 			return sessionId, nil, ""
 		}
+		err = node.checkPartySet(ctx, op, out)
+		logger.Printf("node.checkPartySet(%v, %v) => %v", op, out, err)
+		if err != nil {
+			return sessionId, nil, ""
+		}
+		if op.Type == common.OperationTypeSignInput && op.Curve == common.CurveEdwards25519Default {
+			node.writeStorageMessage(ctx, op, out)
+		}
 		sessionId = op.Id
 		needsCommittment := op.Type == common.OperationTypeSignInput
 		hash, err := crypto.HashFromString(out.TransactionHash)
 		if err != nil {
 			panic(err)
 		}
-		err = node.store.WriteSessionIfNotExist(ctx, op, hash, out.OutputIndex, out.CreatedAt, needsCommittment)
+		if op.Type == common.OperationTypeReshareInput {
+			err = node.processReshareRequest(ctx, op, out, hash)
+		} else {
+			err = node.store.WriteSessionIfNotExist(ctx, op, hash, out.OutputIndex, out.Sequence, out.CreatedAt, needsCommittment)
+		}
 		if err != nil {
 			panic(err)
 		}
@@ -145,6 +161,11 @@ func (node *Node) processAction(ctx context.Context, out *mtg.Action) (string, [
 			return sessionId, nil, ""
 		}
 		sessionId = req.Id
+		if req.Type == common.CustodianActionVoteWorks {
+			sessionId, txs, asset := node.processWorksVote(ctx, req, out)
+			logger.Printf("node.processWorksVote(%v, %v) => %v %s", req, out, txs, asset)
+			return sessionId, txs, asset
+		}
 		if string(req.Extra) == PrepareExtra {
 			err = node.processSignerPrepare(ctx, req, out)
 			logger.Printf("node.processSignerPrepare(%v, %v) => %v", req, out, err)
@@ -173,6 +194,10 @@ func (node *Node) processSignerPrepare(ctx context.Context, op *common.Operation
 	} else if s.PreparedAt.Valid {
 		return nil
 	}
+	set := node.readPartySet(ctx, s.Sequence)
+	if !set.Members.Contains(party.ID(out.Senders[0])) {
+		return nil
+	}
 	err = node.store.PrepareSessionSignerIfNotExist(ctx, op.Id, out.Senders[0], out.CreatedAt)
 	if err != nil {
 		return fmt.Errorf("store.PrepareSessionSignerIfNotExist(%v) => %v", op, err)
@@ -181,7 +206,7 @@ func (node *Node) processSignerPrepare(ctx context.Context, op *common.Operation
 	if err != nil {
 		return fmt.Errorf("store.ListSessionSignerResults(%s) => %d %v", op.Id, len(signers), err)
 	}
-	if len(signers) <= node.threshold {
+	if len(signers) <= set.Threshold {
 		return nil
 	}
 	err = node.store.MarkSessionPrepared(ctx, op.Id, out.CreatedAt)
@@ -200,7 +225,7 @@ func (node *Node) processSignerResult(ctx context.Context, op *common.Operation,
 
 	self := len(out.Senders) == 1 && out.Senders[0] == string(node.id)
 	switch session.Operation {
-	case common.OperationTypeKeygenInput:
+	case common.OperationTypeKeygenInput, common.OperationTypeRefreshInput, common.OperationTypeReshareInput:
 		err = node.store.WriteSessionSignerIfNotExist(ctx, op.Id, out.Senders[0], op.Extra, out.CreatedAt, self)
 		if err != nil {
 			panic(fmt.Errorf("store.WriteSessionSignerIfNotExist(%v) => %v", op, err))
@@ -221,11 +246,12 @@ func (node *Node) processSignerResult(ctx context.Context, op *common.Operation,
 	if !finished {
 		return nil, ""
 	}
-	if l := len(signers); l <= node.threshold {
+	if l := len(signers); l <= node.readPartySet(ctx, session.Sequence).Threshold {
 		panic(session.Id)
 	}
 
 	op = &common.Operation{Id: op.Id, Curve: session.Curve}
+	receiver := node.conf.KeeperAppId
 	switch session.Operation {
 	case common.OperationTypeKeygenInput:
 		if signers[string(node.id)] != session.Public {
@@ -247,8 +273,13 @@ func (node *Node) processSignerResult(ctx context.Context, op *common.Operation,
 		if hex.EncodeToString(public) != session.Public {
 			panic(session.Public)
 		}
+		role := byte(common.RequestRoleSigner)
+		if node.checkCustodianKeygen(session.asOperation()) {
+			role = common.RequestRoleCustodian
+			receiver = node.conf.CustodianAppId
+		}
 		op.Type = common.OperationTypeKeygenOutput
-		op.Extra = append([]byte{common.RequestRoleSigner}, chainCode...)
+		op.Extra = append([]byte{role}, chainCode...)
 		op.Extra = append(op.Extra, common.RequestFlagNone)
 		op.Public = session.Public
 	case common.OperationTypeSignInput:
@@ -274,14 +305,55 @@ func (node *Node) processSignerResult(ctx context.Context, op *common.Operation,
 		if crv != op.Curve {
 			panic(session.Id)
 		}
-		valid, vsig := node.verifySessionSignature(ctx, op.Curve, holder, extra, share, path)
-		logger.Printf("node.verifySessionSignature(%v, %s, %x, %v) => %t", session, holder, extra, path, valid)
-		if !valid || !bytes.Equal(sig, vsig) {
-			panic(hex.EncodeToString(vsig))
+		// a member left the party set has erased its share, and it can only
+		// follow the signature agreed by the threshold of the new party set
+		vsig := sig
+		if share != nil {
+			valid, res := node.verifySessionSignature(ctx, op.Curve, holder, extra, share, path)
+			logger.Printf("node.verifySessionSignature(%v, %s, %x, %v) => %t", session, holder, extra, path, valid)
+			if !valid || !bytes.Equal(sig, res) {
+				panic(hex.EncodeToString(res))
+			}
 		}
 		op.Type = common.OperationTypeSignOutput
 		op.Public = holder
 		op.Extra = vsig
+	case common.OperationTypeRefreshInput:
+		if signers[string(node.id)] != session.Public {
+			panic(session.Public)
+		}
+		cutover, err := node.store.CutoverRefresh(ctx, session.Id, out.Sequence)
+		logger.Printf("store.CutoverRefresh(%s, %d) => %t %v", session.Id, out.Sequence, cutover, err)
+		if err != nil {
+			panic(err)
+		}
+		if !cutover {
+			return nil, ""
+		}
+		holder, crv, share, err := node.store.ReadKeyByFingerprint(ctx, hex.EncodeToString(common.Fingerprint(session.Public)))
+		if err != nil {
+			panic(err)
+		}
+		if holder != session.Public || crv != session.Curve {
+			panic(session.Public)
+		}
+		public, _ := node.deriveByPath(ctx, crv, share, []byte{0, 0, 0, 0})
+		if hex.EncodeToString(public) != session.Public {
+			panic(session.Public)
+		}
+		op.Type = common.OperationTypeRefreshOutput
+		op.Public = session.Public
+	case common.OperationTypeReshareInput:
+		cutover, err := node.store.CutoverReshare(ctx, session.Id, out.Sequence, node.id)
+		logger.Printf("store.CutoverReshare(%s, %d) => %t %v", session.Id, out.Sequence, cutover, err)
+		if err != nil {
+			panic(err)
+		}
+		if !cutover {
+			return nil, ""
+		}
+		op.Type = common.OperationTypeReshareOutput
+		op.Extra = common.DecodeHexOrPanic(session.Extra)
 	default:
 		panic(session.Id)
 	}
@@ -290,7 +362,7 @@ func (node *Node) processSignerResult(ctx context.Context, op *common.Operation,
 	if repliedToKeeper {
 		return nil, ""
 	}
-	tx, asset := node.buildKeeperTransaction(ctx, op, out)
+	tx, asset := node.buildKeeperTransaction(ctx, op, out, receiver)
 	if asset != "" {
 		return nil, asset
 	}
@@ -307,6 +379,16 @@ func (node *Node) readKeyByFingerPath(ctx context.Context, public string) (strin
 	return public, crv, share, fingerPath[8:], err
 }
 
+func (node *Node) readKeyByFingerPathAt(ctx context.Context, public string, sequence uint64) (string, byte, []byte, []byte, error) {
+	fingerPath, err := hex.DecodeString(public)
+	if err != nil || len(fingerPath) != 12 || fingerPath[8] > 3 {
+		return "", 0, nil, nil, fmt.Errorf("node.readKeyByFingerPathAt(%s) invalid fingerprint", public)
+	}
+	fingerprint := hex.EncodeToString(fingerPath[:8])
+	public, crv, share, err := node.store.ReadKeyByFingerprintAt(ctx, fingerprint, sequence)
+	return public, crv, share, fingerPath[8:], err
+}
+
 func (node *Node) deriveByPath(ctx context.Context, crv byte, share, path []byte) ([]byte, []byte) {
 	switch crv {
 	case common.CurveSecp256k1ECDSABitcoin, common.CurveSecp256k1ECDSAEthereum:
@@ -329,6 +411,12 @@ func (node *Node) deriveByPath(ctx context.Context, crv byte, share, path []byte
 		if err != nil {
 			panic(err)
 		}
+		for i := 0; i < int(path[0]); i++ {
+			conf, err = conf.DeriveChild(uint32(path[i+1]))
+			if err != nil {
+				panic(err)
+			}
+		}
 		return conf.PublicKey, conf.ChainKey
 	case common.CurveEdwards25519Default, common.CurveEdwards25519Mixin:
 		conf := frost.EmptyConfig(curve.Edwards25519{})
@@ -406,6 +494,10 @@ func (node *Node) verifySessionSignature(ctx context.Context, crv byte, holder s
 		err := ethereum.VerifyHashSignature(hex.EncodeToString(public), msg, sig)
 		logger.Printf("ethereum.VerifyHashSignature(%x, %x, %x) => %v", public, msg, sig, err)
 		return err == nil, sig
+	case common.CurveSecp256k1SchnorrBitcoin:
+		res := taproot.PublicKey(public).Verify(sig, msg)
+		logger.Printf("taproot.Verify(%x, %x, %x) => %t", public, msg, sig, res)
+		return res, sig
 	case common.CurveEdwards25519Mixin:
 		if len(msg) < 32 || len(sig) != 64 {
 			return false, nil
@@ -433,9 +525,14 @@ func (node *Node) verifySessionSignature(ctx context.Context, crv byte, holder s
 		res := mpub.Verify(hash, msig)
 		logger.Printf("mixin.Verify(%v, %x) => %t", hash, msig[:], res)
 		return res, sig
-	case common.CurveEdwards25519Default,
-		common.CurveSecp256k1SchnorrBitcoin:
-		return common.CheckTestEnvironment(ctx), sig // TODO
+	case common.CurveEdwards25519Default:
+		if len(sig) != ed25519.SignatureSize {
+			return false, nil
+		}
+		msg = node.readStorageMessage(ctx, msg)
+		res := ed25519.Verify(public, msg, sig)
+		logger.Printf("ed25519.Verify(%x, %x, %x) => %t", public, msg, sig, res)
+		return res, sig
 	default:
 		panic(crv)
 	}
@@ -443,21 +540,39 @@ func (node *Node) verifySessionSignature(ctx context.Context, crv byte, holder s
 
 func (node *Node) verifySessionSignerResults(ctx context.Context, session *Session, sessionSigners map[string]string) (bool, []byte) {
 	switch session.Operation {
-	case common.OperationTypeKeygenInput:
+	case common.OperationTypeKeygenInput, common.OperationTypeRefreshInput:
 		var signed int
-		for _, id := range node.conf.MTG.Genesis.Members {
-			public, found := sessionSigners[id]
+		members := node.readPartySet(ctx, session.Sequence).Members
+		for _, id := range members {
+			public, found := sessionSigners[string(id)]
 			if found && public == session.Public && public == sessionSigners[string(node.id)] {
 				signed = signed + 1
 			}
 		}
-		exact := len(node.conf.MTG.Genesis.Members)
+		exact := len(members)
 		return signed >= exact, nil
+	case common.OperationTypeReshareInput:
+		// the nodes not in the reshare have no result, so only the
+		// results of all participants are compared with each other
+		old, next, err := decodeReshareExtra(node.members, common.DecodeHexOrPanic(session.Extra))
+		if err != nil {
+			panic(err)
+		}
+		var digest string
+		for _, id := range reshareParticipants(old.Members, next.Members) {
+			extra := sessionSigners[string(id)]
+			if extra == "" || (digest != "" && extra != digest) {
+				return false, nil
+			}
+			digest = extra
+		}
+		return true, nil
 	case common.OperationTypeSignInput:
 		var signed int
 		var sig []byte
-		for _, id := range node.conf.MTG.Genesis.Members {
-			extra, found := sessionSigners[id]
+		set := node.readPartySet(ctx, session.Sequence)
+		for _, id := range set.Members {
+			extra, found := sessionSigners[string(id)]
 			if sig == nil && found {
 				sig = common.DecodeHexOrPanic(extra)
 			}
@@ -465,7 +580,7 @@ func (node *Node) verifySessionSignerResults(ctx context.Context, session *Sessi
 				signed = signed + 1
 			}
 		}
-		exact := node.threshold + 1
+		exact := set.Threshold + 1
 		return signed >= exact, sig
 	default:
 		panic(session.Id)
@@ -487,38 +602,49 @@ func (node *Node) parseSignerMessage(out *mtg.Action) (*common.Operation, error)
 	switch req.Type {
 	case common.OperationTypeKeygenInput:
 	case common.OperationTypeSignInput:
+	case common.OperationTypeRefreshInput:
+	case common.OperationTypeReshareInput:
+	case common.CustodianActionVoteWorks:
 	default:
 		return nil, fmt.Errorf("invalid action %d", req.Type)
 	}
 	return req, nil
 }
 
-func (node *Node) startOperation(ctx context.Context, op *common.Operation, members []party.ID) error {
-	logger.Printf("node.startOperation(%v)", op)
+func (node *Node) startOperation(ctx context.Context, op *common.Operation, set *PartySet, members []party.ID) error {
+	logger.Printf("node.startOperation(%v, %v)", op, set)
 
 	switch op.Type {
 	case common.OperationTypeKeygenInput:
-		return node.startKeygen(ctx, op)
+		return node.startKeygen(ctx, op, set)
 	case common.OperationTypeSignInput:
 		return node.startSign(ctx, op, members)
+	case common.OperationTypeRefreshInput:
+		return node.startRefresh(ctx, op, set)
+	case common.OperationTypeReshareInput:
+		return node.startReshare(ctx, op)
 	default:
 		panic(op.Id)
 	}
 }
 
-func (node *Node) startKeygen(ctx context.Context, op *common.Operation) error {
-	logger.Printf("node.startKeygen(%v)", op)
+func (node *Node) startKeygen(ctx context.Context, op *common.Operation, set *PartySet) error {
+	logger.Printf("node.startKeygen(%v, %v)", op, set)
+	if !set.Members.Contains(node.id) {
+		logger.Printf("node.startKeygen(%v, %v) exit without participation\n", op, set)
+		return nil
+	}
 	var err error
 	var res *KeygenResult
 	switch op.Curve {
 	case common.CurveSecp256k1ECDSABitcoin, common.CurveSecp256k1ECDSAEthereum:
-		res, err = node.cmpKeygen(ctx, op.IdBytes(), op.Curve)
+		res, err = node.cmpKeygen(ctx, set, op.IdBytes(), op.Curve)
 		logger.Printf("node.cmpKeygen(%v) => %v", op, err)
 	case common.CurveSecp256k1SchnorrBitcoin:
-		res, err = node.taprootKeygen(ctx, op.IdBytes())
+		res, err = node.taprootKeygen(ctx, set, op.IdBytes())
 		logger.Printf("node.taprootKeygen(%v) => %v", op, err)
 	case common.CurveEdwards25519Mixin, common.CurveEdwards25519Default:
-		res, err = node.frostKeygen(ctx, op.IdBytes(), curve.Edwards25519{})
+		res, err = node.frostKeygen(ctx, set, op.IdBytes(), curve.Edwards25519{})
 		logger.Printf("node.frostKeygen(%v) => %v", op, err)
 	default:
 		panic(op.Id)
@@ -544,12 +670,16 @@ func (node *Node) startSign(ctx context.Context, op *common.Operation, members [
 		logger.Printf("node.startSign(%v, %v) exit without committement\n", op, members)
 		return nil
 	}
-	public, crv, share, path, err := node.readKeyByFingerPath(ctx, op.Public)
-	logger.Printf("node.readKeyByFingerPath(%s) => %s %v", op.Public, public, err)
+	session, err := node.store.ReadSession(ctx, op.Id)
+	if err != nil {
+		return fmt.Errorf("store.ReadSession(%s) => %v", op.Id, err)
+	}
+	public, crv, share, path, err := node.readKeyByFingerPathAt(ctx, op.Public, session.Sequence)
+	logger.Printf("node.readKeyByFingerPathAt(%s, %d) => %s %v", op.Public, session.Sequence, public, err)
 	if err != nil {
-		return fmt.Errorf("node.readKeyByFingerPath(%s) => %v", op.Public, err)
+		return fmt.Errorf("node.readKeyByFingerPathAt(%s) => %v", op.Public, err)
 	}
-	if public == "" {
+	if public == "" || share == nil {
 		return node.store.FailSession(ctx, op.Id)
 	}
 	if crv != op.Curve {
@@ -566,10 +696,11 @@ func (node *Node) startSign(ctx context.Context, op *common.Operation, members [
 		res, err = node.cmpSign(ctx, members, public, share, op.Extra, op.IdBytes(), op.Curve, path)
 		logger.Printf("node.cmpSign(%v) => %v %v", op, res, err)
 	case common.CurveSecp256k1SchnorrBitcoin:
-		res, err = node.taprootSign(ctx, members, public, share, op.Extra, op.IdBytes())
+		res, err = node.taprootSign(ctx, members, public, share, op.Extra, op.IdBytes(), path)
 		logger.Printf("node.taprootSign(%v) => %v %v", op, res, err)
 	case common.CurveEdwards25519Default:
-		res, err = node.frostSign(ctx, members, public, share, op.Extra, op.IdBytes(), curve.Edwards25519{}, sign.ProtocolEd25519SHA512)
+		msg := node.readStorageMessage(ctx, op.Extra)
+		res, err = node.frostSign(ctx, members, public, share, msg, op.IdBytes(), curve.Edwards25519{}, sign.ProtocolEd25519SHA512)
 		logger.Printf("node.frostSign(%v) => %v %v", op, res, err)
 	case common.CurveEdwards25519Mixin:
 		res, err = node.frostSign(ctx, members, public, share, op.Extra, op.IdBytes(), curve.Edwards25519{}, sign.ProtocolMixinPublic)
@@ -618,6 +749,8 @@ func (node *Node) parseOperation(ctx context.Context, memo string) (*common.Oper
 	switch op.Type {
 	case common.OperationTypeSignInput:
 	case common.OperationTypeKeygenInput:
+	case common.OperationTypeRefreshInput:
+	case common.OperationTypeReshareInput:
 	default:
 		return nil, fmt.Errorf("invalid action %d", op.Type)
 	}
@@ -629,9 +762,41 @@ func (node *Node) parseOperation(ctx context.Context, memo string) (*common.Oper
 	default:
 		return nil, fmt.Errorf("invalid curve %d", op.Curve)
 	}
+
+	if op.Type == common.OperationTypeKeygenInput && len(op.Extra) > 0 {
+		if !node.checkCustodianKeygen(op) {
+			return nil, fmt.Errorf("invalid keygen extra %x", op.Extra)
+		}
+	}
+	if op.Type == common.OperationTypeRefreshInput {
+		if op.Public == "" || len(op.Extra) > 0 {
+			return nil, fmt.Errorf("invalid refresh %s %x", op.Public, op.Extra)
+		}
+	}
+	if op.Type == common.OperationTypeReshareInput {
+		_, _, err := decodeReshareExtra(node.members, op.Extra)
+		if op.Public != "" || err != nil {
+			return nil, fmt.Errorf("invalid reshare %s %x %v", op.Public, op.Extra, err)
+		}
+	}
 	return op, nil
 }
 
+// the custodian in the keeper mtg requests mixin keys with the custodian role
+// as the only extra, and the keygen output should go back to the custodian app
+func (node *Node) checkCustodianKeygen(op *common.Operation) bool {
+	if node.conf.CustodianAppId == "" {
+		return false
+	}
+	if op.Type != common.OperationTypeKeygenInput {
+		return false
+	}
+	if op.Curve != common.CurveEdwards25519Mixin {
+		return false
+	}
+	return bytes.Equal(op.Extra, []byte{common.RequestRoleCustodian})
+}
+
 func (node *Node) encryptOperation(op *common.Operation) []byte {
 	extra := op.Encode()
 	if len(extra) > OperationExtraLimit {
@@ -640,24 +805,77 @@ func (node *Node) encryptOperation(op *common.Operation) []byte {
 	return common.AESEncrypt(node.aesKey[:], extra, op.Id)
 }
 
-func (node *Node) buildKeeperTransaction(ctx context.Context, op *common.Operation, act *mtg.Action) (*mtg.Transaction, string) {
+func (node *Node) buildKeeperTransaction(ctx context.Context, op *common.Operation, act *mtg.Action, receiver string) (*mtg.Transaction, string) {
 	extra := node.encryptOperation(op)
 	if len(extra) > 160 {
 		panic(fmt.Errorf("node.buildKeeperTransaction(%v) omitted %x", op, extra))
 	}
 
+	// the custodian results are paid with the custodian asset to the
+	// custodian members, though they may be the same as the keeper ones
+	asset, receivers := node.conf.KeeperAssetId, node.keeper
+	if receiver == node.conf.CustodianAppId {
+		asset, receivers = node.conf.CustodianAssetId, node.custodian
+	}
+
 	amount := decimal.NewFromInt(1)
 	if !common.CheckTestEnvironment(ctx) {
-		balance := act.CheckAssetBalanceAt(ctx, node.conf.KeeperAssetId)
+		balance := act.CheckAssetBalanceAt(ctx, asset)
 		if balance.Cmp(amount) < 0 {
-			return nil, node.conf.KeeperAssetId
+			return nil, asset
 		}
 	}
 
-	members := node.keeper.Genesis.Members
-	threshold := node.keeper.Genesis.Threshold
+	members := receivers.Genesis.Members
+	threshold := receivers.Genesis.Threshold
 	traceId := common.UniqueId(node.group.GenesisId(), op.Id)
-	tx := act.BuildTransaction(ctx, traceId, node.conf.KeeperAppId, node.conf.KeeperAssetId, amount.String(), string(extra), members, threshold)
+	tx := act.BuildTransaction(ctx, traceId, receiver, asset, amount.String(), string(extra), members, threshold)
 	logger.Printf("node.buildKeeperTransaction(%v) => %s %x %x", op, traceId, extra, tx.Serialize())
 	return tx, ""
 }
+
+// the ed25519 message may be too large for the operation extra, then the
+// keeper puts the message in the storage transaction referenced by the sign
+// request, and the operation extra is the sha256 hash of the message
+func (node *Node) writeStorageMessage(ctx context.Context, op *common.Operation, out *mtg.Action) {
+	if common.CheckTestEnvironment(ctx) || len(op.Extra) != 32 {
+		return
+	}
+	ver, err := node.group.ReadKernelTransactionUntilSufficient(ctx, out.TransactionHash)
+	if err != nil || ver == nil {
+		panic(fmt.Errorf("group.ReadKernelTransactionUntilSufficient(%s) => %v %v", out.TransactionHash, ver, err))
+	}
+	if len(ver.References) != 1 {
+		return
+	}
+	stx, err := node.group.ReadKernelTransactionUntilSufficient(ctx, ver.References[0].String())
+	if err != nil || stx == nil {
+		panic(fmt.Errorf("group.ReadKernelTransactionUntilSufficient(%s) => %v %v", ver.References[0], stx, err))
+	}
+	sum := sha256.Sum256(stx.Extra)
+	if !bytes.Equal(sum[:], op.Extra) {
+		return
+	}
+	err = node.store.WriteProperty(ctx, storageMessageKey(op.Extra), hex.EncodeToString(stx.Extra))
+	if err != nil {
+		panic(err)
+	}
+}
+
+func (node *Node) readStorageMessage(ctx context.Context, hash []byte) []byte {
+	if len(hash) != 32 {
+		return hash
+	}
+	val, err := node.store.ReadProperty(ctx, storageMessageKey(hash))
+	if err != nil {
+		panic(err)
+	}
+	if val == "" {
+		return hash
+	}
+	return common.DecodeHexOrPanic(val)
+}
+
+func storageMessageKey(hash []byte) string {
+	return fmt.Sprintf("STORAGE:MESSAGE:%x", hash)
+}
@@ -2,6 +2,7 @@ package signer
 
 import (
 	"context"
+	"slices"
 
 	"github.com/MixinNetwork/safe/messenger"
 	"github.com/MixinNetwork/trusted-group/mtg"
@@ -10,6 +11,7 @@ import (
 type Configuration struct {
 	AppId                   string             `toml:"app-id"`
 	KeeperAppId             string             `toml:"keeper-app-id"`
+	CustodianAppId          string             `toml:"custodian-app-id"`
 	StoreDir                string             `toml:"store-dir"`
 	MessengerConversationId string             `toml:"messenger-conversation-id"`
 	MonitorConversaionId    string             `toml:"monitor-conversation-id"`
@@ -17,10 +19,15 @@ type Configuration struct {
 	SharedKey               string             `toml:"shared-key"`
 	AssetId                 string             `toml:"asset-id"`
 	KeeperAssetId           string             `toml:"keeper-asset-id"`
+	CustodianAssetId        string             `toml:"custodian-asset-id"`
 	KeeperPublicKey         string             `toml:"keeper-public-key"`
 	SaverAPI                string             `toml:"saver-api"`
+	SaverAPIs               []string           `toml:"saver-apis"`
+	SaverThreshold          int                `toml:"saver-threshold"`
 	SaverKey                string             `toml:"saver-key"`
 	MixinRPC                string             `toml:"mixin-rpc"`
+	SharePassphrase         string             `toml:"share-passphrase"`
+	ShareKeyFile            string             `toml:"share-key-file"`
 	MTG                     *mtg.Configuration `toml:"mtg"`
 }
 
@@ -35,6 +42,20 @@ func (c *Configuration) Messenger() *messenger.MixinConfiguration {
 	}
 }
 
+// the legacy saver-api is the first saver, and the default threshold
+// is the majority of all savers
+func (c *Configuration) Savers() ([]string, int) {
+	apis := c.SaverAPIs
+	if c.SaverAPI != "" && !slices.Contains(apis, c.SaverAPI) {
+		apis = append([]string{c.SaverAPI}, apis...)
+	}
+	threshold := c.SaverThreshold
+	if threshold == 0 {
+		threshold = len(apis)/2 + 1
+	}
+	return apis, threshold
+}
+
 type Network interface {
 	ReceiveMessage(context.Context) (*messenger.MixinMessage, error)
 	QueueMessage(ctx context.Context, receiver string, b []byte) error
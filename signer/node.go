@@ -38,13 +38,16 @@ type Node struct {
 	operations map[string]bool
 	store      *SQLite3Store
 
-	keeper       *mtg.Configuration
-	mixin        *mixin.Client
-	backupClient *http.Client
-	saverKey     *crypto.Key
+	keeper         *mtg.Configuration
+	custodian      *mtg.Configuration
+	mixin          *mixin.Client
+	backupClient   *http.Client
+	saverKey       *crypto.Key
+	saverAPIs      []string
+	saverThreshold int
 }
 
-func NewNode(store *SQLite3Store, group *mtg.Group, network Network, conf *Configuration, keeper *mtg.Configuration, mixin *mixin.Client) *Node {
+func NewNode(store *SQLite3Store, group *mtg.Group, network Network, conf *Configuration, keeper, custodian *mtg.Configuration, mixin *mixin.Client) *Node {
 	node := &Node{
 		id:         party.ID(conf.MTG.App.AppId),
 		threshold:  conf.Threshold,
@@ -56,14 +59,22 @@ func NewNode(store *SQLite3Store, group *mtg.Group, network Network, conf *Confi
 		operations: make(map[string]bool),
 		store:      store,
 		keeper:     keeper,
+		custodian:  custodian,
 		mixin:      mixin,
 		backupClient: &http.Client{
 			Timeout: 5 * time.Second,
 		},
 	}
 	node.aesKey = common.ECDHEd25519(conf.SharedKey, conf.KeeperPublicKey)
+	if conf.CustodianAppId != "" && (custodian == nil || conf.CustodianAssetId == "") {
+		panic(conf.CustodianAppId)
+	}
 
-	if conf.SaverAPI != "" {
+	node.saverAPIs, node.saverThreshold = conf.Savers()
+	if len(node.saverAPIs) > 0 {
+		if node.saverThreshold < 1 || node.saverThreshold > len(node.saverAPIs) {
+			panic(fmt.Errorf("%d/%d", node.saverThreshold, len(node.saverAPIs)))
+		}
 		priv, err := crypto.KeyFromString(conf.SaverKey)
 		if err != nil {
 			panic(conf.SaverKey)
@@ -92,7 +103,13 @@ func (node *Node) Boot(ctx context.Context) {
 	if err != nil {
 		panic(err)
 	}
+	err = node.store.Migrate3(ctx)
+	if err != nil {
+		panic(err)
+	}
 	go node.loopBackup(ctx)
+	go node.loopBackupVerification(ctx)
+	go node.loopDailyWorks(ctx)
 	go node.loopInitialSessions(ctx)
 	go node.loopPreparedSessions(ctx)
 	go node.loopPendingSessions(ctx)
@@ -101,7 +118,7 @@ func (node *Node) Boot(ctx context.Context) {
 }
 
 func (node *Node) loopBackup(ctx context.Context) {
-	for node.conf.SaverAPI != "" {
+	for len(node.saverAPIs) > 0 {
 		time.Sleep(5 * time.Second)
 		keys, err := node.store.ListUnbackupedKeys(ctx, 1000)
 		if err != nil {
@@ -109,7 +126,7 @@ func (node *Node) loopBackup(ctx context.Context) {
 		}
 
 		for _, key := range keys {
-			share, err := common.Base91Decode(key.Share)
+			share, err := node.store.DecodeKeyShare(ctx, key)
 			if err != nil {
 				panic(err)
 			}
@@ -130,6 +147,14 @@ func (node *Node) loopBackup(ctx context.Context) {
 	}
 }
 
+func (node *Node) loopBackupVerification(ctx context.Context) {
+	for len(node.saverAPIs) > 0 {
+		time.Sleep(time.Hour)
+		invalid, err := node.verifyKeygenBackups(ctx)
+		logger.Printf("node.verifyKeygenBackups() => %d %v", invalid, err)
+	}
+}
+
 func (node *Node) loopInitialSessions(ctx context.Context) {
 	for {
 		time.Sleep(3 * time.Second)
@@ -170,15 +195,25 @@ func (node *Node) loopPreparedSessions(ctx context.Context) {
 		sessions := node.listPreparedSessions(ctx)
 		results := make([]<-chan error, len(sessions))
 		for i, s := range sessions {
-			threshold := node.threshold + 1
+			set := node.readPartySet(ctx, s.Sequence)
+			threshold := set.Threshold + 1
 			signers, err := node.store.ListSessionPreparedMembers(ctx, s.Id, threshold)
 			if err != nil {
 				panic(err)
 			}
-			if len(signers) != threshold && s.Operation != common.OperationTypeKeygenInput {
+			if len(signers) != threshold && s.Operation == common.OperationTypeSignInput {
 				panic(fmt.Sprintf("ListSessionPreparedMember(%s, %d) => %d", s.Id, threshold, len(signers)))
 			}
-			results[i] = node.queueOperation(ctx, s.asOperation(), signers)
+			res := node.queueOperation(ctx, s.asOperation(), set, signers)
+			if res != nil && s.Operation == common.OperationTypeReshareInput {
+				// a reshare goes through all keys and should not block other sessions
+				go func() {
+					err := <-res
+					logger.Printf("node.queueOperation(%s) => %v", s.Id, err)
+				}()
+				continue
+			}
+			results[i] = res
 		}
 		for _, res := range results {
 			if res == nil {
@@ -194,13 +229,19 @@ func (node *Node) loopPreparedSessions(ctx context.Context) {
 func (node *Node) listPreparedSessions(ctx context.Context) []*Session {
 	parallelization := runtime.NumCPU() * (len(node.members)/16 + 1)
 
+	err := node.store.EraseRefreshedShares(ctx, time.Now().Add(-SessionTimeout))
+	if err != nil {
+		panic(err)
+	}
+
 	var sessions []*Session
 	prepared, err := node.store.ListPreparedSessions(ctx, parallelization*4)
 	if err != nil {
 		panic(err)
 	}
 	for _, s := range prepared {
-		if s.CreatedAt.Add(SessionTimeout).Before(time.Now()) {
+		expired := s.CreatedAt.Add(SessionTimeout).Before(time.Now())
+		if expired {
 			err = node.store.FailSession(ctx, s.Id)
 			logger.Printf("store.FailSession(%s, listPreparedSessions) => %v", s.Id, err)
 			if err != nil {
@@ -232,14 +273,17 @@ func (node *Node) loopPendingSessions(ctx context.Context) {
 		for _, s := range sessions {
 			op := s.asOperation()
 			switch op.Type {
-			case common.OperationTypeKeygenInput:
+			case common.OperationTypeKeygenInput, common.OperationTypeRefreshInput, common.OperationTypeReshareInput:
 				op.Extra = common.DecodeHexOrPanic(op.Public)
 			case common.OperationTypeSignInput:
 				holder, crv, share, path, err := node.readKeyByFingerPath(ctx, op.Public)
 				if err != nil || crv != op.Curve {
 					panic(err)
 				}
-				signed, sig := node.verifySessionSignature(ctx, op.Curve, holder, op.Extra, share, path)
+				signed, sig := share != nil, []byte(nil)
+				if signed {
+					signed, sig = node.verifySessionSignature(ctx, op.Curve, holder, op.Extra, share, path)
+				}
 				if signed {
 					op.Extra = sig
 				} else {
@@ -319,7 +363,8 @@ func (node *Node) acceptIncomingMessages(ctx context.Context) {
 		if r == nil {
 			continue
 		}
-		threshold := node.threshold + 1
+		set := node.readPartySet(ctx, r.Sequence)
+		threshold := set.Threshold + 1
 		signers, err := node.store.ListSessionPreparedMembers(ctx, r.Id, threshold)
 		if err != nil {
 			panic(err)
@@ -334,7 +379,7 @@ func (node *Node) acceptIncomingMessages(ctx context.Context) {
 				Curve:  r.Curve,
 				Public: r.Public,
 				Extra:  common.DecodeHexOrPanic(r.Extra),
-			}, signers)
+			}, set, signers)
 		} else {
 			rm := &protocol.Message{SSID: sessionId, From: node.id, To: party.ID(mm.Peer)}
 			rmb := marshalSessionMessage(sessionId, rm)
@@ -344,7 +389,7 @@ func (node *Node) acceptIncomingMessages(ctx context.Context) {
 	}
 }
 
-func (node *Node) queueOperation(ctx context.Context, op *common.Operation, members []party.ID) <-chan error {
+func (node *Node) queueOperation(ctx context.Context, op *common.Operation, set *PartySet, members []party.ID) <-chan error {
 	node.mutex.Lock()
 	defer node.mutex.Unlock()
 
@@ -354,7 +399,7 @@ func (node *Node) queueOperation(ctx context.Context, op *common.Operation, memb
 	node.operations[op.Id] = true
 
 	res := make(chan error)
-	go func() { res <- node.startOperation(ctx, op, members) }()
+	go func() { res <- node.startOperation(ctx, op, set, members) }()
 	return res
 }
 
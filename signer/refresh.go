@@ -0,0 +1,570 @@
+package signer
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/MixinNetwork/mixin/logger"
+	"github.com/MixinNetwork/multi-party-sig/common/round"
+	"github.com/MixinNetwork/multi-party-sig/pkg/hash"
+	"github.com/MixinNetwork/multi-party-sig/pkg/math/arith"
+	"github.com/MixinNetwork/multi-party-sig/pkg/math/curve"
+	"github.com/MixinNetwork/multi-party-sig/pkg/math/polynomial"
+	"github.com/MixinNetwork/multi-party-sig/pkg/math/sample"
+	"github.com/MixinNetwork/multi-party-sig/pkg/paillier"
+	"github.com/MixinNetwork/multi-party-sig/pkg/party"
+	"github.com/MixinNetwork/multi-party-sig/pkg/pedersen"
+	zkfac "github.com/MixinNetwork/multi-party-sig/pkg/zk/fac"
+	zkmod "github.com/MixinNetwork/multi-party-sig/pkg/zk/mod"
+	zkprm "github.com/MixinNetwork/multi-party-sig/pkg/zk/prm"
+	"github.com/MixinNetwork/multi-party-sig/protocols/cmp"
+	"github.com/MixinNetwork/multi-party-sig/protocols/cmp/config"
+	"github.com/MixinNetwork/multi-party-sig/protocols/frost"
+	"github.com/MixinNetwork/safe/common"
+	"github.com/cronokirby/saferith"
+)
+
+const (
+	refreshRoundTimeout = 5 * time.Minute
+	refreshProtocolId   = "safe/refresh-threshold"
+	refreshRounds       = round.Number(2)
+)
+
+// a proactive refresh lets every share holder deal a random polynomial
+// with a zero constant, so the sum of all of them is a sharing of zero,
+// adding it to the old shares keeps the public key and all derived keys
+// while the old shares become useless once all holders have replaced them,
+// so the new share is only kept aside until all members have confirmed it
+// in the mtg, then all of them switch to it at the same sequence
+//
+// the cmp shares also have the paillier, pedersen and elgamal auxiliary
+// material, which is sampled again and proved the same way as cmp keygen
+type refreshResult struct {
+	Share              curve.Scalar
+	VerificationShares map[party.ID]curve.Point
+	ElGamal            curve.Scalar
+	Paillier           *paillier.SecretKey
+	Aux                map[party.ID]*config.Public
+}
+
+type refreshRound1 struct {
+	*round.Helper
+	threshold int
+	aux       bool
+}
+
+type refreshRound2 struct {
+	*refreshRound1
+	phi       map[party.ID]*polynomial.Exponent
+	shareFrom map[party.ID]curve.Scalar
+
+	elgamal  curve.Scalar
+	paillier *paillier.SecretKey
+	public   map[party.ID]*config.Public
+}
+
+type refreshBroadcast2 struct {
+	round.ReliableBroadcastContent
+	Phi *polynomial.Exponent
+
+	ElGamal curve.Point
+	N       *saferith.Modulus
+	S       *saferith.Nat
+	T       *saferith.Nat
+	Mod     *zkmod.Proof
+	Prm     *zkprm.Proof
+	Fac     *zkfac.Proof
+}
+
+type refreshMessage2 struct {
+	Share curve.Scalar
+}
+
+func (node *Node) startRefresh(ctx context.Context, op *common.Operation, set *PartySet) error {
+	logger.Printf("node.startRefresh(%v, %v)", op, set)
+	if !set.Members.Contains(node.id) {
+		logger.Printf("node.startRefresh(%v, %v) exit without participation\n", op, set)
+		return nil
+	}
+	fingerprint := hex.EncodeToString(common.Fingerprint(op.Public))
+	public, crv, share, err := node.store.ReadKeyByFingerprint(ctx, fingerprint)
+	logger.Printf("store.ReadKeyByFingerprint(%s) => %s %v", fingerprint, public, err)
+	if err != nil {
+		return fmt.Errorf("store.ReadKeyByFingerprint(%s) => %v", fingerprint, err)
+	}
+	if public != op.Public || crv != op.Curve {
+		return node.store.FailSession(ctx, op.Id)
+	}
+
+	res, err := node.refreshShare(ctx, op.IdBytes(), crv, public, share)
+	logger.Printf("node.refreshShare(%v) => %v", op, err)
+	if err != nil {
+		return node.store.FailSession(ctx, op.Id)
+	}
+	if hex.EncodeToString(res.Public) != public {
+		panic(public)
+	}
+	saved, err := node.sendKeygenBackup(ctx, op, res.Share)
+	logger.Printf("node.sendKeygenBackup(%v, %d) => %t %v", op, len(res.Share), saved, err)
+	if err != nil {
+		err = node.store.FailSession(ctx, op.Id)
+		logger.Printf("store.FailSession(%s, startRefresh) => %v", op.Id, err)
+		return err
+	}
+	return node.store.WriteRefreshShare(ctx, op.Id, public, share, res.Share, saved)
+}
+
+func (node *Node) refreshShare(ctx context.Context, sessionId []byte, crv byte, public string, share []byte) (*KeygenResult, error) {
+	switch crv {
+	case common.CurveSecp256k1ECDSABitcoin, common.CurveSecp256k1ECDSAEthereum:
+		conf := cmp.EmptyConfig(curve.Secp256k1{})
+		err := conf.UnmarshalBinary(share)
+		if err != nil {
+			panic(err)
+		}
+		verifications := make(map[party.ID]curve.Point, len(conf.Public))
+		for id, p := range conf.Public {
+			verifications[id] = p.ECDSA
+		}
+		res, ssid, err := node.refreshLoop(ctx, sessionId, conf.Group, conf.Threshold, public, verifications, true)
+		if err != nil {
+			return nil, err
+		}
+		conf.ECDSA = conf.Group.NewScalar().Set(conf.ECDSA).Add(res.Share)
+		conf.ElGamal = res.ElGamal
+		conf.Paillier = res.Paillier
+		for id, p := range conf.Public {
+			p.ECDSA = p.ECDSA.Add(res.VerificationShares[id])
+			p.ElGamal = res.Aux[id].ElGamal
+			p.Paillier = res.Aux[id].Paillier
+			p.Pedersen = res.Aux[id].Pedersen
+		}
+		return &KeygenResult{
+			Public: common.MarshalPanic(conf.PublicPoint()),
+			Share:  common.MarshalPanic(conf),
+			SSID:   ssid,
+		}, nil
+	case common.CurveSecp256k1SchnorrBitcoin:
+		group := curve.Secp256k1{}
+		conf := &frost.TaprootConfig{PrivateShare: group.NewScalar()}
+		err := conf.UnmarshalBinary(share)
+		if err != nil {
+			panic(err)
+		}
+		res, ssid, err := node.refreshLoop(ctx, sessionId, group, conf.Threshold, public, conf.VerificationShares, false)
+		if err != nil {
+			return nil, err
+		}
+		conf.PrivateShare = group.NewScalar().Set(conf.PrivateShare).Add(res.Share)
+		for id, p := range conf.VerificationShares {
+			conf.VerificationShares[id] = p.Add(res.VerificationShares[id])
+		}
+		return &KeygenResult{
+			Public: conf.PublicKey,
+			Share:  common.MarshalPanic(conf),
+			SSID:   ssid,
+		}, nil
+	case common.CurveEdwards25519Mixin, common.CurveEdwards25519Default:
+		group := curve.Edwards25519{}
+		conf := frost.EmptyConfig(group)
+		err := conf.UnmarshalBinary(share)
+		if err != nil {
+			panic(err)
+		}
+		res, ssid, err := node.refreshLoop(ctx, sessionId, group, conf.Threshold, public, conf.VerificationShares.Points, false)
+		if err != nil {
+			return nil, err
+		}
+		conf.PrivateShare = group.NewScalar().Set(conf.PrivateShare).Add(res.Share)
+		points := make(map[party.ID]curve.Point, len(conf.VerificationShares.Points))
+		for id, p := range conf.VerificationShares.Points {
+			points[id] = p.Add(res.VerificationShares[id])
+		}
+		conf.VerificationShares = party.NewPointMap(points)
+		return &KeygenResult{
+			Public: common.MarshalPanic(conf.PublicPoint()),
+			Share:  common.MarshalPanic(conf),
+			SSID:   ssid,
+		}, nil
+	default:
+		panic(crv)
+	}
+}
+
+func (node *Node) refreshLoop(ctx context.Context, sessionId []byte, group curve.Curve, threshold int, public string, verifications map[party.ID]curve.Point, aux bool) (*refreshResult, []byte, error) {
+	logger.Printf("node.refreshLoop(%x, %s)", sessionId, public)
+	participants := make([]party.ID, 0, len(verifications))
+	for id := range verifications {
+		participants = append(participants, id)
+	}
+	start, err := startRefreshSession(group, node.id, participants, threshold, sessionId, common.DecodeHexOrPanic(public), aux)
+	if err != nil {
+		return nil, nil, fmt.Errorf("startRefreshSession(%x) => %v", sessionId, err)
+	}
+
+	result, err := node.handlerLoop(ctx, start, sessionId, refreshRoundTimeout)
+	if err != nil {
+		return nil, nil, fmt.Errorf("node.handlerLoop(%x) => %v", sessionId, err)
+	}
+	res := result.(*refreshResult)
+	for id := range verifications {
+		if res.VerificationShares[id] == nil {
+			return nil, nil, fmt.Errorf("node.refreshLoop(%x) missing %s", sessionId, id)
+		}
+		if aux && res.Aux[id] == nil {
+			return nil, nil, fmt.Errorf("node.refreshLoop(%x) missing aux %s", sessionId, id)
+		}
+	}
+	if !res.Share.ActOnBase().Equal(res.VerificationShares[node.id]) {
+		return nil, nil, fmt.Errorf("node.refreshLoop(%x) invalid share", sessionId)
+	}
+	return res, start.SSID(), nil
+}
+
+func startRefreshSession(group curve.Curve, selfID party.ID, participants []party.ID, threshold int, sessionId, public []byte, aux bool) (round.Session, error) {
+	info := round.Info{
+		ProtocolID:       refreshProtocolId,
+		FinalRoundNumber: refreshRounds,
+		SelfID:           selfID,
+		PartyIDs:         participants,
+		Threshold:        threshold,
+		Group:            group,
+	}
+	helper, err := round.NewSession(info, sessionId, nil, &hash.BytesWithDomain{
+		TheDomain: "Public Key",
+		Bytes:     public,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &refreshRound1{Helper: helper, threshold: threshold, aux: aux}, nil
+}
+
+func (r *refreshRound1) VerifyMessage(round.Message) error { return nil }
+
+func (r *refreshRound1) StoreMessage(round.Message) error { return nil }
+
+func (r *refreshRound1) Finalize(out chan<- *round.Message) (round.Session, error) {
+	f := polynomial.NewPolynomial(r.Group(), r.threshold, r.Group().NewScalar())
+	phi := polynomial.NewPolynomialExponent(f)
+	next := &refreshRound2{
+		refreshRound1: r,
+		phi:           map[party.ID]*polynomial.Exponent{r.SelfID(): phi},
+		shareFrom:     map[party.ID]curve.Scalar{r.SelfID(): f.Evaluate(r.SelfID().Scalar(r.Group()))},
+	}
+	msg := &refreshBroadcast2{Phi: phi}
+	if r.aux {
+		next.sampleAux(msg)
+	}
+	err := r.BroadcastMessage(out, msg)
+	if err != nil {
+		return r, err
+	}
+	for _, id := range r.OtherPartyIDs() {
+		share := f.Evaluate(id.Scalar(r.Group()))
+		err := r.SendMessage(out, &refreshMessage2{Share: share}, id)
+		if err != nil {
+			return r, err
+		}
+	}
+	return next, nil
+}
+
+// sample the new paillier and pedersen parameters with the proofs of
+// the modulus and the parameters, and the new elgamal key, as cmp keygen
+func (r *refreshRound2) sampleAux(msg *refreshBroadcast2) {
+	r.paillier = paillier.NewSecretKey(r.Pool)
+	ped, lambda := r.paillier.GeneratePedersen()
+	var elgamal curve.Point
+	r.elgamal, elgamal = sample.ScalarPointPair(rand.Reader, r.Group())
+	r.public = map[party.ID]*config.Public{r.SelfID(): {
+		ElGamal:  elgamal,
+		Paillier: r.paillier.PublicKey,
+		Pedersen: ped,
+	}}
+
+	h := r.HashForID(r.SelfID())
+	p, q, phi := r.paillier.P(), r.paillier.Q(), r.paillier.Phi()
+	msg.ElGamal = elgamal
+	msg.N, msg.S, msg.T = ped.N(), ped.S(), ped.T()
+	msg.Mod = zkmod.NewProof(h.Clone(), zkmod.Private{P: p, Q: q, Phi: phi}, zkmod.Public{N: ped.N()}, r.Pool)
+	msg.Prm = zkprm.NewProof(zkprm.Private{Lambda: lambda, Phi: phi, P: p, Q: q}, h.Clone(), zkprm.Public{N: ped.N(), S: ped.S(), T: ped.T()}, r.Pool)
+	msg.Fac = zkfac.NewProof(zkfac.Private{P: p, Q: q}, h.Clone(), zkfac.Public{
+		Aux: pedersen.New(arith.ModulusFromFactors(p, q), ped.S(), ped.T()),
+	})
+}
+
+func (refreshRound1) MessageContent() round.Content { return nil }
+
+func (refreshRound1) Number() round.Number { return 1 }
+
+func (r *refreshRound2) StoreBroadcastMessage(msg round.Message) error {
+	body, ok := msg.Content.(*refreshBroadcast2)
+	if !ok || body == nil || body.Phi == nil {
+		return round.ErrInvalidContent
+	}
+	// the constant must be zero, otherwise the public key changes
+	if !body.Phi.IsConstant || body.Phi.Degree() != r.threshold {
+		return fmt.Errorf("invalid refresh polynomial from %s", msg.From)
+	}
+	if r.aux {
+		err := r.verifyAux(msg.From, body)
+		if err != nil {
+			return err
+		}
+	}
+	r.phi[msg.From] = body.Phi
+	return nil
+}
+
+func (r *refreshRound2) verifyAux(from party.ID, body *refreshBroadcast2) error {
+	if body.ElGamal == nil || body.N == nil || body.S == nil || body.T == nil {
+		return round.ErrNilFields
+	}
+	if body.Mod == nil || body.Prm == nil || body.Fac == nil {
+		return round.ErrNilFields
+	}
+	if body.ElGamal.IsIdentity() {
+		return fmt.Errorf("invalid refresh elgamal from %s", from)
+	}
+	err := paillier.ValidateN(body.N)
+	if err != nil {
+		return err
+	}
+	err = pedersen.ValidateParameters(body.N, body.S, body.T)
+	if err != nil {
+		return err
+	}
+	if !body.Mod.Verify(zkmod.Public{N: body.N}, r.HashForID(from), r.Pool) {
+		return fmt.Errorf("failed to validate mod proof from %s", from)
+	}
+	if !body.Prm.Verify(zkprm.Public{N: body.N, S: body.S, T: body.T}, r.HashForID(from), r.Pool) {
+		return fmt.Errorf("failed to validate prm proof from %s", from)
+	}
+	aux := pedersen.New(arith.ModulusFromN(body.N), body.S, body.T)
+	if !body.Fac.Verify(zkfac.Public{Aux: aux}, r.HashForID(from)) {
+		return fmt.Errorf("failed to validate fac proof from %s", from)
+	}
+	pk := paillier.NewPublicKey(body.N)
+	r.public[from] = &config.Public{
+		ElGamal:  body.ElGamal,
+		Paillier: pk,
+		Pedersen: pedersen.New(pk.Modulus(), body.S, body.T),
+	}
+	return nil
+}
+
+func (r *refreshRound2) VerifyMessage(msg round.Message) error {
+	body, ok := msg.Content.(*refreshMessage2)
+	if !ok || body == nil {
+		return round.ErrInvalidContent
+	}
+	if body.Share == nil {
+		return round.ErrNilFields
+	}
+	return nil
+}
+
+func (r *refreshRound2) StoreMessage(msg round.Message) error {
+	body := msg.Content.(*refreshMessage2)
+	expected := r.phi[msg.From].Evaluate(r.SelfID().Scalar(r.Group()))
+	if !body.Share.ActOnBase().Equal(expected) {
+		return fmt.Errorf("VSS failed to validate from %s", msg.From)
+	}
+	r.shareFrom[msg.From] = body.Share
+	return nil
+}
+
+func (r *refreshRound2) Finalize(chan<- *round.Message) (round.Session, error) {
+	share := r.Group().NewScalar()
+	for _, s := range r.shareFrom {
+		share.Add(s)
+	}
+	exponents := make([]*polynomial.Exponent, 0, len(r.phi))
+	for _, phi := range r.phi {
+		exponents = append(exponents, phi)
+	}
+	sum, err := polynomial.Sum(exponents)
+	if err != nil {
+		return r, err
+	}
+	verifications := make(map[party.ID]curve.Point, r.N())
+	for _, id := range r.PartyIDs() {
+		verifications[id] = sum.Evaluate(id.Scalar(r.Group()))
+	}
+	return r.ResultRound(&refreshResult{
+		Share:              share,
+		VerificationShares: verifications,
+		ElGamal:            r.elgamal,
+		Paillier:           r.paillier,
+		Aux:                r.public,
+	}), nil
+}
+
+func (r *refreshRound2) BroadcastContent() round.BroadcastContent {
+	msg := &refreshBroadcast2{Phi: polynomial.EmptyExponent(r.Group())}
+	if r.aux {
+		msg.ElGamal = r.Group().NewPoint()
+	}
+	return msg
+}
+
+func (r *refreshRound2) MessageContent() round.Content {
+	return &refreshMessage2{Share: r.Group().NewScalar()}
+}
+
+func (refreshRound2) Number() round.Number { return 2 }
+
+func (refreshBroadcast2) RoundNumber() round.Number { return 2 }
+
+func (refreshMessage2) RoundNumber() round.Number { return 2 }
+
+// the new share waits for the cut-over, and the session turns pending to send
+// the refresh result to the mtg
+func (s *SQLite3Store) WriteRefreshShare(ctx context.Context, sessionId string, public string, old, conf []byte, saved bool) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var current string
+	row := tx.QueryRowContext(ctx, "SELECT share FROM keys WHERE public=?", public)
+	err = row.Scan(&current)
+	if err != nil {
+		return err
+	}
+	plain, err := s.decodeShare(ctx, public, current)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(plain, old) {
+		return fmt.Errorf("SQLite3Store INSERT refreshes %s share changed", public)
+	}
+	share, err := s.encodeShare(ctx, public, conf)
+	if err != nil {
+		return err
+	}
+
+	timestamp := time.Now().UTC()
+	var backedUpAt any
+	if saved {
+		backedUpAt = timestamp
+	}
+	cols := []string{"session_id", "public", "old_share", "share", "cutover", "created_at", "updated_at", "backed_up_at"}
+	err = s.execOne(ctx, tx, buildInsertionSQL("refreshes", cols),
+		sessionId, public, current, share, 0, timestamp, timestamp, backedUpAt)
+	if err != nil {
+		return fmt.Errorf("SQLite3Store INSERT refreshes %v", err)
+	}
+
+	err = s.execOne(ctx, tx, "UPDATE sessions SET state=?, updated_at=? WHERE session_id=? AND created_at=updated_at AND state=?",
+		common.RequestStatePending, timestamp, sessionId, common.RequestStateInitial)
+	if err != nil {
+		return fmt.Errorf("SQLite3Store UPDATE sessions %v", err)
+	}
+
+	return tx.Commit()
+}
+
+// the cut-over happens when all members have confirmed the refresh in the mtg,
+// and the old share is kept for the sign sessions requested before it
+func (s *SQLite3Store) CutoverRefresh(ctx context.Context, sessionId string, sequence uint64) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var cutover uint64
+	var public, old, share string
+	var backedUpAt sql.NullTime
+	row := tx.QueryRowContext(ctx, "SELECT public, old_share, share, cutover, backed_up_at FROM refreshes WHERE session_id=?", sessionId)
+	err = row.Scan(&public, &old, &share, &cutover, &backedUpAt)
+	if err == sql.ErrNoRows {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	if cutover > 0 {
+		return cutover == sequence, nil
+	}
+
+	timestamp := time.Now().UTC()
+	var backed any
+	if backedUpAt.Valid {
+		backed = backedUpAt.Time
+	}
+	err = s.execOne(ctx, tx, "UPDATE keys SET share=?, backed_up_at=? WHERE public=? AND share=?",
+		share, backed, public, old)
+	if err != nil {
+		return false, fmt.Errorf("SQLite3Store UPDATE keys %v", err)
+	}
+	err = s.execOne(ctx, tx, "UPDATE refreshes SET cutover=?, updated_at=? WHERE session_id=? AND cutover=0",
+		sequence, timestamp, sessionId)
+	if err != nil {
+		return false, fmt.Errorf("SQLite3Store UPDATE refreshes %v", err)
+	}
+
+	return true, tx.Commit()
+}
+
+// the sign sessions requested before a refresh cut-over use the old share,
+// because some members may have started them with the old share already
+func (s *SQLite3Store) ReadKeyByFingerprintAt(ctx context.Context, sum string, sequence uint64) (string, uint8, []byte, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var curve uint8
+	var public, share string
+	row := s.db.QueryRowContext(ctx, "SELECT public, curve, share FROM keys WHERE fingerprint=?", sum)
+	err := row.Scan(&public, &curve, &share)
+	if err == sql.ErrNoRows {
+		return "", 0, nil, nil
+	} else if err != nil {
+		return "", 0, nil, err
+	}
+
+	query := "SELECT old_share FROM refreshes WHERE public=? AND cutover>? ORDER BY cutover ASC LIMIT 1"
+	row = s.db.QueryRowContext(ctx, query, public, sequence)
+	err = row.Scan(&share)
+	if err != nil && err != sql.ErrNoRows {
+		return "", 0, nil, err
+	}
+	if share == "" {
+		return public, curve, nil, nil
+	}
+	conf, err := s.decodeShare(ctx, public, share)
+	return public, curve, conf, err
+}
+
+// the old shares are erased after all sign sessions requested before
+// the cut-over have expired
+func (s *SQLite3Store) EraseRefreshedShares(ctx context.Context, before time.Time) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, "UPDATE refreshes SET old_share='' WHERE cutover>0 AND old_share!='' AND updated_at<?", before)
+	if err != nil {
+		return fmt.Errorf("SQLite3Store UPDATE refreshes %v", err)
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,192 @@
+package signer
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/multi-party-sig/pkg/math/curve"
+	"github.com/MixinNetwork/multi-party-sig/protocols/cmp"
+	"github.com/MixinNetwork/safe/apps/bitcoin"
+	"github.com/MixinNetwork/safe/common"
+	"github.com/MixinNetwork/safe/saver"
+	"github.com/MixinNetwork/trusted-group/mtg"
+	"github.com/gofrs/uuid/v5"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRefresh(t *testing.T) {
+	require := require.New(t)
+	ctx, nodes, saverStore := TestPrepare(require)
+
+	public, _ := testCMPKeyGen(ctx, require, nodes, common.CurveSecp256k1ECDSABitcoin)
+	testRefresh(ctx, require, nodes, saverStore, public, common.CurveSecp256k1ECDSABitcoin)
+	sig := testCMPSign(ctx, require, nodes, public, []byte("refresh"), common.CurveSecp256k1ECDSABitcoin)
+	err := bitcoin.VerifySignatureDER(public, []byte("refresh"), sig)
+	require.Nil(err)
+}
+
+func testRefresh(ctx context.Context, require *require.Assertions, nodes []*Node, saverStore *saver.SQLite3Store, public string, crv uint8) {
+	fingerprint := hex.EncodeToString(common.Fingerprint(public))
+	shares := make([][]byte, len(nodes))
+	for i, node := range nodes {
+		_, _, share, err := node.store.ReadKeyByFingerprint(ctx, fingerprint)
+		require.Nil(err)
+		shares[i] = share
+	}
+
+	node := nodes[0]
+	sid := common.UniqueId("refresh", fmt.Sprint(crv))
+	op := &common.Operation{
+		Type:   common.OperationTypeRefreshInput,
+		Id:     sid,
+		Curve:  crv,
+		Public: public,
+	}
+	memo := mtg.EncodeMixinExtraBase64(node.conf.AppId, node.encryptOperation(op))
+	memo = hex.EncodeToString([]byte(memo))
+	out := &mtg.Action{
+		UnifiedOutput: mtg.UnifiedOutput{
+			OutputId:        uuid.Must(uuid.NewV4()).String(),
+			TransactionHash: crypto.Sha256Hash([]byte(op.Id)).String(),
+			AppId:           node.conf.AppId,
+			AssetId:         node.conf.KeeperAssetId,
+			Extra:           memo,
+			Amount:          decimal.NewFromInt(1),
+			CreatedAt:       time.Now(),
+		},
+	}
+	op = TestProcessOutput(ctx, require, nodes, out, sid)
+	require.Equal(common.OperationTypeRefreshOutput, int(op.Type))
+	require.Equal(sid, op.Id)
+	require.Equal(crv, op.Curve)
+	require.Equal(public, op.Public)
+
+	for i, node := range nodes {
+		holder, _, share, err := node.store.ReadKeyByFingerprint(ctx, fingerprint)
+		require.Nil(err)
+		require.Equal(public, holder)
+		require.False(bytes.Equal(shares[i], share))
+		pub, _ := node.deriveByPath(ctx, crv, share, []byte{0, 0, 0, 0})
+		require.Equal(public, hex.EncodeToString(pub))
+		if crv == common.CurveSecp256k1ECDSABitcoin || crv == common.CurveSecp256k1ECDSAEthereum {
+			old, conf := cmp.EmptyConfig(curve.Secp256k1{}), cmp.EmptyConfig(curve.Secp256k1{})
+			require.Nil(old.UnmarshalBinary(shares[i]))
+			require.Nil(conf.UnmarshalBinary(share))
+			require.False(old.ElGamal.Equal(conf.ElGamal))
+			require.NotEqual(old.Paillier.N().String(), conf.Paillier.N().String())
+			for id, p := range conf.Public {
+				require.False(old.Public[id].ElGamal.Equal(p.ElGamal))
+				require.NotEqual(old.Public[id].Pedersen.S().String(), p.Pedersen.S().String())
+				require.Equal(p.Paillier.N().String(), p.Pedersen.N().String())
+			}
+		}
+
+		items, err := saverStore.ListItemsForNode(ctx, string(node.id))
+		require.Nil(err)
+		var backed bool
+		for _, item := range items {
+			var body struct {
+				SessionId string `json:"session_id"`
+				Share     string `json:"share"`
+			}
+			err = json.Unmarshal([]byte(item.Data), &body)
+			require.Nil(err)
+			if body.SessionId != sid {
+				continue
+			}
+			secret := crypto.Sha256Hash([]byte(node.saverKey.String() + item.Id))
+			secret = crypto.Sha256Hash(secret[:])
+			rb, err := base64.RawURLEncoding.DecodeString(body.Share)
+			require.Nil(err)
+			rb = common.AESDecrypt(secret[:], rb)
+			require.True(bytes.Equal(rb[16:], share))
+			backed = true
+		}
+		require.True(backed)
+	}
+}
+
+func TestRefreshCutover(t *testing.T) {
+	require := require.New(t)
+	ctx := common.EnableTestEnvironment(context.Background())
+
+	dir, err := os.MkdirTemp("", "safe-refresh-test-")
+	require.Nil(err)
+	store, err := OpenSQLite3Store(dir + "/mpc.sqlite3")
+	require.Nil(err)
+	defer store.Close()
+
+	seed := crypto.Sha256Hash([]byte("refresh-cutover"))
+	public := crypto.NewKeyFromSeed(append(seed[:], seed[:]...)).Public().String()
+	fingerprint := hex.EncodeToString(common.Fingerprint(public))
+	_, err = store.db.ExecContext(ctx, buildInsertionSQL("keys", []string{"public", "fingerprint", "curve", "share", "session_id", "created_at", "backed_up_at"}),
+		public, fingerprint, common.CurveEdwards25519Default, common.Base91Encode([]byte("old-share")), uuid.Must(uuid.NewV4()).String(), time.Now(), time.Now())
+	require.Nil(err)
+
+	op := &common.Operation{
+		Id:     uuid.Must(uuid.NewV4()).String(),
+		Type:   common.OperationTypeRefreshInput,
+		Curve:  common.CurveEdwards25519Default,
+		Public: public,
+	}
+	err = store.WriteSessionIfNotExist(ctx, op, crypto.Sha256Hash([]byte(op.Id)), 0, 5, time.Now(), false)
+	require.Nil(err)
+	err = store.WriteRefreshShare(ctx, op.Id, public, []byte("old-share"), []byte("new-share"), true)
+	require.Nil(err)
+	session, err := store.ReadSession(ctx, op.Id)
+	require.Nil(err)
+	require.Equal(byte(common.RequestStatePending), session.State)
+
+	// the new share is unused until all members confirm the refresh
+	_, _, share, err := store.ReadKeyByFingerprint(ctx, fingerprint)
+	require.Nil(err)
+	require.Equal([]byte("old-share"), share)
+	cutover, err := store.CutoverRefresh(ctx, uuid.Must(uuid.NewV4()).String(), 10)
+	require.Nil(err)
+	require.False(cutover)
+
+	cutover, err = store.CutoverRefresh(ctx, op.Id, 10)
+	require.Nil(err)
+	require.True(cutover)
+	cutover, err = store.CutoverRefresh(ctx, op.Id, 10)
+	require.Nil(err)
+	require.True(cutover)
+	cutover, err = store.CutoverRefresh(ctx, op.Id, 11)
+	require.Nil(err)
+	require.False(cutover)
+	_, _, share, err = store.ReadKeyByFingerprint(ctx, fingerprint)
+	require.Nil(err)
+	require.Equal([]byte("new-share"), share)
+
+	// the sign sessions requested before the cut-over keep the old share
+	_, _, share, err = store.ReadKeyByFingerprintAt(ctx, fingerprint, 9)
+	require.Nil(err)
+	require.Equal([]byte("old-share"), share)
+	_, _, share, err = store.ReadKeyByFingerprintAt(ctx, fingerprint, 10)
+	require.Nil(err)
+	require.Equal([]byte("new-share"), share)
+
+	err = store.EraseRefreshedShares(ctx, time.Now().Add(-time.Hour))
+	require.Nil(err)
+	_, _, share, err = store.ReadKeyByFingerprintAt(ctx, fingerprint, 9)
+	require.Nil(err)
+	require.Equal([]byte("old-share"), share)
+	err = store.EraseRefreshedShares(ctx, time.Now().Add(time.Second))
+	require.Nil(err)
+	holder, _, share, err := store.ReadKeyByFingerprintAt(ctx, fingerprint, 9)
+	require.Nil(err)
+	require.Equal(public, holder)
+	require.Nil(share)
+	_, _, share, err = store.ReadKeyByFingerprintAt(ctx, fingerprint, 10)
+	require.Nil(err)
+	require.Equal([]byte("new-share"), share)
+}
@@ -0,0 +1,1053 @@
+package signer
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/logger"
+	"github.com/MixinNetwork/multi-party-sig/common/round"
+	"github.com/MixinNetwork/multi-party-sig/common/types"
+	"github.com/MixinNetwork/multi-party-sig/pkg/hash"
+	"github.com/MixinNetwork/multi-party-sig/pkg/math/arith"
+	"github.com/MixinNetwork/multi-party-sig/pkg/math/curve"
+	"github.com/MixinNetwork/multi-party-sig/pkg/math/polynomial"
+	"github.com/MixinNetwork/multi-party-sig/pkg/math/sample"
+	"github.com/MixinNetwork/multi-party-sig/pkg/paillier"
+	"github.com/MixinNetwork/multi-party-sig/pkg/party"
+	"github.com/MixinNetwork/multi-party-sig/pkg/pedersen"
+	zkfac "github.com/MixinNetwork/multi-party-sig/pkg/zk/fac"
+	zkmod "github.com/MixinNetwork/multi-party-sig/pkg/zk/mod"
+	zkprm "github.com/MixinNetwork/multi-party-sig/pkg/zk/prm"
+	"github.com/MixinNetwork/multi-party-sig/protocols/cmp"
+	"github.com/MixinNetwork/multi-party-sig/protocols/cmp/config"
+	"github.com/MixinNetwork/multi-party-sig/protocols/frost"
+	"github.com/MixinNetwork/safe/common"
+	"github.com/MixinNetwork/trusted-group/mtg"
+	"github.com/cronokirby/saferith"
+	"github.com/gofrs/uuid/v5"
+)
+
+const (
+	reshareRoundTimeout = 5 * time.Minute
+	reshareProtocolId   = "safe/reshare-threshold"
+	reshareRounds       = round.Number(2)
+)
+
+// PartySet is the members and threshold holding the shares of all keys, it
+// is the mtg genesis members and the configured threshold until a reshare
+// cuts over to a new set, and all sessions after the cut-over sequence use it
+type PartySet struct {
+	Members   party.IDSlice
+	Threshold int
+}
+
+type Reshare struct {
+	Id           string
+	OldMembers   party.IDSlice
+	OldThreshold int
+	Members      party.IDSlice
+	Threshold    int
+	State        int
+	Sequence     uint64
+	Cutover      uint64
+	CreatedAt    time.Time
+}
+
+type ReshareKey struct {
+	ReshareId    string
+	Public       string
+	Curve        byte
+	SessionId    string
+	Verification string
+	Share        string
+}
+
+func (r *Reshare) participants() party.IDSlice {
+	return reshareParticipants(r.OldMembers, r.Members)
+}
+
+func reshareParticipants(dealers, members party.IDSlice) party.IDSlice {
+	ids := slices.Clone(dealers)
+	for _, id := range members {
+		if !ids.Contains(id) {
+			ids = append(ids, id)
+		}
+	}
+	return party.NewIDSlice(ids)
+}
+
+// the reshare extra is the old and new thresholds, followed by two bitmaps of
+// the sorted mtg members, the first one for the old members dealing their shares,
+// they may be a subset of the current party set to replace offline members
+func EncodeReshareExtra(members party.IDSlice, old, next *PartySet) []byte {
+	size := (len(members) + 7) / 8
+	extra := make([]byte, 2+size*2)
+	extra[0], extra[1] = byte(old.Threshold), byte(next.Threshold)
+	for i, id := range members {
+		if old.Members.Contains(id) {
+			extra[2+i/8] |= 1 << (i % 8)
+		}
+		if next.Members.Contains(id) {
+			extra[2+size+i/8] |= 1 << (i % 8)
+		}
+	}
+	return extra
+}
+
+func decodeReshareExtra(members party.IDSlice, extra []byte) (*PartySet, *PartySet, error) {
+	size := (len(members) + 7) / 8
+	if len(extra) != 2+size*2 {
+		return nil, nil, fmt.Errorf("invalid reshare extra size %d", len(extra))
+	}
+	old := &PartySet{Threshold: int(extra[0])}
+	next := &PartySet{Threshold: int(extra[1])}
+	for i, id := range members {
+		if extra[2+i/8]&(1<<(i%8)) != 0 {
+			old.Members = append(old.Members, id)
+		}
+		if extra[2+size+i/8]&(1<<(i%8)) != 0 {
+			next.Members = append(next.Members, id)
+		}
+	}
+	if !bytes.Equal(EncodeReshareExtra(members, old, next), extra) {
+		return nil, nil, fmt.Errorf("invalid reshare extra %x", extra)
+	}
+	if next.Threshold < 1 || len(next.Members) <= next.Threshold {
+		return nil, nil, fmt.Errorf("invalid reshare members %d/%d", next.Threshold, len(next.Members))
+	}
+	return old, next, nil
+}
+
+func (node *Node) readPartySet(ctx context.Context, sequence uint64) *PartySet {
+	set, err := node.store.ReadPartySet(ctx, sequence)
+	if err != nil {
+		panic(err)
+	}
+	if set != nil {
+		return set
+	}
+	return &PartySet{Members: node.members, Threshold: node.threshold}
+}
+
+// no keygen or refresh is allowed while a reshare is pending, because their keys
+// would be left to the old party set, and a reshare must be dealt by at least
+// threshold + 1 members of the current party set with the same threshold
+func (node *Node) checkPartySet(ctx context.Context, op *common.Operation, out *mtg.Action) error {
+	switch op.Type {
+	case common.OperationTypeKeygenInput, common.OperationTypeRefreshInput:
+		pending, err := node.store.CheckPendingReshare(ctx)
+		if err != nil {
+			panic(err)
+		}
+		if pending {
+			return fmt.Errorf("node.checkPartySet(%v) reshare pending", op)
+		}
+	case common.OperationTypeReshareInput:
+		old, _, err := decodeReshareExtra(node.members, op.Extra)
+		if err != nil {
+			return err
+		}
+		set := node.readPartySet(ctx, out.Sequence)
+		if old.Threshold != set.Threshold || len(old.Members) <= set.Threshold {
+			return fmt.Errorf("node.checkPartySet(%v) threshold %d %d", op, old.Threshold, set.Threshold)
+		}
+		if !set.Members.Contains(old.Members...) {
+			return fmt.Errorf("node.checkPartySet(%v) members %v %v", op, old.Members, set.Members)
+		}
+	}
+	return nil
+}
+
+// all keys confirmed by the mtg are reshared, and a pending reshare is
+// superseded by the new one, so a failed reshare never blocks the group
+func (node *Node) processReshareRequest(ctx context.Context, op *common.Operation, out *mtg.Action, transaction crypto.Hash) error {
+	old, next, err := decodeReshareExtra(node.members, op.Extra)
+	if err != nil {
+		panic(err)
+	}
+	candidates, err := node.store.ListKeygenResults(ctx)
+	if err != nil {
+		return fmt.Errorf("store.ListKeygenResults() => %v", err)
+	}
+	var keys []*Key
+	for _, k := range candidates {
+		if node.store.CheckActionResultsBySessionId(ctx, k.SessionId) {
+			keys = append(keys, k)
+		}
+	}
+	logger.Printf("node.processReshareRequest(%v, %v, %v) => %d", op, old, next, len(keys))
+	return node.store.WriteReshareIfNotExist(ctx, op, old, next, keys, transaction, out.OutputIndex, out.Sequence, out.CreatedAt)
+}
+
+func (node *Node) startReshare(ctx context.Context, op *common.Operation) error {
+	logger.Printf("node.startReshare(%v)", op)
+	r, err := node.store.ReadReshare(ctx, op.Id)
+	if err != nil {
+		return fmt.Errorf("store.ReadReshare(%s) => %v", op.Id, err)
+	}
+	if !r.participants().Contains(node.id) {
+		logger.Printf("node.startReshare(%v, %v) exit without participation\n", op, r.participants())
+		return nil
+	}
+	keys, err := node.store.ListReshareKeys(ctx, r.Id)
+	if err != nil {
+		return fmt.Errorf("store.ListReshareKeys(%s) => %v", r.Id, err)
+	}
+
+	for _, k := range keys {
+		if k.Verification != "" {
+			continue
+		}
+		sid := common.UniqueId(r.Id, k.Public)
+		res, verification, err := node.reshareShare(ctx, r, k, uuid.Must(uuid.FromString(sid)).Bytes())
+		logger.Printf("node.reshareShare(%v, %s) => %x %v", r, k.Public, verification, err)
+		if err != nil {
+			return node.store.FailSession(ctx, op.Id)
+		}
+		var saved bool
+		if res.Share != nil {
+			kop := &common.Operation{Id: sid, Type: common.OperationTypeReshareInput, Curve: k.Curve, Public: k.Public}
+			saved, err = node.sendKeygenBackup(ctx, kop, res.Share)
+			logger.Printf("node.sendKeygenBackup(%v, %d) => %t %v", kop, len(res.Share), saved, err)
+			if err != nil {
+				err = node.store.FailSession(ctx, op.Id)
+				logger.Printf("store.FailSession(%s, startReshare) => %v", op.Id, err)
+				return err
+			}
+		}
+		err = node.store.WriteReshareKeyResult(ctx, r.Id, k.Public, verification, res.Share, saved)
+		if err != nil {
+			return fmt.Errorf("store.WriteReshareKeyResult(%s, %s) => %v", r.Id, k.Public, err)
+		}
+		k.Verification = hex.EncodeToString(verification)
+	}
+
+	// all participants must agree on the new verification shares of all keys
+	var digest []byte
+	for _, k := range keys {
+		digest = append(digest, common.DecodeHexOrPanic(k.Verification)...)
+	}
+	sum := crypto.Sha256Hash(digest)
+	return node.store.MarkReshareSessionPending(ctx, op.Id, hex.EncodeToString(sum[:]))
+}
+
+func (node *Node) reshareShare(ctx context.Context, r *Reshare, k *ReshareKey, sessionId []byte) (*KeygenResult, []byte, error) {
+	var dealer *reshareDealer
+	if r.OldMembers.Contains(node.id) {
+		public, crv, share, err := node.store.ReadKeyByFingerprint(ctx, hex.EncodeToString(common.Fingerprint(k.Public)))
+		if err != nil {
+			return nil, nil, fmt.Errorf("store.ReadKeyByFingerprint(%s) => %v", k.Public, err)
+		}
+		if public != k.Public || crv != k.Curve {
+			return nil, nil, fmt.Errorf("node.reshareShare(%s) missing key %s", k.Public, public)
+		}
+		dealer = &reshareDealer{share: share}
+	}
+
+	switch k.Curve {
+	case common.CurveSecp256k1ECDSABitcoin, common.CurveSecp256k1ECDSAEthereum:
+		group := curve.Secp256k1{}
+		point := group.NewPoint()
+		err := point.UnmarshalBinary(common.DecodeHexOrPanic(k.Public))
+		if err != nil {
+			panic(err)
+		}
+		if dealer != nil {
+			conf := cmp.EmptyConfig(group)
+			err := conf.UnmarshalBinary(dealer.share)
+			if err != nil {
+				panic(err)
+			}
+			dealer.threshold, dealer.secret = conf.Threshold, conf.ECDSA
+			dealer.chainKey, dealer.rid = conf.ChainKey, conf.RID
+			dealer.verifications = make(map[party.ID]curve.Point, len(conf.Public))
+			for id, p := range conf.Public {
+				dealer.verifications[id] = p.ECDSA
+			}
+		}
+		res, ssid, err := node.reshareLoop(ctx, sessionId, group, r, point, dealer, true)
+		if err != nil {
+			return nil, nil, err
+		} else if res.Share == nil {
+			return &KeygenResult{SSID: ssid}, res.digest(), nil
+		}
+		public := make(map[party.ID]*config.Public, len(r.Members))
+		for _, id := range r.Members {
+			aux := res.Aux[id]
+			pk := paillier.NewPublicKey(aux.N)
+			public[id] = &config.Public{
+				ECDSA:    res.VerificationShares[id],
+				ElGamal:  aux.elgamal,
+				Paillier: pk,
+				Pedersen: pedersen.New(pk.Modulus(), aux.S, aux.T),
+			}
+		}
+		conf := &cmp.Config{
+			Group:     group,
+			ID:        node.id,
+			Threshold: r.Threshold,
+			ECDSA:     res.Share,
+			ElGamal:   res.ElGamal,
+			Paillier:  res.Paillier,
+			RID:       res.RID,
+			ChainKey:  res.ChainKey,
+			Public:    public,
+		}
+		if !conf.PublicPoint().Equal(point) {
+			return nil, nil, fmt.Errorf("node.reshareShare(%s) invalid public", k.Public)
+		}
+		return &KeygenResult{
+			Public: common.MarshalPanic(conf.PublicPoint()),
+			Share:  common.MarshalPanic(conf),
+			SSID:   ssid,
+		}, res.digest(), nil
+	case common.CurveSecp256k1SchnorrBitcoin:
+		group := curve.Secp256k1{}
+		point, err := group.LiftX(common.DecodeHexOrPanic(k.Public))
+		if err != nil {
+			panic(err)
+		}
+		if dealer != nil {
+			conf := &frost.TaprootConfig{PrivateShare: group.NewScalar()}
+			err := conf.UnmarshalBinary(dealer.share)
+			if err != nil {
+				panic(err)
+			}
+			dealer.threshold, dealer.secret = conf.Threshold, conf.PrivateShare
+			dealer.chainKey, dealer.verifications = conf.ChainKey, conf.VerificationShares
+		}
+		res, ssid, err := node.reshareLoop(ctx, sessionId, group, r, point, dealer, false)
+		if err != nil {
+			return nil, nil, err
+		} else if res.Share == nil {
+			return &KeygenResult{SSID: ssid}, res.digest(), nil
+		}
+		conf := &frost.TaprootConfig{
+			ID:                 node.id,
+			Threshold:          r.Threshold,
+			PrivateShare:       res.Share,
+			PublicKey:          common.DecodeHexOrPanic(k.Public),
+			ChainKey:           res.ChainKey,
+			VerificationShares: res.VerificationShares,
+		}
+		return &KeygenResult{
+			Public: conf.PublicKey,
+			Share:  common.MarshalPanic(conf),
+			SSID:   ssid,
+		}, res.digest(), nil
+	case common.CurveEdwards25519Mixin, common.CurveEdwards25519Default:
+		group := curve.Edwards25519{}
+		point := group.NewPoint()
+		err := point.UnmarshalBinary(common.DecodeHexOrPanic(k.Public))
+		if err != nil {
+			panic(err)
+		}
+		if dealer != nil {
+			conf := frost.EmptyConfig(group)
+			err := conf.UnmarshalBinary(dealer.share)
+			if err != nil {
+				panic(err)
+			}
+			dealer.threshold, dealer.secret = conf.Threshold, conf.PrivateShare
+			dealer.chainKey, dealer.verifications = conf.ChainKey, conf.VerificationShares.Points
+		}
+		res, ssid, err := node.reshareLoop(ctx, sessionId, group, r, point, dealer, false)
+		if err != nil {
+			return nil, nil, err
+		} else if res.Share == nil {
+			return &KeygenResult{SSID: ssid}, res.digest(), nil
+		}
+		conf := &frost.Config{
+			ID:                 node.id,
+			Threshold:          r.Threshold,
+			PrivateShare:       res.Share,
+			PublicKey:          point,
+			ChainKey:           res.ChainKey,
+			VerificationShares: party.NewPointMap(res.VerificationShares),
+		}
+		return &KeygenResult{
+			Public: common.MarshalPanic(conf.PublicPoint()),
+			Share:  common.MarshalPanic(conf),
+			SSID:   ssid,
+		}, res.digest(), nil
+	default:
+		panic(k.Curve)
+	}
+}
+
+func (node *Node) reshareLoop(ctx context.Context, sessionId []byte, group curve.Curve, r *Reshare, public curve.Point, dealer *reshareDealer, aux bool) (*reshareResult, []byte, error) {
+	logger.Printf("node.reshareLoop(%x, %v)", sessionId, r)
+	if dealer != nil {
+		if dealer.threshold != r.OldThreshold {
+			return nil, nil, fmt.Errorf("node.reshareLoop(%x) threshold %d", sessionId, dealer.threshold)
+		}
+		for _, id := range r.OldMembers {
+			if dealer.verifications[id] == nil {
+				return nil, nil, fmt.Errorf("node.reshareLoop(%x) dealer %s", sessionId, id)
+			}
+		}
+		lambda := polynomial.Lagrange(group, r.OldMembers)
+		dealer.secret = group.NewScalar().Set(lambda[node.id]).Mul(dealer.secret)
+		dealer.lambda = lambda
+	}
+
+	start, err := startReshareSession(group, node.id, r, public, dealer, aux, sessionId)
+	if err != nil {
+		return nil, nil, fmt.Errorf("startReshareSession(%x) => %v", sessionId, err)
+	}
+	result, err := node.handlerLoop(ctx, start, sessionId, reshareRoundTimeout)
+	if err != nil {
+		return nil, nil, fmt.Errorf("node.handlerLoop(%x) => %v", sessionId, err)
+	}
+	res := result.(*reshareResult)
+	for _, id := range r.Members {
+		if res.VerificationShares[id] == nil {
+			return nil, nil, fmt.Errorf("node.reshareLoop(%x) missing %s", sessionId, id)
+		}
+	}
+	if res.Share != nil && !res.Share.ActOnBase().Equal(res.VerificationShares[node.id]) {
+		return nil, nil, fmt.Errorf("node.reshareLoop(%x) invalid share", sessionId)
+	}
+	return res, start.SSID(), nil
+}
+
+// every old member deals its Lagrange weighted share with a polynomial of
+// the new threshold degree, the sum of these polynomials is a new sharing of
+// the same secret among the new members, and all old shares become useless
+// to the new members once the reshare cuts over
+type reshareDealer struct {
+	share         []byte
+	threshold     int
+	secret        curve.Scalar
+	lambda        map[party.ID]curve.Scalar
+	verifications map[party.ID]curve.Point
+	chainKey      []byte
+	rid           []byte
+}
+
+type reshareResult struct {
+	Share              curve.Scalar
+	VerificationShares map[party.ID]curve.Point
+	ChainKey           []byte
+	RID                []byte
+	Aux                map[party.ID]*reshareAux
+	ElGamal            curve.Scalar
+	Paillier           *paillier.SecretKey
+}
+
+func (res *reshareResult) digest() []byte {
+	ids := make([]party.ID, 0, len(res.VerificationShares))
+	for id := range res.VerificationShares {
+		ids = append(ids, id)
+	}
+	var data []byte
+	for _, id := range party.NewIDSlice(ids) {
+		data = append(data, []byte(id)...)
+		data = append(data, common.MarshalPanic(res.VerificationShares[id])...)
+	}
+	data = append(data, res.ChainKey...)
+	sum := crypto.Sha256Hash(data)
+	return sum[:]
+}
+
+// the cmp config needs the paillier and pedersen parameters for all new members,
+// they are generated by the new members and proved the same way as in keygen
+type reshareAux struct {
+	ElGamal []byte
+	N       *saferith.Modulus
+	S       *saferith.Nat
+	T       *saferith.Nat
+	Mod     *zkmod.Proof
+	Prm     *zkprm.Proof
+	Fac     *zkfac.Proof
+
+	elgamal curve.Point
+}
+
+type reshareRound1 struct {
+	*round.Helper
+	dealers   party.IDSlice
+	members   party.IDSlice
+	threshold int
+	public    curve.Point
+	dealer    *reshareDealer
+	aux       bool
+}
+
+type reshareRound2 struct {
+	*reshareRound1
+	chainKey  []byte
+	rid       []byte
+	phi       map[party.ID]*polynomial.Exponent
+	shareFrom map[party.ID]curve.Scalar
+	auxFrom   map[party.ID]*reshareAux
+	elgamal   curve.Scalar
+	paillier  *paillier.SecretKey
+}
+
+type reshareBroadcast2 struct {
+	round.ReliableBroadcastContent
+	Phi      *polynomial.Exponent
+	ChainKey []byte
+	RID      []byte
+	Aux      *reshareAux
+}
+
+type reshareMessage2 struct {
+	Share curve.Scalar
+}
+
+func startReshareSession(group curve.Curve, selfID party.ID, r *Reshare, public curve.Point, dealer *reshareDealer, aux bool, sessionId []byte) (round.Session, error) {
+	info := round.Info{
+		ProtocolID:       reshareProtocolId,
+		FinalRoundNumber: reshareRounds,
+		SelfID:           selfID,
+		PartyIDs:         r.participants(),
+		Threshold:        r.Threshold,
+		Group:            group,
+	}
+	helper, err := round.NewSession(info, sessionId, nil, &hash.BytesWithDomain{
+		TheDomain: "Public Key",
+		Bytes:     common.MarshalPanic(public),
+	}, r.OldMembers, r.Members)
+	if err != nil {
+		return nil, err
+	}
+	return &reshareRound1{
+		Helper:    helper,
+		dealers:   r.OldMembers,
+		members:   r.Members,
+		threshold: r.Threshold,
+		public:    public,
+		dealer:    dealer,
+		aux:       aux,
+	}, nil
+}
+
+func (r *reshareRound1) VerifyMessage(round.Message) error { return nil }
+
+func (r *reshareRound1) StoreMessage(round.Message) error { return nil }
+
+func (r *reshareRound1) Finalize(out chan<- *round.Message) (round.Session, error) {
+	next := &reshareRound2{
+		reshareRound1: r,
+		phi:           make(map[party.ID]*polynomial.Exponent),
+		shareFrom:     make(map[party.ID]curve.Scalar),
+		auxFrom:       make(map[party.ID]*reshareAux),
+	}
+	body := &reshareBroadcast2{}
+
+	var f *polynomial.Polynomial
+	if r.dealer != nil {
+		f = polynomial.NewPolynomial(r.Group(), r.threshold, r.dealer.secret)
+		body.Phi = polynomial.NewPolynomialExponent(f)
+		body.ChainKey, body.RID = r.dealer.chainKey, r.dealer.rid
+		next.phi[r.SelfID()] = body.Phi
+		next.chainKey, next.rid = body.ChainKey, body.RID
+		if r.members.Contains(r.SelfID()) {
+			next.shareFrom[r.SelfID()] = f.Evaluate(r.SelfID().Scalar(r.Group()))
+		}
+	}
+	if r.aux && r.members.Contains(r.SelfID()) {
+		secret := paillier.NewSecretKey(nil)
+		ped, lambda := secret.GeneratePedersen()
+		elgamal, elgamalPublic := sample.ScalarPointPair(rand.Reader, r.Group())
+		priv := zkmod.Private{P: secret.P(), Q: secret.Q(), Phi: secret.Phi()}
+		body.Aux = &reshareAux{
+			ElGamal: common.MarshalPanic(elgamalPublic),
+			N:       ped.N(),
+			S:       ped.S(),
+			T:       ped.T(),
+			Mod:     zkmod.NewProof(r.HashForID(r.SelfID()), priv, zkmod.Public{N: ped.N()}, nil),
+			Prm: zkprm.NewProof(zkprm.Private{Lambda: lambda, Phi: secret.Phi(), P: secret.P(), Q: secret.Q()},
+				r.HashForID(r.SelfID()), zkprm.Public{N: ped.N(), S: ped.S(), T: ped.T()}, nil),
+			Fac: zkfac.NewProof(zkfac.Private{P: secret.P(), Q: secret.Q()}, r.HashForID(r.SelfID()), zkfac.Public{
+				Aux: pedersen.New(arith.ModulusFromFactors(secret.P(), secret.Q()), ped.S(), ped.T()),
+			}),
+			elgamal: elgamalPublic,
+		}
+		next.auxFrom[r.SelfID()] = body.Aux
+		next.elgamal, next.paillier = elgamal, secret
+	}
+
+	err := r.BroadcastMessage(out, body)
+	if err != nil {
+		return r, err
+	}
+	// the handler expects a message from every other party, so a zero share
+	// is sent when there is nothing to deal to the receiver
+	for _, id := range r.OtherPartyIDs() {
+		share := r.Group().NewScalar()
+		if f != nil && r.members.Contains(id) {
+			share = f.Evaluate(id.Scalar(r.Group()))
+		}
+		err := r.SendMessage(out, &reshareMessage2{Share: share}, id)
+		if err != nil {
+			return r, err
+		}
+	}
+	return next, nil
+}
+
+func (reshareRound1) MessageContent() round.Content { return nil }
+
+func (reshareRound1) Number() round.Number { return 1 }
+
+func (r *reshareRound2) StoreBroadcastMessage(msg round.Message) error {
+	body, ok := msg.Content.(*reshareBroadcast2)
+	if !ok || body == nil {
+		return round.ErrInvalidContent
+	}
+
+	if r.dealers.Contains(msg.From) {
+		if body.Phi == nil || body.Phi.IsConstant || body.Phi.Degree() != r.threshold {
+			return fmt.Errorf("invalid reshare polynomial from %s", msg.From)
+		}
+		// the old members know the old verification shares of the dealers
+		if r.dealer != nil {
+			expected := r.dealer.lambda[msg.From].Act(r.dealer.verifications[msg.From])
+			if !body.Phi.Constant().Equal(expected) {
+				return fmt.Errorf("invalid reshare constant from %s", msg.From)
+			}
+		}
+		if len(body.ChainKey) != len(types.EmptyRID()) {
+			return fmt.Errorf("invalid reshare chain key from %s", msg.From)
+		}
+		if r.aux && types.RID(body.RID).Validate() != nil {
+			return fmt.Errorf("invalid reshare rid from %s", msg.From)
+		}
+		if r.chainKey == nil {
+			r.chainKey, r.rid = body.ChainKey, body.RID
+		}
+		if !bytes.Equal(r.chainKey, body.ChainKey) || !bytes.Equal(r.rid, body.RID) {
+			return fmt.Errorf("inconsistent reshare chain key from %s", msg.From)
+		}
+		r.phi[msg.From] = body.Phi
+	} else if body.Phi != nil && body.Phi.Degree() >= 0 {
+		return fmt.Errorf("unexpected reshare polynomial from %s", msg.From)
+	}
+
+	if !r.aux || !r.members.Contains(msg.From) {
+		return nil
+	}
+	aux := body.Aux
+	if aux == nil || aux.N == nil || aux.S == nil || aux.T == nil || aux.Mod == nil || aux.Prm == nil || aux.Fac == nil {
+		return round.ErrNilFields
+	}
+	aux.elgamal = r.Group().NewPoint()
+	err := aux.elgamal.UnmarshalBinary(aux.ElGamal)
+	if err != nil || aux.elgamal.IsIdentity() {
+		return fmt.Errorf("invalid reshare elgamal from %s", msg.From)
+	}
+	if err := paillier.ValidateN(aux.N); err != nil {
+		return err
+	}
+	if err := pedersen.ValidateParameters(aux.N, aux.S, aux.T); err != nil {
+		return err
+	}
+	if !aux.Mod.Verify(zkmod.Public{N: aux.N}, r.HashForID(msg.From), nil) {
+		return fmt.Errorf("failed to validate mod proof from %s", msg.From)
+	}
+	if !aux.Prm.Verify(zkprm.Public{N: aux.N, S: aux.S, T: aux.T}, r.HashForID(msg.From), nil) {
+		return fmt.Errorf("failed to validate prm proof from %s", msg.From)
+	}
+	if !aux.Fac.Verify(zkfac.Public{Aux: pedersen.New(arith.ModulusFromN(aux.N), aux.S, aux.T)}, r.HashForID(msg.From)) {
+		return fmt.Errorf("failed to validate fac proof from %s", msg.From)
+	}
+	r.auxFrom[msg.From] = aux
+	return nil
+}
+
+func (r *reshareRound2) VerifyMessage(msg round.Message) error {
+	body, ok := msg.Content.(*reshareMessage2)
+	if !ok || body == nil {
+		return round.ErrInvalidContent
+	}
+	if body.Share == nil {
+		return round.ErrNilFields
+	}
+	return nil
+}
+
+func (r *reshareRound2) StoreMessage(msg round.Message) error {
+	body := msg.Content.(*reshareMessage2)
+	if !r.dealers.Contains(msg.From) || !r.members.Contains(r.SelfID()) {
+		if !body.Share.IsZero() {
+			return fmt.Errorf("unexpected reshare share from %s", msg.From)
+		}
+		return nil
+	}
+	expected := r.phi[msg.From].Evaluate(r.SelfID().Scalar(r.Group()))
+	if !body.Share.ActOnBase().Equal(expected) {
+		return fmt.Errorf("VSS failed to validate from %s", msg.From)
+	}
+	r.shareFrom[msg.From] = body.Share
+	return nil
+}
+
+func (r *reshareRound2) Finalize(chan<- *round.Message) (round.Session, error) {
+	exponents := make([]*polynomial.Exponent, 0, len(r.phi))
+	constant := r.Group().NewPoint()
+	for _, id := range r.dealers {
+		phi := r.phi[id]
+		if phi == nil {
+			return r, fmt.Errorf("missing reshare polynomial from %s", id)
+		}
+		exponents = append(exponents, phi)
+		constant = constant.Add(phi.Constant())
+	}
+	if !constant.Equal(r.public) {
+		return r, fmt.Errorf("invalid reshare public")
+	}
+	sum, err := polynomial.Sum(exponents)
+	if err != nil {
+		return r, err
+	}
+	verifications := make(map[party.ID]curve.Point, len(r.members))
+	for _, id := range r.members {
+		verifications[id] = sum.Evaluate(id.Scalar(r.Group()))
+	}
+
+	res := &reshareResult{
+		VerificationShares: verifications,
+		ChainKey:           r.chainKey,
+		RID:                r.rid,
+		Aux:                r.auxFrom,
+		ElGamal:            r.elgamal,
+		Paillier:           r.paillier,
+	}
+	if r.members.Contains(r.SelfID()) {
+		share := r.Group().NewScalar()
+		for _, id := range r.dealers {
+			s := r.shareFrom[id]
+			if s == nil {
+				return r, fmt.Errorf("missing reshare share from %s", id)
+			}
+			share.Add(s)
+		}
+		res.Share = share
+	}
+	return r.ResultRound(res), nil
+}
+
+func (r *reshareRound2) BroadcastContent() round.BroadcastContent {
+	return &reshareBroadcast2{Phi: polynomial.EmptyExponent(r.Group())}
+}
+
+func (r *reshareRound2) MessageContent() round.Content {
+	return &reshareMessage2{Share: r.Group().NewScalar()}
+}
+
+func (reshareRound2) Number() round.Number { return 2 }
+
+func (reshareBroadcast2) RoundNumber() round.Number { return 2 }
+
+func (reshareMessage2) RoundNumber() round.Number { return 2 }
+
+func (s *SQLite3Store) ReadPartySet(ctx context.Context, sequence uint64) (*PartySet, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var members string
+	var set PartySet
+	query := "SELECT members, threshold FROM reshares WHERE state=? AND cutover<? ORDER BY cutover DESC LIMIT 1"
+	row := s.db.QueryRowContext(ctx, query, common.RequestStateDone, sequence)
+	err := row.Scan(&members, &set.Threshold)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	set.Members = decodePartyIDs(members)
+	return &set, nil
+}
+
+func (s *SQLite3Store) CheckPendingReshare(ctx context.Context) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	return s.checkExistence(ctx, tx, "SELECT session_id FROM reshares WHERE state=?", common.RequestStateInitial)
+}
+
+func (s *SQLite3Store) ListKeygenResults(ctx context.Context) ([]*Key, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	query := `SELECT s.session_id, s.curve, ss.extra FROM sessions s JOIN session_signers ss ON s.session_id=ss.session_id
+		WHERE s.operation=? AND ss.extra!='' GROUP BY s.session_id ORDER BY s.created_at ASC, s.session_id ASC`
+	rows, err := s.db.QueryContext(ctx, query, common.OperationTypeKeygenInput)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []*Key
+	for rows.Next() {
+		var k Key
+		err := rows.Scan(&k.SessionId, &k.Curve, &k.Public)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, &k)
+	}
+	return keys, nil
+}
+
+func (s *SQLite3Store) WriteReshareIfNotExist(ctx context.Context, op *common.Operation, old, next *PartySet, keys []*Key, transaction crypto.Hash, outputIndex int, sequence uint64, createdAt time.Time) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	existed, err := s.checkExistence(ctx, tx, "SELECT session_id FROM reshares WHERE session_id=?", op.Id)
+	if err != nil || existed {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE reshares SET state=?, updated_at=? WHERE state=?",
+		common.RequestStateFailed, createdAt, common.RequestStateInitial)
+	if err != nil {
+		return fmt.Errorf("SQLite3Store UPDATE reshares %v", err)
+	}
+
+	cols := []string{"session_id", "old_members", "old_threshold", "members", "threshold",
+		"state", "sequence", "cutover", "created_at", "updated_at"}
+	vals := []any{op.Id, encodePartyIDs(old.Members), old.Threshold, encodePartyIDs(next.Members), next.Threshold,
+		common.RequestStateInitial, sequence, 0, createdAt, createdAt}
+	err = s.execOne(ctx, tx, buildInsertionSQL("reshares", cols), vals...)
+	if err != nil {
+		return fmt.Errorf("SQLite3Store INSERT reshares %v", err)
+	}
+
+	for _, k := range keys {
+		cols := []string{"reshare_id", "public", "curve", "session_id", "verification", "share", "created_at", "updated_at"}
+		err = s.execOne(ctx, tx, buildInsertionSQL("reshare_keys", cols),
+			op.Id, k.Public, k.Curve, k.SessionId, "", "", createdAt, createdAt)
+		if err != nil {
+			return fmt.Errorf("SQLite3Store INSERT reshare_keys %v", err)
+		}
+	}
+
+	cols = []string{"session_id", "mixin_hash", "mixin_index", "operation", "curve", "public",
+		"extra", "state", "created_at", "updated_at", "committed_at", "prepared_at", "sequence"}
+	vals = []any{op.Id, transaction.String(), outputIndex, op.Type, op.Curve, "",
+		hex.EncodeToString(op.Extra), common.RequestStateInitial, createdAt, createdAt, createdAt, createdAt, sequence}
+	err = s.execOne(ctx, tx, buildInsertionSQL("sessions", cols), vals...)
+	if err != nil {
+		return fmt.Errorf("SQLite3Store INSERT sessions %v", err)
+	}
+
+	return tx.Commit()
+}
+
+func (s *SQLite3Store) ReadReshare(ctx context.Context, id string) (*Reshare, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var r Reshare
+	var old, members string
+	query := "SELECT session_id, old_members, old_threshold, members, threshold, state, sequence, cutover, created_at FROM reshares WHERE session_id=?"
+	row := s.db.QueryRowContext(ctx, query, id)
+	err := row.Scan(&r.Id, &old, &r.OldThreshold, &members, &r.Threshold, &r.State, &r.Sequence, &r.Cutover, &r.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	r.OldMembers, r.Members = decodePartyIDs(old), decodePartyIDs(members)
+	return &r, nil
+}
+
+func (s *SQLite3Store) ListReshareKeys(ctx context.Context, id string) ([]*ReshareKey, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	query := "SELECT reshare_id, public, curve, session_id, verification, share FROM reshare_keys WHERE reshare_id=? ORDER BY created_at ASC, session_id ASC"
+	rows, err := s.db.QueryContext(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []*ReshareKey
+	for rows.Next() {
+		var k ReshareKey
+		err := rows.Scan(&k.ReshareId, &k.Public, &k.Curve, &k.SessionId, &k.Verification, &k.Share)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, &k)
+	}
+	return keys, nil
+}
+
+func (s *SQLite3Store) WriteReshareKeyResult(ctx context.Context, id, public string, verification, conf []byte, saved bool) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	share, err := s.encodeShare(ctx, public, conf)
+	if err != nil {
+		return err
+	}
+
+	timestamp := time.Now().UTC()
+	var backedUpAt any
+	if saved {
+		backedUpAt = timestamp
+	}
+	err = s.execOne(ctx, tx, "UPDATE reshare_keys SET verification=?, share=?, backed_up_at=?, updated_at=? WHERE reshare_id=? AND public=? AND verification=''",
+		hex.EncodeToString(verification), share, backedUpAt, timestamp, id, public)
+	if err != nil {
+		return fmt.Errorf("SQLite3Store UPDATE reshare_keys %v", err)
+	}
+
+	return tx.Commit()
+}
+
+func (s *SQLite3Store) MarkReshareSessionPending(ctx context.Context, sessionId string, digest string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = s.execOne(ctx, tx, "UPDATE sessions SET public=?, state=?, updated_at=? WHERE session_id=? AND state=?",
+		digest, common.RequestStatePending, time.Now().UTC(), sessionId, common.RequestStateInitial)
+	if err != nil {
+		return fmt.Errorf("SQLite3Store UPDATE sessions %v", err)
+	}
+
+	return tx.Commit()
+}
+
+// the cut-over replaces the shares of all reshared keys in a single transaction,
+// and the members leaving the party set erase their old shares, because any
+// threshold + 1 old shares could still sign or reconstruct the keys, only the
+// public keys are kept to follow the signer results of the new party set
+func (s *SQLite3Store) CutoverReshare(ctx context.Context, id string, sequence uint64, self party.ID) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var state int
+	var cutover uint64
+	var members string
+	row := tx.QueryRowContext(ctx, "SELECT state, cutover, members FROM reshares WHERE session_id=?", id)
+	err = row.Scan(&state, &cutover, &members)
+	if err != nil {
+		return false, err
+	}
+	if state == common.RequestStateDone && cutover == sequence {
+		return true, nil
+	}
+	if state != common.RequestStateInitial {
+		return false, nil
+	}
+
+	timestamp := time.Now().UTC()
+	err = s.execOne(ctx, tx, "UPDATE reshares SET state=?, cutover=?, updated_at=? WHERE session_id=? AND state=?",
+		common.RequestStateDone, sequence, timestamp, id, common.RequestStateInitial)
+	if err != nil {
+		return false, fmt.Errorf("SQLite3Store UPDATE reshares %v", err)
+	}
+
+	if !decodePartyIDs(members).Contains(self) {
+		query := "UPDATE keys SET share='', backed_up_at=? WHERE public IN (SELECT public FROM reshare_keys WHERE reshare_id=?)"
+		_, err = tx.ExecContext(ctx, query, timestamp, id)
+		if err != nil {
+			return false, fmt.Errorf("SQLite3Store UPDATE keys %v", err)
+		}
+		return true, tx.Commit()
+	}
+
+	query := "SELECT public, curve, session_id, share, backed_up_at FROM reshare_keys WHERE reshare_id=? AND share!=''"
+	rows, err := tx.QueryContext(ctx, query, id)
+	if err != nil {
+		return false, err
+	}
+	var keys []*Key
+	for rows.Next() {
+		var k Key
+		err := rows.Scan(&k.Public, &k.Curve, &k.SessionId, &k.Share, &k.BackedUpAt)
+		if err != nil {
+			rows.Close()
+			return false, err
+		}
+		keys = append(keys, &k)
+	}
+	rows.Close()
+
+	for _, k := range keys {
+		var backedUpAt any
+		if k.BackedUpAt.Valid {
+			backedUpAt = k.BackedUpAt.Time
+		}
+		existed, err := s.checkExistence(ctx, tx, "SELECT public FROM keys WHERE public=?", k.Public)
+		if err != nil {
+			return false, err
+		}
+		if existed {
+			err = s.execOne(ctx, tx, "UPDATE keys SET share=?, backed_up_at=? WHERE public=?", k.Share, backedUpAt, k.Public)
+			if err != nil {
+				return false, fmt.Errorf("SQLite3Store UPDATE keys %v", err)
+			}
+			continue
+		}
+		fingerprint := hex.EncodeToString(common.Fingerprint(k.Public))
+		cols := []string{"public", "fingerprint", "curve", "share", "session_id", "created_at", "backed_up_at"}
+		err = s.execOne(ctx, tx, buildInsertionSQL("keys", cols), k.Public, fingerprint, k.Curve, k.Share, k.SessionId, timestamp, backedUpAt)
+		if err != nil {
+			return false, fmt.Errorf("SQLite3Store INSERT keys %v", err)
+		}
+	}
+
+	return true, tx.Commit()
+}
+
+func encodePartyIDs(ids party.IDSlice) string {
+	members := make([]string, len(ids))
+	for i, id := range ids {
+		members[i] = string(id)
+	}
+	return strings.Join(members, ",")
+}
+
+func decodePartyIDs(members string) party.IDSlice {
+	var ids []party.ID
+	for _, id := range strings.Split(members, ",") {
+		ids = append(ids, party.ID(id))
+	}
+	return party.NewIDSlice(ids)
+}
@@ -0,0 +1,167 @@
+package signer
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"math"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/multi-party-sig/pkg/party"
+	"github.com/MixinNetwork/safe/common"
+	"github.com/MixinNetwork/trusted-group/mtg"
+	"github.com/gofrs/uuid/v5"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReshare(t *testing.T) {
+	require := require.New(t)
+	ctx, nodes, _ := TestPrepare(require)
+
+	edwards := testFROSTKeyGen(ctx, require, nodes, common.CurveEdwards25519Default)
+	publics := map[string]byte{edwards: common.CurveEdwards25519Default}
+
+	genesis := &PartySet{Members: nodes[0].members, Threshold: nodes[0].threshold}
+	next := &PartySet{Members: party.NewIDSlice(genesis.Members[:3]), Threshold: 1}
+	testReshare(ctx, require, nodes, "reshare-to-three", genesis, next, publics)
+	testFROSTSign(ctx, require, nodes, edwards, []byte("reshare-to-three"), common.CurveEdwards25519Default)
+
+	testReshare(ctx, require, nodes, "reshare-to-genesis", next, genesis, publics)
+	testFROSTSign(ctx, require, nodes, edwards, []byte("reshare-to-genesis"), common.CurveEdwards25519Default)
+}
+
+func testReshare(ctx context.Context, require *require.Assertions, nodes []*Node, name string, old, next *PartySet, publics map[string]byte) {
+	shares := make(map[party.ID]map[string][]byte)
+	for _, node := range nodes {
+		shares[node.id] = make(map[string][]byte)
+		for public := range publics {
+			_, _, share, err := node.store.ReadKeyByFingerprint(ctx, hex.EncodeToString(common.Fingerprint(public)))
+			require.Nil(err)
+			shares[node.id][public] = share
+		}
+	}
+
+	node := nodes[0]
+	sid := common.UniqueId("reshare", name)
+	op := &common.Operation{
+		Type:  common.OperationTypeReshareInput,
+		Id:    sid,
+		Curve: common.CurveEdwards25519Default,
+		Extra: EncodeReshareExtra(node.members, old, next),
+	}
+	memo := mtg.EncodeMixinExtraBase64(node.conf.AppId, node.encryptOperation(op))
+	memo = hex.EncodeToString([]byte(memo))
+	out := &mtg.Action{
+		UnifiedOutput: mtg.UnifiedOutput{
+			OutputId:        uuid.Must(uuid.NewV4()).String(),
+			TransactionHash: crypto.Sha256Hash([]byte(op.Id)).String(),
+			AppId:           node.conf.AppId,
+			AssetId:         node.conf.KeeperAssetId,
+			Extra:           memo,
+			Amount:          decimal.NewFromInt(1),
+			CreatedAt:       time.Now(),
+		},
+	}
+	res := TestProcessOutput(ctx, require, nodes, out, sid)
+	require.NotNil(res)
+	require.Equal(common.OperationTypeReshareOutput, int(res.Type))
+	require.Equal(sid, res.Id)
+	require.True(bytes.Equal(op.Extra, res.Extra))
+
+	for _, node := range nodes {
+		set := node.readPartySet(ctx, math.MaxInt64)
+		require.Equal(next.Members, set.Members)
+		require.Equal(next.Threshold, set.Threshold)
+		for public, crv := range publics {
+			holder, _, share, err := node.store.ReadKeyByFingerprint(ctx, hex.EncodeToString(common.Fingerprint(public)))
+			require.Nil(err)
+			require.Equal(public, holder)
+			if !next.Members.Contains(node.id) {
+				require.Nil(share)
+				continue
+			}
+			pub, _ := node.deriveByPath(ctx, crv, share, []byte{0, 0, 0, 0})
+			require.Equal(public, hex.EncodeToString(pub))
+			require.False(bytes.Equal(shares[node.id][public], share))
+		}
+	}
+}
+
+func TestReshareCutoverLeaving(t *testing.T) {
+	require := require.New(t)
+	ctx := common.EnableTestEnvironment(context.Background())
+
+	members := party.NewIDSlice([]party.ID{"member-0", "member-1", "member-2", "member-3"})
+	old := &PartySet{Members: members, Threshold: 2}
+	next := &PartySet{Members: members[:3], Threshold: 1}
+	seed := crypto.Sha256Hash([]byte("reshare-cutover-leaving"))
+	public := crypto.NewKeyFromSeed(append(seed[:], seed[:]...)).Public().String()
+	fingerprint := hex.EncodeToString(common.Fingerprint(public))
+
+	for _, self := range members {
+		dir, err := os.MkdirTemp("", "safe-reshare-test-")
+		require.Nil(err)
+		store, err := OpenSQLite3Store(dir + "/mpc.sqlite3")
+		require.Nil(err)
+		defer store.Close()
+
+		key := &Key{Public: public, Curve: common.CurveEdwards25519Default, SessionId: uuid.Must(uuid.NewV4()).String()}
+		share, err := store.encodeShare(ctx, public, []byte("old-share"))
+		require.Nil(err)
+		_, err = store.db.ExecContext(ctx, buildInsertionSQL("keys", []string{"public", "fingerprint", "curve", "share", "session_id", "created_at"}),
+			public, fingerprint, key.Curve, share, key.SessionId, time.Now())
+		require.Nil(err)
+
+		op := &common.Operation{
+			Id:    uuid.Must(uuid.NewV4()).String(),
+			Type:  common.OperationTypeReshareInput,
+			Curve: common.CurveEdwards25519Default,
+			Extra: EncodeReshareExtra(members, old, next),
+		}
+		err = store.WriteReshareIfNotExist(ctx, op, old, next, []*Key{key}, crypto.Sha256Hash([]byte(op.Id)), 0, 1, time.Now())
+		require.Nil(err)
+		var conf []byte
+		if next.Members.Contains(self) {
+			conf = []byte("new-share")
+		}
+		err = store.WriteReshareKeyResult(ctx, op.Id, public, []byte("verification"), conf, false)
+		require.Nil(err)
+		cutover, err := store.CutoverReshare(ctx, op.Id, 2, self)
+		require.Nil(err)
+		require.True(cutover)
+
+		holder, _, share2, err := store.ReadKeyByFingerprint(ctx, fingerprint)
+		require.Nil(err)
+		require.Equal(public, holder)
+		if next.Members.Contains(self) {
+			require.Equal([]byte("new-share"), share2)
+			continue
+		}
+		require.Nil(share2)
+		keys, err := store.ListUnbackupedKeys(ctx, 10)
+		require.Nil(err)
+		require.Len(keys, 0)
+
+		// the leaving member fails the sign session without any share
+		sop := &common.Operation{
+			Id:     uuid.Must(uuid.NewV4()).String(),
+			Type:   common.OperationTypeSignInput,
+			Curve:  common.CurveEdwards25519Default,
+			Public: hex.EncodeToString(append(common.Fingerprint(public), 0, 0, 0, 0)),
+			Extra:  []byte("reshare-cutover-leaving"),
+		}
+		err = store.WriteSessionIfNotExist(ctx, sop, crypto.Sha256Hash([]byte(sop.Id)), 0, 3, time.Now(), false)
+		require.Nil(err)
+		node := &Node{id: self, store: store}
+		err = node.startSign(ctx, sop, []party.ID{members[0], self})
+		require.Nil(err)
+		session, err := store.ReadSession(ctx, sop.Id)
+		require.Nil(err)
+		require.Equal(byte(common.RequestStatePending), session.State)
+		require.Equal(hex.EncodeToString(sop.Extra), session.Extra)
+	}
+}
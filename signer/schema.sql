@@ -32,6 +32,7 @@ CREATE TABLE IF NOT EXISTS sessions (
 	updated_at    TIMESTAMP NOT NULL,
 	committed_at  TIMESTAMP,
 	prepared_at   TIMESTAMP,
+	sequence      INTEGER NOT NULL DEFAULT 0,
 	PRIMARY KEY ('session_id')
 );
 
@@ -69,3 +70,54 @@ CREATE TABLE IF NOT EXISTS action_results (
 );
 
 CREATE INDEX IF NOT EXISTS action_results_by_session ON action_results(session_id);
+
+CREATE TABLE IF NOT EXISTS reshares (
+	session_id      VARCHAR NOT NULL,
+	old_members     VARCHAR NOT NULL,
+	old_threshold   INTEGER NOT NULL,
+	members         VARCHAR NOT NULL,
+	threshold       INTEGER NOT NULL,
+	state           INTEGER NOT NULL,
+	sequence        INTEGER NOT NULL,
+	cutover         INTEGER NOT NULL,
+	created_at      TIMESTAMP NOT NULL,
+	updated_at      TIMESTAMP NOT NULL,
+	PRIMARY KEY ('session_id')
+);
+
+CREATE INDEX IF NOT EXISTS reshares_by_state_cutover ON reshares(state, cutover);
+
+CREATE TABLE IF NOT EXISTS reshare_keys (
+	reshare_id      VARCHAR NOT NULL,
+	public          VARCHAR NOT NULL,
+	curve           INTEGER NOT NULL,
+	session_id      VARCHAR NOT NULL,
+	verification    VARCHAR NOT NULL,
+	share           VARCHAR NOT NULL,
+	created_at      TIMESTAMP NOT NULL,
+	updated_at      TIMESTAMP NOT NULL,
+	backed_up_at    TIMESTAMP,
+	PRIMARY KEY ('reshare_id', 'public')
+);
+
+CREATE TABLE IF NOT EXISTS refreshes (
+	session_id      VARCHAR NOT NULL,
+	public          VARCHAR NOT NULL,
+	old_share       VARCHAR NOT NULL,
+	share           VARCHAR NOT NULL,
+	cutover         INTEGER NOT NULL,
+	created_at      TIMESTAMP NOT NULL,
+	updated_at      TIMESTAMP NOT NULL,
+	backed_up_at    TIMESTAMP,
+	PRIMARY KEY ('session_id')
+);
+
+CREATE INDEX IF NOT EXISTS refreshes_by_public_cutover ON refreshes(public, cutover);
+
+CREATE TABLE IF NOT EXISTS works_votes (
+	day         TIMESTAMP NOT NULL,
+	signer_id   VARCHAR NOT NULL,
+	works       VARCHAR NOT NULL,
+	created_at  TIMESTAMP NOT NULL,
+	PRIMARY KEY ('day', 'signer_id')
+);
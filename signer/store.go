@@ -1,6 +1,7 @@
 package signer
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
 	_ "embed"
@@ -24,6 +25,7 @@ var SCHEMA string
 type SQLite3Store struct {
 	db    *sql.DB
 	mutex *sync.Mutex
+	kms   ShareKMS
 }
 
 func OpenSQLite3Store(path string) (*SQLite3Store, error) {
@@ -41,6 +43,127 @@ func (s *SQLite3Store) Close() error {
 	return s.db.Close()
 }
 
+func (s *SQLite3Store) EnableShareEncryption(kms ShareKMS) {
+	s.kms = kms
+}
+
+func (s *SQLite3Store) encodeShare(ctx context.Context, public string, conf []byte) (string, error) {
+	if len(conf) == 0 || s.kms == nil {
+		return common.Base91Encode(conf), nil
+	}
+	return sealShareEnvelope(ctx, s.kms, public, conf)
+}
+
+func (s *SQLite3Store) decodeShare(ctx context.Context, public, share string) ([]byte, error) {
+	if isShareEnvelope(share) {
+		return openShareEnvelope(ctx, s.kms, public, share)
+	}
+	return common.Base91Decode(share)
+}
+
+func (s *SQLite3Store) DecodeKeyShare(ctx context.Context, k *Key) ([]byte, error) {
+	return s.decodeShare(ctx, k.Public, k.Share)
+}
+
+// encrypt all the legacy plain shares in place, both the keys in use and
+// the reshare results waiting for the cut-over
+func (s *SQLite3Store) EncryptKeyShares(ctx context.Context) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.kms == nil {
+		return 0, fmt.Errorf("SQLite3Store.EncryptKeyShares() without kms")
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var count int
+	for _, table := range []string{"keys", "reshare_keys", "refreshes"} {
+		query := fmt.Sprintf("SELECT public, share FROM %s WHERE share!=''", table)
+		rows, err := tx.QueryContext(ctx, query)
+		if err != nil {
+			return 0, err
+		}
+		var plains [][2]string
+		for rows.Next() {
+			var public, share string
+			err = rows.Scan(&public, &share)
+			if err != nil {
+				rows.Close()
+				return 0, err
+			}
+			if !isShareEnvelope(share) {
+				plains = append(plains, [2]string{public, share})
+			}
+		}
+		rows.Close()
+
+		for _, p := range plains {
+			conf, err := common.Base91Decode(p[1])
+			if err != nil {
+				return 0, err
+			}
+			share, err := sealShareEnvelope(ctx, s.kms, p[0], conf)
+			if err != nil {
+				return 0, err
+			}
+			query := fmt.Sprintf("UPDATE %s SET share=? WHERE public=? AND share=?", table)
+			_, err = tx.ExecContext(ctx, query, share, p[0], p[1])
+			if err != nil {
+				return 0, fmt.Errorf("SQLite3Store UPDATE %s %v", table, err)
+			}
+			count += 1
+		}
+	}
+
+	return count, tx.Commit()
+}
+
+func (s *SQLite3Store) Migrate3(ctx context.Context) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	key, val := "SCHEMA:VERSION:dd2cfca0f8c3b82e9110d19b8717fe9d0fb0150c", ""
+	row := tx.QueryRowContext(ctx, "SELECT value FROM properties WHERE key=?", key)
+	err = row.Scan(&val)
+	if err == nil {
+		return nil
+	} else if err != sql.ErrNoRows {
+		return err
+	}
+
+	// the column is already in the schema for a new database
+	query := "ALTER TABLE sessions ADD COLUMN sequence INTEGER NOT NULL DEFAULT 0;\n"
+	existed, err := s.checkExistence(ctx, tx, "SELECT name FROM pragma_table_info('sessions') WHERE name='sequence'")
+	if err != nil {
+		return err
+	}
+	if !existed {
+		_, err = tx.ExecContext(ctx, query)
+		if err != nil {
+			return err
+		}
+	}
+
+	now := time.Now().UTC()
+	_, err = tx.ExecContext(ctx, "INSERT INTO properties (key, value, created_at) VALUES (?, ?, ?)", key, query, now)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
 func (s *SQLite3Store) Migrate2(ctx context.Context) error {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
@@ -125,7 +248,10 @@ func (s *SQLite3Store) WriteKeyIfNotExists(ctx context.Context, sessionId string
 	}
 
 	timestamp := time.Now().UTC()
-	share := common.Base91Encode(conf)
+	share, err := s.encodeShare(ctx, public, conf)
+	if err != nil {
+		return err
+	}
 	fingerprint := hex.EncodeToString(common.Fingerprint(public))
 	cols := []string{"public", "fingerprint", "curve", "share", "session_id", "created_at"}
 	values := []any{public, fingerprint, curve, share, sessionId, timestamp}
@@ -153,7 +279,7 @@ func (s *SQLite3Store) ListUnbackupedKeys(ctx context.Context, threshold int) ([
 	defer s.mutex.Unlock()
 
 	cols := []string{"public", "fingerprint", "curve", "share", "session_id", "created_at", "backed_up_at"}
-	query := fmt.Sprintf("SELECT %s FROM keys WHERE backed_up_at IS NULL ORDER BY created_at ASC LIMIT %d", strings.Join(cols, ","), threshold)
+	query := fmt.Sprintf("SELECT %s FROM keys WHERE share!='' AND backed_up_at IS NULL ORDER BY created_at ASC LIMIT %d", strings.Join(cols, ","), threshold)
 	rows, err := s.db.QueryContext(ctx, query)
 	if err != nil {
 		return nil, err
@@ -191,6 +317,121 @@ func (s *SQLite3Store) MarkKeyBackuped(ctx context.Context, public string) error
 	return tx.Commit()
 }
 
+func (s *SQLite3Store) ListBackupedKeys(ctx context.Context) ([]*Key, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	cols := []string{"public", "fingerprint", "curve", "share", "session_id", "created_at", "backed_up_at"}
+	query := fmt.Sprintf("SELECT %s FROM keys WHERE share!='' AND backed_up_at IS NOT NULL ORDER BY created_at ASC", strings.Join(cols, ","))
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []*Key
+	for rows.Next() {
+		var k Key
+		err := rows.Scan(&k.Public, &k.Fingerprint, &k.Curve, &k.Share, &k.SessionId, &k.CreatedAt, &k.BackedUpAt)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, &k)
+	}
+	return keys, nil
+}
+
+// the share is compared after decryption, because the stored envelope
+// changes whenever the share is encrypted again
+func (s *SQLite3Store) ResetKeyBackup(ctx context.Context, public string, conf []byte) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var share string
+	row := tx.QueryRowContext(ctx, "SELECT share FROM keys WHERE public=?", public)
+	err = row.Scan(&share)
+	if err == sql.ErrNoRows || share == "" {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	current, err := s.decodeShare(ctx, public, share)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(current, conf) {
+		return nil
+	}
+
+	query := "UPDATE keys SET backed_up_at=NULL WHERE public=? AND share=? AND backed_up_at IS NOT NULL"
+	_, err = tx.ExecContext(ctx, query, public, share)
+	if err != nil {
+		return fmt.Errorf("SQLite3Store UPDATE keys %v", err)
+	}
+
+	return tx.Commit()
+}
+
+// RestoreKey never overwrites the share in use with a different backup, which
+// could be older than a refreshed or reshared share, unless forced
+func (s *SQLite3Store) RestoreKey(ctx context.Context, sessionId string, curve uint8, public string, conf []byte, force bool) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	timestamp := time.Now().UTC()
+	share, err := s.encodeShare(ctx, public, conf)
+	if err != nil {
+		return err
+	}
+	var old string
+	row := tx.QueryRowContext(ctx, "SELECT share FROM keys WHERE public=?", public)
+	err = row.Scan(&old)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	if err == nil {
+		if old != "" {
+			current, err := s.decodeShare(ctx, public, old)
+			if err != nil {
+				return err
+			}
+			if bytes.Equal(current, conf) {
+				share = old
+			}
+		}
+		if share != old && !force {
+			return fmt.Errorf("backup of %s differs from the share in use", public)
+		}
+		err = s.execOne(ctx, tx, "UPDATE keys SET share=?, backed_up_at=? WHERE public=? AND curve=?",
+			share, timestamp, public, curve)
+		if err != nil {
+			return fmt.Errorf("SQLite3Store UPDATE keys %v", err)
+		}
+		return tx.Commit()
+	}
+
+	fingerprint := hex.EncodeToString(common.Fingerprint(public))
+	cols := []string{"public", "fingerprint", "curve", "share", "session_id", "created_at", "backed_up_at"}
+	err = s.execOne(ctx, tx, buildInsertionSQL("keys", cols), public, fingerprint, curve, share, sessionId, timestamp, timestamp)
+	if err != nil {
+		return fmt.Errorf("SQLite3Store INSERT keys %v", err)
+	}
+
+	return tx.Commit()
+}
+
 func (s *SQLite3Store) ReadKeyByFingerprint(ctx context.Context, sum string) (string, uint8, []byte, error) {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
@@ -204,7 +445,10 @@ func (s *SQLite3Store) ReadKeyByFingerprint(ctx context.Context, sum string) (st
 	} else if err != nil {
 		return "", 0, nil, err
 	}
-	conf, err := common.Base91Decode(share)
+	if share == "" {
+		return public, curve, nil, nil
+	}
+	conf, err := s.decodeShare(ctx, public, share)
 	return public, curve, conf, err
 }
 
@@ -213,9 +457,9 @@ func (s *SQLite3Store) ReadSession(ctx context.Context, sessionId string) (*Sess
 	defer s.mutex.Unlock()
 
 	var r Session
-	query := "SELECT session_id, mixin_hash, mixin_index, operation, curve, public, extra, state, created_at, prepared_at FROM sessions WHERE session_id=?"
+	query := "SELECT session_id, mixin_hash, mixin_index, operation, curve, public, extra, state, created_at, prepared_at, sequence FROM sessions WHERE session_id=?"
 	row := s.db.QueryRowContext(ctx, query, sessionId)
-	err := row.Scan(&r.Id, &r.MixinHash, &r.MixinIndex, &r.Operation, &r.Curve, &r.Public, &r.Extra, &r.State, &r.CreatedAt, &r.PreparedAt)
+	err := row.Scan(&r.Id, &r.MixinHash, &r.MixinIndex, &r.Operation, &r.Curve, &r.Public, &r.Extra, &r.State, &r.CreatedAt, &r.PreparedAt, &r.Sequence)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -394,7 +638,7 @@ func (s *SQLite3Store) ListSessionSignerResults(ctx context.Context, sessionId s
 	return signers, nil
 }
 
-func (s *SQLite3Store) WriteSessionIfNotExist(ctx context.Context, op *common.Operation, transaction crypto.Hash, outputIndex int, createdAt time.Time, needsCommittment bool) error {
+func (s *SQLite3Store) WriteSessionIfNotExist(ctx context.Context, op *common.Operation, transaction crypto.Hash, outputIndex int, sequence uint64, createdAt time.Time, needsCommittment bool) error {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
@@ -410,9 +654,9 @@ func (s *SQLite3Store) WriteSessionIfNotExist(ctx context.Context, op *common.Op
 	}
 
 	cols := []string{"session_id", "mixin_hash", "mixin_index", "operation", "curve", "public",
-		"extra", "state", "created_at", "updated_at"}
+		"extra", "state", "created_at", "updated_at", "sequence"}
 	vals := []any{op.Id, transaction.String(), outputIndex, op.Type, op.Curve, op.Public,
-		hex.EncodeToString(op.Extra), common.RequestStateInitial, createdAt, createdAt}
+		hex.EncodeToString(op.Extra), common.RequestStateInitial, createdAt, createdAt, sequence}
 	if !needsCommittment {
 		cols = append(cols, "committed_at", "prepared_at")
 		vals = append(vals, createdAt, createdAt)
@@ -628,7 +872,7 @@ func (s *SQLite3Store) ListInitialSessions(ctx context.Context, limit int) ([]*S
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
-	cols := "session_id, mixin_hash, mixin_index, operation, curve, public, extra, state, created_at"
+	cols := "session_id, mixin_hash, mixin_index, operation, curve, public, extra, state, created_at, sequence"
 	sql := fmt.Sprintf("SELECT %s FROM sessions WHERE state=? AND committed_at IS NULL AND prepared_at IS NULL ORDER BY operation DESC, created_at ASC, session_id ASC LIMIT %d", cols, limit)
 	return s.listSessionsByQuery(ctx, sql, common.RequestStateInitial)
 }
@@ -637,7 +881,7 @@ func (s *SQLite3Store) ListPreparedSessions(ctx context.Context, limit int) ([]*
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
-	cols := "session_id, mixin_hash, mixin_index, operation, curve, public, extra, state, created_at"
+	cols := "session_id, mixin_hash, mixin_index, operation, curve, public, extra, state, created_at, sequence"
 	sql := fmt.Sprintf("SELECT %s FROM sessions WHERE state=? AND committed_at IS NOT NULL AND prepared_at IS NOT NULL ORDER BY operation DESC, created_at ASC, session_id ASC LIMIT %d", cols, limit)
 	return s.listSessionsByQuery(ctx, sql, common.RequestStateInitial)
 }
@@ -646,7 +890,7 @@ func (s *SQLite3Store) ListPendingSessions(ctx context.Context, limit int) ([]*S
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
-	cols := "session_id, mixin_hash, mixin_index, operation, curve, public, extra, state, created_at"
+	cols := "session_id, mixin_hash, mixin_index, operation, curve, public, extra, state, created_at, sequence"
 	sql := fmt.Sprintf("SELECT %s FROM sessions WHERE state=? ORDER BY created_at ASC, session_id ASC LIMIT %d", cols, limit)
 	return s.listSessionsByQuery(ctx, sql, common.RequestStatePending)
 }
@@ -661,7 +905,7 @@ func (s *SQLite3Store) listSessionsByQuery(ctx context.Context, sql string, stat
 	var sessions []*Session
 	for rows.Next() {
 		var r Session
-		err := rows.Scan(&r.Id, &r.MixinHash, &r.MixinIndex, &r.Operation, &r.Curve, &r.Public, &r.Extra, &r.State, &r.CreatedAt)
+		err := rows.Scan(&r.Id, &r.MixinHash, &r.MixinIndex, &r.Operation, &r.Curve, &r.Public, &r.Extra, &r.State, &r.CreatedAt, &r.Sequence)
 		if err != nil {
 			return nil, err
 		}
@@ -706,7 +950,7 @@ func (s *SQLite3Store) SessionsState(ctx context.Context) (*State, error) {
 		return nil, err
 	}
 
-	row = tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM keys")
+	row = tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM keys WHERE share!=''")
 	err = row.Scan(&state.Keys)
 	if err != nil {
 		return nil, err
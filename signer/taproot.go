@@ -19,9 +19,9 @@ const (
 	taprootSignRoundTimeout   = time.Minute
 )
 
-func (node *Node) taprootKeygen(ctx context.Context, sessionId []byte) (*KeygenResult, error) {
+func (node *Node) taprootKeygen(ctx context.Context, set *PartySet, sessionId []byte) (*KeygenResult, error) {
 	logger.Printf("node.taprootKeygen(%x)", sessionId)
-	start, err := frost.KeygenTaproot(node.id, node.members, node.threshold)(sessionId)
+	start, err := frost.KeygenTaproot(node.id, set.Members, set.Threshold)(sessionId)
 	if err != nil {
 		return nil, fmt.Errorf("frost.KeygenTaproot(%x) => %v", sessionId, err)
 	}
@@ -39,8 +39,8 @@ func (node *Node) taprootKeygen(ctx context.Context, sessionId []byte) (*KeygenR
 	}, nil
 }
 
-func (node *Node) taprootSign(ctx context.Context, members []party.ID, public string, share []byte, m []byte, sessionId []byte) (*SignResult, error) {
-	logger.Printf("node.taprootSign(%x, %s, %x, %v)", sessionId, public, m, members)
+func (node *Node) taprootSign(ctx context.Context, members []party.ID, public string, share []byte, m []byte, sessionId []byte, path []byte) (*SignResult, error) {
+	logger.Printf("node.taprootSign(%x, %s, %x, %x, %v)", sessionId, public, m, path, members)
 	group := curve.Secp256k1{}
 	conf := &frost.TaprootConfig{PrivateShare: group.NewScalar()}
 	err := conf.UnmarshalBinary(share)
@@ -50,6 +50,15 @@ func (node *Node) taprootSign(ctx context.Context, members []party.ID, public st
 	if hex.EncodeToString(conf.PublicKey) != public {
 		panic(public)
 	}
+	for i := 0; i < int(path[0]); i++ {
+		conf, err = conf.DeriveChild(uint32(path[i+1]))
+		if err != nil {
+			return nil, fmt.Errorf("frost.DeriveChild(%x, %d, %d) => %v", sessionId, i, path[i+1], err)
+		}
+		if hex.EncodeToString(conf.PublicKey) == public {
+			panic(public)
+		}
+	}
 
 	start, err := frost.SignTaproot(conf, members, m)(sessionId)
 	if err != nil {
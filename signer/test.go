@@ -66,7 +66,7 @@ func TestFROSTPrepareKeys(ctx context.Context, require *require.Assertions, node
 		require.Equal(public, pub)
 
 		op := &common.Operation{Id: sid, Curve: curve, Type: common.OperationTypeKeygenInput}
-		err := node.store.WriteSessionIfNotExist(ctx, op, crypto.Sha256Hash([]byte(sid)), 0, time.Now(), false)
+		err := node.store.WriteSessionIfNotExist(ctx, op, crypto.Sha256Hash([]byte(sid)), 0, 0, time.Now(), false)
 		require.Nil(err)
 		err = node.store.WriteKeyIfNotExists(ctx, op.Id, curve, pub, conf, false)
 		require.Nil(err)
@@ -85,7 +85,7 @@ func TestCMPPrepareKeys(ctx context.Context, require *require.Assertions, nodes
 		require.Equal(public, pub)
 
 		op := &common.Operation{Id: sid, Curve: crv, Type: common.OperationTypeKeygenInput}
-		err := node.store.WriteSessionIfNotExist(ctx, op, crypto.Sha256Hash([]byte(sid)), 0, time.Now().UTC(), false)
+		err := node.store.WriteSessionIfNotExist(ctx, op, crypto.Sha256Hash([]byte(sid)), 0, 0, time.Now().UTC(), false)
 		require.Nil(err)
 		err = node.store.WriteKeyIfNotExists(ctx, op.Id, crv, pub, sb, false)
 		require.Nil(err)
@@ -183,6 +183,9 @@ func testBuildNode(ctx context.Context, require *require.Assertions, root string
 		Keeper struct {
 			MTG *mtg.Configuration `toml:"mtg"`
 		} `toml:"keeper"`
+		Custodian struct {
+			MTG *mtg.Configuration `toml:"mtg"`
+		} `toml:"custodian"`
 	}
 	err := toml.Unmarshal(f, &conf)
 	require.Nil(err)
@@ -202,6 +205,12 @@ func testBuildNode(ctx context.Context, require *require.Assertions, root string
 	}
 	kd, err := OpenSQLite3Store(conf.Signer.StoreDir + "/mpc.sqlite3")
 	require.Nil(err)
+	if i%2 == 1 {
+		conf.Signer.SharePassphrase = hex.EncodeToString(seed[:])
+		kms, err := conf.Signer.ShareKMS()
+		require.Nil(err)
+		kd.EnableShareEncryption(kms)
+	}
 
 	md, err := mtg.OpenSQLite3Store(conf.Signer.StoreDir + "/mtg.sqlite3")
 	require.Nil(err)
@@ -209,7 +218,7 @@ func testBuildNode(ctx context.Context, require *require.Assertions, root string
 	require.Nil(err)
 	group.EnableDebug()
 
-	node := NewNode(kd, group, nil, conf.Signer, conf.Keeper.MTG, nil)
+	node := NewNode(kd, group, nil, conf.Signer, conf.Keeper.MTG, conf.Custodian.MTG, nil)
 	group.AttachWorker(node.conf.AppId, node)
 	return node
 }
@@ -279,6 +288,7 @@ func newTestNetwork(parties party.IDSlice) *testNetwork {
 }
 
 func (n *testNetwork) mtgLoop(ctx context.Context, node *Node) {
+	var sequence uint64
 	filter := make(map[string]bool)
 	loop := n.mtgChannels[node.id]
 	logger.Printf("loop: %s %d", node.id, len(loop))
@@ -290,6 +300,10 @@ func (n *testNetwork) mtgLoop(ctx context.Context, node *Node) {
 		var out mtg.Action
 		json.Unmarshal(mob, &out)
 		out.TestAttachActionToGroup(node.group)
+		if out.Sequence == 0 {
+			out.Sequence = sequence + 1
+		}
+		sequence = max(sequence, out.Sequence)
 		ts, asset := node.ProcessOutput(ctx, &out)
 		if asset != "" {
 			panic(asset)
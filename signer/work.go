@@ -2,17 +2,26 @@ package signer
 
 import (
 	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
 	"slices"
 	"time"
 
+	"github.com/MixinNetwork/mixin/logger"
 	"github.com/MixinNetwork/multi-party-sig/pkg/party"
+	"github.com/MixinNetwork/safe/common"
+	"github.com/MixinNetwork/trusted-group/mtg"
+)
+
+const (
+	DailyWorksPeriod = time.Hour * 24
 )
 
 // TODO put all works query to the custodian module
 func (node *Node) DailyWorks(ctx context.Context, now time.Time) []byte {
-	day := time.Hour * 24
-	end := now.UTC().Truncate(day)
-	begin := end.Add(-day)
+	end := now.UTC().Truncate(DailyWorksPeriod)
+	begin := end.Add(-DailyWorksPeriod)
 
 	works, err := node.store.CountDailyWorks(ctx, node.members, begin, end)
 	if err != nil {
@@ -27,6 +36,122 @@ func (node *Node) DailyWorks(ctx context.Context, now time.Time) []byte {
 	return normalizeWorks(works)
 }
 
+func (node *Node) loopDailyWorks(ctx context.Context) {
+	for node.conf.CustodianAppId != "" {
+		err := node.voteDailyWorks(ctx, time.Now())
+		logger.Printf("node.voteDailyWorks() => %v", err)
+		if err != nil {
+			panic(err)
+		}
+		time.Sleep(time.Hour)
+	}
+}
+
+// the vote is sent to the signer mtg for the previous day, and it is safe to vote
+// multiple times for the same day because the trace id is unique per day
+func (node *Node) voteDailyWorks(ctx context.Context, now time.Time) error {
+	works := node.DailyWorks(ctx, now)
+	if slices.Max(works) == 0 {
+		return nil
+	}
+	day := now.UTC().Truncate(DailyWorksPeriod).Add(-DailyWorksPeriod)
+	op := &common.Operation{
+		Id:    common.UniqueId(dailyWorksId(day), string(node.id)),
+		Type:  common.CustodianActionVoteWorks,
+		Curve: common.CurveEdwards25519Mixin,
+		Extra: encodeDailyWorks(day, works),
+	}
+	extra := common.AESEncrypt(node.aesKey[:], op.Encode(), op.Id)
+	if len(extra) > 160 {
+		panic(fmt.Errorf("node.voteDailyWorks(%v) omitted %x", op, extra))
+	}
+	traceId := fmt.Sprintf("WORKS:%s:SIGNER:%s:VOTE", day.Format(time.DateOnly), string(node.id))
+	return node.sendTransactionToSignerGroupUntilSufficient(ctx, extra, traceId)
+}
+
+func (node *Node) processWorksVote(ctx context.Context, op *common.Operation, out *mtg.Action) (string, []*mtg.Transaction, string) {
+	day, works, err := decodeDailyWorks(op.Extra, len(node.members))
+	if err != nil {
+		logger.Printf("decodeDailyWorks(%x) => %v", op.Extra, err)
+		return op.Id, nil, ""
+	}
+	voter := out.Senders[0]
+	if op.Id != common.UniqueId(dailyWorksId(day), voter) {
+		return op.Id, nil, ""
+	}
+	if works[slices.Index(node.members, party.ID(voter))] != 0 {
+		return op.Id, nil, ""
+	}
+	err = node.store.WriteWorksVoteIfNotExist(ctx, day, voter, works, out.CreatedAt)
+	if err != nil {
+		panic(fmt.Errorf("store.WriteWorksVoteIfNotExist(%v) => %v", op, err))
+	}
+
+	votes, err := node.store.ListWorksVotes(ctx, day)
+	if err != nil {
+		panic(fmt.Errorf("store.ListWorksVotes(%v) => %v", day, err))
+	}
+	if len(votes) < node.conf.MTG.Genesis.Threshold || node.conf.CustodianAppId == "" {
+		return op.Id, nil, ""
+	}
+
+	fid := common.UniqueId(dailyWorksId(day), "FINALIZE")
+	if node.store.CheckActionResultsBySessionId(ctx, fid) {
+		return op.Id, nil, ""
+	}
+	op = &common.Operation{
+		Id:    fid,
+		Type:  common.CustodianActionFinalizeWorks,
+		Curve: common.CurveEdwards25519Mixin,
+		Extra: encodeDailyWorks(day, aggregateWorks(node.members, votes)),
+	}
+	tx, asset := node.buildKeeperTransaction(ctx, op, out, node.conf.CustodianAppId)
+	if asset != "" {
+		return fid, nil, asset
+	}
+	return fid, []*mtg.Transaction{tx}, ""
+}
+
+// the works of a member is the median of the votes from all other members,
+// because a member always votes zero for itself
+func aggregateWorks(members []party.ID, votes map[string][]byte) []byte {
+	works := make([]byte, len(members))
+	for i, id := range members {
+		var scores []byte
+		for voter, vote := range votes {
+			if voter != string(id) {
+				scores = append(scores, vote[i])
+			}
+		}
+		if len(scores) == 0 {
+			continue
+		}
+		slices.Sort(scores)
+		works[i] = scores[(len(scores)-1)/2]
+	}
+	return works
+}
+
+func dailyWorksId(day time.Time) string {
+	return common.UniqueId("DAILY:WORKS", day.Format(time.DateOnly))
+}
+
+func encodeDailyWorks(day time.Time, works []byte) []byte {
+	extra := binary.BigEndian.AppendUint64(nil, uint64(day.Unix()))
+	return append(extra, works...)
+}
+
+func decodeDailyWorks(extra []byte, size int) (time.Time, []byte, error) {
+	if len(extra) != 8+size {
+		return time.Time{}, nil, fmt.Errorf("invalid works size %d %d", len(extra), size)
+	}
+	day := time.Unix(int64(binary.BigEndian.Uint64(extra[:8])), 0).UTC()
+	if !day.Truncate(DailyWorksPeriod).Equal(day) {
+		return time.Time{}, nil, fmt.Errorf("invalid works day %v", day)
+	}
+	return day, extra[8:], nil
+}
+
 func (s *SQLite3Store) CountDailyWorks(ctx context.Context, members []party.ID, begin, end time.Time) ([]int, error) {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
@@ -52,9 +177,58 @@ func (s *SQLite3Store) CountDailyWorks(ctx context.Context, members []party.ID,
 	return works, nil
 }
 
+func (s *SQLite3Store) WriteWorksVoteIfNotExist(ctx context.Context, day time.Time, signerId string, works []byte, createdAt time.Time) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := "SELECT created_at FROM works_votes WHERE day=? AND signer_id=?"
+	existed, err := s.checkExistence(ctx, tx, query, day, signerId)
+	if err != nil || existed {
+		return err
+	}
+
+	cols := []string{"day", "signer_id", "works", "created_at"}
+	err = s.execOne(ctx, tx, buildInsertionSQL("works_votes", cols), day, signerId, hex.EncodeToString(works), createdAt)
+	if err != nil {
+		return fmt.Errorf("SQLite3Store INSERT works_votes %v", err)
+	}
+	return tx.Commit()
+}
+
+func (s *SQLite3Store) ListWorksVotes(ctx context.Context, day time.Time) (map[string][]byte, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	rows, err := s.db.QueryContext(ctx, "SELECT signer_id, works FROM works_votes WHERE day=?", day)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	votes := make(map[string][]byte)
+	for rows.Next() {
+		var signer, works string
+		err := rows.Scan(&signer, &works)
+		if err != nil {
+			return nil, err
+		}
+		votes[signer] = common.DecodeHexOrPanic(works)
+	}
+	return votes, nil
+}
+
 func normalizeWorks(works []int) []byte {
 	max := slices.Max(works)
 	norms := make([]byte, len(works))
+	if max == 0 {
+		return norms
+	}
 	for i, w := range works {
 		norms[i] = byte(255 * w / max)
 	}
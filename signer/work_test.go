@@ -0,0 +1,53 @@
+package signer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/safe/common"
+	"github.com/gofrs/uuid/v5"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDailyWorks(t *testing.T) {
+	require := require.New(t)
+	ctx, nodes, _ := TestPrepare(require)
+
+	for i, node := range nodes {
+		for j, id := range node.members {
+			if id == node.id {
+				continue
+			}
+			for k := 0; k < (j+1)*10+i; k++ {
+				sid := uuid.Must(uuid.NewV4()).String()
+				err := node.store.WriteSessionWorkIfNotExist(ctx, sid, string(id), 0, []byte("works"))
+				require.Nil(err)
+			}
+		}
+	}
+
+	now := time.Now().Add(DailyWorksPeriod)
+	day := now.UTC().Truncate(DailyWorksPeriod).Add(-DailyWorksPeriod)
+	for _, node := range nodes {
+		works := node.DailyWorks(ctx, now)
+		require.Len(works, len(node.members))
+		require.Equal(byte(0), works[node.Index()])
+		err := node.voteDailyWorks(ctx, now)
+		require.Nil(err)
+	}
+
+	fid := common.UniqueId(dailyWorksId(day), "FINALIZE")
+	for _, node := range nodes {
+		op := testWaitOperation(ctx, node, fid)
+		require.NotNil(op)
+		require.Equal(common.CustodianActionFinalizeWorks, int(op.Type))
+		d, works, err := decodeDailyWorks(op.Extra, len(node.members))
+		require.Nil(err)
+		require.True(d.Equal(day))
+		require.Len(works, 4)
+		require.True(works[0] < works[1])
+		require.True(works[1] < works[2])
+		require.True(works[2] < works[3])
+		require.Equal(byte(255), works[3])
+	}
+}